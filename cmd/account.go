@@ -0,0 +1,176 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The account command lets an administrator answer data requests for a user:
+// export everything stored for them, or delete it all. Until accounts exist
+// the only user is the local one (ID 1), who owns every bookmark.
+//
+// Example usage:
+//
+//	bookmarkd account export --out export.json
+//	bookmarkd account export --out backup.tar.zst
+//	bookmarkd account export --query "tag:project-x" --out project-x.json
+//	bookmarkd account delete --yes
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// accountCmd groups the per-user data subcommands.
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Export or delete all data stored for a user",
+}
+
+var accountExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a user's bookmarks, archives and settings as JSON",
+	Long: `Export everything stored for a user as one JSON document: bookmarks with
+their tags, notes and metadata, every archive version (HTML, screenshots,
+provenance and timestamps) and their settings and rules.
+
+--query takes the search syntax (words, tag:, domain:, before:, after: and
+so on) and exports only the bookmarks it finds, leaving out the rules, to
+hand a curated subset to someone else.
+
+The document is written to --out, or to stdout when --out isn't given. With
+--output json and no --out it is the command's result. An --out ending in
+.tar.zst gets a backup bundle instead: a zstd-compressed tar of the document
+without archive content (export.json) and the archives' HTML, screenshots
+and downloaded files as separate files, which "bookmarkd merge" reads.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runAccountExport(cmd)
+		finishCommand(cmd, "Failed to export account data", res, err)
+	},
+}
+
+var accountDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Permanently delete all of a user's bookmarks, archives and settings",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runAccountDelete(cmd)
+		finishCommand(cmd, "Failed to delete account data", res, err)
+	},
+}
+
+// accountExportResult describes an export written to a file.
+type accountExportResult struct {
+	Path      string `json:"path"`
+	Bookmarks int    `json:"bookmarks"`
+}
+
+// accountDeleteResult is the outcome of "account delete".
+type accountDeleteResult struct {
+	UserID           int64 `json:"user_id"`
+	DeletedBookmarks int   `json:"deleted_bookmarks"`
+}
+
+func runAccountExport(cmd *cobra.Command) (any, error) {
+	userID, err := cmd.Flags().GetInt64("user")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --user: %w", err)
+	}
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --out: %w", err)
+	}
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --query: %w", err)
+	}
+	export, err := withDB(cmd, func(database *db.DB) (core.UserDataExport, error) {
+		return core.ExportUserData(database, userID, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if out == "" {
+		if jsonOutput(cmd) {
+			return export, nil
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return nil, enc.Encode(export)
+	}
+	if strings.HasSuffix(out, ".tar.zst") {
+		if err := writeAccountBundle(out, export); err != nil {
+			return nil, err
+		}
+	} else {
+		body, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode export: %w", err)
+		}
+		// The export holds everything the user saved; keep it private.
+		if err := os.WriteFile(out, append(body, '\n'), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+	log.Printf("Exported %d bookmarks of user %d to %s", len(export.Bookmarks), userID, out)
+	return accountExportResult{Path: out, Bookmarks: len(export.Bookmarks)}, nil
+}
+
+// writeAccountBundle writes export to path as a backup bundle, private to
+// the user running the command like a JSON export.
+func writeAccountBundle(path string, export core.UserDataExport) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := core.WriteUserDataBundle(file, export); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func runAccountDelete(cmd *cobra.Command) (accountDeleteResult, error) {
+	userID, err := cmd.Flags().GetInt64("user")
+	if err != nil {
+		return accountDeleteResult{}, fmt.Errorf("failed to read --user: %w", err)
+	}
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return accountDeleteResult{}, fmt.Errorf("failed to read --yes: %w", err)
+	}
+	if !yes {
+		return accountDeleteResult{}, errors.New("refusing to delete without --yes")
+	}
+	return withDB(cmd, func(database *db.DB) (accountDeleteResult, error) {
+		n, err := database.DeleteUserData(userID)
+		if err != nil {
+			return accountDeleteResult{}, err
+		}
+		log.Printf("Deleted all data of user %d (%d bookmarks)", userID, n)
+		return accountDeleteResult{UserID: userID, DeletedBookmarks: n}, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountExportCmd, accountDeleteCmd)
+
+	for _, c := range []*cobra.Command{accountExportCmd, accountDeleteCmd} {
+		c.Flags().Int64("user", db.LocalUserID, "ID of the user")
+	}
+	accountExportCmd.Flags().String("out", "", "File to write the export to (default stdout); a .tar.zst name writes a backup bundle")
+	accountExportCmd.Flags().String("query", "", "Export only the bookmarks this search finds")
+	accountDeleteCmd.Flags().Bool("yes", false, "Confirm that the data should be deleted; this can't be undone")
+}
@@ -0,0 +1,34 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestAccountCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"export": false, "delete": false}
+	for _, c := range accountCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected account subcommand %s", name)
+		}
+	}
+
+	for _, c := range accountCmd.Commands() {
+		if c.Flags().Lookup("user") == nil {
+			t.Errorf("Expected account %s flag user to be defined", c.Name())
+		}
+	}
+	for _, name := range []string{"out", "query"} {
+		if accountExportCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected account export flag %s to be defined", name)
+		}
+	}
+	if accountDeleteCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected account delete flag yes to be defined")
+	}
+}
@@ -11,6 +11,7 @@ Copyright © 2025 Katie Mulliken <katie@mulliken.net>
 //   - Choose between headless or headful Chrome execution.
 //   - Configure a timeout for each archive job.
 //   - Wait for a specified CSS selector before scraping, helpful for dynamic JS-rendered pages.
+//   - Apply the archive options saved on the settings page (screenshots, mobile viewport, script stripping).
 //
 // Example usage:
 //
@@ -20,12 +21,14 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"runtime"
 	"time"
 
 	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
 	"github.com/spf13/cobra"
 )
 
@@ -34,47 +37,82 @@ var archiveCmd = &cobra.Command{
 	Use:   "archive",
 	Short: "Archive (scrape) bookmarks into the database",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := runArchive(cmd); err != nil {
-			log.Fatalf("Archive failed: %v", err)
-		}
+		res, err := runArchive(cmd)
+		finishCommand(cmd, "Archive failed", res, err)
 	},
 }
 
+// archiveRunResult summarises an archive run for --output=json.
+type archiveRunResult struct {
+	Attempted int                 `json:"attempted"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Skipped   int                 `json:"skipped"`
+	Bookmarks []archiveItemResult `json:"bookmarks"`
+}
+
+// archiveItemResult is the outcome of archiving a single bookmark.
+type archiveItemResult struct {
+	ID     int64  `json:"id"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// add records the outcome of archiving b.
+func (r *archiveRunResult) add(b db.Bookmark, err error) {
+	item := archiveItemResult{ID: b.ID, URL: b.URL, Status: core.ArchiveStatusOK}
+	r.Attempted++
+	switch {
+	case errors.Is(err, core.ErrArchiveDisallowed):
+		item.Status, item.Error = core.ArchiveStatusSkipped, err.Error()
+		r.Skipped++
+	case err != nil:
+		item.Status, item.Error = core.ArchiveStatusError, err.Error()
+		r.Failed++
+	default:
+		r.Succeeded++
+	}
+	r.Bookmarks = append(r.Bookmarks, item)
+}
+
 // runArchive is the main function for the archive command.
-func runArchive(cmd *cobra.Command) error {
-	db, err := initDB(cmd)
+func runArchive(cmd *cobra.Command) (archiveRunResult, error) {
+	res := archiveRunResult{Bookmarks: []archiveItemResult{}}
+
+	database, err := initDB(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return res, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer func() {
-		if err := db.Close(); err != nil {
+		if err := database.Close(); err != nil {
 			log.Printf("failed to close database: %v", err)
 		}
 	}()
 
 	id, err := cmd.Flags().GetInt64("id")
 	if err != nil {
-		return fmt.Errorf("failed to read --id: %w", err)
+		return res, fmt.Errorf("failed to read --id: %w", err)
 	}
 	limit, err := cmd.Flags().GetInt("limit")
 	if err != nil {
-		return fmt.Errorf("failed to read --limit: %w", err)
+		return res, fmt.Errorf("failed to read --limit: %w", err)
 	}
 	timeout, err := cmd.Flags().GetDuration("timeout")
 	if err != nil {
-		return fmt.Errorf("failed to read --timeout: %w", err)
+		return res, fmt.Errorf("failed to read --timeout: %w", err)
 	}
 	waitSelector, err := cmd.Flags().GetString("wait-selector")
 	if err != nil {
-		return fmt.Errorf("failed to read --wait-selector: %w", err)
+		return res, fmt.Errorf("failed to read --wait-selector: %w", err)
 	}
 	chromePath, err := cmd.Flags().GetString("chrome-path")
 	if err != nil {
-		return fmt.Errorf("failed to read --chrome-path: %w", err)
+		return res, fmt.Errorf("failed to read --chrome-path: %w", err)
 	}
 	headful, err := cmd.Flags().GetBool("headful")
 	if err != nil {
-		return fmt.Errorf("failed to read --headful: %w", err)
+		return res, fmt.Errorf("failed to read --headful: %w", err)
 	}
 
 	if chromePath == "" && runtime.GOOS == "darwin" {
@@ -88,40 +126,60 @@ func runArchive(cmd *cobra.Command) error {
 		Timeout:      timeout,
 		WaitSelector: waitSelector,
 	}
+	opts, err = archivePolicy(cmd, opts)
+	if err != nil {
+		return res, err
+	}
+	defer opts.Browsers.Close()
+
+	// Capture the way the background workers would for the user's bookmarks.
+	settings, err := core.ResolveArchiveSettings(database, db.LocalUserID, core.DefaultArchiveSettings())
+	if err != nil {
+		return res, err
+	}
+	opts = settings.Apply(opts)
 
 	ctx := context.Background()
 
 	if id > 0 {
-		b, err := db.GetBookmark(id)
+		b, err := database.GetBookmark(id)
 		if err != nil {
-			return err
+			return res, err
+		}
+		err = core.ArchiveAndPersist(ctx, database, b, opts)
+		res.add(b, err)
+		if res.Skipped > 0 {
+			log.Printf("Skipped id=%d url=%s: %v", b.ID, b.URL, err)
+			return res, nil
 		}
-		return core.ArchiveAndPersist(ctx, db, b, opts)
+		return res, err
 	}
 
-	bookmarks, err := db.ListBookmarksToArchive(limit)
+	bookmarks, err := database.ListBookmarksToArchive(limit)
 	if err != nil {
-		return err
+		return res, err
 	}
 	if len(bookmarks) == 0 {
 		log.Println("No bookmarks to archive.")
-		return nil
+		return res, nil
 	}
 
 	log.Printf("Archiving %d bookmark(s)...", len(bookmarks))
-	var failures int
 	for _, b := range bookmarks {
-		if err := core.ArchiveAndPersist(ctx, db, b, opts); err != nil {
-			failures++
+		err := core.ArchiveAndPersist(ctx, database, b, opts)
+		if errors.Is(err, core.ErrArchiveDisallowed) {
+			log.Printf("Skipped id=%d url=%s: %v", b.ID, b.URL, err)
+		} else if err != nil {
 			log.Printf("Archive failed for id=%d url=%s: %v", b.ID, b.URL, err)
 		}
+		res.add(b, err)
 	}
-	if failures > 0 {
-		return fmt.Errorf("archiving finished with %d failure(s)", failures)
+	if res.Failed > 0 {
+		return res, fmt.Errorf("archiving finished with %d failure(s)", res.Failed)
 	}
 
 	log.Println("Archiving finished successfully.")
-	return nil
+	return res, nil
 }
 
 func init() {
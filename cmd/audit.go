@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The audit command reads every stored archive and reports the ones a
+// crash may have left corrupt: missing, undecompressable or empty HTML,
+// missing downloaded files, truncated base64 data URIs, contradictory
+// archive status columns, rows of deleted bookmarks and unreferenced blobs.
+// --repair queues the affected bookmarks to archive again and deletes the
+// orphaned rows and blobs.
+//
+// Example usage:
+//
+//	bookmarkd audit
+//	bookmarkd audit --repair
+//	bookmarkd audit -o json
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check stored archives for corruption and orphaned rows",
+	Long: `Read every stored archive and report snapshots whose HTML is missing,
+corrupt or empty, whose downloaded file is missing or whose inlined
+resources are truncated, bookmarks whose archive status doesn't match their
+snapshots, rows left behind by deleted bookmarks and stored blobs no
+snapshot uses.
+
+With --repair, bookmarks whose latest snapshot or status is broken are
+queued to archive again (the server's workers pick them up), and orphaned
+rows and unused blobs are deleted. Older broken snapshots are only
+reported.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runAudit(cmd)
+		finishCommand(cmd, "Audit failed", res, err)
+	},
+}
+
+func runAudit(cmd *cobra.Command) (core.AuditResult, error) {
+	repair, err := cmd.Flags().GetBool("repair")
+	if err != nil {
+		return core.AuditResult{}, fmt.Errorf("failed to read --repair: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (core.AuditResult, error) {
+		res, err := core.AuditArchives(context.Background(), database, core.AuditOptions{Repair: repair})
+		if !jsonOutput(cmd) {
+			for _, issue := range res.Issues {
+				fmt.Fprintln(cmd.OutOrStdout(), describeAuditIssue(issue))
+			}
+			log.Printf("Checked %d snapshot(s); found %d issue(s).", res.Checked, len(res.Issues))
+			if repair {
+				log.Printf("Queued %d bookmark(s) to archive again; deleted %d orphaned row(s) and blob(s).", len(res.Queued), res.Deleted)
+			}
+		}
+		return res, err
+	})
+}
+
+// describeAuditIssue formats an issue as a tab-separated line: kind, where
+// it was found and what is wrong.
+func describeAuditIssue(issue core.AuditIssue) string {
+	where := "-"
+	if issue.BookmarkID > 0 {
+		where = fmt.Sprintf("bookmark %d", issue.BookmarkID)
+		if issue.VersionID > 0 {
+			where += fmt.Sprintf(" snapshot %d", issue.VersionID)
+		}
+	}
+	return fmt.Sprintf("%s\t%s\t%s", issue.Kind, where, issue.Detail)
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().Bool("repair", false, "Queue broken archives to archive again and delete orphaned rows and unused blobs")
+}
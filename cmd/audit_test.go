@@ -0,0 +1,35 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+)
+
+func TestAuditCmd_Flags(t *testing.T) {
+	if auditCmd.Flags().Lookup("repair") == nil {
+		t.Error("Expected audit flag repair to be defined")
+	}
+	if err := auditCmd.Args(auditCmd, []string{"extra"}); err == nil {
+		t.Error("Expected audit to take no arguments")
+	}
+}
+
+func TestDescribeAuditIssue(t *testing.T) {
+	tests := []struct {
+		issue core.AuditIssue
+		want  string
+	}{
+		{core.AuditIssue{Kind: core.AuditEmptyHTML, BookmarkID: 3, VersionID: 7, Detail: "HTML is empty"}, "empty-html\tbookmark 3 snapshot 7\tHTML is empty"},
+		{core.AuditIssue{Kind: core.AuditMissingStatus, BookmarkID: 3, Detail: "archived but has no snapshot"}, "missing-status\tbookmark 3\tarchived but has no snapshot"},
+		{core.AuditIssue{Kind: core.AuditOrphanRows, Count: 2, Detail: "2 row(s) in jobs belong to deleted bookmarks"}, "orphan-rows\t-\t2 row(s) in jobs belong to deleted bookmarks"},
+	}
+	for _, tt := range tests {
+		if got := describeAuditIssue(tt.issue); got != tt.want {
+			t.Errorf("describeAuditIssue() = %q, want %q", got, tt.want)
+		}
+	}
+}
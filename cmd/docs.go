@@ -0,0 +1,206 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The docs command generates reference documentation for the CLI.
+//
+// Example usage:
+//
+//	bookmarkd docs man --dir=/usr/local/share/man/man1
+//
+// Shell completion is provided by cobra's built-in completion command:
+//
+//	bookmarkd completion bash > /etc/bash_completion.d/bookmarkd
+//	bookmarkd completion zsh > "${fpath[1]}/_bookmarkd"
+//	bookmarkd completion fish > ~/.config/fish/completions/bookmarkd.fish
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// docsCmd groups documentation generators.
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate reference documentation for the CLI",
+}
+
+// docsManCmd writes one man page per command.
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages (section 1) for every command",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runDocsMan(cmd)
+		finishCommand(cmd, "Man page generation failed", res, err)
+	},
+}
+
+// docsManResult lists the generated files for --output=json.
+type docsManResult struct {
+	Files []string `json:"files"`
+}
+
+func runDocsMan(cmd *cobra.Command) (docsManResult, error) {
+	res := docsManResult{Files: []string{}}
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return res, fmt.Errorf("failed to read --dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return res, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	files, err := writeManTree(rootCmd, dir, time.Now())
+	res.Files = append(res.Files, files...)
+	if err != nil {
+		return res, err
+	}
+	log.Printf("Wrote %d man page(s) to %s", len(files), dir)
+	return res, nil
+}
+
+// writeManTree writes a man page for cmd and each of its visible
+// subcommands into dir, returning the paths written.
+func writeManTree(cmd *cobra.Command, dir string, date time.Time) ([]string, error) {
+	var files []string
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		sub, err := writeManTree(c, dir, date)
+		files = append(files, sub...)
+		if err != nil {
+			return files, err
+		}
+	}
+
+	path := filepath.Join(dir, manPageName(cmd)+".1")
+	var buf bytes.Buffer
+	writeManPage(&buf, cmd, date)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return files, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return append(files, path), nil
+}
+
+// manPageName is the command path joined with dashes, e.g. "bookmarkd-archive".
+func manPageName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// writeManPage renders a roff man page for cmd.
+func writeManPage(w io.Writer, cmd *cobra.Command, date time.Time) {
+	name := manPageName(cmd)
+	fmt.Fprintf(w, ".TH %q 1 %q \"bookmarkd\" \"User Commands\"\n", strings.ToUpper(name), date.Format("Jan 2006"))
+
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintf(w, "%s \\- %s\n", roffEscape(name), roffEscape(cmd.Short))
+
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintf(w, "\\fB%s\\fP\n", roffEscape(cmd.UseLine()))
+
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	desc := cmd.Long
+	if desc == "" {
+		desc = cmd.Short
+	}
+	for _, para := range strings.Split(strings.TrimSpace(desc), "\n\n") {
+		fmt.Fprintln(w, ".PP")
+		fmt.Fprintln(w, roffEscape(para))
+	}
+
+	writeManFlags(w, "OPTIONS", cmd.NonInheritedFlags())
+	writeManFlags(w, "OPTIONS INHERITED FROM PARENT COMMANDS", cmd.InheritedFlags())
+
+	if cmd.Example != "" {
+		fmt.Fprintln(w, ".SH EXAMPLE")
+		fmt.Fprintln(w, ".nf")
+		fmt.Fprintln(w, roffEscape(cmd.Example))
+		fmt.Fprintln(w, ".fi")
+	}
+
+	var related []string
+	if cmd.HasParent() {
+		related = append(related, manPageName(cmd.Parent()))
+	}
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() && !c.IsAdditionalHelpTopicCommand() {
+			related = append(related, manPageName(c))
+		}
+	}
+	if len(related) > 0 {
+		fmt.Fprintln(w, ".SH SEE ALSO")
+		for i, r := range related {
+			sep := ","
+			if i == len(related)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(w, "\\fB%s\\fP(1)%s\n", roffEscape(r), sep)
+		}
+	}
+}
+
+// writeManFlags renders a flag set as a roff section; empty sets are skipped.
+func writeManFlags(w io.Writer, title string, flags *pflag.FlagSet) {
+	if !flags.HasAvailableFlags() {
+		return
+	}
+	fmt.Fprintf(w, ".SH %s\n", title)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		fmt.Fprintln(w, ".TP")
+		name := "\\fB\\-\\-" + roffEscape(f.Name) + "\\fP"
+		if f.Shorthand != "" && f.ShorthandDeprecated == "" {
+			name = "\\fB\\-" + roffEscape(f.Shorthand) + "\\fP, " + name
+		}
+		if f.Value.Type() != "bool" {
+			name += "=" + roffEscape(f.DefValue)
+		}
+		fmt.Fprintln(w, name)
+		fmt.Fprintln(w, roffEscape(f.Usage))
+	})
+}
+
+// roffEscape escapes backslashes and protects lines that would otherwise be
+// read as roff requests.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// completeFixed returns a flag completion function offering fixed values.
+func completeFixed(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.AddCommand(docsManCmd)
+
+	docsManCmd.Flags().String("dir", "man", "Directory to write man pages to")
+	if err := docsManCmd.MarkFlagDirname("dir"); err != nil {
+		log.Fatalf("Failed to mark --dir as a directory: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestDocsManCmd_Flags(t *testing.T) {
+	dir, err := docsManCmd.Flags().GetString("dir")
+	if err != nil {
+		t.Fatalf("Failed to get dir flag: %v", err)
+	}
+	if dir != "man" {
+		t.Errorf("Expected dir to default to man, got %s", dir)
+	}
+}
+
+func TestWriteManTree(t *testing.T) {
+	dir := t.TempDir()
+	date := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	files, err := writeManTree(rootCmd, dir, date)
+	if err != nil {
+		t.Fatalf("writeManTree() error = %v", err)
+	}
+
+	for _, name := range []string{"bookmarkd.1", "bookmarkd-archive.1", "bookmarkd-refresh-metadata.1", "bookmarkd-docs-man.1"} {
+		path := filepath.Join(dir, name)
+		found := false
+		for _, f := range files {
+			if f == path {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s in generated files %v", name, files)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "bookmarkd-archive.1"))
+	if err != nil {
+		t.Fatalf("Failed to read man page: %v", err)
+	}
+	for _, want := range []string{
+		`.TH "BOOKMARKD-ARCHIVE" 1 "Mar 2025"`,
+		".SH OPTIONS",
+		`\fB\-\-limit\fP=0`,
+		".SH OPTIONS INHERITED FROM PARENT COMMANDS",
+		`\fB\-o\fP, \fB\-\-output\fP=text`,
+		`\fBbookmarkd\fP(1)`,
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Expected man page to contain %q\n%s", want, page)
+		}
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	tests := map[string]string{
+		"plain":            "plain",
+		`back\slash`:       `back\eslash`,
+		"--flag":           `\-\-flag`,
+		".TH at start":     `\&.TH at start`,
+		"line\n'quote":     "line\n\\&'quote",
+		"mid.dle is fine.": "mid.dle is fine.",
+	}
+	for in, want := range tests {
+		if got := roffEscape(in); got != want {
+			t.Errorf("roffEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	t.Run("bash script generates", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := rootCmd.GenBashCompletionV2(&buf, true); err != nil {
+			t.Fatalf("GenBashCompletionV2() error = %v", err)
+		}
+		if !strings.Contains(buf.String(), "bookmarkd") {
+			t.Error("Expected completion script to mention bookmarkd")
+		}
+	})
+
+	t.Run("enumerated flag values", func(t *testing.T) {
+		for flag, want := range map[string][]string{
+			"output":        {"text", "json"},
+			"archive-store": {"sqlite", "dir", "s3"},
+		} {
+			fn, ok := rootCmd.GetFlagCompletionFunc(flag)
+			if !ok {
+				t.Errorf("Expected completion for --%s", flag)
+				continue
+			}
+			got, directive := fn(rootCmd, nil, "")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("--%s completions = %v, want %v", flag, got, want)
+			}
+			if directive != cobra.ShellCompDirectiveNoFileComp {
+				t.Errorf("--%s directive = %v, want NoFileComp", flag, directive)
+			}
+		}
+	})
+}
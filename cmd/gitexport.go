@@ -0,0 +1,106 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The git-export command writes a user's bookmarks into a local git
+// repository as Markdown and commits the changes, giving a versioned
+// plain-text mirror of the collection. The server can do the same on a
+// schedule with --git-export-dir.
+//
+// Example usage:
+//
+//	bookmarkd git-export --dir ~/bookmarks-mirror
+//	bookmarkd git-export --dir ~/bookmarks-mirror --articles --remote origin
+//	bookmarkd git-export --dir ~/project-x --query "tag:project-x"
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+var gitExportCmd = &cobra.Command{
+	Use:   "git-export",
+	Short: "Export bookmarks to a git repository as Markdown and commit",
+	Long: `Write every bookmark of a user into a git repository as Markdown and commit
+the changes. Each bookmark becomes bookmarks/{slug}.md (front matter with the
+URL, title, tags and flags, followed by the notes); --articles adds the
+reader-mode text of archived pages as articles/{slug}.md. README.md lists
+everything. The repository is created if needed, and nothing is committed when
+nothing changed.
+
+--query takes the search syntax (words, tag:, domain:, before:, after: and so
+on) and exports only the bookmarks it finds; files of bookmarks that stop
+matching are removed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runGitExport(cmd)
+		finishCommand(cmd, "Git export failed", res, err)
+	},
+}
+
+func runGitExport(cmd *cobra.Command) (core.GitExportResult, error) {
+	opts, err := gitExportOptions(cmd, "")
+	if err != nil {
+		return core.GitExportResult{}, err
+	}
+	if opts.Dir == "" {
+		return core.GitExportResult{}, errors.New("--dir is required")
+	}
+	return withDB(cmd, func(database *db.DB) (core.GitExportResult, error) {
+		res, err := core.ExportToGit(context.Background(), database, opts)
+		if err != nil {
+			return res, err
+		}
+		if res.Commit == "" {
+			log.Printf("Exported %d bookmark(s) to %s; nothing changed", res.Bookmarks, res.Dir)
+		} else {
+			log.Printf("Exported %d bookmark(s) and %d article(s) to %s as %s", res.Bookmarks, res.Articles, res.Dir, res.Commit)
+		}
+		return res, nil
+	})
+}
+
+// gitExportOptions reads the git export flags, named prefix+"dir" and so
+// on, into options.
+func gitExportOptions(cmd *cobra.Command, prefix string) (core.GitExportOptions, error) {
+	var opts core.GitExportOptions
+	var err error
+	flags := cmd.Flags()
+	if opts.Dir, err = flags.GetString(prefix + "dir"); err != nil {
+		return opts, fmt.Errorf("failed to read --%sdir: %w", prefix, err)
+	}
+	if opts.UserID, err = flags.GetInt64(prefix + "user"); err != nil {
+		return opts, fmt.Errorf("failed to read --%suser: %w", prefix, err)
+	}
+	if opts.Articles, err = flags.GetBool(prefix + "articles"); err != nil {
+		return opts, fmt.Errorf("failed to read --%sarticles: %w", prefix, err)
+	}
+	if opts.Remote, err = flags.GetString(prefix + "remote"); err != nil {
+		return opts, fmt.Errorf("failed to read --%sremote: %w", prefix, err)
+	}
+	if opts.Query, err = flags.GetString(prefix + "query"); err != nil {
+		return opts, fmt.Errorf("failed to read --%squery: %w", prefix, err)
+	}
+	return opts, nil
+}
+
+// addGitExportFlags defines the flags gitExportOptions reads.
+func addGitExportFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().String(prefix+"dir", "", "Git repository to export into (created if missing)")
+	cmd.Flags().Int64(prefix+"user", db.LocalUserID, "ID of the user whose bookmarks are exported")
+	cmd.Flags().Bool(prefix+"articles", false, "Also export the reader-mode text of archived pages as Markdown")
+	cmd.Flags().String(prefix+"remote", "", "Remote to push to after each export, e.g. origin (default: don't push)")
+	cmd.Flags().String(prefix+"query", "", "Export only the bookmarks this search finds (default: all)")
+}
+
+func init() {
+	rootCmd.AddCommand(gitExportCmd)
+	addGitExportFlags(gitExportCmd, "")
+}
@@ -0,0 +1,28 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestGitExportCmd_Flags(t *testing.T) {
+	for _, name := range []string{"dir", "user", "articles", "remote", "query"} {
+		if gitExportCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected git-export flag %s to be defined", name)
+		}
+		if rootCmd.Flags().Lookup("git-export-"+name) == nil {
+			t.Errorf("Expected serve flag git-export-%s to be defined", name)
+		}
+	}
+	if rootCmd.Flags().Lookup("git-export-interval") == nil {
+		t.Error("Expected serve flag git-export-interval to be defined")
+	}
+
+	opts, err := gitExportOptions(gitExportCmd, "")
+	if err != nil {
+		t.Fatalf("gitExportOptions() error = %v", err)
+	}
+	if opts.Dir != "" || opts.Articles || opts.Remote != "" || opts.Query != "" || opts.UserID == 0 {
+		t.Errorf("Unexpected default options: %+v", opts)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The import command brings bookmarks over from other bookmark managers,
+// or picks the URLs out of plain text and Markdown files (--format=txt|md).
+// Titles, tags, descriptions, notes, collections, creation dates and read
+// flags are kept; when the other tool archived a page, its archive date or
+// snapshot URL is recorded in the notes. URLs that are already bookmarked
+// are skipped, so an import can safely be re-run. Progress is checkpointed
+// as it goes: if an import is interrupted, running it again on the same
+// file with the same --tags resumes where it stopped.
+//
+// Imported bookmarks are archived by the server's queue like any other new
+// bookmark, or with "bookmarkd archive" (see --skip-archive). They belong to
+// the first account unless --user names another.
+//
+// Example usage:
+//
+//	bookmarkd import linkding bookmarks.json
+//	bookmarkd import linkwarden backup.json --tags imported
+//	bookmarkd import pocket ril_export.html --user alice
+//	bookmarkd import --format=md notes/reading.md --tags reading
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// importCmd groups the importers, one subcommand per bookmark manager. Run
+// with a file and --format, it imports any of core.ImportFormats, which is
+// how the text formats without a subcommand are reached.
+var importCmd = &cobra.Command{
+	Use:   "import [--format=txt|md] <file>",
+	Short: "Import bookmarks from other bookmark managers or text files",
+	Long: `Import bookmarks from another bookmark manager's export with one of the
+subcommands below, or pick the links out of a text file with --format:
+
+  txt  every http(s) URL in a plain text file, such as a hand-kept links.txt
+  md   the links of a Markdown file, such as a note app's export; link
+       texts become titles, and images are left out
+
+Titles missing from text files are fetched like those of any new bookmark.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			_ = cmd.Help()
+			return
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err == nil && format == "" {
+			err = errors.New("--format is required to import a file without a subcommand")
+		}
+		var res core.ImportResult
+		if err == nil {
+			res, err = runImport(cmd, args[0], format)
+		}
+		finishCommand(cmd, "Failed to import "+args[0], res, err)
+	},
+}
+
+var importLinkdingCmd = &cobra.Command{
+	Use:   "linkding <file>",
+	Short: "Import a linkding JSON export",
+	Long: `Import bookmarks from a linkding JSON export: the output of its REST API
+(GET /api/bookmarks/?limit=...), either the whole page or its "results" array.
+Web archive snapshot URLs are recorded in each bookmark's notes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runImport(cmd, args[0], "linkding")
+		finishCommand(cmd, "Failed to import linkding export", res, err)
+	},
+}
+
+var importLinkwardenCmd = &cobra.Command{
+	Use:   "linkwarden <file>",
+	Short: "Import a Linkwarden data export",
+	Long: `Import the links of a Linkwarden data export (Settings → Data → Export).
+Collections carry over, except Linkwarden's default "Unorganized", and the
+date Linkwarden last preserved each link is recorded in its notes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runImport(cmd, args[0], "linkwarden")
+		finishCommand(cmd, "Failed to import Linkwarden export", res, err)
+	},
+}
+
+var importPocketCmd = &cobra.Command{
+	Use:   "pocket <file>",
+	Short: "Import a Pocket export (ril_export.html or CSV)",
+	Long: `Import a Pocket export: either the ril_export.html page or the CSV file of
+later exports. Tags and time added carry over, and items in Pocket's archive
+are marked read.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runImport(cmd, args[0], "pocket")
+		finishCommand(cmd, "Failed to import Pocket export", res, err)
+	},
+}
+
+// runImport parses the export at path as the named core.ImportFormats
+// format and imports it.
+func runImport(cmd *cobra.Command, path, format string) (core.ImportResult, error) {
+	f, ok := core.ImportFormats[format]
+	if !ok {
+		return core.ImportResult{}, fmt.Errorf("unknown import format %q", format)
+	}
+	tags, err := cmd.Flags().GetStringSlice("tags")
+	if err != nil {
+		return core.ImportResult{}, fmt.Errorf("failed to read --tags: %w", err)
+	}
+	skipArchive, err := cmd.Flags().GetBool("skip-archive")
+	if err != nil {
+		return core.ImportResult{}, fmt.Errorf("failed to read --skip-archive: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return core.ImportResult{}, fmt.Errorf("failed to open export: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close export: %v", err)
+		}
+	}()
+	items, err := f.Parse(file)
+	if err != nil {
+		return core.ImportResult{}, err
+	}
+	// The bookmarks are recorded as imported from this file, so a bad
+	// import can be found and cleaned up later.
+	source := db.BookmarkSource(db.SourceImport, filepath.Base(path))
+	for i := range items {
+		items[i].Source = source
+		items[i].SkipArchive = items[i].SkipArchive || skipArchive
+	}
+
+	return withDB(cmd, func(database *db.DB) (core.ImportResult, error) {
+		scoped, err := userDB(cmd, database)
+		if err != nil {
+			return core.ImportResult{}, err
+		}
+		return core.ImportBookmarks(scoped, f.Source, items, tags)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importLinkdingCmd, importLinkwardenCmd, importPocketCmd)
+
+	importCmd.PersistentFlags().StringSlice("tags", nil, "Tags to add to every imported bookmark (comma-separated)")
+	importCmd.PersistentFlags().Bool("skip-archive", false, "Never archive the imported bookmarks automatically")
+	importCmd.PersistentFlags().String("user", "", "Username to import the bookmarks for (default: the first account)")
+	importCmd.Flags().String("format", "", "Format of the file to import: txt or md (or linkding, linkwarden or pocket)")
+}
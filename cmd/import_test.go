@@ -0,0 +1,29 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestImportCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"linkding": false, "linkwarden": false, "pocket": false}
+	for _, c := range importCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected import subcommand %s", name)
+		}
+	}
+
+	for _, name := range []string{"tags", "skip-archive"} {
+		if importCmd.PersistentFlags().Lookup(name) == nil {
+			t.Errorf("Expected import flag %s to be defined", name)
+		}
+	}
+	if importCmd.Flags().Lookup("format") == nil {
+		t.Error("Expected import flag format to be defined")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The links command works with the outbound links recorded from archived
+// pages. Archiving records them as it goes; "links rebuild" extracts them
+// from existing archives. "links backlinks" lists the saved pages that link
+// to a URL, or to a domain and its subdomains.
+//
+// Example usage:
+//
+//	bookmarkd links rebuild
+//	bookmarkd links backlinks https://example.com/post
+//	bookmarkd links backlinks example.com -o json
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// linksCmd groups the links subcommands.
+var linksCmd = &cobra.Command{
+	Use:   "links",
+	Short: "Query links between archived pages",
+}
+
+var linksRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Extract links from every bookmark's latest archive",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runLinksRebuild(cmd)
+		finishCommand(cmd, "Failed to rebuild links", res, err)
+	},
+}
+
+var linksBacklinksCmd = &cobra.Command{
+	Use:   "backlinks <url|domain>",
+	Short: "List saved pages that link to a URL or domain",
+	Long: `List the bookmarks whose latest archive links to a page or a domain.
+
+A URL matches regardless of scheme, "www.", a trailing slash or the fragment;
+a domain also matches its subdomains.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runLinksBacklinks(cmd, args[0])
+		finishCommand(cmd, "Failed to list backlinks", res, err)
+	},
+}
+
+// backlinkResult describes a backlink in command output.
+type backlinkResult struct {
+	ID    int64  `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+func runLinksRebuild(cmd *cobra.Command) (core.RebuildLinksResult, error) {
+	return withDB(cmd, func(database *db.DB) (core.RebuildLinksResult, error) {
+		res, err := core.RebuildLinks(database)
+		if err != nil {
+			return res, err
+		}
+		log.Printf("Recorded %d link(s) from %d archive(s) (%d failed).", res.Links, res.Bookmarks, res.Failed)
+		return res, nil
+	})
+}
+
+func runLinksBacklinks(cmd *cobra.Command, target string) ([]backlinkResult, error) {
+	q, err := core.NewBacklinkQuery(target)
+	if err != nil {
+		return nil, err
+	}
+	return withDB(cmd, func(database *db.DB) ([]backlinkResult, error) {
+		backlinks, err := database.ListBacklinks(q)
+		if err != nil {
+			return nil, err
+		}
+		res := []backlinkResult{}
+		for _, b := range backlinks {
+			res = append(res, backlinkResult{ID: b.BookmarkID, URL: b.URL, Title: b.Title, Link: b.LinkURL})
+			if !jsonOutput(cmd) {
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\n", b.BookmarkID, b.URL, b.LinkURL)
+			}
+		}
+		return res, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(linksCmd)
+	linksCmd.AddCommand(linksRebuildCmd, linksBacklinksCmd)
+}
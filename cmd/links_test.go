@@ -0,0 +1,23 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestLinksCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"rebuild": false, "backlinks": false}
+	for _, c := range linksCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected links subcommand %s", name)
+		}
+	}
+	if err := linksBacklinksCmd.Args(linksBacklinksCmd, nil); err == nil {
+		t.Error("Expected links backlinks to require a URL or domain")
+	}
+}
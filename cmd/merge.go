@@ -0,0 +1,97 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The merge command consolidates instances: it merges the bookmarks of
+// another instance's backup bundle or account export into this database
+// without losing
+// either side's data. Bookmarks are matched by URL; tags are combined, the
+// newer title wins, archive versions from both are kept, and every field
+// the two disagreed on is reported as a conflict. Merging the same export
+// again changes nothing.
+//
+// Example usage:
+//
+//	bookmarkd account export --out laptop.tar.zst   # on the other instance
+//	bookmarkd merge --from=laptop.tar.zst
+//	bookmarkd merge --from laptop.json.gz --user alice --output json
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge another instance's backup bundle or account export into this database",
+	Long: `Merge the bookmarks of a backup bundle ("bookmarkd account export --out
+backup.tar.zst") or an account export (JSON, plain, gzip- or zstd-compressed)
+from another instance into this database.
+
+Bookmarks are matched by URL. New ones are added with their tags, notes,
+metadata, favicon and archive versions. For ones both sides have, tags are
+combined, read and favorite flags are kept if either side set them, the title
+of the more recently created copy wins, a local collection is kept, differing
+notes are appended, and archive versions not captured at the same time are
+added. Titles, collections and notes that differed are reported as conflicts.
+
+The export's settings, rules, presets and shared collections aren't merged.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runMerge(cmd)
+		finishCommand(cmd, "Failed to merge export", res, err)
+	},
+}
+
+func runMerge(cmd *cobra.Command) (core.MergeResult, error) {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return core.MergeResult{}, fmt.Errorf("failed to read --from: %w", err)
+	}
+	if from == "" {
+		return core.MergeResult{}, errors.New("--from is required")
+	}
+	file, err := os.Open(from)
+	if err != nil {
+		return core.MergeResult{}, fmt.Errorf("failed to open %s: %w", from, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("failed to close %s: %v", from, err)
+		}
+	}()
+	export, err := core.ReadUserDataExport(file)
+	if err != nil {
+		return core.MergeResult{}, err
+	}
+
+	return withDB(cmd, func(database *db.DB) (core.MergeResult, error) {
+		scoped, err := userDB(cmd, database)
+		if err != nil {
+			return core.MergeResult{}, err
+		}
+		res, err := core.MergeUserData(scoped, export)
+		if err != nil {
+			return res, err
+		}
+		if !jsonOutput(cmd) {
+			for _, c := range res.Conflicts {
+				fmt.Fprintf(cmd.OutOrStdout(), "conflict: %s %s: local %q, incoming %q; kept %s\n", c.URL, c.Field, c.Local, c.Incoming, c.Kept)
+			}
+		}
+		return res, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().String("from", "", "Backup bundle (.tar.zst) or account export (JSON, optionally gzip- or zstd-compressed) to merge")
+	mergeCmd.Flags().String("user", "", "Username to merge the bookmarks into (default: the first account)")
+}
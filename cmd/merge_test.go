@@ -0,0 +1,17 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestMergeCmd_Flags(t *testing.T) {
+	for _, name := range []string{"from", "user"} {
+		if mergeCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected merge flag %s to be defined", name)
+		}
+	}
+	if err := mergeCmd.Args(mergeCmd, []string{"extra"}); err == nil {
+		t.Error("Expected merge to take no arguments")
+	}
+}
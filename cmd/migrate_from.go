@@ -0,0 +1,187 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The migrate-from command copies a whole bookmarkd database into a new,
+// empty one. The source may be from any earlier release: it is snapshotted
+// and the snapshot upgraded to the current schema, so the original file is
+// never written to. Archive blobs are moved into whichever archive store the
+// destination is configured with, which makes this the upgrade path from
+// inline SQLite blobs to a directory or S3 store.
+//
+// Example usage:
+//
+//	bookmarkd migrate-from --source old.db --db new.db
+//	bookmarkd migrate-from --source old.db --db new.db --archive-store s3 --s3-bucket archives
+//	bookmarkd migrate-from --source old.db --source-archive-store dir --source-archive-dir ./old-archives --db new.db
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+var migrateFromCmd = &cobra.Command{
+	Use:   "migrate-from",
+	Short: "Copy all data from another bookmarkd database into an empty one",
+	Long: `Copy every bookmark, archive version, setting and rule from the database at
+--source into the (empty) database at --db, and every archive blob into the
+archive store configured with --archive-store.
+
+The source is opened read-only and snapshotted; the snapshot is upgraded to
+the current schema before copying, so databases from older releases can be
+migrated directly. Every blob is checked against its content hash as it is
+read and again after it is written, and row counts are compared table by
+table once the copy is done.
+
+Only SQLite databases are supported in this build.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runMigrateFrom(cmd)
+		finishCommand(cmd, "Failed to migrate data", res, err)
+	},
+}
+
+// migrateFromResult is the outcome of "migrate-from".
+type migrateFromResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	db.CopyReport
+}
+
+func runMigrateFrom(cmd *cobra.Command) (migrateFromResult, error) {
+	flags := cmd.Flags()
+	source, err := flags.GetString("source")
+	if err != nil {
+		return migrateFromResult{}, fmt.Errorf("failed to read --source: %w", err)
+	}
+	if source == "" {
+		return migrateFromResult{}, errors.New("--source is required")
+	}
+	dest, err := flags.GetString("db")
+	if err != nil {
+		return migrateFromResult{}, fmt.Errorf("failed to get database path: %w", err)
+	}
+	if err := checkMigrateFromPaths(source, dest); err != nil {
+		return migrateFromResult{}, err
+	}
+	var srcStore core.ArchiveStoreConfig
+	if srcStore.Backend, err = flags.GetString("source-archive-store"); err != nil {
+		return migrateFromResult{}, fmt.Errorf("failed to read --source-archive-store: %w", err)
+	}
+	if srcStore.Dir, err = flags.GetString("source-archive-dir"); err != nil {
+		return migrateFromResult{}, fmt.Errorf("failed to read --source-archive-dir: %w", err)
+	}
+	if srcStore.Backend == core.ArchiveStoreS3 {
+		return migrateFromResult{}, errors.New("--source-archive-store must be sqlite or dir")
+	}
+
+	src, cleanup, err := openMigrationSource(source, srcStore)
+	if err != nil {
+		return migrateFromResult{}, err
+	}
+	defer cleanup()
+
+	report, err := withDB(cmd, func(database *db.DB) (db.CopyReport, error) {
+		return database.CopyFrom(context.Background(), src)
+	})
+	if err != nil {
+		return migrateFromResult{}, err
+	}
+	var rows int64
+	for _, n := range report.Rows {
+		rows += n
+	}
+	log.Printf("Copied %d rows in %d tables and %d archive blobs from %s to %s",
+		rows, len(report.Rows), report.Blobs, source, dest)
+	return migrateFromResult{Source: source, Destination: dest, CopyReport: report}, nil
+}
+
+// checkMigrateFromPaths makes sure the source exists and isn't also the
+// destination.
+func checkMigrateFromPaths(source, dest string) error {
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("failed to open source database: %w", err)
+	}
+	srcAbs, err := filepath.Abs(source)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+	destAbs, err := filepath.Abs(dest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination path: %w", err)
+	}
+	if srcAbs == destAbs {
+		return errors.New("--source and --db must be different databases")
+	}
+	return nil
+}
+
+// openMigrationSource snapshots the database at path into a temporary file,
+// upgrades the snapshot to the current schema and returns it with the given
+// archive store attached. The cleanup function closes it and removes the
+// snapshot.
+func openMigrationSource(path string, storeCfg core.ArchiveStoreConfig) (*db.DB, func(), error) {
+	orig, err := db.NewSQLiteDB("file:" + path + "?mode=ro")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open source database: %w", err)
+	}
+	tmp, err := os.MkdirTemp("", "bookmarkd-migrate-")
+	if err != nil {
+		_ = orig.Close()
+		return nil, nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	removeTmp := func() {
+		if err := os.RemoveAll(tmp); err != nil {
+			log.Printf("failed to remove snapshot: %v", err)
+		}
+	}
+	snapshot := filepath.Join(tmp, "source.db")
+	err = orig.SnapshotTo(snapshot)
+	if cerr := orig.Close(); cerr != nil {
+		log.Printf("failed to close source database: %v", cerr)
+	}
+	if err != nil {
+		removeTmp()
+		return nil, nil, err
+	}
+
+	src, err := db.NewSQLiteDB(snapshot)
+	if err != nil {
+		removeTmp()
+		return nil, nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	cleanup := func() {
+		if err := src.Close(); err != nil {
+			log.Printf("failed to close snapshot: %v", err)
+		}
+		removeTmp()
+	}
+	if err := src.Migrate(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to upgrade source schema: %w", err)
+	}
+	store, err := core.OpenArchiveStore(storeCfg, src)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open source archive store: %w", err)
+	}
+	src.SetBlobStore(store)
+	return src, cleanup, nil
+}
+
+func init() {
+	rootCmd.AddCommand(migrateFromCmd)
+
+	migrateFromCmd.Flags().String("source", "", "Path to the SQLite database to copy from")
+	migrateFromCmd.Flags().String("source-archive-store", core.ArchiveStoreSQLite, "Where the source keeps archive blobs: sqlite or dir")
+	migrateFromCmd.Flags().String("source-archive-dir", "", "Directory of the source's dir archive store")
+}
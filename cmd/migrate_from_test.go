@@ -0,0 +1,35 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateFromCmd_Flags(t *testing.T) {
+	for _, name := range []string{"source", "source-archive-store", "source-archive-dir"} {
+		if migrateFromCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected migrate-from flag %s to be defined", name)
+		}
+	}
+}
+
+func TestCheckMigrateFromPaths(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "old.db")
+	if err := checkMigrateFromPaths(source, filepath.Join(dir, "new.db")); err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+	if err := os.WriteFile(source, nil, 0o600); err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	if err := checkMigrateFromPaths(source, filepath.Join(dir, ".", "old.db")); err == nil {
+		t.Error("Expected an error when source and destination are the same")
+	}
+	if err := checkMigrateFromPaths(source, filepath.Join(dir, "new.db")); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
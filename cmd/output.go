@@ -0,0 +1,78 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// commandResult is the envelope every command prints in --output=json mode:
+// a single JSON object on stdout, with logs still going to stderr.
+type commandResult struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+// outputFormat returns the validated value of the --output flag.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", fmt.Errorf("failed to read --output: %w", err)
+	}
+	switch format {
+	case outputText, outputJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want text or json)", format)
+	}
+}
+
+// jsonOutput reports whether the command should emit machine-readable results.
+func jsonOutput(cmd *cobra.Command) bool {
+	format, err := outputFormat(cmd)
+	return err == nil && format == outputJSON
+}
+
+// writeJSON writes v to w as a single line of JSON.
+func writeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// finishCommand reports a command's outcome and exits with status 1 if err is
+// non-nil. In --output=json mode result and err are written to stdout as a
+// commandResult so scripts always get a parseable document; in text mode the
+// command has already logged its progress and only the error is logged.
+func finishCommand(cmd *cobra.Command, msg string, result any, err error) {
+	if !jsonOutput(cmd) {
+		if err != nil {
+			log.Fatalf("%s: %v", msg, err)
+		}
+		return
+	}
+
+	out := commandResult{OK: err == nil, Result: result}
+	if err != nil {
+		out.Error = err.Error()
+		log.Printf("%s: %v", msg, err)
+	}
+	if werr := writeJSON(cmd.OutOrStdout(), out); werr != nil {
+		log.Printf("failed to write JSON output: %v", werr)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
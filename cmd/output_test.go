@@ -0,0 +1,111 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// newOutputTestCmd returns a command with its own --output flag so tests
+// don't mutate the shared rootCmd flags.
+func newOutputTestCmd(t *testing.T, format string) (*cobra.Command, *bytes.Buffer) {
+	t.Helper()
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("output", outputText, "")
+	if err := cmd.Flags().Set("output", format); err != nil {
+		t.Fatalf("Failed to set --output: %v", err)
+	}
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	return cmd, &buf
+}
+
+func TestRootCmd_OutputFlag(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("output")
+	if flag == nil {
+		t.Fatal("Expected persistent flag output to be defined")
+	}
+	if flag.DefValue != "text" {
+		t.Errorf("Expected output to default to text, got %s", flag.DefValue)
+	}
+	if flag.Shorthand != "o" {
+		t.Errorf("Expected output shorthand -o, got %q", flag.Shorthand)
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		cmd, _ := newOutputTestCmd(t, format)
+		got, err := outputFormat(cmd)
+		if err != nil {
+			t.Errorf("outputFormat(%q) error = %v", format, err)
+		}
+		if got != format {
+			t.Errorf("outputFormat(%q) = %q", format, got)
+		}
+	}
+
+	cmd, _ := newOutputTestCmd(t, "yaml")
+	if _, err := outputFormat(cmd); err == nil {
+		t.Error("Expected an error for --output=yaml")
+	}
+	if jsonOutput(cmd) {
+		t.Error("Expected jsonOutput to be false for an invalid format")
+	}
+}
+
+func TestFinishCommand(t *testing.T) {
+	t.Run("json mode writes an envelope", func(t *testing.T) {
+		cmd, buf := newOutputTestCmd(t, "json")
+		finishCommand(cmd, "Test failed", archiveRunResult{Attempted: 2, Succeeded: 2}, nil)
+
+		var got struct {
+			OK     bool             `json:"ok"`
+			Error  string           `json:"error"`
+			Result archiveRunResult `json:"result"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+		}
+		if !got.OK || got.Error != "" {
+			t.Errorf("Expected ok without error, got %+v", got)
+		}
+		if got.Result.Attempted != 2 || got.Result.Succeeded != 2 {
+			t.Errorf("Unexpected result: %+v", got.Result)
+		}
+	})
+
+	t.Run("text mode prints nothing on success", func(t *testing.T) {
+		cmd, buf := newOutputTestCmd(t, "text")
+		finishCommand(cmd, "Test failed", archiveRunResult{Attempted: 1}, nil)
+		if buf.Len() != 0 {
+			t.Errorf("Expected no stdout output in text mode, got %q", buf.String())
+		}
+	})
+}
+
+func TestArchiveRunResult_Add(t *testing.T) {
+	var res archiveRunResult
+	res.add(db.Bookmark{ID: 1, URL: "https://example.com/ok"}, nil)
+	res.add(db.Bookmark{ID: 2, URL: "https://example.com/broken"}, errors.New("timeout"))
+
+	if res.Attempted != 2 || res.Succeeded != 1 || res.Failed != 1 {
+		t.Errorf("Unexpected counts: %+v", res)
+	}
+	if len(res.Bookmarks) != 2 {
+		t.Fatalf("Expected 2 bookmark results, got %d", len(res.Bookmarks))
+	}
+	if res.Bookmarks[0].Status != "ok" || res.Bookmarks[0].Error != "" {
+		t.Errorf("Unexpected first result: %+v", res.Bookmarks[0])
+	}
+	if res.Bookmarks[1].Status != "error" || res.Bookmarks[1].Error != "timeout" {
+		t.Errorf("Unexpected second result: %+v", res.Bookmarks[1])
+	}
+}
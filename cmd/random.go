@@ -0,0 +1,83 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The random command picks a saved bookmark at random, so old bookmarks
+// get read again instead of only piling up. --tag and --unread narrow the
+// pick.
+//
+// Example usage:
+//
+//	bookmarkd random
+//	bookmarkd random --tag golang --unread
+//	bookmarkd random --user alice -o json
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// errNoRandomBookmark is returned when no bookmark passes random's filters.
+var errNoRandomBookmark = errors.New("no bookmarks match")
+
+var randomCmd = &cobra.Command{
+	Use:   "random",
+	Short: "Show a random bookmark to rediscover",
+	Long: `Pick one of your bookmarks at random and print its ID, URL and title,
+optionally only among those with a tag or not yet read.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRandom(cmd)
+		finishCommand(cmd, "Failed to pick a bookmark", res, err)
+	},
+}
+
+// randomResult describes the picked bookmark in command output.
+type randomResult struct {
+	ID        int64  `json:"id"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+}
+
+func runRandom(cmd *cobra.Command) (randomResult, error) {
+	tag, err := cmd.Flags().GetString("tag")
+	if err != nil {
+		return randomResult{}, fmt.Errorf("failed to read --tag: %w", err)
+	}
+	unread, err := cmd.Flags().GetBool("unread")
+	if err != nil {
+		return randomResult{}, fmt.Errorf("failed to read --unread: %w", err)
+	}
+	filter := db.BookmarkFilter{Tag: tag, UnreadOnly: unread}
+
+	return withDB(cmd, func(database *db.DB) (randomResult, error) {
+		scoped, err := userDB(cmd, database)
+		if err != nil {
+			return randomResult{}, err
+		}
+		b, ok, err := scoped.RandomBookmark(filter)
+		if err != nil {
+			return randomResult{}, err
+		}
+		if !ok {
+			return randomResult{}, errNoRandomBookmark
+		}
+		if !jsonOutput(cmd) {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\n", b.ID, b.URL, b.Title)
+		}
+		return randomResult{ID: b.ID, URL: b.URL, Title: b.Title, CreatedAt: b.CreatedAt}, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(randomCmd)
+
+	randomCmd.Flags().String("tag", "", "Only pick bookmarks with this tag")
+	randomCmd.Flags().Bool("unread", false, "Only pick bookmarks not yet read")
+	randomCmd.Flags().String("user", "", "Username to pick a bookmark of (default: the first account)")
+}
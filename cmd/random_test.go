@@ -0,0 +1,17 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestRandomCmd_Flags(t *testing.T) {
+	for _, name := range []string{"tag", "unread", "user"} {
+		if randomCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected random flag %s to be defined", name)
+		}
+	}
+	if err := randomCmd.Args(randomCmd, []string{"extra"}); err == nil {
+		t.Error("Expected random to take no arguments")
+	}
+}
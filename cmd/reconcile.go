@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The reconcile command runs the consistency check the server runs every
+// --reconcile-interval: archives marked ok whose content is missing from
+// the archive store are queued to archive again, jobs of deleted bookmarks
+// are deleted and archive jobs stuck running are queued again.
+//
+// Example usage:
+//
+//	bookmarkd reconcile --dry-run
+//	bookmarkd reconcile -o json
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Find and repair archives, jobs and statuses left inconsistent by a crash",
+	Long: `Check that every archive marked ok still has its content in the archive
+store, and look for jobs whose bookmark was deleted and archive jobs that
+have been running for over a day. Missing archives are marked failed and
+queued to archive again, orphaned jobs are deleted and stuck jobs are queued
+again. The server does this every --reconcile-interval.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runReconcile(cmd)
+		finishCommand(cmd, "Consistency check failed", res, err)
+	},
+}
+
+func runReconcile(cmd *cobra.Command) (core.ReconcileResult, error) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return core.ReconcileResult{}, fmt.Errorf("failed to read --dry-run: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (core.ReconcileResult, error) {
+		res, err := core.Reconcile(context.Background(), database, core.ReconcileOptions{DryRun: dryRun})
+		if err == nil && !res.Found() {
+			log.Printf("Checked %d archive(s); nothing to repair.", res.Checked)
+		}
+		return res, err
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(reconcileCmd)
+
+	reconcileCmd.Flags().Bool("dry-run", false, "Report what is inconsistent without repairing anything")
+}
@@ -0,0 +1,18 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestReconcileCmd_Flags(t *testing.T) {
+	if reconcileCmd.Flags().Lookup("dry-run") == nil {
+		t.Error("Expected reconcile flag dry-run to be defined")
+	}
+	if rootCmd.Flags().Lookup("reconcile-interval") == nil {
+		t.Error("Expected root flag reconcile-interval to be defined")
+	}
+	if err := reconcileCmd.Args(reconcileCmd, []string{"extra"}); err == nil {
+		t.Error("Expected reconcile to take no arguments")
+	}
+}
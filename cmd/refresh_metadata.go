@@ -0,0 +1,121 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The refresh-metadata command re-fetches page titles, descriptions, favicons
+// and Open Graph data for bookmarks without performing a full archive.
+//
+// Example usage:
+//
+//	bookmarkd refresh-metadata --stale=90d
+//	bookmarkd refresh-metadata --id=123
+//	bookmarkd refresh-metadata --missing-title
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// refreshMetadataCmd represents the refresh-metadata command
+var refreshMetadataCmd = &cobra.Command{
+	Use:   "refresh-metadata",
+	Short: "Re-fetch bookmark titles, descriptions and favicons without archiving",
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRefreshMetadata(cmd)
+		finishCommand(cmd, "Metadata refresh failed", res, err)
+	},
+}
+
+// runRefreshMetadata is the main function for the refresh-metadata command.
+func runRefreshMetadata(cmd *cobra.Command) (core.RefreshMetadataResult, error) {
+	id, err := cmd.Flags().GetInt64("id")
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to read --id: %w", err)
+	}
+	staleStr, err := cmd.Flags().GetString("stale")
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to read --stale: %w", err)
+	}
+	stale, err := parseAge(staleStr)
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("invalid --stale: %w", err)
+	}
+	missingTitle, err := cmd.Flags().GetBool("missing-title")
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to read --missing-title: %w", err)
+	}
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to read --limit: %w", err)
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to read --timeout: %w", err)
+	}
+
+	db, err := initDB(cmd)
+	if err != nil {
+		return core.RefreshMetadataResult{}, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("failed to close database: %v", err)
+		}
+	}()
+
+	res, err := core.RunRefreshMetadata(context.Background(), db, core.RefreshMetadataOptions{
+		ID:           id,
+		Stale:        stale,
+		MissingTitle: missingTitle,
+		Limit:        limit,
+		Timeout:      timeout,
+	})
+	if err != nil {
+		return res, err
+	}
+	if res.Attempted > 0 {
+		log.Printf("Refreshed metadata for %d bookmark(s).", res.Succeeded)
+	}
+	return res, nil
+}
+
+// parseAge parses a duration that may also be given in days ("90d"), weeks
+// ("2w") or 365-day years ("1y"), since time.ParseDuration stops at hours.
+// An empty string or "0" means zero.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if mult, ok := unit[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * mult, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func init() {
+	rootCmd.AddCommand(refreshMetadataCmd)
+
+	refreshMetadataCmd.Flags().Int64("id", 0, "Refresh a specific bookmark id")
+	refreshMetadataCmd.Flags().String("stale", "0", "Only refresh metadata older than this (e.g. 90d, 2w, 12h; 0 = all)")
+	refreshMetadataCmd.Flags().Bool("missing-title", false, "Only refresh bookmarks whose title is empty or just their URL")
+	refreshMetadataCmd.Flags().Int("limit", 0, "Limit the number of bookmarks to refresh (0 = all)")
+	refreshMetadataCmd.Flags().Duration("timeout", core.DefaultMetadataTimeout, "Per-bookmark fetch timeout")
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshMetadataCmd_Flags(t *testing.T) {
+	flags := refreshMetadataCmd.Flags()
+
+	for _, name := range []string{"id", "stale", "missing-title", "limit", "timeout"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("Expected flag %s to be defined", name)
+		}
+	}
+
+	if refreshMetadataCmd.InheritedFlags().Lookup("db") == nil {
+		t.Error("Expected refresh-metadata command to inherit --db flag from root")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "0", want: 0},
+		{in: "90d", want: 90 * 24 * time.Hour},
+		{in: "2w", want: 14 * 24 * time.Hour},
+		{in: "1y", want: 365 * 24 * time.Hour},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "d", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseAge(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseAge(%q) expected error, got %v", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAge(%q) unexpected error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseAge(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The reindex command rebuilds the full-text search index, optionally with
+// a different tokenizer: unicode61 (the default; case and diacritics are
+// ignored), porter (English stemming) or trigram (unicode61 plus matching
+// inside Chinese, Japanese and Korean text).
+//
+// Example usage:
+//
+//	bookmarkd reindex
+//	bookmarkd reindex --tokenizer trigram
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the search index, optionally with another tokenizer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runReindex(cmd)
+		finishCommand(cmd, "Reindex failed", res, err)
+	},
+}
+
+// reindexResult is the output of "reindex".
+type reindexResult struct {
+	Tokenizer string `json:"tokenizer"`
+	Bookmarks int64  `json:"bookmarks"`
+}
+
+func runReindex(cmd *cobra.Command) (reindexResult, error) {
+	name, err := cmd.Flags().GetString("tokenizer")
+	if err != nil {
+		return reindexResult{}, fmt.Errorf("failed to read --tokenizer: %w", err)
+	}
+	var tokenizer db.SearchTokenizer
+	if name != "" {
+		if tokenizer, err = db.ParseSearchTokenizer(name); err != nil {
+			return reindexResult{}, err
+		}
+	}
+	return withDB(cmd, func(database *db.DB) (reindexResult, error) {
+		n, err := database.Reindex(tokenizer)
+		if err != nil {
+			return reindexResult{}, err
+		}
+		if tokenizer, err = database.SearchTokenizer(); err != nil {
+			return reindexResult{}, err
+		}
+		log.Printf("Reindexed %d bookmark(s) with the %s tokenizer.", n, tokenizer)
+		return reindexResult{Tokenizer: string(tokenizer), Bookmarks: n}, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().String("tokenizer", "", "Tokenizer to rebuild with: unicode61, porter or trigram (default: keep the current one)")
+	var tokenizers []string
+	for _, t := range db.SearchTokenizers {
+		tokenizers = append(tokenizers, string(t))
+	}
+	if err := reindexCmd.RegisterFlagCompletionFunc("tokenizer", completeFixed(tokenizers...)); err != nil {
+		log.Fatalf("Failed to register completion for --tokenizer: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestReindexCmd_Flags(t *testing.T) {
+	if reindexCmd.Flags().Lookup("tokenizer") == nil {
+		t.Error("Expected flag tokenizer to be defined")
+	}
+	if reindexCmd.InheritedFlags().Lookup("db") == nil {
+		t.Error("Expected reindex command to inherit --db flag from root")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/seckatie/bookmarkd/internal/core"
 	"github.com/seckatie/bookmarkd/internal/core/db"
@@ -19,17 +21,45 @@ import (
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "bookmarkd",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "Self-hosted bookmark manager with full-page archiving",
+	Long: `bookmarkd saves bookmarks and archives each page as self-contained HTML
+using headless Chrome.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+Run without a subcommand to start the web UI and the background archive
+workers. Subcommands operate on the same database for scripting and
+maintenance.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := outputFormat(cmd); err != nil {
+			return err
+		}
+		rateStr, err := cmd.Flags().GetString("max-download-rate")
+		if err != nil {
+			return fmt.Errorf("failed to read --max-download-rate: %w", err)
+		}
+		rate, err := parseByteRate(rateStr)
+		if err != nil {
+			return fmt.Errorf("invalid --max-download-rate: %w", err)
+		}
+		core.SetDownloadRateLimit(rate)
+		if rate > 0 {
+			log.Printf("Limiting archive downloads to %s/s", rateStr)
+		}
+		tsaURL, err := cmd.Flags().GetString("timestamp-url")
+		if err != nil {
+			return fmt.Errorf("failed to read --timestamp-url: %w", err)
+		}
+		if tsaURL != "" {
+			if u, err := url.Parse(tsaURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid --timestamp-url %q: must be an http(s) URL", tsaURL)
+			}
+		}
+		core.SetTimestampAuthority(tsaURL)
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		database, err := initDB(cmd)
 		if err != nil {
-			log.Fatalf("Failed to initialize database: %v", err)
+			finishCommand(cmd, "Failed to initialize database", nil, err)
 		}
 		defer func() {
 			if err := database.Close(); err != nil {
@@ -42,89 +72,209 @@ to quickly create a Cobra application.`,
 			log.Fatalf("Failed to get archive workers: %v", err)
 		}
 
-		// Create the work queue for the archive workers
-		workQueue := make(chan db.Bookmark, numWorkers*10) // Buffer for multiple bookmarks
+		quietSpec, err := cmd.Flags().GetString("quiet-hours")
+		if err != nil {
+			log.Fatalf("Failed to get quiet hours: %v", err)
+		}
+		quietHours, err := core.ParseQuietHours(quietSpec)
+		if err != nil {
+			log.Fatalf("Invalid --quiet-hours: %v", err)
+		}
+		if quietHours.Enabled() {
+			log.Printf("Background jobs will pause during quiet hours: %s", quietSpec)
+		}
+
+		maxAttempts, err := cmd.Flags().GetInt("archive-max-attempts")
+		if err != nil {
+			log.Fatalf("Failed to get archive max attempts: %v", err)
+		}
+		retrySpec, err := cmd.Flags().GetString("archive-retry")
+		if err != nil {
+			log.Fatalf("Failed to get archive-retry: %v", err)
+		}
+		retryPolicy, err := core.ParseArchiveRetryPolicy(retrySpec)
+		if err != nil {
+			log.Fatalf("Invalid --archive-retry: %v", err)
+		}
 
-		// queueBookmark attempts to queue a bookmark for archiving.
-		// It tries for up to 5 seconds before giving up. The bookmark will be
-		// automatically retried on next startup since it remains unarchived in the DB.
-		queueBookmark := func(bookmark db.Bookmark, reason string) {
-			select {
-			case workQueue <- bookmark:
-				log.Printf("Queued bookmark %d (%s) for %s", bookmark.ID, bookmark.URL, reason)
-			case <-time.After(5 * time.Second):
-				log.Printf("Warning: work queue full after 5s, bookmark %d (%s) not queued for %s - will be retried on next startup",
-					bookmark.ID, bookmark.URL, reason)
-			}
+		maxQueued, err := cmd.Flags().GetInt("max-queued-archives")
+		if err != nil {
+			log.Fatalf("Failed to get max queued archives: %v", err)
+		}
+
+		defaults, err := archiveSettings(cmd)
+		if err != nil {
+			log.Fatalf("Failed to get archive defaults: %v", err)
+		}
+		archiveOpts, err := archivePolicy(cmd, core.ArchiveOptions{Headless: true})
+		if err != nil {
+			log.Fatalf("Invalid archive domain rules: %v", err)
 		}
+		defer archiveOpts.Browsers.Close()
+
+		hostDelay, err := cmd.Flags().GetDuration("archive-host-delay")
+		if err != nil {
+			log.Fatalf("Failed to get archive-host-delay: %v", err)
+		}
+
+		rearchiveStr, err := cmd.Flags().GetString("rearchive-after")
+		if err != nil {
+			log.Fatalf("Failed to get rearchive-after: %v", err)
+		}
+		rearchiveAfter, err := parseAge(rearchiveStr)
+		if err != nil {
+			log.Fatalf("Invalid --rearchive-after: %v", err)
+		}
+
+		// Archive jobs live in the database, so queued work survives restarts
+		// and failed archives are retried with exponential backoff.
+		queue := core.NewArchiveQueue(database, core.ArchiveQueueOptions{
+			Workers:        numWorkers,
+			MaxAttempts:    maxAttempts,
+			RetryPolicy:    retryPolicy,
+			MaxQueued:      maxQueued,
+			QuietHours:     quietHours,
+			Archive:        archiveOpts,
+			Defaults:       &defaults,
+			RearchiveAfter: rearchiveAfter,
+			HostDelay:      hostDelay,
+		})
 
 		// Register event listeners to queue bookmarks for archiving
 		database.RegisterEventListener(db.OnBookmarkCreatedEvent, func(event db.Event) error {
 			ev := event.(db.BookmarkCreatedEvent)
-			queueBookmark(ev.Bookmark, "archiving (new)")
-			return nil
+			if ev.SkipArchive {
+				log.Printf("Bookmark %d is marked skip-archive, not queuing it for archiving", ev.Bookmark.ID)
+				return nil
+			}
+			return queue.EnqueueNew(ev.Bookmark.ID, ev.Archive)
 		})
 
+		fetchTitles, err := cmd.Flags().GetBool("fetch-titles")
+		if err != nil {
+			log.Fatalf("Failed to get fetch-titles: %v", err)
+		}
+		if fetchTitles {
+			// Bookmarks saved without a usable title (often from the
+			// bookmarklet) get one from the page itself.
+			titles := core.NewTitleFetcher(database, core.DefaultMetadataTimeout, 0)
+			database.RegisterEventListener(db.OnBookmarkCreatedEvent, func(event db.Event) error {
+				ev := event.(db.BookmarkCreatedEvent)
+				if titles.Fetch(ev.Bookmark) {
+					log.Printf("Fetching title for bookmark %d", ev.Bookmark.ID)
+				}
+				return nil
+			})
+		}
+
+		var archiveToday *core.ArchiveTodayClient
+		submitArchiveToday, err := cmd.Flags().GetBool("archive-today")
+		if err != nil {
+			log.Fatalf("Failed to get archive-today: %v", err)
+		}
+		if submitArchiveToday {
+			archiveTodayURL, err := cmd.Flags().GetString("archive-today-url")
+			if err != nil {
+				log.Fatalf("Failed to get archive-today-url: %v", err)
+			}
+			// New bookmarks get a redundant off-site snapshot, unless they
+			// are kept out of archiving or from archive.today.
+			archiveToday = core.NewArchiveTodayClient(archiveTodayURL)
+			submitter := core.NewArchiveTodaySubmitter(database, archiveToday, archiveOpts.Domains, 0)
+			database.RegisterEventListener(db.OnBookmarkCreatedEvent, func(event db.Event) error {
+				ev := event.(db.BookmarkCreatedEvent)
+				if ev.SkipArchive || ev.SkipArchiveToday {
+					return nil
+				}
+				if submitter.Submit(ev.Bookmark) {
+					log.Printf("Submitting bookmark %d to archive.today", ev.Bookmark.ID)
+				}
+				return nil
+			})
+		}
+
+		// Notifications only go out for changes made through the server;
+		// CLI commands run without the dispatcher.
+		core.NewNotificationDispatcher(database,
+			core.NewWebhookDispatcher(database, core.WebhookOptions{}),
+		).Register()
+
 		database.RegisterEventListener(db.OnArchiveClearedEvent, func(event db.Event) error {
 			ev := event.(db.ArchiveClearedEvent)
 			log.Printf("Archive cleared for bookmark %d, queuing for re-archiving", ev.BookmarkID)
-			// Fetch the bookmark to queue it
-			bookmark, err := database.GetBookmark(ev.BookmarkID)
-			if err != nil {
-				log.Printf("Error fetching bookmark %d for re-archiving: %v", ev.BookmarkID, err)
-				return err
-			}
-			queueBookmark(bookmark, "re-archiving")
-			return nil
+			return queue.Enqueue(ev.BookmarkID, "re-archiving")
 		})
 
-		// Start archive workers that process bookmarks and persist results
-		for i := 0; i < numWorkers; i++ {
-			workerID := i
+		go func() {
+			if err := queue.Run(context.Background()); err != nil {
+				log.Printf("Archive queue stopped: %v", err)
+			}
+		}()
+
+		ranking, err := searchRanking(cmd)
+		if err != nil {
+			log.Fatalf("Failed to get search ranking: %v", err)
+		}
+		database.SetSearchRanking(ranking)
+
+		cleanupInterval, err := cmd.Flags().GetDuration("cleanup-interval")
+		if err != nil {
+			log.Fatalf("Failed to get cleanup interval: %v", err)
+		}
+		if cleanupInterval > 0 {
 			go func() {
-				log.Printf("Archive worker %d started", workerID)
-				for bookmark := range workQueue {
-					log.Printf("Worker %d archiving bookmark %d: %s", workerID, bookmark.ID, bookmark.URL)
-					ctx := context.Background()
-					if err := core.ArchiveAndPersist(ctx, database, bookmark, core.ArchiveOptions{
-						Headless: true,
-					}); err != nil {
-						log.Printf("Worker %d: Archive failed for id=%d url=%s: %v", workerID, bookmark.ID, bookmark.URL, err)
-					} else {
-						log.Printf("Worker %d: Successfully archived bookmark %d", workerID, bookmark.ID)
-					}
+				if err := core.RunCleanupSchedule(context.Background(), database, cleanupInterval, quietHours); err != nil {
+					log.Printf("Cleanup rules stopped: %v", err)
 				}
-				log.Printf("Archive worker %d stopped", workerID)
 			}()
 		}
 
-		// On startup, check for any existing unarchived bookmarks and queue them
-		go func() {
-			time.Sleep(2 * time.Second) // Give the server a moment to start
-			log.Println("Checking for existing unarchived bookmarks on startup...")
-			bookmarks, err := database.ListBookmarksToArchive(0)
+		reconcileInterval, err := cmd.Flags().GetDuration("reconcile-interval")
+		if err != nil {
+			log.Fatalf("Failed to get reconcile interval: %v", err)
+		}
+		if reconcileInterval > 0 {
+			go func() {
+				if err := core.RunReconcileSchedule(context.Background(), database, reconcileInterval, quietHours); err != nil {
+					log.Printf("Consistency checks stopped: %v", err)
+				}
+			}()
+		}
+
+		gitExportInterval, err := cmd.Flags().GetDuration("git-export-interval")
+		if err != nil {
+			log.Fatalf("Failed to get git export interval: %v", err)
+		}
+		gitExport, err := gitExportOptions(cmd, "git-export-")
+		if err != nil {
+			log.Fatalf("Failed to get git export options: %v", err)
+		}
+		if gitExport.Dir != "" && gitExportInterval > 0 {
+			go func() {
+				if err := core.RunGitExportSchedule(context.Background(), database, gitExportInterval, gitExport, quietHours); err != nil {
+					log.Printf("Git export stopped: %v", err)
+				}
+			}()
+		}
+
+		// Update checks are opt-in: nothing is fetched unless asked for.
+		checkUpdates, err := cmd.Flags().GetBool("check-updates")
+		if err != nil {
+			log.Fatalf("Failed to get check-updates: %v", err)
+		}
+		var updates *core.UpdateChecker
+		if checkUpdates {
+			feed, err := cmd.Flags().GetString("update-feed")
 			if err != nil {
-				log.Printf("Error listing bookmarks to archive: %v", err)
-				return
+				log.Fatalf("Failed to get update-feed: %v", err)
 			}
-			if len(bookmarks) == 0 {
-				log.Println("No existing bookmarks need archiving")
-				return
-			}
-			log.Printf("Found %d existing unarchived bookmarks, queuing...", len(bookmarks))
-			queued := 0
-			for _, b := range bookmarks {
-				select {
-				case workQueue <- b:
-					queued++
-				case <-time.After(5 * time.Second):
-					log.Printf("Warning: work queue full, stopped queuing at %d/%d bookmarks - remaining will be retried on next startup",
-						queued, len(bookmarks))
-					return
+			updates = core.NewUpdateChecker(feed, core.ReadBuildInfo().Version)
+			go func() {
+				if err := updates.Run(context.Background(), core.DefaultUpdateCheckInterval); err != nil {
+					log.Printf("Update checks stopped: %v", err)
 				}
-			}
-			log.Printf("Successfully queued all %d existing bookmarks for archiving", queued)
-		}()
+			}()
+		}
 
 		// Get the host and port from the flags
 		host, err := cmd.Flags().GetString("host")
@@ -136,11 +286,141 @@ to quickly create a Cobra application.`,
 			log.Fatalf("Failed to get port: %v", err)
 		}
 
-		// Start the web server
-		web.StartServer(fmt.Sprintf("%s:%d", host, port), database)
+		tokenQuota, err := cmd.Flags().GetInt("api-token-quota")
+		if err != nil {
+			log.Fatalf("Failed to get api-token-quota: %v", err)
+		}
+		writeRate, err := cmd.Flags().GetFloat64("write-rate-limit")
+		if err != nil {
+			log.Fatalf("Failed to get write-rate-limit: %v", err)
+		}
+		writeBurst, err := cmd.Flags().GetInt("write-rate-burst")
+		if err != nil {
+			log.Fatalf("Failed to get write-rate-burst: %v", err)
+		}
+		addRate, err := cmd.Flags().GetFloat64("add-rate-limit")
+		if err != nil {
+			log.Fatalf("Failed to get add-rate-limit: %v", err)
+		}
+		addBurst, err := cmd.Flags().GetInt("add-rate-burst")
+		if err != nil {
+			log.Fatalf("Failed to get add-rate-burst: %v", err)
+		}
+		addChallenge, err := cmd.Flags().GetBool("add-challenge")
+		if err != nil {
+			log.Fatalf("Failed to get add-challenge: %v", err)
+		}
+		addDifficulty, err := cmd.Flags().GetInt("add-challenge-difficulty")
+		if err != nil {
+			log.Fatalf("Failed to get add-challenge-difficulty: %v", err)
+		}
+		trustProxy, err := cmd.Flags().GetBool("trust-proxy")
+		if err != nil {
+			log.Fatalf("Failed to get trust-proxy: %v", err)
+		}
+		serveStrip, err := cmd.Flags().GetBool("serve-strip-scripts")
+		if err != nil {
+			log.Fatalf("Failed to get serve-strip-scripts: %v", err)
+		}
+		requestTimeout, err := cmd.Flags().GetDuration("request-timeout")
+		if err != nil {
+			log.Fatalf("Failed to get request-timeout: %v", err)
+		}
+		routeSpec, err := cmd.Flags().GetString("route-timeouts")
+		if err != nil {
+			log.Fatalf("Failed to get route-timeouts: %v", err)
+		}
+		routeTimeouts, err := web.ParseRouteTimeouts(routeSpec)
+		if err != nil {
+			log.Fatalf("Invalid --route-timeouts: %v", err)
+		}
+
+		ttsCfg, err := ttsConfig(cmd)
+		if err != nil {
+			log.Fatalf("Failed to get text-to-speech flags: %v", err)
+		}
+		tts, err := core.OpenTTSBackend(ttsCfg)
+		if err != nil {
+			log.Fatalf("Failed to set up text-to-speech: %v", err)
+		}
+		if tts != nil {
+			log.Printf("Reading articles aloud with the %s text-to-speech backend", ttsCfg.Backend)
+		}
+
+		// The password comes from the flag or, to keep it out of the
+		// process list, the environment.
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			log.Fatalf("Failed to get password: %v", err)
+		}
+		if password == "" {
+			password = os.Getenv("BOOKMARKD_PASSWORD")
+		}
+
+		// Start the web server. In JSON mode, announce the address first so
+		// scripts can wait for it; the server itself never returns.
+		addr := fmt.Sprintf("%s:%d", host, port)
+		if jsonOutput(cmd) {
+			if err := writeJSON(cmd.OutOrStdout(), commandResult{OK: true, Result: serverResult{Addr: addr}}); err != nil {
+				log.Printf("failed to write JSON output: %v", err)
+			}
+		}
+		web.StartServer(addr, database, web.Options{
+			APITokenQuota:          tokenQuota,
+			WriteRateLimit:         writeRate,
+			WriteRateBurst:         writeBurst,
+			AddRateLimit:           addRate,
+			AddRateBurst:           addBurst,
+			AddChallenge:           addChallenge,
+			AddChallengeDifficulty: addDifficulty,
+			TrustProxy:             trustProxy,
+			StripArchiveScripts:    serveStrip,
+			Password:               password,
+			ArchiveWorkers:         numWorkers,
+			TTS:                    tts,
+			Updates:                updates,
+			Wayback:                archiveOpts.Wayback,
+			ArchiveToday:           archiveToday,
+			RequestTimeout:         requestTimeout,
+			RouteTimeouts:          routeTimeouts,
+		})
 	},
 }
 
+// serverResult is printed when the web server starts in --output=json mode.
+type serverResult struct {
+	Addr string `json:"addr"`
+}
+
+// parseByteRate parses a download rate such as "500KB", "2MB/s" or "1048576",
+// or a size such as --max-archive-size in the same units.
+// Units are binary (1KB = 1024 bytes). An empty string or "0" means unlimited.
+func parseByteRate(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "/S")
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, mult = strings.TrimSuffix(s, unit.suffix), unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -151,29 +431,383 @@ func Execute() {
 }
 
 func init() {
+	// --version prints the release set at build time (see core.Version).
+	rootCmd.Version = core.ReadBuildInfo().Version
+
 	rootCmd.PersistentFlags().StringP("db", "d", "bookmarkd.db", "Path to the SQLite database file")
+	rootCmd.PersistentFlags().StringP("output", "o", outputText, "Output format: text or json (machine-readable results on stdout)")
+	rootCmd.PersistentFlags().String("max-download-rate", "0", "Global archive download rate limit, e.g. 500KB or 2MB (0 = unlimited)")
+	rootCmd.PersistentFlags().String("timestamp-url", "", "RFC 3161 timestamp authority URL; when set, every new archive's content hash is timestamped")
+
+	// Archive domain rules; a domain covers its subdomains
+	rootCmd.PersistentFlags().StringSlice("archive-allow-domains", nil, "Only archive pages on these domains (comma-separated; default all)")
+	rootCmd.PersistentFlags().StringSlice("archive-deny-domains", nil, "Never archive pages on these domains (comma-separated)")
+	rootCmd.PersistentFlags().StringSlice("resource-allow-domains", nil, "Only load and inline page resources from these domains (comma-separated; default all)")
+	rootCmd.PersistentFlags().StringSlice("resource-deny-domains", nil, "Never load or inline page resources from these domains, e.g. trackers (comma-separated)")
+	rootCmd.PersistentFlags().Bool("respect-robots", false, "Skip pages whose robots.txt disallows bookmarkd or that are marked noarchive")
+	rootCmd.PersistentFlags().String("archive-user-agent", "", "User agent Chrome and the inliner send when archiving (default Chrome's own and "+core.UserAgent+")")
+	rootCmd.PersistentFlags().StringArray("archive-header", nil, `Extra HTTP header sent with every archive request, as "Name: value" (repeatable)`)
+	rootCmd.PersistentFlags().String("max-archive-size", "50MB", "Stop inlining resources once an archived page reaches this size, e.g. 20MB (0 = unlimited)")
+	rootCmd.PersistentFlags().Duration("resource-cache-ttl", core.DefaultResourceCacheTTL, "How long page resources fetched for one archive are reused by others (0 = always download)")
+	rootCmd.PersistentFlags().String("chrome-profile-dir", "", "Keep a Chrome profile per site in this directory, so re-archives reuse its HTTP cache and cookies (default: a blank profile each time)")
+	rootCmd.PersistentFlags().Bool("scroll-to-bottom", false, "Scroll each page to the bottom in Chrome before capturing it, so lazy-loaded images and content are archived")
+	rootCmd.PersistentFlags().Duration("scroll-delay", core.DefaultScrollDelay, "Pause after each viewport scrolled with --scroll-to-bottom")
+	rootCmd.PersistentFlags().String("wait-strategy", core.WaitNetworkIdle, "What Chrome waits for before capturing a page: networkIdle, domContentLoaded, load or fixed-delay (use the last for pages that never go network idle)")
+	rootCmd.PersistentFlags().Duration("extra-delay", 0, "Extra pause after --wait-strategy is met, before capture; with fixed-delay it is the whole wait (default "+core.DefaultFixedWaitDelay.String()+")")
+	rootCmd.PersistentFlags().String("archive-embeds", core.EmbedsKeep, "What to do with iframes, video and audio: keep, placeholder (a link to the source) or inline (snapshot same-origin iframes and small media, placeholders for the rest)")
+	rootCmd.PersistentFlags().Bool("strip-trackers", false, "Remove common trackers, analytics scripts, tracking pixels and ads from archived pages")
+	rootCmd.PersistentFlags().StringArray("filter-list", nil, "EasyList-style filter list file whose trackers and ads are removed from archived pages, in addition to --strip-trackers' (repeatable)")
+	rootCmd.PersistentFlags().Int("browser-pool-pages", core.DefaultBrowserPoolPages, "Archive pages in tabs of one shared Chrome, replaced after this many pages (0 = launch Chrome for every page)")
+	rootCmd.PersistentFlags().String("archive-engine", "auto", "How pages are captured: chrome, http (a plain GET, without running scripts) or auto (chrome when installed)")
+	rootCmd.PersistentFlags().Bool("wayback", false, "When a page fails to archive, look up its closest Wayback Machine snapshot to link to, and allow submitting pages to the Wayback Machine (sends their URLs to the Internet Archive)")
+
+	// Archive storage flags
+	rootCmd.PersistentFlags().String("archive-store", core.ArchiveStoreSQLite, "Where archived HTML is stored: sqlite, dir or s3")
+	rootCmd.PersistentFlags().String("archive-dir", "archives", "Directory for --archive-store=dir")
+	rootCmd.PersistentFlags().String("s3-endpoint", "", "S3-compatible endpoint URL for --archive-store=s3")
+	rootCmd.PersistentFlags().String("s3-region", "us-east-1", "S3 region")
+	rootCmd.PersistentFlags().String("s3-bucket", "", "S3 bucket name")
+	rootCmd.PersistentFlags().String("s3-prefix", "", "Prefix for S3 object keys")
+	rootCmd.PersistentFlags().Bool("s3-path-style", false, "Use path-style S3 URLs (needed by most self-hosted servers)")
 	rootCmd.Flags().IntP("port", "p", 8080, "Port to listen on")
 	rootCmd.Flags().String("host", "localhost", "Host to listen on")
+	rootCmd.Flags().String("password", "", "Password required to use the web UI, logging in as the first account (or set BOOKMARKD_PASSWORD; default: no login unless users have passwords)")
+	rootCmd.Flags().Int("api-token-quota", core.DefaultAPITokenQuota, "Requests per hour for API tokens without their own quota (0 = unlimited)")
+	rootCmd.Flags().Float64("write-rate-limit", core.DefaultWriteRateLimit, "Write requests per minute allowed from one client IP, including bookmarklet adds (0 = unlimited)")
+	rootCmd.Flags().Int("write-rate-burst", core.DefaultWriteRateBurst, "Write requests one client IP may make at once before --write-rate-limit applies")
+	rootCmd.Flags().Float64("add-rate-limit", core.DefaultAddRateLimit, "Bookmarks per minute that may be added with one API token or from one client IP (0 = unlimited)")
+	rootCmd.Flags().Int("add-rate-burst", core.DefaultAddRateBurst, "Bookmarks one API token or client IP may add at once before --add-rate-limit applies")
+	rootCmd.Flags().Bool("add-challenge", false, "Let bookmarklet users over --add-rate-limit through by solving a proof-of-work challenge instead of waiting")
+	rootCmd.Flags().Int("add-challenge-difficulty", core.DefaultAddChallengeDifficulty, "Leading zero bits an --add-challenge solution needs; each bit doubles the work per bookmark")
+	rootCmd.Flags().Bool("trust-proxy", false, "Take client IPs for rate limiting from X-Forwarded-For (only behind a reverse proxy that sets it)")
+	rootCmd.Flags().Duration("request-timeout", core.DefaultRequestTimeout, "How long a web request may take before it is answered with a 503 and its database queries are canceled (0 = no limit)")
+	rootCmd.Flags().String("route-timeouts", "", `Per-route overrides of --request-timeout, as path=timeout, e.g. "/import=30m,/bookmarks/*/archive/audio=0" (a path ending in / covers everything under it; /import, exports, audio and Wayback and archive.today submissions already get longer, and /dav/, /podcast/ and archived pages, screenshots and downloads none)`)
+
+	// Text-to-speech flags for reading archived articles aloud
+	rootCmd.Flags().String("tts-backend", core.TTSBackendNone, "Text-to-speech backend for reading archived articles aloud: none, command or api")
+	rootCmd.Flags().String("tts-command", "", `Program for --tts-backend=command, reading text on stdin and writing audio to stdout, e.g. "piper --model en_US-amy-medium.onnx --output_file -"`)
+	rootCmd.Flags().String("tts-api-url", "", "OpenAI-compatible speech endpoint for --tts-backend=api, e.g. https://api.openai.com/v1/audio/speech (key from BOOKMARKD_TTS_API_KEY)")
+	rootCmd.Flags().String("tts-model", core.DefaultTTSModel, "Model for --tts-backend=api")
+	rootCmd.Flags().String("tts-voice", core.DefaultTTSVoice, "Voice for --tts-backend=api")
 
 	// Archive workers flags
 	rootCmd.Flags().IntP("archive-workers", "w", 1, "Number of archive workers to run")
+	rootCmd.Flags().Int("archive-max-attempts", core.DefaultJobMaxAttempts, "Attempts per archive job before giving up (retries back off exponentially)")
+	rootCmd.Flags().String("archive-retry", "", `Per-error retry rules overriding --archive-max-attempts, as code=attempts[/first retry delay], e.g. "network=3/5m,timeout=3,http-5xx=4/30m" (codes: timeout, dns, tls, network, http-4xx, http-5xx, blocked, chrome-crash, other; 404s and blocked pages aren't retried unless listed)`)
+	rootCmd.Flags().Int("max-queued-archives", core.DefaultMaxQueuedJobs, "Archive jobs that may wait for a worker before new bookmarks' archives are deferred until the queue drains")
+	rootCmd.Flags().Duration("archive-host-delay", core.DefaultArchiveHostDelay, "Pause between archives of pages on the same host; each host is archived by one worker at a time (0 = no pause)")
+	rootCmd.Flags().String("quiet-hours", "", `Local-time windows when background jobs pause, e.g. "mon-fri 09:00-17:00; sat,sun 23:00-07:00"`)
+	rootCmd.Flags().String("rearchive-after", "0", "Re-archive bookmarks whose latest snapshot is older than this, e.g. 90d (0 = never)")
+	rootCmd.Flags().Bool("fetch-titles", true, "Fetch the page title, description and favicon for bookmarks saved without a title")
+	rootCmd.Flags().Bool("archive-today", false, "Submit new bookmarks to archive.today as a redundant off-site archive and record their snapshots (sends their URLs to archive.today)")
+	rootCmd.Flags().String("archive-today-url", core.DefaultArchiveTodayURL, "archive.today mirror that --archive-today submits to")
+	rootCmd.Flags().Duration("cleanup-interval", core.DefaultCleanupInterval, "How often to run cleanup rules (0 = only via 'rules run')")
+	rootCmd.Flags().Duration("reconcile-interval", core.DefaultReconcileInterval, "How often to check for archives missing from the archive store and stranded archive jobs, and repair them (0 = only via 'reconcile')")
+	addGitExportFlags(rootCmd, "git-export-")
+	rootCmd.Flags().Duration("git-export-interval", core.DefaultGitExportInterval, "How often to export to --git-export-dir (0 = only via 'git-export')")
+	rootCmd.Flags().Bool("check-updates", false, "Check --update-feed daily for newer releases and tell admins on the settings page and /api/version")
+	rootCmd.Flags().String("update-feed", core.DefaultUpdateFeed, "Atom feed of bookmarkd releases that --check-updates reads")
+
+	// Search ranking: boosts blended with the text match score
+	ranking := db.DefaultSearchRanking()
+	rootCmd.Flags().Float64("search-recency-weight", ranking.RecencyWeight, "Search boost for a bookmark saved just now (0 = ignore age)")
+	rootCmd.Flags().Duration("search-recency-half-life", ranking.RecencyHalfLife, "Bookmark age at which the search recency boost halves")
+	rootCmd.Flags().Float64("search-favorite-boost", ranking.FavoriteBoost, "Search boost for favorites")
+	rootCmd.Flags().Float64("search-view-weight", ranking.ViewWeight, "Search boost per doubling of a bookmark's view count")
+
+	// Instance archive defaults; users can override them on the settings page
+	rootCmd.Flags().Bool("auto-archive", core.DefaultArchiveSettings().AutoArchive, "Archive new bookmarks as soon as they are saved")
+	rootCmd.Flags().Bool("archive-strip-scripts", false, "Remove scripts and inline event handlers from archived pages")
+	rootCmd.Flags().Bool("archive-mobile", false, "Capture pages with a mobile viewport")
+	rootCmd.Flags().Bool("archive-screenshots", false, "Store a full-page screenshot with each archive")
+	rootCmd.Flags().Bool("serve-strip-scripts", false, "Remove scripts from archived pages when serving them, including ones archived with scripts")
+
+	// Shell completion for enumerated flag values
+	for name, values := range map[string][]string{
+		"output":         {outputText, outputJSON},
+		"archive-store":  {core.ArchiveStoreSQLite, core.ArchiveStoreDir, core.ArchiveStoreS3},
+		"archive-engine": {"auto", core.ArchiveEngineChrome, core.ArchiveEngineHTTP},
+		"tts-backend":    {core.TTSBackendNone, core.TTSBackendCommand, core.TTSBackendAPI},
+	} {
+		if err := rootCmd.RegisterFlagCompletionFunc(name, completeFixed(values...)); err != nil {
+			log.Fatalf("Failed to register completion for --%s: %v", name, err)
+		}
+	}
+	if err := rootCmd.MarkPersistentFlagFilename("db", "db", "sqlite", "sqlite3"); err != nil {
+		log.Fatalf("Failed to mark --db as a file: %v", err)
+	}
+	if err := rootCmd.MarkPersistentFlagDirname("archive-dir"); err != nil {
+		log.Fatalf("Failed to mark --archive-dir as a directory: %v", err)
+	}
 }
 
 func initDB(cmd *cobra.Command) (*db.DB, error) {
 	dbPath, err := cmd.Flags().GetString("db")
 	if err != nil {
-		log.Fatalf("Failed to get database path: %v", err)
+		return nil, fmt.Errorf("failed to get database path: %w", err)
 	}
 	database, err := db.NewSQLiteDB(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to create database: %v", err)
+		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
 
 	if err := database.Migrate(); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		_ = database.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	log.Println("Database migrated successfully")
+	database.EnableActivityLog()
+
+	storeCfg, err := archiveStoreConfig(cmd)
+	if err != nil {
+		_ = database.Close()
+		return nil, fmt.Errorf("invalid archive store configuration: %w", err)
+	}
+	store, err := core.OpenArchiveStore(storeCfg, database)
+	if err != nil {
+		_ = database.Close()
+		return nil, fmt.Errorf("failed to open archive store: %w", err)
+	}
+	database.SetBlobStore(store)
+	if storeCfg.Backend != "" && storeCfg.Backend != core.ArchiveStoreSQLite {
+		log.Printf("Storing archives in %s archive store", storeCfg.Backend)
+	}
 
 	return database, nil
 }
+
+// archiveSettings reads the instance archive defaults from the flags.
+func archiveSettings(cmd *cobra.Command) (core.ArchiveSettings, error) {
+	flags := cmd.Flags()
+	var s core.ArchiveSettings
+	var err error
+	if s.AutoArchive, err = flags.GetBool("auto-archive"); err != nil {
+		return s, err
+	}
+	if s.StripScripts, err = flags.GetBool("archive-strip-scripts"); err != nil {
+		return s, err
+	}
+	if s.MobileViewport, err = flags.GetBool("archive-mobile"); err != nil {
+		return s, err
+	}
+	if s.Screenshot, err = flags.GetBool("archive-screenshots"); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// archivePolicy reads the flags limiting what may be archived (domain rules
+// and --respect-robots) and how pages are requested (--archive-engine,
+// --archive-user-agent, --archive-header, --max-archive-size,
+// --resource-cache-ttl and --chrome-profile-dir) into opts.
+func archivePolicy(cmd *cobra.Command, opts core.ArchiveOptions) (core.ArchiveOptions, error) {
+	rules := func(allowFlag, denyFlag string) (core.DomainRules, error) {
+		allow, err := cmd.Flags().GetStringSlice(allowFlag)
+		if err != nil {
+			return core.DomainRules{}, fmt.Errorf("failed to read --%s: %w", allowFlag, err)
+		}
+		deny, err := cmd.Flags().GetStringSlice(denyFlag)
+		if err != nil {
+			return core.DomainRules{}, fmt.Errorf("failed to read --%s: %w", denyFlag, err)
+		}
+		return core.NewDomainRules(allow, deny)
+	}
+	var err error
+	if opts.Domains, err = rules("archive-allow-domains", "archive-deny-domains"); err != nil {
+		return opts, err
+	}
+	if opts.ResourceDomains, err = rules("resource-allow-domains", "resource-deny-domains"); err != nil {
+		return opts, err
+	}
+	if opts.RespectRobots, err = cmd.Flags().GetBool("respect-robots"); err != nil {
+		return opts, fmt.Errorf("failed to read --respect-robots: %w", err)
+	}
+	if opts.UserAgent, err = cmd.Flags().GetString("archive-user-agent"); err != nil {
+		return opts, fmt.Errorf("failed to read --archive-user-agent: %w", err)
+	}
+	opts.UserAgent = strings.TrimSpace(opts.UserAgent)
+	headers, err := cmd.Flags().GetStringArray("archive-header")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --archive-header: %w", err)
+	}
+	if opts.ExtraHeaders, err = core.ParseHeaders(headers); err != nil {
+		return opts, fmt.Errorf("invalid --archive-header: %w", err)
+	}
+	engine, err := cmd.Flags().GetString("archive-engine")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --archive-engine: %w", err)
+	}
+	if opts.Engine, err = core.ParseArchiveEngine(engine); err != nil {
+		return opts, fmt.Errorf("invalid --archive-engine: %w", err)
+	}
+	maxSize, err := cmd.Flags().GetString("max-archive-size")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --max-archive-size: %w", err)
+	}
+	if opts.MaxTotalSize, err = parseByteRate(maxSize); err != nil {
+		return opts, fmt.Errorf("invalid --max-archive-size: %w", err)
+	}
+	if opts.ResourceCacheTTL, err = cmd.Flags().GetDuration("resource-cache-ttl"); err != nil {
+		return opts, fmt.Errorf("failed to read --resource-cache-ttl: %w", err)
+	}
+	if opts.ResourceCacheTTL < 0 {
+		return opts, fmt.Errorf("invalid --resource-cache-ttl: must not be negative")
+	}
+	if opts.ChromeProfileDir, err = cmd.Flags().GetString("chrome-profile-dir"); err != nil {
+		return opts, fmt.Errorf("failed to read --chrome-profile-dir: %w", err)
+	}
+	opts.ChromeProfileDir = strings.TrimSpace(opts.ChromeProfileDir)
+	if opts.ScrollToBottom, err = cmd.Flags().GetBool("scroll-to-bottom"); err != nil {
+		return opts, fmt.Errorf("failed to read --scroll-to-bottom: %w", err)
+	}
+	if opts.ScrollDelay, err = cmd.Flags().GetDuration("scroll-delay"); err != nil {
+		return opts, fmt.Errorf("failed to read --scroll-delay: %w", err)
+	}
+	strategy, err := cmd.Flags().GetString("wait-strategy")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --wait-strategy: %w", err)
+	}
+	if opts.WaitStrategy, err = core.ParseWaitStrategy(strategy); err != nil {
+		return opts, fmt.Errorf("invalid --wait-strategy: %w", err)
+	}
+	if opts.ExtraDelay, err = cmd.Flags().GetDuration("extra-delay"); err != nil {
+		return opts, fmt.Errorf("failed to read --extra-delay: %w", err)
+	}
+	if opts.ExtraDelay < 0 {
+		return opts, fmt.Errorf("invalid --extra-delay: must not be negative")
+	}
+	embeds, err := cmd.Flags().GetString("archive-embeds")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --archive-embeds: %w", err)
+	}
+	if opts.Embeds, err = core.ParseEmbedMode(embeds); err != nil {
+		return opts, fmt.Errorf("invalid --archive-embeds: %w", err)
+	}
+	poolPages, err := cmd.Flags().GetInt("browser-pool-pages")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --browser-pool-pages: %w", err)
+	}
+	if poolPages < 0 {
+		return opts, fmt.Errorf("invalid --browser-pool-pages: must not be negative")
+	}
+	if poolPages > 0 && opts.Engine != core.ArchiveEngineHTTP {
+		// Chrome is only launched once a page needs it.
+		opts.Browsers = core.NewBrowserPool(core.BrowserPoolOptions{
+			ChromePath: opts.ChromePath,
+			Headless:   opts.Headless,
+			MaxPages:   poolPages,
+		})
+	}
+	stripTrackers, err := cmd.Flags().GetBool("strip-trackers")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --strip-trackers: %w", err)
+	}
+	filterLists, err := cmd.Flags().GetStringArray("filter-list")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --filter-list: %w", err)
+	}
+	if stripTrackers || len(filterLists) > 0 {
+		if opts.Blocklist, err = core.LoadBlocklist(stripTrackers, filterLists...); err != nil {
+			return opts, fmt.Errorf("invalid --filter-list: %w", err)
+		}
+	}
+	wayback, err := cmd.Flags().GetBool("wayback")
+	if err != nil {
+		return opts, fmt.Errorf("failed to read --wayback: %w", err)
+	}
+	if wayback {
+		opts.Wayback = core.NewWaybackClient("", "")
+	}
+	return opts, nil
+}
+
+// searchRanking reads the search ranking flags.
+func searchRanking(cmd *cobra.Command) (db.SearchRanking, error) {
+	flags := cmd.Flags()
+	var r db.SearchRanking
+	var err error
+	if r.RecencyWeight, err = flags.GetFloat64("search-recency-weight"); err != nil {
+		return r, err
+	}
+	if r.RecencyHalfLife, err = flags.GetDuration("search-recency-half-life"); err != nil {
+		return r, err
+	}
+	if r.FavoriteBoost, err = flags.GetFloat64("search-favorite-boost"); err != nil {
+		return r, err
+	}
+	if r.ViewWeight, err = flags.GetFloat64("search-view-weight"); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// archiveStoreConfig reads the archive store flags. S3 credentials can also
+// come from BOOKMARKD_S3_ACCESS_KEY / BOOKMARKD_S3_SECRET_KEY or the standard
+// AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY, so they needn't appear in the
+// process list.
+func archiveStoreConfig(cmd *cobra.Command) (core.ArchiveStoreConfig, error) {
+	flags := cmd.Flags()
+	var cfg core.ArchiveStoreConfig
+	var err error
+	if cfg.Backend, err = flags.GetString("archive-store"); err != nil {
+		return cfg, err
+	}
+	if cfg.Dir, err = flags.GetString("archive-dir"); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.Endpoint, err = flags.GetString("s3-endpoint"); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.Region, err = flags.GetString("s3-region"); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.Bucket, err = flags.GetString("s3-bucket"); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.Prefix, err = flags.GetString("s3-prefix"); err != nil {
+		return cfg, err
+	}
+	if cfg.S3.PathStyle, err = flags.GetBool("s3-path-style"); err != nil {
+		return cfg, err
+	}
+	cfg.S3.AccessKey = firstEnv("BOOKMARKD_S3_ACCESS_KEY", "AWS_ACCESS_KEY_ID")
+	cfg.S3.SecretKey = firstEnv("BOOKMARKD_S3_SECRET_KEY", "AWS_SECRET_ACCESS_KEY")
+	return cfg, nil
+}
+
+// ttsConfig reads the text-to-speech flags. The API key comes from
+// BOOKMARKD_TTS_API_KEY or OPENAI_API_KEY, so it needn't appear in the
+// process list.
+func ttsConfig(cmd *cobra.Command) (core.TTSConfig, error) {
+	flags := cmd.Flags()
+	var cfg core.TTSConfig
+	var err error
+	if cfg.Backend, err = flags.GetString("tts-backend"); err != nil {
+		return cfg, err
+	}
+	if cfg.Command, err = flags.GetString("tts-command"); err != nil {
+		return cfg, err
+	}
+	if cfg.APIURL, err = flags.GetString("tts-api-url"); err != nil {
+		return cfg, err
+	}
+	if cfg.Model, err = flags.GetString("tts-model"); err != nil {
+		return cfg, err
+	}
+	if cfg.Voice, err = flags.GetString("tts-voice"); err != nil {
+		return cfg, err
+	}
+	cfg.APIKey = firstEnv("BOOKMARKD_TTS_API_KEY", "OPENAI_API_KEY")
+	return cfg, nil
+}
+
+// firstEnv returns the value of the first non-empty environment variable.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -6,6 +6,11 @@ package cmd
 import (
 	"bytes"
 	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
 )
 
 func TestRootCmd_Flags(t *testing.T) {
@@ -39,6 +44,36 @@ func TestRootCmd_Flags(t *testing.T) {
 			defaultValue: 1,
 			flagType:     "int",
 		},
+		{
+			name:         "password flag has correct default",
+			flagName:     "password",
+			defaultValue: "",
+			flagType:     "string",
+		},
+		{
+			name:         "write-rate-burst flag has correct default",
+			flagName:     "write-rate-burst",
+			defaultValue: 20,
+			flagType:     "int",
+		},
+		{
+			name:         "add-rate-burst flag has correct default",
+			flagName:     "add-rate-burst",
+			defaultValue: 30,
+			flagType:     "int",
+		},
+		{
+			name:         "add-challenge-difficulty flag has correct default",
+			flagName:     "add-challenge-difficulty",
+			defaultValue: 18,
+			flagType:     "int",
+		},
+		{
+			name:         "quiet-hours flag has correct default",
+			flagName:     "quiet-hours",
+			defaultValue: "",
+			flagType:     "string",
+		},
 	}
 
 	for _, tt := range tests {
@@ -112,3 +147,291 @@ func TestRootCmd_CommandMetadata(t *testing.T) {
 		t.Error("Expected Long description to be set")
 	}
 }
+
+func TestRootCmd_MaxDownloadRateIsPersistent(t *testing.T) {
+	if rootCmd.PersistentFlags().Lookup("max-download-rate") == nil {
+		t.Fatal("Expected --max-download-rate to be a persistent flag")
+	}
+	if archiveCmd.InheritedFlags().Lookup("max-download-rate") == nil {
+		t.Error("Expected archive command to inherit --max-download-rate")
+	}
+}
+
+func TestParseByteRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "0", want: 0},
+		{in: "1024", want: 1024},
+		{in: "500KB", want: 500 * 1024},
+		{in: "2MB/s", want: 2 * 1024 * 1024},
+		{in: "1.5m", want: 3 * 512 * 1024},
+		{in: "1G", want: 1 << 30},
+		{in: "fast", wantErr: true},
+		{in: "-1KB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseByteRate(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseByteRate(%q) expected error, got %d", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseByteRate(%q) unexpected error: %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteRate(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRootCmd_ArchiveStoreFlags(t *testing.T) {
+	for _, name := range []string{"archive-store", "archive-dir", "s3-endpoint", "s3-region", "s3-bucket", "s3-prefix", "s3-path-style"} {
+		if rootCmd.PersistentFlags().Lookup(name) == nil {
+			t.Errorf("Expected persistent flag %s to be defined", name)
+		}
+	}
+
+	store, err := rootCmd.PersistentFlags().GetString("archive-store")
+	if err != nil {
+		t.Fatalf("Failed to get archive-store flag: %v", err)
+	}
+	if store != "sqlite" {
+		t.Errorf("Expected archive-store to default to sqlite, got %s", store)
+	}
+}
+
+func TestArchiveStoreConfig_CredentialsFromEnv(t *testing.T) {
+	t.Setenv("BOOKMARKD_S3_ACCESS_KEY", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "aws-key")
+	t.Setenv("BOOKMARKD_S3_SECRET_KEY", "bookmarkd-secret")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "aws-secret")
+
+	cfg, err := archiveStoreConfig(archiveCmd)
+	if err != nil {
+		t.Fatalf("archiveStoreConfig() error = %v", err)
+	}
+	if cfg.S3.AccessKey != "aws-key" {
+		t.Errorf("AccessKey = %q, want aws-key", cfg.S3.AccessKey)
+	}
+	if cfg.S3.SecretKey != "bookmarkd-secret" {
+		t.Errorf("SecretKey = %q, want bookmarkd-secret", cfg.S3.SecretKey)
+	}
+}
+
+func TestTTSConfig(t *testing.T) {
+	t.Setenv("BOOKMARKD_TTS_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "openai-key")
+
+	cfg, err := ttsConfig(rootCmd)
+	if err != nil {
+		t.Fatalf("ttsConfig() error = %v", err)
+	}
+	if cfg.Backend != core.TTSBackendNone || cfg.Model != core.DefaultTTSModel || cfg.Voice != core.DefaultTTSVoice {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+	if cfg.APIKey != "openai-key" {
+		t.Errorf("APIKey = %q, want openai-key", cfg.APIKey)
+	}
+}
+
+func TestRootCmd_ArchiveMaxAttemptsFlag(t *testing.T) {
+	got, err := rootCmd.Flags().GetInt("archive-max-attempts")
+	if err != nil {
+		t.Fatalf("Failed to get archive-max-attempts flag: %v", err)
+	}
+	if got != 5 {
+		t.Errorf("Expected archive-max-attempts to default to 5, got %d", got)
+	}
+}
+
+func TestRootCmd_MaxQueuedArchivesFlag(t *testing.T) {
+	got, err := rootCmd.Flags().GetInt("max-queued-archives")
+	if err != nil {
+		t.Fatalf("Failed to get max-queued-archives flag: %v", err)
+	}
+	if got != core.DefaultMaxQueuedJobs {
+		t.Errorf("Expected max-queued-archives to default to %d, got %d", core.DefaultMaxQueuedJobs, got)
+	}
+}
+
+func TestRootCmd_ArchiveSettingsFlags(t *testing.T) {
+	got, err := archiveSettings(rootCmd)
+	if err != nil {
+		t.Fatalf("archiveSettings() error = %v", err)
+	}
+	if !got.AutoArchive {
+		t.Error("Expected auto-archive to default to true")
+	}
+	if got.StripScripts || got.MobileViewport || got.Screenshot {
+		t.Errorf("Expected capture options to default to off, got %+v", got)
+	}
+}
+
+func TestRootCmd_RearchiveAfterFlag(t *testing.T) {
+	got, err := rootCmd.Flags().GetString("rearchive-after")
+	if err != nil {
+		t.Fatalf("Failed to get rearchive-after flag: %v", err)
+	}
+	if d, err := parseAge(got); err != nil || d != 0 {
+		t.Errorf("Expected rearchive-after to default to disabled, got %q", got)
+	}
+}
+
+func TestRootCmd_FetchTitlesFlag(t *testing.T) {
+	got, err := rootCmd.Flags().GetBool("fetch-titles")
+	if err != nil {
+		t.Fatalf("Failed to get fetch-titles flag: %v", err)
+	}
+	if !got {
+		t.Error("Expected fetch-titles to default to true")
+	}
+}
+
+func TestRootCmd_SearchRankingFlags(t *testing.T) {
+	got, err := searchRanking(rootCmd)
+	if err != nil {
+		t.Fatalf("Failed to get search ranking flags: %v", err)
+	}
+	if got != db.DefaultSearchRanking() {
+		t.Errorf("Expected the default search ranking, got %+v", got)
+	}
+}
+
+func TestArchivePolicy(t *testing.T) {
+	if archiveCmd.InheritedFlags().Lookup("archive-deny-domains") == nil {
+		t.Error("Expected archive command to inherit --archive-deny-domains")
+	}
+
+	got, err := archivePolicy(archiveCmd, core.ArchiveOptions{Headless: true})
+	if err != nil {
+		t.Fatalf("archivePolicy() error = %v", err)
+	}
+	if !got.Headless || !got.Domains.IsZero() || !got.ResourceDomains.IsZero() || got.RespectRobots || got.Engine != "" {
+		t.Errorf("Expected no domain rules or robots checks by default, got %+v", got)
+	}
+	if got.MaxTotalSize != core.MaxArchiveSize {
+		t.Errorf("Expected the default archive size limit, got %d", got.MaxTotalSize)
+	}
+	if got.ChromeProfileDir != "" {
+		t.Errorf("Expected no Chrome profile by default, got %q", got.ChromeProfileDir)
+	}
+	if got.Blocklist != nil {
+		t.Error("Expected no tracker stripping by default")
+	}
+	if got.Wayback != nil {
+		t.Error("Expected no Wayback Machine lookups by default")
+	}
+	if got.Embeds != core.EmbedsKeep {
+		t.Errorf("Expected embeds to be kept by default, got %q", got.Embeds)
+	}
+	if got.ScrollToBottom || got.ScrollDelay != core.DefaultScrollDelay {
+		t.Errorf("Expected no scrolling by default, got %v, %v", got.ScrollToBottom, got.ScrollDelay)
+	}
+	if got.WaitStrategy != core.WaitNetworkIdle || got.ExtraDelay != 0 {
+		t.Errorf("Expected to wait for network idle by default, got %q, %v", got.WaitStrategy, got.ExtraDelay)
+	}
+	if got.ResourceCacheTTL != core.DefaultResourceCacheTTL {
+		t.Errorf("Expected the default resource cache TTL, got %v", got.ResourceCacheTTL)
+	}
+	if got.Browsers == nil {
+		t.Error("Expected a browser pool by default")
+	}
+	got.Browsers.Close()
+
+	cmd := &cobra.Command{}
+	for _, name := range []string{"archive-allow-domains", "archive-deny-domains", "resource-allow-domains", "resource-deny-domains"} {
+		cmd.Flags().StringSlice(name, nil, "")
+	}
+	cmd.Flags().Bool("respect-robots", false, "")
+	cmd.Flags().String("archive-user-agent", "", "")
+	cmd.Flags().StringArray("archive-header", nil, "")
+	cmd.Flags().String("archive-engine", "auto", "")
+	cmd.Flags().Duration("resource-cache-ttl", core.DefaultResourceCacheTTL, "")
+	cmd.Flags().String("chrome-profile-dir", "", "")
+	cmd.Flags().String("max-archive-size", "50MB", "")
+	cmd.Flags().Bool("strip-trackers", false, "")
+	cmd.Flags().String("archive-embeds", core.EmbedsKeep, "")
+	cmd.Flags().Bool("scroll-to-bottom", false, "")
+	cmd.Flags().Duration("scroll-delay", core.DefaultScrollDelay, "")
+	cmd.Flags().String("wait-strategy", core.WaitNetworkIdle, "")
+	cmd.Flags().Duration("extra-delay", 0, "")
+	cmd.Flags().Int("browser-pool-pages", core.DefaultBrowserPoolPages, "")
+	cmd.Flags().StringArray("filter-list", nil, "")
+	cmd.Flags().Bool("wayback", false, "")
+	for name, value := range map[string]string{"wayback": "true", "max-archive-size": "20MB", "archive-user-agent": " Mozilla/5.0 Firefox/130.0 ", "archive-header": "Accept-Language: de", "archive-engine": "http", "resource-cache-ttl": "0", "chrome-profile-dir": "/var/lib/bookmarkd/chrome", "strip-trackers": "true", "archive-embeds": "Inline", "scroll-to-bottom": "true", "scroll-delay": "1s", "wait-strategy": "DOMContentLoaded", "extra-delay": "2s"} {
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatalf("Failed to set flag: %v", err)
+		}
+	}
+	if err := cmd.Flags().Set("respect-robots", "true"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if err := cmd.Flags().Set("archive-deny-domains", "bank.example.com,*.health.example"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if err := cmd.Flags().Set("resource-deny-domains", "tracker.example"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	got, err = archivePolicy(cmd, core.ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("archivePolicy() error = %v", err)
+	}
+	if got.Domains.Allows("https://bank.example.com/") || got.Domains.Allows("https://my.health.example/") || !got.Domains.Allows("https://example.com/") {
+		t.Errorf("Unexpected page rules: %+v", got.Domains)
+	}
+	if got.ResourceDomains.Allows("https://tracker.example/pixel.gif") {
+		t.Errorf("Unexpected resource rules: %+v", got.ResourceDomains)
+	}
+	if !got.RespectRobots {
+		t.Error("Expected --respect-robots to be read")
+	}
+	if got.UserAgent != "Mozilla/5.0 Firefox/130.0" || got.ExtraHeaders["Accept-Language"] != "de" {
+		t.Errorf("Expected the user agent and headers to be read, got %q, %v", got.UserAgent, got.ExtraHeaders)
+	}
+	if got.Engine != core.ArchiveEngineHTTP {
+		t.Errorf("Expected --archive-engine to be read, got %q", got.Engine)
+	}
+	if got.ResourceCacheTTL != 0 {
+		t.Errorf("Expected --resource-cache-ttl to be read, got %v", got.ResourceCacheTTL)
+	}
+	if got.MaxTotalSize != 20<<20 {
+		t.Errorf("Expected --max-archive-size to be read, got %d", got.MaxTotalSize)
+	}
+	if got.ChromeProfileDir != "/var/lib/bookmarkd/chrome" {
+		t.Errorf("Expected --chrome-profile-dir to be read, got %q", got.ChromeProfileDir)
+	}
+	if got.Blocklist == nil || !got.Blocklist.Blocks("https://www.google-analytics.com/analytics.js", "https://example.com/") {
+		t.Error("Expected --strip-trackers to load the built-in blocklist")
+	}
+	if got.Embeds != core.EmbedsInline {
+		t.Errorf("Expected --archive-embeds to be read, got %q", got.Embeds)
+	}
+	if !got.ScrollToBottom || got.ScrollDelay != time.Second {
+		t.Errorf("Expected --scroll-to-bottom and --scroll-delay to be read, got %v, %v", got.ScrollToBottom, got.ScrollDelay)
+	}
+	if got.WaitStrategy != core.WaitDOMContentLoaded || got.ExtraDelay != 2*time.Second {
+		t.Errorf("Expected --wait-strategy and --extra-delay to be read, got %q, %v", got.WaitStrategy, got.ExtraDelay)
+	}
+	if got.Browsers != nil {
+		t.Error("Expected no browser pool with the HTTP engine")
+	}
+	if got.Wayback == nil {
+		t.Error("Expected --wayback to set up a Wayback Machine client")
+	}
+
+	if err := cmd.Flags().Set("resource-allow-domains", "https://cdn.example"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if _, err := archivePolicy(cmd, core.ArchiveOptions{}); err == nil {
+		t.Error("Expected an error for a URL instead of a domain")
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The routing command manages routing rules, which tag, file or skip
+// archiving for bookmarks as they are created, however they are added.
+//
+// Example usage:
+//
+//	bookmarkd routing add --name gh --domain github.com --add-tag code
+//	bookmarkd routing add --name news --title-contains "breaking" --collection news --skip-archive
+//	bookmarkd routing test https://gist.github.com/x --title "Some gist"
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// routingCmd groups the routing rule subcommands.
+var routingCmd = &cobra.Command{
+	Use:   "routing",
+	Short: "Manage rules that tag, file or skip archiving for new bookmarks",
+}
+
+var routingAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a routing rule",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingAdd(cmd)
+		finishCommand(cmd, "Failed to add routing rule", res, err)
+	},
+}
+
+var routingListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List routing rules in the order they are applied",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingList(cmd)
+		finishCommand(cmd, "Failed to list routing rules", res, err)
+	},
+}
+
+var routingEnableCmd = &cobra.Command{
+	Use:   "enable ID",
+	Short: "Enable a routing rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingSetEnabled(cmd, args[0], true)
+		finishCommand(cmd, "Failed to enable routing rule", res, err)
+	},
+}
+
+var routingDisableCmd = &cobra.Command{
+	Use:   "disable ID",
+	Short: "Disable a routing rule without deleting it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingSetEnabled(cmd, args[0], false)
+		finishCommand(cmd, "Failed to disable routing rule", res, err)
+	},
+}
+
+var routingRemoveCmd = &cobra.Command{
+	Use:   "rm ID",
+	Short: "Delete a routing rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingRemove(cmd, args[0])
+		finishCommand(cmd, "Failed to delete routing rule", res, err)
+	},
+}
+
+var routingTestCmd = &cobra.Command{
+	Use:   "test URL",
+	Short: "Show how a bookmark would be routed without saving it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRoutingTest(cmd, args[0])
+		finishCommand(cmd, "Failed to test routing rules", res, err)
+	},
+}
+
+// routingRuleResult describes a routing rule in command output.
+type routingRuleResult struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Domain        string `json:"domain,omitempty"`
+	TitleContains string `json:"title_contains,omitempty"`
+	AddTag        string `json:"add_tag,omitempty"`
+	Collection    string `json:"collection,omitempty"`
+	SkipArchive   bool   `json:"skip_archive"`
+	Enabled       bool   `json:"enabled"`
+}
+
+func newRoutingRuleResult(r db.RoutingRule) routingRuleResult {
+	return routingRuleResult{
+		ID:            r.ID,
+		Name:          r.Name,
+		Domain:        r.Domain,
+		TitleContains: r.TitleContains,
+		AddTag:        r.AddTag,
+		Collection:    r.Collection,
+		SkipArchive:   r.SkipArchive,
+		Enabled:       r.Enabled,
+	}
+}
+
+// describe renders a rule as a sentence, e.g.
+// "bookmarks on github.com are tagged #code".
+func (r routingRuleResult) describe() string {
+	var conds []string
+	if r.Domain != "" {
+		conds = append(conds, "on "+r.Domain)
+	}
+	if r.TitleContains != "" {
+		conds = append(conds, fmt.Sprintf("with %q in the title", r.TitleContains))
+	}
+	var actions []string
+	if r.AddTag != "" {
+		actions = append(actions, "tagged #"+r.AddTag)
+	}
+	if r.Collection != "" {
+		actions = append(actions, "filed in "+r.Collection)
+	}
+	if r.SkipArchive {
+		actions = append(actions, "not archived")
+	}
+	return "bookmarks " + strings.Join(conds, " or ") + " are " + strings.Join(actions, ", ")
+}
+
+// routingTestResult is the outcome of "routing test".
+type routingTestResult struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Rules       []string `json:"rules"`
+	Tags        []string `json:"tags"`
+	Collection  string   `json:"collection,omitempty"`
+	SkipArchive bool     `json:"skip_archive"`
+}
+
+func runRoutingAdd(cmd *cobra.Command) (routingRuleResult, error) {
+	flags := cmd.Flags()
+	var r db.RoutingRule
+	var err error
+	if r.Name, err = flags.GetString("name"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --name: %w", err)
+	}
+	if r.Domain, err = flags.GetString("domain"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --domain: %w", err)
+	}
+	if r.TitleContains, err = flags.GetString("title-contains"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --title-contains: %w", err)
+	}
+	if r.AddTag, err = flags.GetString("add-tag"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --add-tag: %w", err)
+	}
+	if r.Collection, err = flags.GetString("collection"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --collection: %w", err)
+	}
+	if r.SkipArchive, err = flags.GetBool("skip-archive"); err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --skip-archive: %w", err)
+	}
+	disabled, err := flags.GetBool("disabled")
+	if err != nil {
+		return routingRuleResult{}, fmt.Errorf("failed to read --disabled: %w", err)
+	}
+	r.Enabled = !disabled
+
+	return withDB(cmd, func(database *db.DB) (routingRuleResult, error) {
+		id, err := database.CreateRoutingRule(r)
+		if err != nil {
+			return routingRuleResult{}, err
+		}
+		created, err := database.GetRoutingRule(id)
+		if err != nil {
+			return routingRuleResult{}, err
+		}
+		res := newRoutingRuleResult(created)
+		log.Printf("Added routing rule %d (%s): %s", res.ID, res.Name, res.describe())
+		return res, nil
+	})
+}
+
+func runRoutingList(cmd *cobra.Command) ([]routingRuleResult, error) {
+	return withDB(cmd, func(database *db.DB) ([]routingRuleResult, error) {
+		rules, err := database.ListRoutingRules()
+		if err != nil {
+			return nil, err
+		}
+		res := []routingRuleResult{}
+		for _, r := range rules {
+			rr := newRoutingRuleResult(r)
+			res = append(res, rr)
+			if !jsonOutput(cmd) {
+				state := "enabled"
+				if !rr.Enabled {
+					state = "disabled"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\t%s\n", rr.ID, rr.Name, state, rr.describe())
+			}
+		}
+		if len(res) == 0 && !jsonOutput(cmd) {
+			log.Println("No routing rules.")
+		}
+		return res, nil
+	})
+}
+
+func runRoutingSetEnabled(cmd *cobra.Command, arg string, enabled bool) (routingRuleResult, error) {
+	id, err := parseRuleID(arg)
+	if err != nil {
+		return routingRuleResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (routingRuleResult, error) {
+		if err := database.SetRoutingRuleEnabled(id, enabled); err != nil {
+			return routingRuleResult{}, err
+		}
+		r, err := database.GetRoutingRule(id)
+		if err != nil {
+			return routingRuleResult{}, err
+		}
+		return newRoutingRuleResult(r), nil
+	})
+}
+
+func runRoutingRemove(cmd *cobra.Command, arg string) (routingRuleResult, error) {
+	id, err := parseRuleID(arg)
+	if err != nil {
+		return routingRuleResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (routingRuleResult, error) {
+		r, err := database.GetRoutingRule(id)
+		if err != nil {
+			return routingRuleResult{}, err
+		}
+		if err := database.DeleteRoutingRule(id); err != nil {
+			return routingRuleResult{}, err
+		}
+		log.Printf("Deleted routing rule %d (%s)", r.ID, r.Name)
+		return newRoutingRuleResult(r), nil
+	})
+}
+
+func runRoutingTest(cmd *cobra.Command, url string) (routingTestResult, error) {
+	title, err := cmd.Flags().GetString("title")
+	if err != nil {
+		return routingTestResult{}, fmt.Errorf("failed to read --title: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (routingTestResult, error) {
+		rules, err := database.ListRoutingRules()
+		if err != nil {
+			return routingTestResult{}, err
+		}
+		route := db.RouteBookmark(rules, url, title)
+		res := routingTestResult{
+			URL:         url,
+			Title:       title,
+			Rules:       append([]string{}, route.Rules...),
+			Tags:        append([]string{}, route.Tags...),
+			Collection:  route.Collection,
+			SkipArchive: route.SkipArchive,
+		}
+		if !jsonOutput(cmd) {
+			if len(res.Rules) == 0 {
+				log.Println("No routing rules match.")
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "rules\t%s\ntags\t%s\ncollection\t%s\nskip archive\t%t\n",
+					strings.Join(res.Rules, ", "), strings.Join(res.Tags, ", "), res.Collection, res.SkipArchive)
+			}
+		}
+		return res, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(routingCmd)
+	routingCmd.AddCommand(routingAddCmd, routingListCmd, routingEnableCmd, routingDisableCmd, routingRemoveCmd, routingTestCmd)
+
+	routingAddCmd.Flags().String("name", "", "Unique rule name (required)")
+	routingAddCmd.Flags().String("domain", "", "Match bookmarks on this domain or its subdomains")
+	routingAddCmd.Flags().String("title-contains", "", "Match bookmarks whose title contains this text (case-insensitive)")
+	routingAddCmd.Flags().String("add-tag", "", "Tag to add to matching bookmarks")
+	routingAddCmd.Flags().String("collection", "", "Collection to file matching bookmarks in")
+	routingAddCmd.Flags().Bool("skip-archive", false, "Don't archive matching bookmarks automatically")
+	routingAddCmd.Flags().Bool("disabled", false, "Create the rule disabled")
+
+	routingTestCmd.Flags().String("title", "", "Title of the bookmark to test")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestRoutingCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"add": false, "list": false, "enable": false, "disable": false, "rm": false, "test": false}
+	for _, c := range routingCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected routing subcommand %s", name)
+		}
+	}
+
+	for _, name := range []string{"name", "domain", "title-contains", "add-tag", "collection", "skip-archive", "disabled"} {
+		if routingAddCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected routing add flag %s to be defined", name)
+		}
+	}
+	if routingTestCmd.Flags().Lookup("title") == nil {
+		t.Error("Expected routing test flag title to be defined")
+	}
+}
+
+func TestRoutingRuleResult_Describe(t *testing.T) {
+	tests := []struct {
+		rule routingRuleResult
+		want string
+	}{
+		{routingRuleResult{Domain: "github.com", AddTag: "code"}, "bookmarks on github.com are tagged #code"},
+		{routingRuleResult{Domain: "youtube.com", TitleContains: "talk", Collection: "Talks", SkipArchive: true},
+			`bookmarks on youtube.com or with "talk" in the title are filed in Talks, not archived`},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.describe(); got != tt.want {
+			t.Errorf("describe() = %q, want %q", got, tt.want)
+		}
+	}
+}
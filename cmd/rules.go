@@ -0,0 +1,360 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The rules command manages bookmark expiration / auto-cleanup rules. The
+// server runs enabled rules every --cleanup-interval; "rules run" runs them
+// on demand.
+//
+// Example usage:
+//
+//	bookmarkd rules add --name temp --tag temp --older-than 30d --action delete
+//	bookmarkd rules add --name stale --unread --older-than 1y --action tag --add-tag stale
+//	bookmarkd rules run --dry-run
+//	bookmarkd rules log --limit 20
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// rulesCmd groups the cleanup rule subcommands.
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Manage bookmark expiration and auto-cleanup rules",
+}
+
+var rulesAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a cleanup rule",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesAdd(cmd)
+		finishCommand(cmd, "Failed to add rule", res, err)
+	},
+}
+
+var rulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cleanup rules",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesList(cmd)
+		finishCommand(cmd, "Failed to list rules", res, err)
+	},
+}
+
+var rulesEnableCmd = &cobra.Command{
+	Use:   "enable ID",
+	Short: "Enable a cleanup rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesSetEnabled(cmd, args[0], true)
+		finishCommand(cmd, "Failed to enable rule", res, err)
+	},
+}
+
+var rulesDisableCmd = &cobra.Command{
+	Use:   "disable ID",
+	Short: "Disable a cleanup rule without deleting it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesSetEnabled(cmd, args[0], false)
+		finishCommand(cmd, "Failed to disable rule", res, err)
+	},
+}
+
+var rulesRemoveCmd = &cobra.Command{
+	Use:   "rm ID",
+	Short: "Delete a cleanup rule (its audit log is kept)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesRemove(cmd, args[0])
+		finishCommand(cmd, "Failed to delete rule", res, err)
+	},
+}
+
+var rulesRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run cleanup rules now",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesRun(cmd)
+		finishCommand(cmd, "Cleanup failed", res, err)
+	},
+}
+
+var rulesLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the cleanup audit log",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runRulesLog(cmd)
+		finishCommand(cmd, "Failed to read cleanup log", res, err)
+	},
+}
+
+// ruleResult describes a cleanup rule in command output.
+type ruleResult struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Tag           string `json:"tag,omitempty"`
+	UnreadOnly    bool   `json:"unread_only"`
+	OlderThanDays int    `json:"older_than_days"`
+	Action        string `json:"action"`
+	ActionTag     string `json:"action_tag,omitempty"`
+	Enabled       bool   `json:"enabled"`
+	LastRunAt     string `json:"last_run_at,omitempty"`
+}
+
+func newRuleResult(r db.CleanupRule) ruleResult {
+	return ruleResult{
+		ID:            r.ID,
+		Name:          r.Name,
+		Tag:           r.Tag,
+		UnreadOnly:    r.UnreadOnly,
+		OlderThanDays: r.OlderThanDays,
+		Action:        r.Action,
+		ActionTag:     r.ActionTag,
+		Enabled:       r.Enabled,
+		LastRunAt:     r.LastRunAt,
+	}
+}
+
+// describe renders a rule as a sentence, e.g.
+// "bookmarks tagged #temp older than 30 days are deleted".
+func (r ruleResult) describe() string {
+	s := "bookmarks"
+	if r.UnreadOnly {
+		s = "unread " + s
+	}
+	if r.Tag != "" {
+		s += " tagged #" + r.Tag
+	}
+	s += fmt.Sprintf(" older than %d day(s)", r.OlderThanDays)
+	if r.Action == db.CleanupActionTag {
+		return s + " are tagged #" + r.ActionTag
+	}
+	return s + " are deleted"
+}
+
+// cleanupLogResult is one audit log entry in command output.
+type cleanupLogResult struct {
+	At         string `json:"at"`
+	Rule       string `json:"rule"`
+	Action     string `json:"action"`
+	BookmarkID int64  `json:"bookmark_id"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
+
+// withDB opens the database for a rules subcommand and closes it when fn returns.
+func withDB[T any](cmd *cobra.Command, fn func(*db.DB) (T, error)) (T, error) {
+	database, err := initDB(cmd)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			log.Printf("failed to close database: %v", err)
+		}
+	}()
+	return fn(database)
+}
+
+func runRulesAdd(cmd *cobra.Command) (ruleResult, error) {
+	flags := cmd.Flags()
+	var r db.CleanupRule
+	var err error
+	if r.Name, err = flags.GetString("name"); err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --name: %w", err)
+	}
+	if r.Tag, err = flags.GetString("tag"); err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --tag: %w", err)
+	}
+	if r.UnreadOnly, err = flags.GetBool("unread"); err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --unread: %w", err)
+	}
+	if r.Action, err = flags.GetString("action"); err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --action: %w", err)
+	}
+	if r.ActionTag, err = flags.GetString("add-tag"); err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --add-tag: %w", err)
+	}
+	disabled, err := flags.GetBool("disabled")
+	if err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --disabled: %w", err)
+	}
+	r.Enabled = !disabled
+	ageStr, err := flags.GetString("older-than")
+	if err != nil {
+		return ruleResult{}, fmt.Errorf("failed to read --older-than: %w", err)
+	}
+	age, err := parseAge(ageStr)
+	if err != nil {
+		return ruleResult{}, fmt.Errorf("invalid --older-than: %w", err)
+	}
+	if age%(24*time.Hour) != 0 {
+		return ruleResult{}, fmt.Errorf("invalid --older-than: %q is not a whole number of days", ageStr)
+	}
+	r.OlderThanDays = int(age / (24 * time.Hour))
+
+	return withDB(cmd, func(database *db.DB) (ruleResult, error) {
+		id, err := database.CreateCleanupRule(r)
+		if err != nil {
+			return ruleResult{}, err
+		}
+		created, err := database.GetCleanupRule(id)
+		if err != nil {
+			return ruleResult{}, err
+		}
+		res := newRuleResult(created)
+		log.Printf("Added rule %d (%s): %s", res.ID, res.Name, res.describe())
+		return res, nil
+	})
+}
+
+func runRulesList(cmd *cobra.Command) ([]ruleResult, error) {
+	return withDB(cmd, func(database *db.DB) ([]ruleResult, error) {
+		rules, err := database.ListCleanupRules()
+		if err != nil {
+			return nil, err
+		}
+		res := []ruleResult{}
+		for _, r := range rules {
+			rr := newRuleResult(r)
+			res = append(res, rr)
+			if !jsonOutput(cmd) {
+				state := "enabled"
+				if !rr.Enabled {
+					state = "disabled"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\t%s\n", rr.ID, rr.Name, state, rr.describe())
+			}
+		}
+		if len(res) == 0 && !jsonOutput(cmd) {
+			log.Println("No cleanup rules.")
+		}
+		return res, nil
+	})
+}
+
+func runRulesSetEnabled(cmd *cobra.Command, arg string, enabled bool) (ruleResult, error) {
+	id, err := parseRuleID(arg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (ruleResult, error) {
+		if err := database.SetCleanupRuleEnabled(id, enabled); err != nil {
+			return ruleResult{}, err
+		}
+		r, err := database.GetCleanupRule(id)
+		if err != nil {
+			return ruleResult{}, err
+		}
+		return newRuleResult(r), nil
+	})
+}
+
+func runRulesRemove(cmd *cobra.Command, arg string) (ruleResult, error) {
+	id, err := parseRuleID(arg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (ruleResult, error) {
+		r, err := database.GetCleanupRule(id)
+		if err != nil {
+			return ruleResult{}, err
+		}
+		if err := database.DeleteCleanupRule(id); err != nil {
+			return ruleResult{}, err
+		}
+		log.Printf("Deleted rule %d (%s)", r.ID, r.Name)
+		return newRuleResult(r), nil
+	})
+}
+
+func runRulesRun(cmd *cobra.Command) (core.CleanupResult, error) {
+	id, err := cmd.Flags().GetInt64("id")
+	if err != nil {
+		return core.CleanupResult{}, fmt.Errorf("failed to read --id: %w", err)
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return core.CleanupResult{}, fmt.Errorf("failed to read --dry-run: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (core.CleanupResult, error) {
+		res, err := core.RunCleanupRules(context.Background(), database, core.CleanupOptions{RuleID: id, DryRun: dryRun})
+		if len(res.Rules) == 0 && err == nil {
+			log.Println("No enabled cleanup rules.")
+		}
+		return res, err
+	})
+}
+
+func runRulesLog(cmd *cobra.Command) ([]cleanupLogResult, error) {
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --limit: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) ([]cleanupLogResult, error) {
+		entries, err := database.ListCleanupLog(limit)
+		if err != nil {
+			return nil, err
+		}
+		res := []cleanupLogResult{}
+		for _, e := range entries {
+			res = append(res, cleanupLogResult{
+				At:         e.CreatedAt,
+				Rule:       e.RuleName,
+				Action:     e.Action,
+				BookmarkID: e.BookmarkID,
+				URL:        e.BookmarkURL,
+				Title:      e.BookmarkTitle,
+			})
+			if !jsonOutput(cmd) {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%d\t%s\n", e.CreatedAt, e.RuleName, e.Action, e.BookmarkID, e.BookmarkURL)
+			}
+		}
+		return res, nil
+	})
+}
+
+// parseRuleID parses a rule ID argument.
+func parseRuleID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid rule ID %q", s)
+	}
+	return id, nil
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesAddCmd, rulesListCmd, rulesEnableCmd, rulesDisableCmd, rulesRemoveCmd, rulesRunCmd, rulesLogCmd)
+
+	rulesAddCmd.Flags().String("name", "", "Unique rule name (required)")
+	rulesAddCmd.Flags().String("tag", "", "Only match bookmarks with this tag")
+	rulesAddCmd.Flags().Bool("unread", false, "Only match bookmarks whose archive was never opened")
+	rulesAddCmd.Flags().String("older-than", "", "Match bookmarks saved longer ago than this (e.g. 30d, 2w, 1y; required)")
+	rulesAddCmd.Flags().String("action", db.CleanupActionDelete, "What to do with matches: delete or tag")
+	rulesAddCmd.Flags().String("add-tag", "", "Tag to add with --action=tag")
+	rulesAddCmd.Flags().Bool("disabled", false, "Create the rule disabled")
+	if err := rulesAddCmd.RegisterFlagCompletionFunc("action", completeFixed(db.CleanupActionDelete, db.CleanupActionTag)); err != nil {
+		log.Fatalf("Failed to register completion for --action: %v", err)
+	}
+
+	rulesRunCmd.Flags().Int64("id", 0, "Run only this rule (even if disabled)")
+	rulesRunCmd.Flags().Bool("dry-run", false, "Report what would change without changing anything")
+
+	rulesLogCmd.Flags().Int("limit", 50, "Number of entries to show (0 = all)")
+}
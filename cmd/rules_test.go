@@ -0,0 +1,57 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestRulesCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"add": false, "list": false, "enable": false, "disable": false, "rm": false, "run": false, "log": false}
+	for _, c := range rulesCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected rules subcommand %s", name)
+		}
+	}
+
+	for _, name := range []string{"name", "tag", "unread", "older-than", "action", "add-tag", "disabled"} {
+		if rulesAddCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected rules add flag %s to be defined", name)
+		}
+	}
+	for _, name := range []string{"id", "dry-run"} {
+		if rulesRunCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected rules run flag %s to be defined", name)
+		}
+	}
+}
+
+func TestRuleResult_Describe(t *testing.T) {
+	tests := []struct {
+		rule ruleResult
+		want string
+	}{
+		{ruleResult{Tag: "temp", OlderThanDays: 30, Action: "delete"}, "bookmarks tagged #temp older than 30 day(s) are deleted"},
+		{ruleResult{UnreadOnly: true, OlderThanDays: 365, Action: "tag", ActionTag: "stale"}, "unread bookmarks older than 365 day(s) are tagged #stale"},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.describe(); got != tt.want {
+			t.Errorf("describe() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseRuleID(t *testing.T) {
+	if id, err := parseRuleID("12"); err != nil || id != 12 {
+		t.Errorf("parseRuleID(12) = %d, %v", id, err)
+	}
+	for _, in := range []string{"", "0", "-1", "abc"} {
+		if _, err := parseRuleID(in); err == nil {
+			t.Errorf("parseRuleID(%q) expected error", in)
+		}
+	}
+}
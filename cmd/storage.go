@@ -0,0 +1,198 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The storage command records and reports disk usage. The server samples
+// storage after each run of the cleanup rules; "storage record" takes a
+// sample on demand, e.g. at the end of a backup script. "storage forecast"
+// fits a line through recent samples and estimates when the disk fills up.
+// "storage resource-cache" reports, or with --clear empties, the cache of
+// page resources shared between archives.
+//
+// Example usage:
+//
+//	bookmarkd storage record
+//	bookmarkd storage list --since 720h
+//	bookmarkd storage forecast --json
+//	bookmarkd storage resource-cache --clear
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// storageCmd groups the storage subcommands.
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Record and forecast disk usage",
+}
+
+var storageRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Measure the database, archive store and free disk space",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runStorageRecord(cmd)
+		finishCommand(cmd, "Failed to record storage sample", res, err)
+	},
+}
+
+var storageListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List storage samples, oldest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runStorageList(cmd)
+		finishCommand(cmd, "Failed to list storage samples", res, err)
+	},
+}
+
+var storageForecastCmd = &cobra.Command{
+	Use:   "forecast",
+	Short: "Estimate storage growth and when the disk fills up",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runStorageForecast(cmd)
+		finishCommand(cmd, "Failed to forecast storage", res, err)
+	},
+}
+
+var storageResourceCacheCmd = &cobra.Command{
+	Use:   "resource-cache",
+	Short: "Report or clear the cache of page resources shared between archives",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runStorageResourceCache(cmd)
+		finishCommand(cmd, "Failed to read resource cache", res, err)
+	},
+}
+
+// storageSampleResult describes a storage sample in command output.
+type storageSampleResult struct {
+	ID                int64  `json:"id"`
+	TakenAt           string `json:"taken_at"`
+	Source            string `json:"source"`
+	DatabaseBytes     int64  `json:"database_bytes"`
+	ArchiveStoreBytes int64  `json:"archive_store_bytes"`
+	FreeBytes         int64  `json:"free_bytes"`
+}
+
+func newStorageSampleResult(s db.StorageSample) storageSampleResult {
+	return storageSampleResult{
+		ID:                s.ID,
+		TakenAt:           s.TakenAt,
+		Source:            s.Source,
+		DatabaseBytes:     s.DatabaseBytes,
+		ArchiveStoreBytes: s.ArchiveStoreBytes,
+		FreeBytes:         s.FreeBytes,
+	}
+}
+
+// storageForecastResult is the output of "storage forecast".
+type storageForecastResult struct {
+	core.StorageForecast
+	Summary string `json:"summary"`
+}
+
+// resourceCacheResult is the output of "storage resource-cache".
+type resourceCacheResult struct {
+	URLs    int   `json:"urls"`
+	Bytes   int64 `json:"bytes"`
+	Cleared bool  `json:"cleared"`
+}
+
+// freeText formats free disk space, which is -1 when unknown.
+func freeText(free int64) string {
+	if free < 0 {
+		return "unknown"
+	}
+	return core.FormatBytes(free)
+}
+
+func runStorageRecord(cmd *cobra.Command) (storageSampleResult, error) {
+	return withDB(cmd, func(database *db.DB) (storageSampleResult, error) {
+		s, err := core.RecordStorageSample(cmd.Context(), database, core.StorageSourceManual)
+		if err != nil {
+			return storageSampleResult{}, err
+		}
+		log.Printf("Recorded storage sample %d: %s used, %s free",
+			s.ID, core.FormatBytes(core.StorageUsed(s)), freeText(s.FreeBytes))
+		return newStorageSampleResult(s), nil
+	})
+}
+
+func runStorageList(cmd *cobra.Command) ([]storageSampleResult, error) {
+	since, err := cmd.Flags().GetDuration("since")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --since: %w", err)
+	}
+	var from time.Time
+	if since > 0 {
+		from = time.Now().Add(-since)
+	}
+	return withDB(cmd, func(database *db.DB) ([]storageSampleResult, error) {
+		samples, err := database.ListStorageSamples(from)
+		if err != nil {
+			return nil, err
+		}
+		res := []storageSampleResult{}
+		for _, s := range samples {
+			res = append(res, newStorageSampleResult(s))
+			if !jsonOutput(cmd) {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s used\t%s free\n",
+					s.TakenAt, s.Source, core.FormatBytes(core.StorageUsed(s)), freeText(s.FreeBytes))
+			}
+		}
+		return res, nil
+	})
+}
+
+func runStorageForecast(cmd *cobra.Command) (storageForecastResult, error) {
+	return withDB(cmd, func(database *db.DB) (storageForecastResult, error) {
+		samples, err := database.ListStorageSamples(time.Now().Add(-core.StorageForecastWindow))
+		if err != nil {
+			return storageForecastResult{}, err
+		}
+		f := core.ForecastStorage(samples)
+		if !jsonOutput(cmd) {
+			fmt.Fprintln(cmd.OutOrStdout(), f.Summary())
+		}
+		return storageForecastResult{StorageForecast: f, Summary: f.Summary()}, nil
+	})
+}
+
+func runStorageResourceCache(cmd *cobra.Command) (resourceCacheResult, error) {
+	clearCache, err := cmd.Flags().GetBool("clear")
+	if err != nil {
+		return resourceCacheResult{}, fmt.Errorf("failed to read --clear: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (resourceCacheResult, error) {
+		if clearCache {
+			if err := database.ClearResourceCache(); err != nil {
+				return resourceCacheResult{}, err
+			}
+		}
+		urls, size, err := database.ResourceCacheSize()
+		if err != nil {
+			return resourceCacheResult{}, err
+		}
+		if !jsonOutput(cmd) {
+			fmt.Fprintf(cmd.OutOrStdout(), "%d resource(s), %s\n", urls, core.FormatBytes(size))
+		}
+		return resourceCacheResult{URLs: urls, Bytes: size, Cleared: clearCache}, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageRecordCmd, storageListCmd, storageForecastCmd, storageResourceCacheCmd)
+
+	storageListCmd.Flags().Duration("since", 0, "Only list samples from this long ago (0 for all)")
+	storageResourceCacheCmd.Flags().Bool("clear", false, "Empty the cache, so every resource is downloaded again")
+}
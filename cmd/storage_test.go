@@ -0,0 +1,35 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestStorageCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"record": false, "list": false, "forecast": false, "resource-cache": false}
+	for _, c := range storageCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected storage subcommand %s", name)
+		}
+	}
+	if storageListCmd.Flags().Lookup("since") == nil {
+		t.Error("Expected storage list flag since to be defined")
+	}
+	if storageResourceCacheCmd.Flags().Lookup("clear") == nil {
+		t.Error("Expected storage resource-cache flag clear to be defined")
+	}
+}
+
+func TestFreeText(t *testing.T) {
+	if got := freeText(-1); got != "unknown" {
+		t.Errorf("expected unknown, got %q", got)
+	}
+	if got := freeText(2048); got != "2.0 KB" {
+		t.Errorf("expected 2.0 KB, got %q", got)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The tokens command manages API tokens, which third-party apps send as
+// "Authorization: Bearer <token>". Requests made with a token act as the
+// user who owns it. Each token has a quota of requests per hour; 0 uses the
+// server's --api-token-quota.
+//
+// Example usage:
+//
+//	bookmarkd tokens create "Reader app" --quota 500 --user alice
+//	bookmarkd tokens list
+//	bookmarkd tokens quota 3 2000
+//	bookmarkd tokens revoke 3
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// tokensCmd groups the API token subcommands.
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Manage API tokens for third-party apps",
+}
+
+var tokensCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create an API token and print it",
+	Long: `Create an API token and print it. Only a hash of the token is stored, so
+copy it now; it can't be shown again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runTokensCreate(cmd, args[0])
+		finishCommand(cmd, "Failed to create API token", res, err)
+	},
+}
+
+var tokensListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runTokensList(cmd)
+		finishCommand(cmd, "Failed to list API tokens", res, err)
+	},
+}
+
+var tokensQuotaCmd = &cobra.Command{
+	Use:   "quota ID REQUESTS",
+	Short: "Set a token's quota in requests per hour (0 = server default)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runTokensQuota(cmd, args[0], args[1])
+		finishCommand(cmd, "Failed to set API token quota", res, err)
+	},
+}
+
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke ID",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runTokensRevoke(cmd, args[0])
+		finishCommand(cmd, "Failed to revoke API token", res, err)
+	},
+}
+
+// tokenResult describes an API token in command output. Token is only set
+// when the token is created.
+type tokenResult struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Scope      string `json:"scope,omitempty"`
+	Quota      int    `json:"quota"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	Token      string `json:"token,omitempty"`
+}
+
+func newTokenResult(t db.APIToken) tokenResult {
+	return tokenResult{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scope:      t.Scope,
+		Quota:      t.Quota,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+// quotaText describes a token quota for text output.
+func quotaText(quota int) string {
+	if quota == 0 {
+		return "default quota"
+	}
+	return fmt.Sprintf("%d/hour", quota)
+}
+
+func runTokensCreate(cmd *cobra.Command, name string) (tokenResult, error) {
+	quota, err := cmd.Flags().GetInt("quota")
+	if err != nil {
+		return tokenResult{}, fmt.Errorf("failed to read --quota: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (tokenResult, error) {
+		scoped, err := userDB(cmd, database)
+		if err != nil {
+			return tokenResult{}, err
+		}
+		t, token, err := scoped.CreateAPIToken(name, quota)
+		if err != nil {
+			return tokenResult{}, err
+		}
+		res := newTokenResult(t)
+		res.Token = token
+		log.Printf("Created API token %d (%s, %s); it won't be shown again", t.ID, t.Name, quotaText(t.Quota))
+		if !jsonOutput(cmd) {
+			fmt.Fprintln(cmd.OutOrStdout(), token)
+		}
+		return res, nil
+	})
+}
+
+func runTokensList(cmd *cobra.Command) ([]tokenResult, error) {
+	return withDB(cmd, func(database *db.DB) ([]tokenResult, error) {
+		tokens, err := database.ListAPITokens()
+		if err != nil {
+			return nil, err
+		}
+		res := []tokenResult{}
+		for _, t := range tokens {
+			res = append(res, newTokenResult(t))
+			if !jsonOutput(cmd) {
+				lastUsed := t.LastUsedAt
+				if lastUsed == "" {
+					lastUsed = "never used"
+				}
+				scope := t.Scope
+				if scope == "" {
+					scope = "full access"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\t%s\t%s\n", t.ID, t.Name, scope, quotaText(t.Quota), lastUsed)
+			}
+		}
+		if len(res) == 0 && !jsonOutput(cmd) {
+			log.Println("No API tokens.")
+		}
+		return res, nil
+	})
+}
+
+func runTokensQuota(cmd *cobra.Command, idArg, quotaArg string) (tokenResult, error) {
+	id, err := parseTokenID(idArg)
+	if err != nil {
+		return tokenResult{}, err
+	}
+	quota, err := strconv.Atoi(quotaArg)
+	if err != nil || quota < 0 {
+		return tokenResult{}, fmt.Errorf("invalid quota %q", quotaArg)
+	}
+	return withDB(cmd, func(database *db.DB) (tokenResult, error) {
+		if err := database.SetAPITokenQuota(id, quota); err != nil {
+			return tokenResult{}, err
+		}
+		t, err := database.GetAPIToken(id)
+		if err != nil {
+			return tokenResult{}, err
+		}
+		log.Printf("API token %d (%s) now has %s", t.ID, t.Name, quotaText(t.Quota))
+		return newTokenResult(t), nil
+	})
+}
+
+func runTokensRevoke(cmd *cobra.Command, arg string) (tokenResult, error) {
+	id, err := parseTokenID(arg)
+	if err != nil {
+		return tokenResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (tokenResult, error) {
+		t, err := database.GetAPIToken(id)
+		if err != nil {
+			return tokenResult{}, err
+		}
+		if err := database.RevokeAPIToken(id); err != nil {
+			return tokenResult{}, err
+		}
+		log.Printf("Revoked API token %d (%s)", t.ID, t.Name)
+		return newTokenResult(t), nil
+	})
+}
+
+func parseTokenID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid token ID %q", s)
+	}
+	return id, nil
+}
+
+func init() {
+	rootCmd.AddCommand(tokensCmd)
+	tokensCmd.AddCommand(tokensCreateCmd, tokensListCmd, tokensQuotaCmd, tokensRevokeCmd)
+
+	tokensCreateCmd.Flags().Int("quota", 0, "Requests per hour the token may make (0 = the server's --api-token-quota)")
+	tokensCreateCmd.Flags().String("user", "", "Username the token acts as (default: the first account)")
+}
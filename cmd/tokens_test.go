@@ -0,0 +1,35 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestTokensCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"create": false, "list": false, "quota": false, "revoke": false}
+	for _, c := range tokensCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected tokens subcommand %s", name)
+		}
+	}
+	if tokensCreateCmd.Flags().Lookup("quota") == nil {
+		t.Error("Expected tokens create flag quota to be defined")
+	}
+	if rootCmd.Flags().Lookup("api-token-quota") == nil {
+		t.Error("Expected api-token-quota flag to be defined")
+	}
+}
+
+func TestQuotaText(t *testing.T) {
+	if got := quotaText(0); got != "default quota" {
+		t.Errorf("expected default quota, got %q", got)
+	}
+	if got := quotaText(500); got != "500/hour" {
+		t.Errorf("expected 500/hour, got %q", got)
+	}
+}
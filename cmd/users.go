@@ -0,0 +1,269 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The users command manages accounts. Each user has their own bookmarks,
+// archive preferences and API tokens, and logs in to the web UI with their
+// username and password. Admins may also see the activity log and change
+// routing rules, which apply to everyone. The first account, "admin", owns
+// every bookmark saved before accounts existed.
+//
+// Example usage:
+//
+//	bookmarkd users add alice --password-stdin < alice.txt
+//	bookmarkd users list
+//	bookmarkd users passwd admin --password-stdin
+//	bookmarkd users admin alice
+//	bookmarkd users rm bob
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// usersCmd groups the user account subcommands.
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage user accounts",
+}
+
+var usersAddCmd = &cobra.Command{
+	Use:   "add USERNAME",
+	Short: "Add a user",
+	Long: `Add a user. Without a password the user can't log in until one is set
+with "users passwd". Once any user has a password the web UI requires a
+login.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runUsersAdd(cmd, args[0])
+		finishCommand(cmd, "Failed to add user", res, err)
+	},
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runUsersList(cmd)
+		finishCommand(cmd, "Failed to list users", res, err)
+	},
+}
+
+var usersPasswdCmd = &cobra.Command{
+	Use:   "passwd USERNAME",
+	Short: "Set a user's password (empty to stop them logging in)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runUsersPasswd(cmd, args[0])
+		finishCommand(cmd, "Failed to set password", res, err)
+	},
+}
+
+var usersAdminCmd = &cobra.Command{
+	Use:   "admin USERNAME",
+	Short: "Make a user an admin (--revoke to take it away)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runUsersAdmin(cmd, args[0])
+		finishCommand(cmd, "Failed to change admin rights", res, err)
+	},
+}
+
+var usersRmCmd = &cobra.Command{
+	Use:   "rm USERNAME",
+	Short: "Delete a user with all their bookmarks and archives",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runUsersRm(cmd, args[0])
+		finishCommand(cmd, "Failed to delete user", res, err)
+	},
+}
+
+// userResult describes a user in command output.
+type userResult struct {
+	ID               int64  `json:"id"`
+	Username         string `json:"username"`
+	Admin            bool   `json:"admin"`
+	HasPassword      bool   `json:"has_password"`
+	CreatedAt        string `json:"created_at"`
+	DeletedBookmarks int    `json:"deleted_bookmarks,omitempty"`
+}
+
+func newUserResult(u db.User) userResult {
+	return userResult{
+		ID:          u.ID,
+		Username:    u.Username,
+		Admin:       u.IsAdmin,
+		HasPassword: u.HasPassword,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+// readPassword returns the password from --password, or the first line of
+// stdin with --password-stdin, which keeps it out of shell history.
+func readPassword(cmd *cobra.Command) (string, error) {
+	password, err := cmd.Flags().GetString("password")
+	if err != nil {
+		return "", fmt.Errorf("failed to read --password: %w", err)
+	}
+	fromStdin, err := cmd.Flags().GetBool("password-stdin")
+	if err != nil {
+		return "", fmt.Errorf("failed to read --password-stdin: %w", err)
+	}
+	if !fromStdin {
+		return password, nil
+	}
+	if password != "" {
+		return "", fmt.Errorf("use either --password or --password-stdin")
+	}
+	line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// userDB returns database scoped to the user named by the --user flag, or
+// to db.LocalUserID if the flag is empty.
+func userDB(cmd *cobra.Command, database *db.DB) (*db.DB, error) {
+	username, err := cmd.Flags().GetString("user")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --user: %w", err)
+	}
+	if username == "" {
+		return database.ForUser(db.LocalUserID), nil
+	}
+	u, err := database.GetUserByName(username)
+	if err != nil {
+		return nil, err
+	}
+	return database.ForUser(u.ID), nil
+}
+
+func runUsersAdd(cmd *cobra.Command, username string) (userResult, error) {
+	password, err := readPassword(cmd)
+	if err != nil {
+		return userResult{}, err
+	}
+	admin, err := cmd.Flags().GetBool("admin")
+	if err != nil {
+		return userResult{}, fmt.Errorf("failed to read --admin: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (userResult, error) {
+		u, err := database.CreateUser(username, password, admin)
+		if err != nil {
+			return userResult{}, err
+		}
+		log.Printf("Added user %d (%s)", u.ID, u.Username)
+		return newUserResult(u), nil
+	})
+}
+
+func runUsersList(cmd *cobra.Command) ([]userResult, error) {
+	return withDB(cmd, func(database *db.DB) ([]userResult, error) {
+		users, err := database.ListUsers()
+		if err != nil {
+			return nil, err
+		}
+		res := []userResult{}
+		for _, u := range users {
+			res = append(res, newUserResult(u))
+			if !jsonOutput(cmd) {
+				role := "user"
+				if u.IsAdmin {
+					role = "admin"
+				}
+				login := "password set"
+				if !u.HasPassword {
+					login = "no password"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\t%s\n", u.ID, u.Username, role, login)
+			}
+		}
+		return res, nil
+	})
+}
+
+func runUsersPasswd(cmd *cobra.Command, username string) (userResult, error) {
+	password, err := readPassword(cmd)
+	if err != nil {
+		return userResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (userResult, error) {
+		u, err := database.GetUserByName(username)
+		if err != nil {
+			return userResult{}, err
+		}
+		if err := database.SetUserPassword(u.ID, password); err != nil {
+			return userResult{}, err
+		}
+		if password == "" {
+			log.Printf("Removed the password of %s; they can't log in until one is set", u.Username)
+		} else {
+			log.Printf("Changed the password of %s", u.Username)
+		}
+		u.HasPassword = password != ""
+		return newUserResult(u), nil
+	})
+}
+
+func runUsersAdmin(cmd *cobra.Command, username string) (userResult, error) {
+	revoke, err := cmd.Flags().GetBool("revoke")
+	if err != nil {
+		return userResult{}, fmt.Errorf("failed to read --revoke: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (userResult, error) {
+		u, err := database.GetUserByName(username)
+		if err != nil {
+			return userResult{}, err
+		}
+		if err := database.SetUserAdmin(u.ID, !revoke); err != nil {
+			return userResult{}, err
+		}
+		u.IsAdmin = !revoke
+		if revoke {
+			log.Printf("%s is no longer an admin", u.Username)
+		} else {
+			log.Printf("%s is now an admin", u.Username)
+		}
+		return newUserResult(u), nil
+	})
+}
+
+func runUsersRm(cmd *cobra.Command, username string) (userResult, error) {
+	return withDB(cmd, func(database *db.DB) (userResult, error) {
+		u, err := database.GetUserByName(username)
+		if err != nil {
+			return userResult{}, err
+		}
+		n, err := database.DeleteUser(u.ID)
+		if err != nil {
+			return userResult{}, err
+		}
+		log.Printf("Deleted user %d (%s) and %d bookmark(s)", u.ID, u.Username, n)
+		res := newUserResult(u)
+		res.DeletedBookmarks = n
+		return res, nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(usersCmd)
+	usersCmd.AddCommand(usersAddCmd, usersListCmd, usersPasswdCmd, usersAdminCmd, usersRmCmd)
+
+	for _, c := range []*cobra.Command{usersAddCmd, usersPasswdCmd} {
+		c.Flags().String("password", "", "The user's password")
+		c.Flags().Bool("password-stdin", false, "Read the password from the first line of stdin")
+	}
+	usersAddCmd.Flags().Bool("admin", false, "Make the user an admin")
+	usersAdminCmd.Flags().Bool("revoke", false, "Take admin rights away instead")
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestUsersCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"add": false, "list": false, "passwd": false, "admin": false, "rm": false}
+	for _, c := range usersCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected users subcommand %s", name)
+		}
+	}
+	for _, c := range []*cobra.Command{usersAddCmd, usersPasswdCmd} {
+		for _, flag := range []string{"password", "password-stdin"} {
+			if c.Flags().Lookup(flag) == nil {
+				t.Errorf("Expected users %s flag %s to be defined", c.Name(), flag)
+			}
+		}
+	}
+	if usersAddCmd.Flags().Lookup("admin") == nil {
+		t.Error("Expected users add flag admin to be defined")
+	}
+	if usersAdminCmd.Flags().Lookup("revoke") == nil {
+		t.Error("Expected users admin flag revoke to be defined")
+	}
+	if importCmd.PersistentFlags().Lookup("user") == nil || tokensCreateCmd.Flags().Lookup("user") == nil {
+		t.Error("Expected import and tokens create to have a user flag")
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	newCmd := func() *cobra.Command {
+		c := &cobra.Command{}
+		c.Flags().String("password", "", "")
+		c.Flags().Bool("password-stdin", false, "")
+		return c
+	}
+
+	t.Run("from the flag", func(t *testing.T) {
+		c := newCmd()
+		_ = c.Flags().Set("password", "s3cret")
+		if got, err := readPassword(c); err != nil || got != "s3cret" {
+			t.Errorf("expected s3cret, got %q, %v", got, err)
+		}
+	})
+
+	t.Run("from stdin", func(t *testing.T) {
+		c := newCmd()
+		_ = c.Flags().Set("password-stdin", "true")
+		c.SetIn(strings.NewReader("s3cret\r\nignored\n"))
+		if got, err := readPassword(c); err != nil || got != "s3cret" {
+			t.Errorf("expected s3cret, got %q, %v", got, err)
+		}
+	})
+
+	t.Run("not both", func(t *testing.T) {
+		c := newCmd()
+		_ = c.Flags().Set("password", "a")
+		_ = c.Flags().Set("password-stdin", "true")
+		if _, err := readPassword(c); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The watch-clipboard command is a desktop helper: it watches the system
+// clipboard and, whenever a URL is copied, offers to save it to a bookmarkd
+// server, or saves it straight away with --yes. It talks to the server over
+// its HTTP API with an API token from "bookmarkd tokens create", so it can
+// run on a different machine than the server. The server and token come
+// from --server and --token or, to keep the token out of the process list,
+// BOOKMARKD_SERVER and BOOKMARKD_TOKEN.
+//
+// The clipboard is read with pbpaste on macOS, PowerShell on Windows, and
+// wl-paste, xclip or xsel elsewhere.
+//
+// Example usage:
+//
+//	BOOKMARKD_TOKEN=... bookmarkd watch-clipboard --server https://bookmarks.example.com
+//	bookmarkd watch-clipboard --server http://localhost:8080 --yes --tags inbox
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var watchClipboardCmd = &cobra.Command{
+	Use:   "watch-clipboard",
+	Short: "Offer to bookmark URLs copied to the clipboard",
+	Long: `Watch the system clipboard and offer to save every URL copied to it to a
+bookmarkd server, asking first unless --yes is given. What the clipboard
+holds when the command starts isn't offered. In --output=json mode each
+saved bookmark is printed as a JSON line. Stop it with Ctrl-C.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runWatchClipboard(cmd); err != nil {
+			finishCommand(cmd, "Failed to watch the clipboard", nil, err)
+		}
+	},
+}
+
+// runWatchClipboard watches the clipboard until reading it, or the answer
+// to a prompt, fails.
+func runWatchClipboard(cmd *cobra.Command) error {
+	server, err := cmd.Flags().GetString("server")
+	if err != nil {
+		return fmt.Errorf("failed to read --server: %w", err)
+	}
+	if server == "" {
+		server = os.Getenv("BOOKMARKD_SERVER")
+	}
+	token, err := cmd.Flags().GetString("token")
+	if err != nil {
+		return fmt.Errorf("failed to read --token: %w", err)
+	}
+	if token == "" {
+		token = os.Getenv("BOOKMARKD_TOKEN")
+	}
+	client, err := core.NewClient(server, token)
+	if err != nil {
+		return err
+	}
+	autoSave, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return fmt.Errorf("failed to read --yes: %w", err)
+	}
+	tags, err := cmd.Flags().GetStringSlice("tags")
+	if err != nil {
+		return fmt.Errorf("failed to read --tags: %w", err)
+	}
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return fmt.Errorf("failed to read --interval: %w", err)
+	}
+
+	args, err := core.ClipboardCommand()
+	if err != nil {
+		return err
+	}
+	watcher := &core.ClipboardWatcher{
+		Read:     func(ctx context.Context) (string, error) { return core.ReadClipboard(ctx, args) },
+		Interval: interval,
+	}
+	in := bufio.NewReader(cmd.InOrStdin())
+	log.Printf("Watching the clipboard for URLs to save to %s", server)
+
+	ctx := context.Background()
+	for {
+		pageURL, err := watcher.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if !autoSave {
+			ok, err := confirmSave(cmd.ErrOrStderr(), in, pageURL)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		b, err := client.AddBookmark(ctx, pageURL, tags)
+		if err != nil {
+			// The server may be briefly down; keep watching.
+			log.Printf("Failed to save %s: %v", pageURL, err)
+			continue
+		}
+		if jsonOutput(cmd) {
+			if err := writeJSON(cmd.OutOrStdout(), commandResult{OK: true, Result: b}); err != nil {
+				log.Printf("failed to write JSON output: %v", err)
+			}
+			continue
+		}
+		log.Printf("Saved bookmark %d: %s", b.ID, b.URL)
+	}
+}
+
+// confirmSave asks on out whether to save pageURL and reads the answer from
+// in; anything but "n" or "no" saves it. Without an input to answer on,
+// such as when run in the background, it fails and suggests --yes.
+func confirmSave(out io.Writer, in *bufio.Reader, pageURL string) (bool, error) {
+	_, _ = fmt.Fprintf(out, "Save %s? [Y/n] ", pageURL)
+	answer, err := in.ReadString('\n')
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(out)
+		return false, fmt.Errorf("no answer to save %s (use --yes to save without asking): %w", pageURL, err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "n", "no":
+		return false, nil
+	}
+	return true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(watchClipboardCmd)
+
+	watchClipboardCmd.Flags().String("server", "", "URL of the bookmarkd server to save to (or set BOOKMARKD_SERVER)")
+	watchClipboardCmd.Flags().String("token", "", "API token to save with (or set BOOKMARKD_TOKEN)")
+	watchClipboardCmd.Flags().BoolP("yes", "y", false, "Save every copied URL without asking")
+	watchClipboardCmd.Flags().StringSlice("tags", nil, "Tags to add to every saved bookmark (comma-separated)")
+	watchClipboardCmd.Flags().Duration("interval", core.DefaultClipboardPollInterval, "How often to check the clipboard")
+}
@@ -0,0 +1,30 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirmSave(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("\nn\nYes\n"))
+	for _, want := range []bool{true, false, true} {
+		got, err := confirmSave(io.Discard, in, "https://example.com/")
+		if err != nil || got != want {
+			t.Errorf("confirmSave() = %v, %v; want %v", got, err, want)
+		}
+	}
+	if _, err := confirmSave(io.Discard, in, "https://example.com/"); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("expected a closed input to fail suggesting --yes, got %v", err)
+	}
+
+	for _, name := range []string{"server", "token", "yes", "tags", "interval"} {
+		if watchClipboardCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected watch-clipboard flag %s to be defined", name)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+
+// The webhooks command manages webhooks, which the server POSTs a signed
+// JSON payload to whenever a bookmark or archive event happens. Changes made
+// by other CLI commands don't trigger webhooks; only the server sends them.
+//
+// Example usage:
+//
+//	bookmarkd webhooks add https://example.com/hook --events bookmark_created,archive_result_saved
+//	bookmarkd webhooks list
+//	bookmarkd webhooks test 2
+//	bookmarkd webhooks disable 2
+//	bookmarkd webhooks rm 2
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+	"github.com/spf13/cobra"
+)
+
+// webhooksCmd groups the webhook subcommands.
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Manage webhooks notified of bookmark and archive events",
+}
+
+var webhooksAddCmd = &cobra.Command{
+	Use:   "add URL",
+	Short: "Add a webhook and print its signing secret",
+	Long: `Add a webhook. Each delivery is a POST with a JSON body and an
+X-Bookmarkd-Signature header of "sha256=" and the hex HMAC-SHA256 of the body,
+keyed by the webhook's secret. A secret is generated unless --secret is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksAdd(cmd, args[0])
+		finishCommand(cmd, "Failed to add webhook", res, err)
+	},
+}
+
+var webhooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhooks and their last delivery",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksList(cmd)
+		finishCommand(cmd, "Failed to list webhooks", res, err)
+	},
+}
+
+var webhooksEnableCmd = &cobra.Command{
+	Use:   "enable ID",
+	Short: "Enable a webhook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksSetEnabled(cmd, args[0], true)
+		finishCommand(cmd, "Failed to enable webhook", res, err)
+	},
+}
+
+var webhooksDisableCmd = &cobra.Command{
+	Use:   "disable ID",
+	Short: "Disable a webhook without removing it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksSetEnabled(cmd, args[0], false)
+		finishCommand(cmd, "Failed to disable webhook", res, err)
+	},
+}
+
+var webhooksRmCmd = &cobra.Command{
+	Use:   "rm ID",
+	Short: "Remove a webhook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksRm(cmd, args[0])
+		finishCommand(cmd, "Failed to remove webhook", res, err)
+	},
+}
+
+var webhooksTestCmd = &cobra.Command{
+	Use:   "test ID",
+	Short: "Send a ping event to a webhook and report the response",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res, err := runWebhooksTest(cmd, args[0])
+		finishCommand(cmd, "Failed to test webhook", res, err)
+	},
+}
+
+// webhookResult describes a webhook in command output. Secret is only set
+// when the webhook is added.
+type webhookResult struct {
+	ID             int64    `json:"id"`
+	URL            string   `json:"url"`
+	Events         []string `json:"events"`
+	Enabled        bool     `json:"enabled"`
+	CreatedAt      string   `json:"created_at"`
+	LastDeliveryAt string   `json:"last_delivery_at,omitempty"`
+	LastStatus     int      `json:"last_status,omitempty"`
+	LastError      string   `json:"last_error,omitempty"`
+	Secret         string   `json:"secret,omitempty"`
+}
+
+func newWebhookResult(w db.Webhook) webhookResult {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return webhookResult{
+		ID:             w.ID,
+		URL:            w.URL,
+		Events:         events,
+		Enabled:        w.Enabled,
+		CreatedAt:      w.CreatedAt,
+		LastDeliveryAt: w.LastDeliveryAt,
+		LastStatus:     w.LastStatus,
+		LastError:      w.LastError,
+	}
+}
+
+// webhookEventsText describes a webhook's event filter for text output.
+func webhookEventsText(events []string) string {
+	if len(events) == 0 {
+		return "all events"
+	}
+	return strings.Join(events, ",")
+}
+
+func runWebhooksAdd(cmd *cobra.Command, url string) (webhookResult, error) {
+	flags := cmd.Flags()
+	events, err := flags.GetStringSlice("events")
+	if err != nil {
+		return webhookResult{}, fmt.Errorf("failed to read --events: %w", err)
+	}
+	secret, err := flags.GetString("secret")
+	if err != nil {
+		return webhookResult{}, fmt.Errorf("failed to read --secret: %w", err)
+	}
+	disabled, err := flags.GetBool("disabled")
+	if err != nil {
+		return webhookResult{}, fmt.Errorf("failed to read --disabled: %w", err)
+	}
+	return withDB(cmd, func(database *db.DB) (webhookResult, error) {
+		id, err := database.CreateWebhook(db.Webhook{URL: url, Secret: secret, Events: events, Enabled: !disabled})
+		if err != nil {
+			return webhookResult{}, err
+		}
+		w, err := database.GetWebhook(id)
+		if err != nil {
+			return webhookResult{}, err
+		}
+		res := newWebhookResult(w)
+		res.Secret = w.Secret
+		log.Printf("Added webhook %d for %s (%s)", w.ID, w.URL, webhookEventsText(w.Events))
+		if !jsonOutput(cmd) {
+			fmt.Fprintln(cmd.OutOrStdout(), w.Secret)
+		}
+		return res, nil
+	})
+}
+
+func runWebhooksList(cmd *cobra.Command) ([]webhookResult, error) {
+	return withDB(cmd, func(database *db.DB) ([]webhookResult, error) {
+		hooks, err := database.ListWebhooks()
+		if err != nil {
+			return nil, err
+		}
+		res := []webhookResult{}
+		for _, w := range hooks {
+			res = append(res, newWebhookResult(w))
+			if !jsonOutput(cmd) {
+				state := "enabled"
+				if !w.Enabled {
+					state = "disabled"
+				}
+				last := "never delivered"
+				if w.LastDeliveryAt != "" {
+					last = fmt.Sprintf("%s %d", w.LastDeliveryAt, w.LastStatus)
+					if w.LastError != "" {
+						last += " " + w.LastError
+					}
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\t%s\t%s\n", w.ID, w.URL, webhookEventsText(w.Events), state, last)
+			}
+		}
+		if len(res) == 0 && !jsonOutput(cmd) {
+			log.Println("No webhooks.")
+		}
+		return res, nil
+	})
+}
+
+func runWebhooksSetEnabled(cmd *cobra.Command, arg string, enabled bool) (webhookResult, error) {
+	id, err := parseWebhookID(arg)
+	if err != nil {
+		return webhookResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (webhookResult, error) {
+		if err := database.SetWebhookEnabled(id, enabled); err != nil {
+			return webhookResult{}, err
+		}
+		w, err := database.GetWebhook(id)
+		if err != nil {
+			return webhookResult{}, err
+		}
+		if enabled {
+			log.Printf("Enabled webhook %d (%s)", w.ID, w.URL)
+		} else {
+			log.Printf("Disabled webhook %d (%s)", w.ID, w.URL)
+		}
+		return newWebhookResult(w), nil
+	})
+}
+
+func runWebhooksRm(cmd *cobra.Command, arg string) (webhookResult, error) {
+	id, err := parseWebhookID(arg)
+	if err != nil {
+		return webhookResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (webhookResult, error) {
+		w, err := database.GetWebhook(id)
+		if err != nil {
+			return webhookResult{}, err
+		}
+		if err := database.DeleteWebhook(id); err != nil {
+			return webhookResult{}, err
+		}
+		log.Printf("Removed webhook %d (%s)", w.ID, w.URL)
+		return newWebhookResult(w), nil
+	})
+}
+
+func runWebhooksTest(cmd *cobra.Command, arg string) (webhookResult, error) {
+	id, err := parseWebhookID(arg)
+	if err != nil {
+		return webhookResult{}, err
+	}
+	return withDB(cmd, func(database *db.DB) (webhookResult, error) {
+		w, err := database.GetWebhook(id)
+		if err != nil {
+			return webhookResult{}, err
+		}
+		payload, err := core.NewWebhookPayload(core.WebhookPingEvent, map[string]any{"webhook_id": w.ID})
+		if err != nil {
+			return webhookResult{}, err
+		}
+		dispatcher := core.NewWebhookDispatcher(database, core.WebhookOptions{})
+		status, err := dispatcher.Deliver(context.Background(), w, payload)
+		if err != nil {
+			return webhookResult{}, err
+		}
+		log.Printf("Webhook %d (%s) answered %d", w.ID, w.URL, status)
+		if w, err = database.GetWebhook(id); err != nil {
+			return webhookResult{}, err
+		}
+		return newWebhookResult(w), nil
+	})
+}
+
+func parseWebhookID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid webhook ID %q", s)
+	}
+	return id, nil
+}
+
+func init() {
+	rootCmd.AddCommand(webhooksCmd)
+	webhooksCmd.AddCommand(webhooksAddCmd, webhooksListCmd, webhooksEnableCmd, webhooksDisableCmd, webhooksRmCmd, webhooksTestCmd)
+
+	webhooksAddCmd.Flags().StringSlice("events", nil, "Comma-separated events to send, e.g. bookmark_created,archive_result_saved (default all)")
+	webhooksAddCmd.Flags().String("secret", "", "Signing secret (default: generated)")
+	webhooksAddCmd.Flags().Bool("disabled", false, "Add the webhook disabled")
+	var events []string
+	for _, k := range db.EventKinds {
+		events = append(events, k.String())
+	}
+	if err := webhooksAddCmd.RegisterFlagCompletionFunc("events", completeFixed(events...)); err != nil {
+		log.Fatalf("Failed to register completion for --events: %v", err)
+	}
+}
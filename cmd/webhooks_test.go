@@ -0,0 +1,34 @@
+/*
+Copyright © 2025 Katie Mulliken <katie@mulliken.net>
+*/
+package cmd
+
+import "testing"
+
+func TestWebhooksCmd_Subcommands(t *testing.T) {
+	want := map[string]bool{"add": false, "list": false, "enable": false, "disable": false, "rm": false, "test": false}
+	for _, c := range webhooksCmd.Commands() {
+		if _, ok := want[c.Name()]; ok {
+			want[c.Name()] = true
+		}
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected webhooks subcommand %s", name)
+		}
+	}
+	for _, flag := range []string{"events", "secret", "disabled"} {
+		if webhooksAddCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("Expected webhooks add flag %s to be defined", flag)
+		}
+	}
+}
+
+func TestWebhookEventsText(t *testing.T) {
+	if got := webhookEventsText(nil); got != "all events" {
+		t.Errorf("expected all events, got %q", got)
+	}
+	if got := webhookEventsText([]string{"bookmark_created", "import_finished"}); got != "bookmark_created,import_finished" {
+		t.Errorf("unexpected events text %q", got)
+	}
+}
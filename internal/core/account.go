@@ -0,0 +1,408 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// UserDataExport is a complete copy of what bookmarkd stores for a user, for
+// data portability requests. Binary fields are base64-encoded in JSON.
+type UserDataExport struct {
+	UserID      int64                 `json:"user_id"`
+	ExportedAt  string                `json:"exported_at"`
+	Preferences ExportedPreferences   `json:"archive_preferences"`
+	Bookmarks   []ExportedBookmark    `json:"bookmarks"`
+	Routing     []ExportedRoutingRule `json:"routing_rules"`
+	Cleanup     []ExportedCleanupRule `json:"cleanup_rules"`
+	Presets     []ExportedPreset      `json:"presets"`
+	// Shared are the collections the user has made public.
+	Shared []ExportedSharedCollection `json:"shared_collections"`
+	// Notifications are the user's notification preferences.
+	Notifications []ExportedNotificationPreference `json:"notification_preferences"`
+	// Pinned are the collections pinned to the user's landing page.
+	Pinned []string `json:"pinned_collections"`
+	// Landing is the user's landing page layout.
+	Landing *ExportedLandingLayout `json:"landing_layout,omitempty"`
+	// Query is the search that picked the bookmarks of a partial export.
+	Query string `json:"query,omitempty"`
+}
+
+// ExportedPreferences are a user's stored archive defaults; nil fields
+// inherit the instance defaults.
+type ExportedPreferences struct {
+	AutoArchive    *bool  `json:"auto_archive"`
+	StripScripts   *bool  `json:"strip_scripts"`
+	MobileViewport *bool  `json:"mobile_viewport"`
+	Screenshot     *bool  `json:"screenshot"`
+	UpdatedAt      string `json:"updated_at,omitempty"`
+}
+
+// ExportedBookmark is a bookmark with everything attached to it.
+type ExportedBookmark struct {
+	ID         int64             `json:"id"`
+	URL        string            `json:"url"`
+	Title      string            `json:"title"`
+	CreatedAt  string            `json:"created_at"`
+	Slug       string            `json:"slug,omitempty"`
+	Collection string            `json:"collection,omitempty"`
+	Notes      string            `json:"notes,omitempty"`
+	IsRead     bool              `json:"is_read"`
+	IsFavorite bool              `json:"is_favorite"`
+	IsPinned   bool              `json:"is_pinned,omitempty"`
+	Tags       []string          `json:"tags"`
+	Metadata   *ExportedMetadata `json:"metadata,omitempty"`
+	Favicon    *ExportedFavicon  `json:"favicon,omitempty"`
+	Archives   []ExportedArchive `json:"archives"`
+}
+
+// ExportedMetadata is the page metadata fetched for a bookmark.
+type ExportedMetadata struct {
+	Description string `json:"description,omitempty"`
+	FaviconURL  string `json:"favicon_url,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	FetchedAt   string `json:"fetched_at"`
+}
+
+// ExportedFavicon is a bookmark's downloaded favicon.
+type ExportedFavicon struct {
+	SourceURL   string `json:"source_url"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// ExportedArchive is one archive version of a bookmark.
+type ExportedArchive struct {
+	ID          int64              `json:"id"`
+	CapturedAt  string             `json:"captured_at"`
+	ArchivedURL string             `json:"archived_url"`
+	HTML        string             `json:"html"`
+	Screenshot  []byte             `json:"screenshot,omitempty"`
+	Download    *ExportedDownload  `json:"download,omitempty"`
+	Provenance  json.RawMessage    `json:"provenance,omitempty"`
+	Timestamp   *ExportedTimestamp `json:"timestamp,omitempty"`
+}
+
+// ExportedDownload is the file an archive version downloaded instead of a
+// page.
+type ExportedDownload struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// ExportedTimestamp is an RFC 3161 timestamp of an archive version.
+type ExportedTimestamp struct {
+	ContentHash   string `json:"content_hash"`
+	Authority     string `json:"authority"`
+	TimestampedAt string `json:"timestamped_at"`
+	Token         []byte `json:"token"`
+}
+
+// ExportedRoutingRule is a routing rule the user set up.
+type ExportedRoutingRule struct {
+	Name          string `json:"name"`
+	Domain        string `json:"domain,omitempty"`
+	TitleContains string `json:"title_contains,omitempty"`
+	AddTag        string `json:"add_tag,omitempty"`
+	Collection    string `json:"collection,omitempty"`
+	SkipArchive   bool   `json:"skip_archive"`
+	Enabled       bool   `json:"enabled"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// ExportedCleanupRule is an expiration rule the user set up.
+type ExportedCleanupRule struct {
+	Name          string `json:"name"`
+	Tag           string `json:"tag,omitempty"`
+	UnreadOnly    bool   `json:"unread_only"`
+	OlderThanDays int    `json:"older_than_days"`
+	Action        string `json:"action"`
+	ActionTag     string `json:"action_tag,omitempty"`
+	Enabled       bool   `json:"enabled"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// ExportedPreset is a bookmark preset the user saved.
+type ExportedPreset struct {
+	Name           string   `json:"name"`
+	Tags           []string `json:"tags"`
+	Collection     string   `json:"collection,omitempty"`
+	SkipArchive    bool     `json:"skip_archive"`
+	StripScripts   *bool    `json:"strip_scripts,omitempty"`
+	MobileViewport *bool    `json:"mobile_viewport,omitempty"`
+	Screenshot     *bool    `json:"screenshot,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+// ExportedSharedCollection is a collection the user has made public, with
+// the token in its public URLs.
+type ExportedSharedCollection struct {
+	Collection string `json:"collection"`
+	Token      string `json:"token"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ExportedNotificationPreference is whether the user is notified of an
+// event over a channel.
+type ExportedNotificationPreference struct {
+	Event     string `json:"event"`
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ExportedLandingLayout is the widgets the user shows on their landing page,
+// in order.
+type ExportedLandingLayout struct {
+	Widgets   []string `json:"widgets"`
+	ListSize  int      `json:"list_size"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+}
+
+// ExportUserData gathers everything stored for a user, including the HTML
+// and screenshots of every archive version, so it can be handed over as a
+// single document. Routing and cleanup rules are instance-wide, so they are
+// only included for admins. A non-empty query, in the search syntax, limits
+// the export to the bookmarks it finds; such partial exports are meant for
+// sharing, so they leave the presets and rules out.
+func ExportUserData(database *db.DB, userID int64, query string) (UserDataExport, error) {
+	query = strings.TrimSpace(query)
+	bookmarks, err := database.ListUserBookmarksMatching(userID, query)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	prefs, err := database.GetArchivePreferences(userID)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	out := UserDataExport{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Query:      query,
+		Preferences: ExportedPreferences{
+			AutoArchive:    prefs.AutoArchive,
+			StripScripts:   prefs.StripScripts,
+			MobileViewport: prefs.MobileViewport,
+			Screenshot:     prefs.Screenshot,
+			UpdatedAt:      prefs.UpdatedAt,
+		},
+		Bookmarks:     []ExportedBookmark{},
+		Routing:       []ExportedRoutingRule{},
+		Cleanup:       []ExportedCleanupRule{},
+		Presets:       []ExportedPreset{},
+		Shared:        []ExportedSharedCollection{},
+		Notifications: []ExportedNotificationPreference{},
+		Pinned:        []string{},
+	}
+
+	for _, b := range bookmarks {
+		eb, err := exportBookmark(database, b)
+		if err != nil {
+			return UserDataExport{}, err
+		}
+		out.Bookmarks = append(out.Bookmarks, eb)
+	}
+
+	if query != "" {
+		return out, nil
+	}
+
+	shared, err := database.ForUser(userID).ListSharedCollections()
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, c := range shared {
+		out.Shared = append(out.Shared, ExportedSharedCollection{Collection: c.Collection, Token: c.Token, CreatedAt: c.CreatedAt})
+	}
+
+	pinned, err := database.ForUser(userID).ListPinnedCollections()
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, c := range pinned {
+		out.Pinned = append(out.Pinned, c.Collection)
+	}
+	landing, err := database.GetLandingLayout(userID)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	if landing.UpdatedAt != "" {
+		out.Landing = &ExportedLandingLayout{Widgets: landing.Widgets, ListSize: landing.ListSize, UpdatedAt: landing.UpdatedAt}
+	}
+
+	notifications, err := database.ListNotificationPreferences(userID)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, p := range notifications {
+		out.Notifications = append(out.Notifications, ExportedNotificationPreference{
+			Event:     p.Event,
+			Channel:   p.Channel,
+			Enabled:   p.Enabled,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+
+	presets, err := database.ForUser(userID).ListBookmarkPresets()
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, p := range presets {
+		out.Presets = append(out.Presets, ExportedPreset{
+			Name:           p.Name,
+			Tags:           append([]string{}, p.Tags...),
+			Collection:     p.Collection,
+			SkipArchive:    p.SkipArchive,
+			StripScripts:   p.Archive.StripScripts,
+			MobileViewport: p.Archive.MobileViewport,
+			Screenshot:     p.Archive.Screenshot,
+			CreatedAt:      p.CreatedAt,
+		})
+	}
+
+	user, err := database.GetUser(userID)
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	if !user.IsAdmin {
+		return out, nil
+	}
+
+	routing, err := database.ListRoutingRules()
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, r := range routing {
+		out.Routing = append(out.Routing, ExportedRoutingRule{
+			Name:          r.Name,
+			Domain:        r.Domain,
+			TitleContains: r.TitleContains,
+			AddTag:        r.AddTag,
+			Collection:    r.Collection,
+			SkipArchive:   r.SkipArchive,
+			Enabled:       r.Enabled,
+			CreatedAt:     r.CreatedAt,
+		})
+	}
+
+	cleanup, err := database.ListCleanupRules()
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for _, r := range cleanup {
+		out.Cleanup = append(out.Cleanup, ExportedCleanupRule{
+			Name:          r.Name,
+			Tag:           r.Tag,
+			UnreadOnly:    r.UnreadOnly,
+			OlderThanDays: r.OlderThanDays,
+			Action:        r.Action,
+			ActionTag:     r.ActionTag,
+			Enabled:       r.Enabled,
+			CreatedAt:     r.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func exportBookmark(database *db.DB, b db.Bookmark) (ExportedBookmark, error) {
+	eb := ExportedBookmark{
+		ID:        b.ID,
+		URL:       b.URL,
+		Title:     b.Title,
+		CreatedAt: b.CreatedAt,
+		Slug:      b.Slug,
+		Archives:  []ExportedArchive{},
+	}
+	var err error
+	if eb.Collection, err = database.GetBookmarkCollection(b.ID); err != nil {
+		return ExportedBookmark{}, err
+	}
+	if eb.Notes, err = database.GetBookmarkNotes(b.ID); err != nil {
+		return ExportedBookmark{}, err
+	}
+	flags, err := database.GetBookmarkFlags(b.ID)
+	if err != nil {
+		return ExportedBookmark{}, err
+	}
+	eb.IsRead, eb.IsFavorite, eb.IsPinned = flags.IsRead, flags.IsFavorite, flags.IsPinned
+	if eb.Tags, err = database.ListBookmarkTags(b.ID); err != nil {
+		return ExportedBookmark{}, err
+	}
+	if eb.Tags == nil {
+		eb.Tags = []string{}
+	}
+
+	meta, err := database.GetBookmarkMetadata(b.ID)
+	if err != nil {
+		return ExportedBookmark{}, err
+	}
+	if meta.FetchedAt != "" {
+		eb.Metadata = &ExportedMetadata{
+			Description: meta.Description,
+			FaviconURL:  meta.FaviconURL,
+			ImageURL:    meta.ImageURL,
+			SiteName:    meta.SiteName,
+			FetchedAt:   meta.FetchedAt,
+		}
+	}
+	hasFavicon, err := database.HasBookmarkFavicon(b.ID)
+	if err != nil {
+		return ExportedBookmark{}, err
+	}
+	if hasFavicon {
+		f, err := database.GetBookmarkFavicon(b.ID)
+		if err != nil {
+			return ExportedBookmark{}, err
+		}
+		eb.Favicon = &ExportedFavicon{SourceURL: f.SourceURL, ContentType: f.ContentType, Data: f.Data}
+	}
+
+	versions, err := database.ListArchiveVersions(b.ID)
+	if err != nil {
+		return ExportedBookmark{}, err
+	}
+	for _, listed := range versions {
+		v, err := database.GetArchiveVersion(b.ID, listed.ID)
+		if err != nil {
+			return ExportedBookmark{}, err
+		}
+		ea := ExportedArchive{ID: v.ID, CapturedAt: v.CapturedAt, ArchivedURL: v.ArchivedURL, HTML: v.ArchivedHTML}
+		if v.HasScreenshot {
+			if ea.Screenshot, err = database.GetArchiveScreenshot(b.ID, v.ID); err != nil {
+				return ExportedBookmark{}, err
+			}
+		}
+		if v.HasDownload {
+			d, err := database.GetArchiveDownload(b.ID, v.ID)
+			if err != nil {
+				return ExportedBookmark{}, err
+			}
+			ea.Download = &ExportedDownload{Filename: d.Filename, ContentType: d.MIMEType, Data: d.Data}
+		}
+		if v.HasProvenance {
+			p, err := database.GetArchiveProvenance(b.ID, v.ID)
+			if err != nil {
+				return ExportedBookmark{}, err
+			}
+			ea.Provenance = json.RawMessage(p)
+		}
+		if v.HasTimestamp {
+			ts, err := database.GetArchiveTimestamp(b.ID, v.ID)
+			if err != nil {
+				return ExportedBookmark{}, err
+			}
+			ea.Timestamp = &ExportedTimestamp{
+				ContentHash:   ts.ContentHash,
+				Authority:     ts.Authority,
+				TimestampedAt: ts.TimestampedAt,
+				Token:         ts.Token,
+			}
+		}
+		eb.Archives = append(eb.Archives, ea)
+	}
+	return eb, nil
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestExportUserData(t *testing.T) {
+	database := newQueueTestDB(t)
+
+	if _, err := ExportUserData(database, 42, ""); err == nil {
+		t.Error("expected error exporting an unknown user")
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		export, err := ExportUserData(database, db.LocalUserID, "")
+		if err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		body, _ := json.Marshal(export)
+		var got map[string]any
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("failed to decode export: %v", err)
+		}
+		if got["bookmarks"] == nil || got["routing_rules"] == nil || got["cleanup_rules"] == nil {
+			t.Errorf("expected empty lists rather than null, got %s", body)
+		}
+	})
+
+	t.Run("everything attached to a bookmark", func(t *testing.T) {
+		id, err := database.CreateBookmark(db.NewBookmark{URL: "https://example.com", Title: "Example", Notes: "*hi*", Tags: []string{"go"}})
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := database.SaveArchiveResult(id, now, &now, ArchiveStatusOK, "", "https://example.com", "<html>v1</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := database.SaveArchiveProvenance(id, `{"egress":"direct"}`); err != nil {
+			t.Fatalf("failed to save provenance: %v", err)
+		}
+		if err := database.SaveArchiveScreenshot(id, []byte("png")); err != nil {
+			t.Fatalf("failed to save screenshot: %v", err)
+		}
+		if err := database.SaveBookmarkFavicon(db.BookmarkFavicon{BookmarkID: id, SourceURL: "https://example.com/favicon.ico", ContentType: "image/x-icon", Data: []byte{0, 0, 1, 0}}); err != nil {
+			t.Fatalf("failed to save favicon: %v", err)
+		}
+		if _, err := database.CreateRoutingRule(db.RoutingRule{Name: "gh", Domain: "github.com", AddTag: "code", Enabled: true}); err != nil {
+			t.Fatalf("failed to create routing rule: %v", err)
+		}
+		if _, err := database.CreateBookmarkPreset(db.BookmarkPreset{Name: "Reading", Tags: []string{"later"}}); err != nil {
+			t.Fatalf("failed to create preset: %v", err)
+		}
+		if err := database.SetBookmarkPinned(id, true); err != nil {
+			t.Fatalf("failed to pin bookmark: %v", err)
+		}
+		if err := database.PinCollection("Reading"); err != nil {
+			t.Fatalf("failed to pin collection: %v", err)
+		}
+
+		export, err := ExportUserData(database, db.LocalUserID, "")
+		if err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		if export.UserID != db.LocalUserID || len(export.Bookmarks) != 1 || len(export.Routing) != 1 {
+			t.Fatalf("unexpected export: %+v", export)
+		}
+		if len(export.Presets) != 1 || export.Presets[0].Name != "Reading" || export.Presets[0].Tags[0] != "later" {
+			t.Errorf("unexpected presets: %+v", export.Presets)
+		}
+		if len(export.Pinned) != 1 || export.Pinned[0] != "Reading" {
+			t.Errorf("unexpected pinned collections: %+v", export.Pinned)
+		}
+		b := export.Bookmarks[0]
+		if b.URL != "https://example.com" || b.Notes != "*hi*" || len(b.Tags) != 1 || b.Tags[0] != "go" || !b.IsPinned {
+			t.Errorf("unexpected bookmark: %+v", b)
+		}
+		if b.Favicon == nil || b.Favicon.ContentType != "image/x-icon" {
+			t.Errorf("expected favicon to be exported, got %+v", b.Favicon)
+		}
+		if len(b.Archives) != 1 {
+			t.Fatalf("expected 1 archive version, got %d", len(b.Archives))
+		}
+		a := b.Archives[0]
+		if a.HTML != "<html>v1</html>" || string(a.Screenshot) != "png" || string(a.Provenance) != `{"egress":"direct"}` {
+			t.Errorf("unexpected archive: %+v", a)
+		}
+	})
+
+	t.Run("query picks bookmarks and drops rules", func(t *testing.T) {
+		if _, err := database.CreateBookmark(db.NewBookmark{URL: "https://other.test", Title: "Other", Tags: []string{"share"}}); err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		export, err := ExportUserData(database, db.LocalUserID, " tag:share ")
+		if err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		if export.Query != "tag:share" || len(export.Bookmarks) != 1 || export.Bookmarks[0].URL != "https://other.test" {
+			t.Errorf("expected only the tagged bookmark, got %+v", export.Bookmarks)
+		}
+		if len(export.Routing) != 0 || len(export.Presets) != 0 {
+			t.Errorf("expected no rules or presets in a partial export, got %+v %+v", export.Routing, export.Presets)
+		}
+		if _, err := ExportUserData(database, db.LocalUserID, "domain:"); !errors.Is(err, db.ErrInvalidSearch) {
+			t.Errorf("expected ErrInvalidSearch, got %v", err)
+		}
+	})
+}
@@ -2,25 +2,42 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/textproto"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
 	"github.com/seckatie/bookmarkd/internal/core/db"
 )
 
 // ArchiveOptions controls how a bookmark page is fetched and captured.
 //
-// This uses a real Chrome/Chromium browser (via the DevTools protocol) so that
-// JS-heavy pages have a chance to fully render before we snapshot the final HTML.
+// By default this uses a real Chrome/Chromium browser (via the DevTools protocol)
+// so that JS-heavy pages have a chance to fully render before we snapshot the
+// final HTML.
 type ArchiveOptions struct {
+	// Engine is ArchiveEngineChrome or ArchiveEngineHTTP. If empty, Chrome
+	// is used when it is installed and the plain HTTP engine otherwise.
+	Engine string
 	// ChromePath optionally overrides the Chrome/Chromium executable path.
 	// If empty, chromedp will try to find a browser on PATH / default locations.
 	ChromePath string
+	// ChromeProfileDir, if set, keeps a Chrome profile per site in this
+	// directory, so archiving a site again reuses its HTTP cache and
+	// cookies instead of starting from a blank profile. A profile another
+	// archive is using falls back to a blank one. Only one bookmarkd
+	// process should use the directory at a time.
+	ChromeProfileDir string
 	// Headless controls whether Chrome runs without a visible window.
 	// Set to false to debug scraping in a real window ("headful").
 	Headless bool
@@ -30,6 +47,103 @@ type ArchiveOptions struct {
 	// WaitSelector optionally waits for a CSS selector to become visible before
 	// capturing the page. This is useful for SPAs or sites that render late.
 	WaitSelector string
+	// WaitStrategy is what Chrome waits for after navigating, before the
+	// capture: WaitNetworkIdle (the default, when empty), WaitDOMContentLoaded,
+	// WaitLoad or WaitFixedDelay. Pages that never go network idle run
+	// until Timeout with the default.
+	WaitStrategy string
+	// ExtraDelay is an extra pause once WaitStrategy is met, for scripts
+	// that render late; with WaitFixedDelay it is the whole wait.
+	ExtraDelay time.Duration
+	// ScrollToBottom scrolls the page a viewport at a time down to the
+	// bottom (at most MaxScrollSteps viewports) and back before capture, so
+	// images and content that load lazily as they come into view are there.
+	ScrollToBottom bool
+	// ScrollDelay is the pause after each scroll step; if <= 0,
+	// DefaultScrollDelay is used.
+	ScrollDelay time.Duration
+	// MobileViewport renders the page with a phone's viewport and user agent.
+	MobileViewport bool
+	// Screenshot captures a full-page screenshot alongside the HTML.
+	Screenshot bool
+	// StripScripts removes scripts and inline event handlers from the
+	// archived HTML.
+	StripScripts bool
+	// Domains limits which pages may be archived. The bookmark URL is
+	// checked before Chrome starts, and every navigation of the page,
+	// including redirects, while it loads.
+	Domains DomainRules
+	// ResourceDomains limits which hosts a page may load resources from,
+	// both in Chrome and when inlining them afterwards.
+	ResourceDomains DomainRules
+	// Blocklist, if set, strips the trackers and ads it matches from the
+	// captured HTML before inlining, and keeps the inliner from fetching
+	// them.
+	Blocklist *Blocklist
+	// Embeds is what becomes of the page's iframes, video, audio and
+	// plugin embeds; see InlineOptions.Embeds.
+	Embeds string
+	// RespectRobots skips pages whose robots.txt disallows bookmarkd, or
+	// that carry a noarchive robots meta tag or X-Robots-Tag header, with
+	// ErrArchiveDisallowed.
+	RespectRobots bool
+	// UserAgent, if set, replaces the user agent Chrome and the inliner
+	// send, e.g. for sites that block headless browsers. It also overrides
+	// the phone's user agent of MobileViewport.
+	UserAgent string
+	// ExtraHeaders are sent with every request Chrome and the inliner make.
+	ExtraHeaders Headers
+	// MaxTotalSize bounds the archived HTML with its resources inlined
+	// (bytes); see InlineOptions.MaxTotalSize. 0 means no limit.
+	MaxTotalSize int64
+	// ResourceCacheTTL is how long ArchiveAndPersist reuses resources the
+	// inliner fetched for earlier archives, from the database's resource
+	// cache; 0 disables the cache.
+	ResourceCacheTTL time.Duration
+	// Browsers, if set, archives pages in tabs of a shared browser rather
+	// than launching Chrome for each one. Its ChromePath and Headless win
+	// over these options'. Pages archived with their site's profile (see
+	// ChromeProfileDir) still get a browser of their own.
+	Browsers *BrowserPool
+	// Archiver captures pages; nil means EngineArchiver, which uses the
+	// engine Engine selects. Tests set a FakeArchiver to run without
+	// Chrome.
+	Archiver Archiver
+	// Wayback, if set, looks up a Wayback Machine snapshot of pages that
+	// fail to archive, for the viewer to link to instead; nil never asks
+	// the Internet Archive.
+	Wayback *WaybackClient
+}
+
+// Headers are extra HTTP request headers, by name. Formatting them shows
+// only the names, so logged options don't leak credentials sent in them.
+type Headers map[string]string
+
+func (h Headers) String() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// ParseHeaders parses "Name: value" lines, as given on the command line,
+// into Headers.
+func ParseHeaders(lines []string) (Headers, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	h := Headers{}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header %q: expected \"Name: value\"", line)
+		}
+		h[textproto.CanonicalMIMEHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return h, nil
 }
 
 // ArchiveResult is the captured output of archiving a single bookmark page.
@@ -40,6 +154,49 @@ type ArchiveResult struct {
 	Title string
 	// HTML is the final rendered document HTML (outerHTML of <html>).
 	HTML string
+	// Screenshot is a full-page JPEG, only set when ArchiveOptions.Screenshot is.
+	Screenshot []byte
+	// UserAgent is the user agent the page saw; Browser is the browser's
+	// product string, e.g. "HeadlessChrome/120.0.6099.109".
+	UserAgent string
+	Browser   string
+	// Download is set instead of HTML and Screenshot when the URL started a
+	// file download rather than opening a page.
+	Download *ArchiveDownload
+	// Engine is the archive engine that captured the page.
+	Engine string
+}
+
+// chromeTab returns a tab to archive url in: one in opts.Browsers' shared
+// browser, or a browser launched for this page alone when there is no pool
+// or the page gets its site's profile. release closes it.
+func chromeTab(ctx context.Context, url string, opts ArchiveOptions) (tab context.Context, release func(), err error) {
+	allocatorOpts := chromeAllocatorOptions(opts.ChromePath, opts.Headless)
+	var releaseProfile func()
+	if opts.ChromeProfileDir != "" {
+		if dir, err := chromeProfileDir(opts.ChromeProfileDir, url); err != nil {
+			log.Printf("Warning: not using a Chrome profile for %s: %v", url, err)
+		} else if release, ok := acquireChromeProfile(dir); !ok {
+			log.Printf("Chrome profile %s is in use; archiving %s with a blank one", dir, url)
+		} else {
+			releaseProfile = release
+			allocatorOpts = append(allocatorOpts, chromedp.UserDataDir(dir))
+		}
+	}
+	if releaseProfile == nil && opts.Browsers != nil {
+		return opts.Browsers.tab(ctx)
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocatorOpts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	return browserCtx, func() {
+		cancelBrowser()
+		cancelAlloc()
+		// The profile is released after Chrome has exited.
+		if releaseProfile != nil {
+			releaseProfile()
+		}
+	}, nil
 }
 
 // ArchiveRunOptions describes a higher-level archive run: either archive a single
@@ -59,65 +216,107 @@ type ArchiveRunResult struct {
 	Attempted int
 	Succeeded int
 	Failed    int
+	// Skipped counts bookmarks whose site asked not to be archived.
+	Skipped int
 }
 
-// ArchiveBookmark loads a URL in Chrome and returns the final rendered HTML.
+// ArchiveBookmark checks a URL against opts.Domains and, with
+// opts.RespectRobots, its site's robots.txt, then captures it with
+// opts.Archiver (EngineArchiver by default).
+func ArchiveBookmark(ctx context.Context, url string, opts ArchiveOptions) (ArchiveResult, error) {
+	log.Printf("Archiving bookmark %s", url)
+	log.Printf("Opts: %+v", opts)
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultArchiveTimeout
+	}
+	if !opts.Domains.Allows(url) {
+		return ArchiveResult{}, fmt.Errorf("%w: %s", ErrDomainBlocked, url)
+	}
+	if opts.RespectRobots {
+		if err := checkRobotsTxt(ctx, newFetchClient(DefaultResourceTimeout), url); err != nil {
+			return ArchiveResult{}, err
+		}
+	}
+	archiver := opts.Archiver
+	if archiver == nil {
+		archiver = EngineArchiver{}
+	}
+	return archiver.Archive(ctx, url, opts)
+}
+
+// archiveChrome loads a URL in Chrome and returns the final rendered HTML.
 //
 // The function:
 // - navigates to the provided URL
+// - waits as opts.WaitStrategy says, then opts.ExtraDelay
 // - waits for <body> to be ready (and optionally opts.WaitSelector to be visible)
+// - optionally scrolls to the bottom and back, for lazy-loaded content
 // - captures final URL, document.title, and <html> outerHTML
 //
+// If the URL downloads a file instead (e.g. a direct link to a .zip), the
+// file is returned in ArchiveResult.Download, up to MaxDownloadSize.
+//
 // Notes:
 //   - This does not attempt to bypass paywalls/CAPTCHAs/login walls; failures are
 //     returned as errors.
 //   - For pages that set a blank title, we fall back to parsing <title> from HTML.
-func ArchiveBookmark(ctx context.Context, url string, opts ArchiveOptions) (ArchiveResult, error) {
-	log.Printf("Archiving bookmark %s", url)
-	log.Printf("Opts: %+v", opts)
-	if opts.Timeout <= 0 {
-		opts.Timeout = DefaultArchiveTimeout
+func archiveChrome(ctx context.Context, url string, opts ArchiveOptions) (ArchiveResult, error) {
+	browserCtx, release, err := chromeTab(ctx, url, opts)
+	if err != nil {
+		return ArchiveResult{}, err
 	}
+	defer release()
 
-	allocatorOpts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
-	allocatorOpts = append(allocatorOpts,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.NoFirstRun,
-	)
-	if opts.ChromePath != "" {
-		allocatorOpts = append(allocatorOpts, chromedp.ExecPath(opts.ChromePath))
-	}
-	if opts.Headless {
-		allocatorOpts = append(allocatorOpts, chromedp.Headless)
-	} else {
-		allocatorOpts = append(allocatorOpts, chromedp.Flag("headless", false))
-	}
+	runCtx, cancelRun := context.WithTimeout(browserCtx, opts.Timeout)
+	defer cancelRun()
 
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocatorOpts...)
-	defer cancelAlloc()
+	downloads, err := newDownloadWatcher(runCtx)
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+	defer downloads.close()
+	downloaded := false
 
-	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
-	defer cancelBrowser()
+	var filter *domainFilter
+	if !opts.Domains.IsZero() || !opts.ResourceDomains.IsZero() {
+		filter = newDomainFilter(runCtx, opts.Domains, opts.ResourceDomains)
+	}
 
-	runCtx, cancelRun := context.WithTimeout(browserCtx, opts.Timeout)
-	defer cancelRun()
+	var robotsHeaders *robotsHeaderWatcher
+	if opts.RespectRobots {
+		robotsHeaders = newRobotsHeaderWatcher(runCtx)
+	}
 
 	var html string
 	var title string
 	var finalURL string
+	var screenshot []byte
+	var userAgent string
+	var browserProduct string
 
-	// Wait for network idle to ensure all resources are loaded
-	waitForNetworkIdle := func(ctx context.Context) error {
+	// Navigate, then wait as opts.WaitStrategy says so that resources have
+	// loaded.
+	strategy := waitStrategy(opts.WaitStrategy)
+	navigateAndWait := func(ctx context.Context) error {
 		// Enable lifecycle events
 		if err := page.SetLifecycleEventsEnabled(true).Do(ctx); err != nil {
 			return err
 		}
 
-		// Create a channel to receive lifecycle events
-		ch := make(chan struct{})
+		// Create a channel to receive the event waited for. It's
+		// buffered, since it may fire before Navigate returns; "init"
+		// starts a new document, so one seen before that is dropped.
+		event := lifecycleEvent(strategy)
+		ch := make(chan struct{}, 1)
 		chromedp.ListenTarget(ctx, func(ev interface{}) {
 			if e, ok := ev.(*page.EventLifecycleEvent); ok {
-				if e.Name == "networkIdle" {
+				switch e.Name {
+				case "init":
+					select {
+					case <-ch:
+					default:
+					}
+				case event:
 					select {
 					case ch <- struct{}{}:
 					default:
@@ -126,41 +325,127 @@ func ArchiveBookmark(ctx context.Context, url string, opts ArchiveOptions) (Arch
 			}
 		})
 
-		// Navigate and wait for network idle
+		// Navigate and wait. Navigations that start a download are
+		// aborted.
 		if err := chromedp.Navigate(url).Do(ctx); err != nil {
+			if filter != nil {
+				if blocked := filter.blockedNavigation(); blocked != "" {
+					return fmt.Errorf("%w: %s redirected to %s", ErrDomainBlocked, url, blocked)
+				}
+			}
+			if strings.Contains(err.Error(), "net::ERR_ABORTED") && downloads.started(ctx, downloadStartGrace) {
+				downloaded = true
+				return nil
+			}
 			return err
 		}
 
-		// Wait for networkIdle event or timeout
-		select {
-		case <-ch:
-			log.Printf("Network idle reached for %s", url)
-		case <-ctx.Done():
-			return ctx.Err()
+		delay := opts.ExtraDelay
+		if strategy == WaitFixedDelay {
+			if delay <= 0 {
+				delay = DefaultFixedWaitDelay
+			}
+		} else {
+			// Wait for the lifecycle event or timeout
+			select {
+			case <-ch:
+				log.Printf("Reached %s for %s", strategy, url)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if delay > 0 {
+			return chromedp.Sleep(delay).Do(ctx)
 		}
-
 		return nil
 	}
 
-	actions := []chromedp.Action{
-		chromedp.ActionFunc(waitForNetworkIdle),
-		chromedp.WaitReady("body", chromedp.ByQuery),
+	var actions []chromedp.Action
+	if filter != nil {
+		actions = append(actions, filter.enable())
+	}
+	if opts.MobileViewport {
+		actions = append(actions, chromedp.Emulate(device.IPhone13))
+	}
+	if opts.UserAgent != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(opts.UserAgent))
+	}
+	if rate := DownloadRateLimit(); rate > 0 || opts.RespectRobots || len(opts.ExtraHeaders) > 0 {
+		// Response headers are only reported, and extra request headers
+		// only sent, with the network domain enabled.
+		actions = append(actions, network.Enable())
+		if rate > 0 {
+			// Throttle the tab itself; upload throughput of -1 leaves uploads unthrottled.
+			actions = append(actions, network.EmulateNetworkConditions(false, 0, float64(rate), -1))
+		}
+		if len(opts.ExtraHeaders) > 0 {
+			headers := network.Headers{}
+			for name, value := range opts.ExtraHeaders {
+				headers[name] = value
+			}
+			actions = append(actions, network.SetExtraHTTPHeaders(headers))
+		}
+	}
+	actions = append(actions,
+		downloads.enable(),
+		chromedp.ActionFunc(navigateAndWait),
+	)
+	if err := chromedp.Run(runCtx, actions...); err != nil {
+		return ArchiveResult{}, err
 	}
+	if downloaded {
+		d, err := downloads.wait(runCtx, MaxDownloadSize)
+		if err != nil {
+			return ArchiveResult{}, err
+		}
+		log.Printf("%s downloaded %s (%s)", url, d.Filename, FormatBytes(int64(len(d.Data))))
+		return ArchiveResult{FinalURL: d.URL, Title: d.Filename, Download: &d, Engine: ArchiveEngineChrome}, nil
+	}
+
+	actions = []chromedp.Action{chromedp.WaitReady("body", chromedp.ByQuery)}
 	if strings.TrimSpace(opts.WaitSelector) != "" {
 		actions = append(actions, chromedp.WaitVisible(opts.WaitSelector, chromedp.ByQuery))
 	}
-	// Small delay to allow any final JS execution after network idle
+	if opts.ScrollToBottom {
+		actions = append(actions, scrollToBottom(opts.ScrollDelay))
+	}
+	// Small delay to allow any final JS execution after the wait
 	actions = append(actions,
 		chromedp.Sleep(DefaultNetworkIdleDelay),
 		chromedp.Location(&finalURL),
 		chromedp.Title(&title),
 		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+		chromedp.Evaluate(`navigator.userAgent`, &userAgent),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			// Only used for the provenance record, so don't fail the capture.
+			_, product, _, _, _, err := browser.GetVersion().Do(ctx)
+			if err != nil {
+				log.Printf("Warning: failed to get browser version: %v", err)
+			}
+			browserProduct = product
+			return nil
+		}),
 	)
+	if opts.Screenshot {
+		actions = append(actions, chromedp.FullScreenshot(&screenshot, DefaultScreenshotQuality))
+	}
 
 	if err := chromedp.Run(runCtx, actions...); err != nil {
 		return ArchiveResult{}, err
 	}
 
+	if opts.RespectRobots {
+		if source := robotsNoArchive(html, robotsHeaders.values()); source != "" {
+			return ArchiveResult{}, fmt.Errorf("%w: %s has a noarchive %s", ErrArchiveDisallowed, finalURL, source)
+		}
+		// A redirect to another site is subject to that site's robots.txt.
+		if !sameOrigin(url, finalURL) {
+			if err := checkRobotsTxt(ctx, newFetchClient(DefaultResourceTimeout), finalURL); err != nil {
+				return ArchiveResult{}, err
+			}
+		}
+	}
+
 	// Some pages leave document.title blank; fall back to parsing HTML if needed.
 	if strings.TrimSpace(title) == "" && strings.TrimSpace(html) != "" {
 		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
@@ -169,50 +454,215 @@ func ArchiveBookmark(ctx context.Context, url string, opts ArchiveOptions) (Arch
 	}
 
 	return ArchiveResult{
-		FinalURL: finalURL,
-		Title:    title,
-		HTML:     html,
+		FinalURL:   finalURL,
+		Title:      title,
+		HTML:       html,
+		Screenshot: screenshot,
+		UserAgent:  userAgent,
+		Browser:    browserProduct,
+		Engine:     ArchiveEngineChrome,
 	}, nil
 }
 
+// ParseWaitStrategy parses a wait strategy as given on the command line,
+// ignoring case: WaitNetworkIdle (or ""), WaitDOMContentLoaded, WaitLoad
+// or WaitFixedDelay.
+func ParseWaitStrategy(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return WaitNetworkIdle, nil
+	}
+	for _, strategy := range []string{WaitNetworkIdle, WaitDOMContentLoaded, WaitLoad, WaitFixedDelay} {
+		if strings.EqualFold(s, strategy) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid wait strategy %q: expected %s, %s, %s or %s", s, WaitNetworkIdle, WaitDOMContentLoaded, WaitLoad, WaitFixedDelay)
+}
+
+// waitStrategy is the strategy ArchiveBookmark uses for s: WaitNetworkIdle
+// if it's empty or unknown.
+func waitStrategy(s string) string {
+	strategy, err := ParseWaitStrategy(s)
+	if err != nil {
+		return WaitNetworkIdle
+	}
+	return strategy
+}
+
+// lifecycleEvent is the Chrome page lifecycle event a wait strategy waits
+// for; WaitFixedDelay waits for none.
+func lifecycleEvent(strategy string) string {
+	switch strategy {
+	case WaitDOMContentLoaded:
+		return "DOMContentLoaded"
+	case WaitLoad:
+		return "load"
+	case WaitFixedDelay:
+		return ""
+	}
+	return "networkIdle"
+}
+
+// scrollPageStep scrolls down a viewport and reports whether the page is at
+// its bottom.
+const scrollPageStep = `(() => {
+	window.scrollBy(0, window.innerHeight);
+	const el = document.scrollingElement || document.documentElement;
+	return window.scrollY + window.innerHeight >= el.scrollHeight - 1;
+})()`
+
+// scrollToBottom scrolls the page down a viewport at a time, pausing delay
+// after each step, until it reaches the bottom or has scrolled
+// MaxScrollSteps times, then back to the top for the capture.
+func scrollToBottom(delay time.Duration) chromedp.Action {
+	if delay <= 0 {
+		delay = DefaultScrollDelay
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		steps := 0
+		for steps < MaxScrollSteps {
+			var atBottom bool
+			if err := chromedp.Evaluate(scrollPageStep, &atBottom).Do(ctx); err != nil {
+				return fmt.Errorf("failed to scroll page: %w", err)
+			}
+			steps++
+			if err := chromedp.Sleep(delay).Do(ctx); err != nil {
+				return err
+			}
+			if atBottom {
+				break
+			}
+		}
+		log.Printf("Scrolled %d viewports to load lazy content", steps)
+		if err := chromedp.Evaluate(`window.scrollTo(0, 0)`, nil).Do(ctx); err != nil {
+			return fmt.Errorf("failed to scroll page: %w", err)
+		}
+		return chromedp.Sleep(delay).Do(ctx)
+	})
+}
+
 // ArchiveAndPersist archives a bookmark URL and stores the result in the database.
 //
 // On success, it writes:
 // - archive_attempted_at
 // - archived_at
 // - archive_status = "ok"
-// - archived_url, archived_html
+// - a new archive version (archived_url + html blob, plus a screenshot if requested)
+// - a provenance record describing how the version was captured
+// - an RFC 3161 timestamp of the version's HTML, if a TSA is configured
+// - readable_* (reader-mode extraction, best effort)
+//
+// A URL that downloads a file is stored by persistDownload instead.
 //
 // On failure, it still records:
 // - archive_attempted_at
-// - archive_status = "error"
-// - archive_error
+// - archive_status = "error", or "skipped" for ErrArchiveDisallowed
+// - archive_error, and archive_error_code from ClassifyArchiveError
+// - wayback_url, the closest Wayback Machine snapshot, if opts.Wayback is set
 func ArchiveAndPersist(ctx context.Context, database *db.DB, b db.Bookmark, opts ArchiveOptions) error {
 	attemptedAt := time.Now()
 
 	res, err := ArchiveBookmark(ctx, b.URL, opts)
 	if err != nil {
-		saveErr := database.SaveArchiveResult(b.ID, attemptedAt, nil, ArchiveStatusError, err.Error(), "", "")
+		status := ArchiveStatusError
+		if errors.Is(err, ErrArchiveDisallowed) {
+			status = ArchiveStatusSkipped
+		}
+		saveErr := database.SaveArchiveFailure(b.ID, attemptedAt, status, ClassifyArchiveError(err), err.Error())
 		if saveErr != nil {
 			return fmt.Errorf("archive failed (%v) and saving failure failed (%v)", err, saveErr)
 		}
+		waybackFallback(ctx, database, b, opts.Wayback, err)
 		return err
 	}
+	if res.Download != nil {
+		return persistDownload(ctx, database, b, res, opts, attemptedAt)
+	}
+
+	if opts.Blocklist != nil {
+		if stripped, n, err := StripTrackers(res.HTML, res.FinalURL, opts.Blocklist); err != nil {
+			log.Printf("Warning: failed to strip trackers for id=%d: %v (keeping them)", b.ID, err)
+		} else {
+			log.Printf("Stripped %d tracker and ad element(s) from bookmark id=%d", n, b.ID)
+			res.HTML = stripped
+		}
+	}
 
 	// Inline external resources to make HTML self-contained
 	log.Printf("Inlining resources for bookmark id=%d", b.ID)
 	inlineOpts := DefaultInlineOptions(res.FinalURL)
+	inlineOpts.Domains = opts.ResourceDomains
+	inlineOpts.Blocklist = opts.Blocklist
+	inlineOpts.Embeds = opts.Embeds
+	inlineOpts.UserAgent = opts.UserAgent
+	inlineOpts.ExtraHeaders = opts.ExtraHeaders
+	inlineOpts.Cache, inlineOpts.CacheTTL = database, opts.ResourceCacheTTL
+	inlineOpts.MaxTotalSize = opts.MaxTotalSize
 	inlinedHTML, err := InlineResources(ctx, res.HTML, inlineOpts)
 	if err != nil {
 		log.Printf("Warning: failed to inline resources for id=%d: %v (using original HTML)", b.ID, err)
 		inlinedHTML = res.HTML
 	}
 
+	if opts.StripScripts {
+		if stripped, err := StripScripts(inlinedHTML); err != nil {
+			log.Printf("Warning: failed to strip scripts for id=%d: %v (keeping scripts)", b.ID, err)
+		} else {
+			inlinedHTML = stripped
+		}
+	}
+
 	archivedAt := time.Now()
 	if err := database.SaveArchiveResult(b.ID, attemptedAt, &archivedAt, ArchiveStatusOK, "", res.FinalURL, inlinedHTML); err != nil {
 		return err
 	}
 
+	if tsaURL := TimestampAuthority(); tsaURL != "" {
+		if ts, err := TimestampArchive(ctx, database, b.ID, inlinedHTML, tsaURL); err != nil {
+			log.Printf("Warning: failed to timestamp archive for id=%d: %v", b.ID, err)
+		} else {
+			log.Printf("Timestamped archive for id=%d at %s", b.ID, ts.TimestampedAt)
+		}
+	}
+
+	provenance := NewArchiveProvenance(b, res, opts, archivedAt)
+	if err := saveArchiveProvenance(database, b.ID, provenance); err != nil {
+		log.Printf("Warning: failed to save provenance for id=%d: %v", b.ID, err)
+	}
+
+	if len(res.Screenshot) > 0 {
+		if err := database.SaveArchiveScreenshot(b.ID, res.Screenshot); err != nil {
+			log.Printf("Warning: failed to save screenshot for id=%d: %v", b.ID, err)
+		} else if diff, err := DetectVisualChange(database, b.ID); err != nil {
+			log.Printf("Warning: failed to compare screenshots for id=%d: %v", b.ID, err)
+		} else if diff.Changed() {
+			log.Printf("Screenshot of id=%d changed in %d of %d regions", b.ID, diff.ChangedRegions, diff.Regions)
+		}
+	}
+
+	// Extract a reader-mode view; failures here don't fail the archive.
+	if article, err := ExtractArticle(inlinedHTML, res.FinalURL); err != nil {
+		log.Printf("Warning: readability extraction failed for id=%d: %v", b.ID, err)
+	} else if err := database.SaveBookmarkReadable(db.BookmarkReadable{
+		BookmarkID:  b.ID,
+		Title:       article.Title,
+		Byline:      article.Byline,
+		Content:     article.Content,
+		TextContent: article.TextContent,
+	}); err != nil {
+		log.Printf("Warning: failed to save readable content for id=%d: %v", b.ID, err)
+	}
+
+	saveArchiveLinks(database, b.ID, res.HTML, res.FinalURL)
+
+	// Keep a local copy of the favicon the archived page declares.
+	if meta, err := ParseMetadata(res.HTML, res.FinalURL); err == nil && meta.FaviconURL != "" {
+		if err := SaveFavicon(ctx, database, b.ID, meta.FaviconURL, DefaultMetadataTimeout); err != nil {
+			log.Printf("Warning: failed to save favicon for id=%d: %v", b.ID, err)
+		}
+	}
+
 	// Optional: if the stored title is empty, you could update it here in the future.
 	_ = res.Title
 	log.Printf("Archived bookmark id=%d url=%s", b.ID, b.URL)
@@ -233,6 +683,9 @@ func RunArchive(ctx context.Context, database *db.DB, opts ArchiveRunOptions) (A
 			return ArchiveRunResult{}, err
 		}
 		if err := ArchiveAndPersist(ctx, database, b, opts.Options); err != nil {
+			if errors.Is(err, ErrArchiveDisallowed) {
+				return ArchiveRunResult{Attempted: 1, Skipped: 1}, err
+			}
 			return ArchiveRunResult{Attempted: 1, Failed: 1}, err
 		}
 		return ArchiveRunResult{Attempted: 1, Succeeded: 1}, nil
@@ -252,6 +705,11 @@ func RunArchive(ctx context.Context, database *db.DB, opts ArchiveRunOptions) (A
 	for _, b := range bookmarks {
 		res.Attempted++
 		if err := ArchiveAndPersist(ctx, database, b, opts.Options); err != nil {
+			if errors.Is(err, ErrArchiveDisallowed) {
+				res.Skipped++
+				log.Printf("Skipped id=%d url=%s: %v", b.ID, b.URL, err)
+				continue
+			}
 			res.Failed++
 			log.Printf("Archive failed for id=%d url=%s: %v", b.ID, b.URL, err)
 			continue
@@ -266,3 +724,32 @@ func RunArchive(ctx context.Context, database *db.DB, opts ArchiveRunOptions) (A
 	log.Println("Archiving finished successfully.")
 	return res, nil
 }
+
+// StripScripts removes <script> elements, inline event handler attributes
+// (onclick, onload, ...) and javascript: links from an HTML document, leaving
+// a static snapshot.
+func StripScripts(rawHTML string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	doc.Find("script").Remove()
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		for _, n := range s.Nodes {
+			attrs := n.Attr[:0]
+			for _, a := range n.Attr {
+				key := strings.ToLower(a.Key)
+				if strings.HasPrefix(key, "on") {
+					continue
+				}
+				if (key == "href" || key == "src" || key == "action") &&
+					strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:") {
+					continue
+				}
+				attrs = append(attrs, a)
+			}
+			n.Attr = attrs
+		}
+	})
+	return doc.Html()
+}
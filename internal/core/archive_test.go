@@ -2,6 +2,9 @@ package core
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -39,6 +42,27 @@ func TestArchiveOptions(t *testing.T) {
 	})
 }
 
+func TestParseHeaders(t *testing.T) {
+	h, err := ParseHeaders([]string{"accept-language: de-DE,de;q=0.9", "X-Token:abc:def"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(h) != 2 || h["Accept-Language"] != "de-DE,de;q=0.9" || h["X-Token"] != "abc:def" {
+		t.Errorf("unexpected headers %v", map[string]string(h))
+	}
+	if got := h.String(); got != "[Accept-Language X-Token]" {
+		t.Errorf("expected only header names, got %q", got)
+	}
+	if h, err := ParseHeaders(nil); err != nil || h != nil {
+		t.Errorf("expected no headers, got %v, %v", h, err)
+	}
+	for _, bad := range []string{"no colon", ": value", "Bad Name: x", "X-A: b\r\nX-B: c"} {
+		if _, err := ParseHeaders([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
 func TestArchiveResult(t *testing.T) {
 	t.Run("empty result", func(t *testing.T) {
 		result := ArchiveResult{}
@@ -265,3 +289,121 @@ func TestArchiveBookmark_RequiresBrowser(t *testing.T) {
 		t.Log("Warning: Title is empty (some pages have no title)")
 	}
 }
+
+func TestStripScripts(t *testing.T) {
+	in := `<html><head><script src="app.js"></script></head><body onload="init()">` +
+		`<a href="javascript:void(0)" onclick="go()">x</a><a href="/ok">y</a>` +
+		`<script>alert(1)</script><p>kept</p></body></html>`
+
+	got, err := StripScripts(in)
+	if err != nil {
+		t.Fatalf("StripScripts() error = %v", err)
+	}
+	for _, banned := range []string{"<script", "onload", "onclick", "javascript:"} {
+		if strings.Contains(got, banned) {
+			t.Errorf("expected %q to be removed, got %s", banned, got)
+		}
+	}
+	for _, want := range []string{`<p>kept</p>`, `href="/ok"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got %s", want, got)
+		}
+	}
+}
+
+// TestArchiveBookmark_ScrollToBottom checks that lazy content loaded on
+// scrolling is captured. Like TestArchiveBookmark_RequiresBrowser, it's
+// skipped if Chrome isn't available.
+func TestArchiveBookmark_ScrollToBottom(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+	if !chromeInstalled("") {
+		t.Skip("Chrome not available")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><div style="height: 5000px">Tall</div><p id="lazy"></p>
+<script>
+window.addEventListener("scroll", () => {
+	if (window.scrollY + window.innerHeight >= document.documentElement.scrollHeight - 10) {
+		document.getElementById("lazy").textContent = "lazy content loaded";
+	}
+});
+</script></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := ArchiveBookmark(ctx, srv.URL, ArchiveOptions{
+		Engine:         ArchiveEngineChrome,
+		Headless:       true,
+		Timeout:        20 * time.Second,
+		ScrollToBottom: true,
+		ScrollDelay:    50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Skipf("Chrome failed: %v", err)
+	}
+	if !strings.Contains(result.HTML, "lazy content loaded") {
+		t.Errorf("expected the lazy content to be captured, got %s", result.HTML)
+	}
+}
+
+func TestParseWaitStrategy(t *testing.T) {
+	for in, want := range map[string]string{"": WaitNetworkIdle, "networkidle": WaitNetworkIdle, " DOMContentLoaded ": WaitDOMContentLoaded, "load": WaitLoad, "Fixed-Delay": WaitFixedDelay} {
+		if got, err := ParseWaitStrategy(in); err != nil || got != want {
+			t.Errorf("ParseWaitStrategy(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseWaitStrategy("idle"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+// TestArchiveBookmark_WaitStrategy checks that a page that never goes
+// network idle is captured with another strategy. Like
+// TestArchiveBookmark_RequiresBrowser, it's skipped if Chrome isn't
+// available.
+func TestArchiveBookmark_WaitStrategy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+	if !chromeInstalled("") {
+		t.Skip("Chrome not available")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/poll" {
+			time.Sleep(100 * time.Millisecond)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><p id="app"></p>
+<script>
+setTimeout(() => { document.getElementById("app").textContent = "rendered"; }, 200);
+setInterval(() => fetch("/poll"), 100);
+</script></body></html>`))
+	}))
+	defer srv.Close()
+
+	for _, strategy := range []string{WaitLoad, WaitFixedDelay} {
+		t.Run(strategy, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			result, err := ArchiveBookmark(ctx, srv.URL, ArchiveOptions{
+				Engine:       ArchiveEngineChrome,
+				Headless:     true,
+				Timeout:      10 * time.Second,
+				WaitStrategy: strategy,
+				ExtraDelay:   time.Second,
+			})
+			if err != nil {
+				t.Skipf("Chrome failed: %v", err)
+			}
+			if !strings.Contains(result.HTML, "rendered") {
+				t.Errorf("expected the rendered page to be captured, got %s", result.HTML)
+			}
+		})
+	}
+}
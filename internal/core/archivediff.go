@@ -0,0 +1,319 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Kinds of ArchiveDiffLine.
+const (
+	// DiffEqual is a paragraph both snapshots have.
+	DiffEqual = "equal"
+	// DiffDelete is a paragraph only the older snapshot has.
+	DiffDelete = "delete"
+	// DiffInsert is a paragraph only the newer snapshot has.
+	DiffInsert = "insert"
+	// DiffSkip stands for unchanged paragraphs left out between changes;
+	// its Skipped counts them.
+	DiffSkip = "skip"
+)
+
+// ArchiveDiff is a paragraph-level diff of the text of two snapshots of a
+// page; see DiffArchiveText.
+type ArchiveDiff struct {
+	Lines []ArchiveDiffLine `json:"lines"`
+	// Inserted and Deleted count the paragraphs added and removed; a
+	// changed paragraph counts as both.
+	Inserted int `json:"inserted"`
+	Deleted  int `json:"deleted"`
+}
+
+// ArchiveDiffLine is a paragraph of an ArchiveDiff.
+type ArchiveDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text,omitempty"`
+	// Spans split a changed paragraph into words it shares with the
+	// paragraph it replaced, or that replaced it, and words it doesn't. They
+	// are set only on deletes followed by inserts that share some words.
+	Spans []ArchiveDiffSpan `json:"spans,omitempty"`
+	// Skipped is how many unchanged paragraphs a DiffSkip line stands for.
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// ArchiveDiffSpan is a run of words in a changed paragraph.
+type ArchiveDiffSpan struct {
+	Text    string `json:"text"`
+	Changed bool   `json:"changed,omitempty"`
+}
+
+// Changed reports whether the two snapshots' text differs.
+func (d ArchiveDiff) Changed() bool {
+	return d.Inserted > 0 || d.Deleted > 0
+}
+
+// diffBlockTags end a paragraph of ArchiveText.
+var diffBlockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Dt: true, atom.Dd: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Tr: true, atom.Figcaption: true, atom.Br: true, atom.Hr: true, atom.Table: true,
+	atom.Ul: true, atom.Ol: true, atom.Header: true, atom.Footer: true, atom.Main: true,
+}
+
+// diffSkipTags hold no readable text.
+var diffSkipTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Head: true, atom.Iframe: true,
+}
+
+// ArchiveText returns the readable text of an archived page, one paragraph
+// per block element, with whitespace collapsed: the article ExtractArticle
+// finds, or the whole page if it finds none.
+func ArchiveText(rawHTML, baseURL string) ([]string, error) {
+	article, err := ExtractArticle(rawHTML, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	source := article.Content
+	if strings.TrimSpace(source) == "" {
+		source = rawHTML
+	}
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse archive: %w", err)
+	}
+
+	var blocks []string
+	var b strings.Builder
+	flush := func() {
+		if text := normalizeText(b.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		b.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if diffSkipTags[n.DataAtom] {
+				return
+			}
+			if n.DataAtom == atom.Td || n.DataAtom == atom.Th {
+				b.WriteString(" ")
+			}
+		}
+		block := n.Type == html.ElementNode && diffBlockTags[n.DataAtom]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(doc)
+	flush()
+	return blocks, nil
+}
+
+// DiffArchiveText diffs the paragraphs of two snapshots, as ArchiveText
+// gives them, from the older to the newer. Runs of unchanged paragraphs
+// are cut to context paragraphs around each change, and a deleted
+// paragraph followed by an inserted one gets word-level Spans.
+func DiffArchiveText(from, to []string, context int) ArchiveDiff {
+	ops := diffSequences(from, to)
+	d := ArchiveDiff{Lines: []ArchiveDiffLine{}}
+	for i := 0; i < len(ops); {
+		if ops[i].op == DiffEqual {
+			j := i
+			for j < len(ops) && ops[j].op == DiffEqual {
+				j++
+			}
+			d.Lines = append(d.Lines, collapseEqual(ops[i:j], i == 0, j == len(ops), context)...)
+			i = j
+			continue
+		}
+
+		// A change: its deletes, then its inserts.
+		j := i
+		for j < len(ops) && ops[j].op == DiffDelete {
+			j++
+		}
+		k := j
+		for k < len(ops) && ops[k].op == DiffInsert {
+			k++
+		}
+		deleted, inserted := ops[i:j], ops[j:k]
+		d.Deleted += len(deleted)
+		d.Inserted += len(inserted)
+		var dels, ins []ArchiveDiffLine
+		for _, op := range deleted {
+			dels = append(dels, ArchiveDiffLine{Op: DiffDelete, Text: op.text})
+		}
+		for _, op := range inserted {
+			ins = append(ins, ArchiveDiffLine{Op: DiffInsert, Text: op.text})
+		}
+		// Pair deleted and inserted paragraphs in order to show which words
+		// changed.
+		for n := range min(len(dels), len(ins)) {
+			dels[n].Spans, ins[n].Spans = diffWords(dels[n].Text, ins[n].Text)
+		}
+		d.Lines = append(append(d.Lines, dels...), ins...)
+		i = k
+	}
+	return d
+}
+
+// collapseEqual turns a run of unchanged paragraphs into lines, keeping
+// context of them next to changes and a DiffSkip line for the rest. A run
+// at the start or end of the text only borders one change.
+func collapseEqual(run []diffOp, first, last bool, context int) []ArchiveDiffLine {
+	keepBefore, keepAfter := context, context
+	if first {
+		keepBefore = 0
+	}
+	if last {
+		keepAfter = 0
+	}
+	if first && last {
+		// Nothing changed.
+		keepBefore, keepAfter = 0, 0
+	}
+	var lines []ArchiveDiffLine
+	if len(run) <= keepBefore+keepAfter {
+		for _, op := range run {
+			lines = append(lines, ArchiveDiffLine{Op: DiffEqual, Text: op.text})
+		}
+		return lines
+	}
+	for _, op := range run[:keepBefore] {
+		lines = append(lines, ArchiveDiffLine{Op: DiffEqual, Text: op.text})
+	}
+	lines = append(lines, ArchiveDiffLine{Op: DiffSkip, Skipped: len(run) - keepBefore - keepAfter})
+	for _, op := range run[len(run)-keepAfter:] {
+		lines = append(lines, ArchiveDiffLine{Op: DiffEqual, Text: op.text})
+	}
+	return lines
+}
+
+// diffWords splits two versions of a paragraph into spans of words they
+// share and words they don't, or returns nil for both if they share none.
+func diffWords(from, to string) ([]ArchiveDiffSpan, []ArchiveDiffSpan) {
+	ops := diffSequences(strings.Fields(from), strings.Fields(to))
+	var fromSpans, toSpans []ArchiveDiffSpan
+	shared := false
+	add := func(spans []ArchiveDiffSpan, word string, changed bool) []ArchiveDiffSpan {
+		if n := len(spans); n > 0 && spans[n-1].Changed == changed {
+			spans[n-1].Text += " " + word
+			return spans
+		}
+		if len(spans) > 0 {
+			word = " " + word
+		}
+		return append(spans, ArchiveDiffSpan{Text: word, Changed: changed})
+	}
+	for _, op := range ops {
+		switch op.op {
+		case DiffEqual:
+			shared = true
+			fromSpans = add(fromSpans, op.text, false)
+			toSpans = add(toSpans, op.text, false)
+		case DiffDelete:
+			fromSpans = add(fromSpans, op.text, true)
+		case DiffInsert:
+			toSpans = add(toSpans, op.text, true)
+		}
+	}
+	if !shared {
+		return nil, nil
+	}
+	return fromSpans, toSpans
+}
+
+// diffOp is one element of an edit script from diffSequences.
+type diffOp struct {
+	op   string
+	text string
+}
+
+// diffSequences returns an edit script turning a into b, keeping a longest
+// common subsequence, with each change's deletes before its inserts. The
+// common prefix and suffix are matched directly; if what's left between
+// them is larger than MaxArchiveDiffCells allows, it is deleted and
+// inserted whole.
+func diffSequences(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, s := range a[:prefix] {
+		ops = append(ops, diffOp{DiffEqual, s})
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > MaxArchiveDiffCells {
+		for _, s := range ma {
+			ops = append(ops, diffOp{DiffDelete, s})
+		}
+		for _, s := range mb {
+			ops = append(ops, diffOp{DiffInsert, s})
+		}
+	} else {
+		ops = append(ops, lcsDiff(ma, mb)...)
+	}
+	for _, s := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{DiffEqual, s})
+	}
+	return ops
+}
+
+// lcsDiff diffs a and b by dynamic programming over their longest common
+// subsequence.
+func lcsDiff(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	cols := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*cols)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			} else {
+				lcs[i*cols+j] = max(lcs[(i+1)*cols+j], lcs[i*cols+j+1])
+			}
+		}
+	}
+
+	var ops, inserts []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, inserts...)
+			inserts = inserts[:0]
+			ops = append(ops, diffOp{DiffEqual, a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i*cols+j+1] >= lcs[(i+1)*cols+j]):
+			// Inserts wait for the change's deletes.
+			inserts = append(inserts, diffOp{DiffInsert, b[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{DiffDelete, a[i]})
+			i++
+		}
+	}
+	return append(ops, inserts...)
+}
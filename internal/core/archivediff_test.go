@@ -0,0 +1,79 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchiveText(t *testing.T) {
+	page := `<html><head><title>Terms</title><style>p { color: red }</style></head><body>
+		<nav>Home</nav>
+		<h1>Terms of   Service</h1>
+		<p>We may change these terms<br>at any time.</p>
+		<ul><li>No spam</li><li>No <b>abuse</b></li></ul>
+		<table><tr><td>Plan</td><td>Free</td></tr></table>
+		<script>var x = 1;</script>
+	</body></html>`
+	got, err := ArchiveText(page, "https://example.com/terms")
+	if err != nil {
+		t.Fatalf("ArchiveText() error = %v", err)
+	}
+	// The nav is left out with the rest of what isn't the article.
+	want := []string{"Terms of Service", "We may change these terms", "at any time.", "No spam", "No abuse", "Plan Free"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ArchiveText() = %q, want %q", got, want)
+	}
+}
+
+func TestDiffArchiveText(t *testing.T) {
+	from := []string{"Intro", "a", "b", "c", "d", "e", "We keep your data for 30 days.", "f", "Old clause"}
+	to := []string{"Intro", "a", "b", "c", "d", "e", "We keep your data for 90 days.", "f", "Arbitration applies"}
+
+	d := DiffArchiveText(from, to, 2)
+	if !d.Changed() || d.Inserted != 2 || d.Deleted != 2 {
+		t.Fatalf("expected 2 inserted and 2 deleted paragraphs, got %+v", d)
+	}
+	var ops []string
+	for _, l := range d.Lines {
+		ops = append(ops, l.Op)
+	}
+	// The 6 unchanged paragraphs before the first change are cut to 2;
+	// the one between the changes is kept.
+	wantOps := []string{DiffSkip, DiffEqual, DiffEqual, DiffDelete, DiffInsert, DiffEqual, DiffDelete, DiffInsert}
+	if !reflect.DeepEqual(ops, wantOps) {
+		t.Fatalf("ops = %v, want %v", ops, wantOps)
+	}
+	if d.Lines[0].Skipped != 4 {
+		t.Errorf("expected 4 skipped paragraphs, got %d", d.Lines[0].Skipped)
+	}
+
+	wantDel := []ArchiveDiffSpan{{Text: "We keep your data for"}, {Text: " 30", Changed: true}, {Text: " days."}}
+	wantIns := []ArchiveDiffSpan{{Text: "We keep your data for"}, {Text: " 90", Changed: true}, {Text: " days."}}
+	if !reflect.DeepEqual(d.Lines[3].Spans, wantDel) || !reflect.DeepEqual(d.Lines[4].Spans, wantIns) {
+		t.Errorf("word spans = %+v / %+v", d.Lines[3].Spans, d.Lines[4].Spans)
+	}
+	// Paragraphs sharing no words aren't split into spans.
+	if d.Lines[6].Spans != nil || d.Lines[7].Spans != nil {
+		t.Errorf("expected no spans for unrelated paragraphs, got %+v / %+v", d.Lines[6].Spans, d.Lines[7].Spans)
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		d := DiffArchiveText(from, from, 2)
+		if d.Changed() || len(d.Lines) != 1 || d.Lines[0].Op != DiffSkip || d.Lines[0].Skipped != len(from) {
+			t.Errorf("expected one skip line for unchanged text, got %+v", d)
+		}
+	})
+
+	t.Run("too large to diff", func(t *testing.T) {
+		n := 2001
+		a, b := make([]string, n), make([]string, n)
+		for i := range n {
+			a[i], b[i] = strings.Repeat("a", i+1), strings.Repeat("b", i+1)
+		}
+		d := DiffArchiveText(a, b, 0)
+		if d.Deleted != n || d.Inserted != n {
+			t.Errorf("expected everything replaced, got %d deleted and %d inserted", d.Deleted, d.Inserted)
+		}
+	})
+}
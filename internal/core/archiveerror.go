@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// Archive error codes, stored with a failed archive's message as
+// archive_error_code so failures can be grouped and retried by kind.
+const (
+	ArchiveErrorTimeout = "timeout"
+	ArchiveErrorDNS     = "dns"
+	ArchiveErrorTLS     = "tls"
+	// ArchiveErrorNetwork covers connections refused, reset or unreachable.
+	ArchiveErrorNetwork = "network"
+	ArchiveErrorHTTP4xx = "http-4xx"
+	ArchiveErrorHTTP5xx = "http-5xx"
+	// ArchiveErrorBlocked is for pages the archive rules, robots.txt or the
+	// internal address guard kept bookmarkd from fetching.
+	ArchiveErrorBlocked = "blocked"
+	// ArchiveErrorChromeCrash is for a browser that failed to start, crashed
+	// or went away mid-capture.
+	ArchiveErrorChromeCrash = "chrome-crash"
+	ArchiveErrorOther       = "other"
+)
+
+// ArchiveErrorCodes lists every archive error code.
+var ArchiveErrorCodes = []string{
+	ArchiveErrorTimeout, ArchiveErrorDNS, ArchiveErrorTLS, ArchiveErrorNetwork,
+	ArchiveErrorHTTP4xx, ArchiveErrorHTTP5xx, ArchiveErrorBlocked, ArchiveErrorChromeCrash,
+	ArchiveErrorOther,
+}
+
+// ErrInternalAddress is returned for fetches that would reach a loopback,
+// private or otherwise internal address.
+var ErrInternalAddress = errors.New("internal address")
+
+// HTTPStatusError is returned when a page is answered with an HTTP error
+// status.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d fetching %s", e.StatusCode, e.URL)
+}
+
+// ClassifyArchiveError returns the archive error code for err: typed errors
+// are checked first, then the net:: error names Chrome reports as text.
+func ClassifyArchiveError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var statusErr *HTTPStatusError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCert x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	switch {
+	case errors.Is(err, ErrDomainBlocked), errors.Is(err, ErrArchiveDisallowed), errors.Is(err, ErrInternalAddress):
+		return ArchiveErrorBlocked
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode == http.StatusRequestTimeout {
+			return ArchiveErrorTimeout
+		}
+		if statusErr.StatusCode >= 500 {
+			return ArchiveErrorHTTP5xx
+		}
+		return ArchiveErrorHTTP4xx
+	case errors.Is(err, ErrBrowserPoolClosed):
+		return ArchiveErrorChromeCrash
+	case errors.As(err, &dnsErr):
+		return ArchiveErrorDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &invalidCert), errors.As(err, &hostnameErr):
+		return ArchiveErrorTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ArchiveErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return ArchiveErrorNetwork
+	}
+
+	msg := err.Error()
+	for _, c := range []struct {
+		code     string
+		contains []string
+	}{
+		{ArchiveErrorTimeout, []string{"net::ERR_TIMED_OUT", "net::ERR_CONNECTION_TIMED_OUT"}},
+		{ArchiveErrorDNS, []string{"net::ERR_NAME_NOT_RESOLVED", "net::ERR_NAME_RESOLUTION_FAILED"}},
+		{ArchiveErrorTLS, []string{"net::ERR_CERT_", "net::ERR_SSL_", "net::ERR_BAD_SSL_"}},
+		{ArchiveErrorNetwork, []string{"net::ERR_CONNECTION_", "net::ERR_ADDRESS_UNREACHABLE", "net::ERR_INTERNET_DISCONNECTED", "net::ERR_EMPTY_RESPONSE"}},
+		{ArchiveErrorBlocked, []string{"net::ERR_BLOCKED_BY_CLIENT", "net::ERR_BLOCKED_BY_RESPONSE"}},
+		{ArchiveErrorChromeCrash, []string{"chrome failed to start", "Target crashed", "websocket", "executable file not found"}},
+	} {
+		for _, s := range c.contains {
+			if strings.Contains(msg, s) {
+				return c.code
+			}
+		}
+	}
+	return ArchiveErrorOther
+}
+
+// archiveErrorRetryable reports whether an archive that failed with err may
+// succeed if tried again. Pages the rules block and client errors other
+// than rate limiting won't change between attempts.
+func archiveErrorRetryable(err error) bool {
+	switch ClassifyArchiveError(err) {
+	case ArchiveErrorBlocked:
+		return false
+	case ArchiveErrorHTTP4xx:
+		var statusErr *HTTPStatusError
+		return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyArchiveError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"domain blocked", fmt.Errorf("%w: example.com", ErrDomainBlocked), ArchiveErrorBlocked},
+		{"robots", fmt.Errorf("%w: robots.txt disallows /", ErrArchiveDisallowed), ArchiveErrorBlocked},
+		{"internal address", fmt.Errorf("blocked connection to %w 127.0.0.1", ErrInternalAddress), ArchiveErrorBlocked},
+		{"not found", &HTTPStatusError{StatusCode: 404, URL: "https://example.com"}, ArchiveErrorHTTP4xx},
+		{"server error", fmt.Errorf("fetch: %w", &HTTPStatusError{StatusCode: 503}), ArchiveErrorHTTP5xx},
+		{"request timeout", &HTTPStatusError{StatusCode: 408}, ArchiveErrorTimeout},
+		{"dns", &net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}, ArchiveErrorDNS},
+		{"deadline", fmt.Errorf("page load: %w", context.DeadlineExceeded), ArchiveErrorTimeout},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ArchiveErrorNetwork},
+		{"pool closed", ErrBrowserPoolClosed, ArchiveErrorChromeCrash},
+		{"chrome cert", errors.New("page load error net::ERR_CERT_AUTHORITY_INVALID"), ArchiveErrorTLS},
+		{"chrome dns", errors.New("page load error net::ERR_NAME_NOT_RESOLVED"), ArchiveErrorDNS},
+		{"chrome crash", errors.New("chrome failed to start"), ArchiveErrorChromeCrash},
+		{"unknown", errors.New("boom"), ArchiveErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyArchiveError(tt.err); got != tt.want {
+				t.Errorf("ClassifyArchiveError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveErrorRetryable(t *testing.T) {
+	for err, want := range map[error]bool{
+		ErrDomainBlocked:                           false,
+		&HTTPStatusError{StatusCode: 404}:          false,
+		&HTTPStatusError{StatusCode: 429}:          true,
+		&HTTPStatusError{StatusCode: 502}:          true,
+		fmt.Errorf("%w", context.DeadlineExceeded): true,
+	} {
+		if got := archiveErrorRetryable(err); got != want {
+			t.Errorf("archiveErrorRetryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
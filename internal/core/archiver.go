@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sync"
+)
+
+// Archiver captures a page for ArchiveBookmark, after the URL has passed
+// the domain rules and robots.txt. opts.Timeout is already set.
+type Archiver interface {
+	Archive(ctx context.Context, url string, opts ArchiveOptions) (ArchiveResult, error)
+}
+
+// EngineArchiver is the Archiver bookmarkd runs with: Chrome through
+// chromedp, or a plain GET with the HTTP engine (see archiveEngine).
+type EngineArchiver struct{}
+
+// Archive captures url with the engine opts select.
+func (EngineArchiver) Archive(ctx context.Context, url string, opts ArchiveOptions) (ArchiveResult, error) {
+	if archiveEngine(opts) == ArchiveEngineHTTP {
+		return archiveHTTP(ctx, url, opts)
+	}
+	return archiveChrome(ctx, url, opts)
+}
+
+// FakeArchiver is an Archiver that fetches nothing, so the queue, retries
+// and archive status flows can be run without Chrome or the network. A URL
+// gets its entry in Errors, else its entry in Results, else a small page
+// titled with the URL. It is safe for concurrent use once set up.
+type FakeArchiver struct {
+	Results map[string]ArchiveResult
+	Errors  map[string]error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// Archive returns the canned result for url and records the call.
+func (f *FakeArchiver) Archive(ctx context.Context, url string, _ ArchiveOptions) (ArchiveResult, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, url)
+	f.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return ArchiveResult{}, err
+	}
+	if err, ok := f.Errors[url]; ok {
+		return ArchiveResult{}, err
+	}
+	if res, ok := f.Results[url]; ok {
+		if res.FinalURL == "" {
+			res.FinalURL = url
+		}
+		return res, nil
+	}
+	title := html.EscapeString(url)
+	return ArchiveResult{
+		FinalURL: url,
+		Title:    url,
+		Engine:   "fake",
+		HTML:     fmt.Sprintf("<html><head><title>%s</title></head><body><p>Archived %s</p></body></html>", title, title),
+	}, nil
+}
+
+// Calls returns the URLs archived so far, in order.
+func (f *FakeArchiver) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
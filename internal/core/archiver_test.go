@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestArchiveAndPersist_FakeArchiver(t *testing.T) {
+	database := newQueueTestDB(t)
+	add := func(url string) db.Bookmark {
+		t.Helper()
+		id, err := database.AddBookmark(url, url)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		b, err := database.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		return b
+	}
+	ok := add("https://example.com/ok")
+	missing := add("https://example.com/missing")
+	blocked := add("https://blocked.example.org/")
+
+	fake := &FakeArchiver{Errors: map[string]error{
+		missing.URL: &HTTPStatusError{StatusCode: 404, URL: missing.URL},
+	}}
+	opts := ArchiveOptions{Archiver: fake, Domains: DomainRules{Deny: []string{"blocked.example.org"}}}
+
+	if err := ArchiveAndPersist(context.Background(), database, ok, opts); err != nil {
+		t.Fatalf("ArchiveAndPersist() error = %v", err)
+	}
+	archive, err := database.GetBookmarkArchive(ok.ID)
+	if err != nil {
+		t.Fatalf("failed to get archive: %v", err)
+	}
+	if archive.ArchiveStatus != ArchiveStatusOK || archive.ArchivedURL != ok.URL || archive.ArchivedHTML == "" {
+		t.Errorf("expected the fake page to be saved, got %+v", archive)
+	}
+	if versions, err := database.ListArchiveVersions(ok.ID); err != nil || len(versions) != 1 {
+		t.Errorf("expected 1 archive version, got %d (err=%v)", len(versions), err)
+	}
+
+	if err := ArchiveAndPersist(context.Background(), database, missing, opts); err == nil {
+		t.Fatal("expected the canned error")
+	}
+	if archive, _ := database.GetBookmarkArchive(missing.ID); archive.ArchiveStatus != ArchiveStatusError || archive.ArchiveErrorCode != ArchiveErrorHTTP4xx {
+		t.Errorf("expected an http-4xx failure, got %q (%q)", archive.ArchiveStatus, archive.ArchiveErrorCode)
+	}
+
+	// Domain rules are checked before the archiver is asked.
+	if err := ArchiveAndPersist(context.Background(), database, blocked, opts); !errors.Is(err, ErrDomainBlocked) {
+		t.Errorf("expected ErrDomainBlocked, got %v", err)
+	}
+	if calls := fake.Calls(); len(calls) != 2 || calls[0] != ok.URL || calls[1] != missing.URL {
+		t.Errorf("unexpected archiver calls: %v", calls)
+	}
+}
+
+// TestArchiveQueue_FakeArchiver runs the queue end to end, through
+// ArchiveAndPersist, with a page that fails once before it archives.
+func TestArchiveQueue_FakeArchiver(t *testing.T) {
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark("https://example.com/flaky", "Flaky")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	fake := &FakeArchiver{Errors: map[string]error{
+		"https://example.com/flaky": fmt.Errorf("dial tcp: %w", syscall.ECONNRESET),
+	}}
+	q := NewArchiveQueue(database, ArchiveQueueOptions{BaseBackoff: time.Hour, Archive: ArchiveOptions{Archiver: fake}})
+	if err := q.Enqueue(id, "test"); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+		t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+	}
+	if archive, _ := database.GetBookmarkArchiveStatus(id); archive.ArchiveStatus != ArchiveStatusError {
+		t.Errorf("expected the first attempt to fail, got %+v", archive)
+	}
+
+	delete(fake.Errors, "https://example.com/flaky")
+	jobs, err := database.ListJobs(db.JobStatusQueued, 0)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected a retry to be queued, got %d (err=%v)", len(jobs), err)
+	}
+	if err := database.RetryJob(jobs[0].ID, "", time.Now()); err != nil {
+		t.Fatalf("failed to make job due: %v", err)
+	}
+	if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+		t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+	}
+	if archive, _ := database.GetBookmarkArchiveStatus(id); archive.ArchiveStatus != ArchiveStatusOK || archive.ArchiveErrorCode != "" {
+		t.Errorf("expected the retry to archive the page, got %+v", archive)
+	}
+	if attempts, err := database.ListArchiveAttempts(id); err != nil || len(attempts) != 2 || attempts[0].Status != ArchiveStatusOK {
+		t.Errorf("expected a failed and a successful attempt, got %+v (err=%v)", attempts, err)
+	}
+}
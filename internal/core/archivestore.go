@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ArchiveStore is where archived page HTML is kept. Metadata (bookmarks,
+// versions, status) always stays in SQLite; only the blobs move.
+//
+// Keys are the hex SHA-256 of the uncompressed HTML and data is
+// gzip-compressed, so stores never need to interpret what they hold. Get must
+// return an error wrapping db.ErrBlobNotFound for a missing key.
+//
+// Every ArchiveStore is a db.BlobStore and can be passed to db.SetBlobStore.
+type ArchiveStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Archive store backends selectable with ArchiveStoreConfig.Backend.
+const (
+	ArchiveStoreSQLite = "sqlite"
+	ArchiveStoreDir    = "dir"
+	ArchiveStoreS3     = "s3"
+)
+
+// ArchiveStoreConfig selects and configures an archive store backend.
+type ArchiveStoreConfig struct {
+	// Backend is one of ArchiveStoreSQLite (default), ArchiveStoreDir or ArchiveStoreS3.
+	Backend string
+	// Dir is the root directory for ArchiveStoreDir.
+	Dir string
+	// S3 configures ArchiveStoreS3.
+	S3 S3Config
+}
+
+// NewSQLiteArchiveStore returns the store that keeps blobs in the database's
+// archive_blobs table. This is the default.
+func NewSQLiteArchiveStore(database *db.DB) ArchiveStore {
+	return database.SQLiteBlobStore()
+}
+
+// OpenArchiveStore builds the store described by cfg.
+func OpenArchiveStore(cfg ArchiveStoreConfig, database *db.DB) (ArchiveStore, error) {
+	switch cfg.Backend {
+	case "", ArchiveStoreSQLite:
+		return NewSQLiteArchiveStore(database), nil
+	case ArchiveStoreDir:
+		return NewDirArchiveStore(cfg.Dir)
+	case ArchiveStoreS3:
+		return NewS3ArchiveStore(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown archive store %q (want %s, %s or %s)",
+			cfg.Backend, ArchiveStoreSQLite, ArchiveStoreDir, ArchiveStoreS3)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// DirArchiveStore keeps blobs as files under a local directory, fanned out by
+// the first two characters of the key (root/ab/abcdef....html.gz).
+type DirArchiveStore struct {
+	root string
+}
+
+// NewDirArchiveStore creates the root directory if needed and returns a store using it.
+func NewDirArchiveStore(root string) (*DirArchiveStore, error) {
+	if root == "" {
+		return nil, errors.New("archive directory is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &DirArchiveStore{root: root}, nil
+}
+
+func (s *DirArchiveStore) path(key string) (string, error) {
+	if len(key) < 3 || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid archive key %q", key)
+	}
+	return filepath.Join(s.root, key[:2], key+".html.gz"), nil
+}
+
+// Put writes data to a temporary file and renames it into place, so readers
+// never see a partially written blob.
+func (s *DirArchiveStore) Put(_ context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err == nil {
+		return nil // content-addressed: already stored
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // no-op after a successful rename
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store archive file: %w", err)
+	}
+	return nil
+}
+
+func (s *DirArchiveStore) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", db.ErrBlobNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to read archive file: %w", err)
+	}
+	return data, nil
+}
+
+// Has reports whether a non-empty file is stored under key; an empty one is
+// what a full disk or a crash mid-write can leave behind.
+func (s *DirArchiveStore) Has(_ context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check archive file: %w", err)
+	}
+	return info.Size() > 0, nil
+}
+
+func (s *DirArchiveStore) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete archive file: %w", err)
+	}
+	return nil
+}
+
+// Size returns the total size of the stored blobs in bytes.
+func (s *DirArchiveStore) Size(_ context.Context) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(s.root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure archive directory: %w", err)
+	}
+	return total, nil
+}
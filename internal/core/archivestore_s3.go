@@ -0,0 +1,273 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// S3Config configures an S3-compatible archive store (AWS S3, MinIO,
+// Backblaze B2, Cloudflare R2, Garage, ...).
+type S3Config struct {
+	// Endpoint is the service URL, e.g. "https://s3.us-east-1.amazonaws.com"
+	// or "http://minio.lan:9000".
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every object key, e.g. "bookmarkd/".
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as endpoint/bucket/key instead of
+	// bucket.endpoint/key. Most self-hosted servers need this.
+	PathStyle bool
+	// Timeout bounds each request. If <= 0, DefaultResourceTimeout is used.
+	Timeout time.Duration
+}
+
+// S3ArchiveStore keeps blobs as objects in an S3-compatible bucket. Requests
+// are signed with AWS Signature Version 4 using only the standard library.
+type S3ArchiveStore struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	// now is overridable for tests.
+	now func() time.Time
+}
+
+// NewS3ArchiveStore validates cfg and returns a store using it.
+func NewS3ArchiveStore(cfg S3Config) (*S3ArchiveStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 archive store requires an endpoint and bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 archive store requires an access key and secret key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultResourceTimeout
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	return &S3ArchiveStore{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL returns the URL of the object holding key.
+func (s *S3ArchiveStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	object := s.cfg.Prefix + key
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		u.Path = basePath + "/" + s.cfg.Bucket + "/" + object
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = basePath + "/" + object
+	}
+	u.RawPath = ""
+	return &u
+}
+
+func (s *S3ArchiveStore) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	signV4(req, body, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, s.now())
+	return s.client.Do(req)
+}
+
+func (s *S3ArchiveStore) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	defer closeS3Body(resp)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 put %s: %s", key, s3Error(resp))
+	}
+	return nil
+}
+
+func (s *S3ArchiveStore) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	defer closeS3Body(resp)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("s3 get %s: %w", key, err)
+		}
+		return data, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", db.ErrBlobNotFound, key)
+	default:
+		return nil, fmt.Errorf("s3 get %s: %s", key, s3Error(resp))
+	}
+}
+
+// Has reports whether a non-empty object is stored under key, with a HEAD
+// request rather than downloading it.
+func (s *S3ArchiveStore) Has(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, fmt.Errorf("s3 head %s: %w", key, err)
+	}
+	defer closeS3Body(resp)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// ContentLength is -1 when the server doesn't say.
+		return resp.ContentLength != 0, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("s3 head %s: %s", key, s3Error(resp))
+	}
+}
+
+func (s *S3ArchiveStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return fmt.Errorf("s3 delete %s: %w", key, err)
+	}
+	defer closeS3Body(resp)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("s3 delete %s: %s", key, s3Error(resp))
+	}
+}
+
+func closeS3Body(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("failed to close response body: %v", err)
+	}
+}
+
+// s3Error summarizes an error response, including the start of the XML body
+// which carries the S3 error code.
+func s3Error(resp *http.Response) string {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if len(msg) == 0 {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// signV4 adds AWS Signature Version 4 headers for the S3 service to req.
+// It signs the host, range, content-type and all x-amz-* headers.
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// s3EscapePath URI-encodes each path segment as SigV4 requires for S3
+// (every byte except unreserved characters, keeping '/').
+func s3EscapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
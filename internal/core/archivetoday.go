@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// DefaultArchiveTodayURL is archive.today, which snapshots pages submitted
+// to its /submit/ endpoint.
+const DefaultArchiveTodayURL = "https://archive.ph"
+
+// ErrNoArchiveTodaySnapshot is returned by ArchiveTodayClient.Submit when
+// archive.today took the page but didn't say where its snapshot is.
+var ErrNoArchiveTodaySnapshot = errors.New("no archive.today snapshot found")
+
+// ArchiveTodayClient submits pages to archive.today, as a redundant off-site
+// archive of bookmarks. Nothing is sent to archive.today unless a client is
+// set up.
+type ArchiveTodayClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewArchiveTodayClient returns a client for the archive.today mirror at
+// baseURL (DefaultArchiveTodayURL if empty).
+func NewArchiveTodayClient(baseURL string) *ArchiveTodayClient {
+	if baseURL == "" {
+		baseURL = DefaultArchiveTodayURL
+	}
+	return &ArchiveTodayClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{
+			Timeout: DefaultArchiveTodayTimeout,
+			// The snapshot's address is in the redirect itself.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Submit asks archive.today to capture pageURL and returns the snapshot's
+// URL. archive.today redirects to the snapshot when it has a recent one, or
+// points at the capture in progress with a Refresh header; either way the
+// snapshot's final address is returned.
+func (c *ArchiveTodayClient) Submit(ctx context.Context, pageURL string) (string, error) {
+	if c == nil {
+		return "", errors.New("archive.today is not enabled")
+	}
+	form := url.Values{"url": {pageURL}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/submit/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive.today request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit to archive.today: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("failed to submit to archive.today: %s", resp.Status)
+	}
+
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		// Refresh: 0;url=https://archive.ph/wip/AbCd1
+		if _, target, ok := strings.Cut(resp.Header.Get("Refresh"), "url="); ok {
+			loc = strings.TrimSpace(target)
+		}
+	}
+	if loc == "" {
+		return "", ErrNoArchiveTodaySnapshot
+	}
+	u, err := resp.Request.URL.Parse(loc)
+	if err != nil {
+		return "", fmt.Errorf("invalid archive.today snapshot %q: %w", loc, err)
+	}
+	// A capture in progress lives under /wip/ until it's done.
+	u.Path = strings.Replace(u.Path, "/wip/", "/", 1)
+	return u.String(), nil
+}
+
+// SubmitToArchiveToday submits b's page to archive.today and stores the
+// snapshot's URL.
+func SubmitToArchiveToday(ctx context.Context, database *db.DB, client *ArchiveTodayClient, b db.Bookmark) (string, error) {
+	snapshot, err := client.Submit(ctx, b.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := database.SetArchiveTodayURL(b.ID, snapshot); err != nil {
+		return "", err
+	}
+	return snapshot, nil
+}
+
+// ArchiveTodaySubmitter submits new bookmarks to archive.today in the
+// background, a few at a time.
+type ArchiveTodaySubmitter struct {
+	db      *db.DB
+	client  *ArchiveTodayClient
+	domains DomainRules
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewArchiveTodaySubmitter creates an ArchiveTodaySubmitter running at most
+// workers submissions at once (DefaultArchiveTodayWorkers if zero). Pages
+// domains keep out of archiving aren't submitted.
+func NewArchiveTodaySubmitter(database *db.DB, client *ArchiveTodayClient, domains DomainRules, workers int) *ArchiveTodaySubmitter {
+	if workers <= 0 {
+		workers = DefaultArchiveTodayWorkers
+	}
+	return &ArchiveTodaySubmitter{
+		db:      database,
+		client:  client,
+		domains: domains,
+		sem:     make(chan struct{}, workers),
+	}
+}
+
+// Submit starts submitting b to archive.today unless the domain rules keep
+// it out, and reports whether it did. It doesn't wait for the submission;
+// failures are logged.
+func (s *ArchiveTodaySubmitter) Submit(b db.Bookmark) bool {
+	if !s.domains.Allows(b.URL) {
+		return false
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sem <- struct{}{}
+		defer func() { <-s.sem }()
+		snapshot, err := SubmitToArchiveToday(context.Background(), s.db, s.client, b)
+		if err != nil {
+			log.Printf("Failed to submit bookmark id=%d url=%s to archive.today: %v", b.ID, b.URL, err)
+			return
+		}
+		log.Printf("Submitted bookmark id=%d to archive.today: %s", b.ID, snapshot)
+	}()
+	return true
+}
+
+// Wait blocks until every started submission has finished.
+func (s *ArchiveTodaySubmitter) Wait() {
+	s.wg.Wait()
+}
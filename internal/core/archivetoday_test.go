@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// newArchiveTodayTestServer serves a /submit/ that redirects to an existing
+// snapshot of the URLs in existing and answers others with a Refresh header
+// pointing at a capture in progress. It records the URLs submitted.
+func newArchiveTodayTestServer(t *testing.T, existing map[string]string) (*ArchiveTodayClient, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var submitted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/submit/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := r.FormValue("url")
+		mu.Lock()
+		submitted = append(submitted, page)
+		mu.Unlock()
+		if snapshot, ok := existing[page]; ok {
+			http.Redirect(w, r, snapshot, http.StatusFound)
+			return
+		}
+		w.Header().Set("Refresh", "0;url=/wip/NeW01")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+	return NewArchiveTodayClient(ts.URL + "/"), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), submitted...)
+	}
+}
+
+func TestArchiveTodayClient(t *testing.T) {
+	client, _ := newArchiveTodayTestServer(t, map[string]string{
+		"https://example.com/": "https://archive.ph/OlD01",
+	})
+	ctx := context.Background()
+
+	snapshot, err := client.Submit(ctx, "https://example.com/")
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if snapshot != "https://archive.ph/OlD01" {
+		t.Errorf("expected the existing snapshot, got %q", snapshot)
+	}
+	snapshot, err = client.Submit(ctx, "https://example.com/new")
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if u := client.baseURL + "/NeW01"; snapshot != u {
+		t.Errorf("expected %q without /wip/, got %q", u, snapshot)
+	}
+
+	var off *ArchiveTodayClient
+	if _, err := off.Submit(ctx, "https://example.com/"); err == nil {
+		t.Error("expected a nil client to refuse submissions")
+	}
+}
+
+func TestArchiveTodaySubmitter(t *testing.T) {
+	database := newQueueTestDB(t)
+	client, submitted := newArchiveTodayTestServer(t, nil)
+	submitter := NewArchiveTodaySubmitter(database, client, DomainRules{Deny: []string{"blocked.example.org"}}, 0)
+
+	id, err := database.AddBookmark("https://example.com/page", "Page")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+	if !submitter.Submit(b) {
+		t.Fatal("expected the bookmark to be submitted")
+	}
+	// Pages the archive rules keep out aren't sent to archive.today.
+	if submitter.Submit(db.Bookmark{ID: id + 1, URL: "https://blocked.example.org/"}) {
+		t.Error("expected a blocked page not to be submitted")
+	}
+	submitter.Wait()
+
+	if got := submitted(); len(got) != 1 || got[0] != b.URL {
+		t.Errorf("expected only %q to be submitted, got %v", b.URL, got)
+	}
+	archive, err := database.GetBookmarkArchive(id)
+	if err != nil {
+		t.Fatalf("failed to get archive: %v", err)
+	}
+	if want := client.baseURL + "/NeW01"; archive.ArchiveTodayURL != want {
+		t.Errorf("expected snapshot %q, got %q", want, archive.ArchiveTodayURL)
+	}
+}
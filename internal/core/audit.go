@@ -0,0 +1,278 @@
+package core
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Kinds of AuditIssue.
+const (
+	// AuditMissingBlob is a snapshot whose HTML or downloaded file isn't in
+	// the archive store, or is empty there.
+	AuditMissingBlob = "missing-blob"
+	// AuditCorruptBlob is a snapshot whose HTML doesn't decompress, as a
+	// blob cut short by a crash doesn't.
+	AuditCorruptBlob = "corrupt-blob"
+	// AuditEmptyHTML is a snapshot with no HTML or only whitespace.
+	AuditEmptyHTML = "empty-html"
+	// AuditTruncatedDataURI is a snapshot with inlined resources whose
+	// base64 data is cut short or doesn't decode.
+	AuditTruncatedDataURI = "truncated-data-uri"
+	// AuditMissingStatus is a bookmark whose archive status columns
+	// contradict each other or its snapshots.
+	AuditMissingStatus = "missing-status"
+	// AuditOrphanRows are rows of a deleted bookmark; Count says how many.
+	AuditOrphanRows = "orphan-rows"
+	// AuditUnreferencedBlobs are archive_blobs rows no snapshot refers to;
+	// Count says how many.
+	AuditUnreferencedBlobs = "unreferenced-blobs"
+)
+
+// auditBlobGrace is how old an unreferenced blob must be before the audit
+// counts it: blobs are stored just before the snapshot that refers to them.
+const auditBlobGrace = time.Hour
+
+// AuditOptions describes an archive integrity audit.
+type AuditOptions struct {
+	// Repair queues bookmarks whose latest snapshot or archive status is
+	// broken to archive again and deletes orphaned rows and unreferenced
+	// blobs. Without it the audit only reports.
+	Repair bool
+	// Now is the time blob ages are measured from. If zero, time.Now() is used.
+	Now time.Time
+}
+
+// AuditIssue is one problem AuditArchives found.
+type AuditIssue struct {
+	Kind       string `json:"kind"`
+	BookmarkID int64  `json:"bookmark_id,omitempty"`
+	VersionID  int64  `json:"version_id,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Detail     string `json:"detail"`
+	// Count is set for issues covering many rows.
+	Count int `json:"count,omitempty"`
+}
+
+// AuditResult reports what AuditArchives found and, with Repair, did.
+type AuditResult struct {
+	Repair bool `json:"repair"`
+	// Checked is how many snapshots were read.
+	Checked int          `json:"checked"`
+	Issues  []AuditIssue `json:"issues"`
+	// Queued are the bookmarks queued to archive again.
+	Queued []int64 `json:"queued,omitempty"`
+	// Deleted is how many orphaned rows and unreferenced blobs were deleted.
+	Deleted int `json:"deleted"`
+	// Failed counts snapshots that couldn't be read and repairs that
+	// failed; each is logged.
+	Failed int `json:"failed"`
+}
+
+// AuditArchives reads every stored snapshot and checks it for missing,
+// corrupt or empty HTML, missing downloaded files and truncated base64
+// data URIs, then checks archive status columns and looks for rows left
+// behind by deleted bookmarks and unreferenced blobs. Old snapshots are
+// only reported: archiving again replaces what a bookmark shows, not its
+// history. Unlike Reconcile it reads every blob, so it is only run on
+// demand.
+func AuditArchives(ctx context.Context, database *db.DB, opts AuditOptions) (AuditResult, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	res := AuditResult{Repair: opts.Repair, Issues: []AuditIssue{}}
+	requeue := map[int64]bool{}
+	var order []int64
+	flag := func(issue AuditIssue, repair bool) {
+		res.Issues = append(res.Issues, issue)
+		if repair && !requeue[issue.BookmarkID] {
+			requeue[issue.BookmarkID] = true
+			order = append(order, issue.BookmarkID)
+		}
+	}
+
+	refs, err := database.ListArchiveContent()
+	if err != nil {
+		return res, err
+	}
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Checked++
+		issue, err := auditSnapshot(ctx, database, ref)
+		if err != nil {
+			res.Failed++
+			log.Printf("Audit: failed to read snapshot %d of bookmark %d: %v", ref.VersionID, ref.BookmarkID, err)
+			continue
+		}
+		if issue != nil {
+			flag(*issue, ref.Latest)
+		}
+	}
+
+	problems, err := database.ListArchiveStatusProblems()
+	if err != nil {
+		return res, err
+	}
+	for _, p := range problems {
+		flag(AuditIssue{Kind: AuditMissingStatus, BookmarkID: p.BookmarkID, URL: p.URL, Detail: p.Problem}, true)
+	}
+
+	orphans, err := database.CountOrphanRows()
+	if err != nil {
+		return res, err
+	}
+	for _, o := range orphans {
+		res.Issues = append(res.Issues, AuditIssue{Kind: AuditOrphanRows, Count: o.Count,
+			Detail: fmt.Sprintf("%d row(s) in %s belong to deleted bookmarks", o.Count, o.Table)})
+	}
+	blobCutoff := now.Add(-auditBlobGrace)
+	blobs, size, err := database.CountUnreferencedBlobs(blobCutoff)
+	if err != nil {
+		return res, err
+	}
+	if blobs > 0 {
+		res.Issues = append(res.Issues, AuditIssue{Kind: AuditUnreferencedBlobs, Count: blobs,
+			Detail: fmt.Sprintf("%d stored archive blob(s) (%d bytes) belong to no snapshot", blobs, size)})
+	}
+
+	if !opts.Repair {
+		return res, auditErr(res)
+	}
+	for _, id := range order {
+		if _, err := database.EnqueueJob(db.JobKindArchive, id, ""); err != nil {
+			res.Failed++
+			log.Printf("Audit: failed to queue bookmark %d: %v", id, err)
+			continue
+		}
+		res.Queued = append(res.Queued, id)
+	}
+	if len(orphans) > 0 {
+		deleted, err := database.DeleteOrphanRows()
+		for _, d := range deleted {
+			res.Deleted += d.Count
+		}
+		if err != nil {
+			return res, err
+		}
+	}
+	if blobs > 0 {
+		n, err := database.DeleteUnreferencedBlobs(blobCutoff)
+		if err != nil {
+			return res, err
+		}
+		res.Deleted += int(n)
+	}
+	return res, auditErr(res)
+}
+
+// auditErr reports failures to read snapshots or make repairs; the issues
+// found are the result, not an error.
+func auditErr(res AuditResult) error {
+	if res.Failed > 0 {
+		return fmt.Errorf("audit finished with %d failure(s)", res.Failed)
+	}
+	return nil
+}
+
+// auditSnapshot returns what is wrong with a snapshot, or nil if nothing
+// is. Errors are for snapshots that couldn't be checked, such as an
+// unreachable store.
+func auditSnapshot(ctx context.Context, database *db.DB, ref db.ArchiveContentRef) (*AuditIssue, error) {
+	issue := func(kind, detail string) *AuditIssue {
+		return &AuditIssue{Kind: kind, BookmarkID: ref.BookmarkID, VersionID: ref.VersionID, URL: ref.URL, Detail: detail}
+	}
+	if ref.BlobHash == "" {
+		return issue(AuditEmptyHTML, "snapshot has no HTML"), nil
+	}
+	version, err := database.GetArchiveVersion(ref.BookmarkID, ref.VersionID)
+	switch {
+	case errors.Is(err, db.ErrBlobNotFound):
+		return issue(AuditMissingBlob, "HTML is missing from the archive store"), nil
+	case corruptBlob(err):
+		return issue(AuditCorruptBlob, err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+	if strings.TrimSpace(version.ArchivedHTML) == "" {
+		return issue(AuditEmptyHTML, "HTML is empty"), nil
+	}
+	if ref.DownloadHash != "" {
+		ok, err := hasArchiveBlob(ctx, database.BlobStore(), ref.DownloadHash)
+		if err == nil && !ok {
+			ok, err = hasArchiveBlob(ctx, database.SQLiteBlobStore(), ref.DownloadHash)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return issue(AuditMissingBlob, "downloaded file is missing from the archive store"), nil
+		}
+	}
+	if n := truncatedDataURIs(version.ArchivedHTML); n > 0 {
+		return issue(AuditTruncatedDataURI, fmt.Sprintf("%d inlined resource(s) have truncated or invalid base64 data", n)), nil
+	}
+	return nil, nil
+}
+
+// corruptBlob reports whether err is a blob failing to decompress.
+func corruptBlob(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.As(err, &corrupt)
+}
+
+// dataURIPattern matches the start of a base64 data URI, up to its data.
+var dataURIPattern = regexp.MustCompile(`(?i)data:[a-z0-9.+-]+/[a-z0-9.+-]+(?:;[a-z0-9-]+=[^;,"'\s)]*)*;base64,`)
+
+// truncatedDataURIs counts the base64 data URIs in page whose data doesn't
+// decode or runs to the end of the page, as inlined resources cut off by
+// an interrupted capture or write do. Whitespace in the data is ignored,
+// as browsers ignore it, and so is missing padding.
+func truncatedDataURIs(page string) int {
+	n := 0
+	for _, loc := range dataURIPattern.FindAllStringIndex(page, -1) {
+		end := loc[1]
+		for end < len(page) && isBase64Byte(page[end]) {
+			end++
+		}
+		if end == len(page) {
+			n++
+			continue
+		}
+		data := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+				return -1
+			}
+			return r
+		}, page[loc[1]:end])
+		if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+			if _, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// isBase64Byte reports whether c may appear in the data of a base64 data
+// URI.
+func isBase64Byte(c byte) bool {
+	switch {
+	case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("+/= \t\n\r\f", c) >= 0
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestAuditArchives(t *testing.T) {
+	database := newQueueTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	archive := func(url, html string, at time.Time) int64 {
+		t.Helper()
+		id, err := database.AddBookmark(url, "")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := database.SaveArchiveResult(id, at, &at, ArchiveStatusOK, "", url, html); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		return id
+	}
+	good := archive("https://good.example.com", `<p>good</p>`, now.Add(-time.Hour))
+	// A newer good snapshot over an older empty one.
+	if err := database.SaveArchiveResult(good, now, &now, ArchiveStatusOK, "", "", `<p>good</p><img src="data:image/png;base64,iVBORw0KGgo=">`); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	oldest := now.Add(-2 * time.Hour)
+	if err := database.SaveArchiveResult(good, oldest, &oldest, ArchiveStatusOK, "", "", "  "); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	truncated := archive("https://truncated.example.com", `<img src="data:image/png;base64,iVBORw0KG">`, now)
+	empty := archive("https://empty.example.com", "\n", now)
+	corrupt := archive("https://corrupt.example.com", "<p>corrupt</p>", now)
+	missing := archive("https://missing.example.com", "<p>missing</p>", now)
+	blobOf := func(id int64) string {
+		refs, err := database.ListArchiveContent()
+		if err != nil {
+			t.Fatalf("failed to list archive content: %v", err)
+		}
+		for _, r := range refs {
+			if r.BookmarkID == id {
+				return r.BlobHash
+			}
+		}
+		t.Fatalf("no snapshot for bookmark %d", id)
+		return ""
+	}
+	store := database.SQLiteBlobStore()
+	if err := store.Delete(ctx, blobOf(corrupt)); err != nil {
+		t.Fatalf("failed to delete blob: %v", err)
+	}
+	if err := store.Put(ctx, blobOf(corrupt), []byte("not gzip")); err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	if err := store.Delete(ctx, blobOf(missing)); err != nil {
+		t.Fatalf("failed to delete blob: %v", err)
+	}
+	noSnapshot, err := database.AddBookmark("https://none.example.com", "")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := database.SaveArchiveResult(noSnapshot, now, nil, ArchiveStatusOK, "", "", ""); err != nil {
+		t.Fatalf("failed to save archive status: %v", err)
+	}
+	if _, err := database.EnqueueJob(db.JobKindArchive, 9999, ""); err != nil {
+		t.Fatalf("failed to enqueue orphaned job: %v", err)
+	}
+	if err := store.Put(ctx, "stray", []byte("stray")); err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	// Long enough after the stray blob was stored to count it.
+	later := now.Add(2 * auditBlobGrace)
+
+	res, err := AuditArchives(ctx, database, AuditOptions{Now: later})
+	if err != nil {
+		t.Fatalf("AuditArchives() error = %v", err)
+	}
+	type found struct {
+		kind string
+		id   int64
+	}
+	var got []found
+	for _, issue := range res.Issues {
+		got = append(got, found{issue.Kind, issue.BookmarkID})
+	}
+	want := []found{
+		{AuditEmptyHTML, good},
+		{AuditTruncatedDataURI, truncated},
+		{AuditEmptyHTML, empty},
+		{AuditCorruptBlob, corrupt},
+		{AuditMissingBlob, missing},
+		{AuditMissingStatus, noSnapshot},
+		{AuditOrphanRows, 0},
+		{AuditUnreferencedBlobs, 0},
+	}
+	if res.Checked != 7 || !slices.Equal(got, want) {
+		t.Fatalf("AuditArchives() checked %d and found %v, want 7 and %v", res.Checked, got, want)
+	}
+	if res.Queued != nil || res.Deleted != 0 {
+		t.Errorf("expected an audit without repair to change nothing, got %+v", res)
+	}
+
+	res, err = AuditArchives(ctx, database, AuditOptions{Repair: true, Now: later})
+	if err != nil {
+		t.Fatalf("AuditArchives() error = %v", err)
+	}
+	// The good bookmark's broken snapshot isn't its latest.
+	if wantQueued := []int64{truncated, empty, corrupt, missing, noSnapshot}; !slices.Equal(res.Queued, wantQueued) {
+		t.Errorf("expected %v queued, got %v", wantQueued, res.Queued)
+	}
+	if res.Deleted != 2 {
+		t.Errorf("expected the orphaned job and the stray blob deleted, got %d", res.Deleted)
+	}
+	for _, id := range res.Queued {
+		if _, err := database.GetPendingJob(db.JobKindArchive, id); err != nil {
+			t.Errorf("expected bookmark %d to be queued: %v", id, err)
+		}
+	}
+	if orphans, _ := database.CountOrphanRows(); len(orphans) != 0 {
+		t.Errorf("expected no orphaned rows left, got %+v", orphans)
+	}
+}
+
+func TestTruncatedDataURIs(t *testing.T) {
+	tests := []struct {
+		page string
+		want int
+	}{
+		{`<img src="data:image/png;base64,iVBORw0KGgo=">`, 0},
+		{`<img src="data:image/gif;base64,R0lGODlhAQABAIAAAP///wAAACH5BAEAAAAALAAAAAABAAEAAAICRAEAOw">`, 0},
+		{"<style>a { background: url(data:image/png;charset=utf-8;base64,iVBO\n  Rw0KGgo=) }</style>", 0},
+		{`<img src="data:image/png;base64,iVBORw0KG">`, 1},
+		{`<img src="data:image/png;base64,iVBORw0KGgo=`, 1},
+		{`<img src="data:image/png;base64,iVBORw0KG"><img src="data:image/png;base64,iVBOR"> <a href="data:text/plain,plain">`, 2},
+	}
+	for _, tt := range tests {
+		if got := truncatedDataURIs(tt.page); got != tt.want {
+			t.Errorf("truncatedDataURIs(%q) = %d, want %d", tt.page, got, tt.want)
+		}
+	}
+}
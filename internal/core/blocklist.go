@@ -0,0 +1,401 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrResourceFiltered is returned for resource fetches a Blocklist refuses.
+var ErrResourceFiltered = errors.New("blocked by filter list")
+
+// builtinFilters is the built-in list of common analytics, ad and tracking
+// hosts, plus the usual ad slots and tracking pixels, in filter list syntax.
+const builtinFilters = `! Analytics
+||google-analytics.com^
+||googletagmanager.com^
+||googletagservices.com^
+||analytics.google.com^
+||scorecardresearch.com^
+||quantserve.com^
+||quantcount.com^
+||hotjar.com^
+||segment.com^
+||segment.io^
+||mixpanel.com^
+||amplitude.com^
+||chartbeat.com^
+||chartbeat.net^
+||nr-data.net^
+||js-agent.newrelic.com^
+||clarity.ms^
+||mc.yandex.ru^
+||stats.wp.com^
+||pixel.wp.com^
+||plausible.io^
+||static.cloudflareinsights.com^
+! Social tracking
+||connect.facebook.net^
+||facebook.com/tr^
+||analytics.twitter.com^
+||ads-twitter.com^
+||ads.linkedin.com^
+||snap.licdn.com^
+||analytics.tiktok.com^
+||bat.bing.com^
+! Ads
+||doubleclick.net^
+||googlesyndication.com^
+||googleadservices.com^
+||adservice.google.com^
+||amazon-adsystem.com^
+||adnxs.com^
+||criteo.com^
+||criteo.net^
+||taboola.com^
+||outbrain.com^
+||rubiconproject.com^
+||pubmatic.com^
+||openx.net^
+||moatads.com^
+||adsrvr.org^
+||casalemedia.com^
+! Ad slots and tracking pixels
+##.adsbygoogle
+##[id^="google_ads_"]
+##[id^="div-gpt-ad"]
+##img[width="1"][height="1"]
+##img[width="0"][height="0"]
+`
+
+// Blocklist decides which resources and elements to strip from archived
+// pages. Rules use the common subset of the Adblock Plus filter syntax that
+// EasyList and EasyPrivacy are written in:
+//
+//	||example.com^     URLs on example.com and its subdomains
+//	|https://x.test/   URLs starting with https://x.test/
+//	/banner/*.gif      URLs containing the pattern (* is a wildcard, ^ a separator)
+//	@@||cdn.test^      exception: never block what it matches
+//	##.ad-banner       remove elements matching a CSS selector
+//	! comment
+//
+// Of the $ options, only third-party is honoured; rules limited to some
+// sites with domain= are skipped, as are site-specific element rules
+// (example.com##.ad), regular expression rules and other extensions.
+type Blocklist struct {
+	// hosts are the rules that block a whole domain, by domain.
+	hosts map[string]filterRule
+	block []filterRule
+	allow []filterRule
+	// selectors match elements to remove.
+	selectors []cascadia.Selector
+}
+
+type filterRule struct {
+	// pattern is nil for a whole-domain rule.
+	pattern *regexp.Regexp
+	// thirdParty rules only block resources from other sites than the page.
+	thirdParty bool
+}
+
+// DefaultBlocklist returns the built-in blocklist.
+func DefaultBlocklist() *Blocklist {
+	bl := &Blocklist{}
+	if err := bl.Add(strings.NewReader(builtinFilters)); err != nil {
+		panic(err)
+	}
+	return bl
+}
+
+// LoadBlocklist returns a blocklist of the rules in the given filter list
+// files, added to the built-in list if builtin is set.
+func LoadBlocklist(builtin bool, paths ...string) (*Blocklist, error) {
+	bl := &Blocklist{}
+	if builtin {
+		bl = DefaultBlocklist()
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open filter list: %w", err)
+		}
+		err = bl.Add(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read filter list %s: %w", path, err)
+		}
+	}
+	return bl, nil
+}
+
+// Add adds the rules in a filter list. Lines it doesn't support are
+// skipped.
+func (bl *Blocklist) Add(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		bl.addRule(strings.TrimSpace(scanner.Text()))
+	}
+	return scanner.Err()
+}
+
+func (bl *Blocklist) addRule(line string) {
+	if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+		return
+	}
+	if domains, selector, ok := strings.Cut(line, "##"); ok {
+		if domains != "" {
+			return
+		}
+		if sel, err := cascadia.Compile(selector); err == nil {
+			bl.selectors = append(bl.selectors, sel)
+		}
+		return
+	}
+	if strings.Contains(line, "#@#") || strings.Contains(line, "#?#") || strings.Contains(line, "#$#") {
+		return
+	}
+
+	exception := strings.HasPrefix(line, "@@")
+	line = strings.TrimPrefix(line, "@@")
+	var rule filterRule
+	if pattern, options, ok := strings.Cut(line, "$"); ok {
+		line = pattern
+		for _, opt := range strings.Split(options, ",") {
+			switch {
+			case opt == "third-party":
+				rule.thirdParty = true
+			case strings.HasPrefix(opt, "domain="):
+				return
+			}
+		}
+	}
+	if line == "" || len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+		return
+	}
+
+	// Most rules block a whole domain; look those up instead of matching.
+	if host, ok := strings.CutPrefix(line, "||"); ok && !exception {
+		host = strings.TrimSuffix(host, "^")
+		if host != "" && !strings.ContainsAny(host, "/*^|:") {
+			if bl.hosts == nil {
+				bl.hosts = map[string]filterRule{}
+			}
+			bl.hosts[strings.ToLower(host)] = rule
+			return
+		}
+	}
+	re, err := regexp.Compile(filterPattern(line))
+	if err != nil {
+		return
+	}
+	rule.pattern = re
+	if exception {
+		bl.allow = append(bl.allow, rule)
+	} else {
+		bl.block = append(bl.block, rule)
+	}
+}
+
+// filterPattern translates a filter's URL pattern into a regular expression.
+func filterPattern(p string) string {
+	var b strings.Builder
+	b.WriteString("(?i)")
+	if rest, ok := strings.CutPrefix(p, "||"); ok {
+		b.WriteString(`^[a-z][a-z0-9+.-]*://([^/?#]*\.)?`)
+		p = rest
+	} else if rest, ok := strings.CutPrefix(p, "|"); ok {
+		b.WriteString("^")
+		p = rest
+	}
+	end := strings.HasSuffix(p, "|")
+	p = strings.TrimSuffix(p, "|")
+	for _, r := range p {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '^':
+			b.WriteString(`(?:[^\w.%-]|$)`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if end {
+		b.WriteString("$")
+	}
+	return b.String()
+}
+
+// Blocks reports whether the blocklist blocks rawURL on the page at pageURL.
+// URLs that aren't http(s) are never blocked.
+func (bl *Blocklist) Blocks(rawURL, pageURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	thirdParty := !sameSite(host, pageURL)
+	applies := func(r filterRule) bool {
+		return (!r.thirdParty || thirdParty) && (r.pattern == nil || r.pattern.MatchString(rawURL))
+	}
+
+	blocked := false
+	for h := host; h != ""; {
+		if r, ok := bl.hosts[h]; ok && applies(r) {
+			blocked = true
+			break
+		}
+		_, h, _ = strings.Cut(h, ".")
+	}
+	for i := 0; !blocked && i < len(bl.block); i++ {
+		blocked = applies(bl.block[i])
+	}
+	if !blocked {
+		return false
+	}
+	for _, r := range bl.allow {
+		if applies(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSite reports whether host belongs to the same registrable domain as
+// pageURL's.
+func sameSite(host, pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	site := func(h string) string {
+		h = strings.TrimSuffix(strings.ToLower(h), ".")
+		if s, err := publicsuffix.EffectiveTLDPlusOne(h); err == nil {
+			return s
+		}
+		return h
+	}
+	return site(host) == site(u.Hostname())
+}
+
+// trackerURLAttrs are the attributes elements load resources from.
+var trackerURLAttrs = map[string]string{
+	"script": "src",
+	"img":    "src",
+	"iframe": "src",
+	"frame":  "src",
+	"embed":  "src",
+	"source": "src",
+	"object": "data",
+	"link":   "href",
+}
+
+// scriptURLPattern finds URLs in inline scripts and <noscript> contents.
+var scriptURLPattern = regexp.MustCompile(`(?i)(?:https?:)?//[a-z0-9.-]+\.[a-z]{2,}[^\s"'<>)\\]*`)
+
+// StripTrackers removes what a blocklist matches from an HTML document:
+// elements loading a blocked URL, inline scripts and <noscript> blocks
+// that refer to one (analytics snippets and their fallback pixels), and
+// elements matching its selectors. pageURL resolves relative URLs. It
+// returns the document and how many elements it removed.
+func StripTrackers(rawHTML, pageURL string, bl *Blocklist) (string, int, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	blocked := func(ref string) bool {
+		ref = strings.TrimSpace(ref)
+		if strings.HasPrefix(ref, "//") {
+			ref = base.Scheme + ":" + ref
+		}
+		return ref != "" && bl.Blocks(resolveURL(base, ref), pageURL)
+	}
+	mentionsBlocked := func(text string) bool {
+		for _, ref := range scriptURLPattern.FindAllString(text, -1) {
+			if blocked(ref) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var remove []*html.Node
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		name := goquery.NodeName(s)
+		if attr, ok := trackerURLAttrs[name]; ok {
+			if ref, ok := s.Attr(attr); ok && blocked(ref) {
+				remove = append(remove, s.Nodes...)
+				return
+			}
+		}
+		if name == "noscript" || (name == "script" && s.AttrOr("src", "") == "") {
+			if mentionsBlocked(s.Text()) {
+				remove = append(remove, s.Nodes...)
+			}
+		}
+	})
+	for _, sel := range bl.selectors {
+		remove = append(remove, doc.FindMatcher(sel).Nodes...)
+	}
+
+	// Elements inside another one being removed go with it, and aren't
+	// counted.
+	matched := map[*html.Node]bool{}
+	for _, n := range remove {
+		matched[n] = true
+	}
+	removed := 0
+	for _, n := range remove {
+		if n.Parent == nil || insideAny(n.Parent, matched) {
+			continue
+		}
+		n.Parent.RemoveChild(n)
+		removed++
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to serialize HTML: %w", err)
+	}
+	return out, removed, nil
+}
+
+// insideAny reports whether n or one of its ancestors is in nodes.
+func insideAny(n *html.Node, nodes map[*html.Node]bool) bool {
+	for ; n != nil; n = n.Parent {
+		if nodes[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// blocklistTransport refuses requests, including redirect hops, for URLs
+// its blocklist blocks on pageURL.
+type blocklistTransport struct {
+	base      http.RoundTripper
+	blocklist *Blocklist
+	pageURL   string
+}
+
+func (t blocklistTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.blocklist.Blocks(req.URL.String(), t.pageURL) {
+		return nil, fmt.Errorf("%w: %s", ErrResourceFiltered, req.URL.Redacted())
+	}
+	return t.base.RoundTrip(req)
+}
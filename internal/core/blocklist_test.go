@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlocklistBlocks(t *testing.T) {
+	bl := DefaultBlocklist()
+	if err := bl.Add(strings.NewReader(`! custom rules
+[Adblock Plus 2.0]
+/banners/*.gif
+|http://plain.example/track
+||cdn.example^$third-party
+||widgets.example^$domain=news.example
+@@||google-analytics.com/allowed.js
+/^regex$/
+example.org##.sponsored
+`)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	page := "https://www.example.com/article"
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://www.google-analytics.com/analytics.js", true},
+		{"https://google-analytics.com/collect?v=1", true},
+		{"https://notgoogle-analytics.com/a.js", false},
+		{"https://www.facebook.com/tr?id=1&ev=PageView", true},
+		{"https://www.facebook.com/translate", false},
+		{"https://example.com/static/banners/top.gif", true},
+		{"https://example.com/static/banners/top.png", false},
+		{"http://plain.example/track.gif", true},
+		{"https://plain.example/track.gif", false},
+		{"https://cdn.example/app.js", true},
+		{"https://widgets.example/w.js", false},
+		{"https://www.google-analytics.com/allowed.js", false},
+		{"https://www.example.com/app.js", false},
+		{"data:image/gif;base64,R0lGOD", false},
+	}
+	for _, tt := range tests {
+		if got := bl.Blocks(tt.url, page); got != tt.want {
+			t.Errorf("Blocks(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	// third-party rules leave the site's own resources alone.
+	if bl.Blocks("https://cdn.example/app.js", "https://www.cdn.example/") {
+		t.Error("expected a first-party resource not to be blocked by a third-party rule")
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte("||ads.example^\n"), 0o644); err != nil {
+		t.Fatalf("failed to write filter list: %v", err)
+	}
+	bl, err := LoadBlocklist(false, path)
+	if err != nil {
+		t.Fatalf("LoadBlocklist() error = %v", err)
+	}
+	if !bl.Blocks("https://ads.example/a.js", "https://example.com/") {
+		t.Error("expected the list's rule to apply")
+	}
+	if bl.Blocks("https://www.google-analytics.com/analytics.js", "https://example.com/") {
+		t.Error("expected the built-in list to be left out")
+	}
+	if _, err := LoadBlocklist(true, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing filter list")
+	}
+}
+
+func TestStripTrackers(t *testing.T) {
+	page := `<html><head>
+<script async src="https://www.googletagmanager.com/gtag/js?id=G-1"></script>
+<script>window.dataLayer = []; gtag('config', 'G-1');
+(function(){var s=document.createElement('script');s.src='//connect.facebook.net/en_US/fbevents.js';})();</script>
+<script src="/app.js"></script>
+<script>console.log("hello");</script>
+<link rel="preconnect" href="https://stats.wp.com">
+</head><body>
+<p>Article text</p>
+<img src="/photo.jpg" width="600" height="400">
+<img src="https://px.example/p.gif" width="1" height="1" alt="">
+<ins class="adsbygoogle"><iframe src="https://googleads.g.doubleclick.net/pagead/ads"></iframe></ins>
+<noscript><img src="https://www.facebook.com/tr?id=1&ev=PageView"></noscript>
+<noscript>Please enable JavaScript.</noscript>
+</body></html>`
+
+	out, n, err := StripTrackers(page, "https://www.example.com/article", DefaultBlocklist())
+	if err != nil {
+		t.Fatalf("StripTrackers() error = %v", err)
+	}
+	for _, gone := range []string{"googletagmanager", "fbevents", "stats.wp.com", "px.example", "adsbygoogle", "doubleclick", "facebook.com/tr"} {
+		if strings.Contains(out, gone) {
+			t.Errorf("expected %s to be stripped, got:\n%s", gone, out)
+		}
+	}
+	for _, kept := range []string{"/app.js", `console.log("hello")`, "/photo.jpg", "Article text", "Please enable JavaScript."} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %s to be kept, got:\n%s", kept, out)
+		}
+	}
+	// The ad's iframe goes with its <ins> and isn't counted twice.
+	if n != 6 {
+		t.Errorf("expected 6 elements removed, got %d", n)
+	}
+}
+
+func TestInlineResources_Blocklist(t *testing.T) {
+	var fetched []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write([]byte("GIF89a"))
+	}))
+	defer ts.Close()
+
+	bl := &Blocklist{}
+	if err := bl.Add(strings.NewReader("/track/*\n")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	opts := DefaultInlineOptions(ts.URL + "/")
+	opts.Blocklist = bl
+	html := `<html><body><img src="/track/pixel.gif"><div style="background: url(/track/bg.gif)"></div><img src="/photo.gif"></body></html>`
+	out, err := InlineResources(context.Background(), html, opts)
+	if err != nil {
+		t.Fatalf("InlineResources() error = %v", err)
+	}
+	if len(fetched) != 1 || fetched[0] != "/photo.gif" {
+		t.Errorf("expected only the photo to be fetched, got %v", fetched)
+	}
+	if !strings.Contains(out, `<img src="/track/pixel.gif"/>`) {
+		t.Errorf("expected the blocked image to keep its URL, got %s", out)
+	}
+}
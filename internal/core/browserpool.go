@@ -0,0 +1,182 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrBrowserPoolClosed is returned for tabs asked of a closed BrowserPool.
+var ErrBrowserPoolClosed = errors.New("browser pool is closed")
+
+// BrowserPoolOptions configure a BrowserPool.
+type BrowserPoolOptions struct {
+	// ChromePath and Headless are as in ArchiveOptions; the pool's browser
+	// is launched with these, whatever the archives it serves ask for.
+	ChromePath string
+	Headless   bool
+	// MaxPages is how many pages a browser archives before it is replaced
+	// by a fresh one, which bounds what a long-running Chrome leaks. If <=
+	// 0, DefaultBrowserPoolPages is used.
+	MaxPages int
+}
+
+// BrowserPool shares one Chrome process between archives instead of
+// launching one per page, which dominates archive latency and memory when
+// workers are busy. Each archive gets a tab of its own in a fresh browser
+// context, so pages don't see each other's cookies, cache or downloads.
+//
+// The browser is launched with the first tab, relaunched if it dies, and
+// recycled after MaxPages tabs: new tabs go to a new browser and the old one
+// is closed once its last tab is. A BrowserPool is safe for concurrent use;
+// a nil one launches a browser per tab.
+type BrowserPool struct {
+	opts BrowserPoolOptions
+
+	mu       sync.Mutex
+	current  *pooledBrowser
+	browsers map[*pooledBrowser]struct{}
+	closed   bool
+}
+
+// pooledBrowser is one Chrome process of a pool.
+type pooledBrowser struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// pages counts the tabs opened in it; open the ones still open.
+	pages, open int
+	retired     bool
+}
+
+// NewBrowserPool returns a pool; no browser is launched until a tab is
+// needed.
+func NewBrowserPool(opts BrowserPoolOptions) *BrowserPool {
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultBrowserPoolPages
+	}
+	return &BrowserPool{opts: opts, browsers: map[*pooledBrowser]struct{}{}}
+}
+
+// tab opens a tab in the pool's browser, launching one if need be. The tab
+// is closed when ctx is done or release is called, whichever comes first;
+// release must be called.
+func (p *BrowserPool) tab(ctx context.Context) (context.Context, func(), error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, nil, ErrBrowserPoolClosed
+	}
+	if p.current != nil && p.current.ctx.Err() != nil {
+		// Chrome exited or crashed; its open tabs fail on their own.
+		log.Printf("Pooled browser exited; launching a new one")
+		p.retire(p.current)
+	}
+	if p.current == nil {
+		b, err := p.launch()
+		if err != nil {
+			p.mu.Unlock()
+			return nil, nil, err
+		}
+		p.current = b
+	}
+	b := p.current
+	b.pages++
+	b.open++
+	if b.pages >= p.opts.MaxPages {
+		log.Printf("Pooled browser archived %d pages; the next archive gets a new one", b.pages)
+		p.retire(b)
+	}
+	p.mu.Unlock()
+
+	tabCtx, cancelTab := chromedp.NewContext(b.ctx, chromedp.WithNewBrowserContext())
+	stop := context.AfterFunc(ctx, cancelTab)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			stop()
+			cancelTab()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			b.open--
+			if b.retired && b.open == 0 {
+				p.closeBrowser(b)
+			}
+		})
+	}
+	return tabCtx, release, nil
+}
+
+// launch starts a browser. The caller holds p.mu.
+func (p *BrowserPool) launch() (*pooledBrowser, error) {
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), chromeAllocatorOptions(p.opts.ChromePath, p.opts.Headless)...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to launch pooled browser: %w", err)
+	}
+	b := &pooledBrowser{ctx: browserCtx, cancel: cancel}
+	p.browsers[b] = struct{}{}
+	return b, nil
+}
+
+// retire stops handing out tabs of b, closing it now if none are open. The
+// caller holds p.mu.
+func (p *BrowserPool) retire(b *pooledBrowser) {
+	b.retired = true
+	if p.current == b {
+		p.current = nil
+	}
+	if b.open == 0 {
+		p.closeBrowser(b)
+	}
+}
+
+// closeBrowser exits b's Chrome. The caller holds p.mu.
+func (p *BrowserPool) closeBrowser(b *pooledBrowser) {
+	if _, ok := p.browsers[b]; !ok {
+		return
+	}
+	delete(p.browsers, b)
+	b.cancel()
+}
+
+// Close exits the pool's browsers, closing any tabs still open, and
+// refuses new tabs. It is safe to call on a nil pool.
+func (p *BrowserPool) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.current = nil
+	for b := range p.browsers {
+		p.closeBrowser(b)
+	}
+}
+
+// chromeAllocatorOptions are the flags Chrome is launched with.
+func chromeAllocatorOptions(chromePath string, headless bool) []chromedp.ExecAllocatorOption {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	opts = append(opts,
+		chromedp.NoDefaultBrowserCheck,
+		chromedp.NoFirstRun,
+	)
+	if chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(chromePath))
+	}
+	if headless {
+		opts = append(opts, chromedp.Headless)
+	} else {
+		opts = append(opts, chromedp.Flag("headless", false))
+	}
+	return opts
+}
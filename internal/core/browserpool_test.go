@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrowserPool_Close(t *testing.T) {
+	var nilPool *BrowserPool
+	nilPool.Close()
+
+	pool := NewBrowserPool(BrowserPoolOptions{})
+	if pool.opts.MaxPages != DefaultBrowserPoolPages {
+		t.Errorf("expected MaxPages to default to %d, got %d", DefaultBrowserPoolPages, pool.opts.MaxPages)
+	}
+	pool.Close()
+	if _, _, err := pool.tab(context.Background()); !errors.Is(err, ErrBrowserPoolClosed) {
+		t.Errorf("expected ErrBrowserPoolClosed, got %v", err)
+	}
+}
+
+func TestArchiveBookmark_BrowserPool(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+	if !chromeInstalled("") {
+		t.Skip("Chrome not available")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Page ` + r.URL.Path + `</title></head><body>ok</body></html>`))
+	}))
+	defer srv.Close()
+
+	pool := NewBrowserPool(BrowserPoolOptions{Headless: true, MaxPages: 2})
+	defer pool.Close()
+
+	var first *pooledBrowser
+	for i, path := range []string{"/a", "/b", "/c"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result, err := ArchiveBookmark(ctx, srv.URL+path, ArchiveOptions{Headless: true, Browsers: pool, WaitStrategy: WaitLoad})
+		cancel()
+		if err != nil {
+			t.Fatalf("ArchiveBookmark(%s) error = %v", path, err)
+		}
+		if !strings.Contains(result.Title, path) {
+			t.Errorf("expected the title of %s, got %q", path, result.Title)
+		}
+
+		pool.mu.Lock()
+		n, current := len(pool.browsers), pool.current
+		pool.mu.Unlock()
+		switch i {
+		case 0:
+			first = current
+			if n != 1 || first == nil {
+				t.Errorf("expected one shared browser, got %d", n)
+			}
+		case 1:
+			// The second page used up the first browser, which closed
+			// once its tab did.
+			if n != 0 || current != nil {
+				t.Errorf("expected the browser to be recycled, got %d open", n)
+			}
+		case 2:
+			if n != 1 || current == first {
+				t.Errorf("expected a fresh browser, got %d open", n)
+			}
+		}
+	}
+}
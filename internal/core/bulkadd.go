@@ -0,0 +1,129 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ErrBulkAddTooLarge is returned when a bulk add has more than
+// MaxBulkAddLines URLs.
+var ErrBulkAddTooLarge = errors.New("too many URLs")
+
+// BulkAddLineError describes a line of a bulk add that couldn't be used.
+type BulkAddLineError struct {
+	// Line is 1-based.
+	Line  int    `json:"line"`
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// BulkAddResult reports the outcome of BulkAddBookmarks.
+type BulkAddResult struct {
+	// Added holds the new bookmark IDs in list order.
+	Added []int64 `json:"added"`
+	// Duplicates are URLs listed more than once; only the first was added.
+	Duplicates []string `json:"duplicates"`
+	// Existing are URLs that were already bookmarked and were skipped.
+	Existing []string           `json:"existing"`
+	Invalid  []BulkAddLineError `json:"invalid"`
+}
+
+// BulkAddBookmarks adds a bookmark for each non-blank line of text. Lines use
+// the quick-add syntax (see ParseQuickAdd), so a line may be a bare URL or
+// carry a title, #tags and ~read or ~fav flags; tags are added to every bookmark, and source
+// (see db.BookmarkSource) records where the list came from.
+//
+// Invalid lines, URLs repeated in the list and URLs that are already
+// bookmarked are reported and skipped. The rest are created in one
+// transaction; archiving and title fetching are left to the usual
+// bookmark-created listeners.
+func BulkAddBookmarks(database *db.DB, text string, tags []string, source string) (BulkAddResult, error) {
+	res := BulkAddResult{Added: []int64{}, Duplicates: []string{}, Existing: []string{}, Invalid: []BulkAddLineError{}}
+
+	lines, err := bulkAddLines(text)
+	if err != nil {
+		return res, err
+	}
+
+	var nbs []db.NewBookmark
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		qa, err := ParseQuickAdd(line)
+		if err != nil {
+			res.Invalid = append(res.Invalid, BulkAddLineError{Line: i + 1, Text: line, Error: err.Error()})
+			continue
+		}
+		if seen[qa.URL] {
+			res.Duplicates = append(res.Duplicates, qa.URL)
+			continue
+		}
+		seen[qa.URL] = true
+		nb := qa.NewBookmark()
+		nb.Tags = append(nb.Tags, tags...)
+		nb.Source = source
+		nbs = append(nbs, nb)
+	}
+
+	urls := make([]string, len(nbs))
+	for i, nb := range nbs {
+		urls[i] = nb.URL
+	}
+	existing, err := database.ExistingBookmarkURLs(urls)
+	if err != nil {
+		return res, err
+	}
+	fresh := nbs[:0]
+	for _, nb := range nbs {
+		if existing[nb.URL] {
+			res.Existing = append(res.Existing, nb.URL)
+			continue
+		}
+		fresh = append(fresh, nb)
+	}
+	if len(fresh) == 0 {
+		return res, nil
+	}
+
+	ids, err := database.CreateBookmarks(fresh)
+	if err != nil {
+		return res, err
+	}
+	res.Added = ids
+	log.Printf("Bulk add: added %d bookmark(s), skipped %d duplicate(s), %d existing and %d invalid line(s)",
+		len(res.Added), len(res.Duplicates), len(res.Existing), len(res.Invalid))
+	return res, nil
+}
+
+// CountBulkAddURLs returns how many different valid URLs BulkAddBookmarks
+// would be given in text, at most one bookmark each, or ErrBulkAddTooLarge.
+func CountBulkAddURLs(text string) (int, error) {
+	lines, err := bulkAddLines(text)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if qa, err := ParseQuickAdd(line); err == nil {
+			seen[qa.URL] = true
+		}
+	}
+	return len(seen), nil
+}
+
+// bulkAddLines returns the non-blank lines of a bulk add, trimmed.
+func bulkAddLines(text string) ([]string, error) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > MaxBulkAddLines {
+		return nil, fmt.Errorf("%w: %d given, at most %d allowed", ErrBulkAddTooLarge, len(lines), MaxBulkAddLines)
+	}
+	return lines, nil
+}
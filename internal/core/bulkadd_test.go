@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestBulkAddBookmarks(t *testing.T) {
+	database := newQueueTestDB(t)
+	if _, err := database.AddBookmark("https://saved.com", "Saved"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	text := strings.Join([]string{
+		"https://one.com",
+		"",
+		"  two.com/post Second post #go  ",
+		"https://one.com",
+		"not a url",
+		"https://saved.com",
+	}, "\n")
+	res, err := BulkAddBookmarks(database, text, []string{"imported"}, db.SourceWeb)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(res.Added) != 2 {
+		t.Fatalf("expected 2 bookmarks added, got %+v", res)
+	}
+	if !reflect.DeepEqual(res.Duplicates, []string{"https://one.com"}) {
+		t.Errorf("unexpected duplicates: %v", res.Duplicates)
+	}
+	if !reflect.DeepEqual(res.Existing, []string{"https://saved.com"}) {
+		t.Errorf("unexpected existing: %v", res.Existing)
+	}
+	if len(res.Invalid) != 1 || res.Invalid[0].Line != 4 || res.Invalid[0].Text != "not a url" {
+		t.Errorf("unexpected invalid lines: %+v", res.Invalid)
+	}
+
+	b, err := database.GetBookmark(res.Added[1])
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+	if b.URL != "https://two.com/post" || b.Title != "Second post" {
+		t.Errorf("unexpected bookmark: %+v", b)
+	}
+	tags, err := database.ListBookmarkTags(b.ID)
+	if err != nil {
+		t.Fatalf("failed to list tags: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"go", "imported"}) {
+		t.Errorf("expected tags [go imported], got %v", tags)
+	}
+
+	t.Run("counts each URL once", func(t *testing.T) {
+		if n, err := CountBulkAddURLs(text); err != nil || n != 3 {
+			t.Errorf("expected 3 URLs, got %d (err=%v)", n, err)
+		}
+	})
+
+	t.Run("read and favorite flags", func(t *testing.T) {
+		res, err := BulkAddBookmarks(database, "https://flagged.com Flagged ~read ~fav\nhttps://plain.com", nil, db.SourceWeb)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(res.Added) != 2 {
+			t.Fatalf("expected 2 bookmarks added, got %+v", res)
+		}
+		for i, want := range []db.BookmarkFlags{{IsRead: true, IsFavorite: true}, {}} {
+			flags, err := database.GetBookmarkFlags(res.Added[i])
+			if err != nil {
+				t.Fatalf("failed to get flags: %v", err)
+			}
+			if flags != want {
+				t.Errorf("bookmark %d: expected flags %+v, got %+v", i, want, flags)
+			}
+		}
+	})
+
+	t.Run("nothing new", func(t *testing.T) {
+		res, err := BulkAddBookmarks(database, "https://one.com\n", nil, db.SourceWeb)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(res.Added) != 0 || len(res.Existing) != 1 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("too many lines", func(t *testing.T) {
+		text := strings.Repeat("https://example.com\n", MaxBulkAddLines+1)
+		if _, err := BulkAddBookmarks(database, text, nil, db.SourceWeb); !errors.Is(err, ErrBulkAddTooLarge) {
+			t.Errorf("expected ErrBulkAddTooLarge, got %v", err)
+		}
+		if _, err := CountBulkAddURLs(text); !errors.Is(err, ErrBulkAddTooLarge) {
+			t.Errorf("expected ErrBulkAddTooLarge counting, got %v", err)
+		}
+	})
+}
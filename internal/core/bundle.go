@@ -0,0 +1,154 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// bundleExportName is the account export in a backup bundle.
+const bundleExportName = "export.json"
+
+// bundleArchiveDir holds a backup bundle's archive content, one file per
+// archive version and kind, named by version ID: 12.html, 12.png and
+// 12.download.
+const bundleArchiveDir = "archives/"
+
+// WriteUserDataBundle writes export as a backup bundle: a zstd-compressed
+// tar holding export.json, which is the export without its archives' HTML,
+// screenshots and downloaded files, and that content as files under
+// archives/. ReadUserDataExport reads bundles back into a whole export.
+func WriteUserDataBundle(w io.Writer, export UserDataExport) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to start zstd stream: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	type bundleFile struct {
+		name string
+		data []byte
+	}
+	var files []bundleFile
+	// Copy the bookmarks and archives rather than clear the caller's.
+	bookmarks := make([]ExportedBookmark, len(export.Bookmarks))
+	for i, eb := range export.Bookmarks {
+		eb.Archives = slices.Clone(eb.Archives)
+		for j := range eb.Archives {
+			a := &eb.Archives[j]
+			name := bundleArchiveDir + strconv.FormatInt(a.ID, 10)
+			if a.HTML != "" {
+				files = append(files, bundleFile{name + ".html", []byte(a.HTML)})
+				a.HTML = ""
+			}
+			if len(a.Screenshot) > 0 {
+				files = append(files, bundleFile{name + ".png", a.Screenshot})
+				a.Screenshot = nil
+			}
+			if a.Download != nil && len(a.Download.Data) > 0 {
+				files = append(files, bundleFile{name + ".download", a.Download.Data})
+				download := *a.Download
+				download.Data = nil
+				a.Download = &download
+			}
+		}
+		bookmarks[i] = eb
+	}
+	export.Bookmarks = bookmarks
+	body, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	files = append([]bundleFile{{bundleExportName, append(body, '\n')}}, files...)
+
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0o600, Size: int64(len(f.data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish zstd stream: %w", err)
+	}
+	return nil
+}
+
+// isTar reports whether header, the start of a file, is a POSIX or GNU tar
+// header.
+func isTar(header []byte) bool {
+	return len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar"))
+}
+
+// readUserDataBundle reads an uncompressed backup bundle and fills its
+// export's archives back in from their files.
+func readUserDataBundle(r io.Reader) (UserDataExport, error) {
+	tr := tar.NewReader(r)
+	var body []byte
+	content := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name != bundleExportName && !strings.HasPrefix(name, bundleArchiveDir) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("failed to read %s from bundle: %w", name, err)
+		}
+		if name == bundleExportName {
+			body = data
+		} else {
+			content[strings.TrimPrefix(name, bundleArchiveDir)] = data
+		}
+	}
+	if body == nil {
+		return UserDataExport{}, fmt.Errorf("failed to read bundle: no %s", bundleExportName)
+	}
+
+	export, err := decodeUserDataExport(bytes.NewReader(body))
+	if err != nil {
+		return UserDataExport{}, err
+	}
+	for i := range export.Bookmarks {
+		for j := range export.Bookmarks[i].Archives {
+			a := &export.Bookmarks[i].Archives[j]
+			name := strconv.FormatInt(a.ID, 10)
+			if data, ok := content[name+".html"]; ok {
+				a.HTML = string(data)
+			}
+			if data, ok := content[name+".png"]; ok {
+				a.Screenshot = data
+			}
+			if data, ok := content[name+".download"]; ok && a.Download != nil {
+				a.Download.Data = data
+			}
+		}
+	}
+	return export, nil
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestUserDataBundle(t *testing.T) {
+	export := UserDataExport{
+		UserID:     1,
+		ExportedAt: "2025-05-01T12:00:00Z",
+		Bookmarks: []ExportedBookmark{
+			{ID: 1, URL: "https://go.dev", Title: "Go", Tags: []string{"go"}, Archives: []ExportedArchive{
+				{ID: 7, CapturedAt: "2025-05-01T12:00:00Z", HTML: "<html>go</html>", Screenshot: []byte("png")},
+				{ID: 9, CapturedAt: "2025-05-02T12:00:00Z", HTML: "%PDF", Download: &ExportedDownload{Filename: "spec.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.7")}},
+			}},
+			{ID: 2, URL: "https://example.com", Tags: []string{}, Archives: []ExportedArchive{}},
+		},
+	}
+	var bundle bytes.Buffer
+	if err := WriteUserDataBundle(&bundle, export); err != nil {
+		t.Fatalf("WriteUserDataBundle() error = %v", err)
+	}
+	if export.Bookmarks[0].Archives[0].HTML == "" || export.Bookmarks[0].Archives[1].Download.Data == nil {
+		t.Fatal("expected the caller's export to be left alone")
+	}
+
+	zr, err := zstd.NewReader(bytes.NewReader(bundle.Bytes()))
+	if err != nil {
+		t.Fatalf("expected a zstd stream: %v", err)
+	}
+	defer zr.Close()
+	files := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected a tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+	for name, want := range map[string]string{"archives/7.html": "<html>go</html>", "archives/7.png": "png", "archives/9.download": "%PDF-1.7"} {
+		if files[name] != want {
+			t.Errorf("expected %s to hold %q, got %q", name, want, files[name])
+		}
+	}
+	var stripped UserDataExport
+	if err := json.Unmarshal([]byte(files["export.json"]), &stripped); err != nil {
+		t.Fatalf("failed to parse export.json: %v", err)
+	}
+	if a := stripped.Bookmarks[0].Archives[0]; a.HTML != "" || a.Screenshot != nil {
+		t.Errorf("expected archive content out of export.json, got %+v", a)
+	}
+
+	read, err := ReadUserDataExport(&bundle)
+	if err != nil {
+		t.Fatalf("ReadUserDataExport() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, export) {
+		t.Errorf("expected the bundle to read back as the export\ngot  %+v\nwant %+v", read, export)
+	}
+
+	var empty bytes.Buffer
+	tw := tar.NewWriter(&empty)
+	_ = tw.WriteHeader(&tar.Header{Name: "archives/1.html", Mode: 0o600, Size: 2, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("<p"))
+	_ = tw.Close()
+	if _, err := ReadUserDataExport(&empty); err == nil {
+		t.Error("expected an error for a bundle without export.json")
+	}
+}
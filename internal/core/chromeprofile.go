@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// chromeProfiles tracks which profile directories a running archive has
+// open; Chrome won't start a second instance on a profile that is in use.
+var chromeProfiles = struct {
+	sync.Mutex
+	inUse map[string]bool
+}{inUse: map[string]bool{}}
+
+// chromeProfileDir returns, creating it if needed, the directory under root
+// that Chrome keeps its profile for pageURL's site in. Pages share a profile
+// with the rest of their registrable domain, so "news.example.com" and
+// "www.example.com" reuse each other's cache and cookies.
+func chromeProfileDir(root, pageURL string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	host := strings.ToLower(u.Hostname())
+	site, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// IP addresses, localhost and the like are their own site.
+		site = host
+	}
+	site = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, site)
+	if strings.Trim(site, ".") == "" {
+		return "", fmt.Errorf("no site in URL: %s", pageURL)
+	}
+	dir := filepath.Join(root, site)
+	// Profiles hold cookies, so keep them private.
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create Chrome profile: %w", err)
+	}
+	return dir, nil
+}
+
+// acquireChromeProfile claims a profile directory for one archive, and
+// reports false if another archive in this process has it. Call release
+// once Chrome has exited.
+func acquireChromeProfile(dir string) (release func(), ok bool) {
+	chromeProfiles.Lock()
+	defer chromeProfiles.Unlock()
+	if chromeProfiles.inUse[dir] {
+		return nil, false
+	}
+	chromeProfiles.inUse[dir] = true
+	return func() {
+		chromeProfiles.Lock()
+		defer chromeProfiles.Unlock()
+		delete(chromeProfiles.inUse, dir)
+	}, true
+}
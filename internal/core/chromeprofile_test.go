@@ -0,0 +1,57 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChromeProfileDir(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/a", "example.com"},
+		{"https://news.example.com/b", "example.com"},
+		{"https://blog.example.co.uk/", "example.co.uk"},
+		{"http://127.0.0.1:8080/", "127.0.0.1"},
+		{"http://[::1]/", "__1"},
+	}
+	for _, tt := range tests {
+		dir, err := chromeProfileDir(root, tt.url)
+		if err != nil {
+			t.Fatalf("chromeProfileDir(%q) error = %v", tt.url, err)
+		}
+		if want := filepath.Join(root, tt.want); dir != want {
+			t.Errorf("chromeProfileDir(%q) = %q, want %q", tt.url, dir, want)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() || info.Mode().Perm() != 0o700 {
+			t.Errorf("expected a private directory at %s, got %v, %v", dir, info, err)
+		}
+	}
+	if _, err := chromeProfileDir(root, "file:///etc/passwd"); err == nil {
+		t.Error("expected an error for a URL without a host")
+	}
+}
+
+func TestAcquireChromeProfile(t *testing.T) {
+	release, ok := acquireChromeProfile("/profiles/example.com")
+	if !ok {
+		t.Fatal("expected to claim a free profile")
+	}
+	if _, ok := acquireChromeProfile("/profiles/example.com"); ok {
+		t.Error("expected a profile in use not to be claimed twice")
+	}
+	other, ok := acquireChromeProfile("/profiles/example.org")
+	if !ok {
+		t.Fatal("expected another site's profile to be free")
+	}
+	other()
+	release()
+	release, ok = acquireChromeProfile("/profiles/example.com")
+	if !ok {
+		t.Error("expected a released profile to be free again")
+	}
+	release()
+}
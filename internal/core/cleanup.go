@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// CleanupOptions describes a cleanup run: either a single rule by ID or
+// every enabled rule.
+type CleanupOptions struct {
+	// RuleID, if > 0, runs only this rule, even if it is disabled.
+	RuleID int64
+	// DryRun reports what each rule matches without changing anything or
+	// writing to the audit log.
+	DryRun bool
+	// Now is the time rule ages are measured from. If zero, time.Now() is used.
+	Now time.Time
+}
+
+// CleanupRuleResult reports what one rule did.
+type CleanupRuleResult struct {
+	RuleID  int64  `json:"rule_id"`
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	Matched int    `json:"matched"`
+	Applied int    `json:"applied"`
+	Failed  int    `json:"failed"`
+	// Bookmarks are the IDs the rule matched.
+	Bookmarks []int64 `json:"bookmarks,omitempty"`
+}
+
+// CleanupResult reports the outcome of a cleanup run.
+type CleanupResult struct {
+	DryRun bool                `json:"dry_run"`
+	Rules  []CleanupRuleResult `json:"rules"`
+}
+
+// RunCleanupRules evaluates cleanup rules and deletes or tags the bookmarks
+// they match, recording each action in the audit log.
+func RunCleanupRules(ctx context.Context, database *db.DB, opts CleanupOptions) (CleanupResult, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	res := CleanupResult{DryRun: opts.DryRun}
+
+	var rules []db.CleanupRule
+	if opts.RuleID > 0 {
+		r, err := database.GetCleanupRule(opts.RuleID)
+		if err != nil {
+			return res, err
+		}
+		rules = []db.CleanupRule{r}
+	} else {
+		all, err := database.ListCleanupRules()
+		if err != nil {
+			return res, err
+		}
+		for _, r := range all {
+			if r.Enabled {
+				rules = append(rules, r)
+			}
+		}
+	}
+
+	failed := 0
+	for _, r := range rules {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		rr, err := applyCleanupRule(ctx, database, r, now, opts.DryRun)
+		res.Rules = append(res.Rules, rr)
+		if err != nil {
+			return res, err
+		}
+		failed += rr.Failed
+	}
+	if failed > 0 {
+		return res, fmt.Errorf("cleanup finished with %d failure(s)", failed)
+	}
+	return res, nil
+}
+
+// applyCleanupRule runs a single rule. Per-bookmark failures are counted and
+// logged; only failing to evaluate the rule itself returns an error.
+func applyCleanupRule(ctx context.Context, database *db.DB, r db.CleanupRule, now time.Time, dryRun bool) (CleanupRuleResult, error) {
+	rr := CleanupRuleResult{RuleID: r.ID, Name: r.Name, Action: r.Action}
+	matches, err := database.ListCleanupRuleMatches(r, now)
+	if err != nil {
+		return rr, err
+	}
+	rr.Matched = len(matches)
+	for _, b := range matches {
+		rr.Bookmarks = append(rr.Bookmarks, b.ID)
+	}
+	if dryRun {
+		if rr.Matched > 0 {
+			log.Printf("Cleanup rule %q would %s %d bookmark(s)", r.Name, r.Action, rr.Matched)
+		}
+		return rr, nil
+	}
+
+	for _, b := range matches {
+		if err := ctx.Err(); err != nil {
+			return rr, err
+		}
+		var actErr error
+		switch r.Action {
+		case db.CleanupActionDelete:
+			actErr = database.DeleteBookmark(b.ID)
+		case db.CleanupActionTag:
+			actErr = database.AddBookmarkTags(b.ID, []string{r.ActionTag})
+		default:
+			actErr = fmt.Errorf("unknown cleanup action %q", r.Action)
+		}
+		if actErr != nil {
+			rr.Failed++
+			log.Printf("Cleanup rule %q failed to %s bookmark id=%d: %v", r.Name, r.Action, b.ID, actErr)
+			continue
+		}
+		rr.Applied++
+		log.Printf("Cleanup rule %q: %s bookmark id=%d url=%s", r.Name, cleanupActionDescription(r), b.ID, b.URL)
+		if err := database.LogCleanupAction(db.CleanupLogEntry{
+			RuleID:        r.ID,
+			RuleName:      r.Name,
+			BookmarkID:    b.ID,
+			BookmarkURL:   b.URL,
+			BookmarkTitle: b.Title,
+			Action:        cleanupActionDescription(r),
+		}); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	if err := database.MarkCleanupRuleRun(r.ID, now); err != nil {
+		return rr, err
+	}
+	return rr, nil
+}
+
+// cleanupActionDescription is the audit log wording for a rule's action,
+// e.g. "delete" or "tag #stale".
+func cleanupActionDescription(r db.CleanupRule) string {
+	if r.Action == db.CleanupActionTag {
+		return fmt.Sprintf("%s #%s", r.Action, r.ActionTag)
+	}
+	return r.Action
+}
+
+// RunCleanupSchedule runs every enabled cleanup rule once per interval until
+// ctx is cancelled, pausing during quiet hours. After each run it records a
+// storage sample, so the growth forecast tracks what the rules left behind.
+func RunCleanupSchedule(ctx context.Context, database *db.DB, interval time.Duration, quietHours QuietHours) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := quietHours.Wait(ctx, "cleanup rules"); err != nil {
+			return err
+		}
+		if _, err := RunCleanupRules(ctx, database, CleanupOptions{}); err != nil {
+			log.Printf("Cleanup rules: %v", err)
+		}
+		if _, err := RecordStorageSample(ctx, database, StorageSourceCleanup); err != nil {
+			log.Printf("Storage sample: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
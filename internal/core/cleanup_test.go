@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestRunCleanupRules(t *testing.T) {
+	database := newQueueTestDB(t)
+
+	temp, err := database.CreateBookmark(db.NewBookmark{URL: "https://temp.com", Title: "Temp", Tags: []string{"temp"}})
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	keep, err := database.AddBookmark("https://keep.com", "Keep")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := database.MarkBookmarkRead(keep); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+
+	deleteRule, err := database.CreateCleanupRule(db.CleanupRule{Name: "temp", Tag: "temp", OlderThanDays: 30, Action: db.CleanupActionDelete, Enabled: true})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if _, err := database.CreateCleanupRule(db.CleanupRule{Name: "stale", UnreadOnly: true, OlderThanDays: 30, Action: db.CleanupActionTag, ActionTag: "stale", Enabled: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if _, err := database.CreateCleanupRule(db.CleanupRule{Name: "off", OlderThanDays: 1, Action: db.CleanupActionDelete}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	later := time.Now().AddDate(0, 0, 31)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		res, err := RunCleanupRules(context.Background(), database, CleanupOptions{DryRun: true, Now: later})
+		if err != nil {
+			t.Fatalf("RunCleanupRules() error = %v", err)
+		}
+		if len(res.Rules) != 2 {
+			t.Fatalf("expected the 2 enabled rules to run, got %+v", res.Rules)
+		}
+		if res.Rules[0].Matched != 1 || res.Rules[0].Applied != 0 {
+			t.Errorf("unexpected dry-run result: %+v", res.Rules[0])
+		}
+		if _, err := database.GetBookmark(temp); err != nil {
+			t.Errorf("expected bookmark to survive a dry run: %v", err)
+		}
+		if entries, _ := database.ListCleanupLog(0); len(entries) != 0 {
+			t.Errorf("expected no audit entries, got %+v", entries)
+		}
+	})
+
+	t.Run("rules apply in order and are audited", func(t *testing.T) {
+		res, err := RunCleanupRules(context.Background(), database, CleanupOptions{Now: later})
+		if err != nil {
+			t.Fatalf("RunCleanupRules() error = %v", err)
+		}
+		// The delete rule runs first, so the stale rule finds nothing unread.
+		if res.Rules[0].Applied != 1 || res.Rules[1].Matched != 0 {
+			t.Errorf("unexpected results: %+v", res.Rules)
+		}
+		if _, err := database.GetBookmark(temp); err == nil {
+			t.Error("expected temp bookmark to be deleted")
+		}
+		if _, err := database.GetBookmark(keep); err != nil {
+			t.Errorf("expected other bookmark to be kept: %v", err)
+		}
+
+		entries, err := database.ListCleanupLog(0)
+		if err != nil {
+			t.Fatalf("ListCleanupLog() error = %v", err)
+		}
+		if len(entries) != 1 || entries[0].BookmarkURL != "https://temp.com" || entries[0].Action != db.CleanupActionDelete {
+			t.Errorf("unexpected audit log: %+v", entries)
+		}
+		r, err := database.GetCleanupRule(deleteRule)
+		if err != nil {
+			t.Fatalf("GetCleanupRule() error = %v", err)
+		}
+		if r.LastRunAt == "" {
+			t.Error("expected last run time to be recorded")
+		}
+	})
+
+	t.Run("tag rule", func(t *testing.T) {
+		unread, err := database.AddBookmark("https://unread.com", "Unread")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if _, err := RunCleanupRules(context.Background(), database, CleanupOptions{Now: later}); err != nil {
+			t.Fatalf("RunCleanupRules() error = %v", err)
+		}
+		tags, err := database.ListBookmarkTags(unread)
+		if err != nil {
+			t.Fatalf("ListBookmarkTags() error = %v", err)
+		}
+		if len(tags) != 1 || tags[0] != "stale" {
+			t.Errorf("expected bookmark to be tagged stale, got %v", tags)
+		}
+		entries, _ := database.ListCleanupLog(1)
+		if len(entries) != 1 || entries[0].Action != "tag #stale" {
+			t.Errorf("unexpected audit log: %+v", entries)
+		}
+	})
+
+	t.Run("single rule by ID runs even when disabled", func(t *testing.T) {
+		if err := database.SetCleanupRuleEnabled(deleteRule, false); err != nil {
+			t.Fatalf("SetCleanupRuleEnabled() error = %v", err)
+		}
+		res, err := RunCleanupRules(context.Background(), database, CleanupOptions{RuleID: deleteRule, DryRun: true, Now: later})
+		if err != nil {
+			t.Fatalf("RunCleanupRules() error = %v", err)
+		}
+		if len(res.Rules) != 1 || res.Rules[0].RuleID != deleteRule {
+			t.Errorf("unexpected results: %+v", res.Rules)
+		}
+		if _, err := RunCleanupRules(context.Background(), database, CleanupOptions{RuleID: 99999}); err == nil {
+			t.Error("expected error for unknown rule")
+		}
+	})
+}
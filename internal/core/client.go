@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client adds bookmarks to a bookmarkd server over its HTTP API, for
+// helpers such as "bookmarkd watch-clipboard" that run on another machine.
+// Requests carry an API token (see "bookmarkd tokens create"), so they act
+// as the token's owner and skip the web UI's CSRF checks.
+type Client struct {
+	serverURL string
+	token     string
+	client    *http.Client
+}
+
+// NewClient returns a client for the server at serverURL, such as
+// "https://bookmarks.example.com", authenticating with token.
+func NewClient(serverURL, token string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: expected http(s)://host", serverURL)
+	}
+	if token == "" {
+		return nil, errors.New("an API token is required")
+	}
+	return &Client{
+		serverURL: strings.TrimRight(u.String(), "/"),
+		token:     token,
+		client:    &http.Client{Timeout: DefaultClientTimeout},
+	}, nil
+}
+
+// ClientBookmark is the part of the server's answer to an add that Client
+// reads.
+type ClientBookmark struct {
+	ID    int64  `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// AddBookmark saves pageURL on the server with tags and returns the new
+// bookmark. The server fetches its title and archives it as usual.
+func (c *Client) AddBookmark(ctx context.Context, pageURL string, tags []string) (ClientBookmark, error) {
+	form := url.Values{"url": {pageURL}}
+	if len(tags) > 0 {
+		form.Set("tags", strings.Join(tags, ","))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serverURL+"/bookmarks", strings.NewReader(form.Encode()))
+	if err != nil {
+		return ClientBookmark{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ClientBookmark{}, fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxClientResponseSize))
+	if err != nil {
+		return ClientBookmark{}, fmt.Errorf("failed to read server answer: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return ClientBookmark{}, fmt.Errorf("server refused bookmark (%d): %s", resp.StatusCode, clientErrorMessage(resp, body))
+	}
+
+	var b ClientBookmark
+	if err := json.Unmarshal(body, &b); err != nil {
+		return ClientBookmark{}, fmt.Errorf("failed to parse server answer: %w", err)
+	}
+	return b, nil
+}
+
+// clientErrorMessage returns what the server said went wrong: the message
+// and code of its JSON error, or the body of older servers' plain text one.
+func clientErrorMessage(resp *http.Response, body []byte) string {
+	var e struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		if e.Code != "" {
+			return e.Message + " (" + e.Code + ")"
+		}
+		return e.Message
+	}
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return msg
+	}
+	return resp.Status
+}
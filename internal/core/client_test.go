@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_AddBookmark(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/bookmarks" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("url") == "https://bad.example.com/" {
+			http.Error(w, "invalid URL", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("url") == "https://busy.example.com/" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"code": "rate_limited", "message": "Rate limit exceeded"}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"id": 7, "url": %q, "title": %q}`, r.FormValue("url"), r.FormValue("tags"))
+	}))
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	client, err := NewClient(ts.URL+"/", "secret")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	b, err := client.AddBookmark(ctx, "https://example.com/", []string{"inbox", "later"})
+	if err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+	if b.ID != 7 || b.URL != "https://example.com/" || b.Title != "inbox,later" {
+		t.Errorf("unexpected bookmark: %+v", b)
+	}
+	if _, err := client.AddBookmark(ctx, "https://bad.example.com/", nil); err == nil || !strings.Contains(err.Error(), "invalid URL") {
+		t.Errorf("expected the server's refusal, got %v", err)
+	}
+	if _, err := client.AddBookmark(ctx, "https://busy.example.com/", nil); err == nil || !strings.HasSuffix(err.Error(), "(429): Rate limit exceeded (rate_limited)") {
+		t.Errorf("expected the server's JSON error, got %v", err)
+	}
+
+	wrong, _ := NewClient(ts.URL, "wrong")
+	if _, err := wrong.AddBookmark(ctx, "https://example.com/", nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401, got %v", err)
+	}
+
+	for _, tt := range []struct{ server, token string }{
+		{"bookmarks.example.com", "secret"},
+		{"ftp://bookmarks.example.com", "secret"},
+		{ts.URL, ""},
+	} {
+		if _, err := NewClient(tt.server, tt.token); err == nil {
+			t.Errorf("NewClient(%q, %q): expected error", tt.server, tt.token)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrNoClipboard is returned by ClipboardCommand when none of the programs
+// that read the clipboard on this system is installed.
+var ErrNoClipboard = errors.New("no clipboard program found (install wl-clipboard, xclip or xsel)")
+
+// ClipboardCommand returns the command that prints the system clipboard's
+// text: pbpaste on macOS, PowerShell's Get-Clipboard on Windows, and
+// wl-paste under Wayland or xclip or xsel under X11 elsewhere.
+func ClipboardCommand() ([]string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbpaste"}}
+	case "windows":
+		candidates = [][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-paste", "--no-newline"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard", "-o"},
+			[]string{"xsel", "--clipboard", "--output"},
+		)
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c, nil
+		}
+	}
+	return nil, ErrNoClipboard
+}
+
+// ReadClipboard runs args, as ClipboardCommand returns them, and returns
+// what it printed. An empty clipboard reads as "", even where the program
+// fails for it.
+func ReadClipboard(ctx context.Context, args []string) (string, error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// wl-paste and xclip fail when the clipboard is empty or holds
+		// something other than text.
+		if len(out) == 0 && ctx.Err() == nil {
+			return "", nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// ClipboardURL returns the http(s) URL text holds, if it is nothing but
+// one, as when a link is copied from a browser's address bar.
+func ClipboardURL(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, " \t\r\n") {
+		return "", false
+	}
+	u, err := url.Parse(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	return text, true
+}
+
+// ClipboardWatcher reports the URLs copied to the clipboard, polling it
+// with Read every Interval (DefaultClipboardPollInterval if zero). Whatever
+// the clipboard held when the watcher first read it isn't reported, and
+// neither is a URL copied again right after it was reported.
+type ClipboardWatcher struct {
+	Read     func(context.Context) (string, error)
+	Interval time.Duration
+
+	last    string
+	started bool
+}
+
+// Next waits for a URL to be copied and returns it. It returns the error
+// of a failed read, or ctx's once ctx is done.
+func (w *ClipboardWatcher) Next(ctx context.Context) (string, error) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultClipboardPollInterval
+	}
+	for {
+		text, err := w.Read(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", err
+		}
+		changed := w.started && text != w.last
+		w.last, w.started = text, true
+		if changed {
+			if u, ok := ClipboardURL(text); ok {
+				return u, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
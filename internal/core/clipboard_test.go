@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClipboardURL(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"https://example.com/a?b=c\n", "https://example.com/a?b=c", true},
+		{"  http://example.com  ", "http://example.com", true},
+		{"https://example.com/ is great", "", false},
+		{"example.com", "", false},
+		{"mailto:katie@example.com", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ClipboardURL(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ClipboardURL(%q) = %q, %v; want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClipboardWatcher(t *testing.T) {
+	// The clipboard holds each of these in turn, then the last for good.
+	contents := []string{
+		"https://already.example.com/",
+		"https://already.example.com/",
+		"some text",
+		"https://new.example.com/",
+		"https://new.example.com/",
+		"https://next.example.com/",
+	}
+	reads := 0
+	w := &ClipboardWatcher{
+		Read: func(context.Context) (string, error) {
+			text := contents[min(reads, len(contents)-1)]
+			reads++
+			return text, nil
+		},
+		Interval: time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, want := range []string{"https://new.example.com/", "https://next.example.com/"} {
+		got, err := w.Next(ctx)
+		if err != nil || got != want {
+			t.Fatalf("Next() = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := w.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to wait for the next copy, got %v", err)
+	}
+
+	failing := &ClipboardWatcher{Read: func(context.Context) (string, error) { return "", errors.New("boom") }}
+	if _, err := failing.Next(context.Background()); err == nil || err.Error() != "boom" {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
@@ -6,6 +6,48 @@ import "time"
 const (
 	ArchiveStatusOK    = "ok"
 	ArchiveStatusError = "error"
+	// ArchiveStatusSkipped marks a bookmark whose site asked not to be
+	// archived (see ArchiveOptions.RespectRobots); archive_error holds why.
+	ArchiveStatusSkipped = "skipped"
+)
+
+// Archive engines, for ArchiveOptions.Engine and provenance records
+const (
+	// ArchiveEngineChrome renders pages in Chrome/Chromium.
+	ArchiveEngineChrome = "chrome"
+	// ArchiveEngineHTTP fetches pages with a plain GET, without running
+	// scripts, for servers without Chromium.
+	ArchiveEngineHTTP = "http"
+)
+
+// Embed modes, for InlineOptions.Embeds: what becomes of iframes, video,
+// audio and plugin embeds, which the inliner otherwise leaves pointing at
+// the live site.
+const (
+	// EmbedsKeep leaves embeds as they are.
+	EmbedsKeep = "keep"
+	// EmbedsPlaceholder replaces every embed with a link to its source.
+	EmbedsPlaceholder = "placeholder"
+	// EmbedsInline snapshots same-origin iframes into srcdoc, recursively,
+	// and inlines video and audio that fit MaxResourceSize; everything else
+	// gets a placeholder.
+	EmbedsInline = "inline"
+)
+
+// Wait strategies (ArchiveOptions.WaitStrategy): what Chrome waits for
+// after navigating before it captures a page.
+const (
+	// WaitNetworkIdle waits until the page has made no network requests
+	// for a while. Pages that keep polling never get there.
+	WaitNetworkIdle = "networkIdle"
+	// WaitDOMContentLoaded waits for the HTML to be parsed.
+	WaitDOMContentLoaded = "domContentLoaded"
+	// WaitLoad waits for the load event: the page and its images,
+	// stylesheets and scripts.
+	WaitLoad = "load"
+	// WaitFixedDelay waits for ArchiveOptions.ExtraDelay, or
+	// DefaultFixedWaitDelay, after navigation.
+	WaitFixedDelay = "fixed-delay"
 )
 
 // Timeout defaults for archiving operations
@@ -13,11 +55,199 @@ const (
 	DefaultArchiveTimeout   = 35 * time.Second
 	DefaultResourceTimeout  = 10 * time.Second
 	DefaultNetworkIdleDelay = 500 * time.Millisecond
+	// DefaultScrollDelay is the pause after each scroll step of
+	// ArchiveOptions.ScrollToBottom, for lazy content to start loading.
+	DefaultScrollDelay = 250 * time.Millisecond
+	// DefaultFixedWaitDelay is how long WaitFixedDelay waits when no
+	// ExtraDelay is set.
+	DefaultFixedWaitDelay  = 3 * time.Second
+	DefaultMetadataTimeout = 15 * time.Second
+	// DefaultTimestampTimeout bounds a request to an RFC 3161 timestamp authority.
+	DefaultTimestampTimeout = 15 * time.Second
+	// DefaultWebhookTimeout bounds one webhook delivery attempt.
+	DefaultWebhookTimeout = 10 * time.Second
+	// DefaultTTSTimeout bounds reading one article aloud, however many
+	// requests the backend needs for it.
+	DefaultTTSTimeout = 5 * time.Minute
+	// DefaultUpdateCheckTimeout bounds a request for the release feed.
+	DefaultUpdateCheckTimeout = 15 * time.Second
+	// DefaultWaybackTimeout bounds a request to the Wayback Machine. Save
+	// Page Now captures the page before it answers, so it can be slow.
+	DefaultWaybackTimeout = time.Minute
+	// DefaultArchiveTodayTimeout bounds a submission to archive.today.
+	DefaultArchiveTodayTimeout = time.Minute
+	// DefaultClientTimeout bounds a request Client makes to a bookmarkd
+	// server.
+	DefaultClientTimeout = 30 * time.Second
+	// DefaultRequestTimeout bounds how long the web server spends on a
+	// request, except on the slower routes given their own timeouts.
+	DefaultRequestTimeout = 30 * time.Second
+	// DefaultReadHeaderTimeout bounds how long a client may take to send a
+	// request's headers, and DefaultIdleTimeout how long an idle
+	// keep-alive connection stays open.
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	// DefaultClipboardPollInterval is how often ClipboardWatcher reads the
+	// clipboard.
+	DefaultClipboardPollInterval = time.Second
+)
+
+// Background job queue defaults
+const (
+	DefaultJobMaxAttempts  = 5
+	DefaultJobBaseBackoff  = time.Minute
+	DefaultJobMaxBackoff   = 6 * time.Hour
+	DefaultJobPollInterval = 5 * time.Second
+	// DefaultMaxQueuedJobs is how many archive jobs may wait for a worker
+	// before new ones are deferred.
+	DefaultMaxQueuedJobs = 1000
+	// DefaultArchiveHostDelay is the pause between archives of pages on
+	// the same host, so a bulk import doesn't hammer one site.
+	DefaultArchiveHostDelay = 2 * time.Second
+	// DefaultCleanupInterval is how often the server runs cleanup rules.
+	DefaultCleanupInterval = time.Hour
+	// DefaultRearchiveInterval is how often the server looks for stale archives.
+	DefaultRearchiveInterval = time.Hour
+	// DefaultGitExportInterval is how often the server exports to a git
+	// repository when one is configured.
+	DefaultGitExportInterval = 24 * time.Hour
+	// DefaultUpdateCheckInterval is how often the server looks for a new
+	// release when update checks are on.
+	DefaultUpdateCheckInterval = 24 * time.Hour
+	// DefaultReconcileInterval is how often the server runs the consistency
+	// check.
+	DefaultReconcileInterval = 24 * time.Hour
+	// StuckJobAge is how long an archive job may run before the consistency
+	// check takes it for abandoned and queues it again. Archives time out
+	// long before this.
+	StuckJobAge = 24 * time.Hour
+	// DefaultTitleFetchWorkers bounds concurrent title fetches for new bookmarks.
+	DefaultTitleFetchWorkers = 4
+	// DefaultArchiveTodayWorkers bounds concurrent archive.today
+	// submissions; it throttles clients that send many at once.
+	DefaultArchiveTodayWorkers = 1
+	// DefaultWebhookWorkers bounds concurrent webhook deliveries.
+	DefaultWebhookWorkers = 4
+	// DefaultInlineWorkers bounds concurrent resource fetches while one
+	// archived page is inlined.
+	DefaultInlineWorkers = 8
+	// DefaultInlineWorkersPerHost bounds how many of those fetches go to
+	// the same host.
+	DefaultInlineWorkersPerHost = 4
+	// DefaultWebhookAttempts is how many times a webhook delivery is tried
+	// before it is given up.
+	DefaultWebhookAttempts = 5
+)
+
+// Visual change detection between archived screenshots
+const (
+	// VisualChangeThreshold is how many of a region's 64 perceptual-hash
+	// bits must differ between two screenshots for it to count as changed.
+	VisualChangeThreshold = 10
+	// MaxVisualDiffRegions bounds how many regions, from the top of a
+	// full-page screenshot, are compared.
+	MaxVisualDiffRegions = 32
+	// VisualDiffPreviewWidth is the width of the before and after images
+	// attached to visual change notifications.
+	VisualDiffPreviewWidth = 320
 )
 
 // Resource limits
 const (
 	MaxResourceSize = 5 * 1024 * 1024 // 5MB
+	// MaxArchiveSize bounds an archived page with its resources inlined;
+	// resources that don't fit keep their URL.
+	MaxArchiveSize = 50 * 1024 * 1024 // 50MB
+	// MaxFontSize bounds a web font inlined into an archive; larger fonts
+	// keep their URL.
+	MaxFontSize = 1024 * 1024 // 1MB
+	// DefaultResourceCacheTTL is how long a resource fetched for one
+	// archive is reused for others before it is downloaded again.
+	DefaultResourceCacheTTL = 7 * 24 * time.Hour
+	// MaxCSSImportDepth bounds how deeply nested @import rules are
+	// inlined.
+	MaxCSSImportDepth = 5
+	// MaxFrameDepth bounds how deeply nested iframes are snapshotted with
+	// EmbedsInline; deeper ones get a placeholder.
+	MaxFrameDepth = 3
+	// MaxScrollSteps bounds how many viewports ScrollToBottom scrolls, so
+	// infinitely scrolling pages still get captured.
+	MaxScrollSteps = 50
+	// DefaultBrowserPoolPages is how many pages a BrowserPool's browser
+	// archives before it is replaced by a fresh one.
+	DefaultBrowserPoolPages = 50
+	// MaxFaviconSize bounds a stored favicon; larger icons aren't saved.
+	MaxFaviconSize = 100 * 1024 // 100KB
+	// MaxBulkAddLines bounds how many URLs a single bulk add accepts.
+	MaxBulkAddLines = 1000
+	// MaxBulkActionIDs bounds how many bookmarks a single bulk delete, tag
+	// or re-archive accepts.
+	MaxBulkActionIDs = 1000
+	// MaxDownloadSize bounds a file saved when a bookmarked URL downloads
+	// instead of opening a page; larger downloads fail the archive.
+	MaxDownloadSize = 100 * 1024 * 1024 // 100MB
+	// MaxImportSize bounds an export uploaded on the web import page.
+	MaxImportSize = 50 * 1024 * 1024 // 50MB
+	// DefaultAPITokenQuota is how many requests per hour an API token
+	// without a quota of its own may make.
+	DefaultAPITokenQuota = 1000
+	// DefaultWriteRateLimit and DefaultWriteRateBurst are the per-minute
+	// rate and burst of write requests allowed from one client IP.
+	DefaultWriteRateLimit = 60
+	DefaultWriteRateBurst = 20
+	// DefaultAddRateLimit and DefaultAddRateBurst are the per-minute rate
+	// and burst of new bookmarks allowed from one API token or client IP.
+	DefaultAddRateLimit = 10
+	DefaultAddRateBurst = 30
+	// AddChallengeLifetime is how long a challenge handed to a client over
+	// the add limit may be answered.
+	AddChallengeLifetime = 10 * time.Minute
+	// DefaultAddChallengeDifficulty is the leading zero bits add challenges
+	// ask for: about 260,000 hashes, a second or two in the bookmarklet.
+	// MaxAddChallengeDifficulty keeps them solvable at all.
+	DefaultAddChallengeDifficulty = 18
+	MaxAddChallengeDifficulty     = 32
+	// DefaultLauncherResults and MaxLauncherResults are the default and
+	// largest number of matches /api/v1/launcher returns.
+	DefaultLauncherResults = 9
+	MaxLauncherResults     = 50
+	// DefaultTagSuggestions and MaxTagSuggestions are the default and
+	// largest number of tags /tags/suggest returns.
+	DefaultTagSuggestions = 8
+	MaxTagSuggestions     = 50
+	// LiveSearchResults is how many matches the index page's live search
+	// shows as you type.
+	LiveSearchResults = 8
+	// DefaultScreenshotQuality is the JPEG quality of archive screenshots.
+	DefaultScreenshotQuality = 80
+	// MaxTTSTextLength bounds, in bytes, how much of an article is
+	// read aloud; longer ones are cut at a sentence.
+	MaxTTSTextLength = 100000
+	// MaxTTSChunkLength bounds, in bytes, the text sent to a
+	// text-to-speech API in one request.
+	MaxTTSChunkLength = 4000
+	// MaxAudioSize bounds the audio a text-to-speech backend returns.
+	MaxAudioSize = 200 * 1024 * 1024 // 200MB
+	// DefaultPodcastEpisodes is how many episodes a podcast feed lists.
+	DefaultPodcastEpisodes = 100
+	// MaxReleaseFeedSize bounds the release feed update checks read.
+	MaxReleaseFeedSize = 5 * 1024 * 1024 // 5MB
+	// MaxChangelogSummary bounds, in bytes, the release notes shown with
+	// an available update.
+	MaxChangelogSummary = 500
+	// MaxWaybackResponseSize bounds an answer from the Wayback Machine's
+	// availability API.
+	MaxWaybackResponseSize = 1024 * 1024 // 1MB
+	// MaxClientResponseSize bounds an answer Client reads from a bookmarkd
+	// server.
+	MaxClientResponseSize = 1024 * 1024 // 1MB
+	// ArchiveDiffContext is how many unchanged paragraphs an archive diff
+	// shows around each change.
+	ArchiveDiffContext = 2
+	// MaxArchiveDiffCells bounds the work of diffing two snapshots: the
+	// product of their changed paragraph counts, or of two paragraphs'
+	// word counts. Past it, the changed part is shown replaced whole.
+	MaxArchiveDiffCells = 4_000_000
 )
 
 // HTTP client configuration
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// checkUser returns an error unless userID is a known user.
+func (db *DB) checkUser(userID int64) error {
+	_, err := db.GetUser(userID)
+	return err
+}
+
+// ListUserBookmarks returns every bookmark owned by a user, oldest first.
+func (db *DB) ListUserBookmarks(userID int64) ([]Bookmark, error) {
+	if err := db.checkUser(userID); err != nil {
+		return nil, err
+	}
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE user_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, []any{userID}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// ListUserBookmarksMatching is ListUserBookmarks limited to the bookmarks a
+// search for query finds, so an export can be narrowed with the search
+// syntax (such as "tag:work domain:example.com before:2024"). An empty
+// query lists them all.
+func (db *DB) ListUserBookmarksMatching(userID int64, query string) ([]Bookmark, error) {
+	bookmarks, err := db.ListUserBookmarks(userID)
+	if err != nil || strings.TrimSpace(query) == "" {
+		return bookmarks, err
+	}
+	results, err := db.ForUser(userID).SearchBookmarks(query, BookmarkFilter{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[int64]bool, len(results))
+	for _, r := range results {
+		found[r.ID] = true
+	}
+	matching := []Bookmark{}
+	for _, b := range bookmarks {
+		if found[b.ID] {
+			matching = append(matching, b)
+		}
+	}
+	return matching, nil
+}
+
+// userLogChunk is how many bookmark IDs DeleteUserData puts in one query,
+// well under SQLite's limit on parameters.
+const userLogChunk = 500
+
+// DeleteUserData permanently deletes everything stored for a user: their
+// bookmarks with all archive versions, tags, metadata, favicons, links and
+// jobs, and their archive preferences, API tokens, import checkpoints,
+// presets, notification preferences, shared and pinned collections,
+// landing page layout and triage history. Tags no bookmark uses any more
+// are dropped too. If the user is the only account, the instance-wide
+// routing and cleanup rules, the cleanup and activity logs and webhooks are
+// deleted as well, since they are all theirs; otherwise only the log
+// entries about their bookmarks are. It all goes in one transaction, so a
+// failure deletes nothing and the call can be retried. Afterwards
+// unreferenced blobs are released and a BookmarkDeletedEvent with
+// WithUserData set is emitted per bookmark. The account itself is kept;
+// see DeleteUser. It returns the number of bookmarks deleted.
+func (db *DB) DeleteUserData(userID int64) (int, error) {
+	bookmarks, err := db.ListUserBookmarks(userID)
+	if err != nil {
+		return 0, err
+	}
+	// Everything is read before the transaction starts; see CreateBookmarks.
+	var blobKeys []string
+	for _, b := range bookmarks {
+		keys, err := db.bookmarkBlobKeys(b.ID)
+		if err != nil {
+			return 0, err
+		}
+		blobKeys = append(blobKeys, keys...)
+	}
+	var others bool
+	if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id != ?)`, userID).Scan(&others); err != nil {
+		return 0, fmt.Errorf("failed to check for other users: %w", err)
+	}
+
+	type statement struct {
+		what  string
+		query string
+		args  []any
+	}
+	stmts := []statement{
+		{"unused tags", `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM bookmark_tags)`, nil},
+		{"archive preferences", `DELETE FROM user_archive_preferences WHERE user_id = ?`, []any{userID}},
+		{"API tokens", `DELETE FROM api_tokens WHERE user_id = ?`, []any{userID}},
+		{"import checkpoints", `DELETE FROM import_checkpoints WHERE user_id = ?`, []any{userID}},
+		{"presets", `DELETE FROM bookmark_presets WHERE user_id = ?`, []any{userID}},
+		{"notification preferences", `DELETE FROM notification_preferences WHERE user_id = ?`, []any{userID}},
+		{"shared collections", `DELETE FROM shared_collections WHERE user_id = ?`, []any{userID}},
+		{"pinned collections", `DELETE FROM pinned_collections WHERE user_id = ?`, []any{userID}},
+		{"landing layout", `DELETE FROM landing_layouts WHERE user_id = ?`, []any{userID}},
+		{"triage sessions", `DELETE FROM triage_sessions WHERE user_id = ?`, []any{userID}},
+	}
+	if others {
+		// The logs are shared, but their entries for the user's bookmarks
+		// carry URLs and titles.
+		for start := 0; start < len(bookmarks); start += userLogChunk {
+			chunk := bookmarks[start:min(start+userLogChunk, len(bookmarks))]
+			ids := make([]any, len(chunk))
+			for i, b := range chunk {
+				ids[i] = b.ID
+			}
+			in := `bookmark_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+			stmts = append(stmts,
+				statement{"activity log entries", `DELETE FROM activity_log WHERE ` + in, ids},
+				statement{"cleanup log entries", `DELETE FROM cleanup_log WHERE ` + in, ids},
+			)
+		}
+	} else {
+		stmts = append(stmts,
+			statement{"routing rules", `DELETE FROM routing_rules`, nil},
+			statement{"cleanup log", `DELETE FROM cleanup_log`, nil},
+			statement{"cleanup rules", `DELETE FROM cleanup_rules`, nil},
+			statement{"activity log", `DELETE FROM activity_log`, nil},
+			statement{"webhooks", `DELETE FROM webhooks`, nil},
+		)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+	for _, b := range bookmarks {
+		if err := deleteBookmarkRows(tx, b.ID); err != nil {
+			return 0, err
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return 0, fmt.Errorf("failed to delete %s: %w", stmt.what, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit user data deletion: %w", err)
+	}
+
+	db.releaseArchiveBlobs(blobKeys)
+	for _, b := range bookmarks {
+		db.emit(BookmarkDeletedEvent{Bookmark: b, UserID: userID, WithUserData: true})
+	}
+	return len(bookmarks), nil
+}
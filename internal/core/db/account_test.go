@@ -0,0 +1,219 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeleteUserData(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	if _, err := db.ListUserBookmarks(42); err == nil {
+		t.Error("expected error listing bookmarks of an unknown user")
+	}
+	if _, err := db.DeleteUserData(42); err == nil {
+		t.Error("expected error deleting an unknown user")
+	}
+
+	first, _ := db.AddBookmark("https://example.com/a", "A")
+	second, _ := db.AddBookmark("https://example.com/b", "B")
+	if err := db.AddBookmarkTags(first, []string{"keep"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := db.SaveArchiveResult(second, now, &now, "ok", "", "https://example.com/b", "<html>b</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	on := true
+	if err := db.SaveArchivePreferences(ArchivePreferences{UserID: LocalUserID, Screenshot: &on}); err != nil {
+		t.Fatalf("failed to save preferences: %v", err)
+	}
+	if _, err := db.CreateRoutingRule(RoutingRule{Name: "gh", Domain: "github.com", AddTag: "code", Enabled: true}); err != nil {
+		t.Fatalf("failed to create routing rule: %v", err)
+	}
+	if _, err := db.CreateCleanupRule(CleanupRule{Name: "old", OlderThanDays: 30, Action: CleanupActionDelete, Enabled: true}); err != nil {
+		t.Fatalf("failed to create cleanup rule: %v", err)
+	}
+
+	listed, err := db.ListUserBookmarks(LocalUserID)
+	if err != nil || len(listed) != 2 || listed[0].ID != first {
+		t.Fatalf("expected both bookmarks oldest first, got %+v, %v", listed, err)
+	}
+
+	var deleted []int64
+	db.RegisterEventListener(OnBookmarkDeletedEvent, func(e Event) error {
+		deleted = append(deleted, e.(BookmarkDeletedEvent).Bookmark.ID)
+		return nil
+	})
+
+	n, err := db.DeleteUserData(LocalUserID)
+	if err != nil {
+		t.Fatalf("failed to delete user data: %v", err)
+	}
+	if n != 2 || len(deleted) != 2 {
+		t.Errorf("expected 2 bookmarks deleted with events, got %d and %v", n, deleted)
+	}
+	if bookmarks, _ := db.ListBookmarks(0); len(bookmarks) != 0 {
+		t.Errorf("expected no bookmarks left, got %d", len(bookmarks))
+	}
+	if versions, _ := db.ListArchiveVersions(second); len(versions) != 0 {
+		t.Errorf("expected no archive versions left, got %d", len(versions))
+	}
+	var blobs, tags int
+	_ = db.db.QueryRow(`SELECT COUNT(*) FROM archive_blobs`).Scan(&blobs)
+	_ = db.db.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&tags)
+	if blobs != 0 || tags != 0 {
+		t.Errorf("expected blobs and tags to be purged, got %d blobs and %d tags", blobs, tags)
+	}
+	if prefs, _ := db.GetArchivePreferences(LocalUserID); prefs.Screenshot != nil {
+		t.Error("expected preferences to be deleted")
+	}
+	if rules, _ := db.ListRoutingRules(); len(rules) != 0 {
+		t.Errorf("expected routing rules to be deleted, got %d", len(rules))
+	}
+	if rules, _ := db.ListCleanupRules(); len(rules) != 0 {
+		t.Errorf("expected cleanup rules to be deleted, got %d", len(rules))
+	}
+}
+
+// TestDeleteUserDataSharedLogs tests that deleting one user's data drops
+// the activity and cleanup log entries about their bookmarks, and only
+// those, while other users keep the shared logs.
+func TestDeleteUserDataSharedLogs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	db.EnableActivityLog()
+
+	other, err := db.CreateUser("other", "correct horse battery", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	mine, _ := db.AddBookmark("https://example.com/mine", "Mine")
+	theirs, err := db.ForUser(other.ID).AddBookmark("https://example.com/theirs", "Theirs")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	for _, e := range []CleanupLogEntry{
+		{RuleID: 1, RuleName: "old", BookmarkID: mine, BookmarkURL: "https://example.com/mine", BookmarkTitle: "Mine", Action: CleanupActionTag},
+		{RuleID: 1, RuleName: "old", BookmarkID: theirs, BookmarkURL: "https://example.com/theirs", BookmarkTitle: "Theirs", Action: CleanupActionTag},
+	} {
+		if err := db.LogCleanupAction(e); err != nil {
+			t.Fatalf("failed to log cleanup action: %v", err)
+		}
+	}
+
+	if _, err := db.DeleteUserData(LocalUserID); err != nil {
+		t.Fatalf("failed to delete user data: %v", err)
+	}
+
+	for _, table := range []string{"activity_log", "cleanup_log"} {
+		var mineRows, theirRows int
+		_ = db.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE bookmark_id = ? OR bookmark_url LIKE '%/mine'`, mine).Scan(&mineRows)
+		_ = db.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE bookmark_id = ?`, theirs).Scan(&theirRows)
+		if mineRows != 0 {
+			t.Errorf("expected no %s entries about the deleted user's bookmark, got %d", table, mineRows)
+		}
+		if theirRows == 0 {
+			t.Errorf("expected the other user's %s entries to be kept", table)
+		}
+	}
+}
+
+// TestDeleteUserDataRollsBack tests that a failed deletion leaves
+// everything in place.
+func TestDeleteUserDataRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.CreateBookmark(NewBookmark{URL: "https://example.com", Title: "Example", Tags: []string{"kept"}})
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	// Make the last per-user deletion fail.
+	if _, err := db.db.Exec(`DROP TABLE triage_sessions`); err != nil {
+		t.Fatalf("failed to drop table: %v", err)
+	}
+	var deleted int
+	db.RegisterEventListener(OnBookmarkDeletedEvent, func(Event) error {
+		deleted++
+		return nil
+	})
+
+	if n, err := db.DeleteUserData(LocalUserID); err == nil || n != 0 {
+		t.Fatalf("expected an error and nothing deleted, got %d, %v", n, err)
+	}
+	if _, err := db.GetBookmark(id); err != nil || deleted != 0 {
+		t.Errorf("expected the bookmark to be kept without events, got %v and %d event(s)", err, deleted)
+	}
+	if tags, err := db.ListBookmarkTags(id); err != nil || len(tags) != 1 {
+		t.Errorf("expected the bookmark's tag to be kept, got %v, %v", tags, err)
+	}
+}
+
+func TestListUserBookmarksMatching(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	work, _ := db.CreateBookmark(NewBookmark{URL: "https://docs.example.com/a", Title: "Design doc", Tags: []string{"work"}})
+	_, _ = db.CreateBookmark(NewBookmark{URL: "https://other.test/b", Title: "Recipes", Tags: []string{"home"}})
+	older, _ := db.CreateBookmark(NewBookmark{URL: "https://example.com/c", Title: "Old notes", Tags: []string{"work"}, CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	user, err := db.CreateUser("other", "correct horse battery", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := db.ForUser(user.ID).CreateBookmark(NewBookmark{URL: "https://example.com/theirs", Tags: []string{"work"}}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	for query, want := range map[string][]int64{
+		"tag:work":                  {older, work},
+		"domain:example.com":        {older, work},
+		"tag:work before:2021":      {older},
+		"design domain:example.com": {work},
+		"tag:nothing":               {},
+	} {
+		bookmarks, err := db.ListUserBookmarksMatching(LocalUserID, query)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", query, err)
+		}
+		var got []int64
+		for _, b := range bookmarks {
+			got = append(got, b.ID)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %v, got %v", query, want, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected %v, got %v", query, want, got)
+				break
+			}
+		}
+	}
+
+	if all, err := db.ListUserBookmarksMatching(LocalUserID, " "); err != nil || len(all) != 3 {
+		t.Errorf("expected an empty query to list all 3 bookmarks, got %d (err=%v)", len(all), err)
+	}
+	if _, err := db.ListUserBookmarksMatching(LocalUserID, "before:soon"); err == nil {
+		t.Error("expected an error for an invalid query")
+	}
+}
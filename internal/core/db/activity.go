@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Kinds of activity log entries.
+const (
+	ActivityBookmarkAdded    = "bookmark_added"
+	ActivityBookmarkDeleted  = "bookmark_deleted"
+	ActivityArchiveCompleted = "archive_completed"
+	ActivityArchiveFailed    = "archive_failed"
+	ActivityImportFinished   = "import_finished"
+	ActivityVisualChange     = "visual_change"
+)
+
+// ActivityKinds lists the activity kinds in the order the UI offers them.
+var ActivityKinds = []string{
+	ActivityBookmarkAdded,
+	ActivityBookmarkDeleted,
+	ActivityArchiveCompleted,
+	ActivityArchiveFailed,
+	ActivityImportFinished,
+	ActivityVisualChange,
+}
+
+// activityLogLimit is how many entries the activity log keeps; older ones
+// are pruned as new ones are written.
+const activityLogLimit = 10000
+
+// EnableActivityLog registers event listeners that record bookmarks being
+// added and deleted, archives completing, failing or looking different, and
+// imports finishing in the activity log. Call it once per DB; every process
+// that changes the database should, so the log covers the web server and the
+// CLI alike.
+func (db *DB) EnableActivityLog() {
+	db.RegisterEventListener(OnBookmarkCreatedEvent, func(event Event) error {
+		ev := event.(BookmarkCreatedEvent)
+		return db.logActivity(ActivityEntry{Kind: ActivityBookmarkAdded, BookmarkID: ev.Bookmark.ID,
+			BookmarkURL: ev.Bookmark.URL, BookmarkTitle: ev.Bookmark.Title})
+	})
+	db.RegisterEventListener(OnBookmarkDeletedEvent, func(event Event) error {
+		ev := event.(BookmarkDeletedEvent)
+		if ev.WithUserData {
+			return nil
+		}
+		return db.logActivity(ActivityEntry{Kind: ActivityBookmarkDeleted, BookmarkID: ev.Bookmark.ID,
+			BookmarkURL: ev.Bookmark.URL, BookmarkTitle: ev.Bookmark.Title})
+	})
+	db.RegisterEventListener(OnArchiveResultSavedEvent, func(event Event) error {
+		ev := event.(ArchiveResultSavedEvent)
+		e := ActivityEntry{Kind: ActivityArchiveCompleted, BookmarkID: ev.BookmarkID}
+		if ev.Status != "ok" {
+			e.Kind, e.Detail = ActivityArchiveFailed, ev.Error
+		}
+		if b, err := db.GetBookmark(ev.BookmarkID); err == nil {
+			e.BookmarkURL, e.BookmarkTitle = b.URL, b.Title
+		}
+		return db.logActivity(e)
+	})
+	db.RegisterEventListener(OnImportFinishedEvent, func(event Event) error {
+		ev := event.(ImportFinishedEvent)
+		return db.logActivity(ActivityEntry{Kind: ActivityImportFinished,
+			Detail: fmt.Sprintf("Imported %d bookmark(s) from %s; skipped %d, %d invalid", ev.Added, ev.Source, ev.Skipped, ev.Invalid)})
+	})
+	db.RegisterEventListener(OnArchiveVisualChangeEvent, func(event Event) error {
+		ev := event.(ArchiveVisualChangeEvent)
+		e := ActivityEntry{Kind: ActivityVisualChange, BookmarkID: ev.BookmarkID,
+			Detail: fmt.Sprintf("%d of %d screenshot region(s) changed since the previous archive", ev.ChangedRegions, ev.Regions)}
+		if b, err := db.GetBookmark(ev.BookmarkID); err == nil {
+			e.BookmarkURL, e.BookmarkTitle = b.URL, b.Title
+		}
+		return db.logActivity(e)
+	})
+}
+
+// logActivity appends an entry to the activity log and prunes the oldest
+// entries beyond activityLogLimit.
+func (db *DB) logActivity(e ActivityEntry) error {
+	var bookmarkID any
+	if e.BookmarkID != 0 {
+		bookmarkID = e.BookmarkID
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO activity_log (kind, bookmark_id, bookmark_url, bookmark_title, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, e.Kind, bookmarkID, e.BookmarkURL, e.BookmarkTitle, e.Detail, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to write activity log: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get activity log ID: %w", err)
+	}
+	if _, err := db.db.Exec(`DELETE FROM activity_log WHERE id <= ?`, id-activityLogLimit); err != nil {
+		return fmt.Errorf("failed to prune activity log: %w", err)
+	}
+	return nil
+}
+
+// ListActivity returns activity log entries that pass filter, newest first,
+// up to limit (0 for all).
+func (db *DB) ListActivity(filter ActivityFilter, limit int) ([]ActivityEntry, error) {
+	query := `
+		SELECT id, kind, COALESCE(bookmark_id, 0), bookmark_url, bookmark_title, detail, created_at
+		FROM activity_log
+		WHERE (? = 0 OR id < ?)`
+	args := []any{filter.Before, filter.Before}
+	if len(filter.Kinds) > 0 {
+		query += ` AND kind IN (?` + strings.Repeat(", ?", len(filter.Kinds)-1) + `)`
+		for _, k := range filter.Kinds {
+			args = append(args, k)
+		}
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activity: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	entries := []ActivityEntry{}
+	for rows.Next() {
+		var e ActivityEntry
+		if err := rows.Scan(&e.ID, &e.Kind, &e.BookmarkID, &e.BookmarkURL, &e.BookmarkTitle, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan activity entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate activity log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// TestActivityLog tests recording events in the activity log.
+func TestActivityLog(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	db.EnableActivityLog()
+
+	kept, err := db.AddBookmark("https://a.example.com", "Kept")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	gone, err := db.AddBookmark("https://b.example.com", "Gone")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := db.SaveArchiveResult(kept, now, &now, "ok", "", "https://a.example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveResult(gone, now, nil, "error", "timed out", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.DeleteBookmark(gone); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+	db.EmitImportFinished(ImportFinishedEvent{Source: "Pocket", Added: 3, Skipped: 1})
+
+	t.Run("lists entries newest first", func(t *testing.T) {
+		entries, err := db.ListActivity(ActivityFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []string{ActivityImportFinished, ActivityBookmarkDeleted, ActivityArchiveFailed,
+			ActivityArchiveCompleted, ActivityBookmarkAdded, ActivityBookmarkAdded}
+		if len(entries) != len(want) {
+			t.Fatalf("expected %d entries, got %+v", len(want), entries)
+		}
+		for i, kind := range want {
+			if entries[i].Kind != kind {
+				t.Errorf("entry %d: expected %s, got %s", i, kind, entries[i].Kind)
+			}
+		}
+		if e := entries[1]; e.BookmarkID != gone || e.BookmarkURL != "https://b.example.com" || e.BookmarkTitle != "Gone" {
+			t.Errorf("expected the deleted bookmark to stay readable, got %+v", e)
+		}
+		if e := entries[2]; e.Detail != "timed out" || e.BookmarkTitle != "Gone" {
+			t.Errorf("expected the archive error, got %+v", e)
+		}
+		if e := entries[0]; e.BookmarkID != 0 || e.Detail != "Imported 3 bookmark(s) from Pocket; skipped 1, 0 invalid" {
+			t.Errorf("unexpected import entry: %+v", e)
+		}
+	})
+
+	t.Run("filters by kind and pages back", func(t *testing.T) {
+		entries, err := db.ListActivity(ActivityFilter{Kinds: []string{ActivityBookmarkAdded, ActivityArchiveFailed}}, 2)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(entries) != 2 || entries[0].Kind != ActivityArchiveFailed || entries[1].BookmarkID != gone {
+			t.Fatalf("unexpected entries: %+v", entries)
+		}
+		older, err := db.ListActivity(ActivityFilter{Kinds: []string{ActivityBookmarkAdded}, Before: entries[1].ID}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(older) != 1 || older[0].BookmarkID != kept {
+			t.Errorf("expected the first bookmark, got %+v", older)
+		}
+	})
+
+	t.Run("is cleared with the user's data", func(t *testing.T) {
+		if _, err := db.DeleteUserData(LocalUserID); err != nil {
+			t.Fatalf("failed to delete user data: %v", err)
+		}
+		entries, err := db.ListActivity(ActivityFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected an empty log, got %+v", entries)
+		}
+	})
+}
+
+// TestActivityLogVisualChange tests logging a visual change with its bookmark.
+func TestActivityLogVisualChange(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	db.EnableActivityLog()
+
+	id, err := db.AddBookmark("https://a.example.com", "Watched")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	db.EmitArchiveVisualChange(ArchiveVisualChangeEvent{BookmarkID: id, Regions: 4, ChangedRegions: 1, Distance: 30})
+
+	entries, err := db.ListActivity(ActivityFilter{Kinds: []string{ActivityVisualChange}}, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %+v", entries)
+	}
+	if e := entries[0]; e.BookmarkID != id || e.BookmarkTitle != "Watched" || e.Detail != "1 of 4 screenshot region(s) changed since the previous archive" {
+		t.Errorf("unexpected visual change entry: %+v", e)
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"time"
 )
 
+// archiveVersionSize is the SQL for ArchiveVersion.Size; html_size is NULL
+// for versions saved before sizes were recorded.
+const archiveVersionSize = `COALESCE(html_size + COALESCE(screenshot_size, 0) + COALESCE(download_size, 0) + COALESCE(audio_size, 0), 0)`
+
 func (db *DB) QueueBookmarkForArchive(id int64) error {
 	_, err := db.db.Exec(`
 		UPDATE bookmarks
 		SET archived_at = NULL
-		WHERE id = ?
-	`, id)
+		WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...)
 	return err
 }
 
@@ -23,7 +26,7 @@ func scanBookmarks(rows *sql.Rows) ([]Bookmark, error) {
 	var out []Bookmark
 	for rows.Next() {
 		var b Bookmark
-		if err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt, &b.Slug); err != nil {
 			return nil, fmt.Errorf("failed to scan bookmark: %w", err)
 		}
 		out = append(out, b)
@@ -55,11 +58,12 @@ func (db *DB) queryBookmarks(query string, args []any, limit int) ([]Bookmark, e
 
 func (db *DB) ListBookmarksToArchive(limit int) ([]Bookmark, error) {
 	query := `
-		SELECT id, url, title, created_at
+		SELECT id, url, title, created_at, COALESCE(slug, '')
 		FROM bookmarks
-		WHERE archived_at IS NULL
+		WHERE archived_at IS NULL AND COALESCE(archive_status, '') != 'skipped'
+		  AND ` + ownerFilter("user_id") + `
 		ORDER BY created_at DESC`
-	bookmarks, err := db.queryBookmarks(query, nil, limit)
+	bookmarks, err := db.queryBookmarks(query, db.owner(), limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list bookmarks to archive: %w", err)
 	}
@@ -68,11 +72,11 @@ func (db *DB) ListBookmarksToArchive(limit int) ([]Bookmark, error) {
 
 func (db *DB) ListArchivedBookmarks(limit int) ([]Bookmark, error) {
 	query := `
-		SELECT id, url, title, created_at
+		SELECT id, url, title, created_at, COALESCE(slug, '')
 		FROM bookmarks
-		WHERE archived_at IS NOT NULL
+		WHERE archived_at IS NOT NULL AND ` + ownerFilter("user_id") + `
 		ORDER BY archived_at DESC`
-	bookmarks, err := db.queryBookmarks(query, nil, limit)
+	bookmarks, err := db.queryBookmarks(query, db.owner(), limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list archived bookmarks: %w", err)
 	}
@@ -81,60 +85,169 @@ func (db *DB) ListArchivedBookmarks(limit int) ([]Bookmark, error) {
 
 func (db *DB) ListBookmarksByArchiveStatus(status string, limit int) ([]Bookmark, error) {
 	query := `
-		SELECT id, url, title, created_at
+		SELECT id, url, title, created_at, COALESCE(slug, '')
 		FROM bookmarks
-		WHERE archive_status = ?
+		WHERE archive_status = ? AND ` + ownerFilter("user_id") + `
 		ORDER BY archive_attempted_at DESC`
-	bookmarks, err := db.queryBookmarks(query, []any{status}, limit)
+	bookmarks, err := db.queryBookmarks(query, append([]any{status}, db.owner()...), limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list bookmarks by archive status: %w", err)
 	}
 	return bookmarks, nil
 }
 
+// GetBookmarkArchive returns the archive status for a bookmark together with
+// the snapshot captured by its most recent successful attempt.
+//
+// After ClearBookmarkArchive (or a failed attempt) the status fields are reset
+// and ArchivedURL/ArchivedHTML are empty, but earlier snapshots remain
+// available through ListArchiveVersions.
 func (db *DB) GetBookmarkArchive(id int64) (BookmarkArchive, error) {
+	a, key, err := db.getBookmarkArchive(id)
+	if err != nil {
+		return BookmarkArchive{}, err
+	}
+	if a.ArchivedHTML, err = db.loadArchiveBlob(key); err != nil {
+		return BookmarkArchive{}, err
+	}
+	return a, nil
+}
+
+// GetBookmarkArchiveStatus is like GetBookmarkArchive but leaves ArchivedHTML
+// empty, so it never reads from the archive store. Use it for list views.
+func (db *DB) GetBookmarkArchiveStatus(id int64) (BookmarkArchive, error) {
+	a, _, err := db.getBookmarkArchive(id)
+	return a, err
+}
+
+// getBookmarkArchive loads the archive status and the blob key of the latest snapshot.
+func (db *DB) getBookmarkArchive(id int64) (BookmarkArchive, string, error) {
 	var a BookmarkArchive
+	var key string
 	err := db.db.QueryRow(`
 		SELECT
-			id,
-			COALESCE(archived_url, ''),
-			COALESCE(archived_html, ''),
-			COALESCE(archive_attempted_at, ''),
-			COALESCE(archived_at, ''),
-			COALESCE(archive_status, ''),
-			COALESCE(archive_error, '')
-		FROM bookmarks
-		WHERE id = ?
-	`, id).Scan(
+			b.id,
+			COALESCE(v.archived_url, ''),
+			COALESCE(v.blob_hash, ''),
+			COALESCE(b.archive_attempted_at, ''),
+			COALESCE(b.archived_at, ''),
+			COALESCE(b.archive_status, ''),
+			COALESCE(b.archive_error, ''),
+			COALESCE(b.archive_error_code, ''),
+			b.rearchive_disabled,
+			COALESCE(v.html_size + COALESCE(v.screenshot_size, 0) + COALESCE(v.download_size, 0) + COALESCE(v.audio_size, 0), 0),
+			COALESCE(b.wayback_url, ''),
+			COALESCE(b.archive_today_url, ''),
+			b.archive_today_disabled
+		FROM bookmarks b
+		LEFT JOIN bookmark_archives v
+			ON v.bookmark_id = b.id AND v.captured_at = b.archived_at
+		WHERE b.id = ? AND `+ownerFilter("b.user_id"), append([]any{id}, db.owner()...)...).Scan(
 		&a.BookmarkID,
 		&a.ArchivedURL,
-		&a.ArchivedHTML,
+		&key,
 		&a.ArchiveAttemptedAt,
 		&a.ArchivedAt,
 		&a.ArchiveStatus,
 		&a.ArchiveError,
+		&a.ArchiveErrorCode,
+		&a.RearchiveDisabled,
+		&a.Size,
+		&a.WaybackURL,
+		&a.ArchiveTodayURL,
+		&a.ArchiveTodayDisabled,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return BookmarkArchive{}, fmt.Errorf("bookmark not found: %d", id)
+			return BookmarkArchive{}, "", fmt.Errorf("bookmark not found: %d", id)
 		}
-		return BookmarkArchive{}, fmt.Errorf("failed to get bookmark archive: %w", err)
+		return BookmarkArchive{}, "", fmt.Errorf("failed to get bookmark archive: %w", err)
 	}
-	return a, nil
+	return a, key, nil
 }
 
+// SetWaybackURL stores the Wayback Machine snapshot of a bookmark's page;
+// "" clears it.
+func (db *DB) SetWaybackURL(id int64, waybackURL string) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET wayback_url = NULLIF(?, '') WHERE id = ? AND `+ownerFilter("user_id"), append([]any{waybackURL, id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to save wayback URL: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// SetArchiveTodayURL stores the archive.today snapshot of a bookmark's
+// page; "" clears it.
+func (db *DB) SetArchiveTodayURL(id int64, snapshotURL string) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET archive_today_url = NULLIF(?, '') WHERE id = ? AND `+ownerFilter("user_id"), append([]any{snapshotURL, id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to save archive.today URL: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// SetArchiveTodayDisabled keeps a bookmark from being submitted to
+// archive.today, or allows it again.
+func (db *DB) SetArchiveTodayDisabled(id int64, disabled bool) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET archive_today_disabled = ? WHERE id = ? AND `+ownerFilter("user_id"), append([]any{disabled, id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update archive.today setting: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// SetRearchiveDisabled opts a bookmark out of (or back into) scheduled
+// re-archiving.
+func (db *DB) SetRearchiveDisabled(id int64, disabled bool) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET rearchive_disabled = ? WHERE id = ? AND `+ownerFilter("user_id"), append([]any{disabled, id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update re-archive setting: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// ClearBookmarkArchive resets a bookmark's archive status so it is picked up
+// for re-archiving. Previously captured versions are kept; the next successful
+// run adds a new version alongside them.
+// Emits an ArchiveClearedEvent after the status is reset.
 func (db *DB) ClearBookmarkArchive(id int64) error {
 	res, err := db.db.Exec(`
 		UPDATE bookmarks
 		SET
-			archived_html = NULL,
-			archived_url = NULL,
 			archive_attempted_at = NULL,
 			archived_at = NULL,
 			archive_status = NULL,
-			archive_error = NULL
-		WHERE id = ?
-	`, id)
+			archive_error = NULL,
+			archive_error_code = NULL
+		WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...)
 	if err != nil {
 		return fmt.Errorf("failed to clear bookmark archive: %w", err)
 	}
@@ -155,30 +268,66 @@ func (db *DB) ClearBookmarkArchive(id int64) error {
 }
 
 // SaveArchiveResult saves the result of an archive operation.
+//
+// The bookmark's status columns always reflect the latest attempt, except
+// that a failed attempt keeps archived_at pointing at the last good version
+// (e.g. when a scheduled re-archive fails). When archivedAt is set, the captured page is stored as a new version keyed by
+// (bookmark_id, captured_at); saving twice with the same capture time
+// replaces that version. The HTML is stored gzip-compressed in the blob store,
+// shared with any other version captured with identical content.
 // Emits an ArchiveResultSavedEvent after successful save.
 func (db *DB) SaveArchiveResult(id int64, attemptedAt time.Time, archivedAt *time.Time, status string, archiveErr string, archivedURL string, archivedHTML string) error {
+	return db.saveArchiveResult(id, attemptedAt, archivedAt, status, archiveErr, "", archivedURL, archivedHTML)
+}
+
+// SaveArchiveFailure records a failed (or skipped) archive attempt like
+// SaveArchiveResult, with code classifying archiveErr.
+func (db *DB) SaveArchiveFailure(id int64, attemptedAt time.Time, status, code, archiveErr string) error {
+	return db.saveArchiveResult(id, attemptedAt, nil, status, archiveErr, code, "", "")
+}
+
+func (db *DB) saveArchiveResult(id int64, attemptedAt time.Time, archivedAt *time.Time, status, archiveErr, errCode, archivedURL, archivedHTML string) error {
+	if err := db.checkOwner(id); err != nil {
+		return err
+	}
+
 	var archivedAtStr any = nil
+	var key string
 	if archivedAt != nil {
 		archivedAtStr = archivedAt.Format(time.RFC3339)
+		// Write the blob before the transaction; if the transaction fails the
+		// blob is simply unreferenced.
+		var err error
+		if key, err = db.putArchiveBlob(archivedHTML); err != nil {
+			return err
+		}
 	}
 
-	res, err := db.db.Exec(`
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	res, err := tx.Exec(`
 		UPDATE bookmarks
 		SET
 			archive_attempted_at = ?,
-			archived_at = ?,
+			archived_at = COALESCE(?, archived_at),
 			archive_status = ?,
 			archive_error = ?,
-			archived_url = ?,
-			archived_html = ?
+			archive_error_code = NULLIF(?, '')
 		WHERE id = ?
 	`,
 		attemptedAt.Format(time.RFC3339),
 		archivedAtStr,
 		status,
 		archiveErr,
-		archivedURL,
-		archivedHTML,
+		errCode,
 		id,
 	)
 	if err != nil {
@@ -192,10 +341,623 @@ func (db *DB) SaveArchiveResult(id int64, attemptedAt time.Time, archivedAt *tim
 		return fmt.Errorf("bookmark not found: %d", id)
 	}
 
+	var replaced []string
+	if archivedAt != nil {
+		// Remember the blob of a version we're about to replace so it can be
+		// released once nothing refers to it.
+		var prev, prevScreenshot, prevDownload, prevAudio string
+		err := tx.QueryRow(`
+			SELECT COALESCE(blob_hash, ''), COALESCE(screenshot_hash, ''), COALESCE(download_hash, ''), COALESCE(audio_hash, '') FROM bookmark_archives
+			WHERE bookmark_id = ? AND captured_at = ?
+		`, id, archivedAtStr).Scan(&prev, &prevScreenshot, &prevDownload, &prevAudio)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to look up archive version: %w", err)
+		}
+		if prev != "" && prev != key {
+			replaced = append(replaced, prev)
+		}
+		if prevScreenshot != "" {
+			replaced = append(replaced, prevScreenshot)
+		}
+		if prevDownload != "" {
+			replaced = append(replaced, prevDownload)
+		}
+		if prevAudio != "" {
+			replaced = append(replaced, prevAudio)
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO bookmark_archives (bookmark_id, captured_at, archived_url, blob_hash, html_size)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (bookmark_id, captured_at) DO UPDATE SET
+				archived_url = excluded.archived_url,
+				blob_hash = excluded.blob_hash,
+				html_size = excluded.html_size,
+				screenshot_hash = NULL,
+				screenshot_size = NULL,
+				download_hash = NULL,
+				download_filename = NULL,
+				download_mime_type = NULL,
+				download_size = NULL,
+				audio_hash = NULL,
+				audio_mime_type = NULL,
+				audio_size = NULL,
+				provenance = NULL,
+				timestamp_token = NULL,
+				timestamp_authority = NULL,
+				timestamped_at = NULL,
+				readable_title = NULL,
+				readable_byline = NULL,
+				readable_html = NULL,
+				readable_text = NULL
+		`, id, archivedAtStr, archivedURL, key, len(archivedHTML)); err != nil {
+			return fmt.Errorf("failed to save archive version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive result: %w", err)
+	}
+	db.releaseArchiveBlobs(replaced)
+
 	db.emit(ArchiveResultSavedEvent{
 		BookmarkID: id,
 		Status:     status,
+		Error:      archiveErr,
+		ErrorCode:  errCode,
 	})
 
 	return nil
 }
+
+// ListArchiveVersions returns all captured versions of a bookmark, newest first.
+// ArchivedHTML is left empty; use GetArchiveVersion to load a version's content.
+func (db *DB) ListArchiveVersions(bookmarkID int64) ([]ArchiveVersion, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.db.Query(`
+		SELECT id, bookmark_id, captured_at, COALESCE(archived_url, ''), screenshot_hash IS NOT NULL, provenance IS NOT NULL, timestamp_token IS NOT NULL, download_hash IS NOT NULL, audio_hash IS NOT NULL, `+archiveVersionSize+`
+		FROM bookmark_archives
+		WHERE bookmark_id = ?
+		ORDER BY captured_at DESC, id DESC
+	`, bookmarkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive versions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var out []ArchiveVersion
+	for rows.Next() {
+		var v ArchiveVersion
+		if err := rows.Scan(&v.ID, &v.BookmarkID, &v.CapturedAt, &v.ArchivedURL, &v.HasScreenshot, &v.HasProvenance, &v.HasTimestamp, &v.HasDownload, &v.HasAudio, &v.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan archive version: %w", err)
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating archive versions: %w", err)
+	}
+	return out, nil
+}
+
+// GetArchiveVersion returns a single version of a bookmark's archive, including its HTML.
+func (db *DB) GetArchiveVersion(bookmarkID int64, versionID int64) (ArchiveVersion, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return ArchiveVersion{}, err
+	}
+
+	var v ArchiveVersion
+	var key string
+	err := db.db.QueryRow(`
+		SELECT id, bookmark_id, captured_at, COALESCE(archived_url, ''), screenshot_hash IS NOT NULL, provenance IS NOT NULL, timestamp_token IS NOT NULL, download_hash IS NOT NULL, audio_hash IS NOT NULL, `+archiveVersionSize+`, COALESCE(blob_hash, '')
+		FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&v.ID, &v.BookmarkID, &v.CapturedAt, &v.ArchivedURL, &v.HasScreenshot, &v.HasProvenance, &v.HasTimestamp, &v.HasDownload, &v.HasAudio, &v.Size, &key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchiveVersion{}, fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return ArchiveVersion{}, fmt.Errorf("failed to get archive version: %w", err)
+	}
+	if v.ArchivedHTML, err = db.loadArchiveBlob(key); err != nil {
+		return ArchiveVersion{}, err
+	}
+	return v, nil
+}
+
+// GetLatestArchiveVersion returns the most recently captured version of a
+// bookmark's archive, including its HTML.
+func (db *DB) GetLatestArchiveVersion(bookmarkID int64) (ArchiveVersion, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return ArchiveVersion{}, err
+	}
+
+	var v ArchiveVersion
+	var key string
+	err := db.db.QueryRow(`
+		SELECT id, bookmark_id, captured_at, COALESCE(archived_url, ''), screenshot_hash IS NOT NULL, provenance IS NOT NULL, timestamp_token IS NOT NULL, download_hash IS NOT NULL, audio_hash IS NOT NULL, `+archiveVersionSize+`, COALESCE(blob_hash, '')
+		FROM bookmark_archives
+		WHERE bookmark_id = ?
+		ORDER BY captured_at DESC, id DESC
+		LIMIT 1
+	`, bookmarkID).Scan(&v.ID, &v.BookmarkID, &v.CapturedAt, &v.ArchivedURL, &v.HasScreenshot, &v.HasProvenance, &v.HasTimestamp, &v.HasDownload, &v.HasAudio, &v.Size, &key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchiveVersion{}, fmt.Errorf("no archive versions for bookmark: %d", bookmarkID)
+		}
+		return ArchiveVersion{}, fmt.Errorf("failed to get latest archive version: %w", err)
+	}
+	if v.ArchivedHTML, err = db.loadArchiveBlob(key); err != nil {
+		return ArchiveVersion{}, err
+	}
+	return v, nil
+}
+
+// SaveArchiveScreenshot attaches a full-page screenshot to the latest version
+// of a bookmark's archive, replacing any previous one. The image is kept in
+// the blob store alongside the HTML.
+func (db *DB) SaveArchiveScreenshot(bookmarkID int64, image []byte) error {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return err
+	}
+
+	key, err := db.putArchiveBlob(string(image))
+	if err != nil {
+		return err
+	}
+
+	var versionID int64
+	var prev string
+	err = db.db.QueryRow(`
+		SELECT id, COALESCE(screenshot_hash, '') FROM bookmark_archives
+		WHERE bookmark_id = ?
+		ORDER BY captured_at DESC, id DESC
+		LIMIT 1
+	`, bookmarkID).Scan(&versionID, &prev)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			db.releaseArchiveBlobs([]string{key})
+			return fmt.Errorf("no archive versions for bookmark: %d", bookmarkID)
+		}
+		return fmt.Errorf("failed to look up latest archive version: %w", err)
+	}
+
+	if _, err := db.db.Exec(`UPDATE bookmark_archives SET screenshot_hash = ?, screenshot_size = ? WHERE id = ?`, key, len(image), versionID); err != nil {
+		return fmt.Errorf("failed to save archive screenshot: %w", err)
+	}
+	if prev != "" && prev != key {
+		db.releaseArchiveBlobs([]string{prev})
+	}
+	return nil
+}
+
+// GetArchiveScreenshot returns the screenshot captured with a version of a
+// bookmark's archive.
+func (db *DB) GetArchiveScreenshot(bookmarkID, versionID int64) ([]byte, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return nil, err
+	}
+
+	var key string
+	err := db.db.QueryRow(`
+		SELECT COALESCE(screenshot_hash, '') FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return nil, fmt.Errorf("failed to get archive screenshot: %w", err)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no screenshot for archive version: %d", versionID)
+	}
+	image, err := db.loadArchiveBlob(key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(image), nil
+}
+
+// SaveArchiveDownload attaches a downloaded file to the latest version of a
+// bookmark's archive, replacing any previous one. The file is kept in the
+// blob store alongside the HTML.
+func (db *DB) SaveArchiveDownload(bookmarkID int64, d ArchiveDownload) error {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return err
+	}
+
+	key, err := db.putArchiveBlob(string(d.Data))
+	if err != nil {
+		return err
+	}
+
+	var versionID int64
+	var prev string
+	err = db.db.QueryRow(`
+		SELECT id, COALESCE(download_hash, '') FROM bookmark_archives
+		WHERE bookmark_id = ?
+		ORDER BY captured_at DESC, id DESC
+		LIMIT 1
+	`, bookmarkID).Scan(&versionID, &prev)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			db.releaseArchiveBlobs([]string{key})
+			return fmt.Errorf("no archive versions for bookmark: %d", bookmarkID)
+		}
+		return fmt.Errorf("failed to look up latest archive version: %w", err)
+	}
+
+	if _, err := db.db.Exec(`
+		UPDATE bookmark_archives
+		SET download_hash = ?, download_filename = ?, download_mime_type = ?, download_size = ?
+		WHERE id = ?
+	`, key, d.Filename, d.MIMEType, len(d.Data), versionID); err != nil {
+		return fmt.Errorf("failed to save archive download: %w", err)
+	}
+	if prev != "" && prev != key {
+		db.releaseArchiveBlobs([]string{prev})
+	}
+	return nil
+}
+
+// GetArchiveDownload returns the file downloaded with a version of a
+// bookmark's archive.
+func (db *DB) GetArchiveDownload(bookmarkID, versionID int64) (ArchiveDownload, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return ArchiveDownload{}, err
+	}
+
+	var d ArchiveDownload
+	var key string
+	err := db.db.QueryRow(`
+		SELECT COALESCE(download_hash, ''), COALESCE(download_filename, ''), COALESCE(download_mime_type, ''), COALESCE(download_size, 0)
+		FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&key, &d.Filename, &d.MIMEType, &d.Size)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchiveDownload{}, fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return ArchiveDownload{}, fmt.Errorf("failed to get archive download: %w", err)
+	}
+	if key == "" {
+		return ArchiveDownload{}, fmt.Errorf("no download for archive version: %d", versionID)
+	}
+	data, err := db.loadArchiveBlob(key)
+	if err != nil {
+		return ArchiveDownload{}, err
+	}
+	d.Data = []byte(data)
+	return d, nil
+}
+
+// SaveArchiveProvenance stores the provenance record (JSON) for the latest
+// version of a bookmark's archive.
+func (db *DB) SaveArchiveProvenance(bookmarkID int64, provenance string) error {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return err
+	}
+
+	res, err := db.db.Exec(`
+		UPDATE bookmark_archives
+		SET provenance = ?
+		WHERE id = (
+			SELECT id FROM bookmark_archives
+			WHERE bookmark_id = ?
+			ORDER BY captured_at DESC, id DESC
+			LIMIT 1
+		)
+	`, provenance, bookmarkID)
+	if err != nil {
+		return fmt.Errorf("failed to save archive provenance: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("no archive versions for bookmark: %d", bookmarkID)
+	}
+	return nil
+}
+
+// GetArchiveProvenance returns the provenance record (JSON) saved with a
+// version of a bookmark's archive.
+func (db *DB) GetArchiveProvenance(bookmarkID, versionID int64) (string, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return "", err
+	}
+
+	var provenance string
+	err := db.db.QueryRow(`
+		SELECT COALESCE(provenance, '') FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&provenance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return "", fmt.Errorf("failed to get archive provenance: %w", err)
+	}
+	if provenance == "" {
+		return "", fmt.Errorf("no provenance for archive version: %d", versionID)
+	}
+	return provenance, nil
+}
+
+// ArchiveVersionByContentHash returns the ID of the latest version of a
+// bookmark's archive whose HTML has the hex SHA-256 contentHash.
+func (db *DB) ArchiveVersionByContentHash(bookmarkID int64, contentHash string) (int64, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := db.db.QueryRow(`
+		SELECT id FROM bookmark_archives
+		WHERE bookmark_id = ? AND blob_hash = ?
+		ORDER BY captured_at DESC, id DESC
+		LIMIT 1
+	`, bookmarkID, contentHash).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("no archive version of bookmark %d with content hash %s", bookmarkID, contentHash)
+		}
+		return 0, fmt.Errorf("failed to look up archive version: %w", err)
+	}
+	return id, nil
+}
+
+// SaveArchiveTimestamp stores an RFC 3161 timestamp for version
+// ts.VersionID of bookmark ts.BookmarkID's archive. ts.ContentHash must
+// match that version's blob_hash, so a timestamp can't be attached to
+// content it doesn't cover.
+func (db *DB) SaveArchiveTimestamp(ts ArchiveTimestamp) error {
+	if err := db.checkOwner(ts.BookmarkID); err != nil {
+		return err
+	}
+
+	res, err := db.db.Exec(`
+		UPDATE bookmark_archives
+		SET timestamp_token = ?, timestamp_authority = ?, timestamped_at = ?
+		WHERE id = ? AND bookmark_id = ? AND blob_hash = ?
+	`, ts.Token, ts.Authority, ts.TimestampedAt, ts.VersionID, ts.BookmarkID, ts.ContentHash)
+	if err != nil {
+		return fmt.Errorf("failed to save archive timestamp: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("no archive version %d of bookmark %d with content hash %s", ts.VersionID, ts.BookmarkID, ts.ContentHash)
+	}
+	return nil
+}
+
+// AddArchiveVersion adds a complete archive version, such as one from
+// another instance's export, to a bookmark and reports whether it did: a
+// version captured at the same time is kept and v skipped. Unlike
+// SaveArchiveResult it leaves the bookmark's status alone unless v is newer
+// than its latest attempt, and emits no event. A timestamp whose content
+// hash doesn't match v.HTML is dropped.
+func (db *DB) AddArchiveVersion(bookmarkID int64, v NewArchiveVersion) (bool, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return false, err
+	}
+	capturedAt, err := time.Parse(time.RFC3339, v.CapturedAt)
+	if err != nil {
+		return false, fmt.Errorf("invalid capture time %q: %w", v.CapturedAt, err)
+	}
+	captured := capturedAt.Format(time.RFC3339)
+
+	var exists bool
+	if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM bookmark_archives WHERE bookmark_id = ? AND captured_at = ?)`, bookmarkID, captured).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up archive version: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	// Blobs are written before the transaction; if it fails they are
+	// released again.
+	var keys []string
+	put := func(data string) (string, error) {
+		key, err := db.putArchiveBlob(data)
+		if err == nil {
+			keys = append(keys, key)
+		}
+		return key, err
+	}
+	saved := false
+	defer func() {
+		if !saved {
+			db.releaseArchiveBlobs(keys)
+		}
+	}()
+	key, err := put(v.HTML)
+	if err != nil {
+		return false, err
+	}
+	var screenshotKey, downloadKey any
+	if len(v.Screenshot) > 0 {
+		if screenshotKey, err = put(string(v.Screenshot)); err != nil {
+			return false, err
+		}
+	}
+	var download ArchiveDownload
+	if v.Download != nil {
+		download = *v.Download
+		if downloadKey, err = put(string(download.Data)); err != nil {
+			return false, err
+		}
+	}
+	var provenance any
+	if v.Provenance != "" {
+		provenance = v.Provenance
+	}
+	var ts ArchiveTimestamp
+	if v.Timestamp != nil && v.Timestamp.ContentHash == key {
+		ts = *v.Timestamp
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+	res, err := tx.Exec(`
+		INSERT INTO bookmark_archives (
+			bookmark_id, captured_at, archived_url, blob_hash, html_size,
+			screenshot_hash, screenshot_size,
+			download_hash, download_filename, download_mime_type, download_size,
+			provenance, timestamp_token, timestamp_authority, timestamped_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, 0), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0), ?, ?, NULLIF(?, ''), NULLIF(?, ''))
+		ON CONFLICT (bookmark_id, captured_at) DO NOTHING
+	`, bookmarkID, captured, v.ArchivedURL, key, len(v.HTML),
+		screenshotKey, len(v.Screenshot),
+		downloadKey, download.Filename, download.MIMEType, len(download.Data),
+		provenance, ts.Token, ts.Authority, ts.TimestampedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to save archive version: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to determine rows affected: %w", err)
+	} else if n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(`
+		UPDATE bookmarks
+		SET archived_at = ?1
+		WHERE id = ?2 AND (archived_at IS NULL OR archived_at < ?1)
+	`, captured, bookmarkID); err != nil {
+		return false, fmt.Errorf("failed to update archive date: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE bookmarks
+		SET archive_attempted_at = ?1, archive_status = 'ok', archive_error = '', archive_error_code = NULL
+		WHERE id = ?2 AND (archive_attempted_at IS NULL OR archive_attempted_at < ?1)
+	`, captured, bookmarkID); err != nil {
+		return false, fmt.Errorf("failed to update archive status: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit archive version: %w", err)
+	}
+	saved = true
+	return true, nil
+}
+
+// GetArchiveTimestamp returns the timestamp saved with a version of a
+// bookmark's archive.
+func (db *DB) GetArchiveTimestamp(bookmarkID, versionID int64) (ArchiveTimestamp, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return ArchiveTimestamp{}, err
+	}
+
+	ts := ArchiveTimestamp{BookmarkID: bookmarkID, VersionID: versionID}
+	err := db.db.QueryRow(`
+		SELECT COALESCE(blob_hash, ''), timestamp_token, COALESCE(timestamp_authority, ''), COALESCE(timestamped_at, '')
+		FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&ts.ContentHash, &ts.Token, &ts.Authority, &ts.TimestampedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchiveTimestamp{}, fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return ArchiveTimestamp{}, fmt.Errorf("failed to get archive timestamp: %w", err)
+	}
+	if len(ts.Token) == 0 {
+		return ArchiveTimestamp{}, fmt.Errorf("no timestamp for archive version: %d", versionID)
+	}
+	return ts, nil
+}
+
+// SaveBookmarkReadable stores the reader-mode extraction for the latest
+// version of a bookmark's archive.
+func (db *DB) SaveBookmarkReadable(r BookmarkReadable) error {
+	if err := db.checkOwner(r.BookmarkID); err != nil {
+		return err
+	}
+
+	res, err := db.db.Exec(`
+		UPDATE bookmark_archives
+		SET
+			readable_title = ?,
+			readable_byline = ?,
+			readable_html = ?,
+			readable_text = ?
+		WHERE id = (
+			SELECT id FROM bookmark_archives
+			WHERE bookmark_id = ?
+			ORDER BY captured_at DESC, id DESC
+			LIMIT 1
+		)
+	`,
+		r.Title,
+		r.Byline,
+		r.Content,
+		r.TextContent,
+		r.BookmarkID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save readable content: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("no archive versions for bookmark: %d", r.BookmarkID)
+	}
+	return nil
+}
+
+// GetBookmarkReadable returns the reader-mode extraction for the latest version
+// of a bookmark's archive. Fields are empty if nothing has been extracted yet.
+func (db *DB) GetBookmarkReadable(id int64) (BookmarkReadable, error) {
+	if err := db.checkOwner(id); err != nil {
+		return BookmarkReadable{}, err
+	}
+
+	var r BookmarkReadable
+	err := db.db.QueryRow(`
+		SELECT
+			b.id,
+			COALESCE(v.readable_title, ''),
+			COALESCE(v.readable_byline, ''),
+			COALESCE(v.readable_html, ''),
+			COALESCE(v.readable_text, '')
+		FROM bookmarks b
+		LEFT JOIN bookmark_archives v ON v.id = (
+			SELECT id FROM bookmark_archives
+			WHERE bookmark_id = b.id
+			ORDER BY captured_at DESC, id DESC
+			LIMIT 1
+		)
+		WHERE b.id = ?
+	`, id).Scan(
+		&r.BookmarkID,
+		&r.Title,
+		&r.Byline,
+		&r.Content,
+		&r.TextContent,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BookmarkReadable{}, fmt.Errorf("bookmark not found: %d", id)
+		}
+		return BookmarkReadable{}, fmt.Errorf("failed to get readable content: %w", err)
+	}
+	return r, nil
+}
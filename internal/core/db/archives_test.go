@@ -67,6 +67,31 @@ func TestListBookmarksToArchive(t *testing.T) {
 		}
 	})
 
+	t.Run("excludes skipped bookmarks", func(t *testing.T) {
+		db2 := newTestDB(t)
+		t.Cleanup(func() {
+			if err := db2.Close(); err != nil {
+				t.Errorf("failed to close db2: %v", err)
+			}
+		})
+
+		id, err := db2.AddBookmark("https://noarchive.example", "No archive")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := db2.SaveArchiveResult(id, time.Now(), nil, "skipped", "noarchive meta tag", "", ""); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		bookmarks, err := db2.ListBookmarksToArchive(0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(bookmarks) != 0 {
+			t.Errorf("expected skipped bookmark to be left alone, got %d", len(bookmarks))
+		}
+	})
+
 	t.Run("respects limit", func(t *testing.T) {
 		db3 := newTestDB(t)
 		t.Cleanup(func() {
@@ -355,6 +380,104 @@ func TestSaveArchiveResult(t *testing.T) {
 		}
 	})
 
+	t.Run("saves failure error code", func(t *testing.T) {
+		id, err := db.AddBookmark("https://code.com", "Code")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := db.SaveArchiveFailure(id, time.Now(), "error", "dns", "no such host"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		archive, _ := db.GetBookmarkArchive(id)
+		if archive.ArchiveErrorCode != "dns" || archive.ArchiveError != "no such host" {
+			t.Errorf("expected dns failure, got code=%q error=%q", archive.ArchiveErrorCode, archive.ArchiveError)
+		}
+
+		now := time.Now()
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://code.com", "<html></html>"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		archive, _ = db.GetBookmarkArchive(id)
+		if archive.ArchiveErrorCode != "" {
+			t.Errorf("expected success to clear the error code, got %q", archive.ArchiveErrorCode)
+		}
+	})
+
+	t.Run("saves and clears the wayback URL", func(t *testing.T) {
+		id, err := db.AddBookmark("https://wayback.com", "Wayback")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		snapshot := "https://web.archive.org/web/20200101000000/https://wayback.com"
+		if err := db.SetWaybackURL(id, snapshot); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if archive, _ := db.GetBookmarkArchive(id); archive.WaybackURL != snapshot {
+			t.Errorf("expected wayback URL %q, got %q", snapshot, archive.WaybackURL)
+		}
+		if err := db.SetWaybackURL(id, ""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if archive, _ := db.GetBookmarkArchive(id); archive.WaybackURL != "" {
+			t.Errorf("expected the wayback URL to be cleared, got %q", archive.WaybackURL)
+		}
+		if err := db.SetWaybackURL(99999, snapshot); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+	})
+
+	t.Run("saves the archive.today snapshot and opt-out", func(t *testing.T) {
+		id, err := db.CreateBookmark(NewBookmark{URL: "https://archivetoday.com", SkipArchiveToday: true})
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if archive, _ := db.GetBookmarkArchive(id); !archive.ArchiveTodayDisabled {
+			t.Error("expected the bookmark to be kept from archive.today")
+		}
+		if err := db.SetArchiveTodayDisabled(id, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		snapshot := "https://archive.ph/AbCd1"
+		if err := db.SetArchiveTodayURL(id, snapshot); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		archive, _ := db.GetBookmarkArchive(id)
+		if archive.ArchiveTodayDisabled || archive.ArchiveTodayURL != snapshot {
+			t.Errorf("expected snapshot %q and no opt-out, got %q, %v", snapshot, archive.ArchiveTodayURL, archive.ArchiveTodayDisabled)
+		}
+		if err := db.SetArchiveTodayURL(99999, snapshot); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+		if err := db.SetArchiveTodayDisabled(99999, true); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+	})
+
+	t.Run("failed re-archive keeps the last snapshot", func(t *testing.T) {
+		id, err := db.AddBookmark("https://rearchive.com", "Rearchive")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		archivedAt := time.Now().Add(-time.Hour)
+		if err := db.SaveArchiveResult(id, archivedAt, &archivedAt, "ok", "", "https://rearchive.com", "<html>v1</html>"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.SaveArchiveResult(id, time.Now(), nil, "error", "timeout", "", ""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		archive, err := db.GetBookmarkArchive(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if archive.ArchiveStatus != "error" || archive.ArchiveError != "timeout" {
+			t.Errorf("expected latest attempt to be the failure, got %+v", archive)
+		}
+		if archive.ArchivedAt != archivedAt.Format(time.RFC3339) || archive.ArchivedHTML != "<html>v1</html>" {
+			t.Errorf("expected last good snapshot to be kept, got archived_at=%q html=%q", archive.ArchivedAt, archive.ArchivedHTML)
+		}
+	})
+
 	t.Run("returns error for non-existent bookmark", func(t *testing.T) {
 		err := db.SaveArchiveResult(99999, time.Now(), nil, "ok", "", "", "")
 		if err == nil {
@@ -456,3 +579,304 @@ func TestQueueBookmarkForArchive(t *testing.T) {
 		}
 	})
 }
+
+// TestBookmarkReadable tests saving and retrieving reader-mode content.
+func TestBookmarkReadable(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("round-trips readable content", func(t *testing.T) {
+		id, err := db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		want := BookmarkReadable{
+			BookmarkID:  id,
+			Title:       "Headline",
+			Byline:      "Jane Doe",
+			Content:     "<p>Body</p>",
+			TextContent: "Body",
+		}
+		if err := db.SaveBookmarkReadable(want); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got, err := db.GetBookmarkReadable(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("readable content belongs to the latest version", func(t *testing.T) {
+		id, err := db.AddBookmark("https://versions.com", "Versions")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		first := time.Now()
+		if err := db.SaveArchiveResult(id, first, &first, "ok", "", "https://versions.com", "<html>v1</html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		if err := db.SaveBookmarkReadable(BookmarkReadable{BookmarkID: id, Content: "<p>v1</p>"}); err != nil {
+			t.Fatalf("failed to save readable: %v", err)
+		}
+
+		second := first.Add(time.Minute)
+		if err := db.SaveArchiveResult(id, second, &second, "ok", "", "https://versions.com", "<html>v2</html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		got, err := db.GetBookmarkReadable(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Content != "" {
+			t.Errorf("expected no readable content for new version, got %q", got.Content)
+		}
+	})
+
+	t.Run("returns empty content for bookmark without versions", func(t *testing.T) {
+		id, err := db.AddBookmark("https://empty.com", "Empty")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		got, err := db.GetBookmarkReadable(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Content != "" {
+			t.Errorf("expected empty content, got %q", got.Content)
+		}
+		if err := db.SaveBookmarkReadable(BookmarkReadable{BookmarkID: id}); err == nil {
+			t.Error("expected error saving readable without an archive version")
+		}
+	})
+
+	t.Run("returns error for non-existent bookmark", func(t *testing.T) {
+		if err := db.SaveBookmarkReadable(BookmarkReadable{BookmarkID: 99999}); err == nil {
+			t.Error("expected error saving for non-existent bookmark")
+		}
+		if _, err := db.GetBookmarkReadable(99999); err == nil {
+			t.Error("expected error getting non-existent bookmark")
+		}
+	})
+}
+
+// TestArchiveVersions tests that each successful archive is kept as a version.
+func TestArchiveVersions(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("refetch adds a new version", func(t *testing.T) {
+		id, err := db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+
+		first := time.Now().Add(-time.Hour)
+		if err := db.SaveArchiveResult(id, first, &first, "ok", "", "https://example.com", "<html>v1</html>"); err != nil {
+			t.Fatalf("failed to save first archive: %v", err)
+		}
+		if err := db.ClearBookmarkArchive(id); err != nil {
+			t.Fatalf("failed to clear archive: %v", err)
+		}
+		second := time.Now()
+		if err := db.SaveArchiveResult(id, second, &second, "ok", "", "https://example.com", "<html>v2</html>"); err != nil {
+			t.Fatalf("failed to save second archive: %v", err)
+		}
+
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(versions) != 2 {
+			t.Fatalf("expected 2 versions, got %d", len(versions))
+		}
+		if versions[0].CapturedAt != second.Format(time.RFC3339) {
+			t.Errorf("expected newest version first, got %q", versions[0].CapturedAt)
+		}
+		if versions[0].ArchivedHTML != "" {
+			t.Error("expected listing to omit HTML")
+		}
+
+		old, err := db.GetArchiveVersion(id, versions[1].ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if old.ArchivedHTML != "<html>v1</html>" {
+			t.Errorf("expected first version HTML, got %q", old.ArchivedHTML)
+		}
+
+		latest, err := db.GetLatestArchiveVersion(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if latest.ArchivedHTML != "<html>v2</html>" {
+			t.Errorf("expected latest version HTML, got %q", latest.ArchivedHTML)
+		}
+	})
+
+	t.Run("records each version's size", func(t *testing.T) {
+		id, err := db.AddBookmark("https://sized.example", "Sized")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://sized.example", "<html>12345</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := db.SaveArchiveScreenshot(id, make([]byte, 100)); err != nil {
+			t.Fatalf("failed to save screenshot: %v", err)
+		}
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(versions) != 1 || versions[0].Size != 118 {
+			t.Fatalf("expected one version of 118 bytes, got %+v", versions)
+		}
+		if v, err := db.GetArchiveVersion(id, versions[0].ID); err != nil || v.Size != 118 {
+			t.Errorf("expected the version's size, got %d, %v", v.Size, err)
+		}
+		if a, err := db.GetBookmarkArchiveStatus(id); err != nil || a.Size != 118 {
+			t.Errorf("expected the latest archive's size, got %d, %v", a.Size, err)
+		}
+
+		if _, err := db.db.Exec(`UPDATE bookmark_archives SET html_size = NULL WHERE bookmark_id = ?`, id); err != nil {
+			t.Fatalf("failed to clear size: %v", err)
+		}
+		if a, err := db.GetBookmarkArchiveStatus(id); err != nil || a.Size != 0 {
+			t.Errorf("expected an unknown size for older versions, got %d, %v", a.Size, err)
+		}
+	})
+
+	t.Run("failed attempts do not add versions", func(t *testing.T) {
+		id, err := db.AddBookmark("https://fail.com", "Fail")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := db.SaveArchiveResult(id, time.Now(), nil, "error", "timeout", "", ""); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(versions) != 0 {
+			t.Errorf("expected no versions, got %d", len(versions))
+		}
+		if _, err := db.GetLatestArchiveVersion(id); err == nil {
+			t.Error("expected error for bookmark without versions")
+		}
+	})
+
+	t.Run("version lookup is scoped to the bookmark", func(t *testing.T) {
+		a, _ := db.AddBookmark("https://a.com", "A")
+		b, _ := db.AddBookmark("https://b.com", "B")
+		now := time.Now()
+		if err := db.SaveArchiveResult(a, now, &now, "ok", "", "https://a.com", "<html>a</html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		versions, _ := db.ListArchiveVersions(a)
+		if _, err := db.GetArchiveVersion(b, versions[0].ID); err == nil {
+			t.Error("expected error fetching another bookmark's version")
+		}
+	})
+
+	t.Run("deleting the bookmark removes its versions", func(t *testing.T) {
+		id, _ := db.AddBookmark("https://gone.com", "Gone")
+		now := time.Now()
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://gone.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(versions) != 0 {
+			t.Errorf("expected versions to be deleted, got %d", len(versions))
+		}
+	})
+}
+
+func TestAddArchiveVersion(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	captured := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC).Format(time.RFC3339)
+	html := "<html>merged</html>"
+	v := NewArchiveVersion{
+		CapturedAt:  captured,
+		ArchivedURL: "https://example.com/",
+		HTML:        html,
+		Screenshot:  []byte("png"),
+		Download:    &ArchiveDownload{Filename: "a.pdf", MIMEType: "application/pdf", Data: []byte("%PDF")},
+		Provenance:  `{"egress":"direct"}`,
+		Timestamp:   &ArchiveTimestamp{ContentHash: archiveBlobKey(html), Authority: "https://tsa.example", TimestampedAt: captured, Token: []byte{1, 2}},
+	}
+	added, err := db.AddArchiveVersion(id, v)
+	if err != nil || !added {
+		t.Fatalf("AddArchiveVersion() = %v, %v", added, err)
+	}
+	versions, err := db.ListArchiveVersions(id)
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("expected one version, got %+v, %v", versions, err)
+	}
+	got := versions[0]
+	if !got.HasScreenshot || !got.HasDownload || !got.HasProvenance || !got.HasTimestamp || got.CapturedAt != captured {
+		t.Errorf("expected everything to be saved with the version, got %+v", got)
+	}
+	archive, err := db.GetBookmarkArchiveStatus(id)
+	if err != nil || archive.ArchiveStatus != "ok" || archive.ArchivedAt != captured {
+		t.Errorf("expected the bookmark to count as archived, got %+v, %v", archive, err)
+	}
+
+	// A second version at the same time is skipped, however it differs.
+	v.HTML = "<html>other</html>"
+	if added, err := db.AddArchiveVersion(id, v); err != nil || added {
+		t.Errorf("expected a version captured at the same time to be skipped, got %v, %v", added, err)
+	}
+
+	// A timestamp of other content is dropped.
+	v.CapturedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	if added, err := db.AddArchiveVersion(id, v); err != nil || !added {
+		t.Fatalf("AddArchiveVersion() = %v, %v", added, err)
+	}
+	versions, _ = db.ListArchiveVersions(id)
+	if len(versions) != 2 || versions[1].HasTimestamp {
+		t.Errorf("expected the older version without its mismatched timestamp, got %+v", versions)
+	}
+	if archive, _ := db.GetBookmarkArchiveStatus(id); archive.ArchivedAt != captured {
+		t.Errorf("expected an older version to leave the archive date alone, got %q", archive.ArchivedAt)
+	}
+
+	if _, err := db.AddArchiveVersion(id, NewArchiveVersion{CapturedAt: "yesterday"}); err == nil {
+		t.Error("expected an error for an invalid capture time")
+	}
+}
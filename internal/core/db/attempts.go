@@ -0,0 +1,75 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// maxArchiveAttempts is how many of a bookmark's latest archive attempts
+// are kept.
+const maxArchiveAttempts = 50
+
+// RecordArchiveAttempt adds an archive attempt to its bookmark's history,
+// dropping the oldest beyond the latest maxArchiveAttempts.
+func (db *DB) RecordArchiveAttempt(a ArchiveAttempt) (int64, error) {
+	if err := db.checkOwner(a.BookmarkID); err != nil {
+		return 0, err
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO archive_attempts (bookmark_id, job_id, attempt, started_at, finished_at, status, error_code, error, next_attempt_at)
+		VALUES (?, NULLIF(?, 0), ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))
+	`, a.BookmarkID, a.JobID, a.Attempt, a.StartedAt, a.FinishedAt, a.Status, a.ErrorCode, a.Error, a.NextAttemptAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record archive attempt: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get archive attempt ID: %w", err)
+	}
+	if _, err := db.db.Exec(`
+		DELETE FROM archive_attempts
+		WHERE bookmark_id = ? AND id NOT IN (
+			SELECT id FROM archive_attempts WHERE bookmark_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, a.BookmarkID, a.BookmarkID, maxArchiveAttempts); err != nil {
+		return 0, fmt.Errorf("failed to prune archive attempts: %w", err)
+	}
+	return id, nil
+}
+
+// ListArchiveAttempts returns a bookmark's recorded archive attempts, newest
+// first.
+func (db *DB) ListArchiveAttempts(bookmarkID int64) ([]ArchiveAttempt, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return nil, err
+	}
+	rows, err := db.db.Query(`
+		SELECT id, bookmark_id, COALESCE(job_id, 0), attempt, started_at, finished_at, COALESCE(next_attempt_at, ''),
+			status, COALESCE(error_code, ''), COALESCE(error, '')
+		FROM archive_attempts
+		WHERE bookmark_id = ?
+		ORDER BY id DESC
+	`, bookmarkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive attempts: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var out []ArchiveAttempt
+	for rows.Next() {
+		var a ArchiveAttempt
+		if err := rows.Scan(&a.ID, &a.BookmarkID, &a.JobID, &a.Attempt, &a.StartedAt, &a.FinishedAt, &a.NextAttemptAt,
+			&a.Status, &a.ErrorCode, &a.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan archive attempt: %w", err)
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate archive attempts: %w", err)
+	}
+	return out, nil
+}
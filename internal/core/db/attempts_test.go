@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestArchiveAttempts(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := 1; i <= maxArchiveAttempts+2; i++ {
+		a := ArchiveAttempt{BookmarkID: id, JobID: 7, Attempt: i, StartedAt: now, FinishedAt: now, Status: "error", ErrorCode: "network", Error: fmt.Sprintf("attempt %d", i)}
+		if _, err := db.RecordArchiveAttempt(a); err != nil {
+			t.Fatalf("RecordArchiveAttempt() error = %v", err)
+		}
+	}
+
+	attempts, err := db.ListArchiveAttempts(id)
+	if err != nil {
+		t.Fatalf("ListArchiveAttempts() error = %v", err)
+	}
+	if len(attempts) != maxArchiveAttempts {
+		t.Fatalf("expected the latest %d attempts, got %d", maxArchiveAttempts, len(attempts))
+	}
+	if a := attempts[0]; a.Attempt != maxArchiveAttempts+2 || a.JobID != 7 || a.ErrorCode != "network" || a.NextAttemptAt != "" {
+		t.Errorf("unexpected newest attempt: %+v", a)
+	}
+	if attempts[len(attempts)-1].Attempt != 3 {
+		t.Errorf("expected the oldest attempts to be dropped, got %+v", attempts[len(attempts)-1])
+	}
+
+	if err := db.DeleteBookmark(id); err != nil {
+		t.Fatalf("DeleteBookmark() error = %v", err)
+	}
+	var n int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM archive_attempts`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected attempts to be deleted with the bookmark, got %d (err=%v)", n, err)
+	}
+}
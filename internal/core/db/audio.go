@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// SaveArchiveAudio attaches a spoken version of the article to the latest
+// version of a bookmark's archive, replacing any previous one. The audio is
+// kept in the blob store alongside the HTML.
+func (db *DB) SaveArchiveAudio(bookmarkID int64, a ArchiveAudio) error {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return err
+	}
+
+	key, err := db.putArchiveBlob(string(a.Data))
+	if err != nil {
+		return err
+	}
+
+	var versionID int64
+	var prev string
+	err = db.db.QueryRow(`
+		SELECT id, COALESCE(audio_hash, '') FROM bookmark_archives
+		WHERE bookmark_id = ?
+		ORDER BY captured_at DESC, id DESC
+		LIMIT 1
+	`, bookmarkID).Scan(&versionID, &prev)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			db.releaseArchiveBlobs([]string{key})
+			return fmt.Errorf("no archive versions for bookmark: %d", bookmarkID)
+		}
+		return fmt.Errorf("failed to look up latest archive version: %w", err)
+	}
+
+	if _, err := db.db.Exec(`
+		UPDATE bookmark_archives
+		SET audio_hash = ?, audio_mime_type = ?, audio_size = ?
+		WHERE id = ?
+	`, key, a.MIMEType, len(a.Data), versionID); err != nil {
+		return fmt.Errorf("failed to save archive audio: %w", err)
+	}
+	if prev != "" && prev != key {
+		db.releaseArchiveBlobs([]string{prev})
+	}
+	return nil
+}
+
+// GetArchiveAudio returns the spoken article saved with a version of a
+// bookmark's archive.
+func (db *DB) GetArchiveAudio(bookmarkID, versionID int64) (ArchiveAudio, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return ArchiveAudio{}, err
+	}
+
+	var a ArchiveAudio
+	var key string
+	err := db.db.QueryRow(`
+		SELECT COALESCE(audio_hash, ''), COALESCE(audio_mime_type, ''), COALESCE(audio_size, 0)
+		FROM bookmark_archives
+		WHERE bookmark_id = ? AND id = ?
+	`, bookmarkID, versionID).Scan(&key, &a.MIMEType, &a.Size)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ArchiveAudio{}, fmt.Errorf("archive version not found: %d", versionID)
+		}
+		return ArchiveAudio{}, fmt.Errorf("failed to get archive audio: %w", err)
+	}
+	if key == "" {
+		return ArchiveAudio{}, fmt.Errorf("no audio for archive version: %d", versionID)
+	}
+	data, err := db.loadArchiveBlob(key)
+	if err != nil {
+		return ArchiveAudio{}, err
+	}
+	a.Data = []byte(data)
+	return a, nil
+}
+
+// ListAudioEpisodes returns the bookmarks tagged tag whose latest archive
+// version has audio, most recently captured first, up to limit of them.
+func (db *DB) ListAudioEpisodes(tag string, limit int) ([]AudioEpisode, error) {
+	args := append([]any{tag}, db.owner()...)
+	args = append(args, limit)
+	rows, err := db.db.Query(`
+		SELECT
+			b.id,
+			v.id,
+			b.url,
+			COALESCE(NULLIF(v.readable_title, ''), NULLIF(b.title, ''), b.url),
+			COALESCE(m.description, ''),
+			v.captured_at,
+			COALESCE(v.audio_mime_type, ''),
+			COALESCE(v.audio_size, 0)
+		FROM bookmarks b
+		JOIN bookmark_tags bt ON bt.bookmark_id = b.id
+		JOIN tags t ON t.id = bt.tag_id AND t.name = ?
+		JOIN bookmark_archives v ON v.id = (
+			SELECT id FROM bookmark_archives
+			WHERE bookmark_id = b.id
+			ORDER BY captured_at DESC, id DESC
+			LIMIT 1
+		)
+		LEFT JOIN bookmark_metadata m ON m.bookmark_id = b.id
+		WHERE v.audio_hash IS NOT NULL AND `+ownerFilter("b.user_id")+`
+		ORDER BY v.captured_at DESC, b.id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio episodes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var out []AudioEpisode
+	for rows.Next() {
+		var e AudioEpisode
+		if err := rows.Scan(&e.BookmarkID, &e.VersionID, &e.URL, &e.Title, &e.Description, &e.CapturedAt, &e.MIMEType, &e.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan audio episode: %w", err)
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audio episodes: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchiveAudio(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com/essay", "Essay")
+	audio := ArchiveAudio{MIMEType: "audio/mpeg", Data: []byte("ID3 spoken essay")}
+
+	t.Run("requires an archive version", func(t *testing.T) {
+		if err := db.SaveArchiveAudio(id, audio); err == nil {
+			t.Error("expected error without an archive version")
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected unused blob to be released, got %d blobs", n)
+		}
+	})
+
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/essay", "<html>essay</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.AddBookmarkTags(id, []string{"listen"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	v, err := db.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+
+	t.Run("save and load", func(t *testing.T) {
+		if err := db.SaveArchiveAudio(id, audio); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.GetArchiveAudio(id, v.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.MIMEType != audio.MIMEType || got.Size != int64(len(audio.Data)) || string(got.Data) != string(audio.Data) {
+			t.Errorf("expected the audio to round-trip, got %+v", got)
+		}
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("failed to list versions: %v", err)
+		}
+		if len(versions) != 1 || !versions[0].HasAudio {
+			t.Errorf("expected the version to have audio, got %+v", versions)
+		}
+	})
+
+	t.Run("lists episodes by tag", func(t *testing.T) {
+		other, _ := db.AddBookmark("https://example.com/other", "Other")
+		if err := db.SaveArchiveResult(other, now, &now, "ok", "", "https://example.com/other", "<html>other</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := db.AddBookmarkTags(other, []string{"listen"}); err != nil {
+			t.Fatalf("failed to tag bookmark: %v", err)
+		}
+
+		episodes, err := db.ListAudioEpisodes("listen", 10)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(episodes) != 1 || episodes[0].BookmarkID != id || episodes[0].VersionID != v.ID || episodes[0].Title != "Essay" || episodes[0].Size != int64(len(audio.Data)) {
+			t.Errorf("expected only the bookmark with audio, got %+v", episodes)
+		}
+		if episodes, err := db.ListAudioEpisodes("other", 10); err != nil || len(episodes) != 0 {
+			t.Errorf("expected no episodes for another tag, got %+v, %v", episodes, err)
+		}
+	})
+
+	t.Run("recapturing the version releases the audio", func(t *testing.T) {
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/essay", "<html>essay</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if _, err := db.GetArchiveAudio(id, v.ID); err == nil {
+			t.Error("expected the replaced version to have no audio")
+		}
+		var n int
+		if err := db.db.QueryRow(`SELECT COUNT(*) FROM archive_blobs WHERE hash = ?`, archiveBlobKey(string(audio.Data))).Scan(&n); err != nil {
+			t.Fatalf("failed to count blobs: %v", err)
+		}
+		if n != 0 {
+			t.Error("expected the audio blob to be released")
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// bookmarkTables are the tables whose rows belong to a bookmark, in the
+// order DeleteBookmark removes them. It deletes them one at a time, so a
+// crash part way through can leave rows of a deleted bookmark behind.
+var bookmarkTables = []string{
+	"bookmark_archives", "bookmark_tags", "bookmark_metadata", "bookmark_favicons",
+	"bookmark_links", "jobs", "archive_attempts",
+}
+
+// orphanCondition matches rows of a bookmarkTables table whose bookmark no
+// longer exists.
+const orphanCondition = `NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.id = bookmark_id)`
+
+// OrphanRows counts a bookmarkTables table's rows that belong to a deleted
+// bookmark.
+type OrphanRows struct {
+	Table string `json:"table"`
+	Count int    `json:"count"`
+}
+
+// ListArchiveContent returns every snapshot of every bookmark, newest first
+// within each bookmark; the first of each bookmark has Latest set.
+func (db *DB) ListArchiveContent() ([]ArchiveContentRef, error) {
+	rows, err := db.db.Query(`
+		SELECT a.bookmark_id, b.url, a.id, COALESCE(a.blob_hash, ''), COALESCE(a.html_size, -1), COALESCE(a.download_hash, '')
+		FROM bookmark_archives a
+		JOIN bookmarks b ON b.id = a.bookmark_id
+		WHERE `+ownerFilter("b.user_id")+`
+		ORDER BY a.bookmark_id, a.captured_at DESC, a.id DESC
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive content: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var refs []ArchiveContentRef
+	for rows.Next() {
+		var r ArchiveContentRef
+		if err := rows.Scan(&r.BookmarkID, &r.URL, &r.VersionID, &r.BlobHash, &r.HTMLSize, &r.DownloadHash); err != nil {
+			return nil, fmt.Errorf("failed to scan archive content: %w", err)
+		}
+		r.Latest = len(refs) == 0 || refs[len(refs)-1].BookmarkID != r.BookmarkID
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// ArchiveStatusProblem is a bookmark whose archive status columns
+// contradict each other or its snapshots.
+type ArchiveStatusProblem struct {
+	BookmarkID int64
+	URL        string
+	Problem    string
+}
+
+// ListArchiveStatusProblems returns bookmarks with snapshots but no archive
+// status, an ok status but no archived_at or no snapshot, or a status but
+// no archive_attempted_at. Every archive result sets all three columns
+// together, so these only come from interrupted writes or hand edits.
+func (db *DB) ListArchiveStatusProblems() ([]ArchiveStatusProblem, error) {
+	rows, err := db.db.Query(`
+		SELECT id, url, problem FROM (
+			SELECT b.id, b.url, CASE
+				WHEN COALESCE(b.archive_status, '') = '' AND EXISTS (SELECT 1 FROM bookmark_archives a WHERE a.bookmark_id = b.id)
+					THEN 'has snapshots but no archive status'
+				WHEN b.archive_status = 'ok' AND b.archived_at IS NULL
+					THEN 'archived but has no archived_at'
+				WHEN b.archive_status = 'ok' AND NOT EXISTS (SELECT 1 FROM bookmark_archives a WHERE a.bookmark_id = b.id)
+					THEN 'archived but has no snapshot'
+				WHEN COALESCE(b.archive_status, '') != '' AND b.archive_attempted_at IS NULL
+					THEN 'has an archive status but no attempt time'
+			END AS problem
+			FROM bookmarks b
+			WHERE `+ownerFilter("b.user_id")+`
+		)
+		WHERE problem IS NOT NULL
+		ORDER BY id
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive status problems: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var problems []ArchiveStatusProblem
+	for rows.Next() {
+		var p ArchiveStatusProblem
+		if err := rows.Scan(&p.BookmarkID, &p.URL, &p.Problem); err != nil {
+			return nil, fmt.Errorf("failed to scan archive status problem: %w", err)
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+// CountOrphanRows counts, per bookmarkTables table, the rows of deleted
+// bookmarks, leaving out tables that have none.
+func (db *DB) CountOrphanRows() ([]OrphanRows, error) {
+	var counts []OrphanRows
+	for _, table := range bookmarkTables {
+		var n int
+		if err := db.db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE ` + orphanCondition).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count orphaned rows in %s: %w", table, err)
+		}
+		if n > 0 {
+			counts = append(counts, OrphanRows{Table: table, Count: n})
+		}
+	}
+	return counts, nil
+}
+
+// DeleteOrphanRows deletes the rows CountOrphanRows counts and returns how
+// many it deleted from each table. Blobs only orphaned snapshots referred
+// to are released.
+func (db *DB) DeleteOrphanRows() ([]OrphanRows, error) {
+	var keys []string
+	rows, err := db.db.Query(`
+		SELECT blob_hash FROM bookmark_archives WHERE blob_hash IS NOT NULL AND ` + orphanCondition + `
+		UNION SELECT screenshot_hash FROM bookmark_archives WHERE screenshot_hash IS NOT NULL AND ` + orphanCondition + `
+		UNION SELECT download_hash FROM bookmark_archives WHERE download_hash IS NOT NULL AND ` + orphanCondition + `
+		UNION SELECT audio_hash FROM bookmark_archives WHERE audio_hash IS NOT NULL AND ` + orphanCondition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphaned archive blobs: %w", err)
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan archive blob key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to list orphaned archive blobs: %w", err)
+	}
+
+	var deleted []OrphanRows
+	for _, table := range bookmarkTables {
+		res, err := db.db.Exec(`DELETE FROM ` + table + ` WHERE ` + orphanCondition)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete orphaned rows in %s: %w", table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to determine rows affected: %w", err)
+		}
+		if n > 0 {
+			deleted = append(deleted, OrphanRows{Table: table, Count: int(n)})
+		}
+		if table == "bookmark_archives" {
+			db.releaseArchiveBlobs(keys)
+		}
+	}
+	return deleted, nil
+}
+
+// unreferencedBlobCondition matches archive_blobs rows no snapshot refers
+// to that were stored before a cutoff. Blobs are written before the
+// snapshot that refers to them, so newer ones may be about to be used.
+const unreferencedBlobCondition = `
+	julianday(created_at) < julianday(?)
+	AND NOT EXISTS (
+		SELECT 1 FROM bookmark_archives a
+		WHERE a.blob_hash = archive_blobs.hash OR a.screenshot_hash = archive_blobs.hash
+			OR a.download_hash = archive_blobs.hash OR a.audio_hash = archive_blobs.hash
+	)`
+
+// CountUnreferencedBlobs counts the archive_blobs rows stored before
+// olderThan that no snapshot refers to, and their total size. Only the
+// database's own store is checked; directory and S3 stores can't be listed.
+func (db *DB) CountUnreferencedBlobs(olderThan time.Time) (int, int64, error) {
+	var n int
+	var size int64
+	err := db.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0) FROM archive_blobs WHERE `+unreferencedBlobCondition,
+		olderThan.Format(time.RFC3339)).Scan(&n, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count unreferenced archive blobs: %w", err)
+	}
+	return n, size, nil
+}
+
+// DeleteUnreferencedBlobs deletes the blobs CountUnreferencedBlobs counts
+// and returns how many it deleted.
+func (db *DB) DeleteUnreferencedBlobs(olderThan time.Time) (int64, error) {
+	res, err := db.db.Exec(`DELETE FROM archive_blobs WHERE `+unreferencedBlobCondition, olderThan.Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unreferenced archive blobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
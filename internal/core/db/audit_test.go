@@ -0,0 +1,120 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArchiveStatusProblems(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	now := time.Now()
+
+	fine, _ := db.AddBookmark("https://fine.example.com", "")
+	if err := db.SaveArchiveResult(fine, now, &now, "ok", "", "", "<p>fine</p>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if _, err := db.AddBookmark("https://unarchived.example.com", ""); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	noStatus, _ := db.AddBookmark("https://nostatus.example.com", "")
+	noArchivedAt, _ := db.AddBookmark("https://noarchivedat.example.com", "")
+	noAttempt, _ := db.AddBookmark("https://noattempt.example.com", "")
+	for _, id := range []int64{noStatus, noArchivedAt, noAttempt} {
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "", "<p>x</p>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+	}
+	// As an interrupted write might leave them.
+	for query, id := range map[string]int64{
+		`UPDATE bookmarks SET archive_status = NULL WHERE id = ?`:       noStatus,
+		`UPDATE bookmarks SET archived_at = NULL WHERE id = ?`:          noArchivedAt,
+		`UPDATE bookmarks SET archive_attempted_at = NULL WHERE id = ?`: noAttempt,
+	} {
+		if _, err := db.db.Exec(query, id); err != nil {
+			t.Fatalf("failed to update bookmark: %v", err)
+		}
+	}
+
+	problems, err := db.ListArchiveStatusProblems()
+	if err != nil {
+		t.Fatalf("ListArchiveStatusProblems() error = %v", err)
+	}
+	want := []ArchiveStatusProblem{
+		{noStatus, "https://nostatus.example.com", "has snapshots but no archive status"},
+		{noArchivedAt, "https://noarchivedat.example.com", "archived but has no archived_at"},
+		{noAttempt, "https://noattempt.example.com", "has an archive status but no attempt time"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("ListArchiveStatusProblems() = %+v, want %+v", problems, want)
+	}
+}
+
+func TestOrphanRows(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	now := time.Now()
+
+	kept, err := db.CreateBookmark(NewBookmark{URL: "https://kept.example.com", Tags: []string{"a"}})
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	gone, err := db.CreateBookmark(NewBookmark{URL: "https://gone.example.com", Tags: []string{"a"}})
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	for _, id := range []int64{kept, gone} {
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "", fmt.Sprintf("<p>%d</p>", id)); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+	}
+	// A delete that stopped after removing the bookmark itself.
+	if _, err := db.db.Exec(`DELETE FROM bookmarks WHERE id = ?`, gone); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+
+	counts, err := db.CountOrphanRows()
+	want := []OrphanRows{{"bookmark_archives", 1}, {"bookmark_tags", 1}}
+	if err != nil || !reflect.DeepEqual(counts, want) {
+		t.Fatalf("CountOrphanRows() = %+v, %v, want %+v", counts, err, want)
+	}
+	if n, _, err := db.CountUnreferencedBlobs(now.Add(time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected orphaned snapshots' blobs to still count as referenced, got %d (%v)", n, err)
+	}
+
+	deleted, err := db.DeleteOrphanRows()
+	if err != nil || !reflect.DeepEqual(deleted, want) {
+		t.Errorf("DeleteOrphanRows() = %+v, %v, want %+v", deleted, err, want)
+	}
+	var blobs int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM archive_blobs`).Scan(&blobs); err != nil || blobs != 1 {
+		t.Errorf("expected only the kept bookmark's blob left, got %d (%v)", blobs, err)
+	}
+	if counts, _ := db.CountOrphanRows(); len(counts) != 0 {
+		t.Errorf("expected no orphaned rows left, got %+v", counts)
+	}
+
+	if err := insertSQLiteBlob(db.db, "stray", []byte("stray")); err != nil {
+		t.Fatalf("failed to store blob: %v", err)
+	}
+	if n, _, err := db.CountUnreferencedBlobs(now.Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected a just-stored blob to be left alone, got %d (%v)", n, err)
+	}
+	n, size, err := db.CountUnreferencedBlobs(now.Add(time.Hour))
+	if err != nil || n != 1 || size != 5 {
+		t.Errorf("CountUnreferencedBlobs() = %d, %d, %v, want 1 blob of 5 bytes", n, size, err)
+	}
+	if n, err := db.DeleteUnreferencedBlobs(now.Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("DeleteUnreferencedBlobs() = %d, %v, want 1", n, err)
+	}
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrBlobNotFound is returned by a BlobStore when a key doesn't exist.
+var ErrBlobNotFound = errors.New("archive blob not found")
+
+// BlobStore holds archived page HTML, gzip-compressed and keyed by the hex
+// SHA-256 of the uncompressed content. bookmark_archives only stores the key.
+//
+// The default store is the archive_blobs table in this database; see
+// core.ArchiveStore for the filesystem and S3 implementations.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// SetBlobStore replaces the store used for archived HTML. Blobs that are not
+// found in the new store are still read from the archive_blobs table, so
+// archives captured before switching backends remain viewable.
+func (db *DB) SetBlobStore(s BlobStore) {
+	db.blobs = s
+}
+
+// SQLiteBlobStore returns the BlobStore backed by this database's archive_blobs table.
+func (db *DB) SQLiteBlobStore() BlobStore {
+	return sqliteBlobStore{db: db}
+}
+
+// sqliteBlobStore keeps blobs in the archive_blobs table.
+type sqliteBlobStore struct {
+	db *DB
+}
+
+func (s sqliteBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	return insertSQLiteBlob(s.db.WithContext(ctx).db, key, data)
+}
+
+func (s sqliteBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := s.db.WithContext(ctx).db.QueryRow(`SELECT data FROM archive_blobs WHERE hash = ?`, key).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get archive blob: %w", err)
+	}
+	return data, nil
+}
+
+// Has reports whether a non-empty blob is stored under key, without
+// reading it.
+func (s sqliteBlobStore) Has(ctx context.Context, key string) (bool, error) {
+	var ok bool
+	err := s.db.WithContext(ctx).db.QueryRow(`SELECT EXISTS (SELECT 1 FROM archive_blobs WHERE hash = ? AND size > 0)`, key).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("failed to check archive blob: %w", err)
+	}
+	return ok, nil
+}
+
+func (s sqliteBlobStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.WithContext(ctx).db.Exec(`DELETE FROM archive_blobs WHERE hash = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete archive blob: %w", err)
+	}
+	return nil
+}
+
+// insertSQLiteBlob stores data in archive_blobs unless the key already exists.
+func insertSQLiteBlob(exec execer, key string, data []byte) error {
+	if _, err := exec.Exec(`
+		INSERT INTO archive_blobs (hash, size, data, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (hash) DO NOTHING
+	`, key, len(data), data, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to store archive blob: %w", err)
+	}
+	return nil
+}
+
+// archiveBlobKey returns the content-addressed key for archived HTML.
+func archiveBlobKey(html string) string {
+	sum := sha256.Sum256([]byte(html))
+	return hex.EncodeToString(sum[:])
+}
+
+// putArchiveBlob compresses archived HTML, writes it to the blob store and
+// returns its key. Identical captures share a single blob.
+func (db *DB) putArchiveBlob(html string) (string, error) {
+	key := archiveBlobKey(html)
+	gz, err := compressHTML(html)
+	if err != nil {
+		return "", err
+	}
+	if err := db.blobs.Put(db.context(), key, gz); err != nil {
+		return "", fmt.Errorf("failed to store archive blob: %w", err)
+	}
+	return key, nil
+}
+
+// loadArchiveBlob reads and decompresses the HTML stored under key. An empty
+// key yields empty HTML.
+func (db *DB) loadArchiveBlob(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	gz, err := db.getArchiveBlob(db.context(), key)
+	if err != nil {
+		return "", err
+	}
+	return decompressHTML(gz)
+}
+
+// getArchiveBlob returns the compressed blob stored under key, falling back
+// to the archive_blobs table when the configured store doesn't have it.
+func (db *DB) getArchiveBlob(ctx context.Context, key string) ([]byte, error) {
+	gz, err := db.blobs.Get(ctx, key)
+	if errors.Is(err, ErrBlobNotFound) {
+		if _, isSQLite := db.blobs.(sqliteBlobStore); !isSQLite {
+			gz, err = db.SQLiteBlobStore().Get(ctx, key)
+		}
+	}
+	return gz, err
+}
+
+// releaseArchiveBlobs deletes the given blobs from the store unless an
+// archive version still refers to them. It is called after versions are
+// replaced or deleted; failures only leave orphaned blobs behind, so they are
+// logged rather than returned.
+func (db *DB) releaseArchiveBlobs(keys []string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		var inUse bool
+		if err := db.db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM bookmark_archives WHERE blob_hash = ? OR screenshot_hash = ? OR download_hash = ? OR audio_hash = ?)
+		`, key, key, key, key).Scan(&inUse); err != nil {
+			log.Printf("failed to check archive blob %s: %v", key, err)
+			continue
+		}
+		if inUse {
+			continue
+		}
+		if err := db.blobs.Delete(db.context(), key); err != nil {
+			log.Printf("failed to delete archive blob %s: %v", key, err)
+		}
+		if _, isSQLite := db.blobs.(sqliteBlobStore); !isSQLite {
+			// Also drop any copy left over from before the backend was switched.
+			if err := db.SQLiteBlobStore().Delete(db.context(), key); err != nil {
+				log.Printf("failed to delete archive blob %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// bookmarkBlobKeys returns the blob keys (HTML, screenshots, downloads and audio)
+// referenced by a bookmark's versions.
+func (db *DB) bookmarkBlobKeys(bookmarkID int64) ([]string, error) {
+	rows, err := db.db.Query(`
+		SELECT blob_hash FROM bookmark_archives
+		WHERE bookmark_id = ? AND blob_hash IS NOT NULL
+		UNION
+		SELECT screenshot_hash FROM bookmark_archives
+		WHERE bookmark_id = ? AND screenshot_hash IS NOT NULL
+		UNION
+		SELECT download_hash FROM bookmark_archives
+		WHERE bookmark_id = ? AND download_hash IS NOT NULL
+		UNION
+		SELECT audio_hash FROM bookmark_archives
+		WHERE bookmark_id = ? AND audio_hash IS NOT NULL
+	`, bookmarkID, bookmarkID, bookmarkID, bookmarkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive blobs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan archive blob key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// relocateArchiveBlobs is the data migration for 0008-archive-blobs. It moves
+// each version's HTML (compressed or, for rows 0006 missed, plain) into
+// archive_blobs one row at a time, then drops the old columns.
+func relocateArchiveBlobs(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT id FROM bookmark_archives
+		WHERE archived_html_gz IS NOT NULL OR archived_html IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list archives to relocate: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan archive id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	for _, id := range ids {
+		var plain sql.NullString
+		var gz []byte
+		if err := tx.QueryRow(`
+			SELECT archived_html, archived_html_gz FROM bookmark_archives WHERE id = ?
+		`, id).Scan(&plain, &gz); err != nil {
+			return fmt.Errorf("failed to read archive %d: %w", id, err)
+		}
+		html := plain.String
+		if len(gz) > 0 {
+			if html, err = decompressHTML(gz); err != nil {
+				return fmt.Errorf("failed to decompress archive %d: %w", id, err)
+			}
+		}
+		key := archiveBlobKey(html)
+		if gz, err = compressHTML(html); err != nil {
+			return err
+		}
+		if err := insertSQLiteBlob(tx, key, gz); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE bookmark_archives SET blob_hash = ? WHERE id = ?`, key, id); err != nil {
+			return fmt.Errorf("failed to link archive %d to blob: %w", id, err)
+		}
+	}
+
+	for _, stmt := range []string{
+		`ALTER TABLE bookmark_archives DROP COLUMN archived_html`,
+		`ALTER TABLE bookmark_archives DROP COLUMN archived_html_gz`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to drop legacy archive column: %w", err)
+		}
+	}
+	return nil
+}
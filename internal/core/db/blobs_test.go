@@ -0,0 +1,512 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func countBlobs(t *testing.T, db *DB) int {
+	t.Helper()
+	var n int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM archive_blobs`).Scan(&n); err != nil {
+		t.Fatalf("failed to count blobs: %v", err)
+	}
+	return n
+}
+
+// TestArchiveBlobs tests that archive HTML is stored in the blob table.
+func TestArchiveBlobs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	first, _ := db.AddBookmark("https://one.com", "One")
+	second, _ := db.AddBookmark("https://two.com", "Two")
+
+	t.Run("identical captures share a blob", func(t *testing.T) {
+		now := time.Now()
+		if err := db.SaveArchiveResult(first, now, &now, "ok", "", "https://one.com", "<html>same</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := db.SaveArchiveResult(second, now, &now, "ok", "", "https://two.com", "<html>same</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if n := countBlobs(t, db); n != 1 {
+			t.Errorf("expected 1 blob, got %d", n)
+		}
+
+		a, err := db.GetBookmarkArchive(second)
+		if err != nil {
+			t.Fatalf("failed to get archive: %v", err)
+		}
+		if a.ArchivedHTML != "<html>same</html>" {
+			t.Errorf("expected archived html, got %q", a.ArchivedHTML)
+		}
+	})
+
+	t.Run("replacing a version removes its unused blob", func(t *testing.T) {
+		at := time.Now().Add(time.Hour)
+		if err := db.SaveArchiveResult(first, at, &at, "ok", "", "https://one.com", "<html>draft</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := db.SaveArchiveResult(first, at, &at, "ok", "", "https://one.com", "<html>final</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if n := countBlobs(t, db); n != 2 {
+			t.Errorf("expected 2 blobs, got %d", n)
+		}
+		v, err := db.GetLatestArchiveVersion(first)
+		if err != nil {
+			t.Fatalf("failed to get latest version: %v", err)
+		}
+		if v.ArchivedHTML != "<html>final</html>" {
+			t.Errorf("expected final html, got %q", v.ArchivedHTML)
+		}
+	})
+
+	t.Run("deleting a bookmark keeps shared blobs", func(t *testing.T) {
+		if err := db.DeleteBookmark(first); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if n := countBlobs(t, db); n != 1 {
+			t.Errorf("expected shared blob to remain, got %d blobs", n)
+		}
+		if err := db.DeleteBookmark(second); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected no blobs, got %d", n)
+		}
+	})
+}
+
+// TestRelocateArchiveBlobs tests the 0008 data migration.
+func TestRelocateArchiveBlobs(t *testing.T) {
+	db := newTestDBAt(t, "0007-metadata")
+
+	id := addLegacyBookmark(t, db, "https://example.com")
+	gz, err := compressHTML("<html>compressed</html>")
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if _, err := db.db.Exec(`
+		INSERT INTO bookmark_archives (bookmark_id, captured_at, archived_url, archived_html_gz)
+		VALUES (?, '2000-01-01T00:00:00Z', 'https://example.com', ?)
+	`, id, gz); err != nil {
+		t.Fatalf("failed to insert compressed row: %v", err)
+	}
+	if _, err := db.db.Exec(`
+		INSERT INTO bookmark_archives (bookmark_id, captured_at, archived_url, archived_html)
+		VALUES (?, '2001-01-01T00:00:00Z', 'https://example.com', '<html>plain</html>')
+	`, id); err != nil {
+		t.Fatalf("failed to insert plain row: %v", err)
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	versions, err := db.ListArchiveVersions(id)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	want := map[string]string{
+		"2000-01-01T00:00:00Z": "<html>compressed</html>",
+		"2001-01-01T00:00:00Z": "<html>plain</html>",
+	}
+	for _, v := range versions {
+		full, err := db.GetArchiveVersion(id, v.ID)
+		if err != nil {
+			t.Fatalf("failed to get version: %v", err)
+		}
+		if full.ArchivedHTML != want[v.CapturedAt] {
+			t.Errorf("version %s: expected %q, got %q", v.CapturedAt, want[v.CapturedAt], full.ArchivedHTML)
+		}
+	}
+
+	if _, err := db.db.Exec(`SELECT archived_html FROM bookmark_archives`); err == nil {
+		t.Error("expected archived_html column to be dropped")
+	}
+}
+
+// memBlobStore is an in-memory BlobStore for tests.
+type memBlobStore struct {
+	blobs map[string][]byte
+}
+
+func (m *memBlobStore) Put(_ context.Context, key string, data []byte) error {
+	m.blobs[key] = data
+	return nil
+}
+
+func (m *memBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	return data, nil
+}
+
+func (m *memBlobStore) Delete(_ context.Context, key string) error {
+	delete(m.blobs, key)
+	return nil
+}
+
+// TestSetBlobStore tests using an external blob store.
+func TestSetBlobStore(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com", "Example")
+
+	// Captured before switching stores, so it lives in archive_blobs.
+	old := time.Now().Add(-time.Hour)
+	if err := db.SaveArchiveResult(id, old, &old, "ok", "", "https://example.com", "<html>old</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	store := &memBlobStore{blobs: map[string][]byte{}}
+	db.SetBlobStore(store)
+
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html>new</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	t.Run("new captures go to the configured store", func(t *testing.T) {
+		if len(store.blobs) != 1 {
+			t.Errorf("expected 1 blob in store, got %d", len(store.blobs))
+		}
+		if n := countBlobs(t, db); n != 1 {
+			t.Errorf("expected only the old blob in sqlite, got %d", n)
+		}
+		a, err := db.GetBookmarkArchive(id)
+		if err != nil {
+			t.Fatalf("failed to get archive: %v", err)
+		}
+		if a.ArchivedHTML != "<html>new</html>" {
+			t.Errorf("expected new html, got %q", a.ArchivedHTML)
+		}
+	})
+
+	t.Run("older captures fall back to sqlite", func(t *testing.T) {
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil || len(versions) != 2 {
+			t.Fatalf("expected 2 versions, got %d (%v)", len(versions), err)
+		}
+		v, err := db.GetArchiveVersion(id, versions[1].ID)
+		if err != nil {
+			t.Fatalf("failed to get old version: %v", err)
+		}
+		if v.ArchivedHTML != "<html>old</html>" {
+			t.Errorf("expected old html, got %q", v.ArchivedHTML)
+		}
+	})
+
+	t.Run("status lookup does not read blobs", func(t *testing.T) {
+		a, err := db.GetBookmarkArchiveStatus(id)
+		if err != nil {
+			t.Fatalf("failed to get status: %v", err)
+		}
+		if a.ArchivedHTML != "" || a.ArchiveStatus != "ok" {
+			t.Errorf("unexpected status: %+v", a)
+		}
+	})
+
+	t.Run("deleting the bookmark releases blobs in both stores", func(t *testing.T) {
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if len(store.blobs) != 0 {
+			t.Errorf("expected store to be empty, got %d", len(store.blobs))
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected sqlite blobs to be empty, got %d", n)
+		}
+	})
+}
+
+// TestArchiveScreenshots tests storing screenshots with archive versions.
+// TestArchiveProvenance tests saving provenance with the latest archive
+// version and clearing it when the version is replaced.
+func TestArchiveProvenance(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com", "Example")
+	if err := db.SaveArchiveProvenance(id, `{}`); err == nil {
+		t.Error("expected error without an archive version")
+	}
+
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	v, err := db.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+	if v.HasProvenance {
+		t.Error("expected no provenance yet")
+	}
+	if _, err := db.GetArchiveProvenance(id, v.ID); err == nil {
+		t.Error("expected error for missing provenance")
+	}
+
+	if err := db.SaveArchiveProvenance(id, `{"egress":"direct"}`); err != nil {
+		t.Fatalf("failed to save provenance: %v", err)
+	}
+	got, err := db.GetArchiveProvenance(id, v.ID)
+	if err != nil || got != `{"egress":"direct"}` {
+		t.Errorf("expected provenance to round-trip, got %q, %v", got, err)
+	}
+	versions, err := db.ListArchiveVersions(id)
+	if err != nil || len(versions) != 1 || !versions[0].HasProvenance {
+		t.Errorf("expected listed version to have provenance, got %+v, %v", versions, err)
+	}
+
+	// Re-saving the same capture replaces the version, provenance included.
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html>2</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if v, _ := db.GetLatestArchiveVersion(id); v.HasProvenance {
+		t.Error("expected replaced version to have no provenance")
+	}
+}
+
+func TestArchiveTimestamp(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com", "Example")
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	v, err := db.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+	if v.HasTimestamp {
+		t.Error("expected no timestamp yet")
+	}
+	if _, err := db.GetArchiveTimestamp(id, v.ID); err == nil {
+		t.Error("expected error for missing timestamp")
+	}
+
+	ts := ArchiveTimestamp{
+		BookmarkID:    id,
+		VersionID:     v.ID,
+		ContentHash:   archiveBlobKey("<html></html>"),
+		Authority:     "https://tsa.example.com",
+		TimestampedAt: "2025-01-02T03:04:05Z",
+		Token:         []byte{0x30, 0x03, 0x02, 0x01, 0x00},
+	}
+	wrong := ts
+	wrong.ContentHash = "deadbeef"
+	if err := db.SaveArchiveTimestamp(wrong); err == nil {
+		t.Error("expected error for a content hash the version doesn't have")
+	}
+	if found, err := db.ArchiveVersionByContentHash(id, ts.ContentHash); err != nil || found != v.ID {
+		t.Errorf("expected version %d for the content hash, got %d, %v", v.ID, found, err)
+	}
+	if _, err := db.ArchiveVersionByContentHash(id, "deadbeef"); err == nil {
+		t.Error("expected error for a content hash no version has")
+	}
+
+	if err := db.SaveArchiveTimestamp(ts); err != nil {
+		t.Fatalf("failed to save timestamp: %v", err)
+	}
+	got, err := db.GetArchiveTimestamp(id, v.ID)
+	if err != nil {
+		t.Fatalf("failed to get timestamp: %v", err)
+	}
+	if got.VersionID != v.ID || got.ContentHash != ts.ContentHash || got.Authority != ts.Authority ||
+		got.TimestampedAt != ts.TimestampedAt || string(got.Token) != string(ts.Token) {
+		t.Errorf("unexpected timestamp: %+v", got)
+	}
+	if v, _ := db.GetLatestArchiveVersion(id); !v.HasTimestamp {
+		t.Error("expected version to have a timestamp")
+	}
+
+	// Re-saving the same capture replaces the version, timestamp included.
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html>2</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if v, _ := db.GetLatestArchiveVersion(id); v.HasTimestamp {
+		t.Error("expected replaced version to have no timestamp")
+	}
+}
+
+func TestArchiveScreenshots(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com", "Example")
+	image := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}
+
+	t.Run("requires an archive version", func(t *testing.T) {
+		if err := db.SaveArchiveScreenshot(id, image); err == nil {
+			t.Error("expected error without an archive version")
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected unused blob to be released, got %d blobs", n)
+		}
+	})
+
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	v, err := db.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+	if v.HasScreenshot {
+		t.Error("expected no screenshot yet")
+	}
+
+	t.Run("save and load", func(t *testing.T) {
+		if err := db.SaveArchiveScreenshot(id, image); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.GetArchiveScreenshot(id, v.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if string(got) != string(image) {
+			t.Errorf("expected screenshot bytes to round-trip, got %x", got)
+		}
+		latest, err := db.GetLatestArchiveVersion(id)
+		if err != nil {
+			t.Fatalf("failed to get latest version: %v", err)
+		}
+		if !latest.HasScreenshot {
+			t.Error("expected version to have a screenshot")
+		}
+	})
+
+	t.Run("replacing a screenshot releases the old one", func(t *testing.T) {
+		if err := db.SaveArchiveScreenshot(id, append(image, 0x01)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if n := countBlobs(t, db); n != 2 {
+			t.Errorf("expected html and one screenshot blob, got %d", n)
+		}
+	})
+
+	t.Run("deleting the bookmark releases the screenshot", func(t *testing.T) {
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected no blobs, got %d", n)
+		}
+	})
+
+	t.Run("missing screenshot", func(t *testing.T) {
+		if _, err := db.GetArchiveScreenshot(id, v.ID); err == nil {
+			t.Error("expected error for missing screenshot")
+		}
+	})
+}
+
+func TestArchiveDownloads(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, _ := db.AddBookmark("https://example.com/report.zip", "Report")
+	file := ArchiveDownload{Filename: "report.zip", MIMEType: "application/zip", Data: []byte("PK\x03\x04 zipped")}
+
+	t.Run("requires an archive version", func(t *testing.T) {
+		if err := db.SaveArchiveDownload(id, file); err == nil {
+			t.Error("expected error without an archive version")
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected unused blob to be released, got %d blobs", n)
+		}
+	})
+
+	now := time.Now()
+	if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/report.zip", "<html>report.zip</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	v, err := db.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+	if v.HasDownload {
+		t.Error("expected no download yet")
+	}
+
+	t.Run("save and load", func(t *testing.T) {
+		if err := db.SaveArchiveDownload(id, file); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.GetArchiveDownload(id, v.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Filename != file.Filename || got.MIMEType != file.MIMEType || got.Size != int64(len(file.Data)) || string(got.Data) != string(file.Data) {
+			t.Errorf("expected the download to round-trip, got %+v", got)
+		}
+		versions, err := db.ListArchiveVersions(id)
+		if err != nil {
+			t.Fatalf("failed to list versions: %v", err)
+		}
+		if len(versions) != 1 || !versions[0].HasDownload {
+			t.Errorf("expected the version to have a download, got %+v", versions)
+		}
+	})
+
+	t.Run("recapturing the version releases the download", func(t *testing.T) {
+		if err := db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/report.zip", "<html>report.zip</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if n := countBlobs(t, db); n != 1 {
+			t.Errorf("expected only the html blob, got %d", n)
+		}
+		if _, err := db.GetArchiveDownload(id, v.ID); err == nil {
+			t.Error("expected the replaced version to have no download")
+		}
+	})
+
+	t.Run("deleting the bookmark releases the download", func(t *testing.T) {
+		if err := db.SaveArchiveDownload(id, file); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if n := countBlobs(t, db); n != 0 {
+			t.Errorf("expected no blobs, got %d", n)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -39,10 +40,23 @@ func ValidateBookmarkURL(urlStr string) error {
 // Bookmark methods
 // ------------------------------
 
+// BookmarkOwner returns the ID of the user who owns a bookmark.
+func (db *DB) BookmarkOwner(id int64) (int64, error) {
+	var owner int64
+	err := db.db.QueryRow(`SELECT user_id FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&owner)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("bookmark not found: %d", id)
+		}
+		return 0, fmt.Errorf("failed to get bookmark owner: %w", err)
+	}
+	return owner, nil
+}
+
 func (db *DB) GetBookmark(id int64) (Bookmark, error) {
 	var b Bookmark
-	err := db.db.QueryRow("SELECT id, url, title, created_at FROM bookmarks WHERE id = ?", id).
-		Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt)
+	err := db.db.QueryRow("SELECT id, url, title, created_at, COALESCE(slug, '') FROM bookmarks WHERE id = ? AND "+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).
+		Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt, &b.Slug)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Bookmark{}, fmt.Errorf("bookmark not found: %d", id)
@@ -52,55 +66,256 @@ func (db *DB) GetBookmark(id int64) (Bookmark, error) {
 	return b, nil
 }
 
+// NewBookmark describes a bookmark to be created with CreateBookmark.
+type NewBookmark struct {
+	URL   string
+	Title string
+	// Tags are normalized with NormalizeTag and de-duplicated before saving.
+	Tags []string
+	// Collection files the bookmark in a collection, taking precedence over
+	// any routing rule's collection.
+	Collection string
+	// SkipArchive keeps the bookmark out of automatic archiving.
+	SkipArchive bool
+	// SkipArchiveToday keeps the bookmark from being submitted to
+	// archive.today.
+	SkipArchiveToday bool
+	// Archive overrides the owner's archive preferences for the bookmark's
+	// first archive.
+	Archive ArchiveOverrides
+	// Notes are free-form Markdown notes about the bookmark.
+	Notes string
+	// CreatedAt backdates the bookmark, for imports; zero means now.
+	CreatedAt time.Time
+	// Source records how the bookmark was added; see BookmarkSource.
+	Source string
+	// IsRead and IsFavorite set the bookmark's read and favorite flags.
+	IsRead     bool
+	IsFavorite bool
+	// Description is saved as the bookmark's metadata description, as of
+	// its creation date, for imports that carry one.
+	Description string
+}
+
 // AddBookmark adds a new bookmark to the database and returns the ID of the new bookmark.
 //
 // It validates the URL before inserting and returns ErrInvalidURL if validation fails.
 // It returns the new bookmark ID (>0) on success.
 // Emits a BookmarkCreatedEvent after successful insert.
 func (db *DB) AddBookmark(url string, title string) (int64, error) {
-	if err := ValidateBookmarkURL(url); err != nil {
+	return db.CreateBookmark(NewBookmark{URL: url, Title: title})
+}
+
+// CreateBookmark inserts a bookmark together with its tags in a single
+// transaction and returns the new bookmark ID. Enabled routing rules are
+// applied first, adding their tags, collection and skip-archive flag to nb,
+// so every way of creating bookmarks is routed the same way.
+//
+// It validates the URL before inserting and returns ErrInvalidURL if validation fails.
+// Emits a BookmarkCreatedEvent after the transaction commits.
+func (db *DB) CreateBookmark(nb NewBookmark) (int64, error) {
+	ids, err := db.CreateBookmarks([]NewBookmark{nb})
+	if err != nil {
 		return 0, err
 	}
+	return ids[0], nil
+}
 
-	createdAt := time.Now().Format(time.RFC3339)
-	result, err := db.db.Exec(
-		"INSERT INTO bookmarks (url, title, created_at) VALUES (?, ?, ?)",
-		url,
-		title,
-		createdAt,
-	)
+// CreateBookmarks inserts several bookmarks in a single transaction, so
+// either all of them are saved or none are, and returns their IDs in order.
+// Each is routed and validated like CreateBookmark; one invalid URL fails
+// the whole batch. Emits a BookmarkCreatedEvent per bookmark after the
+// transaction commits.
+func (db *DB) CreateBookmarks(nbs []NewBookmark) ([]int64, error) {
+	for _, nb := range nbs {
+		if err := ValidateBookmarkURL(nb.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rules are read before the transaction starts: a transaction that reads
+	// first holds a shared lock, and SQLite fails its upgrade to a write lock
+	// with "database is locked" at once, rather than waiting, if another
+	// connection is already writing.
+	rules, err := db.ListRoutingRules()
 	if err != nil {
-		return 0, fmt.Errorf("failed to add bookmark: %w", err)
+		return nil, err
 	}
-	id, err := result.LastInsertId()
+
+	tx, err := db.db.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	now := time.Now().Format(time.RFC3339)
+	events := make([]BookmarkCreatedEvent, 0, len(nbs))
+	ids := make([]int64, 0, len(nbs))
+	for _, nb := range nbs {
+		route := RouteBookmark(rules, nb.URL, nb.Title)
+		nb.Tags = append(nb.Tags, route.Tags...)
+		if nb.Collection = strings.TrimSpace(nb.Collection); nb.Collection == "" {
+			nb.Collection = route.Collection
+		}
+		nb.SkipArchive = nb.SkipArchive || route.SkipArchive
+		createdAt := now
+		if !nb.CreatedAt.IsZero() {
+			createdAt = nb.CreatedAt.Format(time.RFC3339)
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO bookmarks (url, title, created_at, collection, skip_archive, archive_today_disabled, notes, source, is_read, is_favorite) VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?)",
+			nb.URL,
+			nb.Title,
+			createdAt,
+			nb.Collection,
+			nb.SkipArchive,
+			nb.SkipArchiveToday,
+			strings.TrimSpace(nb.Notes),
+			nb.Source,
+			nb.IsRead,
+			nb.IsFavorite,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add bookmark: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+		// Unscoped handles leave user_id at its default, LocalUserID.
+		if db.userID != 0 {
+			if _, err := tx.Exec(`UPDATE bookmarks SET user_id = ? WHERE id = ?`, db.userID, id); err != nil {
+				return nil, fmt.Errorf("failed to set bookmark owner: %w", err)
+			}
+		}
+
+		if err := assignBookmarkSlug(tx, id); err != nil {
+			return nil, err
+		}
+		if err := addBookmarkTags(tx, id, nb.Tags); err != nil {
+			return nil, err
+		}
+		if description := strings.TrimSpace(nb.Description); description != "" {
+			if _, err := tx.Exec(`INSERT INTO bookmark_metadata (bookmark_id, description, fetched_at) VALUES (?, ?, ?)`, id, description, createdAt); err != nil {
+				return nil, fmt.Errorf("failed to save bookmark description: %w", err)
+			}
+		}
+		if len(route.Rules) > 0 {
+			log.Printf("Bookmark %d matched routing rule(s): %s", id, strings.Join(route.Rules, ", "))
+		}
+
+		ids = append(ids, id)
+		events = append(events, BookmarkCreatedEvent{
+			Bookmark: Bookmark{
+				ID:        id,
+				URL:       nb.URL,
+				Title:     nb.Title,
+				CreatedAt: createdAt,
+			},
+			SkipArchive:      nb.SkipArchive,
+			SkipArchiveToday: nb.SkipArchiveToday,
+			Archive:          nb.Archive,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bookmark: %w", err)
+	}
+
+	for _, ev := range events {
+		db.emit(ev)
 	}
+	return ids, nil
+}
 
-	db.emit(BookmarkCreatedEvent{
-		Bookmark: Bookmark{
-			ID:        id,
-			URL:       url,
-			Title:     title,
-			CreatedAt: createdAt,
-		},
-	})
+// ExistingBookmarkURLs returns which of urls are already bookmarked by the
+// handle's user.
+func (db *DB) ExistingBookmarkURLs(urls []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(urls) == 0 {
+		return existing, nil
+	}
+	args := make([]any, len(urls))
+	for i, u := range urls {
+		args[i] = u
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(urls)), ", ")
+	rows, err := db.db.Query(`SELECT DISTINCT url FROM bookmarks WHERE url IN (`+placeholders+`) AND `+ownerFilter("user_id"), append(args, db.owner()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up bookmark URLs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark URL: %w", err)
+		}
+		existing[u] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmark URLs: %w", err)
+	}
+	return existing, nil
+}
 
-	return id, nil
+// BookmarkIDsByURL returns the IDs of the handle's user's bookmarks of
+// urls, by URL. A URL bookmarked more than once maps to its oldest
+// bookmark.
+func (db *DB) BookmarkIDsByURL(urls []string) (map[string]int64, error) {
+	ids := make(map[string]int64)
+	if len(urls) == 0 {
+		return ids, nil
+	}
+	args := make([]any, len(urls))
+	for i, u := range urls {
+		args[i] = u
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(urls)), ", ")
+	rows, err := db.db.Query(`SELECT url, MIN(id) FROM bookmarks WHERE url IN (`+placeholders+`) AND `+ownerFilter("user_id")+` GROUP BY url`, append(args, db.owner()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up bookmark URLs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var u string
+		var id int64
+		if err := rows.Scan(&u, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark URL: %w", err)
+		}
+		ids[u] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmark URLs: %w", err)
+	}
+	return ids, nil
 }
 
 func (db *DB) ListBookmarks(limit int) ([]Bookmark, error) {
 	query := `
-		SELECT id, url, title, created_at
+		SELECT id, url, title, created_at, COALESCE(slug, '')
 		FROM bookmarks
+		WHERE ` + ownerFilter("user_id") + `
 		ORDER BY created_at DESC
 	`
 	var rows *sql.Rows
 	var err error
 	if limit > 0 {
-		rows, err = db.db.Query(query+" LIMIT ?", limit)
+		rows, err = db.db.Query(query+" LIMIT ?", append(db.owner(), limit)...)
 	} else {
-		rows, err = db.db.Query(query)
+		rows, err = db.db.Query(query, db.owner()...)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list bookmarks: %w", err)
@@ -114,7 +329,7 @@ func (db *DB) ListBookmarks(limit int) ([]Bookmark, error) {
 	var out []Bookmark
 	for rows.Next() {
 		var b Bookmark
-		if err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.CreatedAt, &b.Slug); err != nil {
 			return nil, fmt.Errorf("failed to scan bookmark: %w", err)
 		}
 		out = append(out, b)
@@ -125,7 +340,7 @@ func (db *DB) ListBookmarks(limit int) ([]Bookmark, error) {
 // UpdateBookmark updates a bookmark's URL and title.
 // Emits a BookmarkUpdatedEvent after successful update.
 func (db *DB) UpdateBookmark(id int64, url string, title string) error {
-	res, err := db.db.Exec("UPDATE bookmarks SET url = ?, title = ? WHERE id = ?", url, title, id)
+	res, err := db.db.Exec("UPDATE bookmarks SET url = ?, title = ? WHERE id = ? AND "+ownerFilter("user_id"), append([]any{url, title, id}, db.owner()...)...)
 	if err != nil {
 		return fmt.Errorf("failed to update bookmark: %w", err)
 	}
@@ -146,15 +361,53 @@ func (db *DB) UpdateBookmark(id int64, url string, title string) error {
 	return nil
 }
 
-// DeleteBookmark removes a bookmark from the database.
-// Emits a BookmarkDeletedEvent after successful deletion.
-func (db *DB) DeleteBookmark(id int64) error {
-	// Fetch bookmark before deletion to include in event
-	b, _ := db.GetBookmark(id)
+// GetBookmarkNotes returns a bookmark's notes, or "" if it has none.
+func (db *DB) GetBookmarkNotes(id int64) (string, error) {
+	var notes string
+	err := db.db.QueryRow(`SELECT COALESCE(notes, '') FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&notes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("bookmark not found: %d", id)
+		}
+		return "", fmt.Errorf("failed to get bookmark notes: %w", err)
+	}
+	return notes, nil
+}
 
-	res, err := db.db.Exec("DELETE FROM bookmarks WHERE id = ?", id)
+// SetBookmarkNotes replaces a bookmark's notes; "" clears them.
+func (db *DB) SetBookmarkNotes(id int64, notes string) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET notes = NULLIF(?, '') WHERE id = ? AND `+ownerFilter("user_id"), append([]any{strings.TrimSpace(notes), id}, db.owner()...)...)
 	if err != nil {
-		return fmt.Errorf("failed to delete bookmark: %w", err)
+		return fmt.Errorf("failed to set bookmark notes: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// GetBookmarkFlags returns a bookmark's read, favorite and pinned flags.
+func (db *DB) GetBookmarkFlags(id int64) (BookmarkFlags, error) {
+	var f BookmarkFlags
+	err := db.db.QueryRow(`SELECT is_read, is_favorite, pinned_at IS NOT NULL FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&f.IsRead, &f.IsFavorite, &f.IsPinned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BookmarkFlags{}, fmt.Errorf("bookmark not found: %d", id)
+		}
+		return BookmarkFlags{}, fmt.Errorf("failed to get bookmark flags: %w", err)
+	}
+	return f, nil
+}
+
+// MarkRead marks a bookmark read, or unread again when read is false.
+func (db *DB) MarkRead(id int64, read bool) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET is_read = ? WHERE id = ? AND `+ownerFilter("user_id"), append([]any{read, id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to mark bookmark read: %w", err)
 	}
 	affected, err := res.RowsAffected()
 	if err != nil {
@@ -163,12 +416,174 @@ func (db *DB) DeleteBookmark(id int64) error {
 	if affected == 0 {
 		return fmt.Errorf("bookmark not found: %d", id)
 	}
+	return nil
+}
+
+// ToggleFavorite flips a bookmark's favorite flag and returns the new value.
+func (db *DB) ToggleFavorite(id int64) (bool, error) {
+	var favorite bool
+	err := db.db.QueryRow(`
+		UPDATE bookmarks SET is_favorite = NOT is_favorite
+		WHERE id = ? AND `+ownerFilter("user_id")+`
+		RETURNING is_favorite
+	`, append([]any{id}, db.owner()...)...).Scan(&favorite)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("bookmark not found: %d", id)
+		}
+		return false, fmt.Errorf("failed to toggle favorite: %w", err)
+	}
+	return favorite, nil
+}
+
+// ListFilteredBookmarks is like ListBookmarks but only returns bookmarks
+// that pass filter, in the order its Sort and Order give (newest first by
+// default).
+func (db *DB) ListFilteredBookmarks(filter BookmarkFilter, limit int) ([]Bookmark, error) {
+	where, args := filter.where("")
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE `+where+`
+		  AND `+ownerFilter("user_id")+`
+		ORDER BY `+filter.orderBy()+`
+	`, append(args, db.owner()...), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// RandomBookmark picks one of the bookmarks that pass filter at random, so
+// old ones can be rediscovered, and returns false if none does.
+// filter.Sort and Order are ignored.
+func (db *DB) RandomBookmark(filter BookmarkFilter) (Bookmark, bool, error) {
+	where, args := filter.where("")
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE `+where+`
+		  AND `+ownerFilter("user_id")+`
+		ORDER BY RANDOM()
+	`, append(args, db.owner()...), 1)
+	if err != nil {
+		return Bookmark{}, false, fmt.Errorf("failed to pick a random bookmark: %w", err)
+	}
+	if len(bookmarks) == 0 {
+		return Bookmark{}, false, nil
+	}
+	return bookmarks[0], true, nil
+}
+
+// where returns the SQL condition f puts on bookmarks, whose columns are
+// prefixed with prefix (such as "b."), and its arguments.
+func (f BookmarkFilter) where(prefix string) (string, []any) {
+	tag := NormalizeTag(f.Tag)
+	// The tag subquery has ids of its own, so the bookmark's is qualified.
+	idCol := prefix + "id"
+	if prefix == "" {
+		idCol = "bookmarks.id"
+	}
+	status := f.ArchiveStatus
+	if status == NotArchived {
+		status = ""
+	}
+	sourceKind := sourcePrefix(f.Source)
+	return `(? = 0 OR ` + prefix + `is_read = 0)
+		  AND (? = 0 OR ` + prefix + `is_read = 1)
+		  AND (? = 0 OR ` + prefix + `is_favorite = 1)
+		  AND (? = '' OR url_host(` + prefix + `url) = ?)
+		  AND (? = '' OR EXISTS (
+		        SELECT 1 FROM bookmark_tags bt
+		        JOIN tags t ON t.id = bt.tag_id
+		        WHERE bt.bookmark_id = ` + idCol + ` AND t.name = ?))
+		  AND (? = '' OR COALESCE(` + prefix + `archive_status, '') = ?)
+		  AND (? = '' OR ` + prefix + `source = ?
+		       OR (? != '' AND substr(` + prefix + `source, 1, length(?)) = ?))`,
+		[]any{f.UnreadOnly, f.ReadOnly, f.FavoritesOnly, f.Domain, f.Domain, tag, tag, f.ArchiveStatus, status,
+			f.Source, f.Source, sourceKind, sourceKind, sourceKind}
+}
+
+// orderBy returns the ORDER BY clause for f's Sort and Order, with ties
+// broken by id so pages don't shuffle.
+func (f BookmarkFilter) orderBy() string {
+	by := f.Sort
+	if by == "" {
+		by = SortCreated
+	}
+	dir := "ASC"
+	if f.Order == SortDesc || (f.Order == "" && by == SortCreated) {
+		dir = "DESC"
+	}
+	switch by {
+	case SortTitle:
+		return `COALESCE(NULLIF(title, ''), url) COLLATE NOCASE ` + dir + `, id ` + dir
+	case SortDomain:
+		return `url_host(url) ` + dir + `, created_at DESC, id DESC`
+	default:
+		return `created_at ` + dir + `, id ` + dir
+	}
+}
+
+// DeleteBookmark removes a bookmark from the database.
+// Emits a BookmarkDeletedEvent after successful deletion.
+func (db *DB) DeleteBookmark(id int64) error {
+	if err := db.checkOwner(id); err != nil {
+		return err
+	}
+
+	// Fetch bookmark and owner before deletion to include in event
+	b, _ := db.GetBookmark(id)
+	var owner int64
+	_ = db.db.QueryRow(`SELECT user_id FROM bookmarks WHERE id = ?`, id).Scan(&owner)
+
+	blobKeys, err := db.bookmarkBlobKeys(id)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteBookmarkRows(db.db, id); err != nil {
+		return err
+	}
+	db.releaseArchiveBlobs(blobKeys)
 
 	// If we couldn't fetch earlier, at least include the ID
 	if b.ID == 0 {
 		b.ID = id
 	}
-	db.emit(BookmarkDeletedEvent{Bookmark: b})
+	db.emit(BookmarkDeletedEvent{Bookmark: b, UserID: owner})
+
+	return nil
+}
+
+// deleteBookmarkRows deletes a bookmark and the rows that depend on it,
+// leaving its archive blobs for the caller to release.
+func deleteBookmarkRows(exec execer, id int64) error {
+	// Foreign keys aren't enforced on our connections, so remove dependent rows explicitly.
+	for _, dep := range []struct{ what, table string }{
+		{"archive versions", "bookmark_archives"},
+		{"bookmark tags", "bookmark_tags"},
+		{"bookmark metadata", "bookmark_metadata"},
+		{"bookmark favicon", "bookmark_favicons"},
+		{"bookmark links", "bookmark_links"},
+		{"bookmark jobs", "jobs"},
+		{"archive attempts", "archive_attempts"},
+	} {
+		if _, err := exec.Exec("DELETE FROM "+dep.table+" WHERE bookmark_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", dep.what, err)
+		}
+	}
 
+	res, err := exec.Exec("DELETE FROM bookmarks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete bookmark: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
 	return nil
 }
@@ -2,8 +2,10 @@ package db
 
 import (
 	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestAddBookmark tests bookmark creation.
@@ -341,3 +343,316 @@ func TestAddBookmarkValidation(t *testing.T) {
 		}
 	})
 }
+
+// TestCreateBookmarks tests creating several bookmarks in one transaction.
+func TestCreateBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	var events int
+	db.RegisterEventListener(OnBookmarkCreatedEvent, func(Event) error {
+		events++
+		return nil
+	})
+
+	t.Run("creates all in order", func(t *testing.T) {
+		ids, err := db.CreateBookmarks([]NewBookmark{
+			{URL: "https://one.com", Title: "One", Tags: []string{"bulk"}},
+			{URL: "https://two.com", Title: "Two"},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(ids) != 2 || events != 2 {
+			t.Fatalf("expected 2 bookmarks and events, got %v and %d", ids, events)
+		}
+		b, err := db.GetBookmark(ids[1])
+		if err != nil || b.URL != "https://two.com" {
+			t.Errorf("expected second bookmark to be two.com, got %+v, %v", b, err)
+		}
+		if tags, _ := db.ListBookmarkTags(ids[0]); len(tags) != 1 || tags[0] != "bulk" {
+			t.Errorf("expected tag bulk, got %v", tags)
+		}
+	})
+
+	t.Run("saves flags and description", func(t *testing.T) {
+		created := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+		ids, err := db.CreateBookmarks([]NewBookmark{{URL: "https://described.com", IsRead: true, IsFavorite: true, Description: " About it ", CreatedAt: created}})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if flags, err := db.GetBookmarkFlags(ids[0]); err != nil || !flags.IsRead || !flags.IsFavorite {
+			t.Errorf("expected read favorite, got %+v, %v", flags, err)
+		}
+		meta, err := db.GetBookmarkMetadata(ids[0])
+		if err != nil || meta.Description != "About it" || meta.FetchedAt != created.Format(time.RFC3339) {
+			t.Errorf("unexpected metadata %+v, %v", meta, err)
+		}
+	})
+
+	t.Run("one invalid URL fails the batch", func(t *testing.T) {
+		events = 0
+		_, err := db.CreateBookmarks([]NewBookmark{{URL: "https://three.com"}, {URL: "ftp://bad"}})
+		if !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("expected ErrInvalidURL, got %v", err)
+		}
+		if existing, _ := db.ExistingBookmarkURLs([]string{"https://three.com"}); existing["https://three.com"] || events != 0 {
+			t.Error("expected nothing to be created")
+		}
+	})
+
+	t.Run("existing URLs", func(t *testing.T) {
+		existing, err := db.ExistingBookmarkURLs([]string{"https://one.com", "https://nope.com", "https://two.com"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(existing) != 2 || !existing["https://one.com"] || !existing["https://two.com"] {
+			t.Errorf("unexpected existing URLs: %v", existing)
+		}
+		if none, err := db.ExistingBookmarkURLs(nil); err != nil || len(none) != 0 {
+			t.Errorf("expected empty result, got %v, %v", none, err)
+		}
+	})
+}
+
+// TestBookmarkNotes tests creating bookmarks with notes and changing them.
+func TestBookmarkNotes(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.CreateBookmark(NewBookmark{URL: "https://example.com", Title: "Example", Notes: "  Read the *second* half.\n"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	if notes, err := db.GetBookmarkNotes(id); err != nil || notes != "Read the *second* half." {
+		t.Errorf("expected trimmed notes, got %q, %v", notes, err)
+	}
+
+	if err := db.SetBookmarkNotes(id, "Updated"); err != nil {
+		t.Fatalf("failed to set notes: %v", err)
+	}
+	if notes, _ := db.GetBookmarkNotes(id); notes != "Updated" {
+		t.Errorf("expected updated notes, got %q", notes)
+	}
+
+	if err := db.SetBookmarkNotes(id, "  "); err != nil {
+		t.Fatalf("failed to clear notes: %v", err)
+	}
+	if notes, _ := db.GetBookmarkNotes(id); notes != "" {
+		t.Errorf("expected notes to be cleared, got %q", notes)
+	}
+
+	if err := db.SetBookmarkNotes(99999, "x"); err == nil {
+		t.Error("expected error for missing bookmark")
+	}
+	if _, err := db.GetBookmarkNotes(99999); err == nil {
+		t.Error("expected error for missing bookmark")
+	}
+}
+
+func TestBookmarkFlags(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	a, _ := db.AddBookmark("https://example.com/a", "A")
+	b, _ := db.AddBookmark("https://example.com/b", "B")
+
+	t.Run("new bookmarks are unread and not favorites", func(t *testing.T) {
+		f, err := db.GetBookmarkFlags(a)
+		if err != nil || f.IsRead || f.IsFavorite {
+			t.Errorf("expected no flags, got %+v, %v", f, err)
+		}
+	})
+
+	t.Run("MarkRead sets and clears the read flag", func(t *testing.T) {
+		if err := db.MarkRead(a, true); err != nil {
+			t.Fatalf("failed to mark read: %v", err)
+		}
+		if f, _ := db.GetBookmarkFlags(a); !f.IsRead {
+			t.Error("expected bookmark to be read")
+		}
+		if err := db.MarkRead(a, false); err != nil {
+			t.Fatalf("failed to mark unread: %v", err)
+		}
+		if f, _ := db.GetBookmarkFlags(a); f.IsRead {
+			t.Error("expected bookmark to be unread")
+		}
+	})
+
+	t.Run("ToggleFavorite flips the flag", func(t *testing.T) {
+		if fav, err := db.ToggleFavorite(b); err != nil || !fav {
+			t.Fatalf("expected favorite after first toggle, got %v, %v", fav, err)
+		}
+		if fav, err := db.ToggleFavorite(b); err != nil || fav {
+			t.Fatalf("expected no favorite after second toggle, got %v, %v", fav, err)
+		}
+	})
+
+	t.Run("missing bookmark", func(t *testing.T) {
+		if err := db.MarkRead(999, true); err == nil {
+			t.Error("expected error marking a missing bookmark read")
+		}
+		if _, err := db.ToggleFavorite(999); err == nil {
+			t.Error("expected error toggling a missing bookmark")
+		}
+		if _, err := db.GetBookmarkFlags(999); err == nil {
+			t.Error("expected error getting flags of a missing bookmark")
+		}
+	})
+
+	t.Run("ListFilteredBookmarks", func(t *testing.T) {
+		if err := db.MarkRead(a, true); err != nil {
+			t.Fatalf("failed to mark read: %v", err)
+		}
+		if _, err := db.ToggleFavorite(a); err != nil {
+			t.Fatalf("failed to toggle favorite: %v", err)
+		}
+		tests := []struct {
+			filter BookmarkFilter
+			want   []int64
+		}{
+			{BookmarkFilter{}, []int64{a, b}},
+			{BookmarkFilter{UnreadOnly: true}, []int64{b}},
+			{BookmarkFilter{FavoritesOnly: true}, []int64{a}},
+			{BookmarkFilter{UnreadOnly: true, FavoritesOnly: true}, nil},
+			{BookmarkFilter{Domain: "example.com"}, []int64{a, b}},
+			{BookmarkFilter{Domain: "other.example"}, nil},
+		}
+		for _, tt := range tests {
+			got, err := db.ListFilteredBookmarks(tt.filter, 0)
+			if err != nil {
+				t.Fatalf("failed to list bookmarks: %v", err)
+			}
+			ids := map[int64]bool{}
+			for _, bm := range got {
+				ids[bm.ID] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("%+v: expected %v, got %+v", tt.filter, tt.want, got)
+				continue
+			}
+			for _, id := range tt.want {
+				if !ids[id] {
+					t.Errorf("%+v: expected bookmark %d in %+v", tt.filter, id, got)
+				}
+			}
+		}
+	})
+}
+
+func TestListFilteredBookmarks_SortAndFilter(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	gamma, _ := db.AddBookmark("https://b.example.com/g", "gamma")
+	alpha, _ := db.AddBookmark("https://c.example.com/a", "Alpha")
+	untitled, _ := db.AddBookmark("https://a.example.com/u", "")
+	if err := db.AddBookmarkTags(alpha, []string{"go"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	if err := db.MarkRead(gamma, true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	now := time.Now()
+	if err := db.SaveArchiveResult(alpha, now, &now, "ok", "", "https://c.example.com/a", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveFailure(gamma, now, "error", "network", "boom"); err != nil {
+		t.Fatalf("failed to save archive failure: %v", err)
+	}
+
+	tests := []struct {
+		filter BookmarkFilter
+		want   []int64
+	}{
+		// Bookmarks saved in the same second fall back to id order.
+		{BookmarkFilter{}, []int64{untitled, alpha, gamma}},
+		{BookmarkFilter{Order: SortAsc}, []int64{gamma, alpha, untitled}},
+		// Untitled bookmarks sort by URL, and case is ignored.
+		{BookmarkFilter{Sort: SortTitle}, []int64{alpha, gamma, untitled}},
+		{BookmarkFilter{Sort: SortTitle, Order: SortDesc}, []int64{untitled, gamma, alpha}},
+		{BookmarkFilter{Sort: SortDomain}, []int64{untitled, gamma, alpha}},
+		{BookmarkFilter{ReadOnly: true}, []int64{gamma}},
+		{BookmarkFilter{Tag: "#Go"}, []int64{alpha}},
+		{BookmarkFilter{Tag: "rust"}, nil},
+		{BookmarkFilter{ArchiveStatus: "ok"}, []int64{alpha}},
+		{BookmarkFilter{ArchiveStatus: "error"}, []int64{gamma}},
+		{BookmarkFilter{ArchiveStatus: NotArchived}, []int64{untitled}},
+	}
+	for _, tt := range tests {
+		got, err := db.ListFilteredBookmarks(tt.filter, 0)
+		if err != nil {
+			t.Fatalf("failed to list bookmarks: %v", err)
+		}
+		var ids []int64
+		for _, bm := range got {
+			ids = append(ids, bm.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%+v: expected %v, got %v", tt.filter, tt.want, ids)
+		}
+	}
+}
+
+func TestRandomBookmark(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	local := db.ForUser(LocalUserID)
+	if _, ok, err := local.RandomBookmark(BookmarkFilter{}); err != nil || ok {
+		t.Fatalf("expected no bookmark from an empty database, got %v, %v", ok, err)
+	}
+
+	read, _ := local.CreateBookmark(NewBookmark{URL: "https://a.example.com", Tags: []string{"go"}})
+	unread, _ := local.CreateBookmark(NewBookmark{URL: "https://b.example.com", Tags: []string{"go"}})
+	if _, err := local.CreateBookmark(NewBookmark{URL: "https://c.example.com"}); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := local.MarkRead(read, true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := db.ForUser(bob.ID).CreateBookmark(NewBookmark{URL: "https://bob.example.com", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("failed to add bob's bookmark: %v", err)
+	}
+
+	seen := map[int64]bool{}
+	for range 50 {
+		b, ok, err := local.RandomBookmark(BookmarkFilter{Tag: "go"})
+		if err != nil || !ok {
+			t.Fatalf("RandomBookmark() = %v, %v", ok, err)
+		}
+		seen[b.ID] = true
+	}
+	if len(seen) != 2 || !seen[read] || !seen[unread] {
+		t.Errorf("expected both of the user's go bookmarks to come up, got %v", seen)
+	}
+	if b, ok, err := local.RandomBookmark(BookmarkFilter{Tag: "go", UnreadOnly: true}); err != nil || !ok || b.ID != unread {
+		t.Errorf("expected the unread go bookmark, got %+v, %v, %v", b, ok, err)
+	}
+	if _, ok, err := local.RandomBookmark(BookmarkFilter{Tag: "rust"}); err != nil || ok {
+		t.Errorf("expected no match for an unused tag, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ExistingBookmarkIDs returns the IDs in ids that are bookmarks visible to
+// db, in the order given and without duplicates.
+func (db *DB) ExistingBookmarkIDs(ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args = append(args, db.owner()...)
+	rows, err := db.db.Query(`SELECT id FROM bookmarks WHERE id IN (`+placeholders+`) AND `+ownerFilter("user_id"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up bookmark IDs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	found := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark ID: %w", err)
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmark IDs: %w", err)
+	}
+
+	var out []int64
+	for _, id := range ids {
+		if found[id] {
+			out = append(out, id)
+			delete(found, id)
+		}
+	}
+	return out, nil
+}
+
+// DeleteBookmarks deletes each of ids with DeleteBookmark and returns the
+// IDs that were deleted. IDs that aren't bookmarks are skipped.
+func (db *DB) DeleteBookmarks(ids []int64) ([]int64, error) {
+	existing, err := db.ExistingBookmarkIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []int64
+	for _, id := range existing {
+		if err := db.DeleteBookmark(id); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
+
+// AddTagsToBookmarks attaches tags to each of ids in one transaction and
+// returns the IDs that were tagged. IDs that aren't bookmarks are skipped.
+func (db *DB) AddTagsToBookmarks(ids []int64, tags []string) ([]int64, error) {
+	existing, err := db.ExistingBookmarkIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+	for _, id := range existing {
+		if err := addBookmarkTags(tx, id, tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bookmark tags: %w", err)
+	}
+	return existing, nil
+}
+
+// ClearBookmarkArchives calls ClearBookmarkArchive for each of ids, queueing
+// them for re-archiving, and returns the IDs that were cleared. IDs that
+// aren't bookmarks are skipped.
+func (db *DB) ClearBookmarkArchives(ids []int64) ([]int64, error) {
+	existing, err := db.ExistingBookmarkIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var cleared []int64
+	for _, id := range existing {
+		if err := db.ClearBookmarkArchive(id); err != nil {
+			return cleared, err
+		}
+		cleared = append(cleared, id)
+	}
+	return cleared, nil
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBulkOperations(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	a, _ := db.AddBookmark("https://example.com/a", "A")
+	b, _ := db.AddBookmark("https://example.com/b", "B")
+	c, _ := db.AddBookmark("https://example.com/c", "C")
+
+	t.Run("ExistingBookmarkIDs keeps order and drops duplicates and unknown IDs", func(t *testing.T) {
+		got, err := db.ExistingBookmarkIDs([]int64{c, 999, a, c})
+		if err != nil {
+			t.Fatalf("failed to look up IDs: %v", err)
+		}
+		if want := []int64{c, a}; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("AddTagsToBookmarks", func(t *testing.T) {
+		tagged, err := db.AddTagsToBookmarks([]int64{a, b, 999}, []string{"#Later", "go"})
+		if err != nil {
+			t.Fatalf("failed to tag bookmarks: %v", err)
+		}
+		if want := []int64{a, b}; !reflect.DeepEqual(tagged, want) {
+			t.Errorf("expected %v tagged, got %v", want, tagged)
+		}
+		for _, id := range []int64{a, b} {
+			if tags, _ := db.ListBookmarkTags(id); len(tags) != 2 {
+				t.Errorf("expected bookmark %d to have 2 tags, got %v", id, tags)
+			}
+		}
+		if tags, _ := db.ListBookmarkTags(c); len(tags) != 0 {
+			t.Errorf("expected bookmark %d to be untouched, got %v", c, tags)
+		}
+	})
+
+	t.Run("ClearBookmarkArchives emits an event per bookmark", func(t *testing.T) {
+		now := time.Now()
+		if err := db.SaveArchiveResult(a, now, &now, "ok", "", "https://example.com/a", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		var events []int64
+		db.RegisterEventListener(OnArchiveClearedEvent, func(e Event) error {
+			events = append(events, e.(ArchiveClearedEvent).BookmarkID)
+			return nil
+		})
+		cleared, err := db.ClearBookmarkArchives([]int64{a, c, 999})
+		if err != nil {
+			t.Fatalf("failed to clear archives: %v", err)
+		}
+		if want := []int64{a, c}; !reflect.DeepEqual(cleared, want) || !reflect.DeepEqual(events, want) {
+			t.Errorf("expected %v cleared with events, got %v and %v", want, cleared, events)
+		}
+		if archive, _ := db.GetBookmarkArchiveStatus(a); archive.ArchiveStatus != "" {
+			t.Errorf("expected archive status to be cleared, got %q", archive.ArchiveStatus)
+		}
+	})
+
+	t.Run("DeleteBookmarks", func(t *testing.T) {
+		deleted, err := db.DeleteBookmarks([]int64{a, 999, c})
+		if err != nil {
+			t.Fatalf("failed to delete bookmarks: %v", err)
+		}
+		if want := []int64{a, c}; !reflect.DeepEqual(deleted, want) {
+			t.Errorf("expected %v deleted, got %v", want, deleted)
+		}
+		bookmarks, _ := db.ListBookmarks(0)
+		if len(bookmarks) != 1 || bookmarks[0].ID != b {
+			t.Errorf("expected only bookmark %d left, got %+v", b, bookmarks)
+		}
+	})
+
+	t.Run("empty lists are no-ops", func(t *testing.T) {
+		if got, err := db.DeleteBookmarks(nil); err != nil || len(got) != 0 {
+			t.Errorf("expected nothing deleted, got %v, %v", got, err)
+		}
+		if got, err := db.AddTagsToBookmarks(nil, []string{"x"}); err != nil || len(got) != 0 {
+			t.Errorf("expected nothing tagged, got %v, %v", got, err)
+		}
+	})
+}
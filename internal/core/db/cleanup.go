@@ -0,0 +1,231 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Cleanup rule actions.
+const (
+	CleanupActionDelete = "delete"
+	CleanupActionTag    = "tag"
+)
+
+// ErrInvalidCleanupRule is returned when a cleanup rule fails validation.
+var ErrInvalidCleanupRule = errors.New("invalid cleanup rule")
+
+// ValidateCleanupRule checks that a rule is complete. Tags are normalized in
+// place with NormalizeTag.
+func ValidateCleanupRule(r *CleanupRule) error {
+	r.Tag = NormalizeTag(r.Tag)
+	r.ActionTag = NormalizeTag(r.ActionTag)
+	if r.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidCleanupRule)
+	}
+	if r.OlderThanDays <= 0 {
+		return fmt.Errorf("%w: age must be at least one day", ErrInvalidCleanupRule)
+	}
+	switch r.Action {
+	case CleanupActionDelete:
+		if r.ActionTag != "" {
+			return fmt.Errorf("%w: %s rules don't take a tag to add", ErrInvalidCleanupRule, r.Action)
+		}
+	case CleanupActionTag:
+		if r.ActionTag == "" {
+			return fmt.Errorf("%w: %s rules need a tag to add", ErrInvalidCleanupRule, r.Action)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q (want %s or %s)", ErrInvalidCleanupRule, r.Action, CleanupActionDelete, CleanupActionTag)
+	}
+	return nil
+}
+
+const cleanupRuleColumns = `id, name, COALESCE(tag, ''), unread_only, older_than_days, action, COALESCE(action_tag, ''), enabled, created_at, COALESCE(last_run_at, '')`
+
+func scanCleanupRule(row interface{ Scan(...any) error }) (CleanupRule, error) {
+	var r CleanupRule
+	err := row.Scan(&r.ID, &r.Name, &r.Tag, &r.UnreadOnly, &r.OlderThanDays, &r.Action, &r.ActionTag, &r.Enabled, &r.CreatedAt, &r.LastRunAt)
+	return r, err
+}
+
+// CreateCleanupRule validates and stores a new rule, returning its ID.
+// Rule names are unique.
+func (db *DB) CreateCleanupRule(r CleanupRule) (int64, error) {
+	if err := ValidateCleanupRule(&r); err != nil {
+		return 0, err
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO cleanup_rules (name, tag, unread_only, older_than_days, action, action_tag, enabled, created_at)
+		VALUES (?, NULLIF(?, ''), ?, ?, ?, NULLIF(?, ''), ?, ?)
+	`, r.Name, r.Tag, r.UnreadOnly, r.OlderThanDays, r.Action, r.ActionTag, r.Enabled, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cleanup rule: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return id, nil
+}
+
+// GetCleanupRule returns a single rule by ID.
+func (db *DB) GetCleanupRule(id int64) (CleanupRule, error) {
+	r, err := scanCleanupRule(db.db.QueryRow(`SELECT `+cleanupRuleColumns+` FROM cleanup_rules WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CleanupRule{}, fmt.Errorf("cleanup rule not found: %d", id)
+		}
+		return CleanupRule{}, fmt.Errorf("failed to get cleanup rule: %w", err)
+	}
+	return r, nil
+}
+
+// ListCleanupRules returns all rules in creation order.
+func (db *DB) ListCleanupRules() ([]CleanupRule, error) {
+	rows, err := db.db.Query(`SELECT ` + cleanupRuleColumns + ` FROM cleanup_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cleanup rules: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var rules []CleanupRule
+	for rows.Next() {
+		r, err := scanCleanupRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cleanup rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate cleanup rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetCleanupRuleEnabled enables or disables a rule. Disabled rules are kept
+// but skipped by scheduled runs.
+func (db *DB) SetCleanupRuleEnabled(id int64, enabled bool) error {
+	return db.updateCleanupRule(id, `UPDATE cleanup_rules SET enabled = ? WHERE id = ?`, enabled, id)
+}
+
+// MarkCleanupRuleRun records when a rule last ran.
+func (db *DB) MarkCleanupRuleRun(id int64, at time.Time) error {
+	return db.updateCleanupRule(id, `UPDATE cleanup_rules SET last_run_at = ? WHERE id = ?`, at.Format(time.RFC3339), id)
+}
+
+// DeleteCleanupRule removes a rule. Its audit log entries are kept.
+func (db *DB) DeleteCleanupRule(id int64) error {
+	return db.updateCleanupRule(id, `DELETE FROM cleanup_rules WHERE id = ?`, id)
+}
+
+func (db *DB) updateCleanupRule(id int64, query string, args ...any) error {
+	res, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update cleanup rule: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("cleanup rule not found: %d", id)
+	}
+	return nil
+}
+
+// ListCleanupRuleMatches returns the bookmarks a rule applies to at now,
+// oldest first. Bookmarks that already carry the tag a tag rule adds are
+// skipped, so re-running a rule is a no-op.
+func (db *DB) ListCleanupRuleMatches(r CleanupRule, now time.Time) ([]Bookmark, error) {
+	cutoff := now.AddDate(0, 0, -r.OlderThanDays).Format(time.RFC3339)
+	hasTag := `EXISTS (
+		SELECT 1 FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.bookmark_id = b.id AND t.name = ?
+	)`
+	query := `
+		SELECT b.id, b.url, b.title, b.created_at, COALESCE(b.slug, '')
+		FROM bookmarks b
+		WHERE julianday(b.created_at) < julianday(?)
+		  AND (? = '' OR ` + hasTag + `)
+		  AND (? = 0 OR b.last_read_at IS NULL)
+		  AND (? = '' OR NOT ` + hasTag + `)
+		  AND ` + ownerFilter("b.user_id") + `
+		ORDER BY julianday(b.created_at), b.id`
+	args := append([]any{cutoff, r.Tag, r.Tag, r.UnreadOnly, r.ActionTag, r.ActionTag}, db.owner()...)
+	bookmarks, err := db.queryBookmarks(query, args, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match cleanup rule %q: %w", r.Name, err)
+	}
+	return bookmarks, nil
+}
+
+// LogCleanupAction appends an entry to the cleanup audit log.
+func (db *DB) LogCleanupAction(e CleanupLogEntry) error {
+	if _, err := db.db.Exec(`
+		INSERT INTO cleanup_log (rule_id, rule_name, bookmark_id, bookmark_url, bookmark_title, action, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.RuleID, e.RuleName, e.BookmarkID, e.BookmarkURL, e.BookmarkTitle, e.Action, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to write cleanup log: %w", err)
+	}
+	return nil
+}
+
+// ListCleanupLog returns audit log entries, newest first.
+func (db *DB) ListCleanupLog(limit int) ([]CleanupLogEntry, error) {
+	query := `
+		SELECT id, rule_id, rule_name, bookmark_id, bookmark_url, bookmark_title, action, created_at
+		FROM cleanup_log
+		ORDER BY id DESC`
+	var args []any
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cleanup log: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var entries []CleanupLogEntry
+	for rows.Next() {
+		var e CleanupLogEntry
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.RuleName, &e.BookmarkID, &e.BookmarkURL, &e.BookmarkTitle, &e.Action, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cleanup log entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate cleanup log: %w", err)
+	}
+	return entries, nil
+}
+
+// MarkBookmarkRead records that a bookmark's archive was opened, so unread
+// cleanup rules leave it alone, and counts the view for search ranking.
+func (db *DB) MarkBookmarkRead(id int64) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET last_read_at = ?, view_count = view_count + 1 WHERE id = ? AND `+ownerFilter("user_id"),
+		append([]any{time.Now().Format(time.RFC3339), id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to mark bookmark read: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestValidateCleanupRule tests cleanup rule validation.
+func TestValidateCleanupRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    CleanupRule
+		wantErr bool
+	}{
+		{"delete rule", CleanupRule{Name: "temp", Tag: "#Temp", OlderThanDays: 30, Action: CleanupActionDelete}, false},
+		{"tag rule", CleanupRule{Name: "stale", UnreadOnly: true, OlderThanDays: 365, Action: CleanupActionTag, ActionTag: "stale"}, false},
+		{"missing name", CleanupRule{OlderThanDays: 30, Action: CleanupActionDelete}, true},
+		{"no age", CleanupRule{Name: "x", Action: CleanupActionDelete}, true},
+		{"unknown action", CleanupRule{Name: "x", OlderThanDays: 1, Action: "archive"}, true},
+		{"tag rule without tag", CleanupRule{Name: "x", OlderThanDays: 1, Action: CleanupActionTag}, true},
+		{"delete rule with tag", CleanupRule{Name: "x", OlderThanDays: 1, Action: CleanupActionDelete, ActionTag: "old"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCleanupRule(&tt.rule)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCleanupRule) {
+					t.Errorf("expected ErrInvalidCleanupRule, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("normalizes tags", func(t *testing.T) {
+		r := CleanupRule{Name: "temp", Tag: " #Temp ", OlderThanDays: 1, Action: CleanupActionDelete}
+		if err := ValidateCleanupRule(&r); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if r.Tag != "temp" {
+			t.Errorf("expected normalized tag, got %q", r.Tag)
+		}
+	})
+}
+
+// TestCleanupRules tests storing and updating cleanup rules.
+func TestCleanupRules(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.CreateCleanupRule(CleanupRule{Name: "temp", Tag: "temp", OlderThanDays: 30, Action: CleanupActionDelete, Enabled: true})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		r, err := db.GetCleanupRule(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if r.Name != "temp" || r.Tag != "temp" || r.OlderThanDays != 30 || !r.Enabled || r.CreatedAt == "" || r.LastRunAt != "" {
+			t.Errorf("unexpected rule: %+v", r)
+		}
+	})
+
+	t.Run("names are unique", func(t *testing.T) {
+		if _, err := db.CreateCleanupRule(CleanupRule{Name: "temp", OlderThanDays: 1, Action: CleanupActionDelete}); err == nil {
+			t.Error("expected error for duplicate name")
+		}
+	})
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		if _, err := db.CreateCleanupRule(CleanupRule{Name: "bad"}); !errors.Is(err, ErrInvalidCleanupRule) {
+			t.Errorf("expected ErrInvalidCleanupRule, got %v", err)
+		}
+	})
+
+	t.Run("disable and mark run", func(t *testing.T) {
+		if err := db.SetCleanupRuleEnabled(id, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.MarkCleanupRuleRun(id, time.Now()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		rules, err := db.ListCleanupRules()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(rules) != 1 || rules[0].Enabled || rules[0].LastRunAt == "" {
+			t.Errorf("unexpected rules: %+v", rules)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := db.DeleteCleanupRule(id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.GetCleanupRule(id); err == nil {
+			t.Error("expected deleted rule to be gone")
+		}
+		if err := db.DeleteCleanupRule(id); err == nil {
+			t.Error("expected error deleting unknown rule")
+		}
+	})
+}
+
+// TestListCleanupRuleMatches tests which bookmarks a rule selects.
+func TestListCleanupRuleMatches(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	temp, _ := db.CreateBookmark(NewBookmark{URL: "https://temp.com", Title: "Temp", Tags: []string{"temp"}})
+	read, _ := db.CreateBookmark(NewBookmark{URL: "https://read.com", Title: "Read"})
+	if _, err := db.CreateBookmark(NewBookmark{URL: "https://stale.com", Title: "Stale", Tags: []string{"stale"}}); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := db.MarkBookmarkRead(read); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+
+	ids := func(bs []Bookmark) []int64 {
+		var out []int64
+		for _, b := range bs {
+			out = append(out, b.ID)
+		}
+		return out
+	}
+	later := time.Now().AddDate(0, 0, 31)
+
+	t.Run("too recent", func(t *testing.T) {
+		got, err := db.ListCleanupRuleMatches(CleanupRule{Name: "all", OlderThanDays: 30, Action: CleanupActionDelete}, time.Now())
+		if err != nil || len(got) != 0 {
+			t.Errorf("expected no matches, got %v (err=%v)", ids(got), err)
+		}
+	})
+
+	t.Run("by tag", func(t *testing.T) {
+		got, err := db.ListCleanupRuleMatches(CleanupRule{Name: "temp", Tag: "temp", OlderThanDays: 30, Action: CleanupActionDelete}, later)
+		if err != nil || len(got) != 1 || got[0].ID != temp {
+			t.Errorf("expected only the temp bookmark, got %v (err=%v)", ids(got), err)
+		}
+	})
+
+	t.Run("unread only, skipping already tagged", func(t *testing.T) {
+		r := CleanupRule{Name: "stale", UnreadOnly: true, OlderThanDays: 30, Action: CleanupActionTag, ActionTag: "stale"}
+		got, err := db.ListCleanupRuleMatches(r, later)
+		if err != nil || len(got) != 1 || got[0].ID != temp {
+			t.Errorf("expected only the unread, untagged bookmark %d, got %v (err=%v)", temp, ids(got), err)
+		}
+	})
+
+	t.Run("unknown bookmark can't be marked read", func(t *testing.T) {
+		if err := db.MarkBookmarkRead(99999); err == nil {
+			t.Error("expected error for unknown bookmark")
+		}
+	})
+}
+
+// TestCleanupLog tests the cleanup audit log.
+func TestCleanupLog(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	for i, url := range []string{"https://one.com", "https://two.com"} {
+		if err := db.LogCleanupAction(CleanupLogEntry{RuleID: 1, RuleName: "temp", BookmarkID: int64(i + 1), BookmarkURL: url, BookmarkTitle: "T", Action: CleanupActionDelete}); err != nil {
+			t.Fatalf("failed to log action: %v", err)
+		}
+	}
+
+	entries, err := db.ListCleanupLog(0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(entries) != 2 || entries[0].BookmarkURL != "https://two.com" || entries[0].CreatedAt == "" {
+		t.Errorf("expected newest entry first, got %+v", entries)
+	}
+	if entries, err := db.ListCleanupLog(1); err != nil || len(entries) != 1 {
+		t.Errorf("expected limit to apply, got %d (err=%v)", len(entries), err)
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+)
+
+// compressHTML gzip-compresses archived HTML for storage.
+func compressHTML(html string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	if _, err := io.WriteString(zw, html); err != nil {
+		return nil, fmt.Errorf("failed to compress archived html: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archived html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressHTML reverses compressHTML. Empty input yields an empty string.
+func decompressHTML(gz []byte) (string, error) {
+	if len(gz) == 0 {
+		return "", nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return "", fmt.Errorf("failed to read compressed archived html: %w", err)
+	}
+	defer func() {
+		if err := zr.Close(); err != nil {
+			log.Printf("failed to close gzip reader: %v", err)
+		}
+	}()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress archived html: %w", err)
+	}
+	return string(out), nil
+}
+
+// compressExistingArchives is the data migration for 0006-compress-archives.
+// It moves every plain-text archived_html into archived_html_gz one row at a
+// time so large databases don't need all archives in memory at once.
+func compressExistingArchives(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id FROM bookmark_archives WHERE archived_html IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to list archives to compress: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan archive id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	for _, id := range ids {
+		var html string
+		if err := tx.QueryRow(`SELECT archived_html FROM bookmark_archives WHERE id = ?`, id).Scan(&html); err != nil {
+			return fmt.Errorf("failed to read archive %d: %w", id, err)
+		}
+		gz, err := compressHTML(html)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE bookmark_archives
+			SET archived_html_gz = ?, archived_html = NULL
+			WHERE id = ?
+		`, gz, id); err != nil {
+			return fmt.Errorf("failed to store compressed archive %d: %w", id, err)
+		}
+	}
+	return nil
+}
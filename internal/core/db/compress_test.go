@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// newTestDBAt creates an in-memory database migrated only through version,
+// for exercising data migrations against an older schema.
+func newTestDBAt(t *testing.T, version string) *DB {
+	t.Helper()
+	db, err := NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	if err := db.migrate(version); err != nil {
+		t.Fatalf("failed to migrate test database to %s: %v", version, err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	return db
+}
+
+// TestCompressHTML tests the gzip round trip.
+func TestCompressHTML(t *testing.T) {
+	t.Run("round trips", func(t *testing.T) {
+		html := "<html><body>" + strings.Repeat("<p>hello</p>", 1000) + "</body></html>"
+		gz, err := compressHTML(html)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(gz) >= len(html) {
+			t.Errorf("expected compressed size < %d, got %d", len(html), len(gz))
+		}
+		got, err := decompressHTML(gz)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got != html {
+			t.Error("expected decompressed html to match original")
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		got, err := decompressHTML(nil)
+		if err != nil || got != "" {
+			t.Errorf("expected empty string and no error, got %q, %v", got, err)
+		}
+	})
+
+	t.Run("rejects corrupt data", func(t *testing.T) {
+		if _, err := decompressHTML([]byte("not gzip")); err == nil {
+			t.Error("expected error for corrupt data")
+		}
+	})
+}
+
+// addLegacyBookmark inserts a bookmark directly, for databases migrated to a
+// version older than CreateBookmark expects.
+func addLegacyBookmark(t *testing.T, db *DB, url string) int64 {
+	t.Helper()
+	res, err := db.db.Exec(`INSERT INTO bookmarks (url, title, created_at) VALUES (?, '', '2000-01-01T00:00:00Z')`, url)
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("failed to get bookmark ID: %v", err)
+	}
+	return id
+}
+
+// TestCompressExistingArchives tests the 0006 data migration.
+func TestCompressExistingArchives(t *testing.T) {
+	db := newTestDBAt(t, "0005-tags")
+
+	id := addLegacyBookmark(t, db, "https://example.com")
+	if _, err := db.db.Exec(`
+		INSERT INTO bookmark_archives (bookmark_id, captured_at, archived_url, archived_html)
+		VALUES (?, '2000-01-01T00:00:00Z', 'https://example.com', '<html>legacy</html>')
+	`, id); err != nil {
+		t.Fatalf("failed to insert legacy row: %v", err)
+	}
+
+	if err := db.migrate("0006-compress-archives"); err != nil {
+		t.Fatalf("failed to apply migration: %v", err)
+	}
+
+	var plain sql.NullString
+	var gz []byte
+	if err := db.db.QueryRow(`
+		SELECT archived_html, archived_html_gz FROM bookmark_archives WHERE bookmark_id = ?
+	`, id).Scan(&plain, &gz); err != nil {
+		t.Fatalf("failed to query archive: %v", err)
+	}
+	if plain.Valid {
+		t.Errorf("expected archived_html to be NULL, got %q", plain.String)
+	}
+	got, err := decompressHTML(gz)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	if got != "<html>legacy</html>" {
+		t.Errorf("expected legacy html, got %q", got)
+	}
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// CopyReport summarizes a CopyFrom run. Every count has been verified
+// against the source.
+type CopyReport struct {
+	// Rows is the number of rows copied per table.
+	Rows map[string]int64 `json:"rows"`
+	// Blobs is the number of archive blobs (HTML, screenshots and downloads)
+	// copied, and BlobBytes their compressed size.
+	Blobs     int   `json:"blobs"`
+	BlobBytes int64 `json:"blob_bytes"`
+}
+
+// SnapshotTo writes a consistent copy of the database, including anything
+// still in the write-ahead log, to a new file at path. The database itself is
+// left untouched, so this works on a read-only connection.
+func (db *DB) SnapshotTo(path string) error {
+	if _, err := db.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// CopyFrom copies everything in src into db: every table's rows with their
+// IDs, and every archive blob src's versions refer to, read from src's blob
+// store and written to db's. Both databases must be migrated to the same
+// schema version, and db must be empty.
+//
+// Blobs are checked to decompress to content matching their SHA-256 key
+// both before and after they are written, and row counts are compared table
+// by table once the copy is committed. Blobs are copied before any rows, so
+// a failed run never leaves versions pointing at missing content.
+func (db *DB) CopyFrom(ctx context.Context, src *DB) (CopyReport, error) {
+	report := CopyReport{Rows: make(map[string]int64)}
+
+	srcVersions, err := src.schemaVersions()
+	if err != nil {
+		return report, err
+	}
+	dstVersions, err := db.schemaVersions()
+	if err != nil {
+		return report, err
+	}
+	if srcVersions != dstVersions {
+		return report, fmt.Errorf("source and destination schemas differ; migrate both first")
+	}
+
+	tables, err := db.dataTables()
+	if err != nil {
+		return report, err
+	}
+	for _, table := range tables {
+		n, err := db.countRows(table)
+		if err != nil {
+			return report, err
+		}
+		if n > seededRows[table] {
+			return report, fmt.Errorf("destination is not empty: %s has %d rows", table, n)
+		}
+	}
+
+	if err := db.copyBlobsFrom(ctx, src, &report); err != nil {
+		return report, err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+	for table := range seededRows {
+		if _, err := tx.Exec(`DELETE FROM "` + table + `"`); err != nil {
+			return report, fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+	for _, table := range tables {
+		n, err := copyTable(tx, src, table)
+		if err != nil {
+			return report, err
+		}
+		report.Rows[table] = n
+	}
+	// The table-by-table copy doesn't leave the search triggers with
+	// complete rows, so rebuild the index with the source's tokenizer.
+	tokenizer, err := searchTokenizer(tx)
+	if err != nil {
+		return report, err
+	}
+	if err := applySearchTokenizer(tx, tokenizer); err != nil {
+		return report, err
+	}
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit copied rows: %w", err)
+	}
+
+	for _, table := range tables {
+		want, err := src.countRows(table)
+		if err != nil {
+			return report, err
+		}
+		got, err := db.countRows(table)
+		if err != nil {
+			return report, err
+		}
+		if got != want || report.Rows[table] != want {
+			return report, fmt.Errorf("row count mismatch for %s: source has %d, destination %d", table, want, got)
+		}
+	}
+	return report, nil
+}
+
+// schemaVersions returns the applied migrations as a single comparable string.
+func (db *DB) schemaVersions() (string, error) {
+	rows, err := db.db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	var versions []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return "", fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to iterate schema versions: %w", err)
+	}
+	return strings.Join(versions, ","), nil
+}
+
+// seededRows counts the rows migrations insert into otherwise empty tables,
+// such as the first account in users. CopyFrom replaces them with the
+// source's rows.
+var seededRows = map[string]int64{"users": 1, "search_settings": 1}
+
+// dataTables lists the tables CopyFrom copies row by row: all of them except
+// SQLite's own, the migration bookkeeping, archive_blobs, whose contents go
+// through the blob stores instead, and full-text indexes (virtual tables and
+// their shadow tables), which are rebuilt.
+func (db *DB) dataTables() ([]string, error) {
+	rows, err := db.db.Query(`
+		SELECT m.name FROM sqlite_master m
+		WHERE m.type = 'table'
+		  AND m.name NOT LIKE 'sqlite_%'
+		  AND m.name NOT IN ('schema_migrations', 'archive_blobs')
+		  AND NOT EXISTS (
+		      SELECT 1 FROM sqlite_master v
+		      WHERE v.type = 'table' AND v.sql LIKE 'CREATE VIRTUAL TABLE%'
+		        AND (m.name = v.name OR substr(m.name, 1, length(v.name) + 1) = v.name || '_')
+		  )
+		ORDER BY m.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tables: %w", err)
+	}
+	return tables, nil
+}
+
+// countRows returns the number of rows in table, which must come from dataTables.
+func (db *DB) countRows(table string) (int64, error) {
+	var n int64
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count rows in %s: %w", table, err)
+	}
+	return n, nil
+}
+
+// copyTable copies every row of table from src using tx and returns how
+// many were copied. Columns are matched by name.
+func copyTable(tx *sql.Tx, src *DB, table string) (int64, error) {
+	rows, err := src.db.Query(`SELECT * FROM "` + table + `"`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = `"` + c + `"`
+	}
+	stmt, err := tx.Prepare(`INSERT INTO "` + table + `" (` + strings.Join(quoted, ", ") + `) VALUES (` +
+		strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + `)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare copy of %s: %w", table, err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Printf("failed to close statement: %v", err)
+		}
+	}()
+
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, fmt.Errorf("failed to scan %s row: %w", table, err)
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return n, fmt.Errorf("failed to copy %s row: %w", table, err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("failed to iterate %s: %w", table, err)
+	}
+	return n, nil
+}
+
+// copyBlobsFrom copies every blob src's archive versions refer to into db's
+// blob store, verifying each one on the way in and on the way out.
+func (db *DB) copyBlobsFrom(ctx context.Context, src *DB, report *CopyReport) error {
+	rows, err := src.db.Query(`
+		SELECT blob_hash FROM bookmark_archives WHERE blob_hash IS NOT NULL
+		UNION
+		SELECT screenshot_hash FROM bookmark_archives WHERE screenshot_hash IS NOT NULL
+		UNION
+		SELECT download_hash FROM bookmark_archives WHERE download_hash IS NOT NULL
+		UNION
+		SELECT audio_hash FROM bookmark_archives WHERE audio_hash IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list archive blobs: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan archive blob key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return fmt.Errorf("failed to iterate archive blob keys: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		log.Printf("failed to close rows: %v", err)
+	}
+
+	for _, key := range keys {
+		gz, err := src.getArchiveBlob(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read source blob %s: %w", key, err)
+		}
+		if err := verifyBlob(key, gz); err != nil {
+			return fmt.Errorf("source blob is corrupt: %w", err)
+		}
+		if err := db.blobs.Put(ctx, key, gz); err != nil {
+			return fmt.Errorf("failed to write blob %s: %w", key, err)
+		}
+		stored, err := db.blobs.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read back blob %s: %w", key, err)
+		}
+		if err := verifyBlob(key, stored); err != nil {
+			return fmt.Errorf("copied blob is corrupt: %w", err)
+		}
+		report.Blobs++
+		report.BlobBytes += int64(len(gz))
+	}
+	return nil
+}
+
+// verifyBlob checks that a compressed blob decompresses to content whose
+// SHA-256 is key.
+func verifyBlob(key string, gz []byte) error {
+	content, err := decompressHTML(gz)
+	if err != nil {
+		return fmt.Errorf("blob %s: %w", key, err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if got := hex.EncodeToString(sum[:]); got != key {
+		return fmt.Errorf("blob %s has content hash %s", key, got)
+	}
+	return nil
+}
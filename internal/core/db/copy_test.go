@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCopyFrom tests copying a database into another one.
+func TestCopyFrom(t *testing.T) {
+	ctx := context.Background()
+
+	// The source starts out on an old schema with an inline archive, like a
+	// database from an early release.
+	src := newTestDBAt(t, "0005-tags")
+	legacyID := addLegacyBookmark(t, src, "https://legacy.example.com")
+	if _, err := src.db.Exec(`
+		INSERT INTO bookmark_archives (bookmark_id, captured_at, archived_url, archived_html)
+		VALUES (?, '2000-01-01T00:00:00Z', 'https://legacy.example.com', '<html>legacy</html>')
+	`, legacyID); err != nil {
+		t.Fatalf("failed to insert legacy archive: %v", err)
+	}
+	if err := src.Migrate(); err != nil {
+		t.Fatalf("failed to upgrade source: %v", err)
+	}
+	id, err := src.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := src.SetBookmarkTags(id, []string{"go"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := src.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html>new</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := src.SaveArchiveScreenshot(id, []byte("png")); err != nil {
+		t.Fatalf("failed to save screenshot: %v", err)
+	}
+	if _, err := src.Reindex(TokenizerPorter); err != nil {
+		t.Fatalf("failed to reindex source: %v", err)
+	}
+
+	t.Run("copies rows and blobs into the destination store", func(t *testing.T) {
+		dst := newTestDB(t)
+		t.Cleanup(func() {
+			if err := dst.Close(); err != nil {
+				t.Errorf("failed to close db: %v", err)
+			}
+		})
+		store := &memBlobStore{blobs: map[string][]byte{}}
+		dst.SetBlobStore(store)
+
+		report, err := dst.CopyFrom(ctx, src)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if report.Rows["bookmarks"] != 2 || report.Rows["bookmark_archives"] != 2 || report.Rows["tags"] != 1 {
+			t.Errorf("unexpected row counts: %v", report.Rows)
+		}
+		if report.Blobs != 3 || len(store.blobs) != 3 {
+			t.Errorf("expected 3 blobs, got %d (%d in store)", report.Blobs, len(store.blobs))
+		}
+		if n := countBlobs(t, dst); n != 0 {
+			t.Errorf("expected no blobs in sqlite, got %d", n)
+		}
+
+		legacy, err := dst.ListArchiveVersions(legacyID)
+		if err != nil || len(legacy) != 1 {
+			t.Fatalf("expected 1 legacy archive version, got %d, %v", len(legacy), err)
+		}
+		a, err := dst.GetArchiveVersion(legacyID, legacy[0].ID)
+		if err != nil {
+			t.Fatalf("failed to get archive: %v", err)
+		}
+		if a.ArchivedHTML != "<html>legacy</html>" {
+			t.Errorf("expected legacy html, got %q", a.ArchivedHTML)
+		}
+		versions, err := dst.ListArchiveVersions(id)
+		if err != nil || len(versions) != 1 {
+			t.Fatalf("expected 1 archive version, got %d, %v", len(versions), err)
+		}
+		shot, err := dst.GetArchiveScreenshot(id, versions[0].ID)
+		if err != nil {
+			t.Fatalf("failed to get screenshot: %v", err)
+		}
+		if string(shot) != "png" {
+			t.Errorf("expected screenshot, got %q", shot)
+		}
+		tags, err := dst.ListBookmarkTags(id)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		if len(tags) != 1 || tags[0] != "go" {
+			t.Errorf("expected tag go, got %v", tags)
+		}
+		results, err := dst.SearchBookmarks("tag:go", BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != id {
+			t.Errorf("expected the search index to be rebuilt, got %v", results)
+		}
+		if tokenizer, err := dst.SearchTokenizer(); err != nil || tokenizer != TokenizerPorter {
+			t.Errorf("expected the source's tokenizer %s, got %s, %v", TokenizerPorter, tokenizer, err)
+		}
+	})
+
+	t.Run("refuses a destination with data", func(t *testing.T) {
+		dst := newTestDB(t)
+		t.Cleanup(func() {
+			if err := dst.Close(); err != nil {
+				t.Errorf("failed to close db: %v", err)
+			}
+		})
+		if _, err := dst.AddBookmark("https://other.example.com", ""); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		_, err := dst.CopyFrom(ctx, src)
+		if err == nil || !strings.Contains(err.Error(), "not empty") {
+			t.Errorf("expected not empty error, got %v", err)
+		}
+	})
+
+	t.Run("refuses a different schema", func(t *testing.T) {
+		dst := newTestDBAt(t, "0007-metadata")
+		_, err := dst.CopyFrom(ctx, src)
+		if err == nil || !strings.Contains(err.Error(), "schemas differ") {
+			t.Errorf("expected schema error, got %v", err)
+		}
+	})
+
+	t.Run("rejects corrupt blobs", func(t *testing.T) {
+		if _, err := src.db.Exec(`UPDATE archive_blobs SET data = ? WHERE hash = (SELECT MIN(hash) FROM archive_blobs)`,
+			mustCompress(t, "tampered")); err != nil {
+			t.Fatalf("failed to tamper with blob: %v", err)
+		}
+		dst := newTestDB(t)
+		t.Cleanup(func() {
+			if err := dst.Close(); err != nil {
+				t.Errorf("failed to close db: %v", err)
+			}
+		})
+		_, err := dst.CopyFrom(ctx, src)
+		if err == nil || !strings.Contains(err.Error(), "corrupt") {
+			t.Errorf("expected corrupt blob error, got %v", err)
+		}
+		if n, _ := dst.countRows("bookmarks"); n != 0 {
+			t.Errorf("expected no rows copied, got %d", n)
+		}
+	})
+}
+
+// TestSnapshotTo tests writing a copy of the database to a file.
+func TestSnapshotTo(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	if _, err := db.AddBookmark("https://example.com", "Example"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := db.SnapshotTo(path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	snap, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("failed to open snapshot: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := snap.Close(); err != nil {
+			t.Errorf("failed to close snapshot: %v", err)
+		}
+	})
+	if n, err := snap.countRows("bookmarks"); err != nil || n != 1 {
+		t.Errorf("expected 1 bookmark in snapshot, got %d, %v", n, err)
+	}
+
+	if err := db.SnapshotTo(path); err == nil {
+		t.Error("expected error when the snapshot file exists")
+	}
+}
+
+func mustCompress(t *testing.T, s string) []byte {
+	t.Helper()
+	gz, err := compressHTML(s)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	return gz
+}
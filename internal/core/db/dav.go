@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// DAVBookmark is what the WebDAV archive tree needs about one archived
+// bookmark: where its latest archive goes (by tag and by capture date) and
+// what to call the file.
+type DAVBookmark struct {
+	ID    int64
+	URL   string
+	Title string
+	// ArchivedAt is the capture time of the latest archive version, as
+	// RFC3339 text.
+	ArchivedAt string
+	Tags       []string
+}
+
+// ListDAVBookmarks returns every bookmark the handle sees that has an
+// archived page, oldest first, with its tags.
+func (db *DB) ListDAVBookmarks() ([]DAVBookmark, error) {
+	rows, err := db.db.Query(`
+		SELECT b.id, b.url, COALESCE(b.title, ''), b.archived_at
+		FROM bookmarks b
+		JOIN bookmark_archives v
+			ON v.bookmark_id = b.id AND v.captured_at = b.archived_at
+		WHERE v.blob_hash IS NOT NULL AND `+ownerFilter("b.user_id")+`
+		ORDER BY b.id
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived bookmarks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	out := []DAVBookmark{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var b DAVBookmark
+		if err := rows.Scan(&b.ID, &b.URL, &b.Title, &b.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark: %w", err)
+		}
+		index[b.ID] = len(out)
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmarks: %w", err)
+	}
+
+	if err := db.collectGraphValues(`
+		SELECT bt.bookmark_id, t.name
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		JOIN bookmarks b ON b.id = bt.bookmark_id
+		WHERE `+ownerFilter("b.user_id")+`
+		ORDER BY t.name
+	`, "bookmark tags", func(id int64, name string) {
+		if i, ok := index[id]; ok {
+			out[i].Tags = append(out[i].Tags, name)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
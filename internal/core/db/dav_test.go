@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// TestListDAVBookmarks tests listing archived bookmarks for the WebDAV tree.
+func TestListDAVBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	archived, _ := db.CreateBookmark(NewBookmark{URL: "https://a.example.com", Title: "A", Tags: []string{"go", "db"}})
+	if _, err := db.CreateBookmark(NewBookmark{URL: "https://unarchived.example.com"}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	failed, _ := db.CreateBookmark(NewBookmark{URL: "https://failed.example.com"})
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := db.SaveArchiveResult(archived, now, &now, "ok", "", "https://a.example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveResult(failed, now, nil, "failed", "timeout", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobs, err := db.ForUser(bob.ID).CreateBookmark(NewBookmark{URL: "https://bob.example.com"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	if err := db.SaveArchiveResult(bobs, now, &now, "ok", "", "https://bob.example.com", "<html>bob</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	got, err := db.ForUser(LocalUserID).ListDAVBookmarks()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 1 || got[0].ID != archived || got[0].Title != "A" {
+		t.Fatalf("expected the local user's archived bookmark, got %+v", got)
+	}
+	if got[0].ArchivedAt != now.Format(time.RFC3339) || len(got[0].Tags) != 2 || got[0].Tags[0] != "db" {
+		t.Errorf("unexpected bookmark %+v", got[0])
+	}
+
+	all, err := db.ListDAVBookmarks()
+	if err != nil || len(all) != 2 {
+		t.Errorf("expected every user's 2 archived bookmarks unscoped, got %d, %v", len(all), err)
+	}
+}
@@ -1,36 +1,163 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
+// execer is satisfied by both *sql.DB and *sql.Tx, so helpers can run
+// either standalone or as part of a transaction.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// dataMigrations are Go functions run inside the same transaction as the SQL
+// migration with the matching version, for changes SQL alone can't express.
+var dataMigrations = map[string]func(tx *sql.Tx) error{
+	"0006-compress-archives": compressExistingArchives,
+	"0008-archive-blobs":     relocateArchiveBlobs,
+	"0020-search":            rebuildSearchIndex,
+	"0029-search-tokenizer":  useDefaultSearchTokenizer,
+	"0031-bookmark-slugs":    assignBookmarkSlugs,
+}
+
+// conn runs a handle's statements under its context, so they are canceled
+// with it; see WithContext. Other *sql.DB methods are used as they are.
+type conn struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (c *conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.ExecContext(c.ctx, query, args...)
+}
+
+func (c *conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.QueryContext(c.ctx, query, args...)
+}
+
+func (c *conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.QueryRowContext(c.ctx, query, args...)
+}
+
+// Begin starts a transaction that is rolled back if the context is done
+// before it is committed.
+func (c *conn) Begin() (*sql.Tx, error) {
+	return c.BeginTx(c.ctx, nil)
+}
+
 type DB struct {
-	db             *sql.DB
+	db             *conn
 	eventListeners map[EventKind][]EventListener
+	// blobs holds archived HTML; see SetBlobStore.
+	blobs BlobStore
+	// ranking tunes SearchBookmarks; see SetSearchRanking.
+	ranking SearchRanking
+	// userID scopes bookmark queries to one user's collection; 0 means
+	// every user's. See ForUser.
+	userID int64
+}
+
+// ForUser returns a handle on the same database that only sees and creates
+// bookmarks owned by userID, and the API tokens of that user. Event
+// listeners, the blob store and search ranking are shared with db.
+func (db *DB) ForUser(userID int64) *DB {
+	scoped := *db
+	scoped.userID = userID
+	return &scoped
+}
+
+// WithContext returns a handle on the same database, scoped to the same
+// user, whose queries and archive blob reads and writes are canceled when
+// ctx is done, such as a web request's context when the client goes away
+// or the request times out. Handles from NewSQLiteDB use
+// context.Background().
+func (db *DB) WithContext(ctx context.Context) *DB {
+	scoped := *db
+	scoped.db = &conn{DB: db.db.DB, ctx: ctx}
+	return &scoped
+}
+
+// context returns the context the handle's statements run under.
+func (db *DB) context() context.Context {
+	return db.db.ctx
+}
+
+// UserID returns the user the handle is scoped to, or 0 if it sees every
+// user's bookmarks.
+func (db *DB) UserID() int64 {
+	return db.userID
+}
+
+// ownerFilter returns a condition limiting col, a user_id column, to the
+// handle's user. Bind it with db.owner(); unscoped handles match every row.
+func ownerFilter(col string) string {
+	return "(? = 0 OR " + col + " = ?)"
+}
+
+// owner returns the arguments for an ownerFilter condition.
+func (db *DB) owner() []any {
+	return []any{db.userID, db.userID}
+}
+
+// actingUserID is the user whose per-user rows, such as import checkpoints
+// and presets, the handle reads and writes: its user, or LocalUserID on an
+// unscoped handle.
+func (db *DB) actingUserID() int64 {
+	if db.userID == 0 {
+		return LocalUserID
+	}
+	return db.userID
+}
+
+// checkOwner returns a "bookmark not found" error if db is scoped to a user
+// who doesn't own bookmark id, for queries on tables keyed by bookmark.
+func (db *DB) checkOwner(id int64) error {
+	if db.userID == 0 {
+		return nil
+	}
+	var owned bool
+	err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM bookmarks WHERE id = ? AND user_id = ?)`, id, db.userID).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("failed to check bookmark owner: %w", err)
+	}
+	if !owned {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
 }
 
 func NewSQLiteDB(path string) (*DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open(sqliteDriver, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	return &DB{
-		db:             db,
+	d := &DB{
+		db:             &conn{DB: db, ctx: context.Background()},
 		eventListeners: make(map[EventKind][]EventListener),
-	}, nil
+		ranking:        DefaultSearchRanking(),
+	}
+	d.blobs = sqliteBlobStore{db: d}
+	return d, nil
 }
 
+// Migrate applies all pending migrations.
 func (db *DB) Migrate() error {
+	return db.migrate("")
+}
+
+// migrate applies pending migrations in order, stopping after version
+// through if it is non-empty. Tests use it to build databases with an older
+// schema and exercise data migrations.
+func (db *DB) migrate(through string) error {
 	// Create migrations tracking table if it doesn't exist
 	_, err := db.db.Exec(`
 		CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -97,6 +224,15 @@ func (db *DB) Migrate() error {
 			return fmt.Errorf("failed to apply migration %s: %w", version, err)
 		}
 
+		if fn, ok := dataMigrations[version]; ok {
+			if err := fn(tx); err != nil {
+				if rbErr := tx.Rollback(); rbErr != nil {
+					log.Printf("failed to rollback transaction: %v", rbErr)
+				}
+				return fmt.Errorf("failed to apply data migration %s: %w", version, err)
+			}
+		}
+
 		// Mark migration as applied
 		if _, err := tx.Exec(`
 		    INSERT INTO schema_migrations (version) VALUES (?)
@@ -112,11 +248,25 @@ func (db *DB) Migrate() error {
 		}
 
 		log.Printf("Migration %s applied successfully", version)
+
+		if version == through {
+			break
+		}
 	}
 
 	return nil
 }
 
+// SchemaVersion returns the newest migration applied to the database, such
+// as "0039-archive-audio", or "" before any has been.
+func (db *DB) SchemaVersion() (string, error) {
+	var version sql.NullString
+	if err := db.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version.String, nil
+}
+
 func (db *DB) Close() error {
 	return db.db.Close()
 }
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -97,6 +100,18 @@ func TestMigrate(t *testing.T) {
 		if count == 0 {
 			t.Error("expected migrations to be recorded")
 		}
+
+		version, err := db.SchemaVersion()
+		if err != nil {
+			t.Fatalf("SchemaVersion() error = %v", err)
+		}
+		entries, err := migrationsFS.ReadDir("migrations")
+		if err != nil {
+			t.Fatalf("failed to list migrations: %v", err)
+		}
+		if latest := strings.TrimSuffix(entries[len(entries)-1].Name(), ".sql"); version != latest {
+			t.Errorf("expected schema version %q, got %q", latest, version)
+		}
 	})
 
 	t.Run("migrations are idempotent", func(t *testing.T) {
@@ -144,3 +159,37 @@ func TestClose(t *testing.T) {
 		t.Error("expected error after close, got nil")
 	}
 }
+
+// TestWithContext tests that a handle's queries are canceled with its
+// context, without affecting the handle it came from.
+func TestWithContext(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scoped := db.ForUser(LocalUserID).WithContext(ctx)
+	if _, err := scoped.GetBookmark(id); err != nil {
+		t.Fatalf("expected the bookmark before cancel, got %v", err)
+	}
+	cancel()
+	if _, err := scoped.GetBookmark(id); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled after cancel, got %v", err)
+	}
+	if _, err := scoped.CreateBookmark(NewBookmark{URL: "https://example.org"}); err == nil {
+		t.Error("expected writes to fail after cancel")
+	}
+	if scoped.UserID() != LocalUserID {
+		t.Errorf("expected the user scope kept, got %d", scoped.UserID())
+	}
+	if _, err := db.GetBookmark(id); err != nil {
+		t.Errorf("expected the original handle unaffected, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// ListDomains lists the domains (see JobHost) of the bookmarks passing
+// filter, with how many bookmarks each has and when the latest was added,
+// in the order domainOrder gives for filter.Sort. Bookmarks whose URL has
+// no host are counted under "".
+func (db *DB) ListDomains(filter BookmarkFilter) ([]DomainCount, error) {
+	where, args := filter.where("")
+	rows, err := db.db.Query(`
+		SELECT url_host(url) AS domain, COUNT(*), MAX(created_at)
+		FROM bookmarks
+		WHERE `+where+`
+		  AND `+ownerFilter("user_id")+`
+		GROUP BY domain
+		ORDER BY `+domainOrder(filter.Sort)+`
+	`, append(args, db.owner()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	domains := []DomainCount{}
+	for rows.Next() {
+		var d DomainCount
+		if err := rows.Scan(&d.Domain, &d.Count, &d.LastAddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating domains: %w", err)
+	}
+	return domains, nil
+}
+
+// domainOrder is the ORDER BY clause for domains grouped from bookmarks:
+// the most bookmarks first and ties by name, or with sort SortDomain by
+// name and with SortCreated by the newest bookmark, most recent first.
+func domainOrder(sort string) string {
+	switch sort {
+	case SortDomain:
+		return "domain"
+	case SortCreated:
+		return "MAX(created_at) DESC, domain"
+	}
+	return "COUNT(*) DESC, domain"
+}
+
+// ListDomainGroups counts the bookmarks passing filter per domain (see
+// JobHost), with how many are unread and archived, in the order
+// domainOrder gives for filter.Sort. Bookmarks whose URL has no host are
+// grouped under "".
+func (db *DB) ListDomainGroups(filter BookmarkFilter) ([]DomainGroup, error) {
+	where, args := filter.where("")
+	rows, err := db.db.Query(`
+		SELECT url_host(url) AS domain,
+		       COUNT(*),
+		       SUM(is_read = 0),
+		       SUM(archived_at IS NOT NULL),
+		       MAX(created_at)
+		FROM bookmarks
+		WHERE `+where+`
+		  AND `+ownerFilter("user_id")+`
+		GROUP BY domain
+		ORDER BY `+domainOrder(filter.Sort)+`
+	`, append(args, db.owner()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to group bookmarks by domain: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	groups := []DomainGroup{}
+	for rows.Next() {
+		var g DomainGroup
+		if err := rows.Scan(&g.Domain, &g.Count, &g.Unread, &g.Archived, &g.LatestAt); err != nil {
+			return nil, fmt.Errorf("failed to scan domain group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating domain groups: %w", err)
+	}
+	return groups, nil
+}
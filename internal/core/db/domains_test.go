@@ -0,0 +1,138 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListDomainGroups(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	var ids []int64
+	for _, u := range []string{"https://go.dev/doc", "https://www.Go.dev/blog", "https://sqlite.org/", "https://news.example.com/1"} {
+		id, err := db.AddBookmark(u, u)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := db.MarkRead(ids[0], true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+
+	groups, err := db.ListDomainGroups(BookmarkFilter{})
+	if err != nil {
+		t.Fatalf("ListDomainGroups() error = %v", err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 domains, got %+v", groups)
+	}
+	if g := groups[0]; g.Domain != "go.dev" || g.Count != 2 || g.Unread != 1 || g.Archived != 0 || g.LatestAt == "" {
+		t.Errorf("expected go.dev with www. folded in first, got %+v", g)
+	}
+	if groups[1].Domain != "news.example.com" || groups[2].Domain != "sqlite.org" {
+		t.Errorf("expected ties ordered by name, got %+v", groups)
+	}
+
+	groups, err = db.ListDomainGroups(BookmarkFilter{UnreadOnly: true})
+	if err != nil {
+		t.Fatalf("ListDomainGroups() error = %v", err)
+	}
+	if len(groups) != 3 || groups[0].Count != 1 {
+		t.Errorf("expected the read bookmark to be left out, got %+v", groups)
+	}
+
+	if _, err := db.CreateBookmark(NewBookmark{URL: "https://sqlite.org/news", CreatedAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	for _, tt := range []struct {
+		sort string
+		want []string
+	}{
+		{SortDomain, []string{"go.dev", "news.example.com", "sqlite.org"}},
+		{SortCreated, []string{"sqlite.org", "go.dev", "news.example.com"}},
+	} {
+		groups, err := db.ListDomainGroups(BookmarkFilter{Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("ListDomainGroups() error = %v", err)
+		}
+		var got []string
+		for _, g := range groups {
+			got = append(got, g.Domain)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sort %q: expected %v, got %v", tt.sort, tt.want, got)
+		}
+	}
+
+	other := db.ForUser(42)
+	if groups, err := other.ListDomainGroups(BookmarkFilter{}); err != nil || len(groups) != 0 {
+		t.Errorf("expected another user to see no domains, got %+v, %v", groups, err)
+	}
+}
+
+func TestListDomains(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, nb := range []NewBookmark{
+		{URL: "https://go.dev/doc", IsRead: true},
+		{URL: "https://www.go.dev/blog"},
+		{URL: "https://sqlite.org/"},
+		{URL: "https://news.example.com/1"},
+	} {
+		nb.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		if _, err := db.CreateBookmark(nb); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+	}
+
+	domains, err := db.ListDomains(BookmarkFilter{})
+	if err != nil {
+		t.Fatalf("ListDomains() error = %v", err)
+	}
+	want := []DomainCount{
+		{Domain: "go.dev", Count: 2, LastAddedAt: base.Add(time.Hour).Format(time.RFC3339)},
+		{Domain: "news.example.com", Count: 1, LastAddedAt: base.Add(3 * time.Hour).Format(time.RFC3339)},
+		{Domain: "sqlite.org", Count: 1, LastAddedAt: base.Add(2 * time.Hour).Format(time.RFC3339)},
+	}
+	if !slices.Equal(domains, want) {
+		t.Errorf("expected %+v, got %+v", want, domains)
+	}
+
+	for _, tt := range []struct {
+		filter BookmarkFilter
+		want   []string
+	}{
+		{BookmarkFilter{Sort: SortDomain}, []string{"go.dev", "news.example.com", "sqlite.org"}},
+		{BookmarkFilter{Sort: SortCreated}, []string{"news.example.com", "sqlite.org", "go.dev"}},
+		{BookmarkFilter{ReadOnly: true}, []string{"go.dev"}},
+	} {
+		domains, err := db.ListDomains(tt.filter)
+		if err != nil {
+			t.Fatalf("ListDomains() error = %v", err)
+		}
+		var got []string
+		for _, d := range domains {
+			got = append(got, d.Domain)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: expected %v, got %v", tt.filter, tt.want, got)
+		}
+	}
+
+	if domains, err := db.ForUser(42).ListDomains(BookmarkFilter{}); err != nil || len(domains) != 0 {
+		t.Errorf("expected another user to see no domains, got %+v, %v", domains, err)
+	}
+}
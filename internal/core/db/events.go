@@ -43,6 +43,11 @@ const (
 	OnArchiveResultSavedEvent
 	// OnArchiveClearedEvent is emitted when an archive is cleared for re-archiving.
 	OnArchiveClearedEvent
+	// OnImportFinishedEvent is emitted when an import from another tool finishes.
+	OnImportFinishedEvent
+	// OnArchiveVisualChangeEvent is emitted when a new archive's screenshot
+	// looks different from the previous one.
+	OnArchiveVisualChangeEvent
 )
 
 func (k EventKind) String() string {
@@ -57,14 +62,48 @@ func (k EventKind) String() string {
 		return "archive_result_saved"
 	case OnArchiveClearedEvent:
 		return "archive_cleared"
+	case OnImportFinishedEvent:
+		return "import_finished"
+	case OnArchiveVisualChangeEvent:
+		return "archive_visual_change"
 	default:
 		return "unknown"
 	}
 }
 
+// EventKinds lists every event kind, in declaration order.
+var EventKinds = []EventKind{
+	OnBookmarkCreatedEvent,
+	OnBookmarkDeletedEvent,
+	OnBookmarkUpdatedEvent,
+	OnArchiveResultSavedEvent,
+	OnArchiveClearedEvent,
+	OnImportFinishedEvent,
+	OnArchiveVisualChangeEvent,
+}
+
+// ParseEventKind returns the event kind whose String is name.
+func ParseEventKind(name string) (EventKind, bool) {
+	for _, k := range EventKinds {
+		if k.String() == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
 // BookmarkCreatedEvent is emitted after a new bookmark is successfully inserted.
 type BookmarkCreatedEvent struct {
 	Bookmark Bookmark
+	// SkipArchive is set when the bookmark should not be archived
+	// automatically, e.g. because a routing rule said so.
+	SkipArchive bool
+	// SkipArchiveToday is set when the bookmark should not be submitted to
+	// archive.today.
+	SkipArchiveToday bool
+	// Archive overrides how the bookmark is first archived, e.g. from a
+	// preset.
+	Archive ArchiveOverrides
 }
 
 func (e BookmarkCreatedEvent) Kind() EventKind { return OnBookmarkCreatedEvent }
@@ -80,6 +119,11 @@ func (e BookmarkUpdatedEvent) Kind() EventKind { return OnBookmarkUpdatedEvent }
 // The Bookmark field contains the state before deletion (if available).
 type BookmarkDeletedEvent struct {
 	Bookmark Bookmark
+	// UserID is the bookmark's owner, or 0 if it couldn't be fetched.
+	UserID int64
+	// WithUserData is set when the bookmark went with the rest of its
+	// owner's data (see DeleteUserData); the activity log doesn't record it.
+	WithUserData bool
 }
 
 func (e BookmarkDeletedEvent) Kind() EventKind { return OnBookmarkDeletedEvent }
@@ -87,7 +131,12 @@ func (e BookmarkDeletedEvent) Kind() EventKind { return OnBookmarkDeletedEvent }
 // ArchiveResultSavedEvent is emitted after an archive result is saved.
 type ArchiveResultSavedEvent struct {
 	BookmarkID int64
-	Status     string // "ok" or "error"
+	Status     string // "ok", "error" or "skipped"
+	// Error is the archive error message when Status is "error", or why
+	// the site was skipped when it is "skipped".
+	Error string
+	// ErrorCode classifies Error; see core.ArchiveErrorCodes.
+	ErrorCode string
 }
 
 func (e ArchiveResultSavedEvent) Kind() EventKind { return OnArchiveResultSavedEvent }
@@ -99,6 +148,60 @@ type ArchiveClearedEvent struct {
 
 func (e ArchiveClearedEvent) Kind() EventKind { return OnArchiveClearedEvent }
 
+// ImportFinishedEvent is emitted by EmitImportFinished once an import from
+// another tool has saved its bookmarks.
+type ImportFinishedEvent struct {
+	// Source names the tool the export came from.
+	Source string
+	Added  int
+	// Skipped counts duplicate and already-saved URLs; Invalid counts
+	// entries that couldn't be imported.
+	Skipped int
+	Invalid int
+	// UserID is who imported; EmitImportFinished fills it in from the
+	// handle if it's 0.
+	UserID int64
+}
+
+func (e ImportFinishedEvent) Kind() EventKind { return OnImportFinishedEvent }
+
+// EmitImportFinished emits an ImportFinishedEvent. Imports run outside the
+// DB (see core.ImportBookmarks), so they announce their end with this.
+func (db *DB) EmitImportFinished(ev ImportFinishedEvent) {
+	if ev.UserID == 0 {
+		ev.UserID = db.actingUserID()
+	}
+	db.emit(ev)
+}
+
+// ArchiveVisualChangeEvent is emitted by EmitArchiveVisualChange when the
+// screenshot of a bookmark's newest archive version looks different from
+// the previous version's.
+type ArchiveVisualChangeEvent struct {
+	BookmarkID        int64
+	VersionID         int64
+	PreviousVersionID int64
+	// Regions is how many regions of the screenshots were compared, and
+	// ChangedRegions how many of them differ. Distance is the largest
+	// perceptual-hash distance between two regions (0-64).
+	Regions        int
+	ChangedRegions int
+	Distance       int
+	// Before and After are JPEGs of the first changed region of each
+	// screenshot, scaled down; either is nil if that screenshot doesn't
+	// reach the region.
+	Before []byte
+	After  []byte
+}
+
+func (e ArchiveVisualChangeEvent) Kind() EventKind { return OnArchiveVisualChangeEvent }
+
+// EmitArchiveVisualChange emits an ArchiveVisualChangeEvent. Screenshots
+// are compared outside the DB (see core.DetectVisualChange).
+func (db *DB) EmitArchiveVisualChange(ev ArchiveVisualChangeEvent) {
+	db.emit(ev)
+}
+
 // EventListener is a callback that handles events of a specific kind.
 type EventListener func(event Event) error
 
@@ -17,6 +17,8 @@ func TestEventKindString(t *testing.T) {
 		{OnBookmarkUpdatedEvent, "bookmark_updated"},
 		{OnArchiveResultSavedEvent, "archive_result_saved"},
 		{OnArchiveClearedEvent, "archive_cleared"},
+		{OnImportFinishedEvent, "import_finished"},
+		{OnArchiveVisualChangeEvent, "archive_visual_change"},
 		{EventKind(999), "unknown"},
 	}
 
@@ -29,6 +31,20 @@ func TestEventKindString(t *testing.T) {
 	}
 }
 
+// TestParseEventKind tests that every event kind round-trips through its
+// name.
+func TestParseEventKind(t *testing.T) {
+	for _, kind := range EventKinds {
+		got, ok := ParseEventKind(kind.String())
+		if !ok || got != kind {
+			t.Errorf("expected %v to parse, got %v, %v", kind, got, ok)
+		}
+	}
+	if _, ok := ParseEventKind("unknown"); ok {
+		t.Error("expected unknown not to parse")
+	}
+}
+
 // TestEventTypes tests that event types return correct Kind.
 func TestEventTypes(t *testing.T) {
 	t.Run("BookmarkCreatedEvent", func(t *testing.T) {
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveBookmarkFavicon stores a bookmark's favicon, replacing any previous one.
+func (db *DB) SaveBookmarkFavicon(f BookmarkFavicon) error {
+	fetchedAt := f.FetchedAt
+	if fetchedAt == "" {
+		fetchedAt = time.Now().Format(time.RFC3339)
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO bookmark_favicons (bookmark_id, source_url, content_type, data, fetched_at)
+		SELECT ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id")+`)
+		ON CONFLICT (bookmark_id) DO UPDATE SET
+			source_url = excluded.source_url,
+			content_type = excluded.content_type,
+			data = excluded.data,
+			fetched_at = excluded.fetched_at
+	`, append([]any{f.BookmarkID, f.SourceURL, f.ContentType, f.Data, fetchedAt, f.BookmarkID}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to save bookmark favicon: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", f.BookmarkID)
+	}
+	return nil
+}
+
+// GetBookmarkFavicon returns the stored favicon for a bookmark.
+func (db *DB) GetBookmarkFavicon(id int64) (BookmarkFavicon, error) {
+	if err := db.checkOwner(id); err != nil {
+		return BookmarkFavicon{}, err
+	}
+	var f BookmarkFavicon
+	err := db.db.QueryRow(`
+		SELECT bookmark_id, source_url, content_type, data, fetched_at
+		FROM bookmark_favicons
+		WHERE bookmark_id = ?
+	`, id).Scan(&f.BookmarkID, &f.SourceURL, &f.ContentType, &f.Data, &f.FetchedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BookmarkFavicon{}, fmt.Errorf("no favicon for bookmark: %d", id)
+		}
+		return BookmarkFavicon{}, fmt.Errorf("failed to get bookmark favicon: %w", err)
+	}
+	return f, nil
+}
+
+// HasBookmarkFavicon reports whether a favicon is stored for a bookmark.
+func (db *DB) HasBookmarkFavicon(id int64) (bool, error) {
+	var n int
+	if err := db.db.QueryRow(`
+		SELECT COUNT(*) FROM bookmark_favicons f
+		JOIN bookmarks b ON b.id = f.bookmark_id
+		WHERE f.bookmark_id = ? AND `+ownerFilter("b.user_id"), append([]any{id}, db.owner()...)...).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to check bookmark favicon: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestBookmarkFavicons tests storing, replacing and deleting favicons.
+func TestBookmarkFavicons(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("none before first save", func(t *testing.T) {
+		if ok, err := db.HasBookmarkFavicon(id); err != nil || ok {
+			t.Errorf("expected no favicon, got %v, %v", ok, err)
+		}
+		if _, err := db.GetBookmarkFavicon(id); err == nil {
+			t.Error("expected error for missing favicon")
+		}
+	})
+
+	t.Run("save and replace", func(t *testing.T) {
+		if err := db.SaveBookmarkFavicon(BookmarkFavicon{BookmarkID: id, SourceURL: "https://example.com/favicon.ico", ContentType: "image/x-icon", Data: []byte("old")}); err != nil {
+			t.Fatalf("failed to save favicon: %v", err)
+		}
+		if err := db.SaveBookmarkFavicon(BookmarkFavicon{BookmarkID: id, SourceURL: "https://example.com/icon.png", ContentType: "image/png", Data: []byte("new")}); err != nil {
+			t.Fatalf("failed to replace favicon: %v", err)
+		}
+		f, err := db.GetBookmarkFavicon(id)
+		if err != nil {
+			t.Fatalf("failed to get favicon: %v", err)
+		}
+		if f.SourceURL != "https://example.com/icon.png" || f.ContentType != "image/png" || !bytes.Equal(f.Data, []byte("new")) || f.FetchedAt == "" {
+			t.Errorf("unexpected favicon: %+v", f)
+		}
+		if ok, err := db.HasBookmarkFavicon(id); err != nil || !ok {
+			t.Errorf("expected favicon, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("missing bookmark", func(t *testing.T) {
+		if err := db.SaveBookmarkFavicon(BookmarkFavicon{BookmarkID: 99999, SourceURL: "x", ContentType: "image/png", Data: []byte("x")}); err == nil {
+			t.Error("expected error for missing bookmark")
+		}
+	})
+
+	t.Run("deleted with bookmark", func(t *testing.T) {
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if ok, _ := db.HasBookmarkFavicon(id); ok {
+			t.Error("expected favicon to be deleted with its bookmark")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// GraphBookmark is what the bookmark graph needs about one bookmark: its
+// tags, and the keys of the outbound links in its latest archive (see
+// BookmarkLink), which connect it to other bookmarks.
+type GraphBookmark struct {
+	ID       int64
+	URL      string
+	Title    string
+	Tags     []string
+	LinkKeys []string
+}
+
+// ListGraphBookmarks returns every bookmark the handle sees, oldest first,
+// with its tags and link keys.
+func (db *DB) ListGraphBookmarks() ([]GraphBookmark, error) {
+	rows, err := db.db.Query(`
+		SELECT b.id, b.url, COALESCE(b.title, '')
+		FROM bookmarks b
+		WHERE `+ownerFilter("b.user_id")+`
+		ORDER BY b.id
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmarks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	out := []GraphBookmark{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var b GraphBookmark
+		if err := rows.Scan(&b.ID, &b.URL, &b.Title); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark: %w", err)
+		}
+		index[b.ID] = len(out)
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmarks: %w", err)
+	}
+
+	if err := db.collectGraphValues(`
+		SELECT bt.bookmark_id, t.name
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		JOIN bookmarks b ON b.id = bt.bookmark_id
+		WHERE `+ownerFilter("b.user_id")+`
+		ORDER BY t.name
+	`, "bookmark tags", func(id int64, name string) {
+		if i, ok := index[id]; ok {
+			out[i].Tags = append(out[i].Tags, name)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if err := db.collectGraphValues(`
+		SELECT l.bookmark_id, l.url_key
+		FROM bookmark_links l
+		JOIN bookmarks b ON b.id = l.bookmark_id
+		WHERE `+ownerFilter("b.user_id")+`
+		ORDER BY l.url_key
+	`, "bookmark links", func(id int64, key string) {
+		if i, ok := index[id]; ok {
+			out[i].LinkKeys = append(out[i].LinkKeys, key)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// collectGraphValues runs an owner-filtered query returning (bookmark_id,
+// value) rows and passes each to add. what names the rows in errors.
+func (db *DB) collectGraphValues(query, what string, add func(id int64, value string)) error {
+	rows, err := db.db.Query(query, db.owner()...)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", what, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			return fmt.Errorf("failed to scan %s: %w", what, err)
+		}
+		add(id, value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate %s: %w", what, err)
+	}
+	return nil
+}
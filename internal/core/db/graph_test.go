@@ -0,0 +1,50 @@
+package db
+
+import (
+	"testing"
+)
+
+// TestListGraphBookmarks tests loading bookmarks for the graph.
+func TestListGraphBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	tagged, _ := db.CreateBookmark(NewBookmark{URL: "https://a.example.com", Title: "A", Tags: []string{"go", "db"}})
+	archived, _ := db.CreateBookmark(NewBookmark{URL: "https://b.example.com"})
+	if err := db.SaveBookmarkLinks(archived, []BookmarkLink{
+		{URL: "https://a.example.com", Key: "a.example.com", Host: "a.example.com"},
+		{URL: "https://elsewhere.org/", Key: "elsewhere.org", Host: "elsewhere.org"},
+	}); err != nil {
+		t.Fatalf("failed to save links: %v", err)
+	}
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := db.ForUser(bob.ID).CreateBookmark(NewBookmark{URL: "https://bob.example.com", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	got, err := db.ForUser(LocalUserID).ListGraphBookmarks()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 2 || got[0].ID != tagged || got[1].ID != archived {
+		t.Fatalf("expected the local user's 2 bookmarks, got %+v", got)
+	}
+	if len(got[0].Tags) != 2 || got[0].Tags[0] != "db" || got[0].Tags[1] != "go" || got[0].LinkKeys != nil {
+		t.Errorf("unexpected tagged bookmark %+v", got[0])
+	}
+	if got[1].Title != "" || got[1].Tags != nil || len(got[1].LinkKeys) != 2 || got[1].LinkKeys[0] != "a.example.com" {
+		t.Errorf("unexpected archived bookmark %+v", got[1])
+	}
+
+	all, err := db.ListGraphBookmarks()
+	if err != nil || len(all) != 3 {
+		t.Errorf("expected every user's 3 bookmarks unscoped, got %d, %v", len(all), err)
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetImportCheckpoint returns the checkpoint of the import with key, and
+// false if there is none.
+func (db *DB) GetImportCheckpoint(key string) (ImportCheckpoint, bool, error) {
+	c := ImportCheckpoint{Key: key}
+	err := db.db.QueryRow(`
+		SELECT source, position, total, result, updated_at
+		FROM import_checkpoints
+		WHERE user_id = ? AND import_key = ?
+	`, db.actingUserID(), key).Scan(&c.Source, &c.Position, &c.Total, &c.Result, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ImportCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return ImportCheckpoint{}, false, fmt.Errorf("failed to get import checkpoint: %w", err)
+	}
+	return c, true, nil
+}
+
+// SaveImportCheckpoint records c, replacing the import's previous
+// checkpoint.
+func (db *DB) SaveImportCheckpoint(c ImportCheckpoint) error {
+	if _, err := db.db.Exec(`
+		INSERT INTO import_checkpoints (user_id, import_key, source, position, total, result, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, import_key) DO UPDATE SET
+			source = excluded.source,
+			position = excluded.position,
+			total = excluded.total,
+			result = excluded.result,
+			updated_at = excluded.updated_at
+	`, db.actingUserID(), c.Key, c.Source, c.Position, c.Total, c.Result, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save import checkpoint: %w", err)
+	}
+	return nil
+}
+
+// DeleteImportCheckpoint removes the checkpoint of the import with key, if
+// there is one.
+func (db *DB) DeleteImportCheckpoint(key string) error {
+	if _, err := db.db.Exec(`DELETE FROM import_checkpoints WHERE user_id = ? AND import_key = ?`, db.actingUserID(), key); err != nil {
+		return fmt.Errorf("failed to delete import checkpoint: %w", err)
+	}
+	return nil
+}
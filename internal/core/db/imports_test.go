@@ -0,0 +1,47 @@
+package db
+
+import "testing"
+
+func TestImportCheckpoints(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	if _, ok, err := db.GetImportCheckpoint("abc"); err != nil || ok {
+		t.Fatalf("expected no checkpoint, got ok=%v err=%v", ok, err)
+	}
+
+	c := ImportCheckpoint{Key: "abc", Source: "Pocket", Position: 200, Total: 1000, Result: `{"added":[1]}`}
+	if err := db.SaveImportCheckpoint(c); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+	c.Position = 400
+	if err := db.SaveImportCheckpoint(c); err != nil {
+		t.Fatalf("failed to update checkpoint: %v", err)
+	}
+	got, ok, err := db.GetImportCheckpoint("abc")
+	if err != nil || !ok {
+		t.Fatalf("expected a checkpoint, got ok=%v err=%v", ok, err)
+	}
+	if got.Source != "Pocket" || got.Position != 400 || got.Total != 1000 || got.Result != c.Result || got.UpdatedAt == "" {
+		t.Errorf("unexpected checkpoint %+v", got)
+	}
+
+	user, err := db.CreateUser("importer", "correct horse battery", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, ok, err := db.ForUser(user.ID).GetImportCheckpoint("abc"); err != nil || ok {
+		t.Errorf("expected another user not to see the checkpoint, got ok=%v err=%v", ok, err)
+	}
+
+	if err := db.DeleteImportCheckpoint("abc"); err != nil {
+		t.Fatalf("failed to delete checkpoint: %v", err)
+	}
+	if _, ok, err := db.GetImportCheckpoint("abc"); err != nil || ok {
+		t.Errorf("expected the checkpoint to be gone, got ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,364 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Job kinds.
+const (
+	JobKindArchive = "archive"
+)
+
+// Job status values.
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+	// JobStatusDeferred jobs were accepted while the queue was saturated;
+	// they aren't claimed until ResumeDeferredJobs queues them.
+	JobStatusDeferred = "deferred"
+)
+
+// ErrNoJobReady is returned by ClaimJob when no queued job is due.
+var ErrNoJobReady = errors.New("no job ready")
+
+// ErrNoPendingJob is returned by GetPendingJob when a bookmark has no job
+// waiting or running.
+var ErrNoPendingJob = errors.New("no pending job")
+
+const jobColumns = `id, kind, bookmark_id, status, attempts, next_attempt_at, COALESCE(last_error, ''), COALESCE(options, ''), created_at, updated_at`
+
+// jobTime formats t for the jobs table. Job timestamps are UTC so that
+// next_attempt_at compares correctly as text.
+func jobTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func scanJob(row interface{ Scan(...any) error }) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.BookmarkID, &j.Status, &j.Attempts, &j.NextAttemptAt, &j.LastError, &j.Options, &j.CreatedAt, &j.UpdatedAt)
+	return j, err
+}
+
+// EnqueueJob queues a job of the given kind for a bookmark, due immediately.
+// options is stored with the job for its runner and may be empty. It reports
+// false without error if the bookmark already has a queued, running or
+// deferred job of that kind.
+func (db *DB) EnqueueJob(kind string, bookmarkID int64, options string) (bool, error) {
+	return db.insertJob(kind, bookmarkID, options, JobStatusQueued)
+}
+
+// DeferJob is EnqueueJob for a saturated queue: the job is recorded, but
+// waits for ResumeDeferredJobs before it can be claimed.
+func (db *DB) DeferJob(kind string, bookmarkID int64, options string) (bool, error) {
+	return db.insertJob(kind, bookmarkID, options, JobStatusDeferred)
+}
+
+func (db *DB) insertJob(kind string, bookmarkID int64, options, status string) (bool, error) {
+	now := jobTime(time.Now())
+	res, err := db.db.Exec(`
+		INSERT OR IGNORE INTO jobs (kind, bookmark_id, status, attempts, next_attempt_at, options, created_at, updated_at)
+		VALUES (?, ?, ?, 0, ?, NULLIF(?, ''), ?, ?)
+	`, kind, bookmarkID, status, now, options, now, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// EnqueueUnarchivedBookmarks queues archive jobs for bookmarks that have never
+// been archived and have no pending or failed archive job, e.g. bookmarks
+// created before the jobs table existed. Bookmarks created with SkipArchive,
+// and those skipped because their site asked not to be archived, are left
+// alone. It returns the number queued.
+func (db *DB) EnqueueUnarchivedBookmarks() (int64, error) {
+	now := jobTime(time.Now())
+	res, err := db.db.Exec(`
+		INSERT OR IGNORE INTO jobs (kind, bookmark_id, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT ?, b.id, ?, 0, ?, ?, ?
+		FROM bookmarks b
+		WHERE b.archived_at IS NULL
+		  AND b.skip_archive = 0
+		  AND COALESCE(b.archive_status, '') != 'skipped'
+		  AND NOT EXISTS (
+			SELECT 1 FROM jobs j
+			WHERE j.bookmark_id = b.id AND j.kind = ? AND j.status != ?
+		  )
+		  AND `+ownerFilter("b.user_id")+`
+		ORDER BY b.created_at DESC
+	`, append([]any{JobKindArchive, JobStatusQueued, now, now, now, JobKindArchive, JobStatusDone}, db.owner()...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue unarchived bookmarks: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// EnqueueStaleArchives queues archive jobs for bookmarks whose latest
+// snapshot, and latest attempt, are older than olderThan, skipping bookmarks
+// opted out of re-archiving and those with a pending job. Jobs are queued
+// without options, so they use the owner's current settings. It returns the
+// number queued.
+func (db *DB) EnqueueStaleArchives(olderThan time.Time) (int64, error) {
+	now := jobTime(time.Now())
+	cutoff := olderThan.Format(time.RFC3339)
+	res, err := db.db.Exec(`
+		INSERT OR IGNORE INTO jobs (kind, bookmark_id, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT ?, b.id, ?, 0, ?, ?, ?
+		FROM bookmarks b
+		WHERE b.archived_at IS NOT NULL
+		  AND b.rearchive_disabled = 0
+		  AND julianday(b.archived_at) < julianday(?)
+		  AND julianday(COALESCE(b.archive_attempted_at, b.archived_at)) < julianday(?)
+		  AND `+ownerFilter("b.user_id")+`
+		ORDER BY julianday(b.archived_at)
+	`, append([]any{JobKindArchive, JobStatusQueued, now, now, now, cutoff, cutoff}, db.owner()...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue stale archives: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// CountQueuedJobs returns how many jobs of the given kind are queued and due
+// at now, i.e. waiting for a worker.
+func (db *DB) CountQueuedJobs(kind string, now time.Time) (int, error) {
+	var n int
+	err := db.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE kind = ? AND status = ? AND next_attempt_at <= ?`,
+		kind, JobStatusQueued, jobTime(now)).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s jobs: %w", kind, err)
+	}
+	return n, nil
+}
+
+// ResumeDeferredJobs queues up to limit deferred jobs of the given kind, due
+// immediately, oldest first. It returns the number queued.
+func (db *DB) ResumeDeferredJobs(kind string, limit int) (int64, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	now := jobTime(time.Now())
+	res, err := db.db.Exec(`
+		UPDATE jobs SET status = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM jobs WHERE kind = ? AND status = ? ORDER BY id LIMIT ?
+		)
+	`, JobStatusQueued, now, now, kind, JobStatusDeferred, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resume deferred %s jobs: %w", kind, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// GetPendingJob returns the bookmark's queued, running or deferred job of
+// the given kind, with how many jobs are waiting to run before it: queued
+// jobs due earlier for a queued job, and every queued job plus older
+// deferred ones for a deferred job. It returns ErrNoPendingJob if there is
+// none, or the bookmark isn't db's user's.
+func (db *DB) GetPendingJob(kind string, bookmarkID int64) (PendingJob, error) {
+	job, err := scanJob(db.db.QueryRow(`
+		SELECT `+jobColumns+` FROM jobs
+		WHERE kind = ? AND bookmark_id = ? AND status IN (?, ?, ?)
+		  AND bookmark_id IN (SELECT id FROM bookmarks WHERE `+ownerFilter("user_id")+`)`,
+		append([]any{kind, bookmarkID, JobStatusQueued, JobStatusRunning, JobStatusDeferred}, db.owner()...)...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PendingJob{}, ErrNoPendingJob
+		}
+		return PendingJob{}, fmt.Errorf("failed to get pending %s job: %w", kind, err)
+	}
+	p := PendingJob{Job: job}
+	switch job.Status {
+	case JobStatusQueued:
+		err = db.db.QueryRow(`
+			SELECT COUNT(*) FROM jobs
+			WHERE kind = ? AND status = ? AND (next_attempt_at < ? OR (next_attempt_at = ? AND id < ?))
+		`, kind, JobStatusQueued, job.NextAttemptAt, job.NextAttemptAt, job.ID).Scan(&p.Ahead)
+	case JobStatusDeferred:
+		err = db.db.QueryRow(`
+			SELECT COUNT(*) FROM jobs
+			WHERE kind = ? AND (status = ? OR (status = ? AND id < ?))
+		`, kind, JobStatusQueued, JobStatusDeferred, job.ID).Scan(&p.Ahead)
+	}
+	if err != nil {
+		return PendingJob{}, fmt.Errorf("failed to count jobs ahead: %w", err)
+	}
+	return p, nil
+}
+
+// JobHost returns the host a job for a bookmark with this URL fetches from,
+// lowercase and without "www.", for spreading jobs across hosts. It is
+// registered on bookmarkd's connections as the url_host SQL function.
+func JobHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// ClaimJob atomically marks the next due job of the given kind as running,
+// increments its attempt count and returns it. Jobs for bookmarks on
+// skipHosts (see JobHost) are passed over. It returns ErrNoJobReady if
+// nothing else is due at now.
+func (db *DB) ClaimJob(kind string, now time.Time, skipHosts ...string) (Job, error) {
+	ts := jobTime(now)
+	args := []any{JobStatusRunning, ts, kind, JobStatusQueued, ts}
+	skip := ""
+	if len(skipHosts) > 0 {
+		skip = `AND bookmark_id NOT IN (
+				SELECT id FROM bookmarks WHERE url_host(url) IN (?` + strings.Repeat(", ?", len(skipHosts)-1) + `)
+			)`
+		for _, h := range skipHosts {
+			args = append(args, h)
+		}
+	}
+	job, err := scanJob(db.db.QueryRow(`
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ? AND status = ? AND next_attempt_at <= ?
+			`+skip+`
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, ErrNoJobReady
+		}
+		return Job{}, fmt.Errorf("failed to claim %s job: %w", kind, err)
+	}
+	return job, nil
+}
+
+// CompleteJob marks a running job as done.
+func (db *DB) CompleteJob(id int64) error {
+	return db.finishJob(id, JobStatusDone, "", time.Time{})
+}
+
+// RetryJob puts a failed job back in the queue, due at nextAttemptAt.
+func (db *DB) RetryJob(id int64, lastError string, nextAttemptAt time.Time) error {
+	return db.finishJob(id, JobStatusQueued, lastError, nextAttemptAt)
+}
+
+// FailJob marks a job as permanently failed; it will not be retried.
+func (db *DB) FailJob(id int64, lastError string) error {
+	return db.finishJob(id, JobStatusFailed, lastError, time.Time{})
+}
+
+// finishJob records the outcome of a run. next_attempt_at is left unchanged
+// when nextAttemptAt is zero.
+func (db *DB) finishJob(id int64, status, lastError string, nextAttemptAt time.Time) error {
+	var next any
+	if !nextAttemptAt.IsZero() {
+		next = jobTime(nextAttemptAt)
+	}
+	res, err := db.db.Exec(`
+		UPDATE jobs
+		SET
+			status = ?,
+			last_error = NULLIF(?, ''),
+			next_attempt_at = COALESCE(?, next_attempt_at),
+			updated_at = ?
+		WHERE id = ?
+	`, status, lastError, next, jobTime(time.Now()), id)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("job not found: %d", id)
+	}
+	return nil
+}
+
+// RequeueRunningJobs returns jobs left running by a previous process (e.g.
+// after a crash) to the queue, due immediately. Call it once at startup,
+// before any workers run.
+func (db *DB) RequeueRunningJobs() (int64, error) {
+	now := jobTime(time.Now())
+	res, err := db.db.Exec(`
+		UPDATE jobs SET status = ?, next_attempt_at = ?, updated_at = ?
+		WHERE status = ?
+	`, JobStatusQueued, now, now, JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue running jobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// GetJob returns a single job by ID.
+func (db *DB) GetJob(id int64) (Job, error) {
+	job, err := scanJob(db.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, fmt.Errorf("job not found: %d", id)
+		}
+		return Job{}, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns jobs with the given status (all jobs if status is empty),
+// most recently updated first.
+func (db *DB) ListJobs(status string, limit int) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE (? = '' OR status = ?) ORDER BY updated_at DESC, id DESC`
+	args := []any{status, status}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate jobs: %w", err)
+	}
+	return jobs, nil
+}
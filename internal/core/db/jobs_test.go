@@ -0,0 +1,415 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestJobQueue tests enqueueing, claiming and finishing jobs.
+func TestJobQueue(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("no job ready on empty queue", func(t *testing.T) {
+		if _, err := db.ClaimJob(JobKindArchive, time.Now()); !errors.Is(err, ErrNoJobReady) {
+			t.Errorf("expected ErrNoJobReady, got %v", err)
+		}
+	})
+
+	t.Run("enqueue is idempotent while pending", func(t *testing.T) {
+		queued, err := db.EnqueueJob(JobKindArchive, id, "")
+		if err != nil || !queued {
+			t.Fatalf("expected job to be queued, got queued=%v err=%v", queued, err)
+		}
+		queued, err = db.EnqueueJob(JobKindArchive, id, "")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if queued {
+			t.Error("expected duplicate enqueue to be ignored")
+		}
+	})
+
+	var job Job
+	t.Run("claim marks job running", func(t *testing.T) {
+		job, err = db.ClaimJob(JobKindArchive, time.Now())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if job.BookmarkID != id || job.Status != JobStatusRunning || job.Attempts != 1 {
+			t.Errorf("unexpected job: %+v", job)
+		}
+		if _, err := db.ClaimJob(JobKindArchive, time.Now()); !errors.Is(err, ErrNoJobReady) {
+			t.Errorf("expected running job not to be claimed twice, got %v", err)
+		}
+	})
+
+	t.Run("retry delays the next attempt", func(t *testing.T) {
+		next := time.Now().Add(time.Hour)
+		if err := db.RetryJob(job.ID, "timeout", next); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.ClaimJob(JobKindArchive, time.Now()); !errors.Is(err, ErrNoJobReady) {
+			t.Errorf("expected job not to be due yet, got %v", err)
+		}
+
+		job, err = db.ClaimJob(JobKindArchive, next.Add(time.Second))
+		if err != nil {
+			t.Fatalf("expected job to be due, got %v", err)
+		}
+		if job.Attempts != 2 || job.LastError != "timeout" {
+			t.Errorf("unexpected job: %+v", job)
+		}
+	})
+
+	t.Run("complete clears the error", func(t *testing.T) {
+		if err := db.CompleteJob(job.ID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.GetJob(job.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Status != JobStatusDone || got.LastError != "" {
+			t.Errorf("unexpected job: %+v", got)
+		}
+
+		// A finished job doesn't block new work for the same bookmark.
+		queued, err := db.EnqueueJob(JobKindArchive, id, "")
+		if err != nil || !queued {
+			t.Errorf("expected a new job to be queued, got queued=%v err=%v", queued, err)
+		}
+	})
+
+	t.Run("fail is permanent", func(t *testing.T) {
+		job, err := db.ClaimJob(JobKindArchive, time.Now())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.FailJob(job.ID, "gave up"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.ClaimJob(JobKindArchive, time.Now().Add(24*time.Hour)); !errors.Is(err, ErrNoJobReady) {
+			t.Errorf("expected failed job not to be claimed, got %v", err)
+		}
+		failed, err := db.ListJobs(JobStatusFailed, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(failed) != 1 || failed[0].ID != job.ID || failed[0].LastError != "gave up" {
+			t.Errorf("unexpected failed jobs: %+v", failed)
+		}
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		if err := db.CompleteJob(99999); err == nil {
+			t.Error("expected error for unknown job")
+		}
+		if _, err := db.GetJob(99999); err == nil {
+			t.Error("expected error for unknown job")
+		}
+	})
+}
+
+// TestClaimJobSkipHosts tests that jobs on busy hosts are passed over.
+func TestClaimJobSkipHosts(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	busy, _ := db.AddBookmark("https://www.Example.com/a", "A")
+	other, _ := db.AddBookmark("https://example.org/b", "B")
+	for _, id := range []int64{busy, other} {
+		if _, err := db.EnqueueJob(JobKindArchive, id, ""); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+
+	if got := JobHost("https://www.Example.com/a"); got != "example.com" {
+		t.Errorf("JobHost() = %q, want example.com", got)
+	}
+	job, err := db.ClaimJob(JobKindArchive, time.Now(), "example.com")
+	if err != nil {
+		t.Fatalf("ClaimJob() error = %v", err)
+	}
+	if job.BookmarkID != other {
+		t.Errorf("expected the job on example.com to be skipped, got bookmark %d", job.BookmarkID)
+	}
+	if _, err := db.ClaimJob(JobKindArchive, time.Now(), "example.com", "example.org"); !errors.Is(err, ErrNoJobReady) {
+		t.Errorf("expected ErrNoJobReady, got %v", err)
+	}
+	if job, err := db.ClaimJob(JobKindArchive, time.Now()); err != nil || job.BookmarkID != busy {
+		t.Errorf("expected the skipped job once its host is free, got %+v, %v", job, err)
+	}
+}
+
+// TestRequeueRunningJobs tests recovering jobs left running by a crash.
+func TestRequeueRunningJobs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, id, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	if _, err := db.ClaimJob(JobKindArchive, time.Now()); err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+
+	n, err := db.RequeueRunningJobs()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 job requeued, got %d", n)
+	}
+	job, err := db.ClaimJob(JobKindArchive, time.Now())
+	if err != nil {
+		t.Fatalf("expected requeued job to be claimable, got %v", err)
+	}
+	if job.Attempts != 2 {
+		t.Errorf("expected attempts to be preserved, got %d", job.Attempts)
+	}
+}
+
+// TestEnqueueUnarchivedBookmarks tests seeding the queue from existing bookmarks.
+func TestEnqueueUnarchivedBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	pending, err := db.AddBookmark("https://example.com/pending", "Pending")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	archived, err := db.AddBookmark("https://example.com/archived", "Archived")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := db.SaveArchiveResult(archived, now, &now, "ok", "", "https://example.com/archived", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	skipped, err := db.AddBookmark("https://example.com/skipped", "Skipped")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := db.SaveArchiveResult(skipped, now, nil, "skipped", "robots.txt disallows /", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	failed, err := db.AddBookmark("https://example.com/failed", "Failed")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, failed, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	job, err := db.ClaimJob(JobKindArchive, now)
+	if err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+	if err := db.FailJob(job.ID, "gave up"); err != nil {
+		t.Fatalf("failed to fail job: %v", err)
+	}
+
+	n, err := db.EnqueueUnarchivedBookmarks()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected only the pending bookmark to be queued, got %d", n)
+	}
+	job, err = db.ClaimJob(JobKindArchive, time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.BookmarkID != pending {
+		t.Errorf("expected job for bookmark %d, got %d", pending, job.BookmarkID)
+	}
+
+	// Running again doesn't duplicate work.
+	if n, err := db.EnqueueUnarchivedBookmarks(); err != nil || n != 0 {
+		t.Errorf("expected nothing queued, got n=%d err=%v", n, err)
+	}
+}
+
+// TestDeleteBookmarkRemovesJobs tests that deleting a bookmark drops its jobs.
+func TestDeleteBookmarkRemovesJobs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, id, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	if err := db.DeleteBookmark(id); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+	jobs, err := db.ListJobs("", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("expected no jobs, got %+v", jobs)
+	}
+}
+
+// TestEnqueueStaleArchives tests queuing re-archives of old snapshots.
+func TestEnqueueStaleArchives(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	old := time.Now().AddDate(0, 0, -100)
+	stale, _ := db.AddBookmark("https://example.com/stale", "Stale")
+	optedOut, _ := db.AddBookmark("https://example.com/opted-out", "Opted out")
+	fresh, _ := db.AddBookmark("https://example.com/fresh", "Fresh")
+	if _, err := db.AddBookmark("https://example.com/never", "Never archived"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	for _, id := range []int64{stale, optedOut} {
+		if err := db.SaveArchiveResult(id, old, &old, "ok", "", "https://example.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+	}
+	now := time.Now()
+	if err := db.SaveArchiveResult(fresh, now, &now, "ok", "", "https://example.com/fresh", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SetRearchiveDisabled(optedOut, true); err != nil {
+		t.Fatalf("failed to opt out: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -90)
+	n, err := db.EnqueueStaleArchives(cutoff)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected only the stale bookmark to be queued, got %d", n)
+	}
+	job, err := db.ClaimJob(JobKindArchive, time.Now())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.BookmarkID != stale || job.Options != "" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	// A failed re-archive waits for the next period instead of retrying at
+	// every check, and keeps the last good snapshot.
+	if err := db.SaveArchiveResult(stale, time.Now(), nil, "error", "timeout", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.FailJob(job.ID, "timeout"); err != nil {
+		t.Fatalf("failed to fail job: %v", err)
+	}
+	if n, err := db.EnqueueStaleArchives(cutoff); err != nil || n != 0 {
+		t.Errorf("expected nothing queued after a recent attempt, got n=%d err=%v", n, err)
+	}
+	a, err := db.GetBookmarkArchiveStatus(stale)
+	if err != nil {
+		t.Fatalf("failed to get archive status: %v", err)
+	}
+	if a.ArchivedAt == "" || a.ArchiveStatus != "error" {
+		t.Errorf("expected failure to keep the last snapshot, got %+v", a)
+	}
+}
+
+// TestDeferredJobs tests deferring jobs while the queue is full and resuming
+// them.
+func TestDeferredJobs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	var ids []int64
+	for _, u := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+		id, err := db.AddBookmark(u, u)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, ids[0], ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	for _, id := range ids[1:] {
+		if deferred, err := db.DeferJob(JobKindArchive, id, ""); err != nil || !deferred {
+			t.Fatalf("expected a deferred job, got %v, %v", deferred, err)
+		}
+	}
+	if queued, err := db.EnqueueJob(JobKindArchive, ids[1], ""); err != nil || queued {
+		t.Errorf("expected a deferred job to count as pending, got %v, %v", queued, err)
+	}
+
+	if n, err := db.CountQueuedJobs(JobKindArchive, time.Now()); err != nil || n != 1 {
+		t.Errorf("expected 1 queued job, got %d, %v", n, err)
+	}
+	p, err := db.GetPendingJob(JobKindArchive, ids[2])
+	if err != nil {
+		t.Fatalf("GetPendingJob() error = %v", err)
+	}
+	if p.Status != JobStatusDeferred || p.Ahead != 2 {
+		t.Errorf("expected a deferred job behind 2, got %+v", p)
+	}
+	if p, err := db.GetPendingJob(JobKindArchive, ids[0]); err != nil || p.Status != JobStatusQueued || p.Ahead != 0 {
+		t.Errorf("expected the queued job to be next, got %+v, %v", p, err)
+	}
+	if _, err := db.ForUser(LocalUserID+1).GetPendingJob(JobKindArchive, ids[0]); !errors.Is(err, ErrNoPendingJob) {
+		t.Errorf("expected another user not to see the job, got %v", err)
+	}
+
+	// Deferred jobs aren't claimed until they're resumed, oldest first.
+	if job, err := db.ClaimJob(JobKindArchive, time.Now()); err != nil || job.BookmarkID != ids[0] {
+		t.Fatalf("expected to claim the queued job, got %+v, %v", job, err)
+	}
+	if _, err := db.ClaimJob(JobKindArchive, time.Now()); !errors.Is(err, ErrNoJobReady) {
+		t.Fatalf("expected ErrNoJobReady, got %v", err)
+	}
+	if n, err := db.ResumeDeferredJobs(JobKindArchive, 1); err != nil || n != 1 {
+		t.Fatalf("expected 1 job resumed, got %d, %v", n, err)
+	}
+	if job, err := db.ClaimJob(JobKindArchive, time.Now()); err != nil || job.BookmarkID != ids[1] {
+		t.Errorf("expected to claim the oldest deferred job, got %+v, %v", job, err)
+	}
+	if n, err := db.ResumeDeferredJobs(JobKindArchive, 0); err != nil || n != 0 {
+		t.Errorf("expected nothing resumed without room, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Landing page widgets.
+const (
+	// LandingPinned lists the bookmarks and collections pinned to the page.
+	LandingPinned = "pinned"
+	// LandingRecent lists the newest bookmarks.
+	LandingRecent = "recent"
+	// LandingUnread lists the newest unread bookmarks.
+	LandingUnread = "unread"
+	// LandingStats counts the user's bookmarks (see BookmarkCounts).
+	LandingStats = "stats"
+)
+
+// LandingWidgets lists every landing page widget, in the default order.
+var LandingWidgets = []string{LandingPinned, LandingRecent, LandingUnread, LandingStats}
+
+// DefaultLandingListSize is how many bookmarks each list on the landing
+// page shows for users who haven't chosen; MaxLandingListSize caps it.
+const (
+	DefaultLandingListSize = 5
+	MaxLandingListSize     = 50
+)
+
+// ErrInvalidLandingLayout is returned when a landing layout names an unknown
+// widget, names one twice or has an out of range list size.
+var ErrInvalidLandingLayout = errors.New("invalid landing layout")
+
+// ValidateLandingLayout checks a landing layout, normalizing its widget
+// names in place. A zero ListSize becomes DefaultLandingListSize.
+func ValidateLandingLayout(l *LandingLayout) error {
+	widgets := make([]string, 0, len(l.Widgets))
+	for _, w := range l.Widgets {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if !slices.Contains(LandingWidgets, w) {
+			return fmt.Errorf("%w: unknown widget %q", ErrInvalidLandingLayout, w)
+		}
+		if slices.Contains(widgets, w) {
+			return fmt.Errorf("%w: widget %q is listed twice", ErrInvalidLandingLayout, w)
+		}
+		widgets = append(widgets, w)
+	}
+	l.Widgets = widgets
+	if l.ListSize == 0 {
+		l.ListSize = DefaultLandingListSize
+	}
+	if l.ListSize < 1 || l.ListSize > MaxLandingListSize {
+		return fmt.Errorf("%w: list size must be between 1 and %d", ErrInvalidLandingLayout, MaxLandingListSize)
+	}
+	return nil
+}
+
+// GetLandingLayout returns a user's landing layout. A user who has never
+// saved one gets every widget, in the default order.
+func (db *DB) GetLandingLayout(userID int64) (LandingLayout, error) {
+	l := LandingLayout{UserID: userID}
+	var widgets string
+	err := db.db.QueryRow(`SELECT widgets, list_size, updated_at FROM landing_layouts WHERE user_id = ?`, userID).
+		Scan(&widgets, &l.ListSize, &l.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			l.Widgets = slices.Clone(LandingWidgets)
+			l.ListSize = DefaultLandingListSize
+			return l, nil
+		}
+		return LandingLayout{}, fmt.Errorf("failed to get landing layout: %w", err)
+	}
+	l.Widgets = []string{}
+	for _, w := range strings.Split(widgets, ",") {
+		// Widgets from a newer version are dropped rather than failing.
+		if slices.Contains(LandingWidgets, w) {
+			l.Widgets = append(l.Widgets, w)
+		}
+	}
+	return l, nil
+}
+
+// SaveLandingLayout validates and stores a user's landing layout, replacing
+// any previous one. An empty Widgets leaves the page with no widgets.
+func (db *DB) SaveLandingLayout(l LandingLayout) error {
+	if err := ValidateLandingLayout(&l); err != nil {
+		return err
+	}
+	if _, err := db.db.Exec(`
+		INSERT INTO landing_layouts (user_id, widgets, list_size, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			widgets = excluded.widgets,
+			list_size = excluded.list_size,
+			updated_at = excluded.updated_at
+	`, l.UserID, strings.Join(l.Widgets, ","), l.ListSize, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save landing layout: %w", err)
+	}
+	return nil
+}
+
+// SetBookmarkPinned pins a bookmark to its owner's landing page, or unpins
+// it when pinned is false. Pinning a pinned bookmark keeps its place.
+func (db *DB) SetBookmarkPinned(id int64, pinned bool) error {
+	res, err := db.db.Exec(`
+		UPDATE bookmarks SET pinned_at = CASE WHEN ? THEN COALESCE(pinned_at, ?) END
+		WHERE id = ? AND `+ownerFilter("user_id"),
+		append([]any{pinned, time.Now().Format(time.RFC3339Nano), id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to pin bookmark: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
+
+// ListPinnedBookmarks returns the pinned bookmarks, in the order they were
+// pinned.
+func (db *DB) ListPinnedBookmarks(limit int) ([]Bookmark, error) {
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE pinned_at IS NOT NULL AND `+ownerFilter("user_id")+`
+		ORDER BY pinned_at, id
+	`, db.owner(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pinned bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// PinCollection pins one of the handle's user's collections to their
+// landing page. Pinning a pinned collection keeps its place, and the
+// collection needn't have bookmarks yet.
+func (db *DB) PinCollection(collection string) error {
+	collection = strings.TrimSpace(collection)
+	if collection == "" {
+		return errors.New("collection name is required")
+	}
+	if _, err := db.db.Exec(`
+		INSERT INTO pinned_collections (user_id, collection, pinned_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, collection) DO NOTHING
+	`, db.actingUserID(), collection, time.Now().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("failed to pin collection: %w", err)
+	}
+	return nil
+}
+
+// UnpinCollection removes a collection from the handle's user's landing
+// page. Unpinning a collection that isn't pinned does nothing.
+func (db *DB) UnpinCollection(collection string) error {
+	if _, err := db.db.Exec(`DELETE FROM pinned_collections WHERE user_id = ? AND collection = ?`,
+		db.actingUserID(), strings.TrimSpace(collection)); err != nil {
+		return fmt.Errorf("failed to unpin collection: %w", err)
+	}
+	return nil
+}
+
+// ListPinnedCollections returns the handle's user's pinned collections, in
+// the order they were pinned, with how many bookmarks each has.
+func (db *DB) ListPinnedCollections() ([]PinnedCollection, error) {
+	rows, err := db.db.Query(`
+		SELECT p.user_id, p.collection, p.pinned_at,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.collection = p.collection AND `+ownerFilter("b.user_id")+`)
+		FROM pinned_collections p
+		WHERE p.user_id = ?
+		ORDER BY p.pinned_at, p.collection
+	`, append(db.owner(), db.actingUserID())...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pinned collections: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var pinned []PinnedCollection
+	for rows.Next() {
+		var c PinnedCollection
+		if err := rows.Scan(&c.UserID, &c.Collection, &c.PinnedAt, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan pinned collection: %w", err)
+		}
+		pinned = append(pinned, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate pinned collections: %w", err)
+	}
+	return pinned, nil
+}
+
+// ListCollectionBookmarks returns the bookmarks in a collection, newest
+// first.
+func (db *DB) ListCollectionBookmarks(collection string, limit int) ([]Bookmark, error) {
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE collection = ? AND `+ownerFilter("user_id")+`
+		ORDER BY created_at DESC, id DESC
+	`, append([]any{strings.TrimSpace(collection)}, db.owner()...), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// CountBookmarks counts the bookmarks for the landing page's stats widget.
+func (db *DB) CountBookmarks() (BookmarkCounts, error) {
+	var c BookmarkCounts
+	err := db.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(is_read = 0), 0),
+			COALESCE(SUM(is_favorite = 1), 0),
+			COALESCE(SUM(pinned_at IS NOT NULL), 0),
+			COALESCE(SUM(archive_status = 'ok'), 0)
+		FROM bookmarks
+		WHERE `+ownerFilter("user_id"), db.owner()...).Scan(&c.Total, &c.Unread, &c.Favorites, &c.Pinned, &c.Archived)
+	if err != nil {
+		return BookmarkCounts{}, fmt.Errorf("failed to count bookmarks: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLandingLayout(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("defaults to every widget", func(t *testing.T) {
+		l, err := db.GetLandingLayout(LocalUserID)
+		if err != nil {
+			t.Fatalf("GetLandingLayout() error = %v", err)
+		}
+		if !reflect.DeepEqual(l.Widgets, LandingWidgets) || l.ListSize != DefaultLandingListSize || l.UpdatedAt != "" {
+			t.Errorf("unexpected default layout %+v", l)
+		}
+	})
+
+	t.Run("saves widgets in order", func(t *testing.T) {
+		if err := db.SaveLandingLayout(LandingLayout{UserID: LocalUserID, Widgets: []string{" Stats", "pinned"}, ListSize: 10}); err != nil {
+			t.Fatalf("SaveLandingLayout() error = %v", err)
+		}
+		l, err := db.GetLandingLayout(LocalUserID)
+		if err != nil {
+			t.Fatalf("GetLandingLayout() error = %v", err)
+		}
+		if want := []string{LandingStats, LandingPinned}; !reflect.DeepEqual(l.Widgets, want) || l.ListSize != 10 || l.UpdatedAt == "" {
+			t.Errorf("unexpected layout %+v", l)
+		}
+
+		if err := db.SaveLandingLayout(LandingLayout{UserID: LocalUserID}); err != nil {
+			t.Fatalf("SaveLandingLayout() error = %v", err)
+		}
+		if l, err := db.GetLandingLayout(LocalUserID); err != nil || len(l.Widgets) != 0 || l.ListSize != DefaultLandingListSize {
+			t.Errorf("expected an empty layout to stay empty, got %+v, %v", l, err)
+		}
+	})
+
+	t.Run("rejects invalid layouts", func(t *testing.T) {
+		for _, l := range []LandingLayout{
+			{UserID: LocalUserID, Widgets: []string{"weather"}},
+			{UserID: LocalUserID, Widgets: []string{"recent", "Recent"}},
+			{UserID: LocalUserID, ListSize: MaxLandingListSize + 1},
+		} {
+			if err := db.SaveLandingLayout(l); !errors.Is(err, ErrInvalidLandingLayout) {
+				t.Errorf("SaveLandingLayout(%+v) error = %v, want ErrInvalidLandingLayout", l, err)
+			}
+		}
+	})
+}
+
+func TestPinning(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobs := db.ForUser(bob.ID)
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, nb := range []NewBookmark{
+		{URL: "https://go.dev", Title: "Go", Collection: "Reading", CreatedAt: day},
+		{URL: "https://example.com/later", Title: "Later", Collection: "Reading", CreatedAt: day.Add(time.Hour)},
+		{URL: "https://example.com/other", Title: "Other", CreatedAt: day},
+	} {
+		id, err := bobs.CreateBookmark(nb)
+		if err != nil {
+			t.Fatalf("failed to create bookmark %d: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	mine, err := db.CreateBookmark(NewBookmark{URL: "https://example.com/mine", Title: "Mine", Collection: "Reading"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	t.Run("bookmarks stay in the order they were pinned", func(t *testing.T) {
+		for _, id := range []int64{ids[2], ids[0], ids[2]} {
+			if err := bobs.SetBookmarkPinned(id, true); err != nil {
+				t.Fatalf("SetBookmarkPinned(%d) error = %v", id, err)
+			}
+		}
+		pinned, err := bobs.ListPinnedBookmarks(0)
+		if err != nil {
+			t.Fatalf("ListPinnedBookmarks() error = %v", err)
+		}
+		if len(pinned) != 2 || pinned[0].ID != ids[2] || pinned[1].ID != ids[0] {
+			t.Errorf("unexpected pinned bookmarks %+v", pinned)
+		}
+		if flags, err := bobs.GetBookmarkFlags(ids[0]); err != nil || !flags.IsPinned {
+			t.Errorf("expected bookmark to be flagged pinned, got %+v, %v", flags, err)
+		}
+		if err := bobs.SetBookmarkPinned(mine, true); err == nil {
+			t.Error("expected pinning someone else's bookmark to fail")
+		}
+
+		if err := bobs.SetBookmarkPinned(ids[2], false); err != nil {
+			t.Fatalf("SetBookmarkPinned() error = %v", err)
+		}
+		if pinned, err := bobs.ListPinnedBookmarks(0); err != nil || len(pinned) != 1 || pinned[0].ID != ids[0] {
+			t.Errorf("expected one pinned bookmark after unpinning, got %+v, %v", pinned, err)
+		}
+	})
+
+	t.Run("collections count their owner's bookmarks", func(t *testing.T) {
+		for _, c := range []string{" Reading ", "Empty", "Reading"} {
+			if err := bobs.PinCollection(c); err != nil {
+				t.Fatalf("PinCollection(%q) error = %v", c, err)
+			}
+		}
+		if err := bobs.PinCollection(" "); err == nil {
+			t.Error("expected pinning a blank collection to fail")
+		}
+		pinned, err := bobs.ListPinnedCollections()
+		if err != nil {
+			t.Fatalf("ListPinnedCollections() error = %v", err)
+		}
+		if len(pinned) != 2 || pinned[0].Collection != "Reading" || pinned[0].Count != 2 || pinned[1].Collection != "Empty" || pinned[1].Count != 0 {
+			t.Errorf("unexpected pinned collections %+v", pinned)
+		}
+		if theirs, err := db.ListPinnedCollections(); err != nil || len(theirs) != 0 {
+			t.Errorf("expected other users to have no pinned collections, got %+v, %v", theirs, err)
+		}
+
+		bookmarks, err := bobs.ListCollectionBookmarks("Reading", 1)
+		if err != nil || len(bookmarks) != 1 || bookmarks[0].ID != ids[1] {
+			t.Errorf("expected the newest bookmark in the collection, got %+v, %v", bookmarks, err)
+		}
+
+		if err := bobs.UnpinCollection("Empty"); err != nil {
+			t.Fatalf("UnpinCollection() error = %v", err)
+		}
+		if pinned, err := bobs.ListPinnedCollections(); err != nil || len(pinned) != 1 {
+			t.Errorf("expected one pinned collection after unpinning, got %+v, %v", pinned, err)
+		}
+	})
+
+	t.Run("counts bookmarks", func(t *testing.T) {
+		if err := bobs.MarkRead(ids[1], true); err != nil {
+			t.Fatalf("failed to mark read: %v", err)
+		}
+		if _, err := bobs.ToggleFavorite(ids[1]); err != nil {
+			t.Fatalf("failed to toggle favorite: %v", err)
+		}
+		counts, err := bobs.CountBookmarks()
+		if err != nil {
+			t.Fatalf("CountBookmarks() error = %v", err)
+		}
+		if want := (BookmarkCounts{Total: 3, Unread: 2, Favorites: 1, Pinned: 1}); counts != want {
+			t.Errorf("CountBookmarks() = %+v, want %+v", counts, want)
+		}
+	})
+
+	t.Run("deleting the user's data removes the pins and layout", func(t *testing.T) {
+		if err := db.SaveLandingLayout(LandingLayout{UserID: bob.ID, Widgets: []string{LandingStats}}); err != nil {
+			t.Fatalf("SaveLandingLayout() error = %v", err)
+		}
+		if _, err := db.DeleteUserData(bob.ID); err != nil {
+			t.Fatalf("DeleteUserData() error = %v", err)
+		}
+		if pinned, err := bobs.ListPinnedCollections(); err != nil || len(pinned) != 0 {
+			t.Errorf("expected pinned collections to be deleted, got %+v, %v", pinned, err)
+		}
+		if l, err := db.GetLandingLayout(bob.ID); err != nil || l.UpdatedAt != "" {
+			t.Errorf("expected the layout to be deleted, got %+v, %v", l, err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// BookmarkLink is an outbound link found in a bookmark's latest archive.
+type BookmarkLink struct {
+	URL string
+	// Key identifies the page URL points to, ignoring the scheme, "www.",
+	// a trailing slash and the fragment; see core.LinkKey.
+	Key string
+	// Host is the lowercase host without "www.".
+	Host string
+}
+
+// Backlink is a bookmark whose latest archive links to the page or domain
+// a BacklinkQuery asked for.
+type Backlink struct {
+	BookmarkID int64
+	URL        string
+	Title      string
+	// LinkURL is the matching link as it appears in the archive.
+	LinkURL string
+}
+
+// BacklinkQuery selects what ListBacklinks looks for: links to the page
+// with URL key Key, or to Domain and its subdomains. Exactly one is set.
+type BacklinkQuery struct {
+	Key    string
+	Domain string
+}
+
+// SaveBookmarkLinks replaces the outbound links stored for a bookmark.
+// Links with the same key are stored once.
+func (db *DB) SaveBookmarkLinks(bookmarkID int64, links []BookmarkLink) error {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM bookmark_links WHERE bookmark_id = ?`, bookmarkID); err != nil {
+		return fmt.Errorf("failed to clear bookmark links: %w", err)
+	}
+	for _, l := range links {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO bookmark_links (bookmark_id, url, url_key, host)
+			VALUES (?, ?, ?, ?)
+		`, bookmarkID, l.URL, l.Key, l.Host); err != nil {
+			return fmt.Errorf("failed to save bookmark link: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bookmark links: %w", err)
+	}
+	return nil
+}
+
+// ListBookmarkLinks returns the outbound links stored for a bookmark,
+// ordered by URL.
+func (db *DB) ListBookmarkLinks(bookmarkID int64) ([]BookmarkLink, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.db.Query(`
+		SELECT url, url_key, host
+		FROM bookmark_links
+		WHERE bookmark_id = ?
+		ORDER BY url
+	`, bookmarkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmark links: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	out := []BookmarkLink{}
+	for rows.Next() {
+		var l BookmarkLink
+		if err := rows.Scan(&l.URL, &l.Key, &l.Host); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark link: %w", err)
+		}
+		out = append(out, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bookmark links: %w", err)
+	}
+	return out, nil
+}
+
+// ListBacklinks returns the bookmarks whose archives link to the page or
+// domain q names, newest bookmark first. A bookmark linking to a domain
+// several times is listed once per link.
+func (db *DB) ListBacklinks(q BacklinkQuery) ([]Backlink, error) {
+	var match string
+	var args []any
+	switch {
+	case q.Key != "" && q.Domain == "":
+		match = `l.url_key = ?`
+		args = []any{q.Key}
+	case q.Domain != "" && q.Key == "":
+		domain := strings.ToLower(q.Domain)
+		match = `(l.host = ? OR l.host LIKE ? ESCAPE '\')`
+		args = []any{domain, "%." + escapeLike(domain)}
+	default:
+		return nil, fmt.Errorf("backlink query needs either a URL or a domain")
+	}
+
+	rows, err := db.db.Query(`
+		SELECT b.id, b.url, COALESCE(b.title, ''), l.url
+		FROM bookmark_links l
+		JOIN bookmarks b ON b.id = l.bookmark_id
+		WHERE `+match+` AND `+ownerFilter("b.user_id")+`
+		ORDER BY b.created_at DESC, b.id DESC, l.url
+	`, append(args, db.owner()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backlinks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	out := []Backlink{}
+	for rows.Next() {
+		var b Backlink
+		if err := rows.Scan(&b.BookmarkID, &b.URL, &b.Title, &b.LinkURL); err != nil {
+			return nil, fmt.Errorf("failed to scan backlink: %w", err)
+		}
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate backlinks: %w", err)
+	}
+	return out, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
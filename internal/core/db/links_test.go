@@ -0,0 +1,108 @@
+package db
+
+import (
+	"testing"
+)
+
+// TestBookmarkLinks tests storing outbound links and querying backlinks.
+func TestBookmarkLinks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	link := func(url, key, host string) BookmarkLink {
+		return BookmarkLink{URL: url, Key: key, Host: host}
+	}
+	older, _ := db.AddBookmark("https://a.example.com/", "Older")
+	newer, _ := db.AddBookmark("https://b.example.com/", "Newer")
+	if err := db.SaveBookmarkLinks(older, []BookmarkLink{
+		link("https://stale.example.org/", "stale.example.org", "stale.example.org"),
+	}); err != nil {
+		t.Fatalf("failed to save links: %v", err)
+	}
+	if err := db.SaveBookmarkLinks(older, []BookmarkLink{
+		link("https://go.dev/doc/", "go.dev/doc", "go.dev"),
+		link("http://go.dev/doc", "go.dev/doc", "go.dev"),
+		link("https://pkg.go.dev/net/http", "pkg.go.dev/net/http", "pkg.go.dev"),
+	}); err != nil {
+		t.Fatalf("failed to save links: %v", err)
+	}
+	if err := db.SaveBookmarkLinks(newer, []BookmarkLink{
+		link("https://go.dev/doc", "go.dev/doc", "go.dev"),
+		link("https://notgo.dev/", "notgo.dev", "notgo.dev"),
+		link("https://go_dev.example/", "go_dev.example", "go_dev.example"),
+	}); err != nil {
+		t.Fatalf("failed to save links: %v", err)
+	}
+
+	t.Run("saving replaces and deduplicates links", func(t *testing.T) {
+		links, err := db.ListBookmarkLinks(older)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(links) != 2 || links[0].Key != "go.dev/doc" || links[1].Host != "pkg.go.dev" {
+			t.Errorf("unexpected links %+v", links)
+		}
+	})
+
+	t.Run("backlinks to a page", func(t *testing.T) {
+		got, err := db.ListBacklinks(BacklinkQuery{Key: "go.dev/doc"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(got) != 2 || got[0].BookmarkID != newer || got[1].BookmarkID != older || got[1].Title != "Older" {
+			t.Errorf("expected both bookmarks, newest first, got %+v", got)
+		}
+	})
+
+	t.Run("backlinks to a domain include subdomains", func(t *testing.T) {
+		got, err := db.ListBacklinks(BacklinkQuery{Domain: "GO.dev"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("expected 3 links, got %+v", got)
+		}
+		for _, b := range got {
+			if b.LinkURL == "https://notgo.dev/" {
+				t.Errorf("expected notgo.dev not to match go.dev")
+			}
+		}
+		if got, _ := db.ListBacklinks(BacklinkQuery{Domain: "dev"}); len(got) != 4 {
+			t.Errorf("expected the _ in go_dev.example not to act as a wildcard, got %+v", got)
+		}
+	})
+
+	t.Run("rejects empty and ambiguous queries", func(t *testing.T) {
+		for _, q := range []BacklinkQuery{{}, {Key: "go.dev", Domain: "go.dev"}} {
+			if _, err := db.ListBacklinks(q); err == nil {
+				t.Errorf("%+v: expected an error", q)
+			}
+		}
+	})
+
+	t.Run("scoped to the user", func(t *testing.T) {
+		bob, err := db.CreateUser("bob", "", false)
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if got, _ := db.ForUser(bob.ID).ListBacklinks(BacklinkQuery{Domain: "go.dev"}); len(got) != 0 {
+			t.Errorf("expected no backlinks for another user, got %+v", got)
+		}
+		if err := db.ForUser(bob.ID).SaveBookmarkLinks(older, nil); err == nil {
+			t.Error("expected an error saving links on another user's bookmark")
+		}
+	})
+
+	t.Run("deleting the bookmark removes its links", func(t *testing.T) {
+		if err := db.DeleteBookmark(older); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if got, _ := db.ListBacklinks(BacklinkQuery{Key: "go.dev/doc"}); len(got) != 1 || got[0].BookmarkID != newer {
+			t.Errorf("expected only the remaining bookmark, got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SaveBookmarkMetadata stores freshly fetched page metadata for a bookmark,
+// replacing any previous metadata. If m.Title is non-empty the bookmark's
+// title is updated to match.
+func (db *DB) SaveBookmarkMetadata(m BookmarkMetadata) error {
+	fetchedAt := m.FetchedAt
+	if fetchedAt == "" {
+		fetchedAt = time.Now().Format(time.RFC3339)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	// A bookmark saved without a title was named after its URL; renamed
+	// after the title it gets now, before links to it are likely shared.
+	if _, err := tx.Exec(`
+		UPDATE bookmarks SET slug = NULL
+		WHERE id = ? AND ? != '' AND `+untitledSlugCondition+` AND `+ownerFilter("user_id"),
+		append([]any{m.BookmarkID, m.Title}, db.owner()...)...); err != nil {
+		return fmt.Errorf("failed to reset bookmark slug: %w", err)
+	}
+	res, err := tx.Exec(`
+		UPDATE bookmarks
+		SET title = CASE WHEN ? != '' THEN ? ELSE title END
+		WHERE id = ? AND `+ownerFilter("user_id"), append([]any{m.Title, m.Title, m.BookmarkID}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update bookmark title: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", m.BookmarkID)
+	}
+	if err := assignBookmarkSlug(tx, m.BookmarkID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO bookmark_metadata (bookmark_id, description, favicon_url, image_url, site_name, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (bookmark_id) DO UPDATE SET
+			description = excluded.description,
+			favicon_url = excluded.favicon_url,
+			image_url = excluded.image_url,
+			site_name = excluded.site_name,
+			fetched_at = excluded.fetched_at
+	`, m.BookmarkID, m.Description, m.FaviconURL, m.ImageURL, m.SiteName, fetchedAt); err != nil {
+		return fmt.Errorf("failed to save bookmark metadata: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bookmark metadata: %w", err)
+	}
+	return nil
+}
+
+// GetBookmarkMetadata returns the stored page metadata for a bookmark.
+// Fields other than BookmarkID and Title are empty if metadata has never been fetched.
+func (db *DB) GetBookmarkMetadata(id int64) (BookmarkMetadata, error) {
+	var m BookmarkMetadata
+	err := db.db.QueryRow(`
+		SELECT
+			b.id,
+			b.title,
+			COALESCE(m.description, ''),
+			COALESCE(m.favicon_url, ''),
+			COALESCE(m.image_url, ''),
+			COALESCE(m.site_name, ''),
+			COALESCE(m.fetched_at, '')
+		FROM bookmarks b
+		LEFT JOIN bookmark_metadata m ON m.bookmark_id = b.id
+		WHERE b.id = ? AND `+ownerFilter("b.user_id"), append([]any{id}, db.owner()...)...).Scan(&m.BookmarkID, &m.Title, &m.Description, &m.FaviconURL, &m.ImageURL, &m.SiteName, &m.FetchedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BookmarkMetadata{}, fmt.Errorf("bookmark not found: %d", id)
+		}
+		return BookmarkMetadata{}, fmt.Errorf("failed to get bookmark metadata: %w", err)
+	}
+	return m, nil
+}
+
+// ListBookmarksWithStaleMetadata returns bookmarks whose metadata has never
+// been fetched or was last fetched before olderThan, least recently fetched first.
+func (db *DB) ListBookmarksWithStaleMetadata(olderThan time.Time, limit int) ([]Bookmark, error) {
+	query := `
+		SELECT b.id, b.url, b.title, b.created_at, COALESCE(b.slug, '')
+		FROM bookmarks b
+		LEFT JOIN bookmark_metadata m ON m.bookmark_id = b.id
+		WHERE (m.fetched_at IS NULL OR m.fetched_at < ?) AND ` + ownerFilter("b.user_id") + `
+		ORDER BY COALESCE(m.fetched_at, '') ASC, b.created_at DESC`
+	bookmarks, err := db.queryBookmarks(query, append([]any{olderThan.Format(time.RFC3339)}, db.owner()...), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmarks with stale metadata: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// ListBookmarksMissingTitles returns bookmarks whose title is empty or just
+// their URL, newest first.
+func (db *DB) ListBookmarksMissingTitles(limit int) ([]Bookmark, error) {
+	query := `
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE (TRIM(title) = '' OR RTRIM(TRIM(title), '/') = RTRIM(url, '/')) AND ` + ownerFilter("user_id") + `
+		ORDER BY created_at DESC`
+	bookmarks, err := db.queryBookmarks(query, db.owner(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmarks missing titles: %w", err)
+	}
+	return bookmarks, nil
+}
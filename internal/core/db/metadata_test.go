@@ -0,0 +1,157 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBookmarkMetadata tests saving and loading page metadata.
+func TestBookmarkMetadata(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Old Title")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("empty before first fetch", func(t *testing.T) {
+		m, err := db.GetBookmarkMetadata(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if m.Title != "Old Title" || m.Description != "" || m.FetchedAt != "" {
+			t.Errorf("unexpected metadata: %+v", m)
+		}
+	})
+
+	t.Run("saves metadata and updates title", func(t *testing.T) {
+		if err := db.SaveBookmarkMetadata(BookmarkMetadata{
+			BookmarkID:  id,
+			Title:       "New Title",
+			Description: "A description",
+			FaviconURL:  "https://example.com/favicon.ico",
+			ImageURL:    "https://example.com/og.png",
+			SiteName:    "Example",
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		m, err := db.GetBookmarkMetadata(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if m.Title != "New Title" {
+			t.Errorf("expected title to be updated, got %q", m.Title)
+		}
+		if m.Description != "A description" || m.FaviconURL != "https://example.com/favicon.ico" || m.ImageURL != "https://example.com/og.png" || m.SiteName != "Example" {
+			t.Errorf("unexpected metadata: %+v", m)
+		}
+		if m.FetchedAt == "" {
+			t.Error("expected FetchedAt to be set")
+		}
+	})
+
+	t.Run("empty title keeps existing title", func(t *testing.T) {
+		if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: id, Description: "Updated"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		b, _ := db.GetBookmark(id)
+		if b.Title != "New Title" {
+			t.Errorf("expected title to be kept, got %q", b.Title)
+		}
+	})
+
+	t.Run("returns error for non-existent bookmark", func(t *testing.T) {
+		if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: 99999}); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+		if _, err := db.GetBookmarkMetadata(99999); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+	})
+}
+
+// TestListBookmarksWithStaleMetadata tests the staleness filter.
+func TestListBookmarksWithStaleMetadata(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	never, _ := db.AddBookmark("https://never.com", "Never")
+	old, _ := db.AddBookmark("https://old.com", "Old")
+	fresh, _ := db.AddBookmark("https://fresh.com", "Fresh")
+
+	oldAt := time.Now().Add(-100 * 24 * time.Hour).Format(time.RFC3339)
+	if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: old, FetchedAt: oldAt}); err != nil {
+		t.Fatalf("failed to save metadata: %v", err)
+	}
+	if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: fresh}); err != nil {
+		t.Fatalf("failed to save metadata: %v", err)
+	}
+
+	bookmarks, err := db.ListBookmarksWithStaleMetadata(time.Now().Add(-90*24*time.Hour), 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := map[int64]bool{}
+	for _, b := range bookmarks {
+		got[b.ID] = true
+	}
+	if !got[never] || !got[old] || got[fresh] || len(bookmarks) != 2 {
+		t.Errorf("expected never-fetched and old bookmarks, got %+v", bookmarks)
+	}
+
+	limited, err := db.ListBookmarksWithStaleMetadata(time.Now().Add(-90*24*time.Hour), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(limited) != 1 || limited[0].ID != never {
+		t.Errorf("expected never-fetched bookmark first, got %+v", limited)
+	}
+
+	if err := db.DeleteBookmark(old); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+	var count int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM bookmark_metadata WHERE bookmark_id = ?`, old).Scan(&count); err != nil {
+		t.Fatalf("failed to count metadata: %v", err)
+	}
+	if count != 0 {
+		t.Error("expected metadata to be deleted with bookmark")
+	}
+}
+
+func TestListBookmarksMissingTitles(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	empty, _ := db.AddBookmark("https://empty.com", "")
+	urlTitle, _ := db.AddBookmark("https://url.com/page", "https://url.com/page/")
+	if _, err := db.AddBookmark("https://titled.com", "Titled"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	bookmarks, err := db.ListBookmarksMissingTitles(0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := map[int64]bool{}
+	for _, b := range bookmarks {
+		got[b.ID] = true
+	}
+	if !got[empty] || !got[urlTitle] || len(bookmarks) != 2 {
+		t.Errorf("expected the untitled bookmarks, got %+v", bookmarks)
+	}
+}
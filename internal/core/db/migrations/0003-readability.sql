@@ -0,0 +1,6 @@
+-- Add reader-mode (readability) extraction of archived pages
+
+ALTER TABLE bookmarks ADD COLUMN readable_title TEXT;
+ALTER TABLE bookmarks ADD COLUMN readable_byline TEXT;
+ALTER TABLE bookmarks ADD COLUMN readable_html TEXT;
+ALTER TABLE bookmarks ADD COLUMN readable_text TEXT;
@@ -0,0 +1,33 @@
+-- Keep every successful capture as its own snapshot instead of overwriting
+-- the archive stored on the bookmark row. The bookmarks table keeps only the
+-- status of the most recent attempt (used for queueing and list views).
+
+CREATE TABLE IF NOT EXISTS bookmark_archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    captured_at TEXT NOT NULL,
+    archived_url TEXT,
+    archived_html TEXT,
+    readable_title TEXT,
+    readable_byline TEXT,
+    readable_html TEXT,
+    readable_text TEXT,
+    UNIQUE (bookmark_id, captured_at)
+);
+
+INSERT INTO bookmark_archives (
+    bookmark_id, captured_at, archived_url, archived_html,
+    readable_title, readable_byline, readable_html, readable_text
+)
+SELECT
+    id, archived_at, archived_url, archived_html,
+    readable_title, readable_byline, readable_html, readable_text
+FROM bookmarks
+WHERE archived_at IS NOT NULL AND archive_status = 'ok';
+
+ALTER TABLE bookmarks DROP COLUMN archived_html;
+ALTER TABLE bookmarks DROP COLUMN archived_url;
+ALTER TABLE bookmarks DROP COLUMN readable_title;
+ALTER TABLE bookmarks DROP COLUMN readable_byline;
+ALTER TABLE bookmarks DROP COLUMN readable_html;
+ALTER TABLE bookmarks DROP COLUMN readable_text;
@@ -0,0 +1,7 @@
+-- Archived HTML is mostly inlined base64 assets and compresses very well.
+-- New captures are written gzip-compressed to archived_html_gz; existing rows
+-- are compressed by the Go data migration registered for this version, which
+-- clears archived_html afterwards. Reads fall back to archived_html so rows
+-- written before this migration still load.
+
+ALTER TABLE bookmark_archives ADD COLUMN archived_html_gz BLOB;
@@ -0,0 +1,12 @@
+-- Page metadata (description, favicon, Open Graph) fetched with a plain HTTP
+-- request, independently of the full archive. fetched_at drives the
+-- refresh-metadata command's staleness check.
+
+CREATE TABLE IF NOT EXISTS bookmark_metadata (
+    bookmark_id INTEGER PRIMARY KEY REFERENCES bookmarks(id) ON DELETE CASCADE,
+    description TEXT,
+    favicon_url TEXT,
+    image_url TEXT,
+    site_name TEXT,
+    fetched_at TEXT NOT NULL
+);
@@ -0,0 +1,17 @@
+-- Move archived page HTML out of bookmark_archives into a content-addressed
+-- blob table, so listing versions and loading status never touches
+-- multi-megabyte rows, and identical captures are stored once.
+--
+-- The Go data migration registered for this version relocates existing HTML
+-- and then drops the old archived_html / archived_html_gz columns.
+
+CREATE TABLE IF NOT EXISTS archive_blobs (
+    hash TEXT PRIMARY KEY, -- hex SHA-256 of the uncompressed HTML
+    size INTEGER NOT NULL, -- uncompressed size in bytes
+    data BLOB NOT NULL,    -- gzip-compressed HTML
+    created_at TEXT NOT NULL
+);
+
+ALTER TABLE bookmark_archives ADD COLUMN blob_hash TEXT REFERENCES archive_blobs(hash);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_archives_blob_hash ON bookmark_archives(blob_hash);
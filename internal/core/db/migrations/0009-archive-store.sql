@@ -0,0 +1,5 @@
+-- archive_blobs is now one of several archive stores (see db.BlobStore), and
+-- a store only sees the compressed bytes it is asked to keep. Record the
+-- stored size instead of the uncompressed size so every backend agrees.
+
+UPDATE archive_blobs SET size = length(data);
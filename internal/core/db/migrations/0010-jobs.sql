@@ -0,0 +1,24 @@
+-- Persistent background job queue. Replaces the in-memory archive work
+-- channel so queued work survives restarts and failed jobs can be retried
+-- with backoff. Timestamps are UTC RFC3339 so they sort lexically.
+--
+-- status is one of 'queued', 'running', 'done' or 'failed' (gave up after
+-- the maximum number of attempts).
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TEXT NOT NULL,
+    last_error TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- At most one pending job of each kind per bookmark.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_pending
+    ON jobs(kind, bookmark_id) WHERE status IN ('queued', 'running');
+
+CREATE INDEX IF NOT EXISTS idx_jobs_ready ON jobs(kind, status, next_attempt_at);
@@ -0,0 +1,20 @@
+-- Per-user archive defaults. A NULL column means "use the instance default"
+-- (the server's command-line flags). Until accounts exist every bookmark
+-- belongs to the local user (db.LocalUserID).
+
+CREATE TABLE IF NOT EXISTS user_archive_preferences (
+    user_id INTEGER PRIMARY KEY,
+    auto_archive INTEGER,
+    strip_scripts INTEGER,
+    mobile_viewport INTEGER,
+    screenshot INTEGER,
+    updated_at TEXT NOT NULL
+);
+
+-- Archive settings resolved when a job is enqueued, as JSON. NULL means
+-- resolve them when the job runs.
+ALTER TABLE jobs ADD COLUMN options TEXT;
+
+-- Optional full-page screenshot captured with a version, stored in the blob
+-- store alongside the HTML.
+ALTER TABLE bookmark_archives ADD COLUMN screenshot_hash TEXT;
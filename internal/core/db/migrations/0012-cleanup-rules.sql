@@ -0,0 +1,36 @@
+-- Expiration / auto-cleanup rules and their audit log.
+--
+-- A rule matches bookmarks older than older_than_days, optionally only those
+-- carrying tag and/or never opened (last_read_at IS NULL), and either
+-- deletes them (action 'delete') or tags them with action_tag (action 'tag').
+--
+-- cleanup_log records every action a rule takes. It copies the bookmark's
+-- URL and title because deleted bookmarks are gone from bookmarks.
+
+ALTER TABLE bookmarks ADD COLUMN last_read_at TEXT;
+
+CREATE TABLE IF NOT EXISTS cleanup_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    tag TEXT,
+    unread_only INTEGER NOT NULL DEFAULT 0,
+    older_than_days INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    action_tag TEXT,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL,
+    last_run_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS cleanup_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule_id INTEGER NOT NULL,
+    rule_name TEXT NOT NULL,
+    bookmark_id INTEGER NOT NULL,
+    bookmark_url TEXT NOT NULL,
+    bookmark_title TEXT NOT NULL,
+    action TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cleanup_log_created ON cleanup_log(created_at);
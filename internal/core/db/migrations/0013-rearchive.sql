@@ -0,0 +1,5 @@
+-- Per-bookmark opt-out from scheduled re-archiving. Bookmarks are
+-- re-archived by default once their latest snapshot is older than the
+-- server's --rearchive-after age.
+
+ALTER TABLE bookmarks ADD COLUMN rearchive_disabled INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,27 @@
+-- Routing rules: user-defined rules evaluated when a bookmark is created.
+--
+-- A rule matches when the bookmark's host is domain (or a subdomain of it)
+-- or its title contains title_contains (case-insensitively); either may be
+-- empty, but not both. A matching rule adds add_tag, files the bookmark in
+-- collection and/or keeps it from being archived automatically.
+--
+-- bookmarks.collection holds the collection a bookmark is filed in, and
+-- bookmarks.skip_archive marks bookmarks a rule kept out of the archive
+-- queue (they can still be archived on demand).
+
+ALTER TABLE bookmarks ADD COLUMN collection TEXT;
+ALTER TABLE bookmarks ADD COLUMN skip_archive INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS routing_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    domain TEXT,
+    title_contains TEXT,
+    add_tag TEXT,
+    collection TEXT,
+    skip_archive INTEGER NOT NULL DEFAULT 0,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_collection ON bookmarks(collection);
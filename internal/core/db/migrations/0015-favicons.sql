@@ -0,0 +1,14 @@
+-- Favicons downloaded and stored per bookmark, so lists don't hot-link the
+-- live site and still show an icon after it goes away.
+--
+-- source_url is where the icon was fetched from (usually
+-- bookmark_metadata.favicon_url); content_type is the image type it is
+-- served with.
+
+CREATE TABLE IF NOT EXISTS bookmark_favicons (
+    bookmark_id INTEGER PRIMARY KEY REFERENCES bookmarks(id) ON DELETE CASCADE,
+    source_url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    data BLOB NOT NULL,
+    fetched_at TEXT NOT NULL
+);
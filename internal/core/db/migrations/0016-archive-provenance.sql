@@ -0,0 +1,5 @@
+-- Provenance record for each archive version: how the capture was made
+-- (browser, user agent, egress, options). Stored as JSON owned by
+-- core.ArchiveProvenance; NULL for versions captured before this existed.
+
+ALTER TABLE bookmark_archives ADD COLUMN provenance TEXT;
@@ -0,0 +1,4 @@
+-- Free-form notes on a bookmark: why it was saved, what to remember about
+-- it. Written in Markdown and rendered in the bookmark list.
+
+ALTER TABLE bookmarks ADD COLUMN notes TEXT;
@@ -0,0 +1,8 @@
+-- RFC 3161 timestamps of archive versions. timestamp_token is the DER
+-- TimeStampToken a time-stamping authority (TSA) returned for the SHA-256
+-- of the version's HTML (which is also its blob_hash); timestamp_authority
+-- is the TSA URL and timestamped_at the token's genTime (UTC RFC3339).
+
+ALTER TABLE bookmark_archives ADD COLUMN timestamp_token BLOB;
+ALTER TABLE bookmark_archives ADD COLUMN timestamp_authority TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN timestamped_at TEXT;
@@ -0,0 +1,9 @@
+-- Read-later flags set by the user. is_read is separate from last_read_at,
+-- which only records that the archive was opened; it's what the unread
+-- filter and the read toggle use.
+
+ALTER TABLE bookmarks ADD COLUMN is_read INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bookmarks ADD COLUMN is_favorite INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_is_read ON bookmarks(is_read);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_is_favorite ON bookmarks(is_favorite);
@@ -0,0 +1,91 @@
+-- Full-text search over bookmarks. The index's docid is the bookmark ID and
+-- each searchable field is its own column, so queries can target one field
+-- and ranking can weight them. Triggers keep it in step with bookmarks and
+-- their tags and archives (content is the reader-mode text of the latest
+-- archive version); the 0020 data migration fills it for existing bookmarks.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS bookmark_search USING fts4(
+    title,
+    url,
+    notes,
+    tags,
+    content,
+    tokenize=unicode61
+);
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_insert AFTER INSERT ON bookmarks
+BEGIN
+    INSERT INTO bookmark_search (docid, title, url, notes, tags, content)
+    SELECT b.id, COALESCE(b.title, ''), b.url, COALESCE(b.notes, ''),
+           COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+                     JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = b.id), ''),
+           COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = b.id
+                     ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+    FROM bookmarks b WHERE b.id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_update
+AFTER UPDATE OF title, url, notes ON bookmarks
+BEGIN
+    DELETE FROM bookmark_search WHERE docid = OLD.id;
+    INSERT INTO bookmark_search (docid, title, url, notes, tags, content)
+    SELECT b.id, COALESCE(b.title, ''), b.url, COALESCE(b.notes, ''),
+           COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+                     JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = b.id), ''),
+           COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = b.id
+                     ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+    FROM bookmarks b WHERE b.id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_delete AFTER DELETE ON bookmarks
+BEGIN
+    DELETE FROM bookmark_search WHERE docid = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_tag_insert AFTER INSERT ON bookmark_tags
+BEGIN
+    UPDATE bookmark_search
+    SET tags = COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+                         JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = NEW.bookmark_id), '')
+    WHERE docid = NEW.bookmark_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_tag_delete AFTER DELETE ON bookmark_tags
+BEGIN
+    UPDATE bookmark_search
+    SET tags = COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+                         JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = OLD.bookmark_id), '')
+    WHERE docid = OLD.bookmark_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_tag_rename AFTER UPDATE OF name ON tags
+BEGIN
+    UPDATE bookmark_search
+    SET tags = COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+                         JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = bookmark_search.docid), '')
+    WHERE docid IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_archive_insert AFTER INSERT ON bookmark_archives
+BEGIN
+    UPDATE bookmark_search
+    SET content = COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = NEW.bookmark_id
+                            ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+    WHERE docid = NEW.bookmark_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_archive_update AFTER UPDATE OF readable_text ON bookmark_archives
+BEGIN
+    UPDATE bookmark_search
+    SET content = COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = NEW.bookmark_id
+                            ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+    WHERE docid = NEW.bookmark_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bookmark_search_archive_delete AFTER DELETE ON bookmark_archives
+BEGIN
+    UPDATE bookmark_search
+    SET content = COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = OLD.bookmark_id
+                            ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+    WHERE docid = OLD.bookmark_id;
+END;
@@ -0,0 +1,4 @@
+-- How many times each bookmark's archive or reader view was opened, next to
+-- last_read_at. Search ranking uses it as an interaction signal.
+
+ALTER TABLE bookmarks ADD COLUMN view_count INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+-- Instance-wide activity stream, written by event listeners (see
+-- EnableActivityLog). bookmark_url and bookmark_title are copied so entries
+-- stay readable after the bookmark is deleted; detail holds event-specific
+-- text such as an archive error or an import summary.
+
+CREATE TABLE IF NOT EXISTS activity_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    bookmark_id INTEGER,
+    bookmark_url TEXT NOT NULL DEFAULT '',
+    bookmark_title TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_kind ON activity_log(kind, id);
@@ -0,0 +1,12 @@
+-- API tokens for third-party apps. Only the SHA-256 of each token is kept;
+-- the token itself is shown once when it is created. quota is the number of
+-- requests per hour the token may make, 0 for the instance default.
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    quota INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL,
+    last_used_at TEXT
+);
@@ -0,0 +1,16 @@
+-- Webhooks POST a signed JSON payload to a URL when DB events happen.
+-- events is a comma-separated list of event names (see EventKind.String);
+-- empty means every event. secret keys the HMAC-SHA256 signature. The
+-- last_* columns record the outcome of the most recent delivery.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '',
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL,
+    last_delivery_at TEXT,
+    last_status INTEGER,
+    last_error TEXT
+);
@@ -0,0 +1,5 @@
+-- What an API token may do. '' allows the whole API; 'bookmarklet' only
+-- allows saving bookmarks through the bookmarklet, so a generated bookmarklet
+-- can't be used to read or change anything else.
+
+ALTER TABLE api_tokens ADD COLUMN scope TEXT NOT NULL DEFAULT '';
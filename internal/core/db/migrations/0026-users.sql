@@ -0,0 +1,20 @@
+-- Accounts. Until now the instance had one implicit user, LocalUserID (1);
+-- it becomes the first admin and keeps every existing bookmark and API
+-- token. password_hash is empty until a password is set, and a user without
+-- one can't log in.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL DEFAULT '',
+    is_admin INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+
+INSERT INTO users (id, username, is_admin, created_at)
+VALUES (1, 'admin', 1, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+
+ALTER TABLE bookmarks ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1 REFERENCES users (id);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user ON bookmarks (user_id, created_at);
+
+ALTER TABLE api_tokens ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1 REFERENCES users (id);
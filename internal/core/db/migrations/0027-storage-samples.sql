@@ -0,0 +1,14 @@
+-- Disk usage measured at each maintenance run (see core.RecordStorageSample),
+-- so growth can be charted and forecast. archive_store_bytes covers archives
+-- kept outside the database; free_bytes is -1 when the disk can't be queried.
+
+CREATE TABLE IF NOT EXISTS storage_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    taken_at TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    database_bytes INTEGER NOT NULL DEFAULT 0,
+    archive_store_bytes INTEGER NOT NULL DEFAULT 0,
+    free_bytes INTEGER NOT NULL DEFAULT -1
+);
+
+CREATE INDEX IF NOT EXISTS idx_storage_samples_taken_at ON storage_samples(taken_at);
@@ -0,0 +1,10 @@
+-- File a version captured when its URL started a download instead of opening
+-- a page (e.g. a direct link to a .zip or .docx). download_hash is the
+-- file's key in the blob store, like screenshot_hash; the version's HTML is a
+-- short page describing the file. download_mime_type is detected from the
+-- file name or content, and download_size is in bytes.
+
+ALTER TABLE bookmark_archives ADD COLUMN download_hash TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN download_filename TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN download_mime_type TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN download_size INTEGER;
@@ -0,0 +1,11 @@
+-- The tokenizer bookmark_search is built with (see db.SearchTokenizer), in
+-- a single row. The 0029 data migration rebuilds the index and its triggers
+-- with unicode61's diacritics removal for every script; `bookmarkd reindex
+-- --tokenizer` switches to another one.
+
+CREATE TABLE IF NOT EXISTS search_settings (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    tokenizer TEXT NOT NULL
+);
+
+INSERT INTO search_settings (id, tokenizer) VALUES (1, 'unicode61');
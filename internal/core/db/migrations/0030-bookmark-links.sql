@@ -0,0 +1,16 @@
+-- Outbound links found in each bookmark's latest archived page (see
+-- core.ExtractLinks), for "which of my pages link to this URL/domain"
+-- queries and the bookmark graph. url_key is the URL reduced to what
+-- identifies the page (core.LinkKey) and host is the lowercase host without
+-- "www.". Each archive replaces the bookmark's links.
+
+CREATE TABLE IF NOT EXISTS bookmark_links (
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    url_key TEXT NOT NULL,
+    host TEXT NOT NULL,
+    PRIMARY KEY (bookmark_id, url_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_links_url_key ON bookmark_links(url_key);
+CREATE INDEX IF NOT EXISTS idx_bookmark_links_host ON bookmark_links(host);
@@ -0,0 +1,8 @@
+-- A readable, URL-safe name for each bookmark (see db.Slugify), such as
+-- 2024-06-01-how-sqlite-works, used in share URLs, export paths and
+-- downloaded file names. Slugs are unique per user; the 0031 data migration
+-- gives existing bookmarks one.
+
+ALTER TABLE bookmarks ADD COLUMN slug TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_slug ON bookmarks(user_id, slug);
@@ -0,0 +1,16 @@
+-- Progress of imports in flight (see core.ImportBookmarks), so an import cut
+-- short by a crash or restart resumes where it stopped when the same export
+-- is imported again. import_key identifies the export and its options,
+-- position counts the entries already handled and result is the
+-- core.ImportResult so far, as JSON. Rows are deleted when an import ends.
+
+CREATE TABLE IF NOT EXISTS import_checkpoints (
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    import_key TEXT NOT NULL,
+    source TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    total INTEGER NOT NULL,
+    result TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (user_id, import_key)
+);
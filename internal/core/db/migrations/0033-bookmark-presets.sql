@@ -0,0 +1,18 @@
+-- Quick-save presets (see db.BookmarkPreset): named sets of tags, a
+-- collection and archive options a user can pick when saving a bookmark,
+-- such as "Recipe" or "Paper". tags is a JSON array of normalized tags; the
+-- capture columns are NULL to keep the user's archive preferences.
+
+CREATE TABLE IF NOT EXISTS bookmark_presets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    tags TEXT NOT NULL DEFAULT '[]',
+    collection TEXT,
+    skip_archive INTEGER NOT NULL DEFAULT 0,
+    strip_scripts INTEGER,
+    mobile_viewport INTEGER,
+    screenshot INTEGER,
+    created_at TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
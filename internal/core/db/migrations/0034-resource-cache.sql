@@ -0,0 +1,23 @@
+-- Resource cache (see db.CachedResource): page resources the inliner
+-- fetched, so stylesheets, scripts, fonts and images shared by many pages
+-- (CDN assets) aren't downloaded again for every archive. Bodies are stored
+-- once per SHA-256 in resource_cache_blobs, however many URLs serve them;
+-- resource_cache maps each URL to its latest body. The cache is
+-- instance-wide and pruned least recently used first.
+
+CREATE TABLE IF NOT EXISTS resource_cache_blobs (
+    hash TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    size INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS resource_cache (
+    url TEXT PRIMARY KEY,
+    hash TEXT NOT NULL REFERENCES resource_cache_blobs (hash),
+    content_type TEXT NOT NULL DEFAULT '',
+    fetched_at TEXT NOT NULL,
+    used_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_cache_hash ON resource_cache (hash);
+CREATE INDEX IF NOT EXISTS idx_resource_cache_used_at ON resource_cache (used_at);
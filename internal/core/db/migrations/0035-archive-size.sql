@@ -0,0 +1,7 @@
+-- Archive sizes in bytes: html_size is the archived HTML uncompressed (with
+-- its resources inlined) and screenshot_size the screenshot, alongside the
+-- existing download_size. A version's size is their sum; versions saved
+-- before this migration have no html_size, so their size is unknown.
+
+ALTER TABLE bookmark_archives ADD COLUMN html_size INTEGER;
+ALTER TABLE bookmark_archives ADD COLUMN screenshot_size INTEGER;
@@ -0,0 +1,8 @@
+-- Jobs may now also be 'deferred': accepted while the queue was saturated,
+-- and moved to 'queued' once there is room. A deferred job is still the
+-- bookmark's pending job, so the uniqueness index covers it too.
+
+DROP INDEX IF EXISTS idx_jobs_pending;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_pending
+    ON jobs(kind, bookmark_id) WHERE status IN ('queued', 'running', 'deferred');
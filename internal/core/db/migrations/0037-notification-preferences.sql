@@ -0,0 +1,12 @@
+-- Per-user notification preferences (see db.NotificationPreference): whether
+-- an event (an EventKind name, or '*' for every event) is sent over a
+-- channel. Events and channels without a row are sent.
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    event TEXT NOT NULL,
+    channel TEXT NOT NULL,
+    enabled INTEGER NOT NULL,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (user_id, event, channel)
+);
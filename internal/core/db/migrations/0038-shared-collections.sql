@@ -0,0 +1,12 @@
+-- Collections a user has shared publicly (see db.SharedCollection). token is
+-- the unguessable part of the public URLs; it stays the same while the
+-- collection is shared, so embeds keep working.
+
+CREATE TABLE IF NOT EXISTS shared_collections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    collection TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL,
+    UNIQUE (user_id, collection)
+);
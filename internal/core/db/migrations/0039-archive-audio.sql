@@ -0,0 +1,6 @@
+-- Spoken versions of archived articles (see db.ArchiveAudio), kept in the
+-- blob store like screenshots and downloads.
+
+ALTER TABLE bookmark_archives ADD COLUMN audio_hash TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN audio_mime_type TEXT;
+ALTER TABLE bookmark_archives ADD COLUMN audio_size INTEGER;
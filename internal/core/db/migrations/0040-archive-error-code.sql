@@ -0,0 +1,36 @@
+-- archive_error_code classifies a failed or skipped archive (core.ClassifyArchiveError):
+-- timeout, dns, tls, network, http-4xx, http-5xx, blocked, chrome-crash or
+-- other, so failures can be grouped and retried by kind. archive_error keeps
+-- the message. Earlier failures are classified from their message as well
+-- as text allows.
+
+ALTER TABLE bookmarks ADD COLUMN archive_error_code TEXT;
+
+UPDATE bookmarks
+SET archive_error_code = CASE
+    WHEN archive_status = 'skipped'
+        OR archive_error LIKE '%domain blocked%'
+        OR archive_error LIKE '%internal address%'
+        OR archive_error LIKE '%internal URL%'
+        OR archive_error LIKE '%net::ERR_BLOCKED_BY_%' THEN 'blocked'
+    WHEN archive_error LIKE 'HTTP 408 %' THEN 'timeout'
+    WHEN archive_error LIKE 'HTTP 4__ %' THEN 'http-4xx'
+    WHEN archive_error LIKE 'HTTP 5__ %' THEN 'http-5xx'
+    WHEN archive_error LIKE '%no such host%'
+        OR archive_error LIKE '%net::ERR_NAME_%' THEN 'dns'
+    WHEN archive_error LIKE '%x509:%'
+        OR archive_error LIKE '%tls:%'
+        OR archive_error LIKE '%net::ERR_CERT_%'
+        OR archive_error LIKE '%net::ERR_SSL_%' THEN 'tls'
+    WHEN archive_error LIKE '%deadline exceeded%'
+        OR archive_error LIKE '%timeout%'
+        OR archive_error LIKE '%net::ERR_TIMED_OUT%'
+        OR archive_error LIKE '%net::ERR_CONNECTION_TIMED_OUT%' THEN 'timeout'
+    WHEN archive_error LIKE '%connection refused%'
+        OR archive_error LIKE '%connection reset%'
+        OR archive_error LIKE '%net::ERR_CONNECTION_%' THEN 'network'
+    WHEN archive_error LIKE '%chrome failed to start%'
+        OR archive_error LIKE '%websocket%' THEN 'chrome-crash'
+    ELSE 'other'
+END
+WHERE archive_status IN ('error', 'skipped');
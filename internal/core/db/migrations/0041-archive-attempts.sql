@@ -0,0 +1,19 @@
+-- Each run of a bookmark's archive job (see db.ArchiveAttempt), so failures
+-- and the retries the queue scheduled can be reviewed after the job is done.
+-- status is 'ok', 'error' or 'skipped'; next_attempt_at is set when the job
+-- was retried. Only the latest attempts per bookmark are kept.
+
+CREATE TABLE IF NOT EXISTS archive_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    job_id INTEGER,
+    attempt INTEGER NOT NULL,
+    started_at TEXT NOT NULL,
+    finished_at TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error_code TEXT,
+    error TEXT,
+    next_attempt_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_attempts_bookmark ON archive_attempts(bookmark_id, id);
@@ -0,0 +1,20 @@
+-- The landing page (see db.LandingLayout). bookmarks.pinned_at is when a
+-- bookmark was pinned to it, NULL if it isn't; pinned_collections are the
+-- collections a user pinned there, and landing_layouts the widgets each
+-- user shows, in order. Users without a layout get the default one.
+
+ALTER TABLE bookmarks ADD COLUMN pinned_at TEXT;
+
+CREATE TABLE IF NOT EXISTS pinned_collections (
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    collection TEXT NOT NULL,
+    pinned_at TEXT NOT NULL,
+    PRIMARY KEY (user_id, collection)
+);
+
+CREATE TABLE IF NOT EXISTS landing_layouts (
+    user_id INTEGER PRIMARY KEY REFERENCES users (id),
+    widgets TEXT NOT NULL,
+    list_size INTEGER NOT NULL,
+    updated_at TEXT NOT NULL
+);
@@ -0,0 +1,5 @@
+-- wayback_url is the Internet Archive snapshot of a bookmark's page that
+-- bookmarkd found when archiving it failed, or got back when submitting the
+-- page to the Wayback Machine (see core.WaybackClient).
+
+ALTER TABLE bookmarks ADD COLUMN wayback_url TEXT;
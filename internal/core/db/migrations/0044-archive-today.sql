@@ -0,0 +1,6 @@
+-- archive_today_url is the archive.today snapshot bookmarkd got back when it
+-- submitted the bookmark's page there (see core.ArchiveTodayClient), and
+-- archive_today_disabled keeps the bookmark from being submitted.
+
+ALTER TABLE bookmarks ADD COLUMN archive_today_url TEXT;
+ALTER TABLE bookmarks ADD COLUMN archive_today_disabled INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Weekly unread triage (see db.TriageBookmark). bookmarks.triage_week is the
+-- ISO week (e.g. "2026-W42") a bookmark was last triaged in, so it isn't
+-- served again that week; triage_sessions count what each user decided in
+-- each week's triage.
+
+ALTER TABLE bookmarks ADD COLUMN triage_week TEXT;
+
+CREATE TABLE IF NOT EXISTS triage_sessions (
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    week TEXT NOT NULL,
+    started_at TEXT NOT NULL,
+    kept INTEGER NOT NULL DEFAULT 0,
+    later INTEGER NOT NULL DEFAULT 0,
+    archived INTEGER NOT NULL DEFAULT 0,
+    deleted INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, week)
+);
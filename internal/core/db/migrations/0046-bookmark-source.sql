@@ -0,0 +1,6 @@
+-- source records how a bookmark was added: "web", "bookmarklet", "merge",
+-- or "api:<token name>" and "import:<file>" (see db.BookmarkSource). It is
+-- NULL for bookmarks saved before sources were recorded.
+
+ALTER TABLE bookmarks ADD COLUMN source TEXT;
+CREATE INDEX IF NOT EXISTS idx_bookmarks_source ON bookmarks (source);
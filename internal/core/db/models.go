@@ -6,6 +6,104 @@ type Bookmark struct {
 	Title string
 	// CreatedAt is stored in the DB as RFC3339 text.
 	CreatedAt string
+	// Slug names the bookmark in URLs and file names; see Slugify.
+	Slug string
+}
+
+// BookmarkFlags are the read-later flags a user sets on a bookmark.
+type BookmarkFlags struct {
+	IsRead     bool
+	IsFavorite bool
+	// IsPinned is set for bookmarks pinned to the landing page.
+	IsPinned bool
+}
+
+// BookmarkFilter narrows ListFilteredBookmarks; the zero value matches
+// every bookmark.
+type BookmarkFilter struct {
+	// UnreadOnly keeps bookmarks not marked read.
+	UnreadOnly bool
+	// FavoritesOnly keeps favorite bookmarks.
+	FavoritesOnly bool
+	// ReadOnly keeps bookmarks marked read.
+	ReadOnly bool
+	// Domain, if set, keeps bookmarks on this host, as JobHost gives it
+	// (lowercase, without "www."); subdomains don't match.
+	Domain string
+	// Tag, if set, keeps bookmarks with this tag (see NormalizeTag).
+	Tag string
+	// ArchiveStatus, if set, keeps bookmarks whose archive status is this
+	// ("ok", "error" or "skipped"), or NotArchived for bookmarks without
+	// one.
+	ArchiveStatus string
+	// Source, if set, keeps bookmarks added this way (see BookmarkSource);
+	// a bare kind such as SourceAPI keeps every API token's.
+	Source string
+	// Sort orders ListFilteredBookmarks by one of BookmarkSorts, SortCreated
+	// if empty.
+	Sort string
+	// Order is SortAsc or SortDesc; empty uses the sort's default, newest
+	// first for SortCreated and A to Z for the others.
+	Order string
+}
+
+// Bookmark list sorts, for BookmarkFilter.Sort.
+const (
+	// SortCreated orders bookmarks by when they were saved.
+	SortCreated = "created"
+	// SortTitle orders bookmarks by title, or URL for untitled ones.
+	SortTitle = "title"
+	// SortDomain orders bookmarks by host, newest first within a host.
+	SortDomain = "domain"
+)
+
+// BookmarkSorts lists the sorts BookmarkFilter.Sort accepts.
+var BookmarkSorts = []string{SortCreated, SortTitle, SortDomain}
+
+// Sort directions, for BookmarkFilter.Order.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// NotArchived is the BookmarkFilter.ArchiveStatus of bookmarks never
+// archived.
+const NotArchived = "none"
+
+// TagCount is a tag and how many of the user's bookmarks have it; see
+// ListTags.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// SourceCount is a bookmark source and how many of the user's bookmarks
+// were added that way; see ListBookmarkSources.
+type SourceCount struct {
+	Source string
+	Count  int
+}
+
+// DomainCount is a domain with how many of the user's bookmarks are from
+// it; see ListDomains.
+type DomainCount struct {
+	// Domain is the host, as JobHost gives it.
+	Domain string
+	Count  int
+	// LastAddedAt is when the newest of its bookmarks was saved (RFC3339).
+	LastAddedAt string
+}
+
+// DomainGroup counts the bookmarks saved from one domain; see
+// ListDomainGroups.
+type DomainGroup struct {
+	// Domain is the host, as JobHost gives it.
+	Domain   string
+	Count    int
+	Unread   int
+	Archived int
+	// LatestAt is when the newest of them was saved (RFC3339).
+	LatestAt string
 }
 
 type BookmarkArchive struct {
@@ -16,4 +114,521 @@ type BookmarkArchive struct {
 	ArchivedAt         string
 	ArchiveStatus      string
 	ArchiveError       string
+	// ArchiveErrorCode classifies ArchiveError; see core.ArchiveErrorCodes.
+	ArchiveErrorCode string
+	// RearchiveDisabled opts the bookmark out of scheduled re-archiving.
+	RearchiveDisabled bool
+	// Size is the latest version's size; see ArchiveVersion.Size.
+	Size int64
+	// WaybackURL is a Wayback Machine snapshot of the page, if one was
+	// found or submitted.
+	WaybackURL string
+	// ArchiveTodayURL is the page's archive.today snapshot, if it was
+	// submitted there.
+	ArchiveTodayURL string
+	// ArchiveTodayDisabled keeps the bookmark from being submitted to
+	// archive.today.
+	ArchiveTodayDisabled bool
+}
+
+// BookmarkReadable is the reader-mode extraction of a bookmark's archive.
+type BookmarkReadable struct {
+	BookmarkID int64
+	Title      string
+	Byline     string
+	// Content is sanitized article HTML.
+	Content string
+	// TextContent is the plain-text version of Content.
+	TextContent string
+}
+
+// ArchiveVersion is a single captured snapshot of a bookmark's page.
+// A bookmark accumulates one version per successful archive run.
+type ArchiveVersion struct {
+	ID          int64
+	BookmarkID  int64
+	CapturedAt  string
+	ArchivedURL string
+	// HasScreenshot reports whether a screenshot was captured with this version.
+	HasScreenshot bool
+	// HasProvenance reports whether a provenance record was saved with this version.
+	HasProvenance bool
+	// HasTimestamp reports whether an RFC 3161 timestamp was saved with this version.
+	HasTimestamp bool
+	// HasDownload reports whether this version is a downloaded file rather
+	// than a page; see GetArchiveDownload.
+	HasDownload bool
+	// HasAudio reports whether the version's article has been read aloud;
+	// see GetArchiveAudio.
+	HasAudio bool
+	// Size is the bytes of the version's HTML, screenshot, download and
+	// audio together, or 0 if it was saved before sizes were recorded.
+	Size int64
+	// ArchivedHTML is only populated when fetching a single version.
+	ArchivedHTML string
+}
+
+// NewArchiveVersion is a complete archive version for AddArchiveVersion.
+type NewArchiveVersion struct {
+	// CapturedAt is RFC3339 text.
+	CapturedAt  string
+	ArchivedURL string
+	HTML        string
+	Screenshot  []byte
+	// Download, if set, needs its Data.
+	Download *ArchiveDownload
+	// Provenance is the JSON provenance record, if any.
+	Provenance string
+	// Timestamp, if set, needs its ContentHash, Authority, TimestampedAt
+	// and Token.
+	Timestamp *ArchiveTimestamp
+}
+
+// ArchiveDownload describes the file a version downloaded.
+type ArchiveDownload struct {
+	Filename string
+	MIMEType string
+	// Size is in bytes.
+	Size int64
+	// Data is only populated by GetArchiveDownload.
+	Data []byte
+}
+
+// ArchiveAudio is a spoken version of an archived article, made by a
+// text-to-speech backend from its reader-mode text.
+type ArchiveAudio struct {
+	MIMEType string
+	// Size is in bytes.
+	Size int64
+	// Data is only populated by GetArchiveAudio.
+	Data []byte
+}
+
+// AudioEpisode is a bookmark whose latest archive version has audio, as
+// listed in a podcast feed.
+type AudioEpisode struct {
+	BookmarkID int64
+	VersionID  int64
+	URL        string
+	// Title is the article's reader-mode title, or else the bookmark's.
+	Title       string
+	Description string
+	CapturedAt  string
+	MIMEType    string
+	Size        int64
+}
+
+// BookmarkMetadata is page metadata fetched without a full archive.
+type BookmarkMetadata struct {
+	BookmarkID  int64
+	Title       string
+	Description string
+	// FaviconURL and ImageURL (og:image) are absolute URLs to the live site.
+	FaviconURL string
+	ImageURL   string
+	SiteName   string
+	// FetchedAt is stored in the DB as RFC3339 text.
+	FetchedAt string
+}
+
+// Job is a unit of background work in the persistent jobs table.
+type Job struct {
+	ID         int64
+	Kind       string
+	BookmarkID int64
+	Status     string
+	// Attempts counts how many times the job has been claimed, including
+	// the current run.
+	Attempts int
+	// NextAttemptAt, CreatedAt and UpdatedAt are stored as UTC RFC3339 text.
+	NextAttemptAt string
+	LastError     string
+	// Options holds settings resolved at enqueue time (JSON owned by the
+	// job's runner); empty means resolve them when the job runs.
+	Options   string
+	CreatedAt string
+	UpdatedAt string
+}
+
+// ArchiveAttempt is one run of a bookmark's archive job.
+type ArchiveAttempt struct {
+	ID         int64
+	BookmarkID int64
+	// JobID is the job that made the attempt, and Attempt its number
+	// within that job.
+	JobID   int64
+	Attempt int
+	// StartedAt, FinishedAt and NextAttemptAt are stored as UTC RFC3339
+	// text; NextAttemptAt is empty unless the job was retried.
+	StartedAt     string
+	FinishedAt    string
+	NextAttemptAt string
+	Status        string // "ok", "error" or "skipped"
+	ErrorCode     string
+	Error         string
+}
+
+// PendingJob is a job that hasn't finished yet, with its place in the queue.
+type PendingJob struct {
+	Job
+	// Ahead is how many jobs will be claimed before it.
+	Ahead int
+}
+
+// ArchiveStats summarises archiving progress for the archive dashboard.
+type ArchiveStats struct {
+	// Bookmark counts by archive state. A bookmark with a running archive
+	// job counts as InProgress regardless of its last result.
+	Pending    int
+	InProgress int
+	OK         int
+	Error      int
+	// Skipped bookmarks' sites asked not to be archived.
+	Skipped int
+	// ErrorsByCode splits Error by archive error code (core.ArchiveErrorCodes).
+	ErrorsByCode map[string]int
+	// Queued is the number of archive jobs due now; Retrying counts queued
+	// jobs waiting out a backoff; FailedJobs gave up after max attempts.
+	// Deferred jobs were accepted while the queue was saturated.
+	Queued     int
+	Retrying   int
+	FailedJobs int
+	Deferred   int
+	// AvgDurationSeconds is the mean time from attempt to successful archive.
+	AvgDurationSeconds float64
+	// Active lists archive jobs that are currently running.
+	Active []ActiveJob
+}
+
+// ActiveJob is a running job with its bookmark, for showing worker activity.
+type ActiveJob struct {
+	JobID      int64
+	BookmarkID int64
+	URL        string
+	Title      string
+	Attempts   int
+	// StartedAt is when the job was claimed, as UTC RFC3339 text.
+	StartedAt string
+}
+
+// ArchivePreferences are a user's defaults for archiving their bookmarks.
+// A nil field inherits the instance default.
+type ArchivePreferences struct {
+	UserID         int64
+	AutoArchive    *bool
+	StripScripts   *bool
+	MobileViewport *bool
+	Screenshot     *bool
+	// UpdatedAt is stored in the DB as RFC3339 text; empty if never saved.
+	UpdatedAt string
+}
+
+// CleanupRule expires old bookmarks: bookmarks older than OlderThanDays that
+// match its filters are deleted or tagged when the rule runs.
+type CleanupRule struct {
+	ID   int64
+	Name string
+	// Tag restricts the rule to bookmarks with this tag; empty matches all.
+	Tag string
+	// UnreadOnly restricts the rule to bookmarks whose archive was never opened.
+	UnreadOnly    bool
+	OlderThanDays int
+	// Action is CleanupActionDelete or CleanupActionTag.
+	Action string
+	// ActionTag is the tag added by CleanupActionTag.
+	ActionTag string
+	Enabled   bool
+	// CreatedAt and LastRunAt are stored as RFC3339 text.
+	CreatedAt string
+	LastRunAt string
+}
+
+// CleanupLogEntry records one action taken by a cleanup rule.
+type CleanupLogEntry struct {
+	ID       int64
+	RuleID   int64
+	RuleName string
+	// BookmarkURL and BookmarkTitle are copied so entries stay readable
+	// after the bookmark is deleted.
+	BookmarkID    int64
+	BookmarkURL   string
+	BookmarkTitle string
+	Action        string
+	CreatedAt     string
+}
+
+// RoutingRule tags, files or skips archiving for new bookmarks. It matches a
+// bookmark whose host is Domain (or a subdomain of it) or whose title
+// contains TitleContains; an empty condition never matches.
+type RoutingRule struct {
+	ID            int64
+	Name          string
+	Domain        string
+	TitleContains string
+	// AddTag, Collection and SkipArchive are the rule's actions; a rule has
+	// at least one.
+	AddTag      string
+	Collection  string
+	SkipArchive bool
+	Enabled     bool
+	// CreatedAt is stored as RFC3339 text.
+	CreatedAt string
+}
+
+// ArchiveOverrides change how a single bookmark is first archived; nil
+// fields keep its owner's archive preferences.
+type ArchiveOverrides struct {
+	StripScripts   *bool
+	MobileViewport *bool
+	Screenshot     *bool
+}
+
+// NotificationPreference says whether a user is notified of an event over
+// a channel (see NotificationChannels). Events and channels without one
+// are notified.
+type NotificationPreference struct {
+	UserID int64
+	// Event is an EventKind name, or NotifyAllEvents.
+	Event   string
+	Channel string
+	Enabled bool
+	// UpdatedAt is stored in the DB as RFC3339 text.
+	UpdatedAt string
+}
+
+// SharedCollection is a collection its owner has made public: anyone with
+// its token can read the bookmarks in it.
+type SharedCollection struct {
+	ID         int64
+	UserID     int64
+	Collection string
+	Token      string
+	// CreatedAt is stored in the DB as RFC3339 text.
+	CreatedAt string
+}
+
+// PinnedCollection is a collection a user pinned to their landing page.
+type PinnedCollection struct {
+	UserID     int64
+	Collection string
+	// Count is how many of the user's bookmarks are in the collection.
+	Count int
+	// PinnedAt is stored in the DB as RFC3339 text.
+	PinnedAt string
+}
+
+// LandingLayout is how a user's landing page is laid out.
+type LandingLayout struct {
+	UserID int64
+	// Widgets are the LandingWidgets shown, top to bottom.
+	Widgets []string
+	// ListSize is how many bookmarks each list on the page shows.
+	ListSize int
+	// UpdatedAt is stored in the DB as RFC3339 text; empty if never saved.
+	UpdatedAt string
+}
+
+// TriageSession is a user's unread triage for one week. It starts the first
+// time the user triages that week.
+type TriageSession struct {
+	UserID int64
+	// Week is the ISO week, e.g. "2026-W42"; see TriageWeek.
+	Week string
+	// StartedAt is stored in the DB as RFC3339 text; empty if the week's
+	// triage hasn't started.
+	StartedAt string
+	// How many bookmarks got each TriageAction.
+	Kept     int
+	Later    int
+	Archived int
+	Deleted  int
+	// Remaining counts the unread bookmarks not triaged this week.
+	Remaining int
+}
+
+// Done counts the bookmarks triaged this week.
+func (s TriageSession) Done() int {
+	return s.Kept + s.Later + s.Archived + s.Deleted
+}
+
+// BookmarkCounts are the numbers the landing page's stats widget shows.
+type BookmarkCounts struct {
+	Total     int
+	Unread    int
+	Favorites int
+	Pinned    int
+	// Archived counts bookmarks whose latest archive succeeded.
+	Archived int
+}
+
+// SharedBookmark is a bookmark as a shared collection shows it: only what
+// makes sense to publish, so no notes, archives or read state.
+type SharedBookmark struct {
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	// CreatedAt is stored in the DB as RFC3339 text.
+	CreatedAt string
+}
+
+// BookmarkPreset is a named set of choices a user can apply when saving a
+// bookmark, e.g. "Recipe" or "Paper" (see BookmarkPreset.Apply).
+type BookmarkPreset struct {
+	ID   int64
+	Name string
+	// Tags, Collection, SkipArchive and Archive are what the preset sets;
+	// a preset sets at least one of them.
+	Tags        []string
+	Collection  string
+	SkipArchive bool
+	Archive     ArchiveOverrides
+	// CreatedAt is stored as RFC3339 text.
+	CreatedAt string
+}
+
+// BookmarkFavicon is a bookmark's favicon image, downloaded so it can be
+// served locally.
+type BookmarkFavicon struct {
+	BookmarkID int64
+	// SourceURL is the live URL the icon was fetched from.
+	SourceURL   string
+	ContentType string
+	Data        []byte
+	// FetchedAt is stored as RFC3339 text.
+	FetchedAt string
+}
+
+// ArchiveTimestamp is an RFC 3161 timestamp of an archive version's content.
+type ArchiveTimestamp struct {
+	BookmarkID int64
+	VersionID  int64
+	// ContentHash is the hex SHA-256 of the version's HTML, which the token
+	// attests to.
+	ContentHash string
+	// Authority is the URL of the time-stamping authority.
+	Authority string
+	// TimestampedAt is the token's genTime as UTC RFC3339 text.
+	TimestampedAt string
+	// Token is the DER-encoded TimeStampToken.
+	Token []byte
+}
+
+// StorageSample is a measurement of disk usage taken at a maintenance run.
+type StorageSample struct {
+	ID int64
+	// TakenAt is stored as RFC3339 text.
+	TakenAt string
+	// Source names what took the sample, such as a cleanup run.
+	Source string
+	// DatabaseBytes is the size of the database, including any archives
+	// kept in it.
+	DatabaseBytes int64
+	// ArchiveStoreBytes is the size of archives kept outside the database,
+	// or 0 if they aren't or the store can't tell.
+	ArchiveStoreBytes int64
+	// FreeBytes is the free space on the database's disk, or -1 if unknown.
+	FreeBytes int64
+}
+
+// ActivityEntry is one event in the instance-wide activity log.
+type ActivityEntry struct {
+	ID int64
+	// Kind is one of the Activity* constants.
+	Kind string
+	// BookmarkID is 0 for entries not about one bookmark, such as imports.
+	// BookmarkURL and BookmarkTitle are copied so entries stay readable
+	// after the bookmark is deleted.
+	BookmarkID    int64
+	BookmarkURL   string
+	BookmarkTitle string
+	// Detail is an archive error or an import summary.
+	Detail string
+	// CreatedAt is stored as RFC3339 text.
+	CreatedAt string
+}
+
+// ActivityFilter narrows ListActivity; the zero value matches every entry.
+type ActivityFilter struct {
+	// Kinds keeps only entries of these kinds.
+	Kinds []string
+	// Before keeps only entries older than this entry ID, for paging.
+	Before int64
+}
+
+// APIToken is a token third-party apps send as "Authorization: Bearer".
+type APIToken struct {
+	ID   int64
+	Name string
+	// UserID owns the token; requests made with it act as that user.
+	UserID int64
+	// Scope limits what the token may do; see APITokenScopeBookmarklet.
+	// Empty allows the whole API.
+	Scope string
+	// Quota is the requests per hour the token may make; 0 uses the
+	// instance default.
+	Quota int
+	// CreatedAt and LastUsedAt are stored as RFC3339 text; LastUsedAt is
+	// empty until the token is first used.
+	CreatedAt  string
+	LastUsedAt string
+}
+
+// Webhook POSTs a signed JSON payload to URL when the events it subscribes
+// to happen.
+type Webhook struct {
+	ID  int64
+	URL string
+	// Secret keys the HMAC-SHA256 signature of each payload.
+	Secret string
+	// Events are the event names (see EventKind.String) it is sent for;
+	// empty means every event.
+	Events  []string
+	Enabled bool
+	// CreatedAt and LastDeliveryAt are stored as RFC3339 text.
+	CreatedAt string
+	// LastDeliveryAt, LastStatus (the HTTP status, 0 if there was no
+	// response) and LastError describe the most recent delivery.
+	LastDeliveryAt string
+	LastStatus     int
+	LastError      string
+}
+
+// User is an account with its own bookmarks. Admins can also manage
+// instance-wide settings such as routing rules and see the activity log.
+type User struct {
+	ID       int64
+	Username string
+	IsAdmin  bool
+	// HasPassword is false until a password is set; such users can't log in.
+	HasPassword bool
+	// CreatedAt is stored in the DB as RFC3339 text.
+	CreatedAt string
+}
+
+// ImportCheckpoint records how far an import has got, so it can resume.
+type ImportCheckpoint struct {
+	// Key identifies the export being imported and the import's options.
+	Key    string
+	Source string
+	// Position is the number of the export's Total entries already handled.
+	Position int
+	Total    int
+	// Result is the import's result so far, as JSON.
+	Result string
+	// UpdatedAt is stored as RFC3339 text.
+	UpdatedAt string
+}
+
+// CachedResource is a page resource (a stylesheet, script, font or image)
+// kept in the resource cache.
+type CachedResource struct {
+	URL         string
+	ContentType string
+	Data        []byte
+	// Hash is the hex SHA-256 of Data, which stores it once however many
+	// URLs serve it.
+	Hash string
+	// FetchedAt is stored as RFC3339 text.
+	FetchedAt string
 }
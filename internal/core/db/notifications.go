@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Notification channels. Each is delivered by a notifier registered with
+// core.NotificationDispatcher.
+const (
+	NotifyDigest  = "digest"
+	NotifyWebhook = "webhook"
+	NotifyPush    = "push"
+	NotifyEmail   = "email"
+)
+
+// NotificationChannels lists every notification channel.
+var NotificationChannels = []string{NotifyDigest, NotifyWebhook, NotifyPush, NotifyEmail}
+
+// NotifyAllEvents is the event of a NotificationPreference that applies to
+// every event without a preference of its own.
+const NotifyAllEvents = "*"
+
+// ErrInvalidNotificationPreference is returned when a notification
+// preference names an unknown event or channel.
+var ErrInvalidNotificationPreference = errors.New("invalid notification preference")
+
+// ValidateNotificationPreference checks that a preference names a known
+// event (or NotifyAllEvents) and channel. Both are normalized in place.
+func ValidateNotificationPreference(p *NotificationPreference) error {
+	p.Event = strings.ToLower(strings.TrimSpace(p.Event))
+	p.Channel = strings.ToLower(strings.TrimSpace(p.Channel))
+	if _, ok := ParseEventKind(p.Event); !ok && p.Event != NotifyAllEvents {
+		return fmt.Errorf("%w: unknown event %q", ErrInvalidNotificationPreference, p.Event)
+	}
+	if !slices.Contains(NotificationChannels, p.Channel) {
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidNotificationPreference, p.Channel)
+	}
+	return nil
+}
+
+// ListNotificationPreferences returns a user's notification preferences,
+// by event and channel.
+func (db *DB) ListNotificationPreferences(userID int64) ([]NotificationPreference, error) {
+	rows, err := db.db.Query(`
+		SELECT user_id, event, channel, enabled, updated_at
+		FROM notification_preferences
+		WHERE user_id = ?
+		ORDER BY event, channel
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification preferences: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var prefs []NotificationPreference
+	for rows.Next() {
+		var p NotificationPreference
+		if err := rows.Scan(&p.UserID, &p.Event, &p.Channel, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		prefs = append(prefs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreference validates and stores a notification preference,
+// replacing the user's previous one for its event and channel.
+func (db *DB) SetNotificationPreference(p NotificationPreference) error {
+	if err := ValidateNotificationPreference(&p); err != nil {
+		return err
+	}
+	if _, err := db.db.Exec(`
+		INSERT INTO notification_preferences (user_id, event, channel, enabled, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, event, channel) DO UPDATE SET
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`, p.UserID, p.Event, p.Channel, p.Enabled, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save notification preference: %w", err)
+	}
+	return nil
+}
+
+// ResetNotificationPreferences removes a user's notification preferences,
+// so they are notified of everything again.
+func (db *DB) ResetNotificationPreferences(userID int64) error {
+	if _, err := db.db.Exec(`DELETE FROM notification_preferences WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to reset notification preferences: %w", err)
+	}
+	return nil
+}
+
+// NotificationEnabled reports whether a user is notified of events of kind
+// over channel: their preference for the event if they have one, else
+// their NotifyAllEvents preference, else true.
+func (db *DB) NotificationEnabled(userID int64, kind EventKind, channel string) (bool, error) {
+	var enabled bool
+	err := db.db.QueryRow(`
+		SELECT enabled FROM notification_preferences
+		WHERE user_id = ? AND channel = ? AND event IN (?, ?)
+		ORDER BY event = ?
+		LIMIT 1
+	`, userID, channel, kind.String(), NotifyAllEvents, NotifyAllEvents).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get notification preference: %w", err)
+	}
+	return enabled, nil
+}
+
+// EventUserID returns the user an event concerns: the owner of its
+// bookmark, or who imported. It returns 0 if that's unknown, such as for a
+// bookmark deleted since.
+func (db *DB) EventUserID(event Event) (int64, error) {
+	var bookmarkID int64
+	switch ev := event.(type) {
+	case BookmarkCreatedEvent:
+		bookmarkID = ev.Bookmark.ID
+	case BookmarkUpdatedEvent:
+		bookmarkID = ev.Bookmark.ID
+	case BookmarkDeletedEvent:
+		return ev.UserID, nil
+	case ArchiveResultSavedEvent:
+		bookmarkID = ev.BookmarkID
+	case ArchiveClearedEvent:
+		bookmarkID = ev.BookmarkID
+	case ArchiveVisualChangeEvent:
+		bookmarkID = ev.BookmarkID
+	case ImportFinishedEvent:
+		return ev.UserID, nil
+	default:
+		return 0, nil
+	}
+	var userID int64
+	err := db.db.QueryRow(`SELECT user_id FROM bookmarks WHERE id = ?`, bookmarkID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get bookmark owner: %w", err)
+	}
+	return userID, nil
+}
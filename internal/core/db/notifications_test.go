@@ -0,0 +1,121 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	enabled := func(kind EventKind, channel string) bool {
+		t.Helper()
+		on, err := db.NotificationEnabled(LocalUserID, kind, channel)
+		if err != nil {
+			t.Fatalf("NotificationEnabled() error = %v", err)
+		}
+		return on
+	}
+
+	if !enabled(OnBookmarkCreatedEvent, NotifyWebhook) {
+		t.Error("expected notifications to be on without preferences")
+	}
+
+	for _, p := range []NotificationPreference{
+		{UserID: LocalUserID, Event: NotifyAllEvents, Channel: NotifyEmail, Enabled: false},
+		{UserID: LocalUserID, Event: " Import_Finished ", Channel: "EMAIL", Enabled: true},
+		{UserID: LocalUserID, Event: "bookmark_deleted", Channel: NotifyWebhook, Enabled: false},
+	} {
+		if err := db.SetNotificationPreference(p); err != nil {
+			t.Fatalf("SetNotificationPreference(%+v) error = %v", p, err)
+		}
+	}
+	if enabled(OnBookmarkCreatedEvent, NotifyEmail) {
+		t.Error("expected the all-events preference to turn email off")
+	}
+	if !enabled(OnImportFinishedEvent, NotifyEmail) {
+		t.Error("expected the event's own preference to win over the all-events one")
+	}
+	if enabled(OnBookmarkDeletedEvent, NotifyWebhook) || !enabled(OnBookmarkCreatedEvent, NotifyWebhook) {
+		t.Error("expected only deletions to be kept from webhooks")
+	}
+
+	// Setting one again replaces it.
+	if err := db.SetNotificationPreference(NotificationPreference{UserID: LocalUserID, Event: "bookmark_deleted", Channel: NotifyWebhook, Enabled: true}); err != nil {
+		t.Fatalf("SetNotificationPreference() error = %v", err)
+	}
+	prefs, err := db.ListNotificationPreferences(LocalUserID)
+	if err != nil {
+		t.Fatalf("ListNotificationPreferences() error = %v", err)
+	}
+	if len(prefs) != 3 || prefs[0].Event != NotifyAllEvents || prefs[1].Event != "bookmark_deleted" || !prefs[1].Enabled || prefs[2].Channel != NotifyEmail {
+		t.Errorf("unexpected preferences %+v", prefs)
+	}
+
+	for _, p := range []NotificationPreference{
+		{UserID: LocalUserID, Event: "bookmark_exploded", Channel: NotifyEmail},
+		{UserID: LocalUserID, Event: "bookmark_created", Channel: "pager"},
+	} {
+		if err := db.SetNotificationPreference(p); !errors.Is(err, ErrInvalidNotificationPreference) {
+			t.Errorf("SetNotificationPreference(%+v) error = %v, want ErrInvalidNotificationPreference", p, err)
+		}
+	}
+
+	if err := db.ResetNotificationPreferences(LocalUserID); err != nil {
+		t.Fatalf("ResetNotificationPreferences() error = %v", err)
+	}
+	if !enabled(OnBookmarkCreatedEvent, NotifyEmail) {
+		t.Error("expected reset preferences to notify again")
+	}
+}
+
+func TestEventUserID(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	var events []Event
+	for _, kind := range EventKinds {
+		db.RegisterEventListener(kind, func(event Event) error {
+			events = append(events, event)
+			return nil
+		})
+	}
+
+	bobs := db.ForUser(bob.ID)
+	id, err := bobs.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if got, err := db.EventUserID(events[0]); err != nil || got != bob.ID {
+		t.Errorf("EventUserID(%s) = %d, %v, want %d", events[0].Kind(), got, err, bob.ID)
+	}
+	if err := bobs.DeleteBookmark(id); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+	bobs.EmitImportFinished(ImportFinishedEvent{Source: "pocket"})
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	for _, ev := range events[1:] {
+		if got, err := db.EventUserID(ev); err != nil || got != bob.ID {
+			t.Errorf("EventUserID(%s) = %d, %v, want %d", ev.Kind(), got, err, bob.ID)
+		}
+	}
+
+	// The bookmark is gone, so events that only have its ID have no known
+	// user.
+	if got, err := db.EventUserID(events[0]); err != nil || got != 0 {
+		t.Errorf("EventUserID() = %d, %v for a deleted bookmark, want 0", got, err)
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LocalUserID is the first account, which migration 0026 created to own the
+// bookmarks saved before there were accounts. Unscoped handles create
+// bookmarks for it, and it acts for everyone while no login is required.
+const LocalUserID int64 = 1
+
+// GetArchivePreferences returns a user's archive defaults. A user who has
+// never saved preferences gets all fields nil (inherit instance defaults).
+func (db *DB) GetArchivePreferences(userID int64) (ArchivePreferences, error) {
+	p := ArchivePreferences{UserID: userID}
+	var autoArchive, stripScripts, mobileViewport, screenshot sql.NullBool
+	err := db.db.QueryRow(`
+		SELECT auto_archive, strip_scripts, mobile_viewport, screenshot, updated_at
+		FROM user_archive_preferences
+		WHERE user_id = ?
+	`, userID).Scan(&autoArchive, &stripScripts, &mobileViewport, &screenshot, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return p, nil
+		}
+		return ArchivePreferences{}, fmt.Errorf("failed to get archive preferences: %w", err)
+	}
+	p.AutoArchive = nullBoolPtr(autoArchive)
+	p.StripScripts = nullBoolPtr(stripScripts)
+	p.MobileViewport = nullBoolPtr(mobileViewport)
+	p.Screenshot = nullBoolPtr(screenshot)
+	return p, nil
+}
+
+// SaveArchivePreferences stores a user's archive defaults, replacing any
+// previous ones. Nil fields are stored as NULL and inherit instance defaults.
+func (db *DB) SaveArchivePreferences(p ArchivePreferences) error {
+	if _, err := db.db.Exec(`
+		INSERT INTO user_archive_preferences (user_id, auto_archive, strip_scripts, mobile_viewport, screenshot, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			auto_archive = excluded.auto_archive,
+			strip_scripts = excluded.strip_scripts,
+			mobile_viewport = excluded.mobile_viewport,
+			screenshot = excluded.screenshot,
+			updated_at = excluded.updated_at
+	`, p.UserID, boolPtrArg(p.AutoArchive), boolPtrArg(p.StripScripts), boolPtrArg(p.MobileViewport), boolPtrArg(p.Screenshot),
+		time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save archive preferences: %w", err)
+	}
+	return nil
+}
+
+func nullBoolPtr(b sql.NullBool) *bool {
+	if !b.Valid {
+		return nil
+	}
+	v := b.Bool
+	return &v
+}
+
+func boolPtrArg(b *bool) any {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
@@ -0,0 +1,73 @@
+package db
+
+import "testing"
+
+// TestArchivePreferences tests saving and loading per-user archive defaults.
+func TestArchivePreferences(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("unset preferences are nil", func(t *testing.T) {
+		p, err := db.GetArchivePreferences(LocalUserID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if p.UserID != LocalUserID || p.AutoArchive != nil || p.StripScripts != nil || p.MobileViewport != nil || p.Screenshot != nil {
+			t.Errorf("expected empty preferences, got %+v", p)
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		on, off := true, false
+		if err := db.SaveArchivePreferences(ArchivePreferences{UserID: LocalUserID, AutoArchive: &off, Screenshot: &on}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		p, err := db.GetArchivePreferences(LocalUserID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if p.AutoArchive == nil || *p.AutoArchive {
+			t.Errorf("expected auto_archive off, got %v", p.AutoArchive)
+		}
+		if p.Screenshot == nil || !*p.Screenshot {
+			t.Errorf("expected screenshot on, got %v", p.Screenshot)
+		}
+		if p.StripScripts != nil || p.MobileViewport != nil {
+			t.Errorf("expected unset fields to stay nil, got %+v", p)
+		}
+		if p.UpdatedAt == "" {
+			t.Error("expected updated_at to be set")
+		}
+	})
+
+	t.Run("save replaces previous preferences", func(t *testing.T) {
+		on := true
+		if err := db.SaveArchivePreferences(ArchivePreferences{UserID: LocalUserID, MobileViewport: &on}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		p, err := db.GetArchivePreferences(LocalUserID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if p.AutoArchive != nil || p.Screenshot != nil {
+			t.Errorf("expected cleared fields to be nil, got %+v", p)
+		}
+		if p.MobileViewport == nil || !*p.MobileViewport {
+			t.Errorf("expected mobile_viewport on, got %v", p.MobileViewport)
+		}
+	})
+
+	t.Run("users are independent", func(t *testing.T) {
+		p, err := db.GetArchivePreferences(LocalUserID + 1)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if p.MobileViewport != nil {
+			t.Errorf("expected other user to have no preferences, got %+v", p)
+		}
+	})
+}
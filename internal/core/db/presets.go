@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPreset is returned when a preset is missing a name or doesn't
+// set anything, or its name is already in use.
+var ErrInvalidPreset = errors.New("invalid preset")
+
+// ValidateBookmarkPreset checks that a preset has a name and sets a tag,
+// collection or archive option. Fields are normalized in place.
+func ValidateBookmarkPreset(p *BookmarkPreset) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Tags = normalizeTags(p.Tags)
+	p.Collection = strings.TrimSpace(p.Collection)
+	if p.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidPreset)
+	}
+	if len(p.Tags) == 0 && p.Collection == "" && !p.SkipArchive && p.Archive == (ArchiveOverrides{}) {
+		return fmt.Errorf("%w: needs a tag, collection or archive option", ErrInvalidPreset)
+	}
+	return nil
+}
+
+// Apply returns nb with the preset's choices added: its tags come before
+// nb's own, and its collection and archive options fill in those nb leaves
+// unset. SkipArchive is set if either sets it.
+func (p BookmarkPreset) Apply(nb NewBookmark) NewBookmark {
+	nb.Tags = append(append([]string{}, p.Tags...), nb.Tags...)
+	if strings.TrimSpace(nb.Collection) == "" {
+		nb.Collection = p.Collection
+	}
+	nb.SkipArchive = nb.SkipArchive || p.SkipArchive
+	for _, f := range []struct{ dst, src **bool }{
+		{&nb.Archive.StripScripts, &p.Archive.StripScripts},
+		{&nb.Archive.MobileViewport, &p.Archive.MobileViewport},
+		{&nb.Archive.Screenshot, &p.Archive.Screenshot},
+	} {
+		if *f.dst == nil {
+			*f.dst = *f.src
+		}
+	}
+	return nb
+}
+
+const bookmarkPresetColumns = `id, name, tags, COALESCE(collection, ''), skip_archive, strip_scripts, mobile_viewport, screenshot, created_at`
+
+func scanBookmarkPreset(row interface{ Scan(...any) error }) (BookmarkPreset, error) {
+	var p BookmarkPreset
+	var tags string
+	var stripScripts, mobileViewport, screenshot sql.NullBool
+	if err := row.Scan(&p.ID, &p.Name, &tags, &p.Collection, &p.SkipArchive,
+		&stripScripts, &mobileViewport, &screenshot, &p.CreatedAt); err != nil {
+		return BookmarkPreset{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &p.Tags); err != nil {
+		return BookmarkPreset{}, fmt.Errorf("failed to decode preset tags: %w", err)
+	}
+	p.Archive = ArchiveOverrides{
+		StripScripts:   nullBoolPtr(stripScripts),
+		MobileViewport: nullBoolPtr(mobileViewport),
+		Screenshot:     nullBoolPtr(screenshot),
+	}
+	return p, nil
+}
+
+// CreateBookmarkPreset validates and stores a new preset for the handle's
+// user, returning its ID. A user's presets have unique names.
+func (db *DB) CreateBookmarkPreset(p BookmarkPreset) (int64, error) {
+	if err := ValidateBookmarkPreset(&p); err != nil {
+		return 0, err
+	}
+	userID := db.actingUserID()
+	var taken bool
+	if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM bookmark_presets WHERE user_id = ? AND name = ?)`, userID, p.Name).Scan(&taken); err != nil {
+		return 0, fmt.Errorf("failed to check preset name: %w", err)
+	}
+	if taken {
+		return 0, fmt.Errorf("%w: a preset named %q already exists", ErrInvalidPreset, p.Name)
+	}
+	tags, err := json.Marshal(append([]string{}, p.Tags...))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode preset tags: %w", err)
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO bookmark_presets (user_id, name, tags, collection, skip_archive, strip_scripts, mobile_viewport, screenshot, created_at)
+		VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?)
+	`, userID, p.Name, string(tags), p.Collection, p.SkipArchive,
+		boolPtrArg(p.Archive.StripScripts), boolPtrArg(p.Archive.MobileViewport), boolPtrArg(p.Archive.Screenshot),
+		time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create preset: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return id, nil
+}
+
+// GetBookmarkPreset returns one of the handle's user's presets by ID.
+func (db *DB) GetBookmarkPreset(id int64) (BookmarkPreset, error) {
+	p, err := scanBookmarkPreset(db.db.QueryRow(`SELECT `+bookmarkPresetColumns+` FROM bookmark_presets WHERE id = ? AND user_id = ?`,
+		id, db.actingUserID()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BookmarkPreset{}, fmt.Errorf("preset not found: %d", id)
+		}
+		return BookmarkPreset{}, fmt.Errorf("failed to get preset: %w", err)
+	}
+	return p, nil
+}
+
+// ListBookmarkPresets returns the handle's user's presets by name.
+func (db *DB) ListBookmarkPresets() ([]BookmarkPreset, error) {
+	rows, err := db.db.Query(`SELECT `+bookmarkPresetColumns+` FROM bookmark_presets WHERE user_id = ? ORDER BY name COLLATE NOCASE, id`,
+		db.actingUserID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presets: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var presets []BookmarkPreset
+	for rows.Next() {
+		p, err := scanBookmarkPreset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preset: %w", err)
+		}
+		presets = append(presets, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate presets: %w", err)
+	}
+	return presets, nil
+}
+
+// DeleteBookmarkPreset removes one of the handle's user's presets. Bookmarks
+// saved with it keep their tags and collection.
+func (db *DB) DeleteBookmarkPreset(id int64) error {
+	res, err := db.db.Exec(`DELETE FROM bookmark_presets WHERE id = ? AND user_id = ?`, id, db.actingUserID())
+	if err != nil {
+		return fmt.Errorf("failed to delete preset: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("preset not found: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBookmarkPresets(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	on := true
+	id, err := db.CreateBookmarkPreset(BookmarkPreset{
+		Name:       " Recipe ",
+		Tags:       []string{"#Cooking", "food", "cooking"},
+		Collection: "Kitchen",
+		Archive:    ArchiveOverrides{Screenshot: &on},
+	})
+	if err != nil {
+		t.Fatalf("failed to create preset: %v", err)
+	}
+	p, err := db.GetBookmarkPreset(id)
+	if err != nil {
+		t.Fatalf("failed to get preset: %v", err)
+	}
+	if p.Name != "Recipe" || len(p.Tags) != 2 || p.Tags[0] != "cooking" || p.Collection != "Kitchen" ||
+		p.Archive.Screenshot == nil || !*p.Archive.Screenshot || p.Archive.StripScripts != nil {
+		t.Errorf("unexpected preset %+v", p)
+	}
+
+	for _, bad := range []BookmarkPreset{
+		{Name: "", Tags: []string{"x"}},
+		{Name: "Empty"},
+		{Name: "Recipe", SkipArchive: true},
+	} {
+		if _, err := db.CreateBookmarkPreset(bad); !errors.Is(err, ErrInvalidPreset) {
+			t.Errorf("CreateBookmarkPreset(%+v): expected ErrInvalidPreset, got %v", bad, err)
+		}
+	}
+
+	t.Run("users have their own presets", func(t *testing.T) {
+		user, err := db.CreateUser("presets", "correct horse battery", false)
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		theirs := db.ForUser(user.ID)
+		if presets, err := theirs.ListBookmarkPresets(); err != nil || len(presets) != 0 {
+			t.Errorf("expected no presets for another user, got %v (err=%v)", presets, err)
+		}
+		if _, err := theirs.GetBookmarkPreset(id); err == nil {
+			t.Error("expected another user not to get the preset")
+		}
+		if err := theirs.DeleteBookmarkPreset(id); err == nil {
+			t.Error("expected another user not to delete the preset")
+		}
+		if _, err := theirs.CreateBookmarkPreset(BookmarkPreset{Name: "Recipe", Tags: []string{"mine"}}); err != nil {
+			t.Errorf("expected another user to reuse the name, got %v", err)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		off := false
+		nb := p.Apply(NewBookmark{URL: "https://example.com", Tags: []string{"soup"}, Archive: ArchiveOverrides{Screenshot: &off}})
+		if len(nb.Tags) != 3 || nb.Tags[0] != "cooking" || nb.Tags[2] != "soup" {
+			t.Errorf("expected the preset's tags first, got %v", nb.Tags)
+		}
+		if nb.Collection != "Kitchen" || nb.SkipArchive {
+			t.Errorf("unexpected collection %q or skip %v", nb.Collection, nb.SkipArchive)
+		}
+		if nb.Archive.Screenshot == nil || *nb.Archive.Screenshot {
+			t.Error("expected the bookmark's own screenshot choice to win")
+		}
+		if nb = p.Apply(NewBookmark{Collection: "Mine"}); nb.Collection != "Mine" || nb.Archive.Screenshot == nil || !*nb.Archive.Screenshot {
+			t.Errorf("unexpected bookmark %+v", nb)
+		}
+		if len(p.Tags) != 2 {
+			t.Errorf("expected Apply to leave the preset alone, got %v", p.Tags)
+		}
+	})
+
+	if err := db.DeleteBookmarkPreset(id); err != nil {
+		t.Fatalf("failed to delete preset: %v", err)
+	}
+	if presets, err := db.ListBookmarkPresets(); err != nil || len(presets) != 0 {
+		t.Errorf("expected no presets left, got %v (err=%v)", presets, err)
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ArchiveContentRef names the stored content of a snapshot, for checking
+// it is still there; see ListArchivedContent and ListArchiveContent.
+type ArchiveContentRef struct {
+	BookmarkID int64
+	URL        string
+	// VersionID is 0 when the bookmark has no snapshot at all.
+	VersionID int64
+	// Latest is set on a bookmark's most recent snapshot by
+	// ListArchiveContent.
+	Latest bool
+	// BlobHash is the snapshot's HTML blob, or "" if it has none.
+	BlobHash string
+	// HTMLSize is the size of the uncompressed HTML, or -1 for snapshots
+	// captured before sizes were recorded.
+	HTMLSize int64
+	// DownloadHash is the snapshot's downloaded file, if it has one.
+	DownloadHash string
+}
+
+// ListArchivedContent returns the latest snapshot of every bookmark whose
+// archive status is "ok", including bookmarks that have none.
+func (db *DB) ListArchivedContent() ([]ArchiveContentRef, error) {
+	rows, err := db.db.Query(`
+		SELECT b.id, b.url, COALESCE(a.id, 0), COALESCE(a.blob_hash, ''), COALESCE(a.html_size, -1), COALESCE(a.download_hash, '')
+		FROM bookmarks b
+		LEFT JOIN bookmark_archives a ON a.id = (
+			SELECT id FROM bookmark_archives
+			WHERE bookmark_id = b.id
+			ORDER BY captured_at DESC, id DESC
+			LIMIT 1
+		)
+		WHERE b.archive_status = 'ok' AND `+ownerFilter("b.user_id")+`
+		ORDER BY b.id
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived content: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var refs []ArchiveContentRef
+	for rows.Next() {
+		var r ArchiveContentRef
+		if err := rows.Scan(&r.BookmarkID, &r.URL, &r.VersionID, &r.BlobHash, &r.HTMLSize, &r.DownloadHash); err != nil {
+			return nil, fmt.Errorf("failed to scan archived content: %w", err)
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// ListOrphanJobs returns jobs whose bookmark no longer exists. Foreign keys
+// aren't enforced on our connections, so a bookmark deleted while its job
+// was being written, or by an older version, can leave one behind.
+func (db *DB) ListOrphanJobs() ([]Job, error) {
+	return db.listJobs(`
+		SELECT `+jobColumns+` FROM jobs
+		WHERE NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.id = jobs.bookmark_id)
+		ORDER BY id
+	`, "orphaned")
+}
+
+// DeleteOrphanJobs deletes the jobs ListOrphanJobs returns and reports how
+// many there were.
+func (db *DB) DeleteOrphanJobs() (int64, error) {
+	res, err := db.db.Exec(`
+		DELETE FROM jobs
+		WHERE NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.id = jobs.bookmark_id)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned jobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// ListStuckJobs returns jobs of the given kind that have been running since
+// before olderThan. RequeueRunningJobs only recovers jobs at startup, so a
+// worker that hangs, or a process killed while another keeps running,
+// leaves them running for good.
+func (db *DB) ListStuckJobs(kind string, olderThan time.Time) ([]Job, error) {
+	return db.listJobs(`
+		SELECT `+jobColumns+` FROM jobs
+		WHERE kind = ? AND status = ? AND updated_at < ?
+		ORDER BY id
+	`, "stuck", kind, JobStatusRunning, jobTime(olderThan))
+}
+
+// RequeueStuckJobs returns the jobs ListStuckJobs reports to the queue, due
+// immediately, and reports how many there were.
+func (db *DB) RequeueStuckJobs(kind string, olderThan time.Time) (int64, error) {
+	now := jobTime(time.Now())
+	res, err := db.db.Exec(`
+		UPDATE jobs
+		SET status = ?, next_attempt_at = ?, updated_at = ?,
+			last_error = 'still running after '||updated_at||'; requeued by the consistency check'
+		WHERE kind = ? AND status = ? AND updated_at < ?
+	`, JobStatusQueued, now, now, kind, JobStatusRunning, jobTime(olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stuck jobs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	return n, nil
+}
+
+// listJobs runs a query selecting jobColumns; what names the jobs in errors.
+func (db *DB) listJobs(query, what string, args ...any) ([]Job, error) {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s jobs: %w", what, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrphanAndStuckJobs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	kept, err := db.AddBookmark("https://kept.example.com", "")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, kept, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	if _, err := db.EnqueueJob(JobKindArchive, kept+100, ""); err != nil {
+		t.Fatalf("failed to enqueue orphaned job: %v", err)
+	}
+
+	orphans, err := db.ListOrphanJobs()
+	if err != nil || len(orphans) != 1 || orphans[0].BookmarkID != kept+100 {
+		t.Fatalf("ListOrphanJobs() = %+v, %v, want the job of the missing bookmark", orphans, err)
+	}
+	if n, err := db.DeleteOrphanJobs(); err != nil || n != 1 {
+		t.Errorf("DeleteOrphanJobs() = %d, %v, want 1", n, err)
+	}
+	if orphans, _ := db.ListOrphanJobs(); len(orphans) != 0 {
+		t.Errorf("expected no orphaned jobs left, got %+v", orphans)
+	}
+
+	if _, err := db.ClaimJob(JobKindArchive, time.Now()); err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+	if stuck, err := db.ListStuckJobs(JobKindArchive, time.Now().Add(-time.Hour)); err != nil || len(stuck) != 0 {
+		t.Errorf("expected a job claimed after the cutoff not to be stuck, got %+v (%v)", stuck, err)
+	}
+	cutoff := time.Now().Add(time.Hour)
+	if stuck, err := db.ListStuckJobs(JobKindArchive, cutoff); err != nil || len(stuck) != 1 {
+		t.Fatalf("ListStuckJobs() = %+v, %v, want 1 job", stuck, err)
+	}
+	if n, err := db.RequeueStuckJobs(JobKindArchive, cutoff); err != nil || n != 1 {
+		t.Errorf("RequeueStuckJobs() = %d, %v, want 1", n, err)
+	}
+	job, err := db.GetPendingJob(JobKindArchive, kept)
+	if err != nil || job.Status != JobStatusQueued || job.LastError == "" {
+		t.Errorf("expected the stuck job queued again with a note, got %+v (%v)", job, err)
+	}
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// resourceCacheLimit is how many bytes of resource bodies the resource cache
+// keeps; the least recently used URLs are dropped beyond it.
+const resourceCacheLimit = 256 * 1024 * 1024
+
+// GetCachedResource returns the cached copy of url, and false if there is
+// none or it was fetched more than maxAge ago. Using an entry keeps it from
+// being pruned.
+func (db *DB) GetCachedResource(url string, maxAge time.Duration) (CachedResource, bool, error) {
+	r := CachedResource{URL: url}
+	err := db.db.QueryRow(`
+		SELECT c.hash, c.content_type, c.fetched_at, b.data
+		FROM resource_cache c
+		JOIN resource_cache_blobs b ON b.hash = c.hash
+		WHERE c.url = ?
+	`, url).Scan(&r.Hash, &r.ContentType, &r.FetchedAt, &r.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CachedResource{}, false, nil
+	}
+	if err != nil {
+		return CachedResource{}, false, fmt.Errorf("failed to get cached resource: %w", err)
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, r.FetchedAt)
+	if err != nil || time.Since(fetchedAt) > maxAge {
+		return CachedResource{}, false, nil
+	}
+	if _, err := db.db.Exec(`UPDATE resource_cache SET used_at = ? WHERE url = ?`, time.Now().UTC().Format(time.RFC3339), url); err != nil {
+		return CachedResource{}, false, fmt.Errorf("failed to mark cached resource used: %w", err)
+	}
+	return r, true, nil
+}
+
+// PutCachedResource stores r.Data as the body of r.URL, fetched now, and
+// prunes the cache back to resourceCacheLimit. Hash and FetchedAt are set
+// here.
+func (db *DB) PutCachedResource(r CachedResource) error {
+	sum := sha256.Sum256(r.Data)
+	r.Hash = hex.EncodeToString(sum[:])
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO resource_cache_blobs (hash, data, size) VALUES (?, ?, ?)`,
+		r.Hash, r.Data, len(r.Data)); err != nil {
+		return fmt.Errorf("failed to store cached resource: %w", err)
+	}
+	var prev sql.NullString
+	if err := tx.QueryRow(`SELECT hash FROM resource_cache WHERE url = ?`, r.URL).Scan(&prev); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to look up cached resource: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO resource_cache (url, hash, content_type, fetched_at, used_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET
+			hash = excluded.hash,
+			content_type = excluded.content_type,
+			fetched_at = excluded.fetched_at,
+			used_at = excluded.used_at
+	`, r.URL, r.Hash, r.ContentType, now, now); err != nil {
+		return fmt.Errorf("failed to cache resource: %w", err)
+	}
+	if prev.Valid && prev.String != r.Hash {
+		if err := deleteOrphanResourceBlobs(tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return db.pruneResourceCache(resourceCacheLimit)
+}
+
+// ResourceCacheSize returns how many URLs the resource cache holds and the
+// bytes of the distinct bodies they share.
+func (db *DB) ResourceCacheSize() (int, int64, error) {
+	var urls int
+	var size int64
+	if err := db.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM resource_cache), (SELECT COALESCE(SUM(size), 0) FROM resource_cache_blobs)
+	`).Scan(&urls, &size); err != nil {
+		return 0, 0, fmt.Errorf("failed to get resource cache size: %w", err)
+	}
+	return urls, size, nil
+}
+
+// ClearResourceCache empties the resource cache.
+func (db *DB) ClearResourceCache() error {
+	if _, err := db.db.Exec(`DELETE FROM resource_cache`); err != nil {
+		return fmt.Errorf("failed to clear resource cache: %w", err)
+	}
+	if _, err := db.db.Exec(`DELETE FROM resource_cache_blobs`); err != nil {
+		return fmt.Errorf("failed to clear resource cache: %w", err)
+	}
+	return nil
+}
+
+// pruneResourceCache drops the least recently used URLs, and bodies no URL
+// serves any more, until the bodies add up to at most limit bytes.
+func (db *DB) pruneResourceCache(limit int64) error {
+	for {
+		_, size, err := db.ResourceCacheSize()
+		if err != nil {
+			return err
+		}
+		if size <= limit {
+			return nil
+		}
+		// Pruning runs after every insert, so it rarely has more than one
+		// URL to drop.
+		res, err := db.db.Exec(`
+			DELETE FROM resource_cache WHERE url IN (
+				SELECT url FROM resource_cache ORDER BY used_at, fetched_at LIMIT 1
+			)`)
+		if err != nil {
+			return fmt.Errorf("failed to prune resource cache: %w", err)
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to determine rows affected: %w", err)
+		}
+		if err := deleteOrphanResourceBlobs(db.db); err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return nil
+		}
+	}
+}
+
+// deleteOrphanResourceBlobs removes cached bodies no URL refers to.
+func deleteOrphanResourceBlobs(e execer) error {
+	if _, err := e.Exec(`
+		DELETE FROM resource_cache_blobs
+		WHERE NOT EXISTS (SELECT 1 FROM resource_cache c WHERE c.hash = resource_cache_blobs.hash)
+	`); err != nil {
+		return fmt.Errorf("failed to delete unused cached resources: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceCache(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	if _, ok, err := db.GetCachedResource("https://cdn.example/a.css", time.Hour); err != nil || ok {
+		t.Fatalf("expected a miss, got ok=%v err=%v", ok, err)
+	}
+
+	body := []byte("body { color: red }")
+	for _, url := range []string{"https://cdn.example/a.css", "https://mirror.example/a.css"} {
+		if err := db.PutCachedResource(CachedResource{URL: url, ContentType: "text/css", Data: body}); err != nil {
+			t.Fatalf("failed to cache resource: %v", err)
+		}
+	}
+	got, ok, err := db.GetCachedResource("https://mirror.example/a.css", time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("expected a hit, got ok=%v err=%v", ok, err)
+	}
+	if string(got.Data) != string(body) || got.ContentType != "text/css" || len(got.Hash) != 64 {
+		t.Errorf("unexpected cached resource %+v", got)
+	}
+	if urls, size, err := db.ResourceCacheSize(); err != nil || urls != 2 || size != int64(len(body)) {
+		t.Errorf("expected two URLs sharing one body, got %d URLs, %d bytes, %v", urls, size, err)
+	}
+	if _, ok, err := db.GetCachedResource("https://cdn.example/a.css", 0); err != nil || ok {
+		t.Errorf("expected an entry older than maxAge to miss, got ok=%v err=%v", ok, err)
+	}
+
+	t.Run("replacing a body drops the old one", func(t *testing.T) {
+		for _, url := range []string{"https://cdn.example/a.css", "https://mirror.example/a.css"} {
+			if err := db.PutCachedResource(CachedResource{URL: url, ContentType: "text/css", Data: []byte("v2")}); err != nil {
+				t.Fatalf("failed to cache resource: %v", err)
+			}
+		}
+		if urls, size, err := db.ResourceCacheSize(); err != nil || urls != 2 || size != 2 {
+			t.Errorf("expected only the new body, got %d URLs, %d bytes, %v", urls, size, err)
+		}
+	})
+
+	t.Run("prunes the least recently used", func(t *testing.T) {
+		if err := db.ClearResourceCache(); err != nil {
+			t.Fatalf("failed to clear cache: %v", err)
+		}
+		for _, name := range []string{"old", "new"} {
+			if err := db.PutCachedResource(CachedResource{URL: "https://cdn.example/" + name, Data: []byte(strings.Repeat(name, 10))}); err != nil {
+				t.Fatalf("failed to cache resource: %v", err)
+			}
+		}
+		if _, err := db.db.Exec(`UPDATE resource_cache SET used_at = '2000-01-01T00:00:00Z' WHERE url = 'https://cdn.example/old'`); err != nil {
+			t.Fatalf("failed to age entry: %v", err)
+		}
+		if err := db.pruneResourceCache(35); err != nil {
+			t.Fatalf("failed to prune: %v", err)
+		}
+		if _, ok, _ := db.GetCachedResource("https://cdn.example/old", time.Hour); ok {
+			t.Error("expected the least recently used entry to be pruned")
+		}
+		if _, ok, _ := db.GetCachedResource("https://cdn.example/new", time.Hour); !ok {
+			t.Error("expected the recently used entry to be kept")
+		}
+		if urls, size, err := db.ResourceCacheSize(); err != nil || urls != 1 || size != 30 {
+			t.Errorf("expected one entry left, got %d URLs, %d bytes, %v", urls, size, err)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRoutingRule is returned when a routing rule fails validation.
+var ErrInvalidRoutingRule = errors.New("invalid routing rule")
+
+// NormalizeRuleDomain cleans up a user-supplied rule domain: it is
+// lowercased and a scheme, leading "*." or "www." and anything after the
+// host are removed, so "https://www.Example.com/x" becomes "example.com".
+func NormalizeRuleDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.TrimPrefix(domain, "www.")
+	return strings.Trim(domain, ".")
+}
+
+// ValidateRoutingRule checks that a rule has a name, a condition and an
+// action. Fields are normalized in place.
+func ValidateRoutingRule(r *RoutingRule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Domain = NormalizeRuleDomain(r.Domain)
+	r.TitleContains = strings.TrimSpace(r.TitleContains)
+	r.AddTag = NormalizeTag(r.AddTag)
+	r.Collection = strings.TrimSpace(r.Collection)
+	if r.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidRoutingRule)
+	}
+	if strings.ContainsAny(r.Domain, " :@") {
+		return fmt.Errorf("%w: %q is not a domain", ErrInvalidRoutingRule, r.Domain)
+	}
+	if r.Domain == "" && r.TitleContains == "" {
+		return fmt.Errorf("%w: needs a domain or title to match", ErrInvalidRoutingRule)
+	}
+	if r.AddTag == "" && r.Collection == "" && !r.SkipArchive {
+		return fmt.Errorf("%w: needs a tag, collection or skip-archive action", ErrInvalidRoutingRule)
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a bookmark with the given URL
+// and title. Domains match the host and its subdomains; titles match
+// case-insensitively.
+func (r RoutingRule) Matches(rawURL, title string) bool {
+	if r.Domain != "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			host := strings.ToLower(u.Hostname())
+			if host == r.Domain || strings.HasSuffix(host, "."+r.Domain) {
+				return true
+			}
+		}
+	}
+	if r.TitleContains != "" && strings.Contains(strings.ToLower(title), strings.ToLower(r.TitleContains)) {
+		return true
+	}
+	return false
+}
+
+// Routing is the combined effect of the routing rules matching a bookmark.
+type Routing struct {
+	// Rules names the matching rules in the order they were applied.
+	Rules       []string
+	Tags        []string
+	Collection  string
+	SkipArchive bool
+}
+
+// RouteBookmark applies rules, in order, to a bookmark with the given URL and
+// title. Every matching rule adds its tag; the first matching rule with a
+// collection decides the collection; any matching rule can skip archiving.
+// Disabled rules are ignored.
+func RouteBookmark(rules []RoutingRule, rawURL, title string) Routing {
+	var route Routing
+	for _, r := range rules {
+		if !r.Enabled || !r.Matches(rawURL, title) {
+			continue
+		}
+		route.Rules = append(route.Rules, r.Name)
+		if r.AddTag != "" {
+			route.Tags = append(route.Tags, r.AddTag)
+		}
+		if route.Collection == "" {
+			route.Collection = r.Collection
+		}
+		route.SkipArchive = route.SkipArchive || r.SkipArchive
+	}
+	return route
+}
+
+const routingRuleColumns = `id, name, COALESCE(domain, ''), COALESCE(title_contains, ''), COALESCE(add_tag, ''), COALESCE(collection, ''), skip_archive, enabled, created_at`
+
+func scanRoutingRule(row interface{ Scan(...any) error }) (RoutingRule, error) {
+	var r RoutingRule
+	err := row.Scan(&r.ID, &r.Name, &r.Domain, &r.TitleContains, &r.AddTag, &r.Collection, &r.SkipArchive, &r.Enabled, &r.CreatedAt)
+	return r, err
+}
+
+// CreateRoutingRule validates and stores a new rule, returning its ID. Rule
+// names are unique.
+func (db *DB) CreateRoutingRule(r RoutingRule) (int64, error) {
+	if err := ValidateRoutingRule(&r); err != nil {
+		return 0, err
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO routing_rules (name, domain, title_contains, add_tag, collection, skip_archive, enabled, created_at)
+		VALUES (?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?)
+	`, r.Name, r.Domain, r.TitleContains, r.AddTag, r.Collection, r.SkipArchive, r.Enabled, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create routing rule: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return id, nil
+}
+
+// GetRoutingRule returns a single rule by ID.
+func (db *DB) GetRoutingRule(id int64) (RoutingRule, error) {
+	r, err := scanRoutingRule(db.db.QueryRow(`SELECT `+routingRuleColumns+` FROM routing_rules WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RoutingRule{}, fmt.Errorf("routing rule not found: %d", id)
+		}
+		return RoutingRule{}, fmt.Errorf("failed to get routing rule: %w", err)
+	}
+	return r, nil
+}
+
+// ListRoutingRules returns all rules in the order they are applied.
+func (db *DB) ListRoutingRules() ([]RoutingRule, error) {
+	rows, err := db.db.Query(`SELECT ` + routingRuleColumns + ` FROM routing_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routing rules: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var rules []RoutingRule
+	for rows.Next() {
+		r, err := scanRoutingRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate routing rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetRoutingRuleEnabled enables or disables a rule. Disabled rules are kept
+// but not applied to new bookmarks.
+func (db *DB) SetRoutingRuleEnabled(id int64, enabled bool) error {
+	return db.updateRoutingRule(id, `UPDATE routing_rules SET enabled = ? WHERE id = ?`, enabled, id)
+}
+
+// DeleteRoutingRule removes a rule. Bookmarks it already routed keep their
+// tags and collection.
+func (db *DB) DeleteRoutingRule(id int64) error {
+	return db.updateRoutingRule(id, `DELETE FROM routing_rules WHERE id = ?`, id)
+}
+
+func (db *DB) updateRoutingRule(id int64, query string, args ...any) error {
+	res, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update routing rule: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("routing rule not found: %d", id)
+	}
+	return nil
+}
+
+// GetBookmarkCollection returns the collection a bookmark is filed in, or ""
+// if it isn't in one.
+func (db *DB) GetBookmarkCollection(id int64) (string, error) {
+	var collection string
+	err := db.db.QueryRow(`SELECT COALESCE(collection, '') FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&collection)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("bookmark not found: %d", id)
+		}
+		return "", fmt.Errorf("failed to get bookmark collection: %w", err)
+	}
+	return collection, nil
+}
+
+// SetBookmarkCollection files a bookmark in a collection; "" removes it from
+// its collection.
+func (db *DB) SetBookmarkCollection(id int64, collection string) error {
+	res, err := db.db.Exec(`UPDATE bookmarks SET collection = NULLIF(?, '') WHERE id = ? AND `+ownerFilter("user_id"), append([]any{strings.TrimSpace(collection), id}, db.owner()...)...)
+	if err != nil {
+		return fmt.Errorf("failed to set bookmark collection: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("bookmark not found: %d", id)
+	}
+	return nil
+}
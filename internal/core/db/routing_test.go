@@ -0,0 +1,215 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestValidateRoutingRule tests routing rule validation.
+func TestValidateRoutingRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    RoutingRule
+		wantErr bool
+	}{
+		{"domain tag rule", RoutingRule{Name: "gh", Domain: "github.com", AddTag: "code"}, false},
+		{"title collection rule", RoutingRule{Name: "news", TitleContains: "breaking", Collection: "News"}, false},
+		{"skip archive only", RoutingRule{Name: "yt", Domain: "youtube.com", SkipArchive: true}, false},
+		{"missing name", RoutingRule{Domain: "github.com", AddTag: "code"}, true},
+		{"no condition", RoutingRule{Name: "x", AddTag: "code"}, true},
+		{"no action", RoutingRule{Name: "x", Domain: "github.com"}, true},
+		{"bad domain", RoutingRule{Name: "x", Domain: "user@github.com", AddTag: "code"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoutingRule(&tt.rule)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRoutingRule) {
+					t.Errorf("expected ErrInvalidRoutingRule, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("normalizes fields", func(t *testing.T) {
+		r := RoutingRule{Name: " gh ", Domain: "https://www.GitHub.com/foo", AddTag: "#Code", Collection: " Dev "}
+		if err := ValidateRoutingRule(&r); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := RoutingRule{Name: "gh", Domain: "github.com", AddTag: "code", Collection: "Dev"}
+		if r != want {
+			t.Errorf("expected %+v, got %+v", want, r)
+		}
+	})
+}
+
+// TestRouteBookmark tests how matching rules combine.
+func TestRouteBookmark(t *testing.T) {
+	rules := []RoutingRule{
+		{Name: "gh", Domain: "github.com", AddTag: "code", Collection: "Dev", Enabled: true},
+		{Name: "go", TitleContains: "golang", AddTag: "go", Collection: "Go", Enabled: true},
+		{Name: "video", Domain: "youtube.com", SkipArchive: true, Enabled: true},
+		{Name: "off", Domain: "github.com", AddTag: "disabled", Enabled: false},
+	}
+
+	tests := []struct {
+		name  string
+		url   string
+		title string
+		want  Routing
+	}{
+		{"no match", "https://example.com", "Example", Routing{}},
+		{"subdomain", "https://gist.github.com/x", "", Routing{Rules: []string{"gh"}, Tags: []string{"code"}, Collection: "Dev"}},
+		{"not a suffix match", "https://notgithub.com", "", Routing{}},
+		{"title case-insensitive", "https://example.com", "Learning GoLang", Routing{Rules: []string{"go"}, Tags: []string{"go"}, Collection: "Go"}},
+		{"first collection wins", "https://github.com/golang/go", "golang/go", Routing{Rules: []string{"gh", "go"}, Tags: []string{"code", "go"}, Collection: "Dev"}},
+		{"skip archive", "https://www.youtube.com/watch?v=1", "", Routing{Rules: []string{"video"}, SkipArchive: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RouteBookmark(rules, tt.url, tt.title)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRoutingRules tests storing rules and applying them on creation.
+func TestRoutingRules(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.CreateRoutingRule(RoutingRule{Name: "gh", Domain: "GitHub.com", AddTag: "code", Collection: "Dev", Enabled: true})
+	if err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if _, err := db.CreateRoutingRule(RoutingRule{Name: "video", Domain: "youtube.com", SkipArchive: true, Enabled: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	t.Run("get and list", func(t *testing.T) {
+		r, err := db.GetRoutingRule(id)
+		if err != nil {
+			t.Fatalf("failed to get rule: %v", err)
+		}
+		if r.Domain != "github.com" || r.AddTag != "code" || r.Collection != "Dev" || !r.Enabled || r.CreatedAt == "" {
+			t.Errorf("unexpected rule: %+v", r)
+		}
+		rules, err := db.ListRoutingRules()
+		if err != nil {
+			t.Fatalf("failed to list rules: %v", err)
+		}
+		if len(rules) != 2 || rules[0].ID != id {
+			t.Errorf("expected 2 rules in creation order, got %+v", rules)
+		}
+	})
+
+	t.Run("names are unique", func(t *testing.T) {
+		if _, err := db.CreateRoutingRule(RoutingRule{Name: "gh", Domain: "gitlab.com", AddTag: "code"}); err == nil {
+			t.Error("expected error for duplicate name")
+		}
+	})
+
+	t.Run("applied on creation", func(t *testing.T) {
+		var events []BookmarkCreatedEvent
+		db.RegisterEventListener(OnBookmarkCreatedEvent, func(e Event) error {
+			events = append(events, e.(BookmarkCreatedEvent))
+			return nil
+		})
+
+		bid, err := db.CreateBookmark(NewBookmark{URL: "https://github.com/seckatie/bookmarkd", Tags: []string{"mine"}})
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		tags, err := db.ListBookmarkTags(bid)
+		if err != nil {
+			t.Fatalf("failed to list tags: %v", err)
+		}
+		if !reflect.DeepEqual(tags, []string{"code", "mine"}) {
+			t.Errorf("expected tags [code mine], got %v", tags)
+		}
+		if c, err := db.GetBookmarkCollection(bid); err != nil || c != "Dev" {
+			t.Errorf("expected collection Dev, got %q, %v", c, err)
+		}
+
+		vid, err := db.CreateBookmark(NewBookmark{URL: "https://youtube.com/watch?v=1", Collection: "Talks"})
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		if c, err := db.GetBookmarkCollection(vid); err != nil || c != "Talks" {
+			t.Errorf("expected explicit collection to be kept, got %q, %v", c, err)
+		}
+
+		if len(events) != 2 || events[0].SkipArchive || !events[1].SkipArchive {
+			t.Errorf("expected only the video to skip archiving, got %+v", events)
+		}
+
+		n, err := db.EnqueueUnarchivedBookmarks()
+		if err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("expected skipped bookmark not to be queued, queued %d", n)
+		}
+	})
+
+	t.Run("disabled rules are not applied", func(t *testing.T) {
+		if err := db.SetRoutingRuleEnabled(id, false); err != nil {
+			t.Fatalf("failed to disable rule: %v", err)
+		}
+		bid, err := db.CreateBookmark(NewBookmark{URL: "https://github.com/other"})
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		if tags, _ := db.ListBookmarkTags(bid); len(tags) != 0 {
+			t.Errorf("expected no tags, got %v", tags)
+		}
+		if c, _ := db.GetBookmarkCollection(bid); c != "" {
+			t.Errorf("expected no collection, got %q", c)
+		}
+	})
+
+	t.Run("set collection", func(t *testing.T) {
+		bid, err := db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		if err := db.SetBookmarkCollection(bid, "Reading"); err != nil {
+			t.Fatalf("failed to set collection: %v", err)
+		}
+		if c, _ := db.GetBookmarkCollection(bid); c != "Reading" {
+			t.Errorf("expected Reading, got %q", c)
+		}
+		if err := db.SetBookmarkCollection(bid, ""); err != nil {
+			t.Fatalf("failed to clear collection: %v", err)
+		}
+		if c, _ := db.GetBookmarkCollection(bid); c != "" {
+			t.Errorf("expected no collection, got %q", c)
+		}
+		if err := db.SetBookmarkCollection(99999, "x"); err == nil {
+			t.Error("expected error for missing bookmark")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := db.DeleteRoutingRule(id); err != nil {
+			t.Fatalf("failed to delete rule: %v", err)
+		}
+		if _, err := db.GetRoutingRule(id); err == nil {
+			t.Error("expected error for deleted rule")
+		}
+		if err := db.DeleteRoutingRule(id); err == nil {
+			t.Error("expected error deleting twice")
+		}
+	})
+}
@@ -0,0 +1,489 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidSearch is wrapped by the errors SearchBookmarks returns for
+// queries it can't run, such as an unknown field prefix.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// SearchResult is a bookmark matching a search, with its relevance score.
+type SearchResult struct {
+	Bookmark
+	Score float64 `json:"score"`
+	// Explain breaks Score down into its parts.
+	Explain ScoreExplanation `json:"explain"`
+	// Snippet is a few words of the field that matched best, such as the
+	// notes or page text, with each match between SearchMatchStart and
+	// SearchMatchEnd. It is empty for queries of only filters.
+	Snippet string `json:"snippet"`
+}
+
+// SearchMatchStart and SearchMatchEnd surround the matches in a
+// SearchResult's Snippet. They are control characters, so they can't clash
+// with the text and survive HTML escaping for the caller to replace.
+const (
+	SearchMatchStart = "\x02"
+	SearchMatchEnd   = "\x03"
+)
+
+// searchSnippetWords is how many words a SearchResult's Snippet has.
+const searchSnippetWords = 16
+
+// ScoreExplanation shows how SearchBookmarks scored a result: the text
+// match score, the boost each signal added, and the total, which is the text
+// score times one plus the boosts.
+type ScoreExplanation struct {
+	Text     float64 `json:"text"`
+	Recency  float64 `json:"recency"`
+	Favorite float64 `json:"favorite"`
+	Views    float64 `json:"views"`
+	Total    float64 `json:"total"`
+	// AgeDays and ViewCount are the inputs to the recency and views boosts.
+	AgeDays   float64 `json:"age_days"`
+	ViewCount int64   `json:"view_count"`
+}
+
+// SearchRanking tunes how SearchBookmarks blends the text match score with
+// other signals. Each boost multiplies the text score by one plus the boost,
+// so a better text match still wins over a recent or popular weak one.
+type SearchRanking struct {
+	// RecencyWeight is the boost for a bookmark saved just now; it halves
+	// every RecencyHalfLife of the bookmark's age.
+	RecencyWeight   float64
+	RecencyHalfLife time.Duration
+	// FavoriteBoost is added for favorites.
+	FavoriteBoost float64
+	// ViewWeight is multiplied by log2(1 + views), where views counts how
+	// often the bookmark's archive or reader view was opened.
+	ViewWeight float64
+}
+
+// DefaultSearchRanking returns the ranking SearchBookmarks uses unless
+// SetSearchRanking changes it.
+func DefaultSearchRanking() SearchRanking {
+	return SearchRanking{
+		RecencyWeight:   0.5,
+		RecencyHalfLife: 90 * 24 * time.Hour,
+		FavoriteBoost:   0.3,
+		ViewWeight:      0.1,
+	}
+}
+
+// SetSearchRanking replaces the ranking used by SearchBookmarks. Zero
+// weights turn a signal off.
+func (db *DB) SetSearchRanking(r SearchRanking) {
+	db.ranking = r
+}
+
+// explain scores a result from its text score and signals at now.
+func (r SearchRanking) explain(text float64, createdAt string, favorite bool, views int64, now time.Time) ScoreExplanation {
+	e := ScoreExplanation{Text: text, ViewCount: views}
+	if created, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		age := max(now.Sub(created), 0)
+		e.AgeDays = age.Hours() / 24
+		if r.RecencyHalfLife > 0 {
+			e.Recency = r.RecencyWeight * math.Exp2(-float64(age)/float64(r.RecencyHalfLife))
+		}
+	}
+	if favorite {
+		e.Favorite = r.FavoriteBoost
+	}
+	if views > 0 {
+		e.Views = r.ViewWeight * math.Log2(1+float64(views))
+	}
+	e.Total = text * (1 + e.Recency + e.Favorite + e.Views)
+	return e
+}
+
+// searchColumns are the bookmark_search columns in index order, each with
+// the weight its matches get when ranking. Title and tag matches say more
+// about what a bookmark is than a passing mention in the page text. A new
+// searchable field needs a column here and in the triggers
+// applySearchTokenizer creates.
+var searchColumns = []struct {
+	name   string
+	weight float64
+}{
+	{"title", 10},
+	{"url", 2},
+	{"notes", 4},
+	{"tags", 6},
+	{"content", 1},
+}
+
+// searchFields maps the field prefixes accepted in queries to columns.
+var searchFields = map[string]string{
+	"title":   "title",
+	"url":     "url",
+	"note":    "notes",
+	"notes":   "notes",
+	"tag":     "tags",
+	"tags":    "tags",
+	"text":    "content",
+	"content": "content",
+}
+
+// searchFilters are the field prefixes that filter results instead of
+// matching text: domain: keeps bookmarks on a site or its subdomains, and
+// before: and after: bound when they were saved.
+var searchFilters = map[string]bool{
+	"domain": true,
+	"before": true,
+	"after":  true,
+}
+
+// searchTerm is one word or quoted phrase of a query, optionally limited to
+// a column.
+type searchTerm struct {
+	column string
+	text   string
+	prefix bool
+}
+
+// searchQuery is a parsed query: the terms to match in the index and the
+// filters results must also pass.
+type searchQuery struct {
+	terms   []searchTerm
+	domains []string
+	// after and before bound the time a bookmark was saved, after
+	// inclusively; zero means unbounded.
+	after, before time.Time
+}
+
+// parseSearchQuery splits a query into terms and filters. Terms are
+// separated by spaces and all must match. A term can be a "quoted phrase",
+// can be limited to one field with a prefix such as note: or tag:, and ends
+// in * to match any word starting with it. Filters (see searchFilters) take
+// a domain or a date (YYYY-MM-DD, YYYY-MM or YYYY); after:2024-06
+// before:2024-07 is June 2024.
+func parseSearchQuery(q string) (searchQuery, error) {
+	var query searchQuery
+	rest := strings.TrimSpace(q)
+	for rest != "" {
+		var term searchTerm
+		var filter string
+		if i := strings.IndexAny(rest, ": \""); i > 0 && rest[i] == ':' {
+			// Anything that isn't a known field, such as the scheme of a
+			// URL, is searched for as plain text.
+			field := strings.ToLower(rest[:i])
+			if column, ok := searchFields[field]; ok {
+				term.column = column
+				rest = rest[i+1:]
+			} else if searchFilters[field] {
+				filter = field
+				rest = rest[i+1:]
+			}
+		}
+
+		var word string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				word, rest = rest[1:], ""
+			} else {
+				word, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.IndexByte(rest, ' '); end >= 0 {
+			word, rest = rest[:end], rest[end:]
+		} else {
+			word, rest = rest, ""
+		}
+		rest = strings.TrimSpace(rest)
+
+		if filter != "" {
+			if err := query.addFilter(filter, word); err != nil {
+				return searchQuery{}, err
+			}
+			continue
+		}
+		term.prefix = strings.HasSuffix(word, "*")
+		term.text = strings.TrimSpace(strings.Trim(word, `*"`))
+		if !strings.ContainsFunc(term.text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+			continue
+		}
+		query.terms = append(query.terms, term)
+	}
+	if len(query.terms) == 0 && !query.filtered() {
+		return searchQuery{}, fmt.Errorf("%w: nothing to search for", ErrInvalidSearch)
+	}
+	return query, nil
+}
+
+// addFilter adds the filter field with value to q.
+func (q *searchQuery) addFilter(field, value string) error {
+	value = strings.TrimSpace(strings.Trim(value, `"`))
+	if field == "domain" {
+		domain := NormalizeRuleDomain(value)
+		if domain == "" || strings.ContainsAny(domain, " :@") {
+			return fmt.Errorf("%w: %q is not a domain", ErrInvalidSearch, value)
+		}
+		q.domains = append(q.domains, domain)
+		return nil
+	}
+	var day time.Time
+	var err error
+	for _, layout := range []string{time.DateOnly, "2006-01", "2006"} {
+		if day, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: needs a date like 2024-06-01, got %q", ErrInvalidSearch, field, value)
+	}
+	if field == "before" {
+		if q.before.IsZero() || day.Before(q.before) {
+			q.before = day
+		}
+	} else if day.After(q.after) {
+		q.after = day
+	}
+	return nil
+}
+
+// SearchTerms returns the words of query's terms, lowercased, without its
+// field prefixes and filters, for highlighting them in results. It returns
+// nil for queries SearchBookmarks rejects.
+func SearchTerms(query string) []string {
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return nil
+	}
+	var words []string
+	for _, t := range q.terms {
+		words = append(words, strings.FieldsFunc(strings.ToLower(t.text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})...)
+	}
+	return words
+}
+
+// filtered reports whether q has any filters.
+func (q searchQuery) filtered() bool {
+	return len(q.domains) > 0 || !q.after.IsZero() || !q.before.IsZero()
+}
+
+// keeps reports whether a bookmark with the given URL and creation time
+// passes q's filters. Domains match the host and its subdomains, ignoring
+// a leading "www.".
+func (q searchQuery) keeps(rawURL, createdAt string) bool {
+	if len(q.domains) > 0 {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		for _, d := range q.domains {
+			if host != d && !strings.HasSuffix(host, "."+d) {
+				return false
+			}
+		}
+	}
+	if !q.after.IsZero() || !q.before.IsZero() {
+		created, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return false
+		}
+		if !q.after.IsZero() && created.Before(q.after) {
+			return false
+		}
+		if !q.before.IsZero() && !created.Before(q.before) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchExpression turns terms into an FTS MATCH expression for an index
+// built with tokenizer. Terms are reduced to their lowercased words, so
+// nothing the user types is read as an FTS operator. FTS4 can't limit a
+// phrase to a column, so a field-limited phrase becomes the phrase anywhere
+// plus each of its words in the field. With TokenizerTrigram, CJK text is
+// matched on its own through trigramQuery.
+func matchExpression(terms []searchTerm, tokenizer SearchTokenizer) string {
+	var parts []string
+	for _, t := range terms {
+		words := strings.FieldsFunc(strings.ToLower(t.text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if tokenizer == TokenizerTrigram {
+			var runs [][]rune
+			words, runs = splitCJK(words)
+			for _, run := range runs {
+				parts = append(parts, trigramQuery(run, t.column)...)
+			}
+			if len(words) == 0 {
+				continue
+			}
+		}
+		star := ""
+		if t.prefix {
+			star = "*"
+		}
+		if t.column == "" || len(words) > 1 {
+			parts = append(parts, `"`+strings.Join(words, " ")+star+`"`)
+		}
+		if t.column != "" {
+			for i, w := range words {
+				if i == len(words)-1 {
+					w += star
+				}
+				parts = append(parts, t.column+":"+w)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// SearchBookmarks returns the bookmarks matching query that pass filter,
+// best match first, up to limit (0 for all). See parseSearchQuery for the
+// query syntax. Matches are scored per field using searchColumns' weights,
+// so a hit in the title outranks one in the page text, and the score is then
+// boosted for recent, favorite and often-viewed bookmarks (see
+// SearchRanking). Each result's Explain shows how it was scored. A query of
+// only filters, such as "domain:example.com", lists every bookmark passing
+// them, newest first.
+func (db *DB) SearchBookmarks(query string, filter BookmarkFilter, limit int) ([]SearchResult, error) {
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	where, args := filter.where("b.")
+	var rows *sql.Rows
+	if len(q.terms) > 0 {
+		var tokenizer SearchTokenizer
+		if tokenizer, err = db.SearchTokenizer(); err != nil {
+			return nil, err
+		}
+		rows, err = db.db.Query(`
+			SELECT b.id, b.url, b.title, b.created_at, COALESCE(b.slug, ''), b.is_favorite, b.view_count,
+			       matchinfo(bookmark_search, 'pcnx'),
+			       snippet(bookmark_search, ?, ?, '…', -1, ?)
+			FROM bookmark_search
+			JOIN bookmarks b ON b.id = bookmark_search.docid
+			WHERE bookmark_search MATCH ?
+			  AND `+where+`
+			  AND `+ownerFilter("b.user_id"), append(append([]any{SearchMatchStart, SearchMatchEnd, searchSnippetWords, matchExpression(q.terms, tokenizer)}, args...), db.owner()...)...)
+	} else {
+		rows, err = db.db.Query(`
+			SELECT b.id, b.url, b.title, b.created_at, COALESCE(b.slug, ''), b.is_favorite, b.view_count, NULL, NULL
+			FROM bookmarks b
+			WHERE `+where+`
+			  AND `+ownerFilter("b.user_id"), append(args, db.owner()...)...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search bookmarks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	now := time.Now()
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		var title sql.NullString
+		var favorite bool
+		var views int64
+		var info []byte
+		var snippet sql.NullString
+		if err := rows.Scan(&r.ID, &r.URL, &title, &r.CreatedAt, &r.Slug, &favorite, &views, &info, &snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if !q.keeps(r.URL, r.CreatedAt) {
+			continue
+		}
+		r.Title = title.String
+		r.Snippet = snippet.String
+		r.Explain = db.ranking.explain(searchScore(info), r.CreatedAt, favorite, views, now)
+		r.Score = r.Explain.Total
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate search results: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].CreatedAt != results[j].CreatedAt {
+			return results[i].CreatedAt > results[j].CreatedAt
+		}
+		return results[i].ID > results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchScore ranks a row from its matchinfo 'pcnx' blob: the number of
+// phrases p, columns c and indexed rows n, then for each phrase and column
+// the hits in this row, the hits in all rows and the rows with a hit. Each
+// phrase scores, per column, a saturating term frequency times the column
+// weight times the phrase's inverse document frequency there.
+func searchScore(info []byte) float64 {
+	if len(info)%4 != 0 {
+		return 0
+	}
+	v := make([]uint32, len(info)/4)
+	for i := range v {
+		v[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(v) < 3 {
+		return 0
+	}
+	phrases, cols, total := int(v[0]), int(v[1]), float64(v[2])
+	if len(v) < 3+phrases*cols*3 {
+		return 0
+	}
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols && c < len(searchColumns); c++ {
+			x := v[3+(p*cols+c)*3:]
+			hits, docs := float64(x[0]), float64(x[2])
+			if hits == 0 {
+				continue
+			}
+			idf := math.Log(1 + total/docs)
+			score += searchColumns[c].weight * hits / (hits + 1) * idf
+		}
+	}
+	return score
+}
+
+// rebuildSearchIndex refills bookmark_search from bookmarks, their tags and
+// their latest archive's reader-mode text.
+// It is the data migration for 0020-search; later rebuilds go through
+// applySearchTokenizer, which also recreates the index for its tokenizer.
+func rebuildSearchIndex(tx *sql.Tx) error {
+	if _, err := tx.Exec(`DELETE FROM bookmark_search`); err != nil {
+		return fmt.Errorf("failed to clear search index: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO bookmark_search (docid, title, url, notes, tags, content)
+		SELECT b.id, COALESCE(b.title, ''), b.url, COALESCE(b.notes, ''),
+		       COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+		                 JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = b.id), ''),
+		       COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = b.id
+		                 ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')
+		FROM bookmarks b
+	`); err != nil {
+		return fmt.Errorf("failed to build search index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestParseSearchQuery tests the search query syntax.
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"words", "go  sqlite", `"go" "sqlite"`},
+		{"field prefixes", "note:later Tag:golang title:intro", `notes:later tags:golang title:intro`},
+		{"phrases", `"Full text" note:"read later"`, `"full text" "read later" notes:read notes:later`},
+		{"prefix match", "arch* title:intro*", `"arch*" title:intro*`},
+		{"unknown prefixes are text", "https://example.com", `"https example com"`},
+		{"operators are quoted", "go OR NEAR(x)", `"go" "or" "near x"`},
+		{"punctuation is dropped", "go - *", `"go"`},
+		{"filters are not text", "go domain:example.com before:2024", `"go"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := matchExpression(q.terms, TokenizerUnicode61); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("filters", func(t *testing.T) {
+		q, err := parseSearchQuery(`domain:https://www.Example.com/x after:2024-06 before:"2024-07-01" before:2025`)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(q.terms) != 0 || len(q.domains) != 1 || q.domains[0] != "example.com" {
+			t.Errorf("unexpected query %+v", q)
+		}
+		if want := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local); !q.after.Equal(want) {
+			t.Errorf("expected after %v, got %v", want, q.after)
+		}
+		if want := time.Date(2024, 7, 1, 0, 0, 0, 0, time.Local); !q.before.Equal(want) {
+			t.Errorf("expected the earliest before, %v, got %v", want, q.before)
+		}
+		for rawURL, want := range map[string]bool{
+			"https://example.com/a":      true,
+			"https://blog.example.com/a": true,
+			"https://notexample.com/a":   false,
+		} {
+			if got := q.keeps(rawURL, "2024-06-15T12:00:00Z"); got != want {
+				t.Errorf("keeps(%q) = %v, want %v", rawURL, got, want)
+			}
+		}
+		if q.keeps("https://example.com", "2024-07-02T12:00:00Z") {
+			t.Error("expected a bookmark saved after the range to be dropped")
+		}
+	})
+
+	for _, q := range []string{"", "  ", "- *", "domain:", "before:yesterday"} {
+		if _, err := parseSearchQuery(q); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("expected ErrInvalidSearch for %q, got %v", q, err)
+		}
+	}
+}
+
+// TestSearchBookmarks tests full-text search and its index triggers.
+func TestSearchBookmarks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	titled, _ := db.CreateBookmark(NewBookmark{URL: "https://a.example.com", Title: "Gardening basics"})
+	noted, _ := db.CreateBookmark(NewBookmark{URL: "https://b.example.com", Title: "Tools", Notes: "Good for gardening in spring"})
+	tagged, _ := db.CreateBookmark(NewBookmark{URL: "https://c.example.com", Title: "Seeds", Tags: []string{"gardening"}})
+	archived, _ := db.CreateBookmark(NewBookmark{URL: "https://d.example.com", Title: "Blog"})
+	now := time.Now()
+	if err := db.SaveArchiveResult(archived, now, &now, "ok", "", "https://d.example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveBookmarkReadable(BookmarkReadable{BookmarkID: archived, TextContent: "A post that mentions gardening once."}); err != nil {
+		t.Fatalf("failed to save readable: %v", err)
+	}
+
+	ids := func(results []SearchResult) []int64 {
+		var out []int64
+		for _, r := range results {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	t.Run("marks matches in a snippet of the best field", func(t *testing.T) {
+		results, err := db.SearchBookmarks("mentions", BookmarkFilter{}, 0)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("expected one result, got %v, %v", results, err)
+		}
+		if want := "A post that " + SearchMatchStart + "mentions" + SearchMatchEnd + " gardening once."; results[0].Snippet != want {
+			t.Errorf("expected snippet %q, got %q", want, results[0].Snippet)
+		}
+		if got := SearchTerms(`title:Garden* "in Spring" domain:example.com`); !slices.Equal(got, []string{"garden", "in", "spring"}) {
+			t.Errorf("SearchTerms() = %q", got)
+		}
+	})
+
+	t.Run("ranks by field weight", func(t *testing.T) {
+		results, err := db.SearchBookmarks("gardening", BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []int64{titled, tagged, noted, archived}
+		got := ids(results)
+		if len(got) != len(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		}
+		if results[0].Score <= results[3].Score {
+			t.Errorf("expected title match to score higher, got %v", results)
+		}
+	})
+
+	t.Run("targets fields", func(t *testing.T) {
+		for query, want := range map[string]int64{
+			"note:gardening":   noted,
+			"tag:gardening":    tagged,
+			"text:mentions":    archived,
+			"title:garden*":    titled,
+			"url:d.example":    archived,
+			`note:"in spring"`: noted,
+		} {
+			results, err := db.SearchBookmarks(query, BookmarkFilter{}, 0)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", query, err)
+			}
+			if got := ids(results); len(got) != 1 || got[0] != want {
+				t.Errorf("%s: expected [%d], got %v", query, want, got)
+			}
+		}
+	})
+
+	t.Run("applies the filter and limit", func(t *testing.T) {
+		if _, err := db.ToggleFavorite(noted); err != nil {
+			t.Fatalf("failed to favorite: %v", err)
+		}
+		results, err := db.SearchBookmarks("gardening", BookmarkFilter{FavoritesOnly: true}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := ids(results); len(got) != 1 || got[0] != noted {
+			t.Errorf("expected [%d], got %v", noted, got)
+		}
+		results, err = db.SearchBookmarks("gardening", BookmarkFilter{}, 2)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(results) != 2 || results[0].ID != titled {
+			t.Errorf("expected the top 2 results, got %v", ids(results))
+		}
+	})
+
+	t.Run("filters by domain and date", func(t *testing.T) {
+		tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+		for query, want := range map[string][]int64{
+			"domain:example.com":                      {archived, tagged, noted, titled},
+			"gardening domain:c.example.com":          {tagged},
+			"domain:a.example.com before:" + tomorrow: {titled},
+			"after:" + tomorrow:                       nil,
+		} {
+			results, err := db.SearchBookmarks(query, BookmarkFilter{}, 0)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", query, err)
+			}
+			if got := ids(results); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s: expected %v, got %v", query, want, got)
+			}
+		}
+	})
+
+	t.Run("follows edits", func(t *testing.T) {
+		if err := db.SetBookmarkNotes(noted, "Compost"); err != nil {
+			t.Fatalf("failed to set notes: %v", err)
+		}
+		if err := db.SetBookmarkTags(tagged, []string{"compost"}); err != nil {
+			t.Fatalf("failed to set tags: %v", err)
+		}
+		if err := db.DeleteBookmark(archived); err != nil {
+			t.Fatalf("failed to delete: %v", err)
+		}
+		results, err := db.SearchBookmarks("gardening", BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := ids(results); len(got) != 1 || got[0] != titled {
+			t.Errorf("expected [%d], got %v", titled, got)
+		}
+		results, err = db.SearchBookmarks("compost", BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("expected 2 results, got %v", ids(results))
+		}
+	})
+
+	t.Run("rejects invalid queries", func(t *testing.T) {
+		if _, err := db.SearchBookmarks("before:yesterday", BookmarkFilter{}, 0); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("expected ErrInvalidSearch, got %v", err)
+		}
+	})
+}
+
+// TestSearchRanking tests the recency, favorite and view boosts.
+func TestSearchRanking(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	old, _ := db.CreateBookmark(NewBookmark{URL: "https://old.example.com", Title: "Bread recipe",
+		CreatedAt: time.Now().AddDate(-2, 0, 0)})
+	recent, _ := db.CreateBookmark(NewBookmark{URL: "https://new.example.com", Title: "Bread recipe"})
+
+	top := func() SearchResult {
+		t.Helper()
+		results, err := db.SearchBookmarks("bread", BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+		return results[0]
+	}
+
+	t.Run("prefers recent bookmarks", func(t *testing.T) {
+		r := top()
+		if r.ID != recent {
+			t.Errorf("expected %d first, got %d", recent, r.ID)
+		}
+		if r.Explain.Recency <= 0.49 || r.Explain.Total != r.Score {
+			t.Errorf("unexpected explanation: %+v", r.Explain)
+		}
+	})
+
+	t.Run("favorites and views outweigh age", func(t *testing.T) {
+		if _, err := db.ToggleFavorite(old); err != nil {
+			t.Fatalf("failed to favorite: %v", err)
+		}
+		for range 3 {
+			if err := db.MarkBookmarkRead(old); err != nil {
+				t.Fatalf("failed to mark read: %v", err)
+			}
+		}
+		r := top()
+		if r.ID != old {
+			t.Fatalf("expected %d first, got %d", old, r.ID)
+		}
+		if r.Explain.Favorite != 0.3 || r.Explain.ViewCount != 3 || r.Explain.Views != 0.2 {
+			t.Errorf("unexpected explanation: %+v", r.Explain)
+		}
+	})
+
+	t.Run("zero weights leave the text score", func(t *testing.T) {
+		db.SetSearchRanking(SearchRanking{})
+		t.Cleanup(func() { db.SetSearchRanking(DefaultSearchRanking()) })
+		r := top()
+		if r.Score != r.Explain.Text || r.Explain.Recency != 0 || r.Explain.Favorite != 0 || r.Explain.Views != 0 {
+			t.Errorf("expected the text score alone, got %+v", r.Explain)
+		}
+	})
+}
+
+// TestBuildSearchIndex tests the 0020 data migration.
+func TestBuildSearchIndex(t *testing.T) {
+	db := newTestDBAt(t, "0019-read-favorite")
+	// CreateBookmark needs the current schema, so the row is written directly.
+	id := addLegacyBookmark(t, db, "https://example.com")
+	if _, err := db.db.Exec(`UPDATE bookmarks SET title = 'Existing' WHERE id = ?`, id); err != nil {
+		t.Fatalf("failed to set title: %v", err)
+	}
+	if err := addBookmarkTags(db.db, id, []string{"old"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	results, err := db.SearchBookmarks("tag:old title:existing", BookmarkFilter{}, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Errorf("expected bookmark %d to be indexed, got %v", id, results)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrSharedCollectionNotFound is returned for unknown share tokens and IDs.
+var ErrSharedCollectionNotFound = errors.New("shared collection not found")
+
+const sharedCollectionColumns = `id, user_id, collection, token, created_at`
+
+func scanSharedCollection(row interface{ Scan(...any) error }) (SharedCollection, error) {
+	var c SharedCollection
+	err := row.Scan(&c.ID, &c.UserID, &c.Collection, &c.Token, &c.CreatedAt)
+	return c, err
+}
+
+// ShareCollection makes one of the handle's user's collections public and
+// returns it. Sharing a collection that already is returns it unchanged,
+// token included. The collection needn't have bookmarks yet.
+func (db *DB) ShareCollection(collection string) (SharedCollection, error) {
+	collection = strings.TrimSpace(collection)
+	if collection == "" {
+		return SharedCollection{}, errors.New("collection name is required")
+	}
+	userID := db.actingUserID()
+	c, err := scanSharedCollection(db.db.QueryRow(`SELECT `+sharedCollectionColumns+` FROM shared_collections WHERE user_id = ? AND collection = ?`,
+		userID, collection))
+	if err == nil {
+		return c, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return SharedCollection{}, fmt.Errorf("failed to get shared collection: %w", err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return SharedCollection{}, fmt.Errorf("failed to generate share token: %w", err)
+	}
+	c = SharedCollection{UserID: userID, Collection: collection, Token: hex.EncodeToString(token), CreatedAt: time.Now().Format(time.RFC3339)}
+	res, err := db.db.Exec(`INSERT INTO shared_collections (user_id, collection, token, created_at) VALUES (?, ?, ?, ?)`,
+		c.UserID, c.Collection, c.Token, c.CreatedAt)
+	if err != nil {
+		return SharedCollection{}, fmt.Errorf("failed to share collection: %w", err)
+	}
+	if c.ID, err = res.LastInsertId(); err != nil {
+		return SharedCollection{}, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return c, nil
+}
+
+// ListSharedCollections returns the handle's user's shared collections, by
+// name.
+func (db *DB) ListSharedCollections() ([]SharedCollection, error) {
+	rows, err := db.db.Query(`SELECT `+sharedCollectionColumns+` FROM shared_collections WHERE user_id = ? ORDER BY collection COLLATE NOCASE, id`,
+		db.actingUserID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared collections: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var shared []SharedCollection
+	for rows.Next() {
+		c, err := scanSharedCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shared collection: %w", err)
+		}
+		shared = append(shared, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shared collections: %w", err)
+	}
+	return shared, nil
+}
+
+// UnshareCollection makes one of the handle's user's shared collections
+// private again; its public URLs stop working. Sharing it again gives it a
+// new token.
+func (db *DB) UnshareCollection(id int64) error {
+	res, err := db.db.Exec(`DELETE FROM shared_collections WHERE id = ? AND user_id = ?`, id, db.actingUserID())
+	if err != nil {
+		return fmt.Errorf("failed to unshare collection: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %d", ErrSharedCollectionNotFound, id)
+	}
+	return nil
+}
+
+// GetSharedCollectionByToken returns the shared collection a public URL's
+// token names, whoever owns it.
+func (db *DB) GetSharedCollectionByToken(token string) (SharedCollection, error) {
+	c, err := scanSharedCollection(db.db.QueryRow(`SELECT `+sharedCollectionColumns+` FROM shared_collections WHERE token = ?`, token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SharedCollection{}, ErrSharedCollectionNotFound
+		}
+		return SharedCollection{}, fmt.Errorf("failed to get shared collection: %w", err)
+	}
+	return c, nil
+}
+
+// ListSharedBookmarks returns the bookmarks in a shared collection, newest
+// first.
+func (db *DB) ListSharedBookmarks(c SharedCollection) ([]SharedBookmark, error) {
+	rows, err := db.db.Query(`
+		SELECT b.url, b.title, COALESCE(m.description, ''), b.created_at,
+			COALESCE((SELECT GROUP_CONCAT(t.name, char(31)) FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = b.id), '')
+		FROM bookmarks b
+		LEFT JOIN bookmark_metadata m ON m.bookmark_id = b.id
+		WHERE b.user_id = ? AND b.collection = ?
+		ORDER BY b.created_at DESC, b.id DESC
+	`, c.UserID, c.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared bookmarks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var bookmarks []SharedBookmark
+	for rows.Next() {
+		var b SharedBookmark
+		var tags string
+		if err := rows.Scan(&b.URL, &b.Title, &b.Description, &b.CreatedAt, &tags); err != nil {
+			return nil, fmt.Errorf("failed to scan shared bookmark: %w", err)
+		}
+		b.Tags = []string{}
+		if tags != "" {
+			b.Tags = strings.Split(tags, "\x1f")
+			sort.Strings(b.Tags)
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shared bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSharedCollections(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobs := db.ForUser(bob.ID)
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, nb := range []NewBookmark{
+		{URL: "https://go.dev", Title: "Go", Collection: "Reading", Tags: []string{"lang", "go"}, CreatedAt: day},
+		{URL: "https://example.com/later", Title: "Later", Collection: "Reading", CreatedAt: day.Add(time.Hour)},
+		{URL: "https://example.com/other", Title: "Other", Collection: "Work", CreatedAt: day},
+	} {
+		id, err := bobs.CreateBookmark(nb)
+		if err != nil {
+			t.Fatalf("failed to create bookmark %d: %v", i, err)
+		}
+		if i == 0 {
+			if err := bobs.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: id, Description: "The Go site", FetchedAt: day.Format(time.RFC3339)}); err != nil {
+				t.Fatalf("failed to save metadata: %v", err)
+			}
+		}
+	}
+	// Someone else's bookmark in a collection of the same name isn't shared.
+	if _, err := db.CreateBookmark(NewBookmark{URL: "https://example.com/mine", Title: "Mine", Collection: "Reading"}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	shared, err := bobs.ShareCollection(" Reading ")
+	if err != nil {
+		t.Fatalf("ShareCollection() error = %v", err)
+	}
+	if shared.UserID != bob.ID || shared.Collection != "Reading" || len(shared.Token) != 32 {
+		t.Errorf("unexpected shared collection %+v", shared)
+	}
+	if again, err := bobs.ShareCollection("Reading"); err != nil || again != shared {
+		t.Errorf("expected sharing again to keep the token, got %+v, %v", again, err)
+	}
+
+	got, err := db.GetSharedCollectionByToken(shared.Token)
+	if err != nil || got != shared {
+		t.Fatalf("GetSharedCollectionByToken() = %+v, %v", got, err)
+	}
+	bookmarks, err := db.ListSharedBookmarks(got)
+	if err != nil {
+		t.Fatalf("ListSharedBookmarks() error = %v", err)
+	}
+	if len(bookmarks) != 2 || bookmarks[0].Title != "Later" || bookmarks[1].Title != "Go" {
+		t.Fatalf("expected bob's two reading bookmarks, newest first, got %+v", bookmarks)
+	}
+	if b := bookmarks[1]; b.Description != "The Go site" || len(b.Tags) != 2 || b.Tags[0] != "go" || b.Tags[1] != "lang" {
+		t.Errorf("expected the description and sorted tags, got %+v", b)
+	}
+	if len(bookmarks[0].Tags) != 0 || bookmarks[0].Tags == nil {
+		t.Errorf("expected an empty tag list, got %#v", bookmarks[0].Tags)
+	}
+
+	if list, err := db.ListSharedCollections(); err != nil || len(list) != 0 {
+		t.Errorf("expected the local user to have no shared collections, got %+v, %v", list, err)
+	}
+	if err := db.UnshareCollection(shared.ID); !errors.Is(err, ErrSharedCollectionNotFound) {
+		t.Errorf("expected another user's shared collection to be left alone, got %v", err)
+	}
+	if err := bobs.UnshareCollection(shared.ID); err != nil {
+		t.Fatalf("UnshareCollection() error = %v", err)
+	}
+	if _, err := db.GetSharedCollectionByToken(shared.Token); !errors.Is(err, ErrSharedCollectionNotFound) {
+		t.Errorf("expected the token to stop working, got %v", err)
+	}
+	if _, err := bobs.ShareCollection("  "); err == nil {
+		t.Error("expected an error for an empty collection name")
+	}
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxSlugLength caps slugs, in runes, not counting the date prefix.
+const maxSlugLength = 60
+
+// ErrInvalidSlug is returned when a slug has nothing usable in it.
+var ErrInvalidSlug = errors.New("invalid slug")
+
+// ErrSlugTaken is returned when another of the user's bookmarks already has
+// the slug.
+var ErrSlugTaken = errors.New("slug already in use")
+
+// Slugify reduces s to a slug: lowercase letters and digits (in any script)
+// separated by single hyphens, cut at a word boundary to maxSlugLength runes.
+// Apostrophes are dropped, so "Don't" becomes "dont".
+func Slugify(s string) string {
+	var b strings.Builder
+	gap := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if gap && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			gap = false
+		case r == '\'' || r == '’':
+		default:
+			gap = true
+		}
+	}
+	slug := b.String()
+	if runes := []rune(slug); len(runes) > maxSlugLength {
+		slug = string(runes[:maxSlugLength])
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	return slug
+}
+
+// FileName names a file saved from the bookmark, such as its archived page
+// or an exported copy: its slug, or "bookmark-{id}" without one, then ext.
+func (b Bookmark) FileName(ext string) string {
+	if b.Slug == "" {
+		return fmt.Sprintf("bookmark-%d%s", b.ID, ext)
+	}
+	return b.Slug + ext
+}
+
+// bookmarkSlugBase is the slug a bookmark is given before making it unique:
+// the day it was saved, then its title, or else (when it has none, or the
+// title is just the URL) its URL's host and path.
+func bookmarkSlugBase(createdAt, title, rawURL string) string {
+	var base string
+	if strings.TrimRight(strings.TrimSpace(title), "/") != strings.TrimRight(rawURL, "/") {
+		base = Slugify(title)
+	}
+	if base == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			base = Slugify(strings.TrimPrefix(u.Hostname(), "www.") + " " + u.Path)
+		}
+	}
+	if base == "" {
+		base = "bookmark"
+	}
+	if len(createdAt) >= len(time.DateOnly) {
+		if day, err := time.Parse(time.DateOnly, createdAt[:len(time.DateOnly)]); err == nil {
+			return day.Format(time.DateOnly) + "-" + base
+		}
+	}
+	if numericSlug(base) {
+		base = "bookmark-" + base
+	}
+	return base
+}
+
+// numericSlug reports whether slug could be mistaken for a bookmark ID in
+// URLs that take either.
+func numericSlug(slug string) bool {
+	_, err := strconv.ParseInt(slug, 10, 64)
+	return err == nil
+}
+
+// assignBookmarkSlug gives bookmark id a slug if it has none, numbering it
+// (-2, -3, ...) past the owner's other bookmarks with the same slug.
+func assignBookmarkSlug(tx *sql.Tx, id int64) error {
+	var userID int64
+	var slug sql.NullString
+	var createdAt, title, rawURL string
+	if err := tx.QueryRow(`SELECT user_id, slug, created_at, COALESCE(title, ''), url FROM bookmarks WHERE id = ?`, id).
+		Scan(&userID, &slug, &createdAt, &title, &rawURL); err != nil {
+		return fmt.Errorf("failed to get bookmark %d for its slug: %w", id, err)
+	}
+	if slug.Valid {
+		return nil
+	}
+	base := bookmarkSlugBase(createdAt, title, rawURL)
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		var taken bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = ? AND slug = ?)`, userID, candidate).Scan(&taken); err != nil {
+			return fmt.Errorf("failed to check slug: %w", err)
+		}
+		if taken {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE bookmarks SET slug = ? WHERE id = ?`, candidate, id); err != nil {
+			return fmt.Errorf("failed to set bookmark slug: %w", err)
+		}
+		return nil
+	}
+}
+
+// untitledSlugCondition matches bookmarks named after their URL because
+// they have no real title, like ListBookmarksMissingTitles.
+const untitledSlugCondition = `(TRIM(COALESCE(title, '')) = '' OR RTRIM(TRIM(title), '/') = RTRIM(url, '/'))`
+
+// assignBookmarkSlugs is the data migration for 0031-bookmark-slugs: it gives
+// every existing bookmark a slug, oldest first, so earlier bookmarks keep
+// the unnumbered ones.
+func assignBookmarkSlugs(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id FROM bookmarks WHERE slug IS NULL ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to list bookmarks without slugs: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan bookmark ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		log.Printf("failed to close rows: %v", err)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate bookmarks: %w", err)
+	}
+	for _, id := range ids {
+		if err := assignBookmarkSlug(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBookmarkIDBySlug returns the ID of the bookmark with slug. On an
+// unscoped handle, where users may share slugs, the oldest bookmark wins.
+func (db *DB) GetBookmarkIDBySlug(slug string) (int64, error) {
+	var id int64
+	err := db.db.QueryRow(`SELECT id FROM bookmarks WHERE slug = ? AND `+ownerFilter("user_id")+` ORDER BY id LIMIT 1`,
+		append([]any{slug}, db.owner()...)...).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("bookmark not found: %s", slug)
+		}
+		return 0, fmt.Errorf("failed to look up bookmark slug: %w", err)
+	}
+	return id, nil
+}
+
+// SetBookmarkSlug renames a bookmark's slug and returns it as stored, after
+// Slugify. It returns ErrInvalidSlug if nothing is left of slug or it could
+// be read as a bookmark ID, and ErrSlugTaken if another of the owner's
+// bookmarks has it. Links made with the old slug stop working.
+func (db *DB) SetBookmarkSlug(id int64, slug string) (string, error) {
+	slug = Slugify(slug)
+	if slug == "" || numericSlug(slug) {
+		return "", fmt.Errorf("%w: use letters as well as digits", ErrInvalidSlug)
+	}
+	res, err := db.db.Exec(`
+		UPDATE bookmarks SET slug = ?
+		WHERE id = ? AND `+ownerFilter("user_id")+`
+		  AND NOT EXISTS (
+			SELECT 1 FROM bookmarks other
+			WHERE other.user_id = bookmarks.user_id AND other.slug = ? AND other.id != bookmarks.id
+		  )`, append(append([]any{slug, id}, db.owner()...), slug)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to set bookmark slug: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		if _, err := db.BookmarkOwner(id); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %s", ErrSlugTaken, slug)
+	}
+	return slug, nil
+}
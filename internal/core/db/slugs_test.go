@@ -0,0 +1,154 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"How SQLite Works", "how-sqlite-works"},
+		{"  Don't Panic!  ", "dont-panic"},
+		{"C++ / Go -- a comparison", "c-go-a-comparison"},
+		{"Café Über Straße", "café-über-straße"},
+		{"日本語のページ", "日本語のページ"},
+		{"!!!", ""},
+		{strings.Repeat("word ", 20), strings.TrimSuffix(strings.Repeat("word-", 12), "-")},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.in); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBookmarkSlugBase(t *testing.T) {
+	tests := []struct {
+		name, createdAt, title, url, want string
+	}{
+		{"title", "2024-06-01T10:00:00Z", "How SQLite Works", "https://sqlite.org/howitworks.html", "2024-06-01-how-sqlite-works"},
+		{"no title", "2024-06-01T10:00:00Z", "", "https://www.example.com/docs/intro", "2024-06-01-example-com-docs-intro"},
+		{"URL as title", "2024-06-01T10:00:00Z", "https://example.com/", "https://example.com", "2024-06-01-example-com"},
+		{"no date", "", "1984", "https://example.com", "bookmark-1984"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bookmarkSlugBase(tt.createdAt, tt.title, tt.url); got != tt.want {
+				t.Errorf("bookmarkSlugBase() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookmarkSlugs(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	add := func(d *DB, url, title string) Bookmark {
+		t.Helper()
+		id, err := d.CreateBookmark(NewBookmark{URL: url, Title: title, CreatedAt: day})
+		if err != nil {
+			t.Fatalf("failed to create bookmark: %v", err)
+		}
+		b, err := d.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		return b
+	}
+
+	first := add(db, "https://a.example", "How SQLite Works")
+	second := add(db, "https://b.example", "How SQLite works?")
+	if first.Slug != "2024-06-01-how-sqlite-works" || second.Slug != "2024-06-01-how-sqlite-works-2" {
+		t.Errorf("expected numbered slugs for the same title, got %q and %q", first.Slug, second.Slug)
+	}
+
+	t.Run("users have their own slugs", func(t *testing.T) {
+		user, err := db.CreateUser("slugger", "correct horse battery", false)
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		theirs := add(db.ForUser(user.ID), "https://c.example", "How SQLite Works")
+		if theirs.Slug != first.Slug {
+			t.Errorf("expected %q for another user, got %q", first.Slug, theirs.Slug)
+		}
+		if id, err := db.ForUser(user.ID).GetBookmarkIDBySlug(first.Slug); err != nil || id != theirs.ID {
+			t.Errorf("expected the user's own bookmark, got %d (err=%v)", id, err)
+		}
+	})
+
+	t.Run("first title renames an untitled bookmark", func(t *testing.T) {
+		untitled := add(db, "https://example.com/post", "")
+		if untitled.Slug != "2024-06-01-example-com-post" {
+			t.Fatalf("expected a slug from the URL, got %q", untitled.Slug)
+		}
+		if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: untitled.ID, Title: "A Real Title"}); err != nil {
+			t.Fatalf("failed to save metadata: %v", err)
+		}
+		if b, _ := db.GetBookmark(untitled.ID); b.Slug != "2024-06-01-a-real-title" {
+			t.Errorf("expected the slug to follow the new title, got %q", b.Slug)
+		}
+		if err := db.SaveBookmarkMetadata(BookmarkMetadata{BookmarkID: untitled.ID, Title: "Another Title"}); err != nil {
+			t.Fatalf("failed to save metadata: %v", err)
+		}
+		if b, _ := db.GetBookmark(untitled.ID); b.Slug != "2024-06-01-a-real-title" {
+			t.Errorf("expected a titled bookmark to keep its slug, got %q", b.Slug)
+		}
+	})
+
+	t.Run("set slug", func(t *testing.T) {
+		slug, err := db.SetBookmarkSlug(second.ID, " SQLite Internals ")
+		if err != nil || slug != "sqlite-internals" {
+			t.Fatalf("SetBookmarkSlug() = %q, %v", slug, err)
+		}
+		if id, err := db.GetBookmarkIDBySlug("sqlite-internals"); err != nil || id != second.ID {
+			t.Errorf("expected to find the renamed bookmark, got %d (err=%v)", id, err)
+		}
+		if _, err := db.SetBookmarkSlug(second.ID, "sqlite-internals"); err != nil {
+			t.Errorf("expected keeping the same slug to work, got %v", err)
+		}
+		if _, err := db.SetBookmarkSlug(first.ID, "SQLite internals"); !errors.Is(err, ErrSlugTaken) {
+			t.Errorf("expected ErrSlugTaken, got %v", err)
+		}
+		for _, bad := range []string{"???", "12345"} {
+			if _, err := db.SetBookmarkSlug(first.ID, bad); !errors.Is(err, ErrInvalidSlug) {
+				t.Errorf("SetBookmarkSlug(%q): expected ErrInvalidSlug, got %v", bad, err)
+			}
+		}
+		if _, err := db.SetBookmarkSlug(99999, "missing"); err == nil || errors.Is(err, ErrSlugTaken) {
+			t.Errorf("expected not found for a missing bookmark, got %v", err)
+		}
+		if _, err := db.GetBookmarkIDBySlug("no-such-slug"); err == nil {
+			t.Error("expected an error for an unknown slug")
+		}
+	})
+}
+
+// TestAssignBookmarkSlugs tests the 0031 data migration.
+func TestAssignBookmarkSlugs(t *testing.T) {
+	db := newTestDBAt(t, "0030-bookmark-links")
+	first := addLegacyBookmark(t, db, "https://example.com/page")
+	second := addLegacyBookmark(t, db, "https://example.com/page/")
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	for id, want := range map[int64]string{first: "2000-01-01-example-com-page", second: "2000-01-01-example-com-page-2"} {
+		b, err := db.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		if b.Slug != want {
+			t.Errorf("bookmark %d: slug = %q, want %q", id, b.Slug, want)
+		}
+	}
+}
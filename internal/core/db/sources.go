@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Kinds of bookmark source, for NewBookmark.Source: how a bookmark entered
+// bookmarkd. API and import sources name the token or file after a colon;
+// see BookmarkSource. A new way of adding bookmarks, such as a feed
+// watcher, should get its own kind.
+const (
+	// SourceWeb is the web UI's add and bulk add forms.
+	SourceWeb = "web"
+	// SourceBookmarklet is the bookmarklet's save page, used with a login
+	// session.
+	SourceBookmarklet = "bookmarklet"
+	// SourceAPI is a request made with an API token, such as from a
+	// generated bookmarklet, watch-clipboard or a script.
+	SourceAPI = "api"
+	// SourceImport is an export imported from another tool.
+	SourceImport = "import"
+	// SourceMerge is a bookmarkd export merged with "bookmarkd merge".
+	SourceMerge = "merge"
+)
+
+// maxSourceDetail caps the length, in characters, of what BookmarkSource
+// appends to a kind.
+const maxSourceDetail = 100
+
+// BookmarkSource returns the source of kind with detail, such as the API
+// token's name or the import file's, e.g. "import:pinboard.json". Control
+// characters in detail are dropped and it is cut to maxSourceDetail
+// characters; without a detail the source is just kind.
+func BookmarkSource(kind, detail string) string {
+	detail = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, detail))
+	if runes := []rune(detail); len(runes) > maxSourceDetail {
+		detail = string(runes[:maxSourceDetail])
+	}
+	if detail == "" {
+		return kind
+	}
+	return kind + ":" + detail
+}
+
+// sourcePrefix returns the prefix of the sources BookmarkFilter.Source
+// matches besides itself: a bare kind such as "api" also matches every
+// "api:<token>" source.
+func sourcePrefix(source string) string {
+	if source == "" || strings.Contains(source, ":") {
+		return ""
+	}
+	return source + ":"
+}
+
+// GetBookmarkSource returns how a bookmark was added (see BookmarkSource),
+// or "" if it was saved before sources were recorded.
+func (db *DB) GetBookmarkSource(id int64) (string, error) {
+	var source string
+	err := db.db.QueryRow(`SELECT COALESCE(source, '') FROM bookmarks WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&source)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("bookmark not found: %d", id)
+		}
+		return "", fmt.Errorf("failed to get bookmark source: %w", err)
+	}
+	return source, nil
+}
+
+// ListBookmarkSources counts the user's bookmarks per source, in order,
+// for the bookmark list's source filter. Bookmarks without a recorded
+// source are left out.
+func (db *DB) ListBookmarkSources() ([]SourceCount, error) {
+	rows, err := db.db.Query(`
+		SELECT source, COUNT(*)
+		FROM bookmarks
+		WHERE source IS NOT NULL
+		  AND `+ownerFilter("user_id")+`
+		GROUP BY source
+		ORDER BY source
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmark sources: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	sources := []SourceCount{}
+	for rows.Next() {
+		var s SourceCount
+		if err := rows.Scan(&s.Source, &s.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan bookmark source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bookmark sources: %w", err)
+	}
+	return sources, nil
+}
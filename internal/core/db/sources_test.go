@@ -0,0 +1,89 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBookmarkSource(t *testing.T) {
+	tests := []struct {
+		kind, detail, want string
+	}{
+		{SourceWeb, "", SourceWeb},
+		{SourceAPI, " CI bot ", "api:CI bot"},
+		{SourceImport, "pin\nboard.json", "import:pinboard.json"},
+		{SourceImport, strings.Repeat("é", maxSourceDetail+5), "import:" + strings.Repeat("é", maxSourceDetail)},
+	}
+	for _, tt := range tests {
+		if got := BookmarkSource(tt.kind, tt.detail); got != tt.want {
+			t.Errorf("BookmarkSource(%q, %q) = %q, want %q", tt.kind, tt.detail, got, tt.want)
+		}
+	}
+}
+
+func TestBookmarkSources(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	local := db.ForUser(LocalUserID)
+	web, _ := local.CreateBookmark(NewBookmark{URL: "https://a.example.com", Source: SourceWeb})
+	ci, _ := local.CreateBookmark(NewBookmark{URL: "https://b.example.com", Source: "api:ci"})
+	cli, _ := local.CreateBookmark(NewBookmark{URL: "https://c.example.com", Source: "api:cli"})
+	imported, _ := local.CreateBookmarks([]NewBookmark{
+		{URL: "https://d.example.com", Source: "import:pocket.csv"},
+		{URL: "https://e.example.com", Source: "import:pocket.csv"},
+	})
+	unknown, _ := local.AddBookmark("https://f.example.com", "")
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := db.ForUser(bob.ID).CreateBookmark(NewBookmark{URL: "https://bob.example.com", Source: "api:ci"}); err != nil {
+		t.Fatalf("failed to add bob's bookmark: %v", err)
+	}
+
+	if source, err := local.GetBookmarkSource(ci); err != nil || source != "api:ci" {
+		t.Errorf("GetBookmarkSource() = %q, %v, want api:ci", source, err)
+	}
+	if source, err := local.GetBookmarkSource(unknown); err != nil || source != "" {
+		t.Errorf("expected no source for a bookmark added without one, got %q, %v", source, err)
+	}
+
+	sources, err := local.ListBookmarkSources()
+	want := []SourceCount{{"api:ci", 1}, {"api:cli", 1}, {"import:pocket.csv", 2}, {"web", 1}}
+	if err != nil || !reflect.DeepEqual(sources, want) {
+		t.Errorf("ListBookmarkSources() = %v, %v, want %v", sources, err, want)
+	}
+
+	tests := []struct {
+		source string
+		want   []int64
+	}{
+		{SourceWeb, []int64{web}},
+		// A bare kind keeps every source of that kind, but a full source
+		// doesn't keep others that start with it.
+		{SourceAPI, []int64{ci, cli}},
+		{"api:ci", []int64{ci}},
+		{"API:CI", nil},
+		{"import:pocket.csv", imported},
+		{"rss", nil},
+	}
+	for _, tt := range tests {
+		got, err := local.ListFilteredBookmarks(BookmarkFilter{Source: tt.source, Order: SortAsc}, 0)
+		if err != nil {
+			t.Fatalf("failed to list bookmarks: %v", err)
+		}
+		var ids []int64
+		for _, bm := range got {
+			ids = append(ids, bm.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("source %q: expected %v, got %v", tt.source, tt.want, ids)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// GetArchiveStats returns bookmark counts by archive state, archive queue
+// depth, the average archive duration and the jobs currently running.
+// Bookmark counts and running jobs are limited to db's user; queue depth is
+// instance-wide since every user shares the one archive queue.
+func (db *DB) GetArchiveStats() (ArchiveStats, error) {
+	var s ArchiveStats
+
+	// archive_status holds core.ArchiveStatusOK ("ok"), core.ArchiveStatusError
+	// ("error") or core.ArchiveStatusSkipped ("skipped").
+	err := db.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN r.bookmark_id IS NULL AND b.archive_status IS NULL THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.bookmark_id IS NOT NULL THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.bookmark_id IS NULL AND b.archive_status = 'ok' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.bookmark_id IS NULL AND b.archive_status = 'error' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.bookmark_id IS NULL AND b.archive_status = 'skipped' THEN 1 ELSE 0 END), 0)
+		FROM bookmarks b
+		LEFT JOIN (
+			SELECT DISTINCT bookmark_id FROM jobs WHERE kind = ? AND status = ?
+		) r ON r.bookmark_id = b.id
+		WHERE `+ownerFilter("b.user_id"), append([]any{JobKindArchive, JobStatusRunning}, db.owner()...)...).Scan(&s.Pending, &s.InProgress, &s.OK, &s.Error, &s.Skipped)
+	if err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to count bookmarks by archive status: %w", err)
+	}
+
+	rows, err := db.db.Query(`
+		SELECT COALESCE(b.archive_error_code, 'other'), COUNT(*)
+		FROM bookmarks b
+		WHERE b.archive_status = 'error' AND NOT EXISTS (
+			SELECT 1 FROM jobs WHERE bookmark_id = b.id AND kind = ? AND status = ?
+		) AND `+ownerFilter("b.user_id")+`
+		GROUP BY 1
+	`, append([]any{JobKindArchive, JobStatusRunning}, db.owner()...)...)
+	if err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to count archive errors: %w", err)
+	}
+	s.ErrorsByCode = map[string]int{}
+	for rows.Next() {
+		var code string
+		var n int
+		if err := rows.Scan(&code, &n); err != nil {
+			_ = rows.Close()
+			return ArchiveStats{}, fmt.Errorf("failed to scan archive error count: %w", err)
+		}
+		s.ErrorsByCode[code] = n
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return ArchiveStats{}, fmt.Errorf("failed to iterate archive error counts: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to count archive errors: %w", err)
+	}
+
+	now := jobTime(time.Now())
+	err = db.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status = ? AND next_attempt_at <= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND next_attempt_at > ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM jobs
+		WHERE kind = ?
+	`, JobStatusQueued, now, JobStatusQueued, now, JobStatusFailed, JobStatusDeferred, JobKindArchive).Scan(&s.Queued, &s.Retrying, &s.FailedJobs, &s.Deferred)
+	if err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to count archive jobs: %w", err)
+	}
+
+	if s.AvgDurationSeconds, err = db.AverageArchiveSeconds(); err != nil {
+		return ArchiveStats{}, err
+	}
+
+	rows, err = db.db.Query(`
+		SELECT j.id, j.bookmark_id, COALESCE(b.url, ''), COALESCE(b.title, ''), j.attempts, j.updated_at
+		FROM jobs j
+		LEFT JOIN bookmarks b ON b.id = j.bookmark_id
+		WHERE j.kind = ? AND j.status = ? AND `+ownerFilter("b.user_id")+`
+		ORDER BY j.updated_at, j.id
+	`, append([]any{JobKindArchive, JobStatusRunning}, db.owner()...)...)
+	if err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to list running archive jobs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+	for rows.Next() {
+		var a ActiveJob
+		if err := rows.Scan(&a.JobID, &a.BookmarkID, &a.URL, &a.Title, &a.Attempts, &a.StartedAt); err != nil {
+			return ArchiveStats{}, fmt.Errorf("failed to scan running archive job: %w", err)
+		}
+		s.Active = append(s.Active, a)
+	}
+	if err := rows.Err(); err != nil {
+		return ArchiveStats{}, fmt.Errorf("failed to iterate running archive jobs: %w", err)
+	}
+
+	return s, nil
+}
+
+// AverageArchiveSeconds returns the mean time from attempt to successful
+// archive of db's user's bookmarks, or 0 if none have been archived.
+func (db *DB) AverageArchiveSeconds() (float64, error) {
+	var avg float64
+	err := db.db.QueryRow(`
+		SELECT COALESCE(AVG((julianday(archived_at) - julianday(archive_attempted_at)) * 86400), 0)
+		FROM bookmarks
+		WHERE archive_status = 'ok' AND archived_at IS NOT NULL AND archive_attempted_at IS NOT NULL
+		  AND `+ownerFilter("user_id"), db.owner()...).Scan(&avg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute average archive duration: %w", err)
+	}
+	return avg, nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// TestGetArchiveStats tests the archive dashboard counters.
+func TestGetArchiveStats(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("empty database", func(t *testing.T) {
+		s, err := db.GetArchiveStats()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if s.Pending != 0 || s.InProgress != 0 || s.OK != 0 || s.Error != 0 || s.Queued != 0 || s.AvgDurationSeconds != 0 || len(s.Active) != 0 {
+			t.Errorf("expected zero stats, got %+v", s)
+		}
+	})
+
+	add := func(url string) int64 {
+		t.Helper()
+		id, err := db.AddBookmark(url, url)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		return id
+	}
+	pending := add("https://example.com/pending")
+	ok := add("https://example.com/ok")
+	failed := add("https://example.com/failed")
+	running := add("https://example.com/running")
+	retrying := add("https://example.com/retrying")
+	skipped := add("https://example.com/skipped")
+
+	attempted := time.Now().Add(-10 * time.Second)
+	archived := attempted.Add(4 * time.Second)
+	if err := db.SaveArchiveResult(ok, attempted, &archived, "ok", "", "https://example.com/ok", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveResult(failed, attempted, nil, "error", "boom", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveFailure(retrying, attempted, "error", "timeout", "deadline exceeded"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := db.SaveArchiveResult(skipped, attempted, nil, "skipped", "robots.txt disallows /", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	// Claim order follows enqueue order, so running is claimed first.
+	for _, id := range []int64{running, retrying, pending} {
+		if _, err := db.EnqueueJob(JobKindArchive, id, ""); err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+	}
+	job, err := db.ClaimJob(JobKindArchive, time.Now())
+	if err != nil || job.BookmarkID != running {
+		t.Fatalf("expected to claim job for %d, got %+v (err=%v)", running, job, err)
+	}
+	retry, err := db.ClaimJob(JobKindArchive, time.Now())
+	if err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+	if err := db.RetryJob(retry.ID, "boom", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to retry job: %v", err)
+	}
+
+	s, err := db.GetArchiveStats()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if s.Pending != 1 || s.InProgress != 1 || s.OK != 1 || s.Error != 2 || s.Skipped != 1 {
+		t.Errorf("unexpected status counts: %+v", s)
+	}
+	if len(s.ErrorsByCode) != 2 || s.ErrorsByCode["timeout"] != 1 || s.ErrorsByCode["other"] != 1 {
+		t.Errorf("expected failures grouped by code, got %v", s.ErrorsByCode)
+	}
+	if s.Queued != 1 || s.Retrying != 1 || s.FailedJobs != 0 {
+		t.Errorf("unexpected queue counts: %+v", s)
+	}
+	if s.AvgDurationSeconds < 3.5 || s.AvgDurationSeconds > 4.5 {
+		t.Errorf("expected average duration of about 4s, got %v", s.AvgDurationSeconds)
+	}
+	if len(s.Active) != 1 || s.Active[0].BookmarkID != running || s.Active[0].URL != "https://example.com/running" || s.Active[0].Attempts != 1 {
+		t.Errorf("unexpected active jobs: %+v", s.Active)
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// storageSampleLimit is how many storage samples are kept; older ones are
+// pruned as new ones are written. At the server's hourly cleanup runs that
+// is over two years of history.
+const storageSampleLimit = 20000
+
+// DatabaseSize returns the size of the database in bytes.
+func (db *DB) DatabaseSize() (int64, error) {
+	var pages, pageSize int64
+	if err := db.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := db.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to get page size: %w", err)
+	}
+	return pages * pageSize, nil
+}
+
+// DatabasePath returns the file the database is stored in, or "" for an
+// in-memory database.
+func (db *DB) DatabasePath() (string, error) {
+	var path string
+	if err := db.db.QueryRow(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&path); err != nil {
+		return "", fmt.Errorf("failed to get database path: %w", err)
+	}
+	return path, nil
+}
+
+// BlobStore returns the store used for archived HTML; see SetBlobStore.
+func (db *DB) BlobStore() BlobStore {
+	return db.blobs
+}
+
+// SaveStorageSample records a storage sample, stamping it with the current
+// time if TakenAt is empty, and prunes the oldest samples beyond
+// storageSampleLimit.
+func (db *DB) SaveStorageSample(s StorageSample) (StorageSample, error) {
+	if s.TakenAt == "" {
+		s.TakenAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO storage_samples (taken_at, source, database_bytes, archive_store_bytes, free_bytes)
+		VALUES (?, ?, ?, ?, ?)
+	`, s.TakenAt, s.Source, s.DatabaseBytes, s.ArchiveStoreBytes, s.FreeBytes)
+	if err != nil {
+		return StorageSample{}, fmt.Errorf("failed to save storage sample: %w", err)
+	}
+	if s.ID, err = res.LastInsertId(); err != nil {
+		return StorageSample{}, fmt.Errorf("failed to get storage sample ID: %w", err)
+	}
+	if _, err := db.db.Exec(`DELETE FROM storage_samples WHERE id <= ?`, s.ID-storageSampleLimit); err != nil {
+		return StorageSample{}, fmt.Errorf("failed to prune storage samples: %w", err)
+	}
+	return s, nil
+}
+
+// ListStorageSamples returns the samples taken at or after since, oldest
+// first. A zero since returns every sample.
+func (db *DB) ListStorageSamples(since time.Time) ([]StorageSample, error) {
+	cutoff := ""
+	if !since.IsZero() {
+		cutoff = since.UTC().Format(time.RFC3339)
+	}
+	rows, err := db.db.Query(`
+		SELECT id, taken_at, source, database_bytes, archive_store_bytes, free_bytes
+		FROM storage_samples
+		WHERE ? = '' OR julianday(taken_at) >= julianday(?)
+		ORDER BY julianday(taken_at), id
+	`, cutoff, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage samples: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	samples := []StorageSample{}
+	for rows.Next() {
+		var s StorageSample
+		if err := rows.Scan(&s.ID, &s.TakenAt, &s.Source, &s.DatabaseBytes, &s.ArchiveStoreBytes, &s.FreeBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan storage sample: %w", err)
+		}
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate storage samples: %w", err)
+	}
+	return samples, nil
+}
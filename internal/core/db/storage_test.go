@@ -0,0 +1,72 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// TestStorageSamples tests recording and listing storage samples.
+func TestStorageSamples(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("measures the database", func(t *testing.T) {
+		size, err := db.DatabaseSize()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if size <= 0 {
+			t.Errorf("expected a positive size, got %d", size)
+		}
+		if _, err := db.DatabasePath(); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	now := time.Now().UTC()
+	for i, days := range []int{20, 10, 0} {
+		s := StorageSample{
+			TakenAt:       now.AddDate(0, 0, -days).Format(time.RFC3339),
+			Source:        "test",
+			DatabaseBytes: int64(1000 * (i + 1)),
+			FreeBytes:     -1,
+		}
+		if _, err := db.SaveStorageSample(s); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	t.Run("lists samples oldest first", func(t *testing.T) {
+		samples, err := db.ListStorageSamples(time.Time{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(samples) != 3 || samples[0].DatabaseBytes != 1000 || samples[2].DatabaseBytes != 3000 {
+			t.Errorf("unexpected samples %+v", samples)
+		}
+	})
+
+	t.Run("limits to recent samples", func(t *testing.T) {
+		samples, err := db.ListStorageSamples(now.AddDate(0, 0, -15))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(samples) != 2 || samples[0].DatabaseBytes != 2000 {
+			t.Errorf("unexpected samples %+v", samples)
+		}
+	})
+
+	t.Run("stamps the time", func(t *testing.T) {
+		s, err := db.SaveStorageSample(StorageSample{Source: "test", FreeBytes: -1})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := time.Parse(time.RFC3339, s.TakenAt); err != nil || s.ID == 0 {
+			t.Errorf("expected an ID and time, got %+v", s)
+		}
+	})
+}
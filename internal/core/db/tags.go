@@ -0,0 +1,301 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrInvalidTag is returned when a tag is empty after NormalizeTag.
+var ErrInvalidTag = errors.New("invalid tag")
+
+// ErrTagNotFound is returned when none of the user's bookmarks has a tag
+// being renamed, merged or deleted.
+var ErrTagNotFound = errors.New("tag not found")
+
+// ------------------------------
+// Tag methods
+// ------------------------------
+
+// NormalizeTag cleans up a user-supplied tag: surrounding whitespace and a
+// leading '#' are removed and the result is lowercased. It returns "" for
+// tags that are empty after cleanup.
+func NormalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = strings.TrimLeft(tag, "#")
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normalizes and de-duplicates tags, preserving first-seen order.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// addBookmarkTags attaches tags to a bookmark using the given executor
+// (either the DB or an open transaction). Existing tags are left in place.
+func addBookmarkTags(exec execer, bookmarkID int64, tags []string) error {
+	for _, tag := range normalizeTags(tags) {
+		if _, err := exec.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return fmt.Errorf("failed to create tag %q: %w", tag, err)
+		}
+		if _, err := exec.Exec(`
+			INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, bookmarkID, tag); err != nil {
+			return fmt.Errorf("failed to tag bookmark %d with %q: %w", bookmarkID, tag, err)
+		}
+	}
+	return nil
+}
+
+// AddBookmarkTags attaches tags to a bookmark, creating any tags that don't exist yet.
+// Tags already on the bookmark are left untouched.
+func (db *DB) AddBookmarkTags(bookmarkID int64, tags []string) error {
+	if _, err := db.GetBookmark(bookmarkID); err != nil {
+		return err
+	}
+	return addBookmarkTags(db.db, bookmarkID, tags)
+}
+
+// SetBookmarkTags replaces all tags on a bookmark.
+func (db *DB) SetBookmarkTags(bookmarkID int64, tags []string) error {
+	if _, err := db.GetBookmark(bookmarkID); err != nil {
+		return err
+	}
+	if _, err := db.db.Exec("DELETE FROM bookmark_tags WHERE bookmark_id = ?", bookmarkID); err != nil {
+		return fmt.Errorf("failed to clear bookmark tags: %w", err)
+	}
+	return addBookmarkTags(db.db, bookmarkID, tags)
+}
+
+// ListBookmarkTags returns the tags on a bookmark in alphabetical order.
+func (db *DB) ListBookmarkTags(bookmarkID int64) ([]string, error) {
+	if err := db.checkOwner(bookmarkID); err != nil {
+		return nil, err
+	}
+	rows, err := db.db.Query(`
+		SELECT t.name
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.bookmark_id = ?
+		ORDER BY t.name
+	`, bookmarkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bookmark tags: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		out = append(out, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag rows: %w", err)
+	}
+	return out, nil
+}
+
+// ListTags returns the tags on the user's bookmarks, with how many each is
+// on, in alphabetical order.
+func (db *DB) ListTags() ([]TagCount, error) {
+	return db.queryTagCounts(`
+		SELECT t.name, COUNT(*)
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		JOIN bookmarks b ON b.id = bt.bookmark_id
+		WHERE `+ownerFilter("b.user_id")+`
+		GROUP BY t.id
+		ORDER BY t.name
+	`, db.owner()...)
+}
+
+// SuggestTags returns up to limit of the user's tags starting with prefix
+// (as NormalizeTag cleans it up), the most used first, for autocompleting
+// tag fields. An empty prefix suggests the most used tags.
+func (db *DB) SuggestTags(prefix string, limit int) ([]TagCount, error) {
+	prefix = NormalizeTag(prefix)
+	return db.queryTagCounts(`
+		SELECT t.name, COUNT(*)
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		JOIN bookmarks b ON b.id = bt.bookmark_id
+		WHERE t.name LIKE ? ESCAPE '\'
+		  AND `+ownerFilter("b.user_id")+`
+		GROUP BY t.id
+		ORDER BY COUNT(*) DESC, t.name
+		LIMIT ?
+	`, append(append([]any{escapeLike(prefix) + "%"}, db.owner()...), limit)...)
+}
+
+// queryTagCounts runs a query for tag names and counts.
+func (db *DB) queryTagCounts(query string, args ...any) ([]TagCount, error) {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag rows: %w", err)
+	}
+	return tags, nil
+}
+
+// RenameTag renames tag from to to on the user's bookmarks and returns how
+// many bookmarks changed. Renaming to a tag already in use merges the two;
+// see MergeTags.
+func (db *DB) RenameTag(from, to string) (int, error) {
+	return db.MergeTags([]string{from}, to)
+}
+
+// MergeTags replaces each of tags with into on the user's bookmarks, in one
+// transaction, and returns how many bookmarks had any of them. into itself
+// may be among tags. It returns ErrInvalidTag if into is empty and
+// ErrTagNotFound if no bookmark has any of tags. Other users' bookmarks
+// keep their tags, as tags are shared between users.
+func (db *DB) MergeTags(tags []string, into string) (int, error) {
+	into = NormalizeTag(into)
+	if into == "" {
+		return 0, fmt.Errorf("%w: the tag to merge into is empty", ErrInvalidTag)
+	}
+	var sources []string
+	for _, t := range normalizeTags(tags) {
+		if t != into {
+			sources = append(sources, t)
+		}
+	}
+	return db.replaceTags(sources, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", into); err != nil {
+			return fmt.Errorf("failed to create tag %q: %w", into, err)
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id)
+			SELECT bt.bookmark_id, (SELECT id FROM tags WHERE name = ?)
+			FROM bookmark_tags bt
+			JOIN tags t ON t.id = bt.tag_id
+			JOIN bookmarks b ON b.id = bt.bookmark_id
+			WHERE t.name IN (`+tagPlaceholders(sources)+`)
+			  AND `+ownerFilter("b.user_id")+`
+		`, append(append([]any{into}, tagArgs(sources)...), db.owner()...)...)
+		if err != nil {
+			return fmt.Errorf("failed to tag bookmarks with %q: %w", into, err)
+		}
+		return nil
+	})
+}
+
+// DeleteTag removes tag from the user's bookmarks and returns how many
+// bookmarks had it, or ErrTagNotFound if none did.
+func (db *DB) DeleteTag(tag string) (int, error) {
+	tag = NormalizeTag(tag)
+	if tag == "" {
+		return 0, fmt.Errorf("%w: empty tag", ErrInvalidTag)
+	}
+	return db.replaceTags([]string{tag}, nil)
+}
+
+// replaceTags runs add, if given, and then removes tags from the user's
+// bookmarks, all in one transaction, dropping the tags no bookmark has any
+// more. It returns how many bookmarks had any of tags.
+func (db *DB) replaceTags(tags []string, add func(*sql.Tx) error) (int, error) {
+	if len(tags) == 0 {
+		return 0, ErrTagNotFound
+	}
+
+	// The bookmarks are counted before the transaction starts, so that it
+	// only writes; see CreateBookmarks.
+	in := tagPlaceholders(tags)
+	var n int
+	if err := db.db.QueryRow(`
+		SELECT COUNT(DISTINCT bt.bookmark_id)
+		FROM bookmark_tags bt
+		JOIN tags t ON t.id = bt.tag_id
+		JOIN bookmarks b ON b.id = bt.bookmark_id
+		WHERE t.name IN (`+in+`)
+		  AND `+ownerFilter("b.user_id")+`
+	`, append(tagArgs(tags), db.owner()...)...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count tagged bookmarks: %w", err)
+	}
+	if n == 0 {
+		return 0, ErrTagNotFound
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+	if add != nil {
+		if err := add(tx); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM bookmark_tags
+		WHERE tag_id IN (SELECT id FROM tags WHERE name IN (`+in+`))
+		  AND bookmark_id IN (SELECT id FROM bookmarks WHERE `+ownerFilter("user_id")+`)
+	`, append(tagArgs(tags), db.owner()...)...); err != nil {
+		return 0, fmt.Errorf("failed to remove tags: %w", err)
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM tags
+		WHERE name IN (`+in+`)
+		  AND id NOT IN (SELECT tag_id FROM bookmark_tags)
+	`, tagArgs(tags)...); err != nil {
+		return 0, fmt.Errorf("failed to remove unused tags: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tag changes: %w", err)
+	}
+	return n, nil
+}
+
+// tagPlaceholders returns the "?, ?" list for an IN clause over tags.
+func tagPlaceholders(tags []string) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+}
+
+// tagArgs returns tags as query arguments.
+func tagArgs(tags []string) []any {
+	args := make([]any, len(tags))
+	for i, t := range tags {
+		args[i] = t
+	}
+	return args
+}
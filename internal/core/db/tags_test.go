@@ -0,0 +1,247 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNormalizeTag tests tag cleanup.
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"go":      "go",
+		"#Go":     "go",
+		"  HTTP ": "http",
+		"##x":     "x",
+		"#":       "",
+		"   ":     "",
+	}
+	for in, want := range tests {
+		if got := NormalizeTag(in); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestCreateBookmarkWithTags tests creating a bookmark with tags.
+func TestCreateBookmarkWithTags(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("stores normalized, de-duplicated tags", func(t *testing.T) {
+		id, err := db.CreateBookmark(NewBookmark{
+			URL:   "https://example.com",
+			Title: "Example",
+			Tags:  []string{"Go", "#http", "go", ""},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		tags, err := db.ListBookmarkTags(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if want := []string{"go", "http"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("expected tags %v, got %v", want, tags)
+		}
+	})
+
+	t.Run("rejects invalid URL without inserting", func(t *testing.T) {
+		before, _ := db.ListBookmarks(0)
+		if _, err := db.CreateBookmark(NewBookmark{URL: "not a url", Tags: []string{"x"}}); err == nil {
+			t.Fatal("expected error for invalid URL")
+		}
+		after, _ := db.ListBookmarks(0)
+		if len(after) != len(before) {
+			t.Errorf("expected no bookmark to be inserted")
+		}
+	})
+}
+
+// TestBookmarkTags tests adding, replacing, and removing tags.
+func TestBookmarkTags(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("AddBookmarkTags merges with existing tags", func(t *testing.T) {
+		if err := db.AddBookmarkTags(id, []string{"b", "a"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.AddBookmarkTags(id, []string{"c", "a"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		tags, _ := db.ListBookmarkTags(id)
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("expected tags %v, got %v", want, tags)
+		}
+	})
+
+	t.Run("SetBookmarkTags replaces tags", func(t *testing.T) {
+		if err := db.SetBookmarkTags(id, []string{"z"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		tags, _ := db.ListBookmarkTags(id)
+		if want := []string{"z"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("expected tags %v, got %v", want, tags)
+		}
+	})
+
+	t.Run("deleting a bookmark removes its tags", func(t *testing.T) {
+		if err := db.DeleteBookmark(id); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		tags, err := db.ListBookmarkTags(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(tags) != 0 {
+			t.Errorf("expected no tags, got %v", tags)
+		}
+	})
+
+	t.Run("returns error for non-existent bookmark", func(t *testing.T) {
+		if err := db.AddBookmarkTags(99999, []string{"x"}); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+		if err := db.SetBookmarkTags(99999, []string{"x"}); err == nil {
+			t.Error("expected error for non-existent bookmark")
+		}
+	})
+}
+
+// TestTagManagement tests listing, suggesting, renaming, merging and
+// deleting tags, and that they stay within the user's bookmarks.
+func TestTagManagement(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	local := db.ForUser(LocalUserID)
+	a, _ := local.CreateBookmark(NewBookmark{URL: "https://a.example.com", Tags: []string{"golang", "db"}})
+	b, _ := local.CreateBookmark(NewBookmark{URL: "https://b.example.com", Tags: []string{"go", "go_tips"}})
+	bob, err := db.CreateUser("bob", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	bobs, _ := db.ForUser(bob.ID).CreateBookmark(NewBookmark{URL: "https://bob.example.com", Tags: []string{"golang"}})
+
+	tags, err := local.ListTags()
+	if want := []TagCount{{"db", 1}, {"go", 1}, {"go_tips", 1}, {"golang", 1}}; err != nil || !reflect.DeepEqual(tags, want) {
+		t.Fatalf("ListTags() = %v, %v, want %v", tags, err, want)
+	}
+	suggested, err := local.SuggestTags("#GO_", 10)
+	if err != nil || len(suggested) != 1 || suggested[0].Name != "go_tips" {
+		t.Errorf("expected _ to match itself only, got %v, %v", suggested, err)
+	}
+
+	t.Run("rename merges into an existing tag", func(t *testing.T) {
+		n, err := local.RenameTag("golang", "Go")
+		if err != nil || n != 1 {
+			t.Fatalf("RenameTag() = %d, %v", n, err)
+		}
+		if got, _ := local.ListBookmarkTags(a); !reflect.DeepEqual(got, []string{"db", "go"}) {
+			t.Errorf("expected golang renamed to go, got %v", got)
+		}
+		if got, _ := db.ForUser(bob.ID).ListBookmarkTags(bobs); !reflect.DeepEqual(got, []string{"golang"}) {
+			t.Errorf("expected bob's tags untouched, got %v", got)
+		}
+		results, err := local.SearchBookmarks("tag:go", BookmarkFilter{}, 0)
+		if err != nil || len(results) != 2 {
+			t.Errorf("expected both bookmarks found by the new tag, got %d, %v", len(results), err)
+		}
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		n, err := local.MergeTags([]string{"go", "go_tips", "db"}, "go")
+		if err != nil || n != 2 {
+			t.Fatalf("MergeTags() = %d, %v", n, err)
+		}
+		if got, _ := local.ListBookmarkTags(b); !reflect.DeepEqual(got, []string{"go"}) {
+			t.Errorf("expected only go left, got %v", got)
+		}
+		tags, _ := local.ListTags()
+		if want := []TagCount{{"go", 2}}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("ListTags() = %v, want %v", tags, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if _, err := local.DeleteTag("golang"); !errors.Is(err, ErrTagNotFound) {
+			t.Errorf("expected ErrTagNotFound for another user's tag, got %v", err)
+		}
+		n, err := local.DeleteTag("go")
+		if err != nil || n != 2 {
+			t.Fatalf("DeleteTag() = %d, %v", n, err)
+		}
+		if tags, _ := local.ListTags(); len(tags) != 0 {
+			t.Errorf("expected no tags left, got %v", tags)
+		}
+	})
+
+	t.Run("rejects empty tags", func(t *testing.T) {
+		if _, err := local.RenameTag("golang", " # "); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected ErrInvalidTag, got %v", err)
+		}
+	})
+}
+
+// TestTagManagementWhileWriting tests that renaming a tag waits out another
+// connection's write instead of failing with "database is locked".
+func TestTagManagementWhileWriting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookmarkd.db")
+	db, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	if _, err := db.CreateBookmark(NewBookmark{URL: "https://example.com", Tags: []string{"old"}}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	other, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		t.Fatalf("failed to open second connection: %v", err)
+	}
+	t.Cleanup(func() { _ = other.Close() })
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE bookmarks SET title = title`); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = tx.Commit()
+	}()
+
+	if n, err := db.RenameTag("old", "new"); err != nil || n != 1 {
+		t.Errorf("expected the rename to wait for the writer, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SearchTokenizer is how bookmark_search splits text into the words queries
+// match. Changing it rebuilds the index; see Reindex.
+type SearchTokenizer string
+
+const (
+	// TokenizerUnicode61 splits on Unicode word boundaries, folds case and
+	// removes diacritics in every script, so "cafe" matches "café". It is
+	// the default.
+	TokenizerUnicode61 SearchTokenizer = "unicode61"
+	// TokenizerPorter stems English words, so "run" matches "running".
+	// Like SQLite's porter tokenizer it only folds ASCII letters.
+	TokenizerPorter SearchTokenizer = "porter"
+	// TokenizerTrigram is TokenizerUnicode61 plus an index of every three
+	// characters of Chinese, Japanese and Korean text, which isn't written
+	// with spaces between words. FTS4 has no trigram tokenizer, so the text
+	// is split by the search_trigrams function registered on bookmarkd's
+	// connections; with it selected, bookmarks can only be written through
+	// bookmarkd.
+	TokenizerTrigram SearchTokenizer = "trigram"
+)
+
+// SearchTokenizers lists the tokenizers Reindex accepts.
+var SearchTokenizers = []SearchTokenizer{TokenizerUnicode61, TokenizerPorter, TokenizerTrigram}
+
+// ParseSearchTokenizer returns the tokenizer named s.
+func ParseSearchTokenizer(s string) (SearchTokenizer, error) {
+	for _, t := range SearchTokenizers {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown search tokenizer %q (want %s, %s or %s)", s, TokenizerUnicode61, TokenizerPorter, TokenizerTrigram)
+}
+
+// fts4Tokenize returns the FTS4 tokenize option bookmark_search is created with.
+func (t SearchTokenizer) fts4Tokenize() string {
+	if t == TokenizerPorter {
+		return "porter"
+	}
+	return `unicode61 "remove_diacritics=2"`
+}
+
+// index wraps expr, a text value written to bookmark_search, in whatever
+// rewriting the tokenizer needs before FTS4 sees it.
+func (t SearchTokenizer) index(expr string) string {
+	if t == TokenizerTrigram {
+		return "search_trigrams(" + expr + ")"
+	}
+	return expr
+}
+
+// sqliteDriver is the database/sql driver NewSQLiteDB opens: SQLite with
+// the functions bookmark_search's triggers and ClaimJob may call.
+const sqliteDriver = "sqlite3_bookmarkd"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("search_trigrams", searchTrigrams, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("url_host", JobHost, true)
+		},
+	})
+}
+
+// isCJK reports whether r is written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchTrigrams rewrites each run of CJK characters in s as the words
+// starting at each of its characters, three characters long or cut short by
+// the end of the run, so "東京都庁" becomes "東京都 京都庁 都庁 庁". Any
+// substring then matches as a phrase of its trigrams, or as a prefix query if
+// it is shorter. Other text is left alone.
+func searchTrigrams(s string) string {
+	if !strings.ContainsFunc(s, isCJK) {
+		return s
+	}
+	var b strings.Builder
+	var run []rune
+	flush := func() {
+		for i := range run {
+			b.WriteByte(' ')
+			b.WriteString(string(run[i:min(i+3, len(run))]))
+		}
+		if len(run) > 0 {
+			b.WriteByte(' ')
+		}
+		run = run[:0]
+	}
+	for _, r := range s {
+		if isCJK(r) {
+			run = append(run, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// trigramQuery returns the MATCH terms for a run of CJK characters indexed
+// by searchTrigrams, limited to column if it isn't empty: its trigrams as a
+// phrase, or a prefix query for runs under three characters.
+func trigramQuery(run []rune, column string) []string {
+	if len(run) < 3 {
+		if column != "" {
+			return []string{column + ":" + string(run) + "*"}
+		}
+		return []string{string(run) + "*"}
+	}
+	var grams []string
+	for i := 0; i+3 <= len(run); i++ {
+		grams = append(grams, string(run[i:i+3]))
+	}
+	if column == "" {
+		return []string{`"` + strings.Join(grams, " ") + `"`}
+	}
+	terms := []string{`"` + strings.Join(grams, " ") + `"`}
+	for _, g := range grams {
+		terms = append(terms, column+":"+g)
+	}
+	return terms
+}
+
+// splitCJK separates the CJK runs out of words, returning the words with
+// them removed and the runs.
+func splitCJK(words []string) ([]string, [][]rune) {
+	var rest []string
+	var runs [][]rune
+	for _, w := range words {
+		var word, run []rune
+		for _, r := range w {
+			if isCJK(r) {
+				if len(word) > 0 {
+					rest, word = append(rest, string(word)), nil
+				}
+				run = append(run, r)
+				continue
+			}
+			if len(run) > 0 {
+				runs, run = append(runs, run), nil
+			}
+			word = append(word, r)
+		}
+		if len(word) > 0 {
+			rest = append(rest, string(word))
+		}
+		if len(run) > 0 {
+			runs = append(runs, run)
+		}
+	}
+	return rest, runs
+}
+
+// searchTokenizer returns the tokenizer recorded in search_settings.
+func searchTokenizer(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (SearchTokenizer, error) {
+	var name string
+	if err := q.QueryRow(`SELECT tokenizer FROM search_settings WHERE id = 1`).Scan(&name); err != nil {
+		return "", fmt.Errorf("failed to get search tokenizer: %w", err)
+	}
+	return ParseSearchTokenizer(name)
+}
+
+// SearchTokenizer returns the tokenizer the search index was built with.
+func (db *DB) SearchTokenizer() (SearchTokenizer, error) {
+	return searchTokenizer(db.db)
+}
+
+// Reindex rebuilds the search index from scratch with tokenizer t, or with
+// the current tokenizer if t is empty, and returns the number of bookmarks
+// indexed. It covers every user's bookmarks.
+func (db *DB) Reindex(t SearchTokenizer) (int64, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("failed to rollback transaction: %v", err)
+		}
+	}()
+
+	if t == "" {
+		if t, err = searchTokenizer(tx); err != nil {
+			return 0, err
+		}
+	} else if t, err = ParseSearchTokenizer(string(t)); err != nil {
+		return 0, err
+	}
+	if err := applySearchTokenizer(tx, t); err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM bookmark_search`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count indexed bookmarks: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit reindex: %w", err)
+	}
+	return n, nil
+}
+
+// searchTriggers are the names of the triggers keeping bookmark_search current.
+var searchTriggers = []string{
+	"bookmark_search_insert",
+	"bookmark_search_update",
+	"bookmark_search_delete",
+	"bookmark_search_tag_insert",
+	"bookmark_search_tag_delete",
+	"bookmark_search_tag_rename",
+	"bookmark_search_archive_insert",
+	"bookmark_search_archive_update",
+	"bookmark_search_archive_delete",
+}
+
+// searchTagsSQL and searchContentSQL select the tags and content column
+// values of the bookmark whose ID is the %s expression.
+const (
+	searchTagsSQL = `COALESCE((SELECT group_concat(t.name, ' ') FROM bookmark_tags bt
+	                 JOIN tags t ON t.id = bt.tag_id WHERE bt.bookmark_id = %s), '')`
+	searchContentSQL = `COALESCE((SELECT a.readable_text FROM bookmark_archives a WHERE a.bookmark_id = %s
+	                 ORDER BY a.captured_at DESC, a.id DESC LIMIT 1), '')`
+)
+
+// searchRowSQL selects bookmark_search rows for the bookmarks matching where.
+func searchRowSQL(t SearchTokenizer, where string) string {
+	return `INSERT INTO bookmark_search (docid, title, url, notes, tags, content)
+		SELECT b.id, ` + t.index("COALESCE(b.title, '')") + `, ` + t.index("b.url") + `, ` + t.index("COALESCE(b.notes, '')") + `,
+		       ` + t.index(fmt.Sprintf(searchTagsSQL, "b.id")) + `,
+		       ` + t.index(fmt.Sprintf(searchContentSQL, "b.id")) + `
+		FROM bookmarks b` + where
+}
+
+// applySearchTokenizer recreates bookmark_search and its triggers (as first
+// created by migration 0020) for tokenizer t, refills it and records t in
+// search_settings.
+func applySearchTokenizer(tx *sql.Tx, t SearchTokenizer) error {
+	for _, name := range searchTriggers {
+		if _, err := tx.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+			return fmt.Errorf("failed to drop trigger %s: %w", name, err)
+		}
+	}
+	if _, err := tx.Exec(`DROP TABLE IF EXISTS bookmark_search`); err != nil {
+		return fmt.Errorf("failed to drop search index: %w", err)
+	}
+
+	tags := func(id string) string { return t.index(fmt.Sprintf(searchTagsSQL, id)) }
+	content := func(id string) string { return t.index(fmt.Sprintf(searchContentSQL, id)) }
+	stmts := []string{
+		`CREATE VIRTUAL TABLE bookmark_search USING fts4(title, url, notes, tags, content, tokenize=` + t.fts4Tokenize() + `)`,
+		`CREATE TRIGGER bookmark_search_insert AFTER INSERT ON bookmarks
+		BEGIN
+			` + searchRowSQL(t, " WHERE b.id = NEW.id") + `;
+		END`,
+		`CREATE TRIGGER bookmark_search_update AFTER UPDATE OF title, url, notes ON bookmarks
+		BEGIN
+			DELETE FROM bookmark_search WHERE docid = OLD.id;
+			` + searchRowSQL(t, " WHERE b.id = NEW.id") + `;
+		END`,
+		`CREATE TRIGGER bookmark_search_delete AFTER DELETE ON bookmarks
+		BEGIN
+			DELETE FROM bookmark_search WHERE docid = OLD.id;
+		END`,
+		`CREATE TRIGGER bookmark_search_tag_insert AFTER INSERT ON bookmark_tags
+		BEGIN
+			UPDATE bookmark_search SET tags = ` + tags("NEW.bookmark_id") + ` WHERE docid = NEW.bookmark_id;
+		END`,
+		`CREATE TRIGGER bookmark_search_tag_delete AFTER DELETE ON bookmark_tags
+		BEGIN
+			UPDATE bookmark_search SET tags = ` + tags("OLD.bookmark_id") + ` WHERE docid = OLD.bookmark_id;
+		END`,
+		`CREATE TRIGGER bookmark_search_tag_rename AFTER UPDATE OF name ON tags
+		BEGIN
+			UPDATE bookmark_search SET tags = ` + tags("bookmark_search.docid") + `
+			WHERE docid IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = NEW.id);
+		END`,
+		`CREATE TRIGGER bookmark_search_archive_insert AFTER INSERT ON bookmark_archives
+		BEGIN
+			UPDATE bookmark_search SET content = ` + content("NEW.bookmark_id") + ` WHERE docid = NEW.bookmark_id;
+		END`,
+		`CREATE TRIGGER bookmark_search_archive_update AFTER UPDATE OF readable_text ON bookmark_archives
+		BEGIN
+			UPDATE bookmark_search SET content = ` + content("NEW.bookmark_id") + ` WHERE docid = NEW.bookmark_id;
+		END`,
+		`CREATE TRIGGER bookmark_search_archive_delete AFTER DELETE ON bookmark_archives
+		BEGIN
+			UPDATE bookmark_search SET content = ` + content("OLD.bookmark_id") + ` WHERE docid = OLD.bookmark_id;
+		END`,
+		searchRowSQL(t, ""),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE search_settings SET tokenizer = ? WHERE id = 1`, string(t)); err != nil {
+		return fmt.Errorf("failed to save search tokenizer: %w", err)
+	}
+	return nil
+}
+
+// useDefaultSearchTokenizer is the data migration for 0029-search-tokenizer:
+// it rebuilds the index with TokenizerUnicode61, which removes diacritics in
+// every script rather than only Latin ones.
+func useDefaultSearchTokenizer(tx *sql.Tx) error {
+	return applySearchTokenizer(tx, TokenizerUnicode61)
+}
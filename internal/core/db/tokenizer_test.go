@@ -0,0 +1,148 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSearchTrigrams tests how CJK text is split for the trigram tokenizer.
+func TestSearchTrigrams(t *testing.T) {
+	tests := map[string]string{
+		"plain text":     "plain text",
+		"東京都庁":           "東京都 京都庁 都庁 庁",
+		"Visit 東京 today": "Visit 東京 京 today",
+		"カフェ":            "カフェ フェ ェ",
+	}
+	for in, want := range tests {
+		if got := strings.Join(strings.Fields(searchTrigrams(in)), " "); got != want {
+			t.Errorf("searchTrigrams(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestMatchExpression_Trigram tests how CJK query words match a trigram index.
+func TestMatchExpression_Trigram(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"東京都庁", `"東京都 京都庁"`},
+		{"東京", `東京*`},
+		{"title:東京都", `"東京都" title:東京都`},
+		{"tokyo東京", `東京* "tokyo"`},
+	}
+	for _, tt := range tests {
+		q, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.query, err)
+		}
+		if got := matchExpression(q.terms, TokenizerTrigram); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+}
+
+// TestReindex tests switching the search tokenizer.
+func TestReindex(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	accented, _ := db.CreateBookmark(NewBookmark{URL: "https://a.example.com", Title: "Crème brûlée at the café"})
+	running, _ := db.CreateBookmark(NewBookmark{URL: "https://b.example.com", Title: "Running shoes"})
+	tokyo, _ := db.CreateBookmark(NewBookmark{URL: "https://c.example.com", Title: "東京都庁舎の写真"})
+
+	search := func(t *testing.T, query string) []int64 {
+		t.Helper()
+		results, err := db.SearchBookmarks(query, BookmarkFilter{}, 0)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", query, err)
+		}
+		var ids []int64
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	expect := func(t *testing.T, query string, want int64) {
+		t.Helper()
+		if got := search(t, query); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: expected [%d], got %v", query, want, got)
+		}
+	}
+
+	t.Run("defaults to unicode61 without diacritics", func(t *testing.T) {
+		tokenizer, err := db.SearchTokenizer()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if tokenizer != TokenizerUnicode61 {
+			t.Errorf("expected %s, got %s", TokenizerUnicode61, tokenizer)
+		}
+		expect(t, "creme brulee cafe", accented)
+		if got := search(t, "run"); len(got) != 0 {
+			t.Errorf("expected no stemming, got %v", got)
+		}
+		if got := search(t, "都庁"); len(got) != 0 {
+			t.Errorf("expected no match inside CJK text, got %v", got)
+		}
+	})
+
+	t.Run("porter stems English", func(t *testing.T) {
+		n, err := db.Reindex(TokenizerPorter)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 bookmarks indexed, got %d", n)
+		}
+		expect(t, "run", running)
+		expect(t, "shoe", running)
+	})
+
+	t.Run("trigram matches inside CJK text", func(t *testing.T) {
+		if _, err := db.Reindex(TokenizerTrigram); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		expect(t, "都庁", tokyo)
+		expect(t, "庁舎の写真", tokyo)
+		expect(t, "title:京都庁", tokyo)
+		expect(t, "cafe", accented)
+
+		// The triggers index new and changed bookmarks the same way.
+		osaka, _ := db.CreateBookmark(NewBookmark{URL: "https://d.example.com", Title: "大阪城", Tags: []string{"日本旅行"}})
+		expect(t, "阪城", osaka)
+		expect(t, "tag:本旅", osaka)
+		now := time.Now()
+		if err := db.SaveArchiveResult(osaka, now, &now, "ok", "", "https://d.example.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if err := db.SaveBookmarkReadable(BookmarkReadable{BookmarkID: osaka, TextContent: "天守閣から見た景色"}); err != nil {
+			t.Fatalf("failed to save readable: %v", err)
+		}
+		expect(t, "text:見た景", osaka)
+	})
+
+	t.Run("keeps the current tokenizer by default", func(t *testing.T) {
+		if _, err := db.Reindex(""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if tokenizer, _ := db.SearchTokenizer(); tokenizer != TokenizerTrigram {
+			t.Errorf("expected %s, got %s", TokenizerTrigram, tokenizer)
+		}
+		expect(t, "都庁", tokyo)
+	})
+
+	t.Run("rejects unknown tokenizers", func(t *testing.T) {
+		if _, err := db.Reindex("icu"); err == nil {
+			t.Error("expected an error")
+		}
+		if tokenizer, _ := db.SearchTokenizer(); tokenizer != TokenizerTrigram {
+			t.Errorf("expected the tokenizer to stay %s, got %s", TokenizerTrigram, tokenizer)
+		}
+	})
+}
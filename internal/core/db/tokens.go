@@ -0,0 +1,175 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned by AuthenticateAPIToken for tokens that don't
+// exist or were revoked.
+var ErrInvalidToken = errors.New("invalid API token")
+
+// apiTokenPrefix starts every API token, so they are easy to recognise in
+// configs and secret scanners.
+const apiTokenPrefix = "bmk_"
+
+// APITokenScopeBookmarklet limits a token to saving bookmarks through the
+// bookmarklet. Tokens with an empty scope may use the whole API.
+const APITokenScopeBookmarklet = "bookmarklet"
+
+// hashAPIToken returns the hex SHA-256 of a token, which is what is stored.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken creates a token with a name and a quota in requests per
+// hour (0 for the instance default), owned by db's user or LocalUserID if
+// db isn't scoped. It returns the token's record and the
+// token itself, which can't be recovered later.
+func (db *DB) CreateAPIToken(name string, quota int) (APIToken, string, error) {
+	return db.createAPIToken(name, "", quota)
+}
+
+// CreateBookmarkletToken creates a token scoped to the bookmarklet, with
+// the instance default quota.
+func (db *DB) CreateBookmarkletToken(name string) (APIToken, string, error) {
+	return db.createAPIToken(name, APITokenScopeBookmarklet, 0)
+}
+
+func (db *DB) createAPIToken(name, scope string, quota int) (APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIToken{}, "", fmt.Errorf("API token needs a name")
+	}
+	if quota < 0 {
+		return APIToken{}, "", fmt.Errorf("API token quota can't be negative")
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIToken{}, "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+
+	t := APIToken{UserID: db.userID, Name: name, Scope: scope, Quota: quota, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	if t.UserID == 0 {
+		t.UserID = LocalUserID
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash, scope, quota, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, t.UserID, t.Name, hashAPIToken(token), t.Scope, t.Quota, t.CreatedAt)
+	if err != nil {
+		return APIToken{}, "", fmt.Errorf("failed to create API token: %w", err)
+	}
+	if t.ID, err = res.LastInsertId(); err != nil {
+		return APIToken{}, "", fmt.Errorf("failed to get API token ID: %w", err)
+	}
+	return t, token, nil
+}
+
+// AuthenticateAPIToken returns the record of a token and notes that it was
+// used, or ErrInvalidToken if there is no such token.
+func (db *DB) AuthenticateAPIToken(token string) (APIToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return APIToken{}, ErrInvalidToken
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var t APIToken
+	var lastUsed sql.NullString
+	err := db.db.QueryRow(`
+		UPDATE api_tokens SET last_used_at = ?
+		WHERE token_hash = ?
+		RETURNING id, user_id, name, scope, quota, created_at, last_used_at
+	`, now, hashAPIToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Quota, &t.CreatedAt, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, ErrInvalidToken
+	}
+	if err != nil {
+		return APIToken{}, fmt.Errorf("failed to authenticate API token: %w", err)
+	}
+	t.LastUsedAt = lastUsed.String
+	return t, nil
+}
+
+// ListAPITokens returns the API tokens of db's user, or every token if db
+// isn't scoped, oldest first.
+func (db *DB) ListAPITokens() ([]APIToken, error) {
+	rows, err := db.db.Query(`
+		SELECT id, user_id, name, scope, quota, created_at, COALESCE(last_used_at, '')
+		FROM api_tokens
+		WHERE `+ownerFilter("user_id")+`
+		ORDER BY id
+	`, db.owner()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Quota, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// GetAPIToken returns the record of the token with the given ID.
+func (db *DB) GetAPIToken(id int64) (APIToken, error) {
+	var t APIToken
+	err := db.db.QueryRow(`
+		SELECT id, user_id, name, scope, quota, created_at, COALESCE(last_used_at, '')
+		FROM api_tokens WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Quota, &t.CreatedAt, &t.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, fmt.Errorf("API token not found: %d", id)
+	}
+	if err != nil {
+		return APIToken{}, fmt.Errorf("failed to get API token: %w", err)
+	}
+	return t, nil
+}
+
+// SetAPITokenQuota changes a token's quota in requests per hour (0 for the
+// instance default).
+func (db *DB) SetAPITokenQuota(id int64, quota int) error {
+	if quota < 0 {
+		return fmt.Errorf("API token quota can't be negative")
+	}
+	return db.updateAPIToken(id, `UPDATE api_tokens SET quota = ? WHERE id = ? AND `+ownerFilter("user_id"), append([]any{quota, id}, db.owner()...)...)
+}
+
+// RevokeAPIToken deletes a token; requests using it are refused from then on.
+func (db *DB) RevokeAPIToken(id int64) error {
+	return db.updateAPIToken(id, `DELETE FROM api_tokens WHERE id = ? AND `+ownerFilter("user_id"), append([]any{id}, db.owner()...)...)
+}
+
+func (db *DB) updateAPIToken(id int64, query string, args ...any) error {
+	res, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update API token: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("API token not found: %d", id)
+	}
+	return nil
+}
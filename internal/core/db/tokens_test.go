@@ -0,0 +1,105 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestAPITokens tests creating, authenticating and revoking API tokens.
+func TestAPITokens(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	created, token, err := db.CreateAPIToken(" Reader app ", 500)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.Name != "Reader app" || created.Quota != 500 || !strings.HasPrefix(token, apiTokenPrefix) {
+		t.Fatalf("unexpected token %+v, %q", created, token)
+	}
+
+	t.Run("stores only a hash", func(t *testing.T) {
+		var n int
+		if err := db.db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE token_hash = ?`, token).Scan(&n); err != nil {
+			t.Fatalf("failed to query tokens: %v", err)
+		}
+		if n != 0 {
+			t.Error("expected the token not to be stored in plain text")
+		}
+	})
+
+	t.Run("authenticates and records use", func(t *testing.T) {
+		got, err := db.AuthenticateAPIToken(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.ID != created.ID || got.Quota != 500 || got.LastUsedAt == "" {
+			t.Errorf("unexpected token: %+v", got)
+		}
+		for _, bad := range []string{"", "bmk_nope", strings.TrimPrefix(token, apiTokenPrefix)} {
+			if _, err := db.AuthenticateAPIToken(bad); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("expected ErrInvalidToken for %q, got %v", bad, err)
+			}
+		}
+	})
+
+	t.Run("changes quotas", func(t *testing.T) {
+		if err := db.SetAPITokenQuota(created.ID, 0); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.GetAPIToken(created.ID)
+		if err != nil || got.Quota != 0 {
+			t.Errorf("expected quota 0, got %+v, %v", got, err)
+		}
+		if err := db.SetAPITokenQuota(created.ID, -1); err == nil {
+			t.Error("expected error for a negative quota")
+		}
+	})
+
+	t.Run("scopes bookmarklet tokens", func(t *testing.T) {
+		created, token, err := db.CreateBookmarkletToken("Laptop")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got, err := db.AuthenticateAPIToken(token)
+		if err != nil || got.ID != created.ID || got.Scope != APITokenScopeBookmarklet {
+			t.Errorf("expected a bookmarklet token, got %+v, %v", got, err)
+		}
+		if got, _ := db.GetAPIToken(created.ID); got.Scope != APITokenScopeBookmarklet {
+			t.Errorf("expected the scope to be stored, got %q", got.Scope)
+		}
+		if err := db.RevokeAPIToken(created.ID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		if _, _, err := db.CreateAPIToken(" ", 0); err == nil {
+			t.Error("expected error for a missing name")
+		}
+		if _, _, err := db.CreateAPIToken("x", -5); err == nil {
+			t.Error("expected error for a negative quota")
+		}
+	})
+
+	t.Run("revokes", func(t *testing.T) {
+		if err := db.RevokeAPIToken(created.ID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.AuthenticateAPIToken(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected a revoked token to be invalid, got %v", err)
+		}
+		tokens, err := db.ListAPITokens()
+		if err != nil || len(tokens) != 0 {
+			t.Errorf("expected no tokens, got %v, %v", tokens, err)
+		}
+		if err := db.RevokeAPIToken(created.ID); err == nil {
+			t.Error("expected error revoking a missing token")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Triage actions, what TriageBookmark does with an unread bookmark.
+const (
+	// TriageKeep keeps the bookmark and marks it read.
+	TriageKeep = "keep"
+	// TriageLater leaves the bookmark unread until next week's triage.
+	TriageLater = "later"
+	// TriageArchive marks the bookmark read, queueing it for archiving
+	// first unless it is already archived.
+	TriageArchive = "archive"
+	// TriageDelete deletes the bookmark.
+	TriageDelete = "delete"
+)
+
+// TriageActions lists every triage action.
+var TriageActions = []string{TriageKeep, TriageLater, TriageArchive, TriageDelete}
+
+// ErrInvalidTriageAction is returned by TriageBookmark for an action not in
+// TriageActions.
+var ErrInvalidTriageAction = errors.New("invalid triage action")
+
+// triageColumns are the triage_sessions counters for each action.
+var triageColumns = map[string]string{
+	TriageKeep:    "kept",
+	TriageLater:   "later",
+	TriageArchive: "archived",
+	TriageDelete:  "deleted",
+}
+
+// TriageWeek returns the ISO week t falls in, e.g. "2026-W42". Each week
+// gets a fresh triage: bookmarks put off with TriageLater come back.
+func TriageWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// GetTriageSession returns the handle's user's triage for the week now
+// falls in, with how many bookmarks remain. A week whose triage hasn't
+// started has no StartedAt and zero counts.
+func (db *DB) GetTriageSession(now time.Time) (TriageSession, error) {
+	s := TriageSession{UserID: db.actingUserID(), Week: TriageWeek(now)}
+	err := db.db.QueryRow(`
+		SELECT started_at, kept, later, archived, deleted
+		FROM triage_sessions WHERE user_id = ? AND week = ?
+	`, s.UserID, s.Week).Scan(&s.StartedAt, &s.Kept, &s.Later, &s.Archived, &s.Deleted)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return TriageSession{}, fmt.Errorf("failed to get triage session: %w", err)
+	}
+	if err := db.db.QueryRow(`
+		SELECT COUNT(*) FROM bookmarks
+		WHERE is_read = 0 AND COALESCE(triage_week, '') != ? AND `+ownerFilter("user_id"),
+		append([]any{s.Week}, db.owner()...)...).Scan(&s.Remaining); err != nil {
+		return TriageSession{}, fmt.Errorf("failed to count bookmarks to triage: %w", err)
+	}
+	return s, nil
+}
+
+// NextTriageBookmark returns the oldest unread bookmark not yet triaged in
+// the week now falls in, and false once there are none left.
+func (db *DB) NextTriageBookmark(now time.Time) (Bookmark, bool, error) {
+	bookmarks, err := db.queryBookmarks(`
+		SELECT id, url, title, created_at, COALESCE(slug, '')
+		FROM bookmarks
+		WHERE is_read = 0 AND COALESCE(triage_week, '') != ? AND `+ownerFilter("user_id")+`
+		ORDER BY created_at, id
+	`, append([]any{TriageWeek(now)}, db.owner()...), 1)
+	if err != nil {
+		return Bookmark{}, false, fmt.Errorf("failed to get next bookmark to triage: %w", err)
+	}
+	if len(bookmarks) == 0 {
+		return Bookmark{}, false, nil
+	}
+	return bookmarks[0], true, nil
+}
+
+// TriageBookmark applies a triage action to bookmark id and counts it in
+// the triage for the week now falls in, starting that week's triage if
+// needed. The bookmark isn't served again that week.
+func (db *DB) TriageBookmark(id int64, action string, now time.Time) error {
+	column, ok := triageColumns[action]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidTriageAction, action)
+	}
+	week := TriageWeek(now)
+	switch action {
+	case TriageKeep:
+		if err := db.MarkRead(id, true); err != nil {
+			return err
+		}
+	case TriageLater:
+		if _, err := db.GetBookmark(id); err != nil {
+			return err
+		}
+	case TriageArchive:
+		archive, err := db.GetBookmarkArchiveStatus(id)
+		if err != nil {
+			return err
+		}
+		if archive.ArchivedAt == "" {
+			if err := db.ClearBookmarkArchive(id); err != nil {
+				return err
+			}
+		}
+		if err := db.MarkRead(id, true); err != nil {
+			return err
+		}
+	case TriageDelete:
+		if err := db.DeleteBookmark(id); err != nil {
+			return err
+		}
+	}
+	if action != TriageDelete {
+		if _, err := db.db.Exec(`UPDATE bookmarks SET triage_week = ? WHERE id = ?`, week, id); err != nil {
+			return fmt.Errorf("failed to mark bookmark triaged: %w", err)
+		}
+	}
+
+	if _, err := db.db.Exec(`
+		INSERT INTO triage_sessions (user_id, week, started_at, `+column+`) VALUES (?, ?, ?, 1)
+		ON CONFLICT (user_id, week) DO UPDATE SET `+column+` = `+column+` + 1
+	`, db.actingUserID(), week, now.Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to record triage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTriage(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	monday := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	if got := TriageWeek(monday); got != "2026-W42" {
+		t.Errorf("TriageWeek() = %q, want 2026-W42", got)
+	}
+
+	var ids []int64
+	for i, url := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com", "https://d.example.com"} {
+		id, err := db.CreateBookmark(NewBookmark{URL: url, CreatedAt: monday.Add(-time.Duration(4-i) * time.Hour)})
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := db.MarkRead(ids[3], true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+
+	s, err := db.GetTriageSession(monday)
+	if err != nil {
+		t.Fatalf("GetTriageSession() error = %v", err)
+	}
+	if s.StartedAt != "" || s.Done() != 0 || s.Remaining != 3 {
+		t.Errorf("expected an unstarted triage with 3 left, got %+v", s)
+	}
+
+	// Unread bookmarks are served oldest first, once a week.
+	for _, step := range []struct {
+		id     int64
+		action string
+	}{{ids[0], TriageLater}, {ids[1], TriageArchive}, {ids[2], TriageDelete}} {
+		next, ok, err := db.NextTriageBookmark(monday)
+		if err != nil || !ok || next.ID != step.id {
+			t.Fatalf("NextTriageBookmark() = %d, %v, %v, want %d", next.ID, ok, err, step.id)
+		}
+		if err := db.TriageBookmark(step.id, step.action, monday); err != nil {
+			t.Fatalf("TriageBookmark(%s) error = %v", step.action, err)
+		}
+	}
+	if _, ok, err := db.NextTriageBookmark(monday); ok || err != nil {
+		t.Errorf("expected the week's triage to be done, got %v, %v", ok, err)
+	}
+
+	s, err = db.GetTriageSession(monday.Add(24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetTriageSession() error = %v", err)
+	}
+	if s.StartedAt == "" || s.Later != 1 || s.Archived != 1 || s.Deleted != 1 || s.Kept != 0 || s.Remaining != 0 {
+		t.Errorf("unexpected triage %+v", s)
+	}
+	if flags, _ := db.GetBookmarkFlags(ids[1]); !flags.IsRead {
+		t.Error("expected the archived bookmark to be marked read")
+	}
+	if _, err := db.GetBookmark(ids[2]); err == nil {
+		t.Error("expected the bookmark to be deleted")
+	}
+
+	// Next week, what was put off comes back.
+	nextWeek := monday.Add(7 * 24 * time.Hour)
+	if next, ok, _ := db.NextTriageBookmark(nextWeek); !ok || next.ID != ids[0] {
+		t.Errorf("expected bookmark %d back next week, got %d, %v", ids[0], next.ID, ok)
+	}
+	if err := db.TriageBookmark(ids[0], TriageKeep, nextWeek); err != nil {
+		t.Fatalf("TriageBookmark(keep) error = %v", err)
+	}
+	if s, _ := db.GetTriageSession(nextWeek); s.Kept != 1 || s.Done() != 1 || s.Remaining != 0 {
+		t.Errorf("unexpected triage for next week %+v", s)
+	}
+
+	if err := db.TriageBookmark(ids[0], "snooze", monday); !errors.Is(err, ErrInvalidTriageAction) {
+		t.Errorf("expected ErrInvalidTriageAction, got %v", err)
+	}
+	if err := db.TriageBookmark(99999, TriageLater, monday); err == nil {
+		t.Error("expected error for non-existent bookmark")
+	}
+}
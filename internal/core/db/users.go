@@ -0,0 +1,258 @@
+package db
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned by AuthenticateUser for an unknown user
+// or a wrong password.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrLastAdmin is returned when a change would leave no admin.
+var ErrLastAdmin = errors.New("the last admin can't be removed")
+
+// validUsername is what usernames may contain; they are matched
+// case-insensitively.
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// passwordIterations is the PBKDF2-SHA256 work factor for new password
+// hashes. Hashes record their own count, so it can be raised later; tests
+// lower it.
+var passwordIterations = 600_000
+
+// hashPassword returns "pbkdf2-sha256$iterations$salt$key" for password,
+// with a random salt.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+const userColumns = `id, username, is_admin, password_hash != '', created_at`
+
+func scanUser(row interface{ Scan(...any) error }) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Username, &u.IsAdmin, &u.HasPassword, &u.CreatedAt)
+	return u, err
+}
+
+// CreateUser adds an account. An empty password leaves the user unable to
+// log in until one is set.
+func (db *DB) CreateUser(username, password string, admin bool) (User, error) {
+	username = strings.TrimSpace(username)
+	if !validUsername.MatchString(username) {
+		return User{}, fmt.Errorf("invalid username %q: use up to 64 letters, digits, '.', '_' or '-'", username)
+	}
+	hash := ""
+	if password != "" {
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return User{}, err
+		}
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO users (username, password_hash, is_admin, created_at) VALUES (?, ?, ?, ?)
+	`, username, hash, admin, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return User{}, fmt.Errorf("user %q already exists", username)
+		}
+		return User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return db.GetUser(id)
+}
+
+// GetUser returns a user by ID.
+func (db *DB) GetUser(id int64) (User, error) {
+	u, err := scanUser(db.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, fmt.Errorf("user not found: %d", id)
+		}
+		return User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+// GetUserByName returns a user by username, ignoring case.
+func (db *DB) GetUserByName(username string) (User, error) {
+	u, err := scanUser(db.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, strings.TrimSpace(username)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, fmt.Errorf("user not found: %s", username)
+		}
+		return User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return u, nil
+}
+
+// ListUsers returns every user, oldest first.
+func (db *DB) ListUsers() ([]User, error) {
+	rows, err := db.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+	return users, nil
+}
+
+// AuthenticateUser returns the user with username if password is theirs, or
+// ErrInvalidCredentials.
+func (db *DB) AuthenticateUser(username, password string) (User, error) {
+	var u User
+	var hash string
+	err := db.db.QueryRow(`SELECT `+userColumns+`, password_hash FROM users WHERE username = ?`, strings.TrimSpace(username)).
+		Scan(&u.ID, &u.Username, &u.IsAdmin, &u.HasPassword, &u.CreatedAt, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to authenticate user: %w", err)
+	}
+	if hash == "" || !checkPassword(hash, password) {
+		return User{}, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+// HasUserPasswords reports whether any user has a password, which makes the
+// web UI require a login.
+func (db *DB) HasUserPasswords() (bool, error) {
+	var any bool
+	if err := db.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE password_hash != '')`).Scan(&any); err != nil {
+		return false, fmt.Errorf("failed to check user passwords: %w", err)
+	}
+	return any, nil
+}
+
+// SetUserPassword changes a user's password; "" removes it, so the user can
+// no longer log in.
+func (db *DB) SetUserPassword(id int64, password string) error {
+	hash := ""
+	if password != "" {
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return err
+		}
+	}
+	return db.updateUser(id, `UPDATE users SET password_hash = ? WHERE id = ?`, hash, id)
+}
+
+// SetUserAdmin grants or revokes admin rights. Revoking them from the last
+// admin returns ErrLastAdmin.
+func (db *DB) SetUserAdmin(id int64, admin bool) error {
+	if !admin {
+		if err := db.checkNotLastAdmin(id); err != nil {
+			return err
+		}
+	}
+	return db.updateUser(id, `UPDATE users SET is_admin = ? WHERE id = ?`, admin, id)
+}
+
+// DeleteUser deletes a user and everything they own (see DeleteUserData).
+// Deleting the last admin returns ErrLastAdmin. It returns the number of
+// bookmarks deleted.
+func (db *DB) DeleteUser(id int64) (int, error) {
+	if err := db.checkNotLastAdmin(id); err != nil {
+		return 0, err
+	}
+	n, err := db.DeleteUserData(id)
+	if err != nil {
+		return n, err
+	}
+	return n, db.updateUser(id, `DELETE FROM users WHERE id = ?`, id)
+}
+
+// checkNotLastAdmin returns ErrLastAdmin if id is the only admin.
+func (db *DB) checkNotLastAdmin(id int64) error {
+	u, err := db.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if !u.IsAdmin {
+		return nil
+	}
+	var admins int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM users WHERE is_admin = 1`).Scan(&admins); err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func (db *DB) updateUser(id int64, query string, args ...any) error {
+	res, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user not found: %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func init() {
+	// Full-strength hashing makes each test that sets a password slow.
+	passwordIterations = 1000
+}
+
+// TestUsers tests creating, authenticating and removing user accounts.
+func TestUsers(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("migration creates the local admin", func(t *testing.T) {
+		u, err := db.GetUser(LocalUserID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if u.Username != "admin" || !u.IsAdmin || u.HasPassword {
+			t.Errorf("unexpected local user %+v", u)
+		}
+		if any, err := db.HasUserPasswords(); err != nil || any {
+			t.Errorf("expected no passwords yet, got %v, %v", any, err)
+		}
+	})
+
+	alice, err := db.CreateUser(" alice ", "s3cret", false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if alice.Username != "alice" || alice.IsAdmin || !alice.HasPassword {
+		t.Fatalf("unexpected user %+v", alice)
+	}
+
+	t.Run("rejects bad and duplicate usernames", func(t *testing.T) {
+		if _, err := db.CreateUser("no spaces", "", false); err == nil {
+			t.Error("expected an error for an invalid username")
+		}
+		if _, err := db.CreateUser("ALICE", "", false); err == nil {
+			t.Error("expected an error for a duplicate username")
+		}
+	})
+
+	t.Run("authenticates", func(t *testing.T) {
+		got, err := db.AuthenticateUser("Alice", "s3cret")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.ID != alice.ID {
+			t.Errorf("expected user %d, got %d", alice.ID, got.ID)
+		}
+		if _, err := db.AuthenticateUser("alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials for a wrong password, got %v", err)
+		}
+		if _, err := db.AuthenticateUser("bob", "s3cret"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+		}
+		if _, err := db.AuthenticateUser("admin", ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials for a user without a password, got %v", err)
+		}
+		if any, err := db.HasUserPasswords(); err != nil || !any {
+			t.Errorf("expected a password to be set, got %v, %v", any, err)
+		}
+	})
+
+	t.Run("changes passwords", func(t *testing.T) {
+		if err := db.SetUserPassword(alice.ID, "n3w"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.AuthenticateUser("alice", "s3cret"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected the old password to stop working, got %v", err)
+		}
+		if _, err := db.AuthenticateUser("alice", "n3w"); err != nil {
+			t.Errorf("expected the new password to work, got %v", err)
+		}
+		if err := db.SetUserPassword(99, "x"); err == nil {
+			t.Error("expected an error for an unknown user")
+		}
+	})
+
+	t.Run("keeps an admin", func(t *testing.T) {
+		if err := db.SetUserAdmin(LocalUserID, false); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("expected ErrLastAdmin, got %v", err)
+		}
+		if _, err := db.DeleteUser(LocalUserID); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("expected ErrLastAdmin, got %v", err)
+		}
+		if err := db.SetUserAdmin(alice.ID, true); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.SetUserAdmin(LocalUserID, false); err != nil {
+			t.Errorf("expected no error with another admin, got %v", err)
+		}
+	})
+
+	t.Run("deletes a user and their bookmarks", func(t *testing.T) {
+		bob, err := db.CreateUser("bob", "", false)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := db.ForUser(bob.ID).AddBookmark("https://bob.example", "Bob's"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		n, err := db.DeleteUser(bob.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if n != 1 {
+			t.Errorf("expected 1 bookmark deleted, got %d", n)
+		}
+		if _, err := db.GetUser(bob.ID); err == nil {
+			t.Error("expected the user to be gone")
+		}
+		users, err := db.ListUsers()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("expected 2 users, got %+v", users)
+		}
+	})
+}
+
+// TestForUser tests that a scoped handle only sees its user's bookmarks and
+// API tokens.
+func TestForUser(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	alice, err := db.CreateUser("alice", "", false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	local := db.ForUser(LocalUserID)
+	scoped := db.ForUser(alice.ID)
+
+	mine, err := scoped.AddBookmark("https://alice.example", "Alice's")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	theirs, err := local.AddBookmark("https://local.example", "Local")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("lists only owned bookmarks", func(t *testing.T) {
+		got, err := scoped.ListBookmarks(0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(got) != 1 || got[0].ID != mine {
+			t.Errorf("expected only bookmark %d, got %+v", mine, got)
+		}
+		all, err := db.ListBookmarks(0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(all) != 2 {
+			t.Errorf("expected the unscoped handle to see 2 bookmarks, got %d", len(all))
+		}
+	})
+
+	t.Run("hides other users' bookmarks", func(t *testing.T) {
+		if _, err := scoped.GetBookmark(theirs); err == nil {
+			t.Error("expected another user's bookmark to be not found")
+		}
+		if err := scoped.SetBookmarkNotes(theirs, "mine now"); err == nil {
+			t.Error("expected updating another user's bookmark to fail")
+		}
+		if err := scoped.DeleteBookmark(theirs); err == nil {
+			t.Error("expected deleting another user's bookmark to fail")
+		}
+		if _, err := scoped.ListBookmarkTags(theirs); err == nil {
+			t.Error("expected listing another user's tags to fail")
+		}
+		if _, err := db.GetBookmark(theirs); err != nil {
+			t.Errorf("expected the bookmark to survive, got %v", err)
+		}
+	})
+
+	t.Run("lets users save the same URL", func(t *testing.T) {
+		existing, err := scoped.ExistingBookmarkURLs([]string{"https://local.example"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(existing) != 0 {
+			t.Errorf("expected another user's URL not to count, got %v", existing)
+		}
+	})
+
+	t.Run("scopes API tokens", func(t *testing.T) {
+		token, secret, err := scoped.CreateAPIToken("phone", 0)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if token.UserID != alice.ID {
+			t.Errorf("expected token owned by %d, got %d", alice.ID, token.UserID)
+		}
+		if _, _, err := local.CreateAPIToken("laptop", 0); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		listed, err := scoped.ListAPITokens()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(listed) != 1 || listed[0].ID != token.ID {
+			t.Errorf("expected only token %d, got %+v", token.ID, listed)
+		}
+		if err := local.RevokeAPIToken(token.ID); err == nil {
+			t.Error("expected revoking another user's token to fail")
+		}
+		authed, err := db.AuthenticateAPIToken(secret)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if authed.UserID != alice.ID {
+			t.Errorf("expected the token to act as %d, got %d", alice.ID, authed.UserID)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidWebhook is returned when a webhook fails validation.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ValidateWebhook checks that a webhook has an http(s) URL and only known
+// event names. Event names are normalized in place.
+func ValidateWebhook(w *Webhook) error {
+	w.URL = strings.TrimSpace(w.URL)
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an http(s) URL", ErrInvalidWebhook, w.URL)
+	}
+	var events []string
+	for _, e := range w.Events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || slices.Contains(events, e) {
+			continue
+		}
+		if _, ok := ParseEventKind(e); !ok {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+		events = append(events, e)
+	}
+	w.Events = events
+	return nil
+}
+
+// Wants reports whether the webhook is sent for events of kind.
+func (w Webhook) Wants(kind EventKind) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, kind.String())
+}
+
+const webhookColumns = `id, url, secret, events, enabled, created_at, COALESCE(last_delivery_at, ''), COALESCE(last_status, 0), COALESCE(last_error, '')`
+
+func scanWebhook(row interface{ Scan(...any) error }) (Webhook, error) {
+	var w Webhook
+	var events string
+	err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Enabled, &w.CreatedAt, &w.LastDeliveryAt, &w.LastStatus, &w.LastError)
+	if events != "" {
+		w.Events = strings.Split(events, ",")
+	}
+	return w, err
+}
+
+// CreateWebhook validates and stores a new webhook, returning its ID. A
+// random secret is generated if it has none.
+func (db *DB) CreateWebhook(w Webhook) (int64, error) {
+	if err := ValidateWebhook(&w); err != nil {
+		return 0, err
+	}
+	if w.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return 0, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		w.Secret = hex.EncodeToString(secret)
+	}
+	res, err := db.db.Exec(`
+		INSERT INTO webhooks (url, secret, events, enabled, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, w.URL, w.Secret, strings.Join(w.Events, ","), w.Enabled, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return id, nil
+}
+
+// GetWebhook returns a single webhook by ID.
+func (db *DB) GetWebhook(id int64) (Webhook, error) {
+	w, err := scanWebhook(db.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, fmt.Errorf("webhook not found: %d", id)
+		}
+		return Webhook{}, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return w, nil
+}
+
+// ListWebhooks returns all webhooks, oldest first.
+func (db *DB) ListWebhooks() ([]Webhook, error) {
+	rows, err := db.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("failed to close rows: %v", err)
+		}
+	}()
+
+	var hooks []Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		hooks = append(hooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// SetWebhookEnabled enables or disables a webhook. Disabled webhooks are
+// kept but not sent.
+func (db *DB) SetWebhookEnabled(id int64, enabled bool) error {
+	return db.updateWebhook(id, `UPDATE webhooks SET enabled = ? WHERE id = ?`, enabled, id)
+}
+
+// DeleteWebhook removes a webhook.
+func (db *DB) DeleteWebhook(id int64) error {
+	return db.updateWebhook(id, `DELETE FROM webhooks WHERE id = ?`, id)
+}
+
+// RecordWebhookDelivery stores the outcome of a delivery attempt: the HTTP
+// status (0 if there was no response) and an error message, empty on
+// success.
+func (db *DB) RecordWebhookDelivery(id int64, status int, deliveryErr string) error {
+	return db.updateWebhook(id, `
+		UPDATE webhooks SET last_delivery_at = ?, last_status = ?, last_error = NULLIF(?, '')
+		WHERE id = ?
+	`, time.Now().Format(time.RFC3339), status, deliveryErr, id)
+}
+
+func (db *DB) updateWebhook(id int64, query string, args ...any) error {
+	res, err := db.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to determine rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("webhook not found: %d", id)
+	}
+	return nil
+}
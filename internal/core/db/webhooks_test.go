@@ -0,0 +1,94 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestWebhooks tests creating, listing, toggling and deleting webhooks.
+func TestWebhooks(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := db.CreateWebhook(Webhook{URL: " https://example.com/hook ", Events: []string{"Bookmark_Created", "bookmark_created", "import_finished"}, Enabled: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("normalizes and generates a secret", func(t *testing.T) {
+		w, err := db.GetWebhook(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if w.URL != "https://example.com/hook" || len(w.Events) != 2 || w.Events[0] != "bookmark_created" {
+			t.Errorf("unexpected webhook %+v", w)
+		}
+		if len(w.Secret) != 64 {
+			t.Errorf("expected a generated secret, got %q", w.Secret)
+		}
+		if !w.Wants(OnImportFinishedEvent) || w.Wants(OnBookmarkDeletedEvent) {
+			t.Errorf("unexpected event filter %v", w.Events)
+		}
+	})
+
+	t.Run("no events means all events", func(t *testing.T) {
+		w := Webhook{}
+		for _, kind := range EventKinds {
+			if !w.Wants(kind) {
+				t.Errorf("expected %v to be wanted", kind)
+			}
+		}
+	})
+
+	t.Run("rejects invalid webhooks", func(t *testing.T) {
+		for _, w := range []Webhook{
+			{URL: "ftp://example.com"},
+			{URL: "not a url"},
+			{URL: "https://example.com", Events: []string{"bookmark_exploded"}},
+		} {
+			if _, err := db.CreateWebhook(w); !errors.Is(err, ErrInvalidWebhook) {
+				t.Errorf("expected ErrInvalidWebhook for %+v, got %v", w, err)
+			}
+		}
+	})
+
+	t.Run("records deliveries", func(t *testing.T) {
+		if err := db.RecordWebhookDelivery(id, 500, "webhook returned 500"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		w, _ := db.GetWebhook(id)
+		if w.LastStatus != 500 || w.LastError != "webhook returned 500" || w.LastDeliveryAt == "" {
+			t.Errorf("unexpected delivery state %+v", w)
+		}
+		if err := db.RecordWebhookDelivery(id, 204, ""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		w, _ = db.GetWebhook(id)
+		if w.LastStatus != 204 || w.LastError != "" {
+			t.Errorf("expected the error to clear, got %+v", w)
+		}
+	})
+
+	t.Run("enables, disables and deletes", func(t *testing.T) {
+		if err := db.SetWebhookEnabled(id, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		hooks, err := db.ListWebhooks()
+		if err != nil || len(hooks) != 1 || hooks[0].Enabled {
+			t.Fatalf("expected one disabled webhook, got %+v, %v", hooks, err)
+		}
+		if err := db.DeleteWebhook(id); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := db.DeleteWebhook(id); err == nil {
+			t.Error("expected an error deleting a missing webhook")
+		}
+		if _, err := db.GetWebhook(id); err == nil {
+			t.Error("expected an error getting a deleted webhook")
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// ErrDomainBlocked is returned when domain rules forbid fetching a page or
+// resource. Archive jobs that fail with it are not retried.
+var ErrDomainBlocked = errors.New("domain blocked by archive rules")
+
+// DomainRules decide which hosts may be fetched. A domain matches itself and
+// its subdomains, so "example.com" covers "www.example.com"; a leading "*."
+// is accepted and ignored. Deny wins over Allow, and a non-empty Allow
+// blocks every host it doesn't match. The zero value allows everything.
+type DomainRules struct {
+	Allow []string
+	Deny  []string
+}
+
+// NewDomainRules normalises allow and deny lists, rejecting entries that
+// aren't bare domain names.
+func NewDomainRules(allow, deny []string) (DomainRules, error) {
+	var r DomainRules
+	var err error
+	if r.Allow, err = normalizeDomains(allow); err != nil {
+		return DomainRules{}, err
+	}
+	if r.Deny, err = normalizeDomains(deny); err != nil {
+		return DomainRules{}, err
+	}
+	return r, nil
+}
+
+func normalizeDomains(domains []string) ([]string, error) {
+	var out []string
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*."), ".")
+		if d == "" {
+			continue
+		}
+		if strings.ContainsAny(d, "/:*@ ") {
+			return nil, fmt.Errorf("invalid domain %q: expected a host name such as example.com", d)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// IsZero reports whether the rules allow everything.
+func (r DomainRules) IsZero() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// Allows reports whether the rules permit fetching rawURL. URLs without a
+// host (data:, about:blank) are always allowed; they don't reach a server.
+func (r DomainRules) Allows(rawURL string) bool {
+	if r.IsZero() {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return true
+	}
+	if matchesDomain(host, r.Deny) {
+		return false
+	}
+	return len(r.Allow) == 0 || matchesDomain(host, r.Allow)
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of
+// one.
+func matchesDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainRulesTransport refuses requests, including redirect hops, to hosts
+// its rules don't allow.
+type domainRulesTransport struct {
+	base  http.RoundTripper
+	rules DomainRules
+}
+
+func (t domainRulesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.rules.Allows(req.URL.String()) {
+		return nil, fmt.Errorf("%w: %s", ErrDomainBlocked, req.URL.Hostname())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// domainFilter applies domain rules inside Chrome. It pauses every request
+// the tab makes and fails the ones the rules don't allow: navigations of
+// the top frame, redirects included, are checked against ArchiveOptions.Domains
+// and everything else (subresources, iframes, XHR) against ResourceDomains.
+type domainFilter struct {
+	pages     DomainRules
+	resources DomainRules
+
+	mu      sync.Mutex
+	blocked string
+}
+
+// newDomainFilter starts listening for paused requests on ctx's target.
+// Requests are only paused once enable has run.
+func newDomainFilter(ctx context.Context, pages, resources DomainRules) *domainFilter {
+	f := &domainFilter{pages: pages, resources: resources}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		if e, ok := ev.(*fetch.EventRequestPaused); ok {
+			// Replying from the listener itself would deadlock.
+			go f.resume(ctx, e)
+		}
+	})
+	return f
+}
+
+// enable makes the browser pause requests for the filter.
+func (f *domainFilter) enable() chromedp.Action {
+	return fetch.Enable()
+}
+
+// resume continues or fails a paused request.
+func (f *domainFilter) resume(ctx context.Context, e *fetch.EventRequestPaused) {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	// The top frame's ID is the target's.
+	navigation := e.ResourceType == network.ResourceTypeDocument && string(e.FrameID) == string(c.Target.TargetID)
+	rules := f.resources
+	if navigation {
+		rules = f.pages
+	}
+
+	execCtx := cdp.WithExecutor(ctx, c.Target)
+	var err error
+	if rules.Allows(e.Request.URL) {
+		err = fetch.ContinueRequest(e.RequestID).Do(execCtx)
+	} else {
+		if navigation {
+			f.mu.Lock()
+			if f.blocked == "" {
+				f.blocked = e.Request.URL
+			}
+			f.mu.Unlock()
+		}
+		err = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Warning: failed to resume request %s: %v", e.Request.URL, err)
+	}
+}
+
+// blockedNavigation returns the first top-frame URL the filter blocked, or
+// "".
+func (f *domainFilter) blockedNavigation() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.blocked
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewDomainRules(t *testing.T) {
+	r, err := NewDomainRules([]string{" Example.COM ", "*.cdn.example.net", ""}, []string{"bank.example.com."})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(r.Allow, ",") != "example.com,cdn.example.net" || strings.Join(r.Deny, ",") != "bank.example.com" {
+		t.Errorf("unexpected rules: %+v", r)
+	}
+
+	for _, bad := range []string{"https://example.com", "example.com/path", "example.com:443", "ex*ample.com"} {
+		if _, err := NewDomainRules(nil, []string{bad}); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestDomainRulesAllows(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules DomainRules
+		url   string
+		want  bool
+	}{
+		{"zero value allows all", DomainRules{}, "https://anything.example", true},
+		{"deny matches domain", DomainRules{Deny: []string{"bank.example.com"}}, "https://bank.example.com/login", false},
+		{"deny matches subdomain", DomainRules{Deny: []string{"example.com"}}, "https://WWW.Example.com./", false},
+		{"deny ignores lookalikes", DomainRules{Deny: []string{"example.com"}}, "https://notexample.com/", true},
+		{"allow matches", DomainRules{Allow: []string{"example.com"}}, "https://blog.example.com/post", true},
+		{"allow blocks others", DomainRules{Allow: []string{"example.com"}}, "https://other.org/", false},
+		{"deny wins over allow", DomainRules{Allow: []string{"example.com"}, Deny: []string{"ads.example.com"}}, "https://ads.example.com/pixel", false},
+		{"hostless URLs pass", DomainRules{Allow: []string{"example.com"}}, "data:image/png;base64,AAAA", true},
+		{"unparsable URLs fail", DomainRules{Deny: []string{"example.com"}}, "http://[::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Allows(tt.url); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInlineResources_Domains(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			_, _ = w.Write([]byte("body { color: red; }"))
+		case "/pixel.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte{0x89, 0x50, 0x4E, 0x47})
+		case "/redirect.png":
+			http.Redirect(w, r, strings.Replace("http://"+r.Host, "localhost", "127.0.0.1", 1)+"/pixel.png", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	// The same server under a second name, so one page has two hosts.
+	local := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	page := `<html><head><link rel="stylesheet" href="` + local + `/style.css"></head>` +
+		`<body><img id="tracker" src="` + ts.URL + `/pixel.png"><img id="bounce" src="` + local + `/redirect.png"></body></html>`
+	opts := DefaultInlineOptions(ts.URL)
+	opts.Domains = DomainRules{Deny: []string{"127.0.0.1"}}
+	result, err := InlineResources(context.Background(), page, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(result, "<style>body { color: red; }</style>") {
+		t.Error("CSS from an allowed host should be inlined")
+	}
+	if !strings.Contains(result, `src="`+ts.URL+`/pixel.png"`) {
+		t.Error("image from a denied host should keep its URL")
+	}
+	if !strings.Contains(result, `src="`+local+`/redirect.png"`) {
+		t.Error("image redirecting to a denied host should keep its URL")
+	}
+}
+
+func TestArchiveBookmark_DeniedDomain(t *testing.T) {
+	// Denied pages are rejected before Chrome is started.
+	opts := ArchiveOptions{Headless: true, Domains: DomainRules{Allow: []string{"example.com"}}}
+	_, err := ArchiveBookmark(context.Background(), "https://bank.example.org/", opts)
+	if !errors.Is(err, ErrDomainBlocked) {
+		t.Errorf("expected ErrDomainBlocked, got %v", err)
+	}
+}
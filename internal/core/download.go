@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/chromedp"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// downloadStartGrace is how long to wait for a download to be announced
+// after a navigation is aborted, since Chrome may report the abort first.
+const downloadStartGrace = 2 * time.Second
+
+// downloadWatcher catches a file a navigation downloads instead of loading a
+// page. Chrome saves it, named by its GUID, in a temporary directory.
+type downloadWatcher struct {
+	dir string
+
+	mu       sync.Mutex
+	begun    *browser.EventDownloadWillBegin
+	progress *browser.EventDownloadProgress
+	changed  chan struct{}
+}
+
+// newDownloadWatcher creates the download directory and starts listening
+// for download events on ctx's target. Call close when done.
+func newDownloadWatcher(ctx context.Context) (*downloadWatcher, error) {
+	dir, err := os.MkdirTemp("", "bookmarkd-download-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	w := &downloadWatcher{dir: dir, changed: make(chan struct{}, 1)}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		w.mu.Lock()
+		switch e := ev.(type) {
+		case *browser.EventDownloadWillBegin:
+			if w.begun == nil {
+				w.begun = e
+			}
+		case *browser.EventDownloadProgress:
+			if w.begun != nil && e.GUID == w.begun.GUID {
+				w.progress = e
+			}
+		default:
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	})
+	return w, nil
+}
+
+// enable lets the browser download files into the watcher's directory and
+// report their progress. In a pooled tab (see BrowserPool) only its own
+// browser context's downloads are redirected.
+func (w *downloadWatcher) enable() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		action := browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).
+			WithDownloadPath(w.dir).
+			WithEventsEnabled(true)
+		if c := chromedp.FromContext(ctx); c != nil && c.BrowserContextID != "" {
+			action = action.WithBrowserContextID(c.BrowserContextID)
+		}
+		return action.Do(ctx)
+	})
+}
+
+// started reports whether a download began, waiting up to grace for one.
+func (w *downloadWatcher) started(ctx context.Context, grace time.Duration) bool {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for {
+		w.mu.Lock()
+		begun := w.begun != nil
+		w.mu.Unlock()
+		if begun {
+			return true
+		}
+		select {
+		case <-w.changed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// wait waits for the download to finish and reads it. Downloads larger than
+// maxSize are canceled.
+func (w *downloadWatcher) wait(ctx context.Context, maxSize int64) (ArchiveDownload, error) {
+	for {
+		w.mu.Lock()
+		begun, progress := w.begun, w.progress
+		w.mu.Unlock()
+
+		if progress != nil {
+			if int64(progress.ReceivedBytes) > maxSize || int64(progress.TotalBytes) > maxSize {
+				if err := browser.CancelDownload(begun.GUID).Do(ctx); err != nil {
+					return ArchiveDownload{}, fmt.Errorf("download is larger than %s and canceling it failed: %w", FormatBytes(maxSize), err)
+				}
+				return ArchiveDownload{}, fmt.Errorf("download is larger than %s", FormatBytes(maxSize))
+			}
+			switch progress.State {
+			case browser.DownloadProgressStateCompleted:
+				data, err := os.ReadFile(filepath.Join(w.dir, begun.GUID))
+				if err != nil {
+					return ArchiveDownload{}, fmt.Errorf("failed to read download: %w", err)
+				}
+				if int64(len(data)) > maxSize {
+					return ArchiveDownload{}, fmt.Errorf("download is larger than %s", FormatBytes(maxSize))
+				}
+				name := downloadFilename(begun.SuggestedFilename)
+				return ArchiveDownload{
+					URL:      begun.URL,
+					Filename: name,
+					MIMEType: DownloadMIMEType(name, data),
+					Data:     data,
+				}, nil
+			case browser.DownloadProgressStateCanceled:
+				return ArchiveDownload{}, fmt.Errorf("download of %s was canceled", begun.URL)
+			}
+		}
+
+		select {
+		case <-w.changed:
+		case <-ctx.Done():
+			return ArchiveDownload{}, ctx.Err()
+		}
+	}
+}
+
+// close removes the download directory.
+func (w *downloadWatcher) close() {
+	if err := os.RemoveAll(w.dir); err != nil {
+		log.Printf("Warning: failed to remove download directory %s: %v", w.dir, err)
+	}
+}
+
+// ArchiveDownload is a file a bookmarked URL downloaded instead of opening
+// as a page.
+type ArchiveDownload struct {
+	// URL is where the file was downloaded from, after redirects.
+	URL      string
+	Filename string
+	MIMEType string
+	Data     []byte
+}
+
+// downloadFilename returns a safe base name for a suggested file name.
+func downloadFilename(suggested string) string {
+	name := filepath.Base(strings.ReplaceAll(suggested, "\\", "/"))
+	if name == "." || name == "/" || strings.TrimSpace(name) == "" {
+		return "download"
+	}
+	return name
+}
+
+// DownloadMIMEType returns the MIME type of a downloaded file, from its
+// extension or else by sniffing its content.
+func DownloadMIMEType(filename string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(filename)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+var downloadPageTemplate = template.Must(template.New("download").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Filename }}</title></head>
+<body>
+<h1>{{ .Filename }}</h1>
+<p>This bookmark downloaded a file instead of opening a page.</p>
+<ul>
+<li>Source: {{ .URL }}</li>
+<li>Type: {{ .MIMEType }}</li>
+<li>Size: {{ .Size }}</li>
+<li>SHA-256: {{ .SHA256 }}</li>
+</ul>
+</body>
+</html>
+`))
+
+// DownloadPageHTML returns the HTML stored as a download's archive version.
+// It includes the file's SHA-256, so a timestamp of the page also covers the
+// file.
+func DownloadPageHTML(d ArchiveDownload) (string, error) {
+	sum := sha256.Sum256(d.Data)
+	var b strings.Builder
+	err := downloadPageTemplate.Execute(&b, map[string]string{
+		"Filename": d.Filename,
+		"URL":      d.URL,
+		"MIMEType": d.MIMEType,
+		"Size":     FormatBytes(int64(len(d.Data))),
+		"SHA256":   hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render download page: %w", err)
+	}
+	return b.String(), nil
+}
+
+// persistDownload stores a downloaded file as a new archive version: a page
+// describing the file, the file itself, provenance and, if a TSA is
+// configured, a timestamp. Page-only steps (inlining, reader view,
+// screenshot, favicon) are skipped.
+func persistDownload(ctx context.Context, database *db.DB, b db.Bookmark, res ArchiveResult, opts ArchiveOptions, attemptedAt time.Time) error {
+	d := *res.Download
+	page, err := DownloadPageHTML(d)
+	if err != nil {
+		return err
+	}
+	archivedAt := time.Now()
+	if err := database.SaveArchiveResult(b.ID, attemptedAt, &archivedAt, ArchiveStatusOK, "", res.FinalURL, page); err != nil {
+		return err
+	}
+	if err := database.SaveArchiveDownload(b.ID, db.ArchiveDownload{Filename: d.Filename, MIMEType: d.MIMEType, Data: d.Data}); err != nil {
+		return err
+	}
+
+	if tsaURL := TimestampAuthority(); tsaURL != "" {
+		if ts, err := TimestampArchive(ctx, database, b.ID, page, tsaURL); err != nil {
+			log.Printf("Warning: failed to timestamp archive for id=%d: %v", b.ID, err)
+		} else {
+			log.Printf("Timestamped archive for id=%d at %s", b.ID, ts.TimestampedAt)
+		}
+	}
+
+	provenance := NewArchiveProvenance(b, res, opts, archivedAt)
+	if err := saveArchiveProvenance(database, b.ID, provenance); err != nil {
+		log.Printf("Warning: failed to save provenance for id=%d: %v", b.ID, err)
+	}
+
+	// The description page's links aren't the bookmark's.
+	if err := database.SaveBookmarkLinks(b.ID, nil); err != nil {
+		log.Printf("Warning: failed to clear links for id=%d: %v", b.ID, err)
+	}
+
+	log.Printf("Archived download for bookmark id=%d: %s (%s, %s)", b.ID, d.Filename, d.MIMEType, FormatBytes(int64(len(d.Data))))
+	return nil
+}
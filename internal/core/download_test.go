@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadFilename(t *testing.T) {
+	tests := map[string]string{
+		"report.zip":            "report.zip",
+		"../../etc/passwd":      "passwd",
+		`C:\Users\me\notes.txt`: "notes.txt",
+		"":                      "download",
+		"/":                     "download",
+	}
+	for in, want := range tests {
+		if got := downloadFilename(in); got != want {
+			t.Errorf("downloadFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDownloadMIMEType(t *testing.T) {
+	if got := DownloadMIMEType("report.zip", nil); got != "application/zip" {
+		t.Errorf("expected application/zip from the extension, got %q", got)
+	}
+	if got := DownloadMIMEType("download", []byte("%PDF-1.7\n")); got != "application/pdf" {
+		t.Errorf("expected application/pdf from the content, got %q", got)
+	}
+}
+
+func TestDownloadPageHTML(t *testing.T) {
+	page, err := DownloadPageHTML(ArchiveDownload{
+		URL:      "https://example.com/a.zip?x=<1>",
+		Filename: "<a>.zip",
+		MIMEType: "application/zip",
+		Data:     []byte("zip"),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, want := range []string{"&lt;a&gt;.zip", "application/zip", "3 B", "SHA-256: 4a70fe9a"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in %s", want, page)
+		}
+	}
+}
+
+func TestPersistDownload(t *testing.T) {
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark("https://example.com/report.zip", "Report")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+	d := ArchiveDownload{URL: b.URL, Filename: "report.zip", MIMEType: "application/zip", Data: []byte("PK\x03\x04")}
+	res := ArchiveResult{FinalURL: d.URL, Title: d.Filename, Download: &d}
+
+	if err := persistDownload(context.Background(), database, b, res, ArchiveOptions{}, time.Now()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	v, err := database.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if !v.HasDownload || !v.HasProvenance || !strings.Contains(v.ArchivedHTML, "report.zip") {
+		t.Errorf("unexpected version %+v", v)
+	}
+	got, err := database.GetArchiveDownload(id, v.ID)
+	if err != nil {
+		t.Fatalf("failed to get download: %v", err)
+	}
+	if got.Filename != "report.zip" || got.MIMEType != "application/zip" || string(got.Data) != string(d.Data) {
+		t.Errorf("unexpected download %+v", got)
+	}
+	saved, err := database.GetBookmarkArchive(id)
+	if err != nil {
+		t.Fatalf("failed to get archive: %v", err)
+	}
+	if saved.ArchiveStatus != ArchiveStatusOK {
+		t.Errorf("expected status %q, got %q", ArchiveStatusOK, saved.ArchiveStatus)
+	}
+}
+
+func TestArchiveBookmark_Download(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.zip"`)
+		_, _ = w.Write([]byte("PK\x03\x04 zipped"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := ArchiveBookmark(ctx, srv.URL+"/report", ArchiveOptions{
+		Headless: true,
+		Timeout:  20 * time.Second,
+	})
+	if err != nil {
+		t.Skipf("Chrome not available or failed: %v", err)
+	}
+	if result.Download == nil {
+		t.Fatal("expected the download to be captured")
+	}
+	if result.Download.Filename != "report.zip" || result.Download.MIMEType != "application/zip" {
+		t.Errorf("unexpected download %+v", result.Download)
+	}
+	if result.HTML != "" {
+		t.Error("expected no HTML for a download")
+	}
+}
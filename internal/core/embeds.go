@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// embedSelector matches the elements InlineOptions.Embeds applies to.
+const embedSelector = "iframe, video, audio, embed, object"
+
+// ParseEmbedMode parses an embed mode as given on the command line:
+// EmbedsKeep (or ""), EmbedsPlaceholder or EmbedsInline.
+func ParseEmbedMode(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", EmbedsKeep:
+		return EmbedsKeep, nil
+	case EmbedsPlaceholder, EmbedsInline:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid embed mode %q: expected %s, %s or %s", s, EmbedsKeep, EmbedsPlaceholder, EmbedsInline)
+}
+
+// embedTasks snapshots or replaces the page's embeds as opts.Embeds says.
+// Embeds nested in another one (an <embed> inside an <object>) go with it.
+func (ri *resourceInliner) embedTasks(doc *goquery.Document) []inlineTask {
+	if ri.opts.Embeds != EmbedsPlaceholder && ri.opts.Embeds != EmbedsInline {
+		return nil
+	}
+	var tasks []inlineTask
+	doc.Find(embedSelector).Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered(embedSelector).Length() > 0 {
+			return
+		}
+		src := embedSource(s)
+		if src == "" {
+			// Nothing to fetch: an iframe with srcdoc, or a player a script
+			// fills in.
+			return
+		}
+		srcURL := resolveURL(ri.baseURL, src)
+		if !strings.HasPrefix(srcURL, "http://") && !strings.HasPrefix(srcURL, "https://") {
+			return
+		}
+		placeholder := func() func() {
+			html := embedPlaceholder(s, srcURL)
+			return ri.budgeted(len(html), func() { s.ReplaceWithHtml(html) })
+		}
+		if ri.opts.Embeds == EmbedsPlaceholder {
+			tasks = append(tasks, placeholder)
+			return
+		}
+
+		switch goquery.NodeName(s) {
+		case "iframe":
+			if !sameOrigin(ri.baseURL.String(), srcURL) || ri.opts.frameDepth >= MaxFrameDepth {
+				tasks = append(tasks, placeholder)
+				return
+			}
+			tasks = append(tasks, func() func() {
+				frameHTML, err := fetchResource(ri.ctx, ri.client, srcURL, ri.opts.MaxResourceSize)
+				if err != nil {
+					ri.logFetchError("iframe", srcURL, err)
+					return placeholder()
+				}
+				opts := ri.opts
+				opts.BaseURL = srcURL
+				opts.frameDepth++
+				inlined, err := InlineResources(ri.ctx, frameHTML, opts)
+				if err != nil {
+					return placeholder()
+				}
+				return ri.budgeted(len(inlined), func() {
+					s.RemoveAttr("src")
+					s.SetAttr("srcdoc", inlined)
+				})
+			})
+		case "video", "audio":
+			tasks = append(tasks, func() func() {
+				dataURI, err := fetchAsDataURI(ri.ctx, ri.client, srcURL, ri.opts.MaxResourceSize)
+				if err != nil {
+					ri.logFetchError(goquery.NodeName(s), srcURL, err)
+					return placeholder()
+				}
+				return ri.budgeted(len(dataURI), func() {
+					s.Find("source").Remove()
+					s.SetAttr("src", dataURI)
+				})
+			})
+		default:
+			tasks = append(tasks, placeholder)
+		}
+	})
+	return tasks
+}
+
+// embedSource returns the URL an embed loads: its src (data for <object>),
+// or for video and audio without one, that of its first <source>.
+func embedSource(s *goquery.Selection) string {
+	attr := "src"
+	if goquery.NodeName(s) == "object" {
+		attr = "data"
+	}
+	if src := strings.TrimSpace(s.AttrOr(attr, "")); src != "" {
+		return src
+	}
+	switch goquery.NodeName(s) {
+	case "video", "audio":
+		return strings.TrimSpace(s.Find("source[src]").First().AttrOr("src", ""))
+	}
+	return ""
+}
+
+// embedPlaceholder returns a box, the size the embed asked for, linking to
+// its source.
+func embedPlaceholder(s *goquery.Selection, srcURL string) string {
+	style := "display:block;box-sizing:border-box;padding:1em;border:1px dashed #999;background:#f6f6f6;color:#333;font:14px sans-serif;overflow:hidden;word-break:break-all"
+	for _, dim := range []string{"width", "height"} {
+		if v := strings.TrimSpace(s.AttrOr(dim, "")); v != "" && strings.Trim(v, "0123456789") == "" {
+			style += ";" + dim + ":" + v + "px"
+		}
+	}
+	kind := "Embedded content"
+	switch goquery.NodeName(s) {
+	case "iframe":
+		kind = "Embedded page"
+	case "video":
+		kind = "Video"
+	case "audio":
+		kind = "Audio"
+	}
+	src := html.EscapeString(srcURL)
+	return fmt.Sprintf(`<div class="bookmarkd-embed" style="%s">%s: <a href="%s" target="_blank" rel="noopener">%s</a></div>`, style, kind, src, src)
+}
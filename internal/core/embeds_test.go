@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseEmbedMode(t *testing.T) {
+	for in, want := range map[string]string{"": EmbedsKeep, "keep": EmbedsKeep, "Placeholder": EmbedsPlaceholder, " inline ": EmbedsInline} {
+		if got, err := ParseEmbedMode(in); err != nil || got != want {
+			t.Errorf("ParseEmbedMode(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseEmbedMode("snapshot"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestInlineResources_Embeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/frame", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><p>Frame text</p><img src="/dot.gif"></body></html>`))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><iframe src="/loop"></iframe></body></html>`))
+	})
+	mux.HandleFunc("/dot.gif", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write([]byte("GIF89a"))
+	})
+	mux.HandleFunc("/clip.mp4", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		_, _ = w.Write([]byte("mp4"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	page := `<html><body>
+<iframe src="/frame" width="400" height="300"></iframe>
+<iframe src="https://player.example/embed/1"></iframe>
+<video controls><source src="/clip.mp4" type="video/mp4"></video>
+<object data="/movie.swf"><embed src="/movie.swf"></object>
+<iframe srcdoc="<p>inline</p>"></iframe>
+</body></html>`
+
+	t.Run("keep", func(t *testing.T) {
+		out, err := InlineResources(context.Background(), page, DefaultInlineOptions(ts.URL+"/"))
+		if err != nil {
+			t.Fatalf("InlineResources() error = %v", err)
+		}
+		if strings.Contains(out, "bookmarkd-embed") || strings.Contains(out, "Frame text") {
+			t.Errorf("expected embeds to be left alone, got %s", out)
+		}
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		opts := DefaultInlineOptions(ts.URL + "/")
+		opts.Embeds = EmbedsPlaceholder
+		out, err := InlineResources(context.Background(), page, opts)
+		if err != nil {
+			t.Fatalf("InlineResources() error = %v", err)
+		}
+		if n := strings.Count(out, `class="bookmarkd-embed"`); n != 4 {
+			t.Errorf("expected 4 placeholders, got %d in %s", n, out)
+		}
+		for _, want := range []string{
+			`href="` + ts.URL + `/frame"`,
+			"width:400px;height:300px",
+			`href="https://player.example/embed/1"`,
+			`href="` + ts.URL + `/clip.mp4"`,
+			`srcdoc="&lt;p&gt;inline&lt;/p&gt;"`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %s in %s", want, out)
+			}
+		}
+		if strings.Contains(out, "<iframe src=") || strings.Contains(out, "<video") || strings.Contains(out, "<embed") {
+			t.Errorf("expected embeds with a source to be replaced, got %s", out)
+		}
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		opts := DefaultInlineOptions(ts.URL + "/")
+		opts.Embeds = EmbedsInline
+		out, err := InlineResources(context.Background(), page, opts)
+		if err != nil {
+			t.Fatalf("InlineResources() error = %v", err)
+		}
+		if !strings.Contains(out, "Frame text") || !strings.Contains(out, "data:image/gif;base64,") {
+			t.Errorf("expected the same-origin frame to be snapshotted with its image, got %s", out)
+		}
+		if strings.Contains(out, `<iframe src="/frame"`) {
+			t.Errorf("expected the frame's src to be dropped, got %s", out)
+		}
+		if !strings.Contains(out, `href="https://player.example/embed/1"`) {
+			t.Errorf("expected a placeholder for the cross-origin frame, got %s", out)
+		}
+		if !strings.Contains(out, `src="data:video/mp4;base64,`) || strings.Contains(out, "<source") {
+			t.Errorf("expected the video to be inlined, got %s", out)
+		}
+		if !strings.Contains(out, `href="`+ts.URL+`/movie.swf"`) {
+			t.Errorf("expected a placeholder for the plugin embed, got %s", out)
+		}
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		opts := DefaultInlineOptions(ts.URL + "/")
+		opts.Embeds = EmbedsInline
+		out, err := InlineResources(context.Background(), `<html><body><iframe src="/loop"></iframe></body></html>`, opts)
+		if err != nil {
+			t.Fatalf("InlineResources() error = %v", err)
+		}
+		// Each level escapes the one it contains once more, so count the
+		// nested placeholders by their class, however escaped.
+		if n := strings.Count(out, "bookmarkd-embed"); n != 1 {
+			t.Errorf("expected the innermost frame to become a placeholder, got %d in %s", n, out)
+		}
+	})
+}
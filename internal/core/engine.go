@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ParseArchiveEngine parses an archive engine name as given on the command
+// line: ArchiveEngineChrome, ArchiveEngineHTTP, or "auto" (or "") to use
+// Chrome when it is installed.
+func ParseArchiveEngine(s string) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "auto":
+		return "", nil
+	case ArchiveEngineChrome, ArchiveEngineHTTP:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid archive engine %q: expected auto, %s or %s", s, ArchiveEngineChrome, ArchiveEngineHTTP)
+}
+
+// archiveEngine returns the engine opts select. Without one, Chrome is used
+// if it can be found, and the plain HTTP engine otherwise.
+func archiveEngine(opts ArchiveOptions) string {
+	if opts.Engine != "" {
+		return opts.Engine
+	}
+	if chromeInstalled(opts.ChromePath) {
+		return ArchiveEngineChrome
+	}
+	log.Printf("Chrome not found; archiving with the %s engine", ArchiveEngineHTTP)
+	return ArchiveEngineHTTP
+}
+
+// chromeInstalled reports whether Chrome can be started: the executable at
+// chromePath, or one of the names and locations chromedp looks for.
+func chromeInstalled(chromePath string) bool {
+	if chromePath != "" {
+		_, err := exec.LookPath(chromePath)
+		return err == nil
+	}
+	var locations []string
+	switch runtime.GOOS {
+	case "darwin":
+		locations = []string{
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		}
+	case "windows":
+		locations = []string{"chrome", "chrome.exe"}
+	default:
+		locations = []string{
+			"headless_shell", "headless-shell", "chromium", "chromium-browser",
+			"google-chrome", "google-chrome-stable", "google-chrome-beta", "google-chrome-unstable",
+			"/usr/local/bin/chrome", "/snap/bin/chromium", "chrome",
+		}
+	}
+	for _, location := range locations {
+		if _, err := exec.LookPath(location); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveHTTP captures pageURL with a plain GET instead of Chrome: the HTML
+// as the server sends it, without running scripts, so pages that render in
+// JavaScript come out mostly empty. Redirects are checked against
+// opts.Domains like Chrome navigations, and a response that isn't HTML is
+// kept as a download, up to MaxDownloadSize. Screenshots, WaitSelector,
+// WaitStrategy, ExtraDelay, ScrollToBottom and MobileViewport need Chrome
+// and are ignored.
+func archiveHTTP(ctx context.Context, pageURL string, opts ArchiveOptions) (ArchiveResult, error) {
+	if isInternalURL(pageURL) {
+		return ArchiveResult{}, fmt.Errorf("blocked request to %w: %s", ErrInternalAddress, pageURL)
+	}
+	if opts.Screenshot || opts.MobileViewport || opts.ScrollToBottom || strings.TrimSpace(opts.WaitSelector) != "" ||
+		waitStrategy(opts.WaitStrategy) != WaitNetworkIdle || opts.ExtraDelay > 0 {
+		log.Printf("The %s engine can't take screenshots, emulate phones, scroll or wait for selectors or page events; ignoring those options for %s", ArchiveEngineHTTP, pageURL)
+	}
+
+	userAgent := UserAgent
+	if opts.UserAgent != "" {
+		userAgent = opts.UserAgent
+	}
+	client := newFetchClient(opts.Timeout)
+	if !opts.Domains.IsZero() {
+		client.Transport = domainRulesTransport{base: client.Transport, rules: opts.Domains}
+	}
+	client.Transport = headerTransport{base: client.Transport, userAgent: userAgent, headers: opts.ExtraHeaders}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return ArchiveResult{}, &HTTPStatusError{StatusCode: resp.StatusCode, URL: pageURL}
+	}
+	finalURL := resp.Request.URL.String()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxDownloadSize+1))
+	if err != nil {
+		return ArchiveResult{}, err
+	}
+	if len(data) > MaxDownloadSize {
+		return ArchiveResult{}, fmt.Errorf("%s is larger than %s", finalURL, FormatBytes(MaxDownloadSize))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		d := ArchiveDownload{URL: finalURL, Filename: responseFilename(resp), Data: data}
+		d.MIMEType = DownloadMIMEType(d.Filename, data)
+		log.Printf("%s downloaded %s (%s)", pageURL, d.Filename, FormatBytes(int64(len(d.Data))))
+		return ArchiveResult{FinalURL: finalURL, Title: d.Filename, UserAgent: userAgent, Download: &d, Engine: ArchiveEngineHTTP}, nil
+	}
+
+	html := string(data)
+	if opts.RespectRobots {
+		if source := robotsNoArchive(html, resp.Header.Values("X-Robots-Tag")); source != "" {
+			return ArchiveResult{}, fmt.Errorf("%w: %s has a noarchive %s", ErrArchiveDisallowed, finalURL, source)
+		}
+		if !sameOrigin(pageURL, finalURL) {
+			if err := checkRobotsTxt(ctx, newFetchClient(DefaultResourceTimeout), finalURL); err != nil {
+				return ArchiveResult{}, err
+			}
+		}
+	}
+
+	var title string
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	return ArchiveResult{
+		FinalURL:  finalURL,
+		Title:     title,
+		HTML:      html,
+		UserAgent: userAgent,
+		Engine:    ArchiveEngineHTTP,
+	}, nil
+}
+
+// responseFilename names a file downloaded by resp: the Content-Disposition
+// filename, or else the last segment of its URL's path.
+func responseFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return downloadFilename(params["filename"])
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return downloadFilename(name)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseArchiveEngine(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"auto", "", false},
+		{"Chrome", ArchiveEngineChrome, false},
+		{" http ", ArchiveEngineHTTP, false},
+		{"firefox", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseArchiveEngine(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseArchiveEngine(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseArchiveEngine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveBookmark_HTTPEngine(t *testing.T) {
+	var gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			gotUserAgent = r.Header.Get("User-Agent")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html><head><title> Plain Page </title></head><body>Hello</body></html>"))
+		case "/private":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("X-Robots-Tag", "noarchive")
+			_, _ = w.Write([]byte("<html><body>Secret</body></html>"))
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4 test"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := ArchiveOptions{Engine: ArchiveEngineHTTP, UserAgent: "TestAgent/1.0"}
+
+	t.Run("page", func(t *testing.T) {
+		res, err := ArchiveBookmark(context.Background(), server.URL+"/article", opts)
+		if err != nil {
+			t.Fatalf("ArchiveBookmark() error = %v", err)
+		}
+		if res.Engine != ArchiveEngineHTTP {
+			t.Errorf("Engine = %q, want %q", res.Engine, ArchiveEngineHTTP)
+		}
+		if res.Title != "Plain Page" || !strings.Contains(res.HTML, "Hello") {
+			t.Errorf("unexpected result: title %q, HTML %q", res.Title, res.HTML)
+		}
+		if res.UserAgent != "TestAgent/1.0" || gotUserAgent != "TestAgent/1.0" {
+			t.Errorf("expected the custom user agent to be sent and recorded, got %q (sent %q)", res.UserAgent, gotUserAgent)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		res, err := ArchiveBookmark(context.Background(), server.URL+"/report.pdf", opts)
+		if err != nil {
+			t.Fatalf("ArchiveBookmark() error = %v", err)
+		}
+		if res.Download == nil {
+			t.Fatal("expected a download")
+		}
+		if res.Download.Filename != "report.pdf" || res.Download.MIMEType != "application/pdf" || res.HTML != "" {
+			t.Errorf("unexpected download: %q (%s)", res.Download.Filename, res.Download.MIMEType)
+		}
+	})
+
+	t.Run("noarchive", func(t *testing.T) {
+		robotsOpts := opts
+		robotsOpts.RespectRobots = true
+		_, err := ArchiveBookmark(context.Background(), server.URL+"/private", robotsOpts)
+		if !errors.Is(err, ErrArchiveDisallowed) {
+			t.Errorf("expected ErrArchiveDisallowed, got %v", err)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		if _, err := ArchiveBookmark(context.Background(), server.URL+"/missing", opts); err == nil {
+			t.Error("expected an error for a 404")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// FetchFavicon downloads a favicon and returns the image and its content
+// type. Responses that aren't images or are larger than MaxFaviconSize are
+// rejected.
+func FetchFavicon(ctx context.Context, faviconURL string, timeout time.Duration) ([]byte, string, error) {
+	if timeout <= 0 {
+		timeout = DefaultMetadataTimeout
+	}
+	client := newFetchClient(timeout)
+	// Read one byte past the limit so oversized icons are rejected rather
+	// than silently truncated.
+	res, err := fetchURL(ctx, client, faviconURL, MaxFaviconSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch favicon %s: %w", faviconURL, err)
+	}
+	if len(res.data) == 0 {
+		return nil, "", fmt.Errorf("empty favicon %s", faviconURL)
+	}
+	if len(res.data) > MaxFaviconSize {
+		return nil, "", fmt.Errorf("favicon %s is larger than %d bytes", faviconURL, MaxFaviconSize)
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(res.contentType))
+	if idx := strings.Index(contentType, ";"); idx > 0 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	// Plenty of servers send .ico files as application/octet-stream.
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(res.data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("unsupported favicon content type %q for %s", res.contentType, faviconURL)
+	}
+	return res.data, contentType, nil
+}
+
+// SaveFavicon downloads a bookmark's favicon and stores it.
+func SaveFavicon(ctx context.Context, database *db.DB, bookmarkID int64, faviconURL string, timeout time.Duration) error {
+	data, contentType, err := FetchFavicon(ctx, faviconURL, timeout)
+	if err != nil {
+		return err
+	}
+	return database.SaveBookmarkFavicon(db.BookmarkFavicon{
+		BookmarkID:  bookmarkID,
+		SourceURL:   faviconURL,
+		ContentType: contentType,
+		Data:        data,
+	})
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestFetchFavicon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/icon.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngHeader)
+		case "/sniffed.ico":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(pngHeader)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html></html>")
+		case "/huge.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(bytes.Repeat([]byte("x"), MaxFaviconSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantType string
+		wantErr  bool
+	}{
+		{"image", "/icon.png", "image/png", false},
+		{"sniffed octet-stream", "/sniffed.ico", "image/png", false},
+		{"not an image", "/page.html", "", true},
+		{"too large", "/huge.png", "", true},
+		{"not found", "/missing.ico", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, contentType, err := FetchFavicon(context.Background(), srv.URL+tt.path, time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if contentType != tt.wantType || !bytes.Equal(data, pngHeader) {
+				t.Errorf("unexpected favicon %q (%d bytes)", contentType, len(data))
+			}
+		})
+	}
+}
+
+func TestRefreshBookmarkMetadata_SavesFavicon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".png") {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngHeader)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Page</title><link rel="icon" href="/static/icon.png"></head></html>`)
+	}))
+	defer srv.Close()
+
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark(srv.URL+"/page", "Page")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+
+	if err := RefreshBookmarkMetadata(context.Background(), database, b, time.Second); err != nil {
+		t.Fatalf("failed to refresh metadata: %v", err)
+	}
+	f, err := database.GetBookmarkFavicon(id)
+	if err != nil {
+		t.Fatalf("expected favicon to be stored: %v", err)
+	}
+	if f.SourceURL != srv.URL+"/static/icon.png" || f.ContentType != "image/png" {
+		t.Errorf("unexpected favicon: %+v", f)
+	}
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Paths ExportToGit owns inside the repository. Anything else in the
+// repository is left alone.
+const (
+	gitExportBookmarksDir = "bookmarks"
+	gitExportArticlesDir  = "articles"
+	gitExportIndex        = "README.md"
+)
+
+// GitExportOptions configures ExportToGit.
+type GitExportOptions struct {
+	// Dir is the repository's working tree. It is created and initialised
+	// with "git init" if it isn't a repository yet.
+	Dir string
+	// UserID is the user whose bookmarks are exported.
+	UserID int64
+	// Articles also writes the reader-mode text of each archived bookmark
+	// as Markdown.
+	Articles bool
+	// Remote, if set, names the remote (e.g. "origin") the current branch
+	// is pushed to after each export.
+	Remote string
+	// Query, if set, limits the export to the bookmarks a search for it
+	// finds, in the search syntax.
+	Query string
+}
+
+// GitExportResult reports the outcome of ExportToGit.
+type GitExportResult struct {
+	Dir       string `json:"dir"`
+	Bookmarks int    `json:"bookmarks"`
+	Articles  int    `json:"articles"`
+	// Commit is the new commit's hash, or "" when nothing changed.
+	Commit string `json:"commit,omitempty"`
+	Pushed bool   `json:"pushed"`
+}
+
+// ExportToGit writes a user's bookmarks into a git repository as Markdown
+// and commits the changes, giving a versioned plain-text mirror of the
+// collection. Each bookmark becomes bookmarks/{slug}.md (front matter plus
+// notes) and, with opts.Articles, articles/{slug}.md; README.md lists them
+// all. Files of deleted bookmarks, or of ones opts.Query no longer finds,
+// are removed. Nothing is committed when the export is unchanged.
+func ExportToGit(ctx context.Context, database *db.DB, opts GitExportOptions) (GitExportResult, error) {
+	res := GitExportResult{Dir: opts.Dir}
+	if opts.Dir == "" {
+		return res, errors.New("git export needs a repository directory")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return res, fmt.Errorf("failed to create %s: %w", opts.Dir, err)
+	}
+	if _, err := runGit(ctx, opts.Dir, "rev-parse", "--git-dir"); err != nil {
+		if _, err := runGit(ctx, opts.Dir, "init", "-q"); err != nil {
+			return res, err
+		}
+		log.Printf("Initialised git repository in %s", opts.Dir)
+	}
+
+	bookmarks, err := database.ListUserBookmarksMatching(opts.UserID, opts.Query)
+	if err != nil {
+		return res, err
+	}
+	files := map[string]string{}
+	var index strings.Builder
+	index.WriteString("# Bookmarks\n\n")
+	for _, b := range bookmarks {
+		page, err := bookmarkMarkdown(database, b)
+		if err != nil {
+			return res, err
+		}
+		name := b.FileName(".md")
+		files[filepath.Join(gitExportBookmarksDir, name)] = page
+		res.Bookmarks++
+
+		title := b.Title
+		if title == "" {
+			title = b.URL
+		}
+		fmt.Fprintf(&index, "- [%s](%s/%s)", markdownEscape(title), gitExportBookmarksDir, name)
+
+		if opts.Articles {
+			r, err := database.GetBookmarkReadable(b.ID)
+			if err != nil {
+				return res, err
+			}
+			if r.Content != "" {
+				files[filepath.Join(gitExportArticlesDir, name)] = articleMarkdown(b, r)
+				res.Articles++
+				fmt.Fprintf(&index, " ([article](%s/%s))", gitExportArticlesDir, name)
+			}
+		}
+		index.WriteString("\n")
+	}
+	files[gitExportIndex] = index.String()
+
+	if err := writeGitExportFiles(opts.Dir, files); err != nil {
+		return res, err
+	}
+
+	if _, err := runGit(ctx, opts.Dir, "add", "-A", "--", gitExportBookmarksDir, gitExportArticlesDir, gitExportIndex); err != nil {
+		return res, err
+	}
+	// "diff --cached --quiet" exits 1 when something is staged.
+	if _, err := runGit(ctx, opts.Dir, "diff", "--cached", "--quiet"); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return res, err
+		}
+		msg := fmt.Sprintf("Export %d bookmark(s) at %s", res.Bookmarks, time.Now().UTC().Format(time.RFC3339))
+		args := []string{"commit", "-q", "-m", msg}
+		if email, _ := runGit(ctx, opts.Dir, "config", "user.email"); email == "" {
+			// Repositories without an identity still get commits.
+			args = append([]string{"-c", "user.name=bookmarkd", "-c", "user.email=bookmarkd@localhost"}, args...)
+		}
+		if _, err := runGit(ctx, opts.Dir, args...); err != nil {
+			return res, err
+		}
+		if res.Commit, err = runGit(ctx, opts.Dir, "rev-parse", "HEAD"); err != nil {
+			return res, err
+		}
+	}
+
+	// Push even when nothing changed, so a failed push is retried next time.
+	if opts.Remote != "" {
+		if _, err := runGit(ctx, opts.Dir, "push", "-q", opts.Remote, "HEAD"); err != nil {
+			return res, err
+		}
+		res.Pushed = true
+	}
+	return res, nil
+}
+
+// RunGitExportSchedule runs ExportToGit once per interval until ctx is
+// cancelled, pausing during quiet hours. Failures are logged and retried at
+// the next interval.
+func RunGitExportSchedule(ctx context.Context, database *db.DB, interval time.Duration, opts GitExportOptions, quietHours QuietHours) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := quietHours.Wait(ctx, "git export"); err != nil {
+			return err
+		}
+		if res, err := ExportToGit(ctx, database, opts); err != nil {
+			log.Printf("Git export: %v", err)
+		} else if res.Commit != "" {
+			log.Printf("Git export: committed %d bookmark(s) to %s (%s)", res.Bookmarks, res.Dir, res.Commit)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// runGit runs git in dir and returns its trimmed output. Errors include
+// what git printed.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// writeGitExportFiles writes files (paths relative to dir) and removes the
+// Markdown files in the export's directories that are no longer part of it.
+func writeGitExportFiles(dir string, files map[string]string) error {
+	for _, sub := range []string{gitExportBookmarksDir, gitExportArticlesDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", sub, err)
+		}
+		stale, err := filepath.Glob(filepath.Join(dir, sub, "*.md"))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if _, ok := files[rel]; ok {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", rel, err)
+			}
+		}
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		// Unchanged files are left untouched.
+		if old, err := os.ReadFile(path); err == nil && string(old) == content {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// bookmarkMarkdown renders a bookmark as YAML front matter followed by its
+// notes. Strings are JSON-quoted, which YAML accepts.
+func bookmarkMarkdown(database *db.DB, b db.Bookmark) (string, error) {
+	collection, err := database.GetBookmarkCollection(b.ID)
+	if err != nil {
+		return "", err
+	}
+	notes, err := database.GetBookmarkNotes(b.ID)
+	if err != nil {
+		return "", err
+	}
+	flags, err := database.GetBookmarkFlags(b.ID)
+	if err != nil {
+		return "", err
+	}
+	tags, err := database.ListBookmarkTags(b.ID)
+	if err != nil {
+		return "", err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	meta, err := database.GetBookmarkMetadata(b.ID)
+	if err != nil {
+		return "", err
+	}
+	archive, err := database.GetBookmarkArchiveStatus(b.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	out.WriteString("---\n")
+	frontMatter(&out, "id", b.ID)
+	frontMatter(&out, "url", b.URL)
+	frontMatter(&out, "title", b.Title)
+	frontMatter(&out, "created_at", b.CreatedAt)
+	if collection != "" {
+		frontMatter(&out, "collection", collection)
+	}
+	frontMatter(&out, "tags", tags)
+	frontMatter(&out, "read", flags.IsRead)
+	frontMatter(&out, "favorite", flags.IsFavorite)
+	if meta.Description != "" {
+		frontMatter(&out, "description", meta.Description)
+	}
+	if archive.ArchivedAt != "" {
+		frontMatter(&out, "archived_at", archive.ArchivedAt)
+	}
+	out.WriteString("---\n")
+	if notes = strings.TrimSpace(notes); notes != "" {
+		out.WriteString("\n" + notes + "\n")
+	}
+	return out.String(), nil
+}
+
+// articleMarkdown renders a bookmark's reader-mode article.
+func articleMarkdown(b db.Bookmark, r db.BookmarkReadable) string {
+	var out strings.Builder
+	out.WriteString("---\n")
+	frontMatter(&out, "id", b.ID)
+	frontMatter(&out, "url", b.URL)
+	if r.Byline != "" {
+		frontMatter(&out, "byline", r.Byline)
+	}
+	out.WriteString("---\n\n")
+	title := r.Title
+	if title == "" {
+		title = b.Title
+	}
+	if title != "" {
+		out.WriteString("# " + markdownEscape(title) + "\n\n")
+	}
+	out.WriteString(HTMLToMarkdown(r.Content))
+	return out.String()
+}
+
+func frontMatter(out *strings.Builder, key string, value any) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		v = []byte(`""`)
+	}
+	fmt.Fprintf(out, "%s: %s\n", key, v)
+}
+
+// markdownEscape escapes the characters that would end a link text or
+// start emphasis.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`, "`", "\\`").Replace(s)
+}
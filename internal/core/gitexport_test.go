@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestExportToGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	database := newQueueTestDB(t)
+	ctx := context.Background()
+
+	first, err := database.AddBookmark("https://example.com/first", "First [post]")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := database.AddBookmarkTags(first, []string{"go", "notes"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	if err := database.SetBookmarkNotes(first, "Worth *rereading*."); err != nil {
+		t.Fatalf("failed to set notes: %v", err)
+	}
+	now := time.Now()
+	if err := database.SaveArchiveResult(first, now, &now, ArchiveStatusOK, "", "https://example.com/first", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := database.SaveBookmarkReadable(db.BookmarkReadable{BookmarkID: first, Title: "First post", Content: "<p>Article <em>body</em>.</p>"}); err != nil {
+		t.Fatalf("failed to save readable: %v", err)
+	}
+	second, err := database.AddBookmark("https://example.com/second", "Second")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	// Files are named by slug, which starts with the day the bookmark was saved.
+	firstName, secondName := slugFileName(t, database, first), slugFileName(t, database, second)
+	if want := time.Now().Format(time.DateOnly) + "-first-post.md"; firstName != want {
+		t.Fatalf("expected %s, got %s", want, firstName)
+	}
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := runGit(ctx, t.TempDir(), "init", "-q", "--bare", remote); err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "mirror")
+	opts := GitExportOptions{Dir: dir, UserID: db.LocalUserID, Articles: true}
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", rel, err)
+		}
+		return string(data)
+	}
+
+	t.Run("first export initialises and commits", func(t *testing.T) {
+		res, err := ExportToGit(ctx, database, opts)
+		if err != nil {
+			t.Fatalf("ExportToGit() error = %v", err)
+		}
+		if res.Bookmarks != 2 || res.Articles != 1 || res.Commit == "" || res.Pushed {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		page := read("bookmarks/" + firstName)
+		for _, want := range []string{`url: "https://example.com/first"`, `tags: ["go","notes"]`, "read: false", "\nWorth *rereading*.\n"} {
+			if !strings.Contains(page, want) {
+				t.Errorf("bookmark page missing %q:\n%s", want, page)
+			}
+		}
+		if article := read("articles/" + firstName); !strings.Contains(article, "# First post\n\nArticle _body_.\n") {
+			t.Errorf("unexpected article:\n%s", article)
+		}
+		if index := read("README.md"); !strings.Contains(index, `- [First \[post\]](bookmarks/`+firstName+`) ([article](articles/`+firstName+`))`) {
+			t.Errorf("unexpected index:\n%s", index)
+		}
+	})
+
+	t.Run("unchanged export commits nothing", func(t *testing.T) {
+		res, err := ExportToGit(ctx, database, opts)
+		if err != nil {
+			t.Fatalf("ExportToGit() error = %v", err)
+		}
+		if res.Commit != "" {
+			t.Errorf("expected no commit, got %s", res.Commit)
+		}
+	})
+
+	t.Run("deleted bookmarks are removed and changes pushed", func(t *testing.T) {
+		if err := database.DeleteBookmark(second); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		// Files the export doesn't own are left alone.
+		if err := os.WriteFile(filepath.Join(dir, "NOTES.txt"), []byte("mine"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := runGit(ctx, dir, "remote", "add", "origin", remote); err != nil {
+			t.Fatalf("failed to add remote: %v", err)
+		}
+		opts.Remote = "origin"
+		res, err := ExportToGit(ctx, database, opts)
+		if err != nil {
+			t.Fatalf("ExportToGit() error = %v", err)
+		}
+		if res.Commit == "" || !res.Pushed {
+			t.Errorf("expected a pushed commit, got %+v", res)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bookmarks", secondName)); !os.IsNotExist(err) {
+			t.Errorf("expected the deleted bookmark's file to be removed, got %v", err)
+		}
+		if status, err := runGit(ctx, dir, "status", "--porcelain"); err != nil || status != "?? NOTES.txt" {
+			t.Errorf("expected only the untracked file to remain, got %q (err=%v)", status, err)
+		}
+		if head, err := runGit(ctx, remote, "rev-parse", "HEAD"); err != nil || head != res.Commit {
+			t.Errorf("expected the remote at %s, got %s (err=%v)", res.Commit, head, err)
+		}
+	})
+}
+
+// slugFileName returns the Markdown file name ExportToGit gives bookmark id.
+func slugFileName(t *testing.T, database *db.DB, id int64) string {
+	t.Helper()
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+	return b.Slug + ".md"
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Graph node and edge kinds.
+const (
+	GraphNodeBookmark = "bookmark"
+	GraphNodeTag      = "tag"
+	GraphNodeDomain   = "domain"
+
+	// GraphEdgeTag and GraphEdgeDomain join a bookmark to its tag or domain
+	// node; GraphEdgeLink goes from a bookmark to one its archived page
+	// links to.
+	GraphEdgeTag    = "tag"
+	GraphEdgeDomain = "domain"
+	GraphEdgeLink   = "link"
+)
+
+// BookmarkGraph connects bookmarks through the tags and domains they share
+// and the links between their archived pages. Shared tags and domains are
+// nodes of their own, so a tag on n bookmarks adds n edges rather than one
+// per pair; tags and domains with a single bookmark are left out.
+type BookmarkGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a bookmark, tag or domain. IDs are "bookmark:{id}",
+// "tag:{name}" and "domain:{host}".
+type GraphNode struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	// BookmarkID and URL are set on bookmark nodes.
+	BookmarkID int64  `json:"bookmark_id,omitempty"`
+	URL        string `json:"url,omitempty"`
+	// Count is how many bookmarks a tag or domain node joins.
+	Count int `json:"count,omitempty"`
+}
+
+// GraphEdge joins two nodes by ID; link edges point from the linking page.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// BuildBookmarkGraph builds the graph of the bookmarks database sees. Link
+// edges come from the links stored for each bookmark's latest archive (see
+// ExtractLinks), matched to other bookmarks by LinkKey.
+func BuildBookmarkGraph(database *db.DB) (BookmarkGraph, error) {
+	bookmarks, err := database.ListGraphBookmarks()
+	if err != nil {
+		return BookmarkGraph{}, err
+	}
+
+	graph := BookmarkGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	byKey := make(map[string]string)
+	tagged := make(map[string][]string)
+	hosted := make(map[string][]string)
+	for _, b := range bookmarks {
+		id := fmt.Sprintf("%s:%d", GraphNodeBookmark, b.ID)
+		label := b.Title
+		if label == "" {
+			label = b.URL
+		}
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: id, Kind: GraphNodeBookmark, Label: label, BookmarkID: b.ID, URL: b.URL})
+		if key := LinkKey(b.URL); key != "" {
+			byKey[key] = id
+		}
+		for _, tag := range b.Tags {
+			tagged[tag] = append(tagged[tag], id)
+		}
+		if u, err := url.Parse(b.URL); err == nil && u.Hostname() != "" {
+			host := linkHost(u)
+			hosted[host] = append(hosted[host], id)
+		}
+	}
+
+	addShared := func(kind string, members map[string][]string) {
+		names := make([]string, 0, len(members))
+		for name, ids := range members {
+			if len(ids) > 1 {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			node := kind + ":" + name
+			graph.Nodes = append(graph.Nodes, GraphNode{ID: node, Kind: kind, Label: name, Count: len(members[name])})
+			for _, id := range members[name] {
+				graph.Edges = append(graph.Edges, GraphEdge{Source: id, Target: node, Kind: kind})
+			}
+		}
+	}
+	addShared(GraphNodeTag, tagged)
+	addShared(GraphNodeDomain, hosted)
+
+	for _, b := range bookmarks {
+		source := fmt.Sprintf("%s:%d", GraphNodeBookmark, b.ID)
+		seen := map[string]bool{source: true}
+		for _, key := range b.LinkKeys {
+			target, ok := byKey[key]
+			if !ok || seen[target] {
+				continue
+			}
+			seen[target] = true
+			graph.Edges = append(graph.Edges, GraphEdge{Source: source, Target: target, Kind: GraphEdgeLink})
+		}
+	}
+	return graph, nil
+}
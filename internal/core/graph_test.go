@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestBuildBookmarkGraph(t *testing.T) {
+	database := newQueueTestDB(t)
+	a, _ := database.CreateBookmark(db.NewBookmark{URL: "https://blog.example.com/a", Title: "A", Tags: []string{"go", "solo"}})
+	b, _ := database.CreateBookmark(db.NewBookmark{URL: "https://blog.example.com/b/", Title: "B", Tags: []string{"go"}})
+	c, _ := database.CreateBookmark(db.NewBookmark{URL: "https://other.org/c", Title: "C"})
+
+	now := time.Now()
+	if err := database.SaveArchiveResult(a, now, &now, ArchiveStatusOK, "", "https://blog.example.com/a", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	saveArchiveLinks(database, a, `<p><a href="/b">B</a>, <a href="http://other.org/c#top">C</a>, <a href="https://nowhere.net">elsewhere</a>, <a href="/a">itself</a></p>`, "https://blog.example.com/a")
+
+	graph, err := BuildBookmarkGraph(database)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	nodes := map[string]GraphNode{}
+	for _, n := range graph.Nodes {
+		nodes[n.ID] = n
+	}
+	if len(nodes) != 5 {
+		t.Errorf("expected 3 bookmarks plus tag:go and domain:blog.example.com, got %+v", graph.Nodes)
+	}
+	if n := nodes["tag:go"]; n.Kind != GraphNodeTag || n.Count != 2 {
+		t.Errorf("unexpected tag node %+v", n)
+	}
+	if _, ok := nodes["tag:solo"]; ok {
+		t.Error("expected tags on one bookmark to be left out")
+	}
+	if n := nodes["domain:blog.example.com"]; n.Count != 2 {
+		t.Errorf("unexpected domain node %+v", n)
+	}
+
+	edges := map[GraphEdge]bool{}
+	for _, e := range graph.Edges {
+		edges[e] = true
+	}
+	id := func(n int64) string { return fmt.Sprintf("%s:%d", GraphNodeBookmark, n) }
+	for _, want := range []GraphEdge{
+		{Source: id(a), Target: "tag:go", Kind: GraphEdgeTag},
+		{Source: id(b), Target: "tag:go", Kind: GraphEdgeTag},
+		{Source: id(a), Target: "domain:blog.example.com", Kind: GraphEdgeDomain},
+		{Source: id(a), Target: id(b), Kind: GraphEdgeLink},
+		{Source: id(a), Target: id(c), Kind: GraphEdgeLink},
+	} {
+		if !edges[want] {
+			t.Errorf("expected edge %+v in %+v", want, graph.Edges)
+		}
+	}
+	if len(graph.Edges) != 6 {
+		t.Errorf("expected 6 edges, got %+v", graph.Edges)
+	}
+}
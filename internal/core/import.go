@@ -0,0 +1,260 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ImportedBookmark is a bookmark read from another tool's export, with the
+// extra details bookmarkd can keep alongside it.
+type ImportedBookmark struct {
+	db.NewBookmark
+	// Description is the page description the other tool stored; it is
+	// saved as the bookmark's metadata description.
+	Description string
+	// Read marks the bookmark read once it's imported.
+	Read bool
+	// ArchivedAt is when the other tool last archived the page, and
+	// ArchiveURL where that snapshot can be seen, if it's public. bookmarkd
+	// doesn't import the snapshots themselves, so these go into the notes.
+	ArchivedAt time.Time
+	ArchiveURL string
+}
+
+// ImportFormat is an export format ImportBookmarks can read.
+type ImportFormat struct {
+	// Source names the tool the export comes from.
+	Source string
+	Parse  func(io.Reader) ([]ImportedBookmark, error)
+}
+
+// ImportFormats are the supported export formats, keyed by the name the
+// import command and the web import page use for them.
+var ImportFormats = map[string]ImportFormat{
+	"linkding":   {Source: "linkding", Parse: ParseLinkdingExport},
+	"linkwarden": {Source: "Linkwarden", Parse: ParseLinkwardenExport},
+	"pocket":     {Source: "Pocket", Parse: ParsePocketExport},
+	"txt":        {Source: "Plain text", Parse: ParseTextURLs},
+	"md":         {Source: "Markdown", Parse: ParseMarkdownLinks},
+}
+
+// ImportError describes an entry of an export that couldn't be imported.
+type ImportError struct {
+	// Index is the entry's 1-based position in the export.
+	Index int    `json:"index"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// ImportResult reports the outcome of ImportBookmarks.
+type ImportResult struct {
+	Source string `json:"source"`
+	// Added holds the new bookmark IDs in export order.
+	Added []int64 `json:"added"`
+	// Duplicates are URLs listed more than once; only the first was added.
+	Duplicates []string `json:"duplicates"`
+	// Existing are URLs that were already bookmarked and were skipped.
+	Existing []string      `json:"existing"`
+	Invalid  []ImportError `json:"invalid"`
+	// Resumed is how many entries an earlier, interrupted run of the same
+	// import had already handled; their outcomes are included above.
+	Resumed int `json:"resumed,omitempty"`
+}
+
+// importChunkSize is how many export entries ImportBookmarks saves per
+// transaction before recording a checkpoint. It also keeps each lookup of
+// existing bookmarks well under SQLite's bound parameter limit.
+const importChunkSize = 200
+
+// ImportBookmarks saves bookmarks read from another tool's export, named by
+// source. Each keeps its title, tags, collection, notes and creation date,
+// plus tags, which are added to every bookmark. Descriptions are saved as
+// metadata, read flags are kept, and an archive date or snapshot URL from
+// the other tool is noted in the bookmark's notes. Entries keep the
+// Source their caller set, such as the export file's (see
+// db.BookmarkSource); the rest are recorded as imported from source.
+//
+// Invalid URLs, URLs repeated in the export and URLs that are already
+// bookmarked are reported and skipped. The rest are created importChunkSize
+// entries at a time, each chunk in one transaction followed by a checkpoint
+// (db.ImportCheckpoint). Read flags and descriptions are written in that
+// transaction too, so a checkpointed chunk is complete however the import
+// stopped. If the import is cut short, importing the same export with the
+// same tags again resumes after the last checkpoint instead of checking
+// every URL again; a chunk saved just before the interruption is then
+// reported as existing. Archiving is left to the usual bookmark-created
+// listeners or a later "bookmarkd archive" run.
+func ImportBookmarks(database *db.DB, source string, items []ImportedBookmark, tags []string) (ImportResult, error) {
+	res := ImportResult{Source: source, Added: []int64{}, Duplicates: []string{}, Existing: []string{}, Invalid: []ImportError{}}
+	key := importKey(source, items, tags)
+	seen := make(map[string]bool)
+
+	next := 0
+	checkpoint, ok, err := database.GetImportCheckpoint(key)
+	if err != nil {
+		return res, err
+	}
+	if ok {
+		if err := json.Unmarshal([]byte(checkpoint.Result), &res); err != nil || checkpoint.Position > len(items) {
+			log.Printf("Ignoring unusable checkpoint of import from %s: %v", source, err)
+			res = ImportResult{Source: source, Added: []int64{}, Duplicates: []string{}, Existing: []string{}, Invalid: []ImportError{}}
+		} else {
+			next = checkpoint.Position
+			res.Resumed = next
+			for _, item := range items[:next] {
+				seen[strings.TrimSpace(item.URL)] = true
+			}
+			log.Printf("Resuming import from %s after %d of %d entries", source, next, len(items))
+		}
+	}
+
+	for next < len(items) {
+		end := min(next+importChunkSize, len(items))
+		if err := importChunk(database, source, items, next, end, tags, seen, &res); err != nil {
+			return res, err
+		}
+		next = end
+		if next == len(items) {
+			break
+		}
+		state, err := json.Marshal(res)
+		if err != nil {
+			return res, fmt.Errorf("failed to encode import checkpoint: %w", err)
+		}
+		if err := database.SaveImportCheckpoint(db.ImportCheckpoint{
+			Key:      key,
+			Source:   source,
+			Position: next,
+			Total:    len(items),
+			Result:   string(state),
+		}); err != nil {
+			return res, err
+		}
+	}
+	if err := database.DeleteImportCheckpoint(key); err != nil {
+		return res, err
+	}
+
+	finishImport(database, res)
+	return res, nil
+}
+
+// importChunk imports items[start:end] into res, skipping URLs in seen and
+// adding the ones it keeps.
+func importChunk(database *db.DB, source string, items []ImportedBookmark, start, end int, tags []string, seen map[string]bool, res *ImportResult) error {
+	var fresh []ImportedBookmark
+	urls := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		item := items[i]
+		item.URL = strings.TrimSpace(item.URL)
+		if err := db.ValidateBookmarkURL(item.URL); err != nil {
+			res.Invalid = append(res.Invalid, ImportError{Index: i + 1, URL: item.URL, Error: err.Error()})
+			continue
+		}
+		if seen[item.URL] {
+			res.Duplicates = append(res.Duplicates, item.URL)
+			continue
+		}
+		seen[item.URL] = true
+		fresh = append(fresh, item)
+		urls = append(urls, item.URL)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	existing, err := database.ExistingBookmarkURLs(urls)
+	if err != nil {
+		return err
+	}
+	var nbs []db.NewBookmark
+	for _, item := range fresh {
+		if existing[item.URL] {
+			res.Existing = append(res.Existing, item.URL)
+			continue
+		}
+		nb := item.NewBookmark
+		nb.Tags = append(append([]string{}, nb.Tags...), tags...)
+		nb.Notes = importNotes(source, item)
+		nb.Description = item.Description
+		nb.IsRead = nb.IsRead || item.Read
+		if nb.Source == "" {
+			nb.Source = db.BookmarkSource(db.SourceImport, source)
+		}
+		nbs = append(nbs, nb)
+	}
+	if len(nbs) == 0 {
+		return nil
+	}
+
+	ids, err := database.CreateBookmarks(nbs)
+	if err != nil {
+		return err
+	}
+	res.Added = append(res.Added, ids...)
+	return nil
+}
+
+// importKey identifies an import for its checkpoints: a hash of the source,
+// the export's URLs in order and the extra tags.
+func importKey(source string, items []ImportedBookmark, tags []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n", source, len(items))
+	for _, item := range items {
+		fmt.Fprintf(h, "%s\n", strings.TrimSpace(item.URL))
+	}
+	fmt.Fprintf(h, "%q", tags)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// finishImport logs the outcome of an import and emits its
+// ImportFinishedEvent.
+func finishImport(database *db.DB, res ImportResult) {
+	log.Printf("Import from %s: added %d bookmark(s), skipped %d duplicate(s), %d existing and %d invalid",
+		res.Source, len(res.Added), len(res.Duplicates), len(res.Existing), len(res.Invalid))
+	database.EmitImportFinished(db.ImportFinishedEvent{
+		Source:  res.Source,
+		Added:   len(res.Added),
+		Skipped: len(res.Duplicates) + len(res.Existing),
+		Invalid: len(res.Invalid),
+	})
+}
+
+// importNotes returns item's notes with a line recording the other tool's
+// archive of the page, if it had one.
+func importNotes(source string, item ImportedBookmark) string {
+	notes := strings.TrimSpace(item.Notes)
+	var line string
+	switch {
+	case item.ArchiveURL != "" && !item.ArchivedAt.IsZero():
+		line = fmt.Sprintf("Archived by %s on %s: %s", source, item.ArchivedAt.UTC().Format(time.DateOnly), item.ArchiveURL)
+	case item.ArchiveURL != "":
+		line = fmt.Sprintf("Archived by %s: %s", source, item.ArchiveURL)
+	case !item.ArchivedAt.IsZero():
+		line = fmt.Sprintf("Archived by %s on %s.", source, item.ArchivedAt.UTC().Format(time.DateOnly))
+	default:
+		return notes
+	}
+	if notes == "" {
+		return line
+	}
+	return notes + "\n\n" + line
+}
+
+// parseImportTime parses a timestamp from an export, returning the zero
+// time for values that are missing or unreadable.
+func parseImportTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
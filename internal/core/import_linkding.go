@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// linkdingBookmark is a bookmark as linkding's REST API returns it. Older
+// releases only filled website_title and website_description.
+type linkdingBookmark struct {
+	URL                   string   `json:"url"`
+	Title                 string   `json:"title"`
+	Description           string   `json:"description"`
+	Notes                 string   `json:"notes"`
+	WebsiteTitle          string   `json:"website_title"`
+	WebsiteDescription    string   `json:"website_description"`
+	WebArchiveSnapshotURL string   `json:"web_archive_snapshot_url"`
+	Unread                bool     `json:"unread"`
+	TagNames              []string `json:"tag_names"`
+	DateAdded             string   `json:"date_added"`
+}
+
+// ParseLinkdingExport reads bookmarks exported from linkding as JSON: either
+// a page of its /api/bookmarks/ endpoint ({"results": [...]}) or a plain
+// array of the same bookmark objects. Web archive snapshot URLs are kept as
+// the archive URL.
+func ParseLinkdingExport(r io.Reader) ([]ImportedBookmark, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read linkding export: %w", err)
+	}
+	var bookmarks []linkdingBookmark
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(raw, &bookmarks)
+	} else {
+		var page struct {
+			Results *[]linkdingBookmark `json:"results"`
+		}
+		err = json.Unmarshal(raw, &page)
+		if err == nil && page.Results == nil {
+			err = fmt.Errorf("no results array")
+		}
+		if page.Results != nil {
+			bookmarks = *page.Results
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid linkding export: %w", err)
+	}
+
+	items := make([]ImportedBookmark, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		item := ImportedBookmark{
+			NewBookmark: db.NewBookmark{
+				URL:       b.URL,
+				Title:     firstNonEmpty(b.Title, b.WebsiteTitle),
+				Tags:      b.TagNames,
+				Notes:     b.Notes,
+				CreatedAt: parseImportTime(b.DateAdded),
+			},
+			Description: firstNonEmpty(b.Description, b.WebsiteDescription),
+			Read:        !b.Unread,
+			ArchiveURL:  b.WebArchiveSnapshotURL,
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// firstNonEmpty returns the first of values that isn't blank, trimmed.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// linkwardenDefaultCollection is the collection Linkwarden files links in
+// when the user didn't pick one; it isn't carried over.
+const linkwardenDefaultCollection = "Unorganized"
+
+// linkwardenBackup is the parts of a Linkwarden data export (Settings →
+// Data → Export) that are imported: collections and the links in them.
+type linkwardenBackup struct {
+	Collections *[]struct {
+		Name  string `json:"name"`
+		Links []struct {
+			Name          string `json:"name"`
+			URL           string `json:"url"`
+			Description   string `json:"description"`
+			LastPreserved string `json:"lastPreserved"`
+			ImportDate    string `json:"importDate"`
+			CreatedAt     string `json:"createdAt"`
+			Tags          []struct {
+				Name string `json:"name"`
+			} `json:"tags"`
+		} `json:"links"`
+	} `json:"collections"`
+}
+
+// ParseLinkwardenExport reads the links of a Linkwarden data export. Each
+// link keeps its collection (except Linkwarden's default "Unorganized"),
+// tags and description; lastPreserved, when Linkwarden last archived the
+// page, becomes the archive date. Links Linkwarden itself imported keep
+// their original date.
+func ParseLinkwardenExport(r io.Reader) ([]ImportedBookmark, error) {
+	var backup linkwardenBackup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("invalid Linkwarden export: %w", err)
+	}
+	if backup.Collections == nil {
+		return nil, fmt.Errorf("invalid Linkwarden export: no collections")
+	}
+
+	var items []ImportedBookmark
+	for _, c := range *backup.Collections {
+		collection := c.Name
+		if collection == linkwardenDefaultCollection {
+			collection = ""
+		}
+		for _, l := range c.Links {
+			tags := make([]string, 0, len(l.Tags))
+			for _, t := range l.Tags {
+				tags = append(tags, t.Name)
+			}
+			created := parseImportTime(l.ImportDate)
+			if created.IsZero() {
+				created = parseImportTime(l.CreatedAt)
+			}
+			items = append(items, ImportedBookmark{
+				NewBookmark: db.NewBookmark{
+					URL:        l.URL,
+					Title:      l.Name,
+					Tags:       tags,
+					Collection: collection,
+					CreatedAt:  created,
+				},
+				Description: l.Description,
+				ArchivedAt:  parseImportTime(l.LastPreserved),
+			})
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ParsePocketExport reads a Pocket export in either of its formats: the
+// ril_export.html page of older exports, or the CSV (title, url, time_added,
+// tags, status) Pocket produced from 2024 until it shut down. Items Pocket
+// had archived are imported as read.
+func ParsePocketExport(r io.Reader) ([]ImportedBookmark, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Pocket export: %w", err)
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("<")) {
+		return parsePocketHTML(raw)
+	}
+	return parsePocketCSV(raw)
+}
+
+// parsePocketHTML reads ril_export.html: an "Unread" and a "Read Archive"
+// heading, each followed by a list of links carrying time_added and
+// comma-separated tags attributes.
+func parsePocketHTML(raw []byte) ([]ImportedBookmark, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pocket export: %w", err)
+	}
+	items := []ImportedBookmark{}
+	doc.Find("ul").Each(func(_ int, list *goquery.Selection) {
+		heading := strings.ToLower(strings.TrimSpace(list.PrevAllFiltered("h1").First().Text()))
+		read := strings.Contains(heading, "read archive")
+		list.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+			href, _ := a.Attr("href")
+			tags, _ := a.Attr("tags")
+			added, _ := a.Attr("time_added")
+			items = append(items, ImportedBookmark{
+				NewBookmark: db.NewBookmark{
+					URL:       href,
+					Title:     pocketTitle(strings.TrimSpace(a.Text()), href),
+					Tags:      splitPocketTags(tags, ","),
+					CreatedAt: parseUnixTime(added),
+				},
+				Read: read,
+			})
+		})
+	})
+	return items, nil
+}
+
+// parsePocketCSV reads Pocket's CSV export. Columns are found by their
+// header names; tags are separated by "|" and status is "unread" or
+// "archive".
+func parsePocketCSV(raw []byte) ([]ImportedBookmark, error) {
+	records, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pocket export: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("invalid Pocket export: empty file")
+	}
+	cols := make(map[string]int)
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["url"]; !ok {
+		return nil, fmt.Errorf("invalid Pocket export: no url column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	items := make([]ImportedBookmark, 0, len(records)-1)
+	for _, record := range records[1:] {
+		url := field(record, "url")
+		items = append(items, ImportedBookmark{
+			NewBookmark: db.NewBookmark{
+				URL:       url,
+				Title:     pocketTitle(field(record, "title"), url),
+				Tags:      splitPocketTags(field(record, "tags"), "|"),
+				CreatedAt: parseUnixTime(field(record, "time_added")),
+			},
+			Read: field(record, "status") == "archive",
+		})
+	}
+	return items, nil
+}
+
+// pocketTitle drops the titles Pocket filled in with the URL itself, so the
+// page's real title can be fetched instead.
+func pocketTitle(title, url string) string {
+	if title == url {
+		return ""
+	}
+	return title
+}
+
+// splitPocketTags splits a Pocket tag list on sep.
+func splitPocketTags(s, sep string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// parseUnixTime parses a Unix timestamp in seconds, returning the zero time
+// for values that are missing or unreadable.
+func parseUnixTime(s string) time.Time {
+	secs, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).UTC()
+}
@@ -0,0 +1,379 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestParseLinkdingExport(t *testing.T) {
+	const page = `{"count": 2, "next": null, "results": [
+		{"id": 1, "url": "https://go.dev", "title": "", "website_title": "The Go Programming Language",
+		 "description": "Go home", "notes": "Read the **tour**", "unread": true,
+		 "web_archive_snapshot_url": "https://web.archive.org/web/20240101000000/https://go.dev",
+		 "tag_names": ["go", "lang"], "date_added": "2024-01-02T03:04:05.123456Z"},
+		{"id": 2, "url": "https://example.com", "title": "Example", "unread": false, "tag_names": []}
+	]}`
+
+	items, err := ParseLinkdingExport(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 bookmarks, got %d", len(items))
+	}
+	first := items[0]
+	if first.URL != "https://go.dev" || first.Title != "The Go Programming Language" {
+		t.Errorf("unexpected bookmark: %+v", first)
+	}
+	if first.Description != "Go home" || first.Notes != "Read the **tour**" || first.Read {
+		t.Errorf("unexpected description, notes or read flag: %+v", first)
+	}
+	if !reflect.DeepEqual(first.Tags, []string{"go", "lang"}) {
+		t.Errorf("unexpected tags: %v", first.Tags)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC); !first.CreatedAt.Equal(want) {
+		t.Errorf("expected created at %v, got %v", want, first.CreatedAt)
+	}
+	if !strings.HasPrefix(first.ArchiveURL, "https://web.archive.org/") {
+		t.Errorf("expected the snapshot URL, got %q", first.ArchiveURL)
+	}
+	if !items[1].Read || !items[1].CreatedAt.IsZero() {
+		t.Errorf("expected second bookmark read with no date, got %+v", items[1])
+	}
+
+	t.Run("plain array", func(t *testing.T) {
+		items, err := ParseLinkdingExport(strings.NewReader(`[{"url": "https://a.com", "title": "A"}]`))
+		if err != nil || len(items) != 1 || items[0].Title != "A" {
+			t.Errorf("expected one bookmark, got %+v, %v", items, err)
+		}
+	})
+
+	t.Run("rejects other JSON", func(t *testing.T) {
+		for _, in := range []string{`{"bookmarks": []}`, `not json`} {
+			if _, err := ParseLinkdingExport(strings.NewReader(in)); err == nil {
+				t.Errorf("expected error for %q", in)
+			}
+		}
+	})
+}
+
+func TestParseLinkwardenExport(t *testing.T) {
+	const backup = `{"id": 1, "username": "me", "collections": [
+		{"id": 1, "name": "Unorganized", "links": [
+			{"id": 10, "name": "Go", "url": "https://go.dev", "description": "Go home",
+			 "lastPreserved": "2024-02-03T00:00:00.000Z", "importDate": null,
+			 "createdAt": "2024-02-01T10:00:00.000Z", "tags": [{"id": 1, "name": "go"}]}
+		]},
+		{"id": 2, "name": "Reading", "links": [
+			{"id": 11, "name": "Post", "url": "https://example.com/post", "description": "",
+			 "lastPreserved": null, "importDate": "2019-05-06T07:08:09.000Z",
+			 "createdAt": "2024-02-01T10:00:00.000Z", "tags": []}
+		]}
+	]}`
+
+	items, err := ParseLinkwardenExport(strings.NewReader(backup))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 bookmarks, got %d", len(items))
+	}
+	if items[0].Collection != "" || items[1].Collection != "Reading" {
+		t.Errorf("unexpected collections: %q, %q", items[0].Collection, items[1].Collection)
+	}
+	if items[0].Title != "Go" || items[0].Description != "Go home" || !reflect.DeepEqual(items[0].Tags, []string{"go"}) {
+		t.Errorf("unexpected bookmark: %+v", items[0])
+	}
+	if want := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC); !items[0].ArchivedAt.Equal(want) {
+		t.Errorf("expected archived at %v, got %v", want, items[0].ArchivedAt)
+	}
+	if want := time.Date(2019, 5, 6, 7, 8, 9, 0, time.UTC); !items[1].CreatedAt.Equal(want) {
+		t.Errorf("expected the import date %v, got %v", want, items[1].CreatedAt)
+	}
+
+	if _, err := ParseLinkwardenExport(strings.NewReader(`{"links": []}`)); err == nil {
+		t.Error("expected error for an export without collections")
+	}
+}
+
+func TestParsePocketExport(t *testing.T) {
+	t.Run("ril_export.html", func(t *testing.T) {
+		const export = `<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://go.dev" time_added="1700000000" tags="go,lang">Go</a></li>
+<li><a href="https://example.com/x" time_added="" tags="">https://example.com/x</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://example.com/old" time_added="1500000000" tags="">Old post</a></li>
+</ul>
+</body></html>`
+		items, err := ParsePocketExport(strings.NewReader(export))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(items) != 3 {
+			t.Fatalf("expected 3 bookmarks, got %d", len(items))
+		}
+		if items[0].Title != "Go" || !reflect.DeepEqual(items[0].Tags, []string{"go", "lang"}) || items[0].Read {
+			t.Errorf("unexpected bookmark: %+v", items[0])
+		}
+		if !items[0].CreatedAt.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("expected time added, got %v", items[0].CreatedAt)
+		}
+		if items[1].Title != "" || !items[1].CreatedAt.IsZero() {
+			t.Errorf("expected no title or date, got %+v", items[1])
+		}
+		if !items[2].Read {
+			t.Error("expected archived item to be read")
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		const export = "\ufefftitle,url,time_added,tags,status\n" +
+			"Go,https://go.dev,1700000000,go|lang,unread\n" +
+			"\"Old, post\",https://example.com/old,1500000000,,archive\n"
+		items, err := ParsePocketExport(strings.NewReader(export))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("expected 2 bookmarks, got %d", len(items))
+		}
+		if items[0].URL != "https://go.dev" || !reflect.DeepEqual(items[0].Tags, []string{"go", "lang"}) || items[0].Read {
+			t.Errorf("unexpected bookmark: %+v", items[0])
+		}
+		if items[1].Title != "Old, post" || !items[1].Read || len(items[1].Tags) != 0 {
+			t.Errorf("unexpected bookmark: %+v", items[1])
+		}
+	})
+
+	t.Run("rejects a CSV without urls", func(t *testing.T) {
+		if _, err := ParsePocketExport(strings.NewReader("title,tags\nGo,go\n")); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestParseTextURLs(t *testing.T) {
+	const text = `Reading list
+https://go.dev/doc/ (the docs), and https://en.wikipedia.org/wiki/Go_(programming_language).
+See also: <https://example.com/a?b=1&c=2>; ftp://example.com/skipped
+`
+	items, err := ParseTextURLs(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var urls []string
+	for _, item := range items {
+		urls = append(urls, item.URL)
+		if item.Title != "" {
+			t.Errorf("expected no title, got %q", item.Title)
+		}
+	}
+	want := []string{"https://go.dev/doc/", "https://en.wikipedia.org/wiki/Go_(programming_language)", "https://example.com/a?b=1&c=2"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("expected %v, got %v", want, urls)
+	}
+}
+
+func TestParseMarkdownLinks(t *testing.T) {
+	const text = `# Links
+
+- [The **Go** blog](https://go.dev/blog "Go blog") is great.
+- [https://sqlite.org/](https://sqlite.org/)
+- ![diagram](https://example.com/diagram.png)
+- Bare: https://example.com/bare.
+- [Reference][1]
+
+[1]: https://example.com/ref "Ref"
+`
+	items, err := ParseMarkdownLinks(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	type link struct{ URL, Title string }
+	var got []link
+	for _, item := range items {
+		got = append(got, link{item.URL, item.Title})
+	}
+	want := []link{
+		{"https://go.dev/blog", "The Go blog"},
+		{"https://sqlite.org/", ""},
+		{"https://example.com/bare", ""},
+		{"https://example.com/ref", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestImportBookmarks(t *testing.T) {
+	database := newQueueTestDB(t)
+	if _, err := database.AddBookmark("https://saved.com", "Saved"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []ImportedBookmark{
+		{
+			NewBookmark: db.NewBookmark{URL: "https://one.com", Title: "One", Tags: []string{"go"}, Notes: "Mine", CreatedAt: created},
+			Description: "A description",
+			Read:        true,
+			ArchivedAt:  time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{NewBookmark: db.NewBookmark{URL: "not a url"}},
+		{NewBookmark: db.NewBookmark{URL: "https://one.com"}},
+		{NewBookmark: db.NewBookmark{URL: "https://saved.com"}},
+	}
+
+	var finished []db.ImportFinishedEvent
+	database.RegisterEventListener(db.OnImportFinishedEvent, func(event db.Event) error {
+		finished = append(finished, event.(db.ImportFinishedEvent))
+		return nil
+	})
+
+	res, err := ImportBookmarks(database, "Linkwarden", items, []string{"imported"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := (db.ImportFinishedEvent{Source: "Linkwarden", Added: 1, Skipped: 2, Invalid: 1, UserID: db.LocalUserID}); len(finished) != 1 || finished[0] != want {
+		t.Errorf("expected %+v, got %+v", want, finished)
+	}
+	if len(res.Added) != 1 || res.Source != "Linkwarden" {
+		t.Fatalf("expected 1 bookmark added, got %+v", res)
+	}
+	if !reflect.DeepEqual(res.Duplicates, []string{"https://one.com"}) || !reflect.DeepEqual(res.Existing, []string{"https://saved.com"}) {
+		t.Errorf("unexpected duplicates or existing: %+v", res)
+	}
+	if len(res.Invalid) != 1 || res.Invalid[0].Index != 2 {
+		t.Errorf("unexpected invalid entries: %+v", res.Invalid)
+	}
+
+	id := res.Added[0]
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+	if b.Title != "One" || b.CreatedAt != created.Format(time.RFC3339) {
+		t.Errorf("unexpected bookmark: %+v", b)
+	}
+	tags, err := database.ListBookmarkTags(id)
+	if err != nil || !reflect.DeepEqual(tags, []string{"go", "imported"}) {
+		t.Errorf("expected tags [go imported], got %v, %v", tags, err)
+	}
+	notes, err := database.GetBookmarkNotes(id)
+	if err != nil || notes != "Mine\n\nArchived by Linkwarden on 2021-06-07." {
+		t.Errorf("unexpected notes %q, %v", notes, err)
+	}
+	meta, err := database.GetBookmarkMetadata(id)
+	if err != nil || meta.Description != "A description" {
+		t.Errorf("expected the description in metadata, got %+v, %v", meta, err)
+	}
+	flags, err := database.GetBookmarkFlags(id)
+	if err != nil || !flags.IsRead {
+		t.Errorf("expected the bookmark to be read, got %+v, %v", flags, err)
+	}
+
+	t.Run("re-running skips everything", func(t *testing.T) {
+		res, err := ImportBookmarks(database, "Linkwarden", items[:1], nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(res.Added) != 0 || len(res.Existing) != 1 {
+			t.Errorf("expected the bookmark to be skipped, got %+v", res)
+		}
+	})
+}
+
+func TestImportBookmarks_Resume(t *testing.T) {
+	database := newQueueTestDB(t)
+
+	items := make([]ImportedBookmark, 2*importChunkSize+10)
+	for i := range items {
+		items[i].URL = fmt.Sprintf("https://example.com/%d", i)
+	}
+	key := importKey("Pocket", items, nil)
+
+	t.Run("checkpoints after each chunk", func(t *testing.T) {
+		var checkpoints []int
+		database.RegisterEventListener(db.OnBookmarkCreatedEvent, func(db.Event) error {
+			if c, ok, err := database.GetImportCheckpoint(key); err == nil && ok {
+				if len(checkpoints) == 0 || checkpoints[len(checkpoints)-1] != c.Position {
+					checkpoints = append(checkpoints, c.Position)
+				}
+			}
+			return nil
+		})
+		res, err := ImportBookmarks(database, "Pocket", items, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(res.Added) != len(items) || res.Resumed != 0 {
+			t.Errorf("expected every bookmark to be added, got %d (resumed %d)", len(res.Added), res.Resumed)
+		}
+		if !reflect.DeepEqual(checkpoints, []int{importChunkSize, 2 * importChunkSize}) {
+			t.Errorf("expected checkpoints after each chunk, got %v", checkpoints)
+		}
+		if _, ok, err := database.GetImportCheckpoint(key); err != nil || ok {
+			t.Errorf("expected the checkpoint to be removed, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	t.Run("saves read flags and descriptions with the chunk", func(t *testing.T) {
+		database := newQueueTestDB(t)
+		items := []ImportedBookmark{{NewBookmark: db.NewBookmark{URL: "https://example.com/read"}, Description: "About it", Read: true}}
+		// Created events fire once the chunk commits, before its checkpoint.
+		var flags db.BookmarkFlags
+		var meta db.BookmarkMetadata
+		database.RegisterEventListener(db.OnBookmarkCreatedEvent, func(e db.Event) error {
+			id := e.(db.BookmarkCreatedEvent).Bookmark.ID
+			flags, _ = database.GetBookmarkFlags(id)
+			meta, _ = database.GetBookmarkMetadata(id)
+			return nil
+		})
+		if _, err := ImportBookmarks(database, "Pocket", items, nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !flags.IsRead || meta.Description != "About it" {
+			t.Errorf("expected the chunk to commit read and described, got %+v and %+v", flags, meta)
+		}
+	})
+
+	t.Run("resumes after the checkpoint", func(t *testing.T) {
+		items := append([]ImportedBookmark{}, items...)
+		items = append(items, ImportedBookmark{NewBookmark: db.NewBookmark{URL: "https://example.com/new"}}, ImportedBookmark{NewBookmark: db.NewBookmark{URL: "https://example.com/0"}})
+		key := importKey("Pocket", items, []string{"resumed"})
+		// As if an earlier run stopped after the first chunks.
+		if err := database.SaveImportCheckpoint(db.ImportCheckpoint{
+			Key:      key,
+			Source:   "Pocket",
+			Position: 2 * importChunkSize,
+			Total:    len(items),
+			Result:   `{"source":"Pocket","added":[1,2],"duplicates":[],"existing":[],"invalid":[]}`,
+		}); err != nil {
+			t.Fatalf("failed to save checkpoint: %v", err)
+		}
+
+		res, err := ImportBookmarks(database, "Pocket", items, []string{"resumed"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if res.Resumed != 2*importChunkSize || len(res.Added) != 3 || len(res.Existing) != 10 {
+			t.Errorf("expected only the entries after the checkpoint to be checked, got resumed %d, %d added, %d existing", res.Resumed, len(res.Added), len(res.Existing))
+		}
+		if !reflect.DeepEqual(res.Duplicates, []string{"https://example.com/0"}) {
+			t.Errorf("expected an entry from before the checkpoint to count as a duplicate, got %v", res.Duplicates)
+		}
+		if _, ok, err := database.GetImportCheckpoint(key); err != nil || ok {
+			t.Errorf("expected the checkpoint to be removed, got ok=%v err=%v", ok, err)
+		}
+	})
+}
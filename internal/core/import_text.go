@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+var (
+	// textURLPattern finds bare http(s) URLs. One level of parentheses is
+	// allowed inside, for URLs such as Wikipedia's.
+	textURLPattern = regexp.MustCompile(`https?://(?:[^\s()<>\[\]"'` + "`" + `]|\([^\s()<>]*\))+`)
+	// markdownLinkPattern finds inline Markdown links and images,
+	// [text](url "title"), with the URL optionally in angle brackets.
+	markdownLinkPattern = regexp.MustCompile(`(!?)\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\(\s*<?(https?://(?:[^\s()<>]|\([^\s()<>]*\))+)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+	// markdownRefPattern finds Markdown link reference definitions,
+	// [label]: url.
+	markdownRefPattern = regexp.MustCompile(`(?m)^[ \t]{0,3}\[[^\]]+\]:[ \t]*<?(https?://\S+?)>?(?:[ \t]+.*)?$`)
+	// markdownEmphasis is the inline markup stripped from link texts.
+	markdownEmphasis = strings.NewReplacer("**", "", "__", "", "*", "", "`", "", "~~", "")
+)
+
+// ParseTextURLs reads the http(s) URLs in a plain text file, such as a
+// links.txt kept by hand or a notes export, in the order they appear.
+// Punctuation ending a sentence after a URL isn't taken as part of it.
+// Plain text has no titles, so they are fetched as for any new bookmark.
+func ParseTextURLs(r io.Reader) ([]ImportedBookmark, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read text file: %w", err)
+	}
+	items := []ImportedBookmark{}
+	for _, u := range textURLPattern.FindAllString(string(raw), -1) {
+		items = append(items, ImportedBookmark{NewBookmark: db.NewBookmark{URL: trimTextURL(u)}})
+	}
+	return items, nil
+}
+
+// ParseMarkdownLinks reads the links in a Markdown file, such as a note
+// app's export, in the order they appear: inline links keep their text as
+// the title, while reference definitions and bare or <autolinked> URLs
+// come without one. Images aren't bookmarked.
+func ParseMarkdownLinks(r io.Reader) ([]ImportedBookmark, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Markdown file: %w", err)
+	}
+	text := string(raw)
+
+	type found struct {
+		pos  int
+		item ImportedBookmark
+	}
+	var links []found
+	// Links found are blanked out of rest, so the bare URL scan doesn't
+	// see them again.
+	rest := []byte(text)
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			rest[i] = ' '
+		}
+	}
+	for _, m := range markdownLinkPattern.FindAllStringSubmatchIndex(text, -1) {
+		blank(m[0], m[1])
+		if m[3] > m[2] {
+			continue // an image
+		}
+		url := text[m[6]:m[7]]
+		links = append(links, found{m[0], ImportedBookmark{NewBookmark: db.NewBookmark{
+			URL:   url,
+			Title: markdownLinkTitle(text[m[4]:m[5]], url),
+		}}})
+	}
+	for _, m := range markdownRefPattern.FindAllStringSubmatchIndex(string(rest), -1) {
+		blank(m[0], m[1])
+		links = append(links, found{m[0], ImportedBookmark{NewBookmark: db.NewBookmark{URL: text[m[2]:m[3]]}}})
+	}
+	for _, m := range textURLPattern.FindAllStringIndex(string(rest), -1) {
+		links = append(links, found{m[0], ImportedBookmark{NewBookmark: db.NewBookmark{URL: trimTextURL(text[m[0]:m[1]])}}})
+	}
+
+	sort.SliceStable(links, func(i, j int) bool { return links[i].pos < links[j].pos })
+	items := make([]ImportedBookmark, 0, len(links))
+	for _, l := range links {
+		items = append(items, l.item)
+	}
+	return items, nil
+}
+
+// trimTextURL drops the punctuation that ends a sentence or closes a
+// bracket after a URL found in text.
+func trimTextURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?*_")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
+
+// markdownLinkTitle turns a link's text into a title: emphasis and extra
+// whitespace are removed, and texts that only repeat the URL are dropped so
+// the page's real title is fetched.
+func markdownLinkTitle(text, url string) string {
+	title := strings.Join(strings.Fields(markdownEmphasis.Replace(text)), " ")
+	if title == url || strings.Trim(title, "<>") == url {
+		return ""
+	}
+	return title
+}
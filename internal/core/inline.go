@@ -3,13 +3,17 @@ package core
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -50,17 +54,10 @@ func isInternalURL(urlStr string) bool {
 		return true
 	}
 
-	// Parse as IP and check for private ranges
-	ip := net.ParseIP(host)
-	if ip != nil {
-		// Check for loopback, private, and link-local addresses
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return true
-		}
-		// Block unspecified addresses (0.0.0.0, ::)
-		if ip.IsUnspecified() {
-			return true
-		}
+	// Parse as IP and check for private ranges. Hostnames are resolved and
+	// checked when they are dialed; see publicDialer.
+	if ip := net.ParseIP(host); ip != nil && isInternalIP(ip) {
+		return true
 	}
 
 	return false
@@ -81,6 +78,57 @@ type InlineOptions struct {
 	InlineCSS bool
 	// InlineJS controls whether external scripts are inlined.
 	InlineJS bool
+	// InlineFonts controls whether font files named in @font-face rules
+	// are inlined. Fonts that aren't keep their absolute URL.
+	InlineFonts bool
+	// MaxFontSize is the maximum size of a font to inline (bytes); larger
+	// fonts keep their absolute URL. 0 means no limit.
+	MaxFontSize int64
+	// MaxTotalSize bounds the inlined HTML (bytes). Resources are inlined
+	// in document order until the next one would take the page past it;
+	// that one and the rest keep their URLs, and no more are downloaded.
+	// 0 means no limit.
+	MaxTotalSize int64
+	// Domains limits which hosts resources are fetched from, e.g. to keep
+	// trackers out of archives. Blocked resources keep their original URL.
+	Domains DomainRules
+	// Blocklist, if set, refuses resources its filters block on BaseURL's
+	// page; they keep their original URL.
+	Blocklist *Blocklist
+	// UserAgent, if set, replaces the UserAgent resources are fetched with.
+	UserAgent string
+	// ExtraHeaders are sent with every resource request.
+	ExtraHeaders Headers
+	// Workers bounds how many resources are fetched at once, and
+	// WorkersPerHost how many of those come from the same host. Zero
+	// values fall back to DefaultInlineWorkers and
+	// DefaultInlineWorkersPerHost.
+	Workers        int
+	WorkersPerHost int
+	// Embeds is what becomes of iframes, video, audio and plugin embeds:
+	// EmbedsKeep (or ""), EmbedsPlaceholder or EmbedsInline.
+	Embeds string
+	// frameDepth is how deeply the document being inlined is nested in
+	// iframes snapshotted by EmbedsInline.
+	frameDepth int
+	// Cache, if set, serves resources fetched less than CacheTTL ago
+	// without downloading them again, and keeps the ones downloaded. It
+	// isn't used with ExtraHeaders, which may make responses private.
+	Cache    ResourceCache
+	CacheTTL time.Duration
+}
+
+// workers returns opts.Workers and opts.WorkersPerHost with their defaults
+// filled in.
+func (opts InlineOptions) workers() (int, int) {
+	workers, perHost := opts.Workers, opts.WorkersPerHost
+	if workers <= 0 {
+		workers = DefaultInlineWorkers
+	}
+	if perHost <= 0 {
+		perHost = DefaultInlineWorkersPerHost
+	}
+	return workers, perHost
 }
 
 // DefaultInlineOptions returns sensible defaults for inlining.
@@ -92,6 +140,11 @@ func DefaultInlineOptions(baseURL string) InlineOptions {
 		InlineImages:    true,
 		InlineCSS:       true,
 		InlineJS:        true,
+		InlineFonts:     true,
+		MaxFontSize:     MaxFontSize,
+		MaxTotalSize:    MaxArchiveSize,
+		Workers:         DefaultInlineWorkers,
+		WorkersPerHost:  DefaultInlineWorkersPerHost,
 	}
 }
 
@@ -103,32 +156,212 @@ type resourceInliner struct {
 	client  *http.Client
 	baseURL *url.URL
 	opts    InlineOptions
+	// size is the document's size so far, counting applied changes, and
+	// full is set once a change didn't fit in opts.MaxTotalSize.
+	size    int64
+	full    bool
+	fetched *fetchBudget
 }
 
-// newResourceInliner creates a new resourceInliner with the given configuration.
-func newResourceInliner(ctx context.Context, opts InlineOptions) (*resourceInliner, error) {
+// newResourceInliner creates a new resourceInliner with the given
+// configuration, for a document of size bytes.
+func newResourceInliner(ctx context.Context, opts InlineOptions, size int) (*resourceInliner, error) {
 	baseURL, err := url.Parse(opts.BaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
+	client := newFetchClient(opts.Timeout)
+	// The timeout applies to each fetch once it has a worker, not to the
+	// wait for one.
+	client.Transport = limitedTransport{base: client.Transport, limiter: newFetchLimiter(opts.workers()), timeout: client.Timeout}
+	client.Timeout = 0
+	if opts.Cache != nil && opts.CacheTTL > 0 && len(opts.ExtraHeaders) == 0 {
+		// Outside the limiter, so cache hits don't wait for a worker, but
+		// inside the domain rules, so blocked hosts stay blocked.
+		client.Transport = cachingTransport{base: client.Transport, cache: opts.Cache, ttl: opts.CacheTTL}
+	}
+	if !opts.Domains.IsZero() {
+		client.Transport = domainRulesTransport{base: client.Transport, rules: opts.Domains}
+	}
+	if opts.Blocklist != nil {
+		client.Transport = blocklistTransport{base: client.Transport, blocklist: opts.Blocklist, pageURL: opts.BaseURL}
+	}
+	if opts.UserAgent != "" || len(opts.ExtraHeaders) > 0 {
+		client.Transport = headerTransport{base: client.Transport, userAgent: opts.UserAgent, headers: opts.ExtraHeaders}
+	}
+	fetched := &fetchBudget{}
+	fetched.read.Store(int64(size))
+	if opts.MaxTotalSize > 0 {
+		// Outermost, so cached resources count too.
+		client.Transport = totalSizeTransport{base: client.Transport, limit: opts.MaxTotalSize, fetched: fetched}
+	}
 	return &resourceInliner{
 		ctx:     ctx,
-		client:  &http.Client{Timeout: opts.Timeout},
+		client:  client,
 		baseURL: baseURL,
 		opts:    opts,
+		size:    int64(size),
+		fetched: fetched,
 	}, nil
 }
 
-// logFetchError logs fetch errors, filtering out common 404 errors.
+// errArchiveFull is returned for fetches refused because the archive has
+// reached InlineOptions.MaxTotalSize.
+var errArchiveFull = errors.New("archive size limit reached")
+
+// fetchBudget counts the bytes a resourceInliner has downloaded, and
+// records whether totalSizeTransport refused a fetch.
+type fetchBudget struct {
+	read    atomic.Int64
+	refused atomic.Bool
+}
+
+// totalSizeTransport refuses requests once the response bodies read through
+// it, plus the document itself, add up to limit bytes. Data URIs are larger
+// than what they encode, so this only saves downloads the archive couldn't
+// hold; resourceInliner.budgeted enforces the limit.
+type totalSizeTransport struct {
+	base    http.RoundTripper
+	limit   int64
+	fetched *fetchBudget
+}
+
+func (t totalSizeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.fetched.read.Load() >= t.limit {
+		t.fetched.refused.Store(true)
+		return nil, errArchiveFull
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = countingBody{ReadCloser: resp.Body, read: &t.fetched.read}
+	return resp, nil
+}
+
+// countingBody adds the bytes read from a response body to read.
+type countingBody struct {
+	io.ReadCloser
+	read *atomic.Int64
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Add(int64(n))
+	return n, err
+}
+
+// budgeted returns apply, which grows the document by n bytes, wrapped so
+// it only runs if the document stays within MaxTotalSize. The first change
+// that doesn't fit stops all later ones, so what's inlined is a prefix of
+// the document's resources.
+func (ri *resourceInliner) budgeted(n int, apply func()) func() {
+	if ri.opts.MaxTotalSize <= 0 {
+		return apply
+	}
+	return func() {
+		if ri.full || ri.size+int64(n) > ri.opts.MaxTotalSize {
+			ri.full = true
+			return
+		}
+		ri.size += int64(n)
+		apply()
+	}
+}
+
+// headerTransport sets a user agent and extra headers on every request,
+// redirect hops included.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   Headers
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// logFetchError logs fetch errors, filtering out common 404 errors and
+// resources blocked by InlineOptions.Domains or Blocklist.
 func (ri *resourceInliner) logFetchError(resourceType, url string, err error) {
-	if !strings.Contains(err.Error(), "HTTP 404") {
+	if !strings.Contains(err.Error(), "HTTP 404") && !errors.Is(err, ErrDomainBlocked) &&
+		!errors.Is(err, ErrResourceFiltered) && !errors.Is(err, errArchiveFull) {
 		log.Printf("Failed to fetch %s %s: %v", resourceType, url, err)
 	}
 }
 
-// inlineStylesheets converts external <link rel="stylesheet"> tags to inline <style> tags.
-func (ri *resourceInliner) inlineStylesheets(doc *goquery.Document) {
+// inlineTask fetches what one element needs, and returns a function that
+// applies it to the document, or nil if there is nothing to change.
+type inlineTask func() func()
+
+// run runs tasks on the worker pool, then applies their results in order,
+// so the output doesn't depend on which fetch finishes first. Tasks only
+// read the document; it is changed once they have all finished.
+func (ri *resourceInliner) run(tasks []inlineTask) {
+	applies := make([]func(), len(tasks))
+	workers, _ := ri.opts.workers()
+	forEach(len(tasks), workers, func(i int) {
+		applies[i] = tasks[i]()
+	})
+	for _, apply := range applies {
+		if apply != nil {
+			apply()
+		}
+	}
+}
+
+// forEach calls fn for every index below n on up to workers goroutines, and
+// returns once every call has.
+func forEach(n, workers int, fn func(i int)) {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// stylesheetTasks converts external <link rel="stylesheet"> tags to inline
+// <style> tags, and inlines the @import rules and url() references of the
+// page's own <style> tags.
+func (ri *resourceInliner) stylesheetTasks(doc *goquery.Document) []inlineTask {
+	var tasks []inlineTask
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		css := s.Text()
+		if !strings.Contains(css, "url(") && !strings.Contains(asciiLower(css), "@import") {
+			return
+		}
+		tasks = append(tasks, func() func() {
+			inlined := inlineCSSURLs(ri.ctx, ri.client, css, ri.baseURL.String(), ri.opts)
+			return ri.budgeted(len(inlined)-len(css), func() { s.SetText(inlined) })
+		})
+	})
+
 	doc.Find("link[rel='stylesheet']").Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists || href == "" {
@@ -140,22 +373,26 @@ func (ri *resourceInliner) inlineStylesheets(doc *goquery.Document) {
 			return
 		}
 
-		css, err := fetchResource(ri.ctx, ri.client, cssURL, ri.opts.MaxResourceSize)
-		if err != nil {
-			ri.logFetchError("CSS", cssURL, err)
-			return
-		}
+		tasks = append(tasks, func() func() {
+			css, err := fetchResource(ri.ctx, ri.client, cssURL, ri.opts.MaxResourceSize)
+			if err != nil {
+				ri.logFetchError("CSS", cssURL, err)
+				return nil
+			}
 
-		// Process CSS to inline any url() references
-		css = inlineCSSURLs(ri.ctx, ri.client, css, cssURL, ri.opts)
+			// Process CSS to inline any url() references
+			css = inlineCSSURLs(ri.ctx, ri.client, css, cssURL, ri.opts)
 
-		// Replace <link> with <style>
-		s.ReplaceWithHtml(fmt.Sprintf("<style>%s</style>", css))
+			// Replace <link> with <style>
+			return ri.budgeted(len(css), func() { s.ReplaceWithHtml(fmt.Sprintf("<style>%s</style>", css)) })
+		})
 	})
+	return tasks
 }
 
-// inlineScripts converts external <script src> tags to inline scripts.
-func (ri *resourceInliner) inlineScripts(doc *goquery.Document) {
+// scriptTasks converts external <script src> tags to inline scripts.
+func (ri *resourceInliner) scriptTasks(doc *goquery.Document) []inlineTask {
+	var tasks []inlineTask
 	doc.Find("script[src]").Each(func(i int, s *goquery.Selection) {
 		src, exists := s.Attr("src")
 		if !exists || src == "" {
@@ -167,20 +404,26 @@ func (ri *resourceInliner) inlineScripts(doc *goquery.Document) {
 			return
 		}
 
-		js, err := fetchResource(ri.ctx, ri.client, jsURL, ri.opts.MaxResourceSize)
-		if err != nil {
-			ri.logFetchError("JS", jsURL, err)
-			return
-		}
+		tasks = append(tasks, func() func() {
+			js, err := fetchResource(ri.ctx, ri.client, jsURL, ri.opts.MaxResourceSize)
+			if err != nil {
+				ri.logFetchError("JS", jsURL, err)
+				return nil
+			}
 
-		// Replace script with inline version
-		s.RemoveAttr("src")
-		s.SetText(js)
+			// Replace script with inline version
+			return ri.budgeted(len(js), func() {
+				s.RemoveAttr("src")
+				s.SetText(js)
+			})
+		})
 	})
+	return tasks
 }
 
-// inlineImages converts image src attributes to data URIs.
-func (ri *resourceInliner) inlineImages(doc *goquery.Document) {
+// imageTasks converts image src attributes to data URIs.
+func (ri *resourceInliner) imageTasks(doc *goquery.Document) []inlineTask {
+	var tasks []inlineTask
 	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
 		src, exists := s.Attr("src")
 		if !exists || src == "" {
@@ -197,30 +440,40 @@ func (ri *resourceInliner) inlineImages(doc *goquery.Document) {
 			return
 		}
 
-		dataURI, err := fetchAsDataURI(ri.ctx, ri.client, imgURL, ri.opts.MaxResourceSize)
-		if err != nil {
-			ri.logFetchError("image", imgURL, err)
-			return
-		}
-
-		s.SetAttr("src", dataURI)
+		tasks = append(tasks, func() func() {
+			dataURI, err := fetchAsDataURI(ri.ctx, ri.client, imgURL, ri.opts.MaxResourceSize)
+			if err != nil {
+				ri.logFetchError("image", imgURL, err)
+				return nil
+			}
+			return ri.budgeted(len(dataURI)-len(src), func() { s.SetAttr("src", dataURI) })
+		})
 	})
+	return tasks
+}
 
-	// Remove srcset attributes since they're complex and we've inlined src
+// removeSrcsets removes srcset attributes since they're complex and the
+// images' src has been inlined.
+func (ri *resourceInliner) removeSrcsets(doc *goquery.Document) {
 	doc.Find("img[srcset], source[srcset]").Each(func(i int, s *goquery.Selection) {
 		s.RemoveAttr("srcset")
 	})
 }
 
-// inlineBackgroundImages processes style attributes to inline CSS url() references.
-func (ri *resourceInliner) inlineBackgroundImages(doc *goquery.Document) {
+// backgroundImageTasks processes style attributes to inline CSS url() references.
+func (ri *resourceInliner) backgroundImageTasks(doc *goquery.Document) []inlineTask {
+	var tasks []inlineTask
 	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
 		style, _ := s.Attr("style")
-		if strings.Contains(style, "url(") {
-			newStyle := inlineCSSURLs(ri.ctx, ri.client, style, ri.opts.BaseURL, ri.opts)
-			s.SetAttr("style", newStyle)
+		if !strings.Contains(style, "url(") {
+			return
 		}
+		tasks = append(tasks, func() func() {
+			newStyle := inlineCSSURLs(ri.ctx, ri.client, style, ri.opts.BaseURL, ri.opts)
+			return ri.budgeted(len(newStyle)-len(style), func() { s.SetAttr("style", newStyle) })
+		})
 	})
+	return tasks
 }
 
 // addBaseTag adds a <base> tag for any remaining relative URLs that couldn't be inlined.
@@ -233,27 +486,39 @@ func (ri *resourceInliner) addBaseTag(doc *goquery.Document) {
 
 // InlineResources processes HTML and inlines external resources.
 // This makes the archived HTML self-contained and viewable offline.
+// Resources are fetched concurrently, up to opts.Workers at once and
+// opts.WorkersPerHost from one host, but the result is the same as fetching
+// them one by one.
 func InlineResources(ctx context.Context, html string, opts InlineOptions) (string, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	inliner, err := newResourceInliner(ctx, opts)
+	inliner, err := newResourceInliner(ctx, opts, len(html))
 	if err != nil {
 		return "", err
 	}
 
+	var tasks []inlineTask
 	if opts.InlineCSS {
-		inliner.inlineStylesheets(doc)
+		tasks = append(tasks, inliner.stylesheetTasks(doc)...)
 	}
 	if opts.InlineJS {
-		inliner.inlineScripts(doc)
+		tasks = append(tasks, inliner.scriptTasks(doc)...)
+	}
+	if opts.InlineImages {
+		tasks = append(tasks, inliner.imageTasks(doc)...)
+	}
+	tasks = append(tasks, inliner.backgroundImageTasks(doc)...)
+	tasks = append(tasks, inliner.embedTasks(doc)...)
+	inliner.run(tasks)
+	if (inliner.full || inliner.fetched.refused.Load()) && opts.frameDepth == 0 {
+		log.Printf("Stopped inlining %s at the %s archive size limit", opts.BaseURL, FormatBytes(opts.MaxTotalSize))
 	}
 	if opts.InlineImages {
-		inliner.inlineImages(doc)
+		inliner.removeSrcsets(doc)
 	}
-	inliner.inlineBackgroundImages(doc)
 	inliner.addBaseTag(doc)
 
 	result, err := doc.Html()
@@ -296,7 +561,7 @@ type fetchResult struct {
 func fetchURL(ctx context.Context, client *http.Client, urlStr string, maxSize int64) (*fetchResult, error) {
 	// SSRF protection: block requests to internal network addresses
 	if isInternalURL(urlStr) {
-		return nil, fmt.Errorf("blocked request to internal URL: %s", urlStr)
+		return nil, fmt.Errorf("blocked request to %w: %s", ErrInternalAddress, urlStr)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
@@ -354,83 +619,262 @@ func fetchAsDataURI(ctx context.Context, client *http.Client, urlStr string, max
 		return "", err
 	}
 
-	contentType := result.contentType
+	return result.dataURI(), nil
+}
+
+// dataURI encodes a fetched resource as a data URI.
+func (r *fetchResult) dataURI() string {
+	contentType := r.contentType
 	// Strip charset suffix for data URI
 	if idx := strings.Index(contentType, ";"); idx > 0 {
 		contentType = strings.TrimSpace(contentType[:idx])
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(result.data)
-	return fmt.Sprintf("data:%s;base64,%s", contentType, encoded), nil
+	encoded := base64.StdEncoding.EncodeToString(r.data)
+	return fmt.Sprintf("data:%s;base64,%s", contentType, encoded)
+}
+
+// cssInliner inlines the resources a stylesheet refers to.
+type cssInliner struct {
+	ctx    context.Context
+	client *http.Client
+	opts   InlineOptions
 }
 
-// inlineCSSURLs processes CSS and inlines any url() references.
+// inlineCSSURLs processes CSS and inlines its url() references and @import
+// rules. Each imported stylesheet replaces its rule, wrapped in @media if
+// the rule had a media list, after its own imports and URLs are inlined
+// against its URL, up to MaxCSSImportDepth deep. Font files in @font-face
+// rules are inlined only with opts.InlineFonts and up to opts.MaxFontSize;
+// otherwise they point at their absolute URL so the archive can still load
+// them. References are fetched concurrently, up to opts.Workers at once.
 func inlineCSSURLs(ctx context.Context, client *http.Client, css string, baseURLStr string, opts InlineOptions) string {
+	ci := &cssInliner{ctx: ctx, client: client, opts: opts}
+	return ci.inline(css, baseURLStr, 0, nil)
+}
+
+// inline inlines css fetched from baseURLStr, depth imports deep. importing
+// holds the stylesheets being imported, so an @import cycle is left alone
+// instead of followed.
+func (ci *cssInliner) inline(css string, baseURLStr string, depth int, importing map[string]bool) string {
 	baseURL, err := url.Parse(baseURLStr)
 	if err != nil {
 		return css
 	}
 
-	// Process url() patterns by building result incrementally
-	var result strings.Builder
-	remaining := css
-
+	lower := asciiLower(css)
+	fonts := fontFaceBlocks(lower)
+	// parts is css split into text kept as it is and references, whose
+	// replacements are filled in by refs once they are fetched.
+	var parts []string
+	var refs []func()
+	addRef := func(inline func() string) {
+		i := len(parts)
+		parts = append(parts, "")
+		refs = append(refs, func() { parts[i] = inline() })
+	}
+	pos := 0
 	for {
-		startIdx := strings.Index(remaining, "url(")
-		if startIdx == -1 {
-			result.WriteString(remaining)
+		urlIdx := strings.Index(lower[pos:], "url(")
+		importIdx := strings.Index(lower[pos:], "@import")
+		if urlIdx == -1 && importIdx == -1 {
+			parts = append(parts, css[pos:])
 			break
 		}
 
-		// Write everything before url(
-		result.WriteString(remaining[:startIdx])
+		if importIdx != -1 && (urlIdx == -1 || importIdx < urlIdx) {
+			start := pos + importIdx
+			end := len(css)
+			if semi := strings.IndexByte(css[start:], ';'); semi != -1 {
+				end = start + semi + 1
+			}
+			parts = append(parts, css[pos:start])
+			rule := css[start:end]
+			addRef(func() string { return ci.inlineImport(rule, baseURL, depth, importing) })
+			pos = end
+			continue
+		}
 
+		start := pos + urlIdx
 		// Find the closing parenthesis
-		afterURL := remaining[startIdx+4:]
-		endIdx := strings.Index(afterURL, ")")
-		if endIdx == -1 {
-			result.WriteString(remaining[startIdx:])
+		closeIdx := strings.IndexByte(css[start+4:], ')')
+		if closeIdx == -1 {
+			parts = append(parts, css[pos:])
 			break
 		}
+		end := start + 4 + closeIdx + 1
+		parts = append(parts, css[pos:start])
+		ref, font := css[start:end], inFontFace(fonts, start)
+		addRef(func() string { return ci.inlineURL(ref, baseURL, font) })
+		pos = end
+	}
 
-		urlContent := afterURL[:endIdx]
+	workers, _ := ci.opts.workers()
+	forEach(len(refs), workers, func(i int) {
+		refs[i]()
+	})
+	return strings.Join(parts, "")
+}
 
-		// Strip quotes
-		urlContent = strings.TrimSpace(urlContent)
-		urlContent = strings.Trim(urlContent, `"'`)
+// inlineURL returns the url() reference ref, from a stylesheet at baseURL,
+// with its resource inlined as a data URI. References it can't inline are
+// kept as they are, except fonts, which get their absolute URL.
+func (ci *cssInliner) inlineURL(ref string, baseURL *url.URL, font bool) string {
+	// Strip quotes
+	urlContent := strings.TrimSpace(ref[len("url(") : len(ref)-1])
+	urlContent = strings.Trim(urlContent, `"'`)
+
+	// Skip data URIs - keep them as-is
+	if strings.HasPrefix(urlContent, "data:") {
+		return ref
+	}
 
-		// Skip data URIs - keep them as-is
-		if strings.HasPrefix(urlContent, "data:") {
-			result.WriteString(remaining[startIdx : startIdx+4+endIdx+1])
-			remaining = remaining[startIdx+4+endIdx+1:]
-			continue
+	resolved := resolveURL(baseURL, urlContent)
+	if resolved == "" {
+		return ref
+	}
+	if !font {
+		dataURI, err := fetchAsDataURI(ci.ctx, ci.client, resolved, ci.opts.MaxResourceSize)
+		if err != nil {
+			logCSSFetchError(resolved, err)
+			return ref
 		}
+		return fmt.Sprintf("url(%s)", dataURI)
+	}
 
-		// Resolve and fetch
-		resolved := resolveURL(baseURL, urlContent)
-		if resolved == "" {
-			// Keep original
-			result.WriteString(remaining[startIdx : startIdx+4+endIdx+1])
-			remaining = remaining[startIdx+4+endIdx+1:]
-			continue
+	absolute := fmt.Sprintf("url(%q)", resolved)
+	if !ci.opts.InlineFonts {
+		return absolute
+	}
+	// Fetch one byte past the cap to tell a font that fits from one cut off.
+	maxSize := ci.opts.MaxFontSize
+	if maxSize > 0 {
+		maxSize++
+	}
+	res, err := fetchURL(ci.ctx, ci.client, resolved, maxSize)
+	if err != nil {
+		logCSSFetchError(resolved, err)
+		return absolute
+	}
+	if ci.opts.MaxFontSize > 0 && int64(len(res.data)) > ci.opts.MaxFontSize {
+		log.Printf("Not inlining font %s: larger than %d bytes", resolved, ci.opts.MaxFontSize)
+		return absolute
+	}
+	return fmt.Sprintf("url(%s)", res.dataURI())
+}
+
+// inlineImport replaces the @import rule, from a stylesheet at baseURL, with
+// the stylesheet it imports. Rules it can't follow (too deep, a cycle, a
+// failed fetch, or a layer() or supports() condition) are kept, with their
+// absolute URL.
+func (ci *cssInliner) inlineImport(rule string, baseURL *url.URL, depth int, importing map[string]bool) string {
+	body := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rule[len("@import"):]), ";"))
+	var target, media string
+	switch {
+	case strings.HasPrefix(asciiLower(body), "url("):
+		end := strings.IndexByte(body, ')')
+		if end == -1 {
+			return rule
 		}
+		target, media = body[len("url("):end], body[end+1:]
+		target = strings.Trim(strings.TrimSpace(target), `"'`)
+	case strings.HasPrefix(body, `"`) || strings.HasPrefix(body, "'"):
+		end := strings.IndexByte(body[1:], body[0])
+		if end == -1 {
+			return rule
+		}
+		target, media = body[1:end+1], body[end+2:]
+	default:
+		return rule
+	}
+	media = strings.TrimSpace(media)
 
-		dataURI, err := fetchAsDataURI(ctx, client, resolved, opts.MaxResourceSize)
-		if err != nil {
-			// Only log non-404 errors (404s are common for deleted/moved resources)
-			if !strings.Contains(err.Error(), "HTTP 404") {
-				log.Printf("Failed to fetch CSS resource %s: %v", resolved, err)
-			}
-			// Keep original URL
-			result.WriteString(remaining[startIdx : startIdx+4+endIdx+1])
-			remaining = remaining[startIdx+4+endIdx+1:]
-			continue
+	resolved := resolveURL(baseURL, target)
+	if resolved == "" {
+		return rule
+	}
+	kept := fmt.Sprintf("@import url(%q)", resolved)
+	if media != "" {
+		kept += " " + media
+	}
+	kept += ";"
+	lowerMedia := asciiLower(media)
+	if depth >= MaxCSSImportDepth || importing[resolved] ||
+		strings.Contains(lowerMedia, "layer") || strings.Contains(lowerMedia, "supports(") {
+		return kept
+	}
+
+	css, err := fetchResource(ci.ctx, ci.client, resolved, ci.opts.MaxResourceSize)
+	if err != nil {
+		logCSSFetchError(resolved, err)
+		return kept
+	}
+	// An imported stylesheet's @charset only applies at its start.
+	if trimmed := strings.TrimSpace(css); strings.HasPrefix(asciiLower(trimmed), "@charset") {
+		if semi := strings.IndexByte(trimmed, ';'); semi != -1 {
+			css = trimmed[semi+1:]
+		}
+	}
+
+	nested := maps.Clone(importing)
+	if nested == nil {
+		nested = map[string]bool{}
+	}
+	nested[resolved] = true
+	css = ci.inline(css, resolved, depth+1, nested)
+	if media != "" {
+		return fmt.Sprintf("@media %s {\n%s\n}", media, css)
+	}
+	return css
+}
+
+// logCSSFetchError logs a failed fetch of a stylesheet's resource. Only
+// non-404 errors are logged (404s are common for deleted/moved resources).
+func logCSSFetchError(url string, err error) {
+	if !strings.Contains(err.Error(), "HTTP 404") && !errors.Is(err, ErrDomainBlocked) && !errors.Is(err, ErrResourceFiltered) {
+		log.Printf("Failed to fetch CSS resource %s: %v", url, err)
+	}
+}
+
+// fontFaceBlocks returns the start and end offsets of the @font-face rules
+// in css, which must already be lowercased.
+func fontFaceBlocks(css string) [][2]int {
+	var blocks [][2]int
+	pos := 0
+	for {
+		start := strings.Index(css[pos:], "@font-face")
+		if start == -1 {
+			return blocks
+		}
+		start += pos
+		end := strings.IndexByte(css[start:], '}')
+		if end == -1 {
+			return append(blocks, [2]int{start, len(css)})
 		}
+		end += start + 1
+		blocks = append(blocks, [2]int{start, end})
+		pos = end
+	}
+}
 
-		// Write the inlined url()
-		result.WriteString(fmt.Sprintf("url(%s)", dataURI))
-		remaining = remaining[startIdx+4+endIdx+1:]
+// inFontFace reports whether offset falls inside one of blocks.
+func inFontFace(blocks [][2]int, offset int) bool {
+	for _, b := range blocks {
+		if offset >= b[0] && offset < b[1] {
+			return true
+		}
 	}
+	return false
+}
 
-	return result.String()
+// asciiLower lowercases the ASCII letters of s, leaving every other byte,
+// so offsets into the result are offsets into s.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return string(b)
 }
@@ -2,11 +2,15 @@ package core
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -412,6 +416,36 @@ func TestInlineResources(t *testing.T) {
 	})
 }
 
+func TestInlineResourcesHeaders(t *testing.T) {
+	var agent, token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent, token = r.UserAgent(), r.Header.Get("X-Token")
+		w.Header().Set("Content-Type", "text/css")
+		if _, err := w.Write([]byte("p {}")); err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	html := `<html><head><link rel="stylesheet" href="/style.css"></head><body></body></html>`
+	if _, err := InlineResources(context.Background(), html, DefaultInlineOptions(ts.URL)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agent != UserAgent || token != "" {
+		t.Errorf("expected the default user agent and no extra headers, got %q, %q", agent, token)
+	}
+
+	opts := DefaultInlineOptions(ts.URL)
+	opts.UserAgent = "Mozilla/5.0 Firefox/130.0"
+	opts.ExtraHeaders = Headers{"X-Token": "abc"}
+	if _, err := InlineResources(context.Background(), html, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agent != opts.UserAgent || token != "abc" {
+		t.Errorf("expected the custom user agent and headers, got %q, %q", agent, token)
+	}
+}
+
 func TestInlineCSSURLs(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "image/png")
@@ -466,6 +500,156 @@ func TestInlineCSSURLs(t *testing.T) {
 	}
 }
 
+func TestInlineCSSImportsAndFonts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.Path {
+		case "/main.css":
+			w.Header().Set("Content-Type", "text/css")
+			body = `@import url("sub/print.css") print; @IMPORT "loop.css"; body { color: red; }`
+		case "/sub/print.css":
+			w.Header().Set("Content-Type", "text/css")
+			body = `@charset "utf-8"; .a { background: url(bg.png); }`
+		case "/loop.css":
+			w.Header().Set("Content-Type", "text/css")
+			body = `@import "loop.css"; .loop { color: blue; }`
+		case "/sub/bg.png":
+			w.Header().Set("Content-Type", "image/png")
+			body = "\x89PNG"
+		case "/fonts.css":
+			w.Header().Set("Content-Type", "text/css")
+			body = `@font-face { font-family: X; src: url(small.woff2) format("woff2"), url(big.woff2); } .x { background: url(sub/bg.png); }`
+		case "/small.woff2":
+			w.Header().Set("Content-Type", "font/woff2")
+			body = "wOF2small"
+		case "/big.woff2":
+			w.Header().Set("Content-Type", "font/woff2")
+			body = "wOF2" + strings.Repeat("x", 100)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	opts := DefaultInlineOptions(ts.URL)
+	opts.MaxFontSize = 50
+
+	t.Run("imports", func(t *testing.T) {
+		result := inlineCSSURLs(context.Background(), client, `@import "main.css";`, ts.URL+"/", opts)
+		for _, want := range []string{
+			"@media print {",
+			".a { background: url(data:image/png;base64,",
+			".loop { color: blue; }",
+			`@import url("` + ts.URL + `/loop.css");`,
+			"body { color: red; }",
+		} {
+			if !strings.Contains(result, want) {
+				t.Errorf("result should contain %q, got %q", want, result)
+			}
+		}
+		if strings.Contains(result, "@charset") || strings.Contains(result, "main.css") {
+			t.Errorf("expected imported rules to be replaced, got %q", result)
+		}
+	})
+
+	t.Run("fonts", func(t *testing.T) {
+		result := inlineCSSURLs(context.Background(), client, `@import "fonts.css";`, ts.URL+"/", opts)
+		if !strings.Contains(result, "url(data:font/woff2;base64,") {
+			t.Errorf("expected the small font to be inlined, got %q", result)
+		}
+		if !strings.Contains(result, `url("`+ts.URL+`/big.woff2")`) {
+			t.Errorf("expected the big font to keep its absolute URL, got %q", result)
+		}
+
+		noFonts := opts
+		noFonts.InlineFonts = false
+		result = inlineCSSURLs(context.Background(), client, `@import "fonts.css";`, ts.URL+"/", noFonts)
+		if !strings.Contains(result, `url("`+ts.URL+`/small.woff2")`) || !strings.Contains(result, ".x { background: url(data:image/png;base64,") {
+			t.Errorf("expected fonts to keep absolute URLs and images to be inlined, got %q", result)
+		}
+	})
+
+	t.Run("style tags", func(t *testing.T) {
+		html := `<html><head><style>@import "main.css";</style></head><body></body></html>`
+		result, err := InlineResources(context.Background(), html, opts)
+		if err != nil {
+			t.Fatalf("InlineResources() error = %v", err)
+		}
+		if !strings.Contains(result, "body { color: red; }") {
+			t.Errorf("expected the <style> import to be inlined, got %q", result)
+		}
+	})
+}
+
+func TestInlineResourcesConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	hostInFlight, maxHostInFlight := map[string]int{}, 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		hostInFlight[r.Host]++
+		maxInFlight = max(maxInFlight, inFlight)
+		maxHostInFlight = max(maxHostInFlight, hostInFlight[r.Host])
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		hostInFlight[r.Host]--
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "image/png")
+		if _, err := w.Write([]byte(r.Host + r.URL.Path)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	page := httptest.NewServer(handler)
+	defer page.Close()
+	cdn := httptest.NewServer(handler)
+	defer cdn.Close()
+
+	var html strings.Builder
+	var want []string
+	html.WriteString(`<html><head></head><body>`)
+	for i := range 12 {
+		server := page
+		if i%2 == 1 {
+			server = cdn
+		}
+		src := fmt.Sprintf("%s/img%d.png", server.URL, i)
+		fmt.Fprintf(&html, `<img src="%s">`, src)
+		want = append(want, base64.StdEncoding.EncodeToString([]byte(strings.TrimPrefix(src, "http://"))))
+	}
+	html.WriteString(`</body></html>`)
+
+	opts := DefaultInlineOptions(page.URL)
+	opts.Workers = 3
+	opts.WorkersPerHost = 2
+	result, err := InlineResources(context.Background(), html.String(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Each image keeps its own place, whichever finished first.
+	pos := 0
+	for i, encoded := range want {
+		idx := strings.Index(result[pos:], encoded)
+		if idx == -1 {
+			t.Fatalf("image %d missing or out of order in %s", i, result)
+		}
+		pos += idx + len(encoded)
+	}
+	if maxInFlight < 2 || maxInFlight > opts.Workers {
+		t.Errorf("expected between 2 and %d fetches at once, got %d", opts.Workers, maxInFlight)
+	}
+	if maxHostInFlight > opts.WorkersPerHost {
+		t.Errorf("expected at most %d fetches per host, got %d", opts.WorkersPerHost, maxHostInFlight)
+	}
+}
+
 func TestDefaultInlineOptions(t *testing.T) {
 	opts := DefaultInlineOptions("https://example.com")
 
@@ -487,6 +671,12 @@ func TestDefaultInlineOptions(t *testing.T) {
 	if !opts.InlineImages {
 		t.Error("InlineImages should be true by default")
 	}
+	if !opts.InlineFonts || opts.MaxFontSize != MaxFontSize {
+		t.Errorf("InlineFonts = %v, MaxFontSize = %d; want fonts inlined up to %d", opts.InlineFonts, opts.MaxFontSize, MaxFontSize)
+	}
+	if opts.Workers != DefaultInlineWorkers || opts.WorkersPerHost != DefaultInlineWorkersPerHost {
+		t.Errorf("Workers = %d, WorkersPerHost = %d; want %d and %d", opts.Workers, opts.WorkersPerHost, DefaultInlineWorkers, DefaultInlineWorkersPerHost)
+	}
 }
 
 func TestInvalidBaseURL(t *testing.T) {
@@ -555,6 +745,20 @@ func TestIsInternalURL(t *testing.T) {
 		// Unspecified (should be blocked)
 		{"unspecified ipv4", "http://0.0.0.0/api", true},
 
+		// Other special-use ranges (should be blocked)
+		{"this network", "http://0.1.2.3/api", true},
+		{"carrier-grade nat", "http://100.64.0.1/api", true},
+		{"carrier-grade nat end", "http://100.127.255.254/api", true},
+		{"ietf protocol assignments", "http://192.0.0.8/api", true},
+		{"documentation", "http://192.0.2.1/api", true},
+		{"benchmarking", "http://198.18.0.1/api", true},
+		{"benchmarking end", "http://198.19.255.254/api", true},
+		{"reserved", "http://240.0.0.1/api", true},
+		{"broadcast", "http://255.255.255.255/api", true},
+		{"ipv4-mapped carrier-grade nat", "http://[::ffff:100.64.0.1]/api", true},
+		{"just outside carrier-grade nat", "http://100.128.0.1/api", false},
+		{"just outside benchmarking", "http://198.20.0.1/api", false},
+
 		// Empty/invalid (should be blocked - fail safe)
 		{"empty host", "http:///path", true},
 		{"no host", "/relative/path", true},
@@ -607,3 +811,43 @@ func TestSSRFProtection(t *testing.T) {
 		}
 	})
 }
+
+func TestInlineResourcesMaxTotalSize(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	var html strings.Builder
+	html.WriteString(`<html><head></head><body>`)
+	for i := range 4 {
+		fmt.Fprintf(&html, `<img src="%s/img%d.png">`, server.URL, i)
+	}
+	html.WriteString(`</body></html>`)
+
+	opts := DefaultInlineOptions(server.URL + "/")
+	opts.Workers = 1
+	// Room for three downloads, but only two images once base64-encoded.
+	opts.MaxTotalSize = int64(html.Len()) + 3000
+	got, err := InlineResources(context.Background(), html.String(), opts)
+	if err != nil {
+		t.Fatalf("InlineResources() error = %v", err)
+	}
+	if n := strings.Count(got, "data:image/png;base64,"); n != 2 {
+		t.Errorf("expected 2 images inlined, got %d", n)
+	}
+	for _, name := range []string{"img2.png", "img3.png"} {
+		if !strings.Contains(got, server.URL+"/"+name) {
+			t.Errorf("expected %s to keep its URL", name)
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("expected downloads to stop at the limit, got %d requests", n)
+	}
+	if int64(len(got)) > opts.MaxTotalSize+200 {
+		t.Errorf("expected the page to stay near %d bytes, got %d", opts.MaxTotalSize, len(got))
+	}
+}
@@ -0,0 +1,550 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ArchiveQueueOptions configures an ArchiveQueue. Zero values fall back to
+// the Default* constants.
+type ArchiveQueueOptions struct {
+	Workers     int
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles with each
+	// further attempt up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// RetryPolicy overrides MaxAttempts and BaseBackoff per archive error
+	// code.
+	RetryPolicy ArchiveRetryPolicy
+	// PollInterval is how often idle workers check for jobs that have become
+	// due. Newly enqueued jobs wake a worker immediately.
+	PollInterval time.Duration
+	// MaxQueued is how many jobs may wait for a worker before new ones are
+	// deferred: still recorded, but queued only once the backlog has
+	// drained.
+	MaxQueued  int
+	QuietHours QuietHours
+	Archive    ArchiveOptions
+	// RearchiveAfter re-archives bookmarks whose latest snapshot is older
+	// than this, adding a new version. Zero disables re-archiving.
+	RearchiveAfter time.Duration
+	// RearchiveInterval is how often to look for stale archives.
+	RearchiveInterval time.Duration
+	// Defaults are the instance archive settings (DefaultArchiveSettings if
+	// nil); each user's stored preferences override them when their
+	// bookmarks are enqueued.
+	Defaults *ArchiveSettings
+	// HostDelay is how long a host rests after one of its pages is
+	// archived before the next is started. Each host is archived by one
+	// worker at a time regardless; zero means no rest.
+	HostDelay time.Duration
+}
+
+// ArchiveQueue runs archive jobs from the persistent jobs table, so queued
+// work survives restarts and failed archives are retried with exponential
+// backoff.
+type ArchiveQueue struct {
+	db       *db.DB
+	opts     ArchiveQueueOptions
+	defaults ArchiveSettings
+	wake     chan struct{}
+	// resumeMu keeps idle workers from resuming deferred jobs at once and
+	// overfilling the queue.
+	resumeMu sync.Mutex
+	// hostsMu guards hosts, the hosts (see db.JobHost) being archived or
+	// resting: each maps to when it may be archived again, zero while a
+	// worker has it. Workers claim jobs while holding it, so no two pick
+	// the same host.
+	hostsMu sync.Mutex
+	hosts   map[string]time.Time
+	// archive is ArchiveAndPersist, replaceable in tests.
+	archive func(ctx context.Context, database *db.DB, b db.Bookmark, opts ArchiveOptions) error
+}
+
+// NewArchiveQueue creates an archive queue backed by database.
+func NewArchiveQueue(database *db.DB, opts ArchiveQueueOptions) *ArchiveQueue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultJobMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultJobBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultJobMaxBackoff
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultJobPollInterval
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = DefaultMaxQueuedJobs
+	}
+	if opts.RearchiveInterval <= 0 {
+		opts.RearchiveInterval = DefaultRearchiveInterval
+	}
+	opts.HostDelay = max(opts.HostDelay, 0)
+	defaults := DefaultArchiveSettings()
+	if opts.Defaults != nil {
+		defaults = *opts.Defaults
+	}
+	return &ArchiveQueue{
+		db:       database,
+		opts:     opts,
+		defaults: defaults,
+		wake:     make(chan struct{}, 1),
+		hosts:    map[string]time.Time{},
+		archive:  ArchiveAndPersist,
+	}
+}
+
+// Enqueue queues a bookmark for archiving with its owner's archive settings
+// and wakes an idle worker, or defers it if MaxQueued jobs are already
+// waiting. reason is only used for logging.
+func (q *ArchiveQueue) Enqueue(bookmarkID int64, reason string) error {
+	settings, err := q.settings(bookmarkID)
+	if err != nil {
+		return err
+	}
+	return q.enqueue(bookmarkID, reason, settings)
+}
+
+// EnqueueNew queues a newly saved bookmark, unless its owner has turned
+// auto-archiving off. overrides are the archive options it was saved with,
+// and take precedence over its owner's preferences for this first capture.
+func (q *ArchiveQueue) EnqueueNew(bookmarkID int64, overrides db.ArchiveOverrides) error {
+	settings, err := q.settings(bookmarkID)
+	if err != nil {
+		return err
+	}
+	settings = settings.OverrideBookmark(overrides)
+	if !settings.AutoArchive {
+		log.Printf("Auto-archive is off, not queuing new bookmark %d", bookmarkID)
+		return nil
+	}
+	return q.enqueue(bookmarkID, "archiving (new)", settings)
+}
+
+func (q *ArchiveQueue) enqueue(bookmarkID int64, reason string, settings ArchiveSettings) error {
+	options, err := encodeArchiveSettings(settings)
+	if err != nil {
+		return err
+	}
+	waiting, err := q.db.CountQueuedJobs(db.JobKindArchive, time.Now())
+	if err != nil {
+		return err
+	}
+	if waiting >= q.opts.MaxQueued {
+		deferred, err := q.db.DeferJob(db.JobKindArchive, bookmarkID, options)
+		if err != nil {
+			return err
+		}
+		if deferred {
+			log.Printf("Archive queue is full (%d waiting), deferring bookmark %d for %s", waiting, bookmarkID, reason)
+		} else {
+			log.Printf("Bookmark %d already queued, not queuing again for %s", bookmarkID, reason)
+		}
+		return nil
+	}
+	queued, err := q.db.EnqueueJob(db.JobKindArchive, bookmarkID, options)
+	if err != nil {
+		return err
+	}
+	if queued {
+		log.Printf("Queued bookmark %d for %s", bookmarkID, reason)
+	} else {
+		log.Printf("Bookmark %d already queued, not queuing again for %s", bookmarkID, reason)
+	}
+	q.notify()
+	return nil
+}
+
+// settings resolves the archive settings for a bookmark from its owner's
+// preferences.
+func (q *ArchiveQueue) settings(bookmarkID int64) (ArchiveSettings, error) {
+	owner, err := q.db.BookmarkOwner(bookmarkID)
+	if err != nil {
+		return q.defaults, err
+	}
+	return ResolveArchiveSettings(q.db, owner, q.defaults)
+}
+
+func (q *ArchiveQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// resumeDeferred queues deferred jobs while there is room for them, and
+// reports whether it queued any.
+func (q *ArchiveQueue) resumeDeferred() (bool, error) {
+	q.resumeMu.Lock()
+	defer q.resumeMu.Unlock()
+	waiting, err := q.db.CountQueuedJobs(db.JobKindArchive, time.Now())
+	if err != nil {
+		return false, err
+	}
+	n, err := q.db.ResumeDeferredJobs(db.JobKindArchive, q.opts.MaxQueued-waiting)
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		log.Printf("Queued %d deferred archive job(s)", n)
+	}
+	return n > 0, nil
+}
+
+// Run recovers jobs interrupted by a previous shutdown, queues any bookmarks
+// that were never archived, then processes jobs with the configured number of
+// workers until ctx is cancelled. If RearchiveAfter is set it also queues
+// stale archives every RearchiveInterval.
+func (q *ArchiveQueue) Run(ctx context.Context) error {
+	if n, err := q.db.RequeueRunningJobs(); err != nil {
+		return err
+	} else if n > 0 {
+		log.Printf("Requeued %d archive job(s) interrupted by the last shutdown", n)
+	}
+	users, err := q.db.ListUsers()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		settings, err := ResolveArchiveSettings(q.db, u.ID, q.defaults)
+		if err != nil {
+			return err
+		}
+		if !settings.AutoArchive {
+			continue
+		}
+		if n, err := q.db.ForUser(u.ID).EnqueueUnarchivedBookmarks(); err != nil {
+			return err
+		} else if n > 0 {
+			log.Printf("Queued %d existing unarchived bookmark(s) of %s", n, u.Username)
+		}
+	}
+
+	var wg sync.WaitGroup
+	if q.opts.RearchiveAfter > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.rearchiveLoop(ctx)
+		}()
+	}
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			q.worker(ctx, workerID)
+		}(i)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// EnqueueStale queues re-archive jobs for bookmarks whose latest snapshot is
+// older than RearchiveAfter at now.
+func (q *ArchiveQueue) EnqueueStale(now time.Time) (int64, error) {
+	n, err := q.db.EnqueueStaleArchives(now.Add(-q.opts.RearchiveAfter))
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		log.Printf("Queued %d bookmark(s) archived more than %s ago for re-archiving", n, q.opts.RearchiveAfter)
+		q.notify()
+	}
+	return n, nil
+}
+
+// rearchiveLoop periodically queues stale archives, pausing during quiet hours.
+func (q *ArchiveQueue) rearchiveLoop(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.RearchiveInterval)
+	defer ticker.Stop()
+	for {
+		if err := q.opts.QuietHours.Wait(ctx, "re-archiving"); err != nil {
+			return
+		}
+		if _, err := q.EnqueueStale(time.Now()); err != nil {
+			log.Printf("Re-archiving: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// worker claims and runs due jobs, sleeping until woken or the next poll when
+// the queue is empty.
+func (q *ArchiveQueue) worker(ctx context.Context, workerID int) {
+	log.Printf("Archive worker %d started", workerID)
+	defer log.Printf("Archive worker %d stopped", workerID)
+
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Don't claim anything during quiet hours, so jobs aren't held as
+		// running while we wait.
+		if err := q.opts.QuietHours.Wait(ctx, fmt.Sprintf("archive worker %d", workerID)); err != nil {
+			return
+		}
+
+		ran, err := q.runNext(ctx, workerID)
+		if err != nil {
+			log.Printf("Worker %d: %v", workerID, err)
+		}
+		if ran {
+			continue
+		}
+		// The queue has drained; make room for anything deferred while it
+		// was full.
+		if resumed, err := q.resumeDeferred(); err != nil {
+			log.Printf("Worker %d: %v", workerID, err)
+		} else if resumed {
+			continue
+		}
+
+		// Jobs may be waiting on a resting host.
+		var hostReady <-chan time.Time
+		if d, ok := q.nextHostReady(time.Now()); ok {
+			hostReady = time.After(d)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		case <-hostReady:
+		}
+	}
+}
+
+// claim claims the next due job whose host no other worker has and that
+// isn't resting, and takes its host. A bookmark that is gone is returned
+// with its error, for the job to be failed.
+func (q *ArchiveQueue) claim(now time.Time) (db.Job, db.Bookmark, string, error) {
+	q.hostsMu.Lock()
+	defer q.hostsMu.Unlock()
+	var busy []string
+	for host, until := range q.hosts {
+		if until.IsZero() || now.Before(until) {
+			busy = append(busy, host)
+		} else {
+			delete(q.hosts, host)
+		}
+	}
+	job, err := q.db.ClaimJob(db.JobKindArchive, now, busy...)
+	if err != nil {
+		return job, db.Bookmark{}, "", err
+	}
+	bookmark, err := q.db.GetBookmark(job.BookmarkID)
+	if err != nil {
+		return job, bookmark, "", err
+	}
+	host := db.JobHost(bookmark.URL)
+	if host != "" {
+		q.hosts[host] = time.Time{}
+	}
+	return job, bookmark, host, nil
+}
+
+// releaseHost lets host rest for HostDelay, then be archived again.
+func (q *ArchiveQueue) releaseHost(host string) {
+	if host == "" {
+		return
+	}
+	q.hostsMu.Lock()
+	if q.opts.HostDelay > 0 {
+		q.hosts[host] = time.Now().Add(q.opts.HostDelay)
+	} else {
+		delete(q.hosts, host)
+	}
+	q.hostsMu.Unlock()
+	// Jobs for host may have been passed over while it was busy.
+	q.notify()
+}
+
+// nextHostReady returns how long until the first resting host may be
+// archived again, if any is resting.
+func (q *ArchiveQueue) nextHostReady(now time.Time) (time.Duration, bool) {
+	q.hostsMu.Lock()
+	defer q.hostsMu.Unlock()
+	var next time.Duration
+	found := false
+	for _, until := range q.hosts {
+		if until.IsZero() {
+			continue
+		}
+		if d := max(until.Sub(now), 0); !found || d < next {
+			next, found = d, true
+		}
+	}
+	return next, found
+}
+
+// runNext claims and runs a single due job. It reports whether a job was run.
+func (q *ArchiveQueue) runNext(ctx context.Context, workerID int) (bool, error) {
+	job, bookmark, host, err := q.claim(time.Now())
+	if errors.Is(err, db.ErrNoJobReady) {
+		return false, nil
+	}
+	if job.ID == 0 {
+		// Nothing was claimed; otherwise err is the job's missing bookmark.
+		return false, err
+	}
+	// Another job may be due; let a sleeping worker pick it up.
+	q.notify()
+	if err != nil {
+		// The bookmark is gone; there is nothing to retry.
+		return true, q.db.FailJob(job.ID, err.Error())
+	}
+	defer q.releaseHost(host)
+
+	// Jobs carry the settings resolved when they were enqueued; jobs seeded
+	// in bulk resolve them now.
+	var settings ArchiveSettings
+	if job.Options != "" {
+		settings, err = decodeArchiveSettings(job.Options)
+	} else {
+		settings, err = q.settings(job.BookmarkID)
+	}
+	if err != nil {
+		return true, q.db.FailJob(job.ID, err.Error())
+	}
+
+	log.Printf("Worker %d archiving bookmark %d (attempt %d): %s",
+		workerID, bookmark.ID, job.Attempts, bookmark.URL)
+	started := time.Now()
+	archiveErr := q.archive(ctx, q.db, bookmark, settings.Apply(q.opts.Archive))
+	if archiveErr == nil {
+		log.Printf("Worker %d: Successfully archived bookmark %d", workerID, bookmark.ID)
+		q.recordAttempt(job, started, ArchiveStatusOK, nil, time.Time{})
+		return true, q.db.CompleteJob(job.ID)
+	}
+
+	if errors.Is(archiveErr, ErrArchiveDisallowed) {
+		// Not a failure: the bookmark is recorded as skipped.
+		log.Printf("Worker %d: Skipped id=%d url=%s: %v", workerID, bookmark.ID, bookmark.URL, archiveErr)
+		q.recordAttempt(job, started, ArchiveStatusSkipped, archiveErr, time.Time{})
+		return true, q.db.CompleteJob(job.ID)
+	}
+	rule := q.opts.RetryPolicy.rule(archiveErr, q.opts.MaxAttempts, q.opts.BaseBackoff)
+	if job.Attempts >= rule.MaxAttempts {
+		if rule.MaxAttempts <= 1 {
+			// Blocked pages and client errors won't change between attempts.
+			log.Printf("Worker %d: Not retrying id=%d url=%s (%s): %v",
+				workerID, bookmark.ID, bookmark.URL, ClassifyArchiveError(archiveErr), archiveErr)
+		} else {
+			log.Printf("Worker %d: Archive failed for id=%d url=%s after %d attempt(s), giving up: %v",
+				workerID, bookmark.ID, bookmark.URL, job.Attempts, archiveErr)
+		}
+		q.recordAttempt(job, started, ArchiveStatusError, archiveErr, time.Time{})
+		return true, q.db.FailJob(job.ID, archiveErr.Error())
+	}
+
+	delay := jobBackoff(job.Attempts, rule.Interval, q.opts.MaxBackoff)
+	next := time.Now().Add(delay)
+	log.Printf("Worker %d: Archive failed for id=%d url=%s (%s), retrying in %s: %v",
+		workerID, bookmark.ID, bookmark.URL, ClassifyArchiveError(archiveErr), delay, archiveErr)
+	q.recordAttempt(job, started, ArchiveStatusError, archiveErr, next)
+	return true, q.db.RetryJob(job.ID, archiveErr.Error(), next)
+}
+
+// recordAttempt adds a finished run of job to its bookmark's attempt
+// history, with next set if the job will be retried. The history is only
+// informational, so failing to record it is logged rather than failing the
+// job.
+func (q *ArchiveQueue) recordAttempt(job db.Job, started time.Time, status string, archiveErr error, next time.Time) {
+	a := db.ArchiveAttempt{
+		BookmarkID: job.BookmarkID,
+		JobID:      job.ID,
+		Attempt:    job.Attempts,
+		StartedAt:  started.UTC().Format(time.RFC3339),
+		FinishedAt: time.Now().UTC().Format(time.RFC3339),
+		Status:     status,
+	}
+	if archiveErr != nil {
+		a.Error = archiveErr.Error()
+		a.ErrorCode = ClassifyArchiveError(archiveErr)
+	}
+	if !next.IsZero() {
+		a.NextAttemptAt = next.UTC().Format(time.RFC3339)
+	}
+	if _, err := q.db.RecordArchiveAttempt(a); err != nil {
+		log.Printf("Failed to record archive attempt for bookmark %d: %v", job.BookmarkID, err)
+	}
+}
+
+// ArchiveETA is where a bookmark's pending archive stands in the queue.
+type ArchiveETA struct {
+	// Status is the archive job's db.JobStatus*: queued, running or
+	// deferred. It's empty if no archive is pending.
+	Status string
+	// Ahead is how many jobs will run before it.
+	Ahead int
+	// Wait is the estimated time until it's archived.
+	Wait time.Duration
+}
+
+// EstimateArchive estimates when a bookmark's pending archive will be done
+// with the given number of workers, from the average archive duration of
+// database's user's bookmarks.
+func EstimateArchive(database *db.DB, bookmarkID int64, workers int) (ArchiveETA, error) {
+	job, err := database.GetPendingJob(db.JobKindArchive, bookmarkID)
+	if errors.Is(err, db.ErrNoPendingJob) {
+		return ArchiveETA{}, nil
+	}
+	if err != nil {
+		return ArchiveETA{}, err
+	}
+	avg, err := database.AverageArchiveSeconds()
+	if err != nil {
+		return ArchiveETA{}, err
+	}
+	eta := ArchiveETA{Status: job.Status, Ahead: job.Ahead}
+	if job.Status == db.JobStatusRunning {
+		eta.Wait = EstimateQueueWait(1, workers, avg)
+	} else {
+		eta.Wait = EstimateQueueWait(job.Ahead+1, workers, avg)
+	}
+	return eta, nil
+}
+
+// EstimateQueueWait estimates how long the given number of workers take to
+// run jobs archive jobs, if each takes avgSeconds. Until anything has been
+// archived, a job is assumed to take DefaultArchiveTimeout.
+func EstimateQueueWait(jobs, workers int, avgSeconds float64) time.Duration {
+	avg := time.Duration(avgSeconds * float64(time.Second))
+	if avg <= 0 {
+		avg = DefaultArchiveTimeout
+	}
+	workers = max(workers, 1)
+	rounds := (jobs + workers - 1) / workers
+	return time.Duration(rounds) * avg
+}
+
+// jobBackoff returns the delay after the given (1-based) failed attempt:
+// base, 2*base, 4*base, ... capped at max.
+func jobBackoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,613 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// newQueueTestDB returns a migrated file-backed database. Queue workers use
+// several connections, which ":memory:" databases don't share.
+func newQueueTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.NewSQLiteDB(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := database.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	return database
+}
+
+func TestJobBackoff(t *testing.T) {
+	base, max := time.Minute, 10*time.Minute
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := jobBackoff(tt.attempt, base, max); got != tt.want {
+			t.Errorf("jobBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestNewArchiveQueue_Defaults(t *testing.T) {
+	q := NewArchiveQueue(nil, ArchiveQueueOptions{})
+	if q.opts.Workers != 1 {
+		t.Errorf("Workers = %d, want 1", q.opts.Workers)
+	}
+	if q.opts.MaxAttempts != DefaultJobMaxAttempts {
+		t.Errorf("MaxAttempts = %d, want %d", q.opts.MaxAttempts, DefaultJobMaxAttempts)
+	}
+	if q.opts.BaseBackoff != DefaultJobBaseBackoff || q.opts.MaxBackoff != DefaultJobMaxBackoff {
+		t.Errorf("unexpected backoff defaults: %s, %s", q.opts.BaseBackoff, q.opts.MaxBackoff)
+	}
+	if q.opts.PollInterval != DefaultJobPollInterval {
+		t.Errorf("PollInterval = %s, want %s", q.opts.PollInterval, DefaultJobPollInterval)
+	}
+	if q.opts.RearchiveInterval != DefaultRearchiveInterval {
+		t.Errorf("RearchiveInterval = %s, want %s", q.opts.RearchiveInterval, DefaultRearchiveInterval)
+	}
+	if q.defaults != DefaultArchiveSettings() {
+		t.Errorf("defaults = %+v, want %+v", q.defaults, DefaultArchiveSettings())
+	}
+}
+
+func TestArchiveQueue_RunNext(t *testing.T) {
+	database := newQueueTestDB(t)
+	q := NewArchiveQueue(database, ArchiveQueueOptions{MaxAttempts: 2, BaseBackoff: time.Hour})
+
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("empty queue", func(t *testing.T) {
+		ran, err := q.runNext(context.Background(), 0)
+		if err != nil || ran {
+			t.Errorf("expected nothing to run, got ran=%v err=%v", ran, err)
+		}
+	})
+
+	var jobID int64
+	t.Run("failure schedules a retry", func(t *testing.T) {
+		q.archive = func(context.Context, *db.DB, db.Bookmark, ArchiveOptions) error {
+			return errors.New("navigation timeout")
+		}
+		if err := q.Enqueue(id, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		before := time.Now()
+		ran, err := q.runNext(context.Background(), 0)
+		if err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+
+		jobs, err := database.ListJobs("", 0)
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("expected 1 job, got %d (err=%v)", len(jobs), err)
+		}
+		job := jobs[0]
+		jobID = job.ID
+		if job.Status != db.JobStatusQueued || job.Attempts != 1 || job.LastError != "navigation timeout" {
+			t.Errorf("unexpected job: %+v", job)
+		}
+		next, err := time.Parse(time.RFC3339, job.NextAttemptAt)
+		if err != nil {
+			t.Fatalf("failed to parse next_attempt_at: %v", err)
+		}
+		if next.Before(before.Add(59 * time.Minute)) {
+			t.Errorf("expected retry about an hour out, got %s", next)
+		}
+
+		// Not due yet, so a second run does nothing.
+		if ran, _ := q.runNext(context.Background(), 0); ran {
+			t.Error("expected retry not to be due yet")
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		if err := database.RetryJob(jobID, "navigation timeout", time.Now()); err != nil {
+			t.Fatalf("failed to make job due: %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		job, err := database.GetJob(jobID)
+		if err != nil {
+			t.Fatalf("GetJob() error = %v", err)
+		}
+		if job.Status != db.JobStatusFailed || job.Attempts != 2 {
+			t.Errorf("expected job to fail permanently, got %+v", job)
+		}
+	})
+
+	t.Run("success completes the job", func(t *testing.T) {
+		var archived int64
+		q.archive = func(_ context.Context, _ *db.DB, b db.Bookmark, _ ArchiveOptions) error {
+			archived = b.ID
+			return nil
+		}
+		if err := q.Enqueue(id, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		if archived != id {
+			t.Errorf("expected bookmark %d to be archived, got %d", id, archived)
+		}
+		done, err := database.ListJobs(db.JobStatusDone, 0)
+		if err != nil || len(done) != 1 {
+			t.Errorf("expected 1 done job, got %d (err=%v)", len(done), err)
+		}
+	})
+
+	t.Run("blocked domain fails without retrying", func(t *testing.T) {
+		q.archive = func(context.Context, *db.DB, db.Bookmark, ArchiveOptions) error {
+			return ErrDomainBlocked
+		}
+		if err := q.Enqueue(id, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		failed, err := database.ListJobs(db.JobStatusFailed, 0)
+		if err != nil {
+			t.Fatalf("ListJobs() error = %v", err)
+		}
+		if len(failed) != 2 || failed[0].BookmarkID != id || failed[0].Attempts != 1 {
+			t.Errorf("expected the job to fail on its first attempt, got %+v", failed)
+		}
+	})
+
+	t.Run("site opting out completes without retrying", func(t *testing.T) {
+		q.archive = func(context.Context, *db.DB, db.Bookmark, ArchiveOptions) error {
+			return ErrArchiveDisallowed
+		}
+		if err := q.Enqueue(id, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		done, err := database.ListJobs(db.JobStatusDone, 0)
+		if err != nil || len(done) != 2 {
+			t.Errorf("expected the skipped job to be done, got %d done (err=%v)", len(done), err)
+		}
+		if queued, err := database.ListJobs(db.JobStatusQueued, 0); err != nil || len(queued) != 0 {
+			t.Errorf("expected no retry to be queued, got %d (err=%v)", len(queued), err)
+		}
+	})
+
+	t.Run("deleted bookmark fails the job", func(t *testing.T) {
+		if _, err := database.EnqueueJob(db.JobKindArchive, 424242, ""); err != nil {
+			t.Fatalf("EnqueueJob() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		failed, err := database.ListJobs(db.JobStatusFailed, 0)
+		if err != nil {
+			t.Fatalf("ListJobs() error = %v", err)
+		}
+		if len(failed) != 3 || failed[0].BookmarkID != 424242 {
+			t.Errorf("unexpected failed jobs: %+v", failed)
+		}
+	})
+}
+
+func TestArchiveQueue_RetryPolicy(t *testing.T) {
+	database := newQueueTestDB(t)
+	q := NewArchiveQueue(database, ArchiveQueueOptions{
+		MaxAttempts: 5,
+		BaseBackoff: time.Hour,
+		RetryPolicy: ArchiveRetryPolicy{ArchiveErrorNetwork: {MaxAttempts: 2, Interval: 10 * time.Minute}},
+	})
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	run := func(t *testing.T, archiveErr error) db.Job {
+		t.Helper()
+		q.archive = func(context.Context, *db.DB, db.Bookmark, ArchiveOptions) error { return archiveErr }
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		jobs, err := database.ListJobs("", 1)
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("expected a job, got %d (err=%v)", len(jobs), err)
+		}
+		return jobs[0]
+	}
+
+	refused := fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED)
+	if err := q.Enqueue(id, "test"); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	before := time.Now()
+	job := run(t, refused)
+	next, err := time.Parse(time.RFC3339, job.NextAttemptAt)
+	if err != nil {
+		t.Fatalf("failed to parse next_attempt_at: %v", err)
+	}
+	if job.Status != db.JobStatusQueued || next.Before(before.Add(9*time.Minute)) || next.After(before.Add(11*time.Minute)) {
+		t.Errorf("expected a retry in the rule's 10m, got %+v", job)
+	}
+	if err := database.RetryJob(job.ID, "", time.Now()); err != nil {
+		t.Fatalf("failed to make job due: %v", err)
+	}
+	if job := run(t, refused); job.Status != db.JobStatusFailed || job.Attempts != 2 {
+		t.Errorf("expected the rule's 2 attempts, got %+v", job)
+	}
+
+	// Codes without a rule keep the defaults: no retrying 404s.
+	if err := q.Enqueue(id, "test"); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if job := run(t, &HTTPStatusError{StatusCode: 404, URL: "https://example.com"}); job.Status != db.JobStatusFailed || job.Attempts != 1 {
+		t.Errorf("expected a 404 not to be retried, got %+v", job)
+	}
+
+	attempts, err := database.ListArchiveAttempts(id)
+	if err != nil {
+		t.Fatalf("ListArchiveAttempts() error = %v", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 recorded attempts, got %+v", attempts)
+	}
+	if a := attempts[2]; a.Attempt != 1 || a.Status != ArchiveStatusError || a.ErrorCode != ArchiveErrorNetwork || a.NextAttemptAt == "" {
+		t.Errorf("expected the first attempt to record its retry, got %+v", a)
+	}
+	if a := attempts[0]; a.ErrorCode != ArchiveErrorHTTP4xx || a.NextAttemptAt != "" || a.JobID == attempts[2].JobID {
+		t.Errorf("expected the 404 attempt last, without a retry, got %+v", a)
+	}
+}
+
+func TestParseArchiveRetryPolicy(t *testing.T) {
+	p, err := ParseArchiveRetryPolicy(" network=3/5m, http-4xx=1 ,timeout=4")
+	if err != nil {
+		t.Fatalf("ParseArchiveRetryPolicy() error = %v", err)
+	}
+	want := ArchiveRetryPolicy{
+		ArchiveErrorNetwork: {MaxAttempts: 3, Interval: 5 * time.Minute},
+		ArchiveErrorHTTP4xx: {MaxAttempts: 1},
+		ArchiveErrorTimeout: {MaxAttempts: 4},
+	}
+	if len(p) != len(want) {
+		t.Fatalf("got %+v, want %+v", p, want)
+	}
+	for code, rule := range want {
+		if p[code] != rule {
+			t.Errorf("rule for %s = %+v, want %+v", code, p[code], rule)
+		}
+	}
+	for _, bad := range []string{"network", "nope=3", "network=0", "network=x", "dns=2/soon", "dns=2/-1m"} {
+		if _, err := ParseArchiveRetryPolicy(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestArchiveQueue_Run(t *testing.T) {
+	database := newQueueTestDB(t)
+
+	// A bookmark created before the queue starts is picked up on startup.
+	existing, err := database.AddBookmark("https://example.com/existing", "Existing")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	q := NewArchiveQueue(database, ArchiveQueueOptions{Workers: 2, PollInterval: time.Hour})
+	archived := make(chan int64, 4)
+	q.archive = func(_ context.Context, _ *db.DB, b db.Bookmark, _ ArchiveOptions) error {
+		archived <- b.ID
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx) }()
+
+	waitFor := func(want int64) {
+		t.Helper()
+		select {
+		case got := <-archived:
+			if got != want {
+				t.Errorf("archived bookmark %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for bookmark %d to be archived", want)
+		}
+	}
+	waitFor(existing)
+
+	// Enqueue wakes an idle worker without waiting for the poll interval.
+	added, err := database.AddBookmark("https://example.com/new", "New")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := q.Enqueue(added, "test"); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	waitFor(added)
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+}
+
+func TestArchiveQueue_HostLimit(t *testing.T) {
+	database := newQueueTestDB(t)
+	for _, u := range []string{"https://example.com/1", "https://www.example.com/2", "https://example.com/3", "https://example.org/"} {
+		if _, err := database.AddBookmark(u, ""); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+	}
+
+	const delay = 100 * time.Millisecond
+	q := NewArchiveQueue(database, ArchiveQueueOptions{Workers: 3, PollInterval: time.Hour, HostDelay: delay})
+	var mu sync.Mutex
+	running := map[string]int{}
+	lastEnd := map[string]time.Time{}
+	archived := make(chan string, 4)
+	q.archive = func(_ context.Context, _ *db.DB, b db.Bookmark, _ ArchiveOptions) error {
+		host := db.JobHost(b.URL)
+		mu.Lock()
+		running[host]++
+		if running[host] > 1 {
+			t.Errorf("%s archived by %d workers at once", host, running[host])
+		}
+		if end, ok := lastEnd[host]; ok && time.Since(end) < delay {
+			t.Errorf("%s archived again after %s, want at least %s", host, time.Since(end), delay)
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running[host]--
+		lastEnd[host] = time.Now()
+		mu.Unlock()
+		archived <- host
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = q.Run(ctx) }()
+
+	var order []string
+	for range 4 {
+		select {
+		case host := <-archived:
+			order = append(order, host)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out with %v archived", order)
+		}
+	}
+	// The other host isn't held up behind example.com's delays.
+	if order[len(order)-1] != "example.com" {
+		t.Errorf("expected example.org to be archived while example.com rested, got %v", order)
+	}
+}
+
+func TestArchiveQueue_Settings(t *testing.T) {
+	database := newQueueTestDB(t)
+	q := NewArchiveQueue(database, ArchiveQueueOptions{
+		Archive:  ArchiveOptions{Headless: true},
+		Defaults: &ArchiveSettings{AutoArchive: true, Screenshot: true},
+	})
+	var got ArchiveOptions
+	q.archive = func(_ context.Context, _ *db.DB, _ db.Bookmark, opts ArchiveOptions) error {
+		got = opts
+		return nil
+	}
+
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("settings are fixed when the job is enqueued", func(t *testing.T) {
+		on := true
+		if err := database.SaveArchivePreferences(db.ArchivePreferences{UserID: db.LocalUserID, StripScripts: &on}); err != nil {
+			t.Fatalf("failed to save preferences: %v", err)
+		}
+		if err := q.Enqueue(id, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		// Later changes don't affect the queued job.
+		if err := database.SaveArchivePreferences(db.ArchivePreferences{UserID: db.LocalUserID}); err != nil {
+			t.Fatalf("failed to save preferences: %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		if !got.Headless || !got.StripScripts || !got.Screenshot || got.MobileViewport {
+			t.Errorf("unexpected archive options: %+v", got)
+		}
+	})
+
+	t.Run("auto-archive off skips new bookmarks", func(t *testing.T) {
+		off := false
+		if err := database.SaveArchivePreferences(db.ArchivePreferences{UserID: db.LocalUserID, AutoArchive: &off}); err != nil {
+			t.Fatalf("failed to save preferences: %v", err)
+		}
+		added, err := database.AddBookmark("https://example.com/new", "New")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := q.EnqueueNew(added, db.ArchiveOverrides{}); err != nil {
+			t.Fatalf("EnqueueNew() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || ran {
+			t.Errorf("expected nothing queued, got ran=%v err=%v", ran, err)
+		}
+
+		// Explicit requests still archive.
+		if err := q.Enqueue(added, "test"); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Errorf("expected explicit enqueue to run, got ran=%v err=%v", ran, err)
+		}
+	})
+
+	t.Run("a new bookmark's overrides beat the preferences", func(t *testing.T) {
+		on, off := true, false
+		if err := database.SaveArchivePreferences(db.ArchivePreferences{UserID: db.LocalUserID, AutoArchive: &on, StripScripts: &on}); err != nil {
+			t.Fatalf("failed to save preferences: %v", err)
+		}
+		added, err := database.AddBookmark("https://example.com/preset", "Preset")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := q.EnqueueNew(added, db.ArchiveOverrides{StripScripts: &off, MobileViewport: &on}); err != nil {
+			t.Fatalf("EnqueueNew() error = %v", err)
+		}
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		if got.StripScripts || !got.MobileViewport || !got.Screenshot {
+			t.Errorf("unexpected archive options: %+v", got)
+		}
+	})
+}
+
+func TestArchiveQueue_EnqueueStale(t *testing.T) {
+	database := newQueueTestDB(t)
+	q := NewArchiveQueue(database, ArchiveQueueOptions{RearchiveAfter: 90 * 24 * time.Hour})
+
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	archivedAt := time.Now()
+	if err := database.SaveArchiveResult(id, archivedAt, &archivedAt, ArchiveStatusOK, "", "https://example.com", "<html>v1</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	if n, err := q.EnqueueStale(time.Now()); err != nil || n != 0 {
+		t.Fatalf("expected a fresh archive not to be queued, got n=%d err=%v", n, err)
+	}
+	if n, err := q.EnqueueStale(time.Now().AddDate(0, 0, 91)); err != nil || n != 1 {
+		t.Fatalf("expected the stale archive to be queued, got n=%d err=%v", n, err)
+	}
+
+	// Re-archiving adds a version rather than replacing the old one.
+	q.archive = func(_ context.Context, database *db.DB, b db.Bookmark, _ ArchiveOptions) error {
+		at := time.Now().Add(time.Minute)
+		return database.SaveArchiveResult(b.ID, at, &at, ArchiveStatusOK, "", b.URL, "<html>v2</html>")
+	}
+	if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+		t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+	}
+	versions, err := database.ListArchiveVersions(id)
+	if err != nil {
+		t.Fatalf("ListArchiveVersions() error = %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("expected 2 versions, got %d", len(versions))
+	}
+}
+
+func TestEstimateQueueWait(t *testing.T) {
+	tests := []struct {
+		jobs, workers int
+		avg           float64
+		want          time.Duration
+	}{
+		{0, 1, 10, 0},
+		{1, 1, 10, 10 * time.Second},
+		{3, 2, 10, 20 * time.Second},
+		{4, 0, 1.5, 6 * time.Second},
+		{1, 1, 0, DefaultArchiveTimeout},
+	}
+	for _, tt := range tests {
+		if got := EstimateQueueWait(tt.jobs, tt.workers, tt.avg); got != tt.want {
+			t.Errorf("EstimateQueueWait(%d, %d, %g) = %s, want %s", tt.jobs, tt.workers, tt.avg, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveQueue_Backpressure(t *testing.T) {
+	database := newQueueTestDB(t)
+	q := NewArchiveQueue(database, ArchiveQueueOptions{MaxQueued: 1})
+	var archived []int64
+	q.archive = func(_ context.Context, _ *db.DB, b db.Bookmark, _ ArchiveOptions) error {
+		archived = append(archived, b.ID)
+		return nil
+	}
+
+	var ids []int64
+	for _, u := range []string{"https://one.example", "https://two.example", "https://three.example"} {
+		id, err := database.AddBookmark(u, u)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := q.EnqueueNew(id, db.ArchiveOverrides{}); err != nil {
+			t.Fatalf("EnqueueNew() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	stats, err := database.GetArchiveStats()
+	if err != nil {
+		t.Fatalf("GetArchiveStats() error = %v", err)
+	}
+	if stats.Queued != 1 || stats.Deferred != 2 {
+		t.Fatalf("expected 1 queued and 2 deferred, got %d and %d", stats.Queued, stats.Deferred)
+	}
+	eta, err := EstimateArchive(database, ids[2], 1)
+	if err != nil {
+		t.Fatalf("EstimateArchive() error = %v", err)
+	}
+	if eta.Status != db.JobStatusDeferred || eta.Ahead != 2 || eta.Wait != 3*DefaultArchiveTimeout {
+		t.Errorf("unexpected estimate %+v", eta)
+	}
+
+	// Deferred jobs are queued, one at a time, as the queue drains.
+	for range ids {
+		if ran, err := q.runNext(context.Background(), 0); err != nil || !ran {
+			t.Fatalf("expected a job to run, got ran=%v err=%v", ran, err)
+		}
+		if _, err := q.resumeDeferred(); err != nil {
+			t.Fatalf("resumeDeferred() error = %v", err)
+		}
+	}
+	if len(archived) != 3 || archived[0] != ids[0] || archived[2] != ids[2] {
+		t.Errorf("expected every bookmark archived in order, got %v", archived)
+	}
+	if eta, err := EstimateArchive(database, ids[2], 1); err != nil || eta.Status != "" {
+		t.Errorf("expected nothing pending, got %+v, %v", eta, err)
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// RebuildLinksResult reports the outcome of RebuildLinks.
+type RebuildLinksResult struct {
+	Bookmarks int `json:"bookmarks"`
+	Links     int `json:"links"`
+	Failed    int `json:"failed"`
+}
+
+// ExtractLinks returns the distinct http(s) links of an archived page,
+// resolved against pageURL. Links back to the page itself are skipped.
+func ExtractLinks(pageHTML, pageURL string) []db.BookmarkLink {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil
+	}
+	base, _ := url.Parse(pageURL)
+	seen := map[string]bool{LinkKey(pageURL): true}
+	var links []db.BookmarkLink
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		ref, err := url.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+		if err != nil {
+			return
+		}
+		if base != nil {
+			ref = base.ResolveReference(ref)
+		}
+		if ref.Scheme != "http" && ref.Scheme != "https" {
+			return
+		}
+		ref.Fragment, ref.RawFragment = "", ""
+		key := LinkKey(ref.String())
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		links = append(links, db.BookmarkLink{URL: ref.String(), Key: key, Host: linkHost(ref)})
+	})
+	return links
+}
+
+// LinkKey reduces a URL to what identifies the page it points to: the host
+// without "www.", the path without a trailing slash and the query. The
+// scheme and fragment are dropped. It returns "" for URLs it can't parse.
+func LinkKey(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	key := strings.TrimPrefix(strings.ToLower(u.Host), "www.") + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// linkHost returns u's lowercase host name without "www.".
+func linkHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// NewBacklinkQuery turns a URL (http or https) or a bare domain into the
+// query ListBacklinks runs.
+func NewBacklinkQuery(target string) (db.BacklinkQuery, error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return db.BacklinkQuery{}, fmt.Errorf("invalid URL %q", target)
+		}
+		return db.BacklinkQuery{Key: LinkKey(target)}, nil
+	}
+	domains, err := normalizeDomains([]string{target})
+	if err != nil {
+		return db.BacklinkQuery{}, err
+	}
+	if len(domains) == 0 {
+		return db.BacklinkQuery{}, fmt.Errorf("expected a URL or a domain")
+	}
+	return db.BacklinkQuery{Domain: strings.TrimPrefix(domains[0], "www.")}, nil
+}
+
+// saveArchiveLinks stores the links of a freshly archived page; failures
+// are logged, never fatal.
+func saveArchiveLinks(database *db.DB, bookmarkID int64, pageHTML, pageURL string) {
+	if err := database.SaveBookmarkLinks(bookmarkID, ExtractLinks(pageHTML, pageURL)); err != nil {
+		log.Printf("Warning: failed to save links for id=%d: %v", bookmarkID, err)
+	}
+}
+
+// RebuildLinks re-extracts the links of every archived bookmark database
+// sees from its latest version, e.g. for archives made before links were
+// recorded. Downloads have no links.
+func RebuildLinks(database *db.DB) (RebuildLinksResult, error) {
+	var res RebuildLinksResult
+	bookmarks, err := database.ListArchivedBookmarks(0)
+	if err != nil {
+		return res, err
+	}
+	for _, b := range bookmarks {
+		v, err := database.GetLatestArchiveVersion(b.ID)
+		if err != nil {
+			log.Printf("Failed to load archive for id=%d: %v", b.ID, err)
+			res.Failed++
+			continue
+		}
+		var links []db.BookmarkLink
+		if !v.HasDownload {
+			pageURL := v.ArchivedURL
+			if pageURL == "" {
+				pageURL = b.URL
+			}
+			links = ExtractLinks(v.ArchivedHTML, pageURL)
+		}
+		if err := database.SaveBookmarkLinks(b.ID, links); err != nil {
+			log.Printf("Failed to save links for id=%d: %v", b.ID, err)
+			res.Failed++
+			continue
+		}
+		res.Bookmarks++
+		res.Links += len(links)
+	}
+	return res, nil
+}
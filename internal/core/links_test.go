@@ -0,0 +1,105 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestLinkKey(t *testing.T) {
+	tests := map[string]string{
+		"https://www.Example.com/post/":    "example.com/post",
+		"http://example.com/post#comments": "example.com/post",
+		"https://example.com/search?q=go":  "example.com/search?q=go",
+		"https://example.com":              "example.com",
+		"not a url":                        "",
+	}
+	for in, want := range tests {
+		if got := LinkKey(in); got != want {
+			t.Errorf("LinkKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	page := `<nav><a href="/">Home</a></nav>
+		<p><a href="/post/2#top">next</a> <a href="https://WWW.Other.org/x">x</a> <a href="//other.org/x/">x again</a>
+		<a href="mailto:me@example.com">mail</a> <a href="#section">jump</a> <a href="/post/1">self</a></p>`
+
+	links := ExtractLinks(page, "https://example.com/post/1")
+
+	want := []db.BookmarkLink{
+		{URL: "https://example.com/", Key: "example.com", Host: "example.com"},
+		{URL: "https://example.com/post/2", Key: "example.com/post/2", Host: "example.com"},
+		{URL: "https://WWW.Other.org/x", Key: "other.org/x", Host: "other.org"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("expected %d links, got %+v", len(want), links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Errorf("link %d: expected %+v, got %+v", i, want[i], links[i])
+		}
+	}
+}
+
+func TestNewBacklinkQuery(t *testing.T) {
+	tests := []struct {
+		target string
+		want   db.BacklinkQuery
+	}{
+		{"https://www.example.com/post/", db.BacklinkQuery{Key: "example.com/post"}},
+		{"Example.com", db.BacklinkQuery{Domain: "example.com"}},
+		{"www.example.com", db.BacklinkQuery{Domain: "example.com"}},
+	}
+	for _, tt := range tests {
+		got, err := NewBacklinkQuery(tt.target)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.target, err)
+		} else if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.target, tt.want, got)
+		}
+	}
+	for _, bad := range []string{"", "ftp://example.com/file", "example.com/path"} {
+		if _, err := NewBacklinkQuery(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRebuildLinks(t *testing.T) {
+	database := newQueueTestDB(t)
+	page, _ := database.AddBookmark("https://example.com/page", "Page")
+	file, _ := database.AddBookmark("https://example.com/file.zip", "File")
+	if _, err := database.AddBookmark("https://example.com/unarchived", ""); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	now := time.Now()
+	if err := database.SaveArchiveResult(page, now, &now, ArchiveStatusOK, "", "https://example.com/page", `<a href="https://other.org/">other</a> <a href="/about">about</a>`); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := database.SaveArchiveResult(file, now, &now, ArchiveStatusOK, "", "https://example.com/file.zip", `<a href="https://example.com/file.zip">file.zip</a> <a href="https://other.org/">other</a>`); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := database.SaveArchiveDownload(file, db.ArchiveDownload{Filename: "file.zip", MIMEType: "application/zip", Data: []byte("PK")}); err != nil {
+		t.Fatalf("failed to save download: %v", err)
+	}
+
+	res, err := RebuildLinks(database)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res != (RebuildLinksResult{Bookmarks: 2, Links: 2}) {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	backlinks, err := database.ListBacklinks(db.BacklinkQuery{Domain: "other.org"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(backlinks) != 1 || backlinks[0].BookmarkID != page {
+		t.Errorf("expected only the page to link to other.org, got %+v", backlinks)
+	}
+}
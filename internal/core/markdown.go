@@ -0,0 +1,275 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToMarkdown converts the sanitized article HTML reader mode produces
+// (see ExtractArticle) into Markdown. Elements without a Markdown form are
+// unwrapped, and inlined data: images are reduced to their alt text.
+func HTMLToMarkdown(content string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return ""
+	}
+	w := &markdownWriter{}
+	for _, n := range nodes {
+		w.node(n)
+	}
+	return strings.TrimSpace(collapseBlankLines(w.b.String())) + "\n"
+}
+
+// collapseBlankLines reduces each run of blank lines (empty, or only
+// blockquote markers) that nested blocks leave behind to one, preferring
+// an empty line when the run has one.
+func collapseBlankLines(s string) string {
+	var out []string
+	inRun := false
+	for _, line := range strings.Split(s, "\n") {
+		if strings.Trim(line, "> ") != "" {
+			out = append(out, line)
+			inRun = false
+			continue
+		}
+		line = strings.TrimRight(line, " ")
+		switch {
+		case !inRun:
+			out = append(out, line)
+			inRun = true
+		case line == "":
+			out[len(out)-1] = ""
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// markdownWriter accumulates Markdown for a tree of nodes.
+type markdownWriter struct {
+	b strings.Builder
+	// prefix starts every line: list indentation and blockquote markers.
+	prefix string
+	// pre is set inside <pre>, where text is written verbatim.
+	pre bool
+}
+
+// block ends the current paragraph with a blank line.
+func (w *markdownWriter) block() {
+	w.b.WriteString("\n" + strings.TrimRight(w.prefix, " ") + "\n" + w.prefix)
+}
+
+// atLineStart reports whether nothing but the prefix has been written on
+// the current line.
+func (w *markdownWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, "\n"+w.prefix)
+}
+
+// newline starts a new line within the current block.
+func (w *markdownWriter) newline() {
+	w.b.WriteString("\n" + w.prefix)
+}
+
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+// markdownText returns the plain text of n.
+func markdownText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func markdownAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func (w *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre {
+			w.b.WriteString(strings.ReplaceAll(n.Data, "\n", "\n"+w.prefix))
+			return
+		}
+		text := collapseWhitespace.ReplaceAllString(n.Data, " ")
+		if w.atLineStart() {
+			text = strings.TrimLeft(text, " ")
+		}
+		w.b.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch tag := n.Data; tag {
+	case "p", "div", "section", "article", "figure", "dl":
+		w.block()
+		w.children(n)
+		w.block()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.block()
+		w.b.WriteString(strings.Repeat("#", int(tag[1]-'0')) + " ")
+		w.b.WriteString(strings.TrimSpace(collapseWhitespace.ReplaceAllString(markdownText(n), " ")))
+		w.block()
+	case "br":
+		w.b.WriteString("  ")
+		w.newline()
+	case "hr":
+		w.block()
+		w.b.WriteString("---")
+		w.block()
+	case "em", "i", "cite":
+		w.wrap(n, "_")
+	case "strong", "b":
+		w.wrap(n, "**")
+	case "s":
+		w.wrap(n, "~~")
+	case "code", "kbd", "samp":
+		if w.pre {
+			w.children(n)
+			return
+		}
+		w.b.WriteString("`" + strings.ReplaceAll(markdownText(n), "`", "") + "`")
+	case "pre":
+		w.block()
+		w.b.WriteString("```")
+		w.newline()
+		w.pre = true
+		w.children(n)
+		w.pre = false
+		w.newline()
+		w.b.WriteString("```")
+		w.block()
+	case "a":
+		href := markdownAttr(n, "href")
+		text := strings.TrimSpace(collapseWhitespace.ReplaceAllString(markdownText(n), " "))
+		if href == "" || strings.HasPrefix(href, "#") {
+			w.b.WriteString(text)
+			return
+		}
+		if text == "" {
+			text = href
+		}
+		w.b.WriteString("[" + text + "](" + href + ")")
+	case "img":
+		alt := markdownAttr(n, "alt")
+		src := markdownAttr(n, "src")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			w.b.WriteString(alt)
+			return
+		}
+		w.b.WriteString("![" + alt + "](" + src + ")")
+	case "blockquote":
+		w.block()
+		saved := w.prefix
+		w.prefix += "> "
+		w.b.WriteString("> ")
+		w.children(n)
+		w.prefix = saved
+		w.block()
+	case "ul", "ol":
+		w.block()
+		i := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.Data != "li" {
+				continue
+			}
+			i++
+			marker := "- "
+			if tag == "ol" {
+				marker = strconv.Itoa(i) + ". "
+			}
+			if i > 1 {
+				w.newline()
+			}
+			w.b.WriteString(marker)
+			saved := w.prefix
+			w.prefix += strings.Repeat(" ", len(marker))
+			w.children(c)
+			w.prefix = saved
+		}
+		w.block()
+	case "table":
+		w.block()
+		for i, row := range markdownRows(n) {
+			if i > 0 {
+				w.newline()
+			}
+			w.b.WriteString("| " + strings.Join(row, " | ") + " |")
+			if i == 0 {
+				w.newline()
+				w.b.WriteString("|" + strings.Repeat(" --- |", len(row)))
+			}
+		}
+		w.block()
+	case "dt":
+		w.newline()
+		w.wrap(n, "**")
+	case "dd":
+		w.newline()
+		w.b.WriteString(": ")
+		w.children(n)
+	case "figcaption", "caption":
+		w.newline()
+		w.wrap(n, "_")
+	default:
+		w.children(n)
+	}
+}
+
+// markdownRows returns the cell text of a table's rows, header rows
+// included.
+func markdownRows(table *html.Node) [][]string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			var cells []string
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+					cell := strings.TrimSpace(collapseWhitespace.ReplaceAllString(markdownText(c), " "))
+					cells = append(cells, strings.ReplaceAll(cell, "|", `\|`))
+				}
+			}
+			if len(cells) > 0 {
+				rows = append(rows, cells)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(table)
+	return rows
+}
+
+// wrap writes n's text between markers, e.g. "**" for bold.
+func (w *markdownWriter) wrap(n *html.Node, marker string) {
+	text := strings.TrimSpace(collapseWhitespace.ReplaceAllString(markdownText(n), " "))
+	if text == "" {
+		return
+	}
+	w.b.WriteString(marker + text + marker)
+}
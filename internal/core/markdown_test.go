@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"paragraphs and inline", `<p>Hello <strong>bold</strong>, <em>soft</em> and <code>x()</code>.</p><p>Second</p>`,
+			"Hello **bold**, _soft_ and `x()`.\n\nSecond\n"},
+		{"headings and links", `<h2>Title</h2><p>See <a href="https://example.com/a" rel="noopener">this</a>.</p>`,
+			"## Title\n\nSee [this](https://example.com/a).\n"},
+		{"line breaks", `<p>one<br>two</p>`, "one  \ntwo\n"},
+		{"lists", `<ul><li>one</li><li>two</li></ul><ol><li>a</li><li>b</li></ol>`, "- one\n- two\n\n1. a\n2. b\n"},
+		{"nested list", `<ul><li>one<ul><li>inner</li></ul></li></ul>`, "- one\n\n  - inner\n"},
+		{"blockquote", `<blockquote><p>quoted</p><p>more</p></blockquote>`, "> quoted\n>\n> more\n"},
+		{"code block", "<pre><code>a := 1\nb := 2</code></pre>", "```\na := 1\nb := 2\n```\n"},
+		{"images", `<img src="data:image/png;base64,AA" alt="inlined"> <img src="https://example.com/i.png" alt="remote">`,
+			"inlined ![remote](https://example.com/i.png)\n"},
+		{"table", `<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>x|y</td></tr></table>`,
+			"| A | B |\n| --- | --- |\n| 1 | x\\|y |\n"},
+		{"unknown elements are unwrapped", `<div><span>plain</span> text</div>`, "plain text\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("HTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// ReadUserDataExport reads an account export (see ExportUserData), as
+// written by "bookmarkd account export": a JSON document or a backup bundle
+// (see WriteUserDataBundle), either optionally gzip- or zstd-compressed.
+func ReadUserDataExport(r io.Reader) (UserDataExport, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	var in io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("failed to read gzip export: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		in = gz
+	case bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return UserDataExport{}, fmt.Errorf("failed to read zstd export: %w", err)
+		}
+		defer zr.Close()
+		in = zr
+	}
+	// bufio.NewReader returns br itself when nothing was decompressed.
+	buf := bufio.NewReader(in)
+	if header, _ := buf.Peek(512); isTar(header) {
+		return readUserDataBundle(buf)
+	}
+	return decodeUserDataExport(buf)
+}
+
+// decodeUserDataExport decodes an uncompressed JSON account export.
+func decodeUserDataExport(in io.Reader) (UserDataExport, error) {
+	var export UserDataExport
+	if err := json.NewDecoder(in).Decode(&export); err != nil {
+		return UserDataExport{}, fmt.Errorf("failed to parse export: %w", err)
+	}
+	if export.Bookmarks == nil {
+		return UserDataExport{}, errors.New("failed to parse export: no bookmarks list; is this an account export?")
+	}
+	return export, nil
+}
+
+// Merge conflict resolutions, as MergeConflict.Kept.
+const (
+	MergeKeptLocal    = "local"
+	MergeKeptIncoming = "incoming"
+	// MergeKeptBoth is for notes, which are appended rather than replaced.
+	MergeKeptBoth = "both"
+)
+
+// MergeConflict is a field a bookmark had different values for in the
+// database and the export, and which one the merge kept.
+type MergeConflict struct {
+	URL        string `json:"url"`
+	BookmarkID int64  `json:"bookmark_id"`
+	// Field is "title", "collection" or "notes".
+	Field    string `json:"field"`
+	Local    string `json:"local"`
+	Incoming string `json:"incoming"`
+	Kept     string `json:"kept"`
+}
+
+// MergeResult reports the outcome of MergeUserData.
+type MergeResult struct {
+	// Added holds the IDs of bookmarks new to the database, in export order.
+	Added []int64 `json:"added"`
+	// Updated holds the IDs of existing bookmarks the export added to.
+	Updated []int64 `json:"updated"`
+	// Unchanged counts bookmarks already in the database with nothing new.
+	Unchanged int `json:"unchanged"`
+	// Archives counts the archive versions added.
+	Archives  int             `json:"archives"`
+	Conflicts []MergeConflict `json:"conflicts"`
+	// Duplicates are URLs listed more than once; only the first was merged.
+	Duplicates []string      `json:"duplicates"`
+	Invalid    []ImportError `json:"invalid"`
+}
+
+// MergeUserData merges the bookmarks of an account export, typically from
+// another instance, into the database's user's. Bookmarks are matched by
+// URL; new ones are added with everything exported for them. For one
+// already bookmarked:
+//
+//   - tags are the union of both, and it is read or a favorite if either
+//     copy is;
+//   - the title of the more recently created copy wins, and so does the
+//     more recently fetched metadata; a missing favicon is filled in;
+//   - the local collection is kept, unless it has none;
+//   - notes that differ are appended to the local ones, so none are lost;
+//   - archive versions are added unless one was captured at the same time.
+//
+// Differing titles, collections and notes are reported as conflicts. The
+// export's settings, rules, presets and shared collections are left out:
+// they belong to the other instance.
+func MergeUserData(database *db.DB, export UserDataExport) (MergeResult, error) {
+	res := MergeResult{Added: []int64{}, Updated: []int64{}, Conflicts: []MergeConflict{}, Duplicates: []string{}, Invalid: []ImportError{}}
+
+	seen := make(map[string]bool)
+	var bookmarks []ExportedBookmark
+	urls := make([]string, 0, len(export.Bookmarks))
+	for i, eb := range export.Bookmarks {
+		eb.URL = strings.TrimSpace(eb.URL)
+		if err := db.ValidateBookmarkURL(eb.URL); err != nil {
+			res.Invalid = append(res.Invalid, ImportError{Index: i + 1, URL: eb.URL, Error: err.Error()})
+			continue
+		}
+		if seen[eb.URL] {
+			res.Duplicates = append(res.Duplicates, eb.URL)
+			continue
+		}
+		seen[eb.URL] = true
+		bookmarks = append(bookmarks, eb)
+		urls = append(urls, eb.URL)
+	}
+
+	existing := make(map[string]int64)
+	for start := 0; start < len(urls); start += importChunkSize {
+		ids, err := database.BookmarkIDsByURL(urls[start:min(start+importChunkSize, len(urls))])
+		if err != nil {
+			return res, err
+		}
+		for u, id := range ids {
+			existing[u] = id
+		}
+	}
+
+	for _, eb := range bookmarks {
+		id, ok := existing[eb.URL]
+		if !ok {
+			id, err := addMergedBookmark(database, eb, &res)
+			if err != nil {
+				return res, fmt.Errorf("failed to add %s: %w", eb.URL, err)
+			}
+			res.Added = append(res.Added, id)
+			continue
+		}
+		changed, err := mergeBookmark(database, id, eb, &res)
+		if err != nil {
+			return res, fmt.Errorf("failed to merge %s: %w", eb.URL, err)
+		}
+		if changed {
+			res.Updated = append(res.Updated, id)
+		} else {
+			res.Unchanged++
+		}
+	}
+
+	log.Printf("Merge: added %d bookmark(s), updated %d, %d unchanged, %d archive version(s), %d conflict(s), %d duplicate(s), %d invalid",
+		len(res.Added), len(res.Updated), res.Unchanged, res.Archives, len(res.Conflicts), len(res.Duplicates), len(res.Invalid))
+	return res, nil
+}
+
+// addMergedBookmark creates an exported bookmark that isn't in the
+// database yet, with everything attached to it.
+func addMergedBookmark(database *db.DB, eb ExportedBookmark, res *MergeResult) (int64, error) {
+	nb := db.NewBookmark{URL: eb.URL, Title: eb.Title, Tags: eb.Tags, Collection: eb.Collection, Notes: eb.Notes, Source: db.SourceMerge}
+	if t, err := time.Parse(time.RFC3339, eb.CreatedAt); err == nil {
+		nb.CreatedAt = t
+	}
+	id, err := database.CreateBookmark(nb)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := mergeAttachments(database, id, eb, db.BookmarkFlags{}, db.BookmarkMetadata{}, false, res); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// mergeBookmark merges an exported bookmark into the existing bookmark id
+// and reports whether that changed it.
+func mergeBookmark(database *db.DB, id int64, eb ExportedBookmark, res *MergeResult) (bool, error) {
+	local, err := database.GetBookmark(id)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	conflict := func(field, localValue, incoming, kept string) {
+		res.Conflicts = append(res.Conflicts, MergeConflict{URL: eb.URL, BookmarkID: id, Field: field, Local: localValue, Incoming: incoming, Kept: kept})
+	}
+
+	if title := strings.TrimSpace(eb.Title); title != "" && title != local.Title {
+		kept := MergeKeptLocal
+		if local.Title == "" || newerTimestamp(eb.CreatedAt, local.CreatedAt) {
+			kept = MergeKeptIncoming
+		}
+		if local.Title != "" {
+			conflict("title", local.Title, title, kept)
+		}
+		if kept == MergeKeptIncoming {
+			if err := database.UpdateBookmark(id, local.URL, title); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+
+	tags, err := database.ListBookmarkTags(id)
+	if err != nil {
+		return false, err
+	}
+	var newTags []string
+	for _, tag := range eb.Tags {
+		if tag = db.NormalizeTag(tag); tag != "" && !slices.Contains(tags, tag) && !slices.Contains(newTags, tag) {
+			newTags = append(newTags, tag)
+		}
+	}
+	if len(newTags) > 0 {
+		if err := database.AddBookmarkTags(id, newTags); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	if incoming := strings.TrimSpace(eb.Collection); incoming != "" {
+		collection, err := database.GetBookmarkCollection(id)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case collection == "":
+			if err := database.SetBookmarkCollection(id, incoming); err != nil {
+				return false, err
+			}
+			changed = true
+		case collection != incoming:
+			conflict("collection", collection, incoming, MergeKeptLocal)
+		}
+	}
+
+	if incoming := strings.TrimSpace(eb.Notes); incoming != "" {
+		notes, err := database.GetBookmarkNotes(id)
+		if err != nil {
+			return false, err
+		}
+		if !strings.Contains(notes, incoming) {
+			merged := incoming
+			if strings.TrimSpace(notes) != "" {
+				conflict("notes", notes, incoming, MergeKeptBoth)
+				merged = strings.TrimRight(notes, "\n") + "\n\n" + incoming
+			}
+			if err := database.SetBookmarkNotes(id, merged); err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+
+	flags, err := database.GetBookmarkFlags(id)
+	if err != nil {
+		return false, err
+	}
+	meta, err := database.GetBookmarkMetadata(id)
+	if err != nil {
+		return false, err
+	}
+	hasFavicon, err := database.HasBookmarkFavicon(id)
+	if err != nil {
+		return false, err
+	}
+	attached, err := mergeAttachments(database, id, eb, flags, meta, hasFavicon, res)
+	if err != nil {
+		return false, err
+	}
+	return changed || attached, nil
+}
+
+// mergeAttachments merges an exported bookmark's flags, metadata, favicon
+// and archive versions into bookmark id, which has the given ones so far,
+// and reports whether anything was added.
+func mergeAttachments(database *db.DB, id int64, eb ExportedBookmark, flags db.BookmarkFlags, meta db.BookmarkMetadata, hasFavicon bool, res *MergeResult) (bool, error) {
+	changed := false
+	if eb.IsRead && !flags.IsRead {
+		if err := database.MarkRead(id, true); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if eb.IsFavorite && !flags.IsFavorite {
+		if _, err := database.ToggleFavorite(id); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if eb.IsPinned && !flags.IsPinned {
+		if err := database.SetBookmarkPinned(id, true); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if m := eb.Metadata; m != nil && (meta.FetchedAt == "" || newerTimestamp(m.FetchedAt, meta.FetchedAt)) {
+		if err := database.SaveBookmarkMetadata(db.BookmarkMetadata{
+			BookmarkID:  id,
+			Description: m.Description,
+			FaviconURL:  m.FaviconURL,
+			ImageURL:    m.ImageURL,
+			SiteName:    m.SiteName,
+			FetchedAt:   m.FetchedAt,
+		}); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if f := eb.Favicon; f != nil && !hasFavicon && len(f.Data) > 0 {
+		if err := database.SaveBookmarkFavicon(db.BookmarkFavicon{BookmarkID: id, SourceURL: f.SourceURL, ContentType: f.ContentType, Data: f.Data}); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	for _, a := range eb.Archives {
+		v := db.NewArchiveVersion{
+			CapturedAt:  a.CapturedAt,
+			ArchivedURL: a.ArchivedURL,
+			HTML:        a.HTML,
+			Screenshot:  a.Screenshot,
+			Provenance:  string(a.Provenance),
+		}
+		if a.Download != nil {
+			v.Download = &db.ArchiveDownload{Filename: a.Download.Filename, MIMEType: a.Download.ContentType, Data: a.Download.Data}
+		}
+		if a.Timestamp != nil {
+			v.Timestamp = &db.ArchiveTimestamp{
+				ContentHash:   a.Timestamp.ContentHash,
+				Authority:     a.Timestamp.Authority,
+				TimestampedAt: a.Timestamp.TimestampedAt,
+				Token:         a.Timestamp.Token,
+			}
+		}
+		added, err := database.AddArchiveVersion(id, v)
+		if err != nil {
+			return false, err
+		}
+		if added {
+			res.Archives++
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// newerTimestamp reports whether RFC3339 timestamp a is after b. A
+// timestamp that doesn't parse is never newer.
+func newerTimestamp(a, b string) bool {
+	ta, err := time.Parse(time.RFC3339, a)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339, b)
+	return err != nil || ta.After(tb)
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestReadUserDataExport(t *testing.T) {
+	body := `{"user_id": 1, "bookmarks": [{"url": "https://example.com", "tags": []}]}`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(body))
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("failed to start zstd: %v", err)
+	}
+	zst := zw.EncodeAll([]byte(body), nil)
+	for name, in := range map[string][]byte{"plain": []byte(body), "gzip": gz.Bytes(), "zstd": zst} {
+		export, err := ReadUserDataExport(bytes.NewReader(in))
+		if err != nil || len(export.Bookmarks) != 1 {
+			t.Errorf("%s: expected one bookmark, got %+v, %v", name, export, err)
+		}
+	}
+	if _, err := ReadUserDataExport(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0})); err == nil {
+		t.Error("expected an error for a broken zstd stream")
+	}
+	if _, err := ReadUserDataExport(strings.NewReader(`[{"url": "https://example.com"}]`)); err == nil {
+		t.Error("expected an error for something that isn't an account export")
+	}
+}
+
+func TestMergeUserData(t *testing.T) {
+	other := newQueueTestDB(t)
+	database := newQueueTestDB(t)
+	day := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// The other instance: a bookmark only it has, with an archive, and a
+	// newer copy of one both have.
+	onlyThere, err := other.CreateBookmark(db.NewBookmark{URL: "https://go.dev", Title: "Go", Tags: []string{"go"}, Collection: "Dev", CreatedAt: day})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	captured := day.Add(time.Hour)
+	if err := other.SaveArchiveResult(onlyThere, captured, &captured, ArchiveStatusOK, "", "https://go.dev/", "<html>go</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := other.SaveArchiveScreenshot(onlyThere, []byte("png")); err != nil {
+		t.Fatalf("failed to save screenshot: %v", err)
+	}
+	both, err := other.CreateBookmark(db.NewBookmark{URL: "https://example.com", Title: "Example (renamed)", Tags: []string{"b", "shared"}, Collection: "Work", Notes: "from there", CreatedAt: day.Add(48 * time.Hour)})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	if err := other.MarkRead(both, true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	older := day.Add(-time.Hour)
+	if err := other.SaveArchiveResult(both, older, &older, ArchiveStatusOK, "", "https://example.com/", "<html>old</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	// This instance has the older copy, archived later.
+	local, err := database.CreateBookmark(db.NewBookmark{URL: "https://example.com", Title: "Example", Tags: []string{"a", "shared"}, Collection: "Reading", Notes: "from here", CreatedAt: day})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	if err := database.SaveArchiveResult(local, day, &day, ArchiveStatusOK, "", "https://example.com/", "<html>new</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	export, err := ExportUserData(other, db.LocalUserID, "")
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	export.Bookmarks = append(export.Bookmarks, ExportedBookmark{URL: "https://go.dev"}, ExportedBookmark{URL: "not a url"})
+	// Round-trip through JSON, as the command reads it.
+	body, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("failed to encode export: %v", err)
+	}
+	if export, err = ReadUserDataExport(bytes.NewReader(body)); err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+
+	res, err := MergeUserData(database, export)
+	if err != nil {
+		t.Fatalf("MergeUserData() error = %v", err)
+	}
+	if len(res.Added) != 1 || len(res.Updated) != 1 || res.Updated[0] != local || res.Unchanged != 0 || res.Archives != 2 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if len(res.Duplicates) != 1 || len(res.Invalid) != 1 || res.Invalid[0].Index != 4 {
+		t.Errorf("expected the repeated and the invalid URL to be reported, got %+v, %+v", res.Duplicates, res.Invalid)
+	}
+	kept := map[string]string{}
+	for _, c := range res.Conflicts {
+		kept[c.Field] = c.Kept
+	}
+	if len(res.Conflicts) != 3 || kept["title"] != MergeKeptIncoming || kept["collection"] != MergeKeptLocal || kept["notes"] != MergeKeptBoth {
+		t.Errorf("unexpected conflicts %+v", res.Conflicts)
+	}
+
+	t.Run("new bookmark comes with its archive", func(t *testing.T) {
+		id := res.Added[0]
+		b, err := database.GetBookmark(id)
+		if err != nil || b.Title != "Go" || b.CreatedAt != day.Format(time.RFC3339) {
+			t.Fatalf("unexpected bookmark %+v, %v", b, err)
+		}
+		if c, _ := database.GetBookmarkCollection(id); c != "Dev" {
+			t.Errorf("expected the collection to carry over, got %q", c)
+		}
+		versions, err := database.ListArchiveVersions(id)
+		if err != nil || len(versions) != 1 || !versions[0].HasScreenshot {
+			t.Fatalf("expected the archive version with its screenshot, got %+v, %v", versions, err)
+		}
+		archive, err := database.GetBookmarkArchiveStatus(id)
+		if err != nil || archive.ArchiveStatus != ArchiveStatusOK || archive.ArchivedAt != captured.Format(time.RFC3339) {
+			t.Errorf("expected the bookmark to count as archived, got %+v, %v", archive, err)
+		}
+	})
+
+	t.Run("existing bookmark is merged", func(t *testing.T) {
+		b, err := database.GetBookmark(local)
+		if err != nil || b.Title != "Example (renamed)" {
+			t.Errorf("expected the newer title to win, got %+v, %v", b, err)
+		}
+		tags, _ := database.ListBookmarkTags(local)
+		if strings.Join(tags, ",") != "a,b,shared" {
+			t.Errorf("expected the union of tags, got %v", tags)
+		}
+		if c, _ := database.GetBookmarkCollection(local); c != "Reading" {
+			t.Errorf("expected the local collection to be kept, got %q", c)
+		}
+		if notes, _ := database.GetBookmarkNotes(local); notes != "from here\n\nfrom there" {
+			t.Errorf("expected both notes, got %q", notes)
+		}
+		if flags, _ := database.GetBookmarkFlags(local); !flags.IsRead {
+			t.Error("expected the read flag to carry over")
+		}
+		versions, err := database.ListArchiveVersions(local)
+		if err != nil || len(versions) != 2 {
+			t.Fatalf("expected both archive versions, got %+v, %v", versions, err)
+		}
+		latest, err := database.GetLatestArchiveVersion(local)
+		if err != nil || latest.ArchivedHTML != "<html>new</html>" {
+			t.Errorf("expected the local version to stay the latest, got %+v, %v", latest, err)
+		}
+		archive, err := database.GetBookmarkArchiveStatus(local)
+		if err != nil || archive.ArchivedAt != day.Format(time.RFC3339) {
+			t.Errorf("expected the older version to leave the archive date alone, got %+v, %v", archive, err)
+		}
+	})
+
+	t.Run("merging again changes nothing", func(t *testing.T) {
+		again, err := MergeUserData(database, export)
+		if err != nil {
+			t.Fatalf("MergeUserData() error = %v", err)
+		}
+		if len(again.Added) != 0 || len(again.Updated) != 0 || again.Unchanged != 2 || again.Archives != 0 {
+			t.Errorf("expected nothing new, got %+v", again)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// PageMetadata is the lightweight metadata shown on bookmark cards.
+type PageMetadata struct {
+	Title       string
+	Description string
+	FaviconURL  string
+	ImageURL    string
+	SiteName    string
+}
+
+// RefreshMetadataOptions describes a metadata refresh run: either a single
+// bookmark by ID, or every bookmark whose metadata is older than Stale.
+type RefreshMetadataOptions struct {
+	// ID, if > 0, refreshes only the bookmark with this ID.
+	ID int64
+	// Stale selects bookmarks whose metadata was fetched longer ago than this
+	// (or never). If <= 0, every bookmark is refreshed.
+	Stale time.Duration
+	// MissingTitle selects bookmarks whose title is empty or just their URL
+	// instead of those with stale metadata.
+	MissingTitle bool
+	// Limit bounds the number of bookmarks refreshed in batch mode.
+	// If <= 0, refreshes all stale bookmarks.
+	Limit int
+	// Timeout is the per-page fetch deadline. If <= 0, DefaultMetadataTimeout is used.
+	Timeout time.Duration
+}
+
+// RefreshMetadataResult reports the outcome of a metadata refresh run.
+type RefreshMetadataResult struct {
+	Attempted int `json:"attempted"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// FetchMetadata downloads a page with a plain HTTP GET (no browser) and
+// parses its metadata. It is much cheaper than ArchiveBookmark and is meant
+// for keeping titles, descriptions and favicons up to date.
+func FetchMetadata(ctx context.Context, pageURL string, timeout time.Duration) (PageMetadata, error) {
+	if timeout <= 0 {
+		timeout = DefaultMetadataTimeout
+	}
+	client := newFetchClient(timeout)
+	res, err := fetchURL(ctx, client, pageURL, MaxResourceSize)
+	if err != nil {
+		return PageMetadata{}, fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+	if ct := strings.ToLower(res.contentType); !strings.Contains(ct, "html") {
+		return PageMetadata{}, fmt.Errorf("unsupported content type %q for %s", res.contentType, pageURL)
+	}
+	return ParseMetadata(string(res.data), pageURL)
+}
+
+// ParseMetadata extracts title, description, favicon and Open Graph data from
+// an HTML document. Relative URLs are resolved against baseURL. If the page
+// doesn't declare a favicon, /favicon.ico on the same host is assumed.
+func ParseMetadata(rawHTML string, baseURL string) (PageMetadata, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return PageMetadata{}, fmt.Errorf("failed to parse html: %w", err)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return PageMetadata{}, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	meta := func(selectors ...string) string {
+		for _, sel := range selectors {
+			if v, ok := doc.Find(sel).First().Attr("content"); ok && strings.TrimSpace(v) != "" {
+				return normalizeText(v)
+			}
+		}
+		return ""
+	}
+
+	m := PageMetadata{
+		Title:       normalizeText(doc.Find("title").First().Text()),
+		Description: meta(`meta[property="og:description"]`, `meta[name="description"]`, `meta[name="twitter:description"]`),
+		SiteName:    meta(`meta[property="og:site_name"]`),
+	}
+	if m.Title == "" {
+		m.Title = meta(`meta[property="og:title"]`, `meta[name="twitter:title"]`)
+	}
+	if img := meta(`meta[property="og:image"]`, `meta[property="og:image:url"]`, `meta[name="twitter:image"]`); img != "" {
+		m.ImageURL = resolveURL(base, img)
+	}
+
+	for _, rel := range []string{"icon", "shortcut icon", "apple-touch-icon"} {
+		if href, ok := doc.Find(`link[rel="` + rel + `"]`).First().Attr("href"); ok && strings.TrimSpace(href) != "" {
+			m.FaviconURL = resolveURL(base, strings.TrimSpace(href))
+			if m.FaviconURL != "" {
+				break
+			}
+		}
+	}
+	if m.FaviconURL == "" && base.Host != "" {
+		m.FaviconURL = resolveURL(base, "/favicon.ico")
+	}
+
+	return m, nil
+}
+
+// RefreshBookmarkMetadata fetches fresh metadata for a bookmark and stores it,
+// along with a copy of its favicon. The bookmark's title is only replaced when the page reports a non-empty one.
+func RefreshBookmarkMetadata(ctx context.Context, database *db.DB, b db.Bookmark, timeout time.Duration) error {
+	m, err := FetchMetadata(ctx, b.URL, timeout)
+	if err != nil {
+		return err
+	}
+	if err := database.SaveBookmarkMetadata(db.BookmarkMetadata{
+		BookmarkID:  b.ID,
+		Title:       m.Title,
+		Description: m.Description,
+		FaviconURL:  m.FaviconURL,
+		ImageURL:    m.ImageURL,
+		SiteName:    m.SiteName,
+	}); err != nil {
+		return err
+	}
+	// A missing favicon doesn't fail the refresh.
+	if m.FaviconURL != "" {
+		if err := SaveFavicon(ctx, database, b.ID, m.FaviconURL, timeout); err != nil {
+			log.Printf("Warning: failed to save favicon for id=%d: %v", b.ID, err)
+		}
+	}
+	log.Printf("Refreshed metadata for bookmark id=%d url=%s", b.ID, b.URL)
+	return nil
+}
+
+// NeedsTitle reports whether a bookmark was saved without a usable title:
+// an empty one, or just its URL, which is what the bookmarklet falls back to.
+func NeedsTitle(b db.Bookmark) bool {
+	title := strings.TrimSpace(b.Title)
+	return title == "" || strings.TrimSuffix(title, "/") == strings.TrimSuffix(b.URL, "/")
+}
+
+// TitleFetcher fills in titles for new bookmarks saved without one, fetching
+// their metadata in the background with a plain HTTP GET.
+type TitleFetcher struct {
+	db      *db.DB
+	timeout time.Duration
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewTitleFetcher creates a TitleFetcher running at most workers fetches at
+// once. Zero values fall back to DefaultMetadataTimeout and
+// DefaultTitleFetchWorkers.
+func NewTitleFetcher(database *db.DB, timeout time.Duration, workers int) *TitleFetcher {
+	if timeout <= 0 {
+		timeout = DefaultMetadataTimeout
+	}
+	if workers <= 0 {
+		workers = DefaultTitleFetchWorkers
+	}
+	return &TitleFetcher{
+		db:      database,
+		timeout: timeout,
+		sem:     make(chan struct{}, workers),
+	}
+}
+
+// Fetch starts fetching metadata for b if it NeedsTitle, and reports whether
+// it did. It doesn't wait for the fetch; failures are logged.
+func (f *TitleFetcher) Fetch(b db.Bookmark) bool {
+	if !NeedsTitle(b) {
+		return false
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.sem <- struct{}{}
+		defer func() { <-f.sem }()
+		if err := RefreshBookmarkMetadata(context.Background(), f.db, b, f.timeout); err != nil {
+			log.Printf("Failed to fetch title for bookmark id=%d url=%s: %v", b.ID, b.URL, err)
+		}
+	}()
+	return true
+}
+
+// Wait blocks until every started fetch has finished.
+func (f *TitleFetcher) Wait() {
+	f.wg.Wait()
+}
+
+// RunRefreshMetadata is the top-level metadata refresh workflow, mirroring RunArchive.
+func RunRefreshMetadata(ctx context.Context, database *db.DB, opts RefreshMetadataOptions) (RefreshMetadataResult, error) {
+	if opts.ID > 0 {
+		b, err := database.GetBookmark(opts.ID)
+		if err != nil {
+			return RefreshMetadataResult{}, err
+		}
+		if err := RefreshBookmarkMetadata(ctx, database, b, opts.Timeout); err != nil {
+			return RefreshMetadataResult{Attempted: 1, Failed: 1}, err
+		}
+		return RefreshMetadataResult{Attempted: 1, Succeeded: 1}, nil
+	}
+
+	var bookmarks []db.Bookmark
+	var err error
+	if opts.MissingTitle {
+		bookmarks, err = database.ListBookmarksMissingTitles(opts.Limit)
+	} else {
+		bookmarks, err = database.ListBookmarksWithStaleMetadata(time.Now().Add(-opts.Stale), opts.Limit)
+	}
+	if err != nil {
+		return RefreshMetadataResult{}, err
+	}
+	if len(bookmarks) == 0 {
+		if opts.MissingTitle {
+			log.Println("No bookmarks missing titles.")
+		} else {
+			log.Println("No bookmarks with stale metadata.")
+		}
+		return RefreshMetadataResult{}, nil
+	}
+
+	log.Printf("Refreshing metadata for %d bookmark(s)...", len(bookmarks))
+	var res RefreshMetadataResult
+	for _, b := range bookmarks {
+		res.Attempted++
+		if err := RefreshBookmarkMetadata(ctx, database, b, opts.Timeout); err != nil {
+			res.Failed++
+			log.Printf("Metadata refresh failed for id=%d url=%s: %v", b.ID, b.URL, err)
+			continue
+		}
+		res.Succeeded++
+	}
+
+	if res.Failed > 0 {
+		return res, fmt.Errorf("metadata refresh finished with %d failure(s)", res.Failed)
+	}
+	return res, nil
+}
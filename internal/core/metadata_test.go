@@ -0,0 +1,124 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestParseMetadata(t *testing.T) {
+	t.Run("extracts title, description, favicon and og data", func(t *testing.T) {
+		html := `<html><head>
+			<title>  How SQLite Works </title>
+			<meta name="description" content="Plain description">
+			<meta property="og:description" content="OG description">
+			<meta property="og:image" content="/img/cover.png">
+			<meta property="og:site_name" content="Example Blog">
+			<link rel="icon" href="/static/icon.svg">
+		</head><body></body></html>`
+		m, err := ParseMetadata(html, "https://blog.example.com/posts/sqlite")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := PageMetadata{
+			Title:       "How SQLite Works",
+			Description: "OG description",
+			FaviconURL:  "https://blog.example.com/static/icon.svg",
+			ImageURL:    "https://blog.example.com/img/cover.png",
+			SiteName:    "Example Blog",
+		}
+		if m != want {
+			t.Errorf("ParseMetadata() = %+v, want %+v", m, want)
+		}
+	})
+
+	t.Run("falls back to og:title and default favicon", func(t *testing.T) {
+		html := `<html><head><meta property="og:title" content="OG Title"></head></html>`
+		m, err := ParseMetadata(html, "https://example.com/a/b")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m.Title != "OG Title" {
+			t.Errorf("Title = %q, want %q", m.Title, "OG Title")
+		}
+		if m.FaviconURL != "https://example.com/favicon.ico" {
+			t.Errorf("FaviconURL = %q, want default favicon", m.FaviconURL)
+		}
+	})
+
+	t.Run("ignores javascript favicons", func(t *testing.T) {
+		html := `<html><head><link rel="icon" href="javascript:alert(1)"><link rel="apple-touch-icon" href="touch.png"></head></html>`
+		m, err := ParseMetadata(html, "https://example.com/dir/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m.FaviconURL != "https://example.com/dir/touch.png" {
+			t.Errorf("FaviconURL = %q, want apple-touch-icon", m.FaviconURL)
+		}
+	})
+}
+
+func TestNeedsTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"", true},
+		{"   ", true},
+		{"https://example.com/page", true},
+		{"https://example.com/page/", true},
+		{"Example Page", false},
+	}
+	for _, tt := range tests {
+		b := db.Bookmark{URL: "https://example.com/page", Title: tt.title}
+		if got := NeedsTitle(b); got != tt.want {
+			t.Errorf("NeedsTitle(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitleFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Fetched Title</title><meta name="description" content="About it"></head></html>`)
+	}))
+	defer srv.Close()
+
+	database := newQueueTestDB(t)
+	fetcher := NewTitleFetcher(database, time.Second, 1)
+
+	missing, err := database.AddBookmark(srv.URL+"/missing", srv.URL+"/missing")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	titled, err := database.AddBookmark(srv.URL+"/titled", "My Title")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	for _, id := range []int64{missing, titled} {
+		b, err := database.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		if started := fetcher.Fetch(b); started != (id == missing) {
+			t.Errorf("Fetch(%d) = %v", id, started)
+		}
+	}
+	fetcher.Wait()
+
+	m, err := database.GetBookmarkMetadata(missing)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if m.Title != "Fetched Title" || m.Description != "About it" {
+		t.Errorf("expected fetched metadata, got %+v", m)
+	}
+	if b, _ := database.GetBookmark(titled); b.Title != "My Title" {
+		t.Errorf("expected title to be kept, got %q", b.Title)
+	}
+}
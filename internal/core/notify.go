@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Notifier sends notifications of DB events over one channel, such as
+// webhooks (see WebhookDispatcher).
+type Notifier interface {
+	// Channel is the db.NotificationChannels entry it delivers.
+	Channel() string
+	// Notify sends event, which concerns userID (0 if unknown). It
+	// shouldn't wait for slow deliveries.
+	Notify(userID int64, event db.Event) error
+}
+
+// NotificationDispatcher sends DB events to its notifiers, each only if the
+// user the event concerns hasn't turned its channel off for that event (see
+// db.NotificationPreference). Events whose user is unknown go to every
+// notifier.
+type NotificationDispatcher struct {
+	db        *db.DB
+	notifiers []Notifier
+}
+
+// NewNotificationDispatcher creates a NotificationDispatcher; call Register
+// to start sending events to it.
+func NewNotificationDispatcher(database *db.DB, notifiers ...Notifier) *NotificationDispatcher {
+	return &NotificationDispatcher{db: database, notifiers: notifiers}
+}
+
+// Register adds event listeners that dispatch every DB event.
+func (d *NotificationDispatcher) Register() {
+	for _, kind := range db.EventKinds {
+		d.db.RegisterEventListener(kind, d.Dispatch)
+	}
+}
+
+// Dispatch sends event to every notifier the user it concerns wants it
+// from. A failing notifier doesn't stop the others; their errors are
+// returned together.
+func (d *NotificationDispatcher) Dispatch(event db.Event) error {
+	userID, err := d.db.EventUserID(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, n := range d.notifiers {
+		if userID != 0 {
+			enabled, err := d.db.NotificationEnabled(userID, event.Kind(), n.Channel())
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !enabled {
+				log.Printf("Not sending %s %s notification: user %d turned it off", event.Kind(), n.Channel(), userID)
+				continue
+			}
+		}
+		if err := n.Notify(userID, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s notification failed: %w", n.Channel(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// recordingNotifier records what it's sent.
+type recordingNotifier struct {
+	channel string
+	events  []db.EventKind
+	users   []int64
+	err     error
+}
+
+func (n *recordingNotifier) Channel() string { return n.channel }
+
+func (n *recordingNotifier) Notify(userID int64, event db.Event) error {
+	n.events = append(n.events, event.Kind())
+	n.users = append(n.users, userID)
+	return n.err
+}
+
+func TestNotificationDispatcher(t *testing.T) {
+	database := newQueueTestDB(t)
+	email := &recordingNotifier{channel: db.NotifyEmail}
+	push := &recordingNotifier{channel: db.NotifyPush, err: errors.New("push service down")}
+	d := NewNotificationDispatcher(database, push, email)
+
+	if err := database.SetNotificationPreference(db.NotificationPreference{
+		UserID: db.LocalUserID, Event: db.NotifyAllEvents, Channel: db.NotifyEmail, Enabled: false,
+	}); err != nil {
+		t.Fatalf("failed to save preference: %v", err)
+	}
+	if err := database.SetNotificationPreference(db.NotificationPreference{
+		UserID: db.LocalUserID, Event: "archive_result_saved", Channel: db.NotifyEmail, Enabled: true,
+	}); err != nil {
+		t.Fatalf("failed to save preference: %v", err)
+	}
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	err = d.Dispatch(db.BookmarkCreatedEvent{Bookmark: db.Bookmark{ID: id}})
+	if err == nil {
+		t.Error("expected the push notifier's error")
+	}
+	if err := d.Dispatch(db.ArchiveResultSavedEvent{BookmarkID: id, Status: ArchiveStatusOK}); err == nil {
+		t.Error("expected the push notifier's error")
+	}
+	// Nobody owns a missing bookmark, so every notifier gets its events.
+	_ = d.Dispatch(db.ArchiveClearedEvent{BookmarkID: id + 100})
+
+	if len(push.events) != 3 {
+		t.Errorf("expected push to get every event despite failing, got %v", push.events)
+	}
+	want := []db.EventKind{db.OnArchiveResultSavedEvent, db.OnArchiveClearedEvent}
+	if len(email.events) != 2 || email.events[0] != want[0] || email.events[1] != want[1] {
+		t.Errorf("expected email to get %v, got %v", want, email.events)
+	}
+	if email.users[0] != db.LocalUserID || email.users[1] != 0 {
+		t.Errorf("unexpected users %v", email.users)
+	}
+}
+
+func TestNotificationDispatcher_Webhooks(t *testing.T) {
+	database := newQueueTestDB(t)
+	rec := &webhookReceiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	if _, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Enabled: true}); err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+	if err := database.SetNotificationPreference(db.NotificationPreference{
+		UserID: db.LocalUserID, Event: "bookmark_deleted", Channel: db.NotifyWebhook, Enabled: false,
+	}); err != nil {
+		t.Fatalf("failed to save preference: %v", err)
+	}
+
+	webhooks := NewWebhookDispatcher(database, WebhookOptions{Backoff: time.Millisecond})
+	NewNotificationDispatcher(database, webhooks).Register()
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := database.DeleteBookmark(id); err != nil {
+		t.Fatalf("failed to delete bookmark: %v", err)
+	}
+	webhooks.Wait()
+
+	if len(rec.requests) != 1 || rec.requests[0].Header.Get(WebhookEventHeader) != "bookmark_created" {
+		t.Errorf("expected only the creation to be sent, got %d deliveries", len(rec.requests))
+	}
+}
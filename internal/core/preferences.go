@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ArchiveSettings are the per-bookmark capture choices a user can set
+// defaults for. Instance defaults come from the server's flags; a user's
+// stored db.ArchivePreferences override them field by field.
+type ArchiveSettings struct {
+	// AutoArchive queues new bookmarks for archiving as soon as they are saved.
+	AutoArchive bool `json:"auto_archive"`
+	// StripScripts removes <script> elements and inline event handlers from
+	// the archived HTML.
+	StripScripts bool `json:"strip_scripts"`
+	// MobileViewport captures the page as a phone would render it.
+	MobileViewport bool `json:"mobile_viewport"`
+	// Screenshot stores a full-page screenshot with each version.
+	Screenshot bool `json:"screenshot"`
+}
+
+// DefaultArchiveSettings are the instance defaults when no flags are given.
+func DefaultArchiveSettings() ArchiveSettings {
+	return ArchiveSettings{AutoArchive: true}
+}
+
+// Override returns s with every preference p sets replacing the
+// corresponding setting.
+func (s ArchiveSettings) Override(p db.ArchivePreferences) ArchiveSettings {
+	if p.AutoArchive != nil {
+		s.AutoArchive = *p.AutoArchive
+	}
+	if p.StripScripts != nil {
+		s.StripScripts = *p.StripScripts
+	}
+	if p.MobileViewport != nil {
+		s.MobileViewport = *p.MobileViewport
+	}
+	if p.Screenshot != nil {
+		s.Screenshot = *p.Screenshot
+	}
+	return s
+}
+
+// OverrideBookmark returns s with the options a bookmark was saved with,
+// such as from a preset, replacing the corresponding capture settings.
+func (s ArchiveSettings) OverrideBookmark(o db.ArchiveOverrides) ArchiveSettings {
+	return s.Override(db.ArchivePreferences{
+		StripScripts:   o.StripScripts,
+		MobileViewport: o.MobileViewport,
+		Screenshot:     o.Screenshot,
+	})
+}
+
+// Apply copies the capture settings onto opts.
+func (s ArchiveSettings) Apply(opts ArchiveOptions) ArchiveOptions {
+	opts.StripScripts = s.StripScripts
+	opts.MobileViewport = s.MobileViewport
+	opts.Screenshot = s.Screenshot
+	return opts
+}
+
+// ResolveArchiveSettings returns the settings for a user's bookmarks: the
+// instance defaults overridden by the user's stored preferences.
+func ResolveArchiveSettings(database *db.DB, userID int64, defaults ArchiveSettings) (ArchiveSettings, error) {
+	prefs, err := database.GetArchivePreferences(userID)
+	if err != nil {
+		return defaults, err
+	}
+	return defaults.Override(prefs), nil
+}
+
+// encodeArchiveSettings serializes settings for storage with a job.
+func encodeArchiveSettings(s ArchiveSettings) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode archive settings: %w", err)
+	}
+	return string(b), nil
+}
+
+// decodeArchiveSettings parses settings stored with a job.
+func decodeArchiveSettings(s string) (ArchiveSettings, error) {
+	var settings ArchiveSettings
+	if err := json.Unmarshal([]byte(s), &settings); err != nil {
+		return ArchiveSettings{}, fmt.Errorf("failed to decode archive settings: %w", err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestArchiveSettings_Override(t *testing.T) {
+	on, off := true, false
+	defaults := ArchiveSettings{AutoArchive: true, Screenshot: true}
+
+	if got := defaults.Override(db.ArchivePreferences{}); got != defaults {
+		t.Errorf("expected unset preferences to keep defaults, got %+v", got)
+	}
+
+	got := defaults.Override(db.ArchivePreferences{AutoArchive: &off, MobileViewport: &on})
+	want := ArchiveSettings{AutoArchive: false, MobileViewport: true, Screenshot: true}
+	if got != want {
+		t.Errorf("Override() = %+v, want %+v", got, want)
+	}
+}
+
+func TestArchiveSettings_Apply(t *testing.T) {
+	s := ArchiveSettings{StripScripts: true, Screenshot: true}
+	opts := s.Apply(ArchiveOptions{Headless: true, MobileViewport: true})
+	if !opts.Headless {
+		t.Error("expected unrelated options to be kept")
+	}
+	if !opts.StripScripts || !opts.Screenshot || opts.MobileViewport {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+}
+
+func TestResolveArchiveSettings(t *testing.T) {
+	database := newQueueTestDB(t)
+	defaults := DefaultArchiveSettings()
+
+	got, err := ResolveArchiveSettings(database, db.LocalUserID, defaults)
+	if err != nil {
+		t.Fatalf("ResolveArchiveSettings() error = %v", err)
+	}
+	if got != defaults {
+		t.Errorf("expected defaults without preferences, got %+v", got)
+	}
+
+	on := true
+	if err := database.SaveArchivePreferences(db.ArchivePreferences{UserID: db.LocalUserID, StripScripts: &on}); err != nil {
+		t.Fatalf("SaveArchivePreferences() error = %v", err)
+	}
+	got, err = ResolveArchiveSettings(database, db.LocalUserID, defaults)
+	if err != nil {
+		t.Fatalf("ResolveArchiveSettings() error = %v", err)
+	}
+	if !got.StripScripts || !got.AutoArchive {
+		t.Errorf("expected stored preference over defaults, got %+v", got)
+	}
+}
+
+func TestArchiveSettings_EncodeDecode(t *testing.T) {
+	want := ArchiveSettings{AutoArchive: true, MobileViewport: true}
+	encoded, err := encodeArchiveSettings(want)
+	if err != nil {
+		t.Fatalf("encodeArchiveSettings() error = %v", err)
+	}
+	got, err := decodeArchiveSettings(encoded)
+	if err != nil {
+		t.Fatalf("decodeArchiveSettings() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+	if _, err := decodeArchiveSettings("not json"); err == nil {
+		t.Error("expected error for invalid settings")
+	}
+}
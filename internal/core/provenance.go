@@ -0,0 +1,210 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Egress modes recorded in ArchiveProvenance.
+const (
+	EgressDirect = "direct"
+	EgressProxy  = "proxy"
+)
+
+// ArchiveProvenance records how an archive version was captured, so an
+// archive can be relied on as evidence of what a page said at a time. It is
+// stored as JSON with the version.
+type ArchiveProvenance struct {
+	BookmarkID   int64  `json:"bookmark_id"`
+	RequestedURL string `json:"requested_url"`
+	FinalURL     string `json:"final_url"`
+	// CapturedAt is RFC3339, matching the version's captured_at.
+	CapturedAt string `json:"captured_at"`
+	// UserAgent is what the page saw; ResourceUserAgent is sent when
+	// inlining images, stylesheets and fonts.
+	UserAgent         string `json:"user_agent"`
+	ResourceUserAgent string `json:"resource_user_agent"`
+	Browser           string `json:"browser,omitempty"`
+	// Engine is the archive engine used, ArchiveEngineChrome or
+	// ArchiveEngineHTTP; it is empty in records made before engines were
+	// recorded, which all used Chrome. ChromedpVersion is only set for
+	// Chrome captures.
+	Engine          string `json:"engine,omitempty"`
+	ChromedpVersion string `json:"chromedp_version,omitempty"`
+	// Egress is EgressProxy when a proxy is configured through the
+	// environment, otherwise EgressDirect.
+	Egress string `json:"egress"`
+	// Robots holds the page's <meta name="robots"> directives, if any.
+	Robots  string            `json:"robots,omitempty"`
+	Options ProvenanceOptions `json:"options"`
+}
+
+// ProvenanceOptions are the capture options in effect for an archive.
+type ProvenanceOptions struct {
+	Headless       bool    `json:"headless"`
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	WaitSelector   string  `json:"wait_selector,omitempty"`
+	// WaitStrategy and ExtraDelaySeconds are how long Chrome waited after
+	// navigating; both are empty for the HTTP engine and in records made
+	// before strategies were recorded, which all waited for network idle.
+	WaitStrategy      string  `json:"wait_strategy,omitempty"`
+	ExtraDelaySeconds float64 `json:"extra_delay_seconds,omitempty"`
+	// ScrollToBottom is set when the page was scrolled through before
+	// capture.
+	ScrollToBottom bool `json:"scroll_to_bottom"`
+	MobileViewport bool `json:"mobile_viewport"`
+	Screenshot     bool `json:"screenshot"`
+	StripScripts   bool `json:"strip_scripts"`
+	// StripTrackers is set when a blocklist removed trackers and ads.
+	StripTrackers bool `json:"strip_trackers"`
+	// Embeds is the embed mode, when not EmbedsKeep.
+	Embeds string `json:"embeds,omitempty"`
+	// DownloadRateLimit is in bytes per second; 0 means unlimited.
+	DownloadRateLimit int64 `json:"download_rate_limit"`
+	// ExtraHeaders lists the names of the extra request headers sent,
+	// comma-separated; their values may be credentials, so they aren't
+	// recorded.
+	ExtraHeaders string `json:"extra_headers,omitempty"`
+}
+
+// NewArchiveProvenance describes the capture of b that produced res.
+func NewArchiveProvenance(b db.Bookmark, res ArchiveResult, opts ArchiveOptions, capturedAt time.Time) ArchiveProvenance {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultArchiveTimeout
+	}
+	resourceUserAgent := UserAgent
+	if opts.UserAgent != "" {
+		resourceUserAgent = opts.UserAgent
+	}
+	var headers []string
+	for name := range opts.ExtraHeaders {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	var chromedp, wait string
+	var extraDelay time.Duration
+	if res.Engine != ArchiveEngineHTTP {
+		chromedp = chromedpVersion()
+		wait = waitStrategy(opts.WaitStrategy)
+		extraDelay = max(opts.ExtraDelay, 0)
+		if wait == WaitFixedDelay && extraDelay == 0 {
+			extraDelay = DefaultFixedWaitDelay
+		}
+	}
+	return ArchiveProvenance{
+		BookmarkID:        b.ID,
+		RequestedURL:      b.URL,
+		FinalURL:          res.FinalURL,
+		CapturedAt:        capturedAt.Format(time.RFC3339),
+		UserAgent:         res.UserAgent,
+		ResourceUserAgent: resourceUserAgent,
+		Browser:           res.Browser,
+		Engine:            res.Engine,
+		ChromedpVersion:   chromedp,
+		Egress:            egressMode(),
+		Robots:            metaRobots(res.HTML),
+		Options: ProvenanceOptions{
+			Headless:          opts.Headless,
+			TimeoutSeconds:    timeout.Seconds(),
+			WaitSelector:      strings.TrimSpace(opts.WaitSelector),
+			WaitStrategy:      wait,
+			ExtraDelaySeconds: extraDelay.Seconds(),
+			ScrollToBottom:    opts.ScrollToBottom && res.Engine != ArchiveEngineHTTP,
+			MobileViewport:    opts.MobileViewport,
+			Screenshot:        opts.Screenshot,
+			StripScripts:      opts.StripScripts,
+			StripTrackers:     opts.Blocklist != nil,
+			Embeds:            embedsProvenance(opts.Embeds),
+			DownloadRateLimit: DownloadRateLimit(),
+			ExtraHeaders:      strings.Join(headers, ", "),
+		},
+	}
+}
+
+// embedsProvenance is mode as recorded in provenance: empty for the default.
+func embedsProvenance(mode string) string {
+	if mode == EmbedsKeep {
+		return ""
+	}
+	return mode
+}
+
+// GetArchiveProvenance returns the provenance of a version of a bookmark's
+// archive.
+func GetArchiveProvenance(database *db.DB, bookmarkID, versionID int64) (ArchiveProvenance, error) {
+	raw, err := database.GetArchiveProvenance(bookmarkID, versionID)
+	if err != nil {
+		return ArchiveProvenance{}, err
+	}
+	var p ArchiveProvenance
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return ArchiveProvenance{}, fmt.Errorf("failed to decode archive provenance: %w", err)
+	}
+	return p, nil
+}
+
+func saveArchiveProvenance(database *db.DB, bookmarkID int64, p ArchiveProvenance) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive provenance: %w", err)
+	}
+	return database.SaveArchiveProvenance(bookmarkID, string(raw))
+}
+
+// chromedpVersion reports the chromedp module version this binary was built
+// with, or "unknown".
+func chromedpVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/chromedp/chromedp" {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// egressMode reports whether captures go out through a proxy. The resource
+// fetcher, and Chrome on Linux, honour the standard proxy environment
+// variables.
+func egressMode() string {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		if os.Getenv(name) != "" {
+			return EgressProxy
+		}
+	}
+	return EgressDirect
+}
+
+// metaRobots returns the directives of a page's robots meta tags, joined
+// with ", ".
+func metaRobots(rawHTML string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return ""
+	}
+	var directives []string
+	doc.Find("meta[name]").Each(func(_ int, s *goquery.Selection) {
+		if !strings.EqualFold(strings.TrimSpace(s.AttrOr("name", "")), "robots") {
+			return
+		}
+		if v := strings.TrimSpace(s.AttrOr("content", "")); v != "" {
+			directives = append(directives, v)
+		}
+	})
+	return strings.Join(directives, ", ")
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestNewArchiveProvenance(t *testing.T) {
+	b := db.Bookmark{ID: 7, URL: "https://example.com"}
+	res := ArchiveResult{
+		FinalURL:  "https://www.example.com/",
+		HTML:      `<html><head><meta name="Robots" content="noarchive"><meta name="robots" content="nofollow"></head></html>`,
+		UserAgent: "TestAgent/1.0",
+		Browser:   "HeadlessChrome/120.0",
+	}
+	opts := ArchiveOptions{Headless: true, MobileViewport: true, StripScripts: true, WaitSelector: " #main "}
+	capturedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	p := NewArchiveProvenance(b, res, opts, capturedAt)
+
+	if p.BookmarkID != 7 || p.RequestedURL != b.URL || p.FinalURL != res.FinalURL {
+		t.Errorf("unexpected URLs: %+v", p)
+	}
+	if p.CapturedAt != "2025-01-02T03:04:05Z" {
+		t.Errorf("unexpected captured_at %q", p.CapturedAt)
+	}
+	if p.UserAgent != "TestAgent/1.0" || p.ResourceUserAgent != UserAgent || p.Browser != "HeadlessChrome/120.0" {
+		t.Errorf("unexpected agents: %+v", p)
+	}
+	if p.ChromedpVersion == "" {
+		t.Error("expected a chromedp version")
+	}
+	if p.Robots != "noarchive, nofollow" {
+		t.Errorf("expected robots directives, got %q", p.Robots)
+	}
+	want := ProvenanceOptions{
+		Headless:       true,
+		TimeoutSeconds: DefaultArchiveTimeout.Seconds(),
+		WaitSelector:   "#main",
+		WaitStrategy:   WaitNetworkIdle,
+		MobileViewport: true,
+		StripScripts:   true,
+	}
+	if p.Options != want {
+		t.Errorf("expected options %+v, got %+v", want, p.Options)
+	}
+	if !NewArchiveProvenance(b, res, ArchiveOptions{Blocklist: DefaultBlocklist()}, capturedAt).Options.StripTrackers {
+		t.Error("expected tracker stripping to be recorded")
+	}
+	if !NewArchiveProvenance(b, res, ArchiveOptions{ScrollToBottom: true}, capturedAt).Options.ScrollToBottom {
+		t.Error("expected scrolling to be recorded")
+	}
+	if o := NewArchiveProvenance(b, res, ArchiveOptions{WaitStrategy: WaitFixedDelay}, capturedAt).Options; o.WaitStrategy != WaitFixedDelay || o.ExtraDelaySeconds != DefaultFixedWaitDelay.Seconds() {
+		t.Errorf("expected the fixed delay to be recorded, got %+v", o)
+	}
+	if o := NewArchiveProvenance(b, res, ArchiveOptions{WaitStrategy: WaitLoad, ExtraDelay: 1500 * time.Millisecond}, capturedAt).Options; o.WaitStrategy != WaitLoad || o.ExtraDelaySeconds != 1.5 {
+		t.Errorf("expected the strategy and extra delay to be recorded, got %+v", o)
+	}
+
+	opts.UserAgent = "Mozilla/5.0 Firefox/130.0"
+	opts.ExtraHeaders = Headers{"X-Token": "secret", "Accept-Language": "de"}
+	p = NewArchiveProvenance(b, res, opts, capturedAt)
+	if p.ResourceUserAgent != opts.UserAgent || p.Options.ExtraHeaders != "Accept-Language, X-Token" {
+		t.Errorf("expected the custom agent and header names, got %+v", p)
+	}
+
+	res.Engine = ArchiveEngineHTTP
+	if p = NewArchiveProvenance(b, res, opts, capturedAt); p.Engine != ArchiveEngineHTTP || p.ChromedpVersion != "" {
+		t.Errorf("expected an HTTP capture without a chromedp version, got %+v", p)
+	}
+	opts.ScrollToBottom = true
+	if p = NewArchiveProvenance(b, res, opts, capturedAt); p.Options.ScrollToBottom {
+		t.Error("expected an HTTP capture not to record scrolling")
+	}
+	if p.Options.WaitStrategy != "" {
+		t.Errorf("expected an HTTP capture not to record a wait strategy, got %q", p.Options.WaitStrategy)
+	}
+}
+
+func TestEgressMode(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		t.Setenv(name, "")
+	}
+	if got := egressMode(); got != EgressDirect {
+		t.Errorf("expected %q, got %q", EgressDirect, got)
+	}
+	t.Setenv("HTTPS_PROXY", "http://proxy.internal:3128")
+	if got := egressMode(); got != EgressProxy {
+		t.Errorf("expected %q, got %q", EgressProxy, got)
+	}
+}
+
+func TestGetArchiveProvenance(t *testing.T) {
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := database.SaveArchiveResult(id, now, &now, ArchiveStatusOK, "", "https://example.com", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	v, err := database.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+
+	want := NewArchiveProvenance(db.Bookmark{ID: id, URL: "https://example.com"}, ArchiveResult{UserAgent: "TestAgent/1.0"}, ArchiveOptions{}, now)
+	if err := saveArchiveProvenance(database, id, want); err != nil {
+		t.Fatalf("failed to save provenance: %v", err)
+	}
+	got, err := GetArchiveProvenance(database, id, v.ID)
+	if err != nil {
+		t.Fatalf("failed to get provenance: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
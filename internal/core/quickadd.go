@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// QuickAdd is the parsed form of a single free-text "omnibox" line such as:
+//
+//	https://example.com Great article #go #http ~toread
+//
+// The first URL-looking token becomes the URL, words prefixed with '#' become
+// tags, words prefixed with '~' become flags, and everything else is joined
+// into the title.
+type QuickAdd struct {
+	URL   string
+	Title string
+	Tags  []string
+	// Flags are lowercased and returned without the leading '~'. It is up to
+	// the caller to decide which flags it understands.
+	Flags []string
+}
+
+// ParseQuickAdd parses a quick-add line.
+//
+// URLs without a scheme (e.g. "example.com/post") are assumed to be https.
+// If no title words are present the URL is used as the title, matching the
+// bookmarklet's behaviour. It returns an error wrapping db.ErrInvalidURL if
+// the line doesn't contain a usable URL.
+func ParseQuickAdd(line string) (QuickAdd, error) {
+	var qa QuickAdd
+	var titleWords []string
+	seenTags := make(map[string]bool)
+	seenFlags := make(map[string]bool)
+
+	for _, tok := range strings.Fields(line) {
+		switch {
+		case strings.HasPrefix(tok, "#") && len(tok) > 1:
+			tag := db.NormalizeTag(tok)
+			if tag != "" && !seenTags[tag] {
+				seenTags[tag] = true
+				qa.Tags = append(qa.Tags, tag)
+			}
+		case strings.HasPrefix(tok, "~") && len(tok) > 1:
+			flag := strings.ToLower(tok[1:])
+			if !seenFlags[flag] {
+				seenFlags[flag] = true
+				qa.Flags = append(qa.Flags, flag)
+			}
+		case qa.URL == "" && looksLikeURL(tok):
+			qa.URL = normalizeQuickAddURL(tok)
+		default:
+			titleWords = append(titleWords, tok)
+		}
+	}
+
+	if qa.URL == "" {
+		return QuickAdd{}, fmt.Errorf("%w: no URL found in %q", db.ErrInvalidURL, line)
+	}
+	if err := db.ValidateBookmarkURL(qa.URL); err != nil {
+		return QuickAdd{}, err
+	}
+
+	qa.Title = strings.Join(titleWords, " ")
+	if qa.Title == "" {
+		qa.Title = qa.URL
+	}
+	return qa, nil
+}
+
+// HasFlag reports whether the quick-add line contained ~flag.
+func (qa QuickAdd) HasFlag(flag string) bool {
+	for _, f := range qa.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBookmark returns the bookmark the quick-add line describes. The flags
+// ~read and ~fav (or ~favorite) mark it read or favorite; ~toread is the
+// default and other flags are ignored.
+func (qa QuickAdd) NewBookmark() db.NewBookmark {
+	return db.NewBookmark{
+		URL:        qa.URL,
+		Title:      qa.Title,
+		Tags:       qa.Tags,
+		IsRead:     qa.HasFlag("read"),
+		IsFavorite: qa.HasFlag("fav") || qa.HasFlag("favorite"),
+	}
+}
+
+// looksLikeURL reports whether a token is an explicit http(s) URL or a bare
+// host such as "example.com" or "example.com/path".
+func looksLikeURL(tok string) bool {
+	lower := strings.ToLower(tok)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return true
+	}
+	if strings.Contains(tok, "://") {
+		return false
+	}
+	host := tok
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	dot := strings.LastIndex(host, ".")
+	// Require a dot with something on both sides and an alphabetic TLD, so
+	// words like "e.g." or version numbers aren't mistaken for hosts.
+	if dot <= 0 || dot == len(host)-1 {
+		return false
+	}
+	for _, r := range host[dot+1:] {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeQuickAddURL(tok string) string {
+	lower := strings.ToLower(tok)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return tok
+	}
+	return "https://" + tok
+}
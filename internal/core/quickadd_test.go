@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		want  QuickAdd
+		isErr bool
+	}{
+		{
+			name: "url, title, tags and flags",
+			line: "https://example.com Great article #go #http ~toread",
+			want: QuickAdd{
+				URL:   "https://example.com",
+				Title: "Great article",
+				Tags:  []string{"go", "http"},
+				Flags: []string{"toread"},
+			},
+		},
+		{
+			name: "tokens in any order",
+			line: "#Go Great ~ToRead https://example.com/post article",
+			want: QuickAdd{
+				URL:   "https://example.com/post",
+				Title: "Great article",
+				Tags:  []string{"go"},
+				Flags: []string{"toread"},
+			},
+		},
+		{
+			name: "bare host gets https",
+			line: "example.com/path Title",
+			want: QuickAdd{URL: "https://example.com/path", Title: "Title"},
+		},
+		{
+			name: "url only uses url as title",
+			line: "https://example.com",
+			want: QuickAdd{URL: "https://example.com", Title: "https://example.com"},
+		},
+		{
+			name: "duplicate tags and flags are collapsed",
+			line: "https://example.com #go #GO ~x ~X",
+			want: QuickAdd{URL: "https://example.com", Title: "https://example.com", Tags: []string{"go"}, Flags: []string{"x"}},
+		},
+		{
+			name: "only the first url is used",
+			line: "https://a.com see also https://b.com",
+			want: QuickAdd{URL: "https://a.com", Title: "see also https://b.com"},
+		},
+		{
+			name: "words with dots are not urls",
+			line: "https://example.com v1.2 release e.g.",
+			want: QuickAdd{URL: "https://example.com", Title: "v1.2 release e.g."},
+		},
+		{
+			name:  "missing url",
+			line:  "Great article #go",
+			isErr: true,
+		},
+		{
+			name:  "unsupported scheme",
+			line:  "ftp://example.com file",
+			isErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuickAdd(tt.line)
+			if tt.isErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				if !errors.Is(err, db.ErrInvalidURL) {
+					t.Errorf("expected ErrInvalidURL, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuickAdd(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuickAddHasFlag(t *testing.T) {
+	qa := QuickAdd{Flags: []string{"toread"}}
+	if !qa.HasFlag("toread") {
+		t.Error("expected HasFlag(toread) to be true")
+	}
+	if qa.HasFlag("private") {
+		t.Error("expected HasFlag(private) to be false")
+	}
+}
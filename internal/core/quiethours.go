@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// QuietHours is a set of recurring local-time windows during which background
+// jobs (archive workers, re-archiving, link checks) should pause, e.g. to
+// avoid running Chrome during work hours on a shared machine.
+//
+// The zero value has no windows and is never active.
+type QuietHours struct {
+	windows []quietWindow
+}
+
+type quietWindow struct {
+	days [7]bool // indexed by time.Weekday
+	// start and end are offsets from local midnight. A window with end <= start
+	// wraps past midnight into the following day.
+	start, end time.Duration
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseQuietHours parses a quiet-hours specification. Windows are separated by
+// ';' and each is an optional day list followed by a HH:MM-HH:MM time range:
+//
+//	09:00-17:00                      every day
+//	mon-fri 09:00-17:00              weekdays
+//	mon-fri 09:00-17:00; sat,sun 23:00-07:00
+//
+// A range whose end is not after its start (e.g. 23:00-07:00) wraps past
+// midnight. An empty spec disables quiet hours.
+func ParseQuietHours(spec string) (QuietHours, error) {
+	var q QuietHours
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Fields(part)
+		var w quietWindow
+		switch len(fields) {
+		case 1:
+			for i := range w.days {
+				w.days[i] = true
+			}
+		case 2:
+			days, err := parseWeekdays(fields[0])
+			if err != nil {
+				return QuietHours{}, err
+			}
+			w.days = days
+		default:
+			return QuietHours{}, fmt.Errorf("invalid quiet hours window %q", part)
+		}
+
+		rng := fields[len(fields)-1]
+		startStr, endStr, ok := strings.Cut(rng, "-")
+		if !ok {
+			return QuietHours{}, fmt.Errorf("invalid time range %q: expected HH:MM-HH:MM", rng)
+		}
+		var err error
+		if w.start, err = parseClock(startStr); err != nil {
+			return QuietHours{}, err
+		}
+		if w.end, err = parseClock(endStr); err != nil {
+			return QuietHours{}, err
+		}
+		q.windows = append(q.windows, w)
+	}
+	return q, nil
+}
+
+// parseWeekdays parses a comma-separated list of day names or ranges such as
+// "mon-fri" or "sat,sun".
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+	for _, item := range strings.Split(strings.ToLower(s), ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		start, ok := weekdayNames[from]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayNames[to]; !ok {
+				return days, fmt.Errorf("invalid day %q", to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Enabled reports whether any quiet windows are configured.
+func (q QuietHours) Enabled() bool {
+	return len(q.windows) > 0
+}
+
+// Active reports whether t falls inside a quiet window, using t's location.
+func (q QuietHours) Active(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range q.windows {
+		if w.start < w.end {
+			if w.days[today] && offset >= w.start && offset < w.end {
+				return true
+			}
+			continue
+		}
+		// Wrapping window: the evening part belongs to the listed day and the
+		// early-morning part to the day after it.
+		if w.days[today] && offset >= w.start {
+			return true
+		}
+		if w.days[yesterday] && offset < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// ResumeAt returns the first time at or after t that is outside every quiet
+// window. It returns t unchanged if t isn't in quiet hours. Windows have
+// minute resolution, so it steps forward a minute at a time; if quiet hours
+// cover the whole week it gives up after eight days and returns the zero Time.
+func (q QuietHours) ResumeAt(t time.Time) time.Time {
+	if !q.Active(t) {
+		return t
+	}
+	next := t.Truncate(time.Minute)
+	for i := 0; i < 8*24*60; i++ {
+		next = next.Add(time.Minute)
+		if !q.Active(next) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// Wait blocks while the current time is inside quiet hours. It returns
+// ctx.Err() if the context is cancelled while waiting. The job name is only
+// used for logging.
+func (q QuietHours) Wait(ctx context.Context, job string) error {
+	for {
+		now := time.Now()
+		if !q.Active(now) {
+			return nil
+		}
+		resume := q.ResumeAt(now)
+		if resume.IsZero() {
+			return fmt.Errorf("quiet hours cover the whole week; %s will never run", job)
+		}
+		log.Printf("Quiet hours: pausing %s until %s", job, resume.Format("Mon 15:04"))
+		timer := time.NewTimer(time.Until(resume))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
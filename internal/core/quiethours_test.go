@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// at returns a local time in the week starting Sunday 2025-01-05.
+func at(day time.Weekday, hour, minute int) time.Time {
+	return time.Date(2025, 1, 5+int(day), hour, minute, 0, 0, time.Local)
+}
+
+func TestParseQuietHours(t *testing.T) {
+	valid := []string{
+		"",
+		"09:00-17:00",
+		"mon-fri 09:00-17:00",
+		"mon-fri 09:00-17:00; sat,sun 23:00-07:00",
+		"fri-mon 00:00-06:00;",
+	}
+	for _, spec := range valid {
+		if _, err := ParseQuietHours(spec); err != nil {
+			t.Errorf("ParseQuietHours(%q) unexpected error: %v", spec, err)
+		}
+	}
+
+	invalid := []string{
+		"9-5",
+		"funday 09:00-17:00",
+		"mon-fri 09:00",
+		"mon 25:00-26:00",
+		"mon fri 09:00-17:00",
+	}
+	for _, spec := range invalid {
+		if _, err := ParseQuietHours(spec); err == nil {
+			t.Errorf("ParseQuietHours(%q) expected error", spec)
+		}
+	}
+}
+
+func TestQuietHoursActive(t *testing.T) {
+	q, err := ParseQuietHours("mon-fri 09:00-17:00; sat 23:00-07:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"weekday during work hours", at(time.Wednesday, 10, 30), true},
+		{"weekday at start", at(time.Monday, 9, 0), true},
+		{"weekday at end", at(time.Friday, 17, 0), false},
+		{"weekday evening", at(time.Tuesday, 20, 0), false},
+		{"weekend daytime", at(time.Sunday, 10, 0), false},
+		{"saturday late night", at(time.Saturday, 23, 30), true},
+		{"wraps into sunday morning", at(time.Sunday, 6, 59), true},
+		{"wrap does not apply to saturday morning", at(time.Saturday, 6, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := q.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+
+	if (QuietHours{}).Active(at(time.Monday, 12, 0)) {
+		t.Error("expected zero QuietHours to never be active")
+	}
+}
+
+func TestQuietHoursResumeAt(t *testing.T) {
+	q, err := ParseQuietHours("mon-fri 09:00-17:00; sat 23:00-07:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := q.ResumeAt(at(time.Monday, 10, 15)), at(time.Monday, 17, 0); !got.Equal(want) {
+		t.Errorf("ResumeAt = %v, want %v", got, want)
+	}
+	if got, want := q.ResumeAt(at(time.Saturday, 23, 30)), at(time.Saturday, 23, 30).Add(7*time.Hour+30*time.Minute); !got.Equal(want) {
+		t.Errorf("ResumeAt = %v, want %v", got, want)
+	}
+	outside := at(time.Monday, 18, 0)
+	if got := q.ResumeAt(outside); !got.Equal(outside) {
+		t.Errorf("ResumeAt outside quiet hours = %v, want %v", got, outside)
+	}
+
+	always, _ := ParseQuietHours("00:00-00:00")
+	if got := always.ResumeAt(at(time.Monday, 12, 0)); !got.IsZero() {
+		t.Errorf("expected zero time for always-quiet schedule, got %v", got)
+	}
+}
+
+func TestQuietHoursWait(t *testing.T) {
+	t.Run("returns immediately when not quiet", func(t *testing.T) {
+		if err := (QuietHours{}).Wait(context.Background(), "test"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("errors when always quiet", func(t *testing.T) {
+		always, _ := ParseQuietHours("00:00-00:00")
+		if err := always.Wait(context.Background(), "test"); err == nil {
+			t.Error("expected error for always-quiet schedule")
+		}
+	})
+}
@@ -0,0 +1,383 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Article is the reader-mode view of an archived page: the main content with
+// navigation, ads, and other page chrome stripped away.
+type Article struct {
+	// Title is the article headline (may differ from the <title> tag).
+	Title string
+	// Byline is the author line if one could be found (may be empty).
+	Byline string
+	// Content is sanitized HTML containing only the main article body.
+	Content string
+	// TextContent is the plain-text version of Content.
+	TextContent string
+}
+
+// Readability tuning constants, loosely modelled on Mozilla's Readability.js.
+const (
+	minParagraphLength = 25
+	minArticleLength   = 140
+)
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ad-break|adbox|advert|banner|breadcrumbs|combx|comment|community|cookie|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|modal|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|popup|promo|newsletter|nav`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveWeight     = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeWeight     = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|footer|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+	bylineClass        = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	titleSeparators    = regexp.MustCompile(`\s+[|\-–—»:]\s+`)
+	collapseWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// removedTags are dropped together with their contents during extraction.
+var removedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true, "object": true,
+	"embed": true, "form": true, "input": true, "button": true, "select": true,
+	"textarea": true, "svg": true, "math": true, "template": true, "canvas": true,
+	"link": true, "meta": true, "base": true, "head": true,
+}
+
+// allowedTags are kept in the sanitized output. Anything not listed here and not
+// in removedTags is unwrapped (its children are kept, the element itself dropped).
+var allowedTags = map[string]bool{
+	"p": true, "a": true, "img": true, "br": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"blockquote": true, "pre": true, "code": true, "kbd": true, "samp": true,
+	"em": true, "strong": true, "b": true, "i": true, "u": true, "s": true,
+	"sup": true, "sub": true, "small": true, "mark": true, "q": true, "cite": true,
+	"figure": true, "figcaption": true, "picture": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true, "caption": true,
+	"div": true, "span": true, "section": true, "article": true,
+}
+
+// ExtractArticle runs a readability pass over archived HTML and returns the
+// main article content.
+//
+// baseURL is used to resolve relative links and image sources so the reader
+// view works outside the original page. The returned Content is sanitized:
+// scripts, event handlers, styles, and non-http(s) links are removed, so it is
+// safe to render on the application origin.
+func ExtractArticle(rawHTML string, baseURL string) (Article, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return Article{}, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	base, _ := url.Parse(baseURL)
+
+	article := Article{
+		Title:  extractArticleTitle(doc),
+		Byline: extractByline(doc),
+	}
+
+	prepareDocument(doc)
+
+	top := findTopCandidate(doc)
+	if top == nil {
+		return article, nil
+	}
+
+	content := sanitizeArticle(top, base)
+	article.Content = content
+	if cdoc, err := goquery.NewDocumentFromReader(strings.NewReader(content)); err == nil {
+		article.TextContent = normalizeText(cdoc.Text())
+	}
+	return article, nil
+}
+
+// extractArticleTitle prefers OpenGraph/Twitter titles, then <title> with any
+// trailing " | Site Name" removed, then the first <h1>.
+func extractArticleTitle(doc *goquery.Document) string {
+	for _, sel := range []string{`meta[property="og:title"]`, `meta[name="twitter:title"]`} {
+		if v, ok := doc.Find(sel).First().Attr("content"); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	title := normalizeText(doc.Find("title").First().Text())
+	if title != "" {
+		if loc := titleSeparators.FindAllStringIndex(title, -1); len(loc) > 0 {
+			// Keep the longer side of the last separator; site names are usually short.
+			last := loc[len(loc)-1]
+			head, tail := title[:last[0]], title[last[1]:]
+			if len(strings.Fields(head)) >= 3 || len(head) >= len(tail) {
+				title = head
+			}
+		}
+		return title
+	}
+
+	return normalizeText(doc.Find("h1").First().Text())
+}
+
+// extractByline looks for common author metadata and byline markup.
+func extractByline(doc *goquery.Document) string {
+	if v, ok := doc.Find(`meta[name="author"]`).First().Attr("content"); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	if s := doc.Find(`[rel="author"], [itemprop="author"]`).First(); s.Length() > 0 {
+		if text := normalizeText(s.Text()); text != "" && len(text) < 100 {
+			return text
+		}
+	}
+
+	var byline string
+	doc.Find("[class], [id]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if !bylineClass.MatchString(class + " " + id) {
+			return true
+		}
+		text := normalizeText(s.Text())
+		if text != "" && len(text) < 100 {
+			byline = text
+			return false
+		}
+		return true
+	})
+	return byline
+}
+
+// prepareDocument strips elements that never contain article content.
+func prepareDocument(doc *goquery.Document) {
+	for tag := range removedTags {
+		doc.Find(tag).Remove()
+	}
+	doc.Find("nav, aside, footer, header").Remove()
+	doc.Find(`[hidden], [aria-hidden="true"], [role="navigation"], [role="complementary"]`).Remove()
+
+	doc.Find("body *").Each(func(_ int, s *goquery.Selection) {
+		tag := goquery.NodeName(s)
+		if tag == "body" || tag == "article" || tag == "main" {
+			return
+		}
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		match := class + " " + id
+		if unlikelyCandidates.MatchString(match) && !maybeCandidate.MatchString(match) {
+			s.Remove()
+		}
+	})
+}
+
+// findTopCandidate scores block elements by the paragraphs they contain and
+// returns the highest-scoring one.
+func findTopCandidate(doc *goquery.Document) *goquery.Selection {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+
+	initialize := func(n *html.Node) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		scores[n] = initialScore(n)
+		order = append(order, n)
+	}
+
+	doc.Find("p, pre, td, blockquote, li").Each(func(_ int, s *goquery.Selection) {
+		text := normalizeText(s.Text())
+		if len(text) < minParagraphLength {
+			return
+		}
+
+		score := 1.0
+		score += float64(strings.Count(text, ","))
+		score += math.Min(float64(len(text))/100, 3)
+
+		parent := s.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		pn := parent.Get(0)
+		initialize(pn)
+		scores[pn] += score
+
+		if grand := parent.Parent(); grand.Length() > 0 {
+			gn := grand.Get(0)
+			if gn.Type == html.ElementNode {
+				initialize(gn)
+				scores[gn] += score / 2
+			}
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		sel := doc.FindNodes(n)
+		score := scores[n] * (1 - linkDensity(sel))
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+
+	if best == nil {
+		// No scorable paragraphs; fall back to semantic containers or <body>.
+		for _, sel := range []string{"article", "main", `[role="main"]`, "body"} {
+			if s := doc.Find(sel).First(); s.Length() > 0 && len(normalizeText(s.Text())) > 0 {
+				return s
+			}
+		}
+		return nil
+	}
+
+	top := doc.FindNodes(best)
+	// If the winner is a thin wrapper, an <article>/<main> ancestor is usually the better pick.
+	if len(normalizeText(top.Text())) < minArticleLength {
+		if anc := top.ParentsFiltered("article, main").First(); anc.Length() > 0 {
+			return anc
+		}
+	}
+	return top
+}
+
+// initialScore seeds a candidate's score from its tag name and class/id weight.
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.Data {
+	case "div", "article", "main", "section":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	var classID string
+	for _, a := range n.Attr {
+		if a.Key == "class" || a.Key == "id" {
+			classID += " " + a.Val
+		}
+	}
+	if negativeWeight.MatchString(classID) {
+		score -= 25
+	}
+	if positiveWeight.MatchString(classID) {
+		score += 25
+	}
+	return score
+}
+
+// linkDensity is the fraction of a selection's text that sits inside links.
+func linkDensity(s *goquery.Selection) float64 {
+	textLen := len(normalizeText(s.Text()))
+	if textLen == 0 {
+		return 0
+	}
+	linkLen := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLen += len(normalizeText(a.Text()))
+	})
+	return float64(linkLen) / float64(textLen)
+}
+
+// sanitizeArticle renders the candidate's children through an allowlist of
+// tags and attributes, resolving relative URLs against base.
+func sanitizeArticle(s *goquery.Selection, base *url.URL) string {
+	var b strings.Builder
+	for c := s.Get(0).FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(&b, c, base)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func writeSanitized(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	tag := n.Data
+	if removedTags[tag] {
+		return
+	}
+	if !allowedTags[tag] {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeSanitized(b, c, base)
+		}
+		return
+	}
+
+	b.WriteString("<" + tag)
+	for _, a := range n.Attr {
+		val, ok := sanitizeAttr(tag, a.Key, a.Val, base)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(b, ` %s="%s"`, a.Key, html.EscapeString(val))
+	}
+	if tag == "a" {
+		b.WriteString(` rel="noopener noreferrer" target="_blank"`)
+	}
+	b.WriteString(">")
+
+	switch tag {
+	case "br", "hr", "img":
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(b, c, base)
+	}
+	b.WriteString("</" + tag + ">")
+}
+
+// sanitizeAttr decides whether an attribute survives sanitization and returns
+// its (possibly rewritten) value.
+func sanitizeAttr(tag, key, val string, base *url.URL) (string, bool) {
+	switch {
+	case tag == "a" && key == "href":
+		if strings.HasPrefix(val, "#") {
+			return val, true
+		}
+		return safeURL(val, base, false)
+	case tag == "img" && key == "src":
+		return safeURL(val, base, true)
+	case key == "alt" || key == "title":
+		return val, true
+	case (tag == "td" || tag == "th") && (key == "colspan" || key == "rowspan"):
+		return val, true
+	}
+	return "", false
+}
+
+// safeURL resolves ref against base and only allows http(s) URLs (and data:image
+// URIs when allowData is set, since archived images are already inlined).
+func safeURL(ref string, base *url.URL, allowData bool) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if allowData && strings.HasPrefix(ref, "data:image/") {
+		return ref, true
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func normalizeText(s string) string {
+	return strings.TrimSpace(collapseWhitespace.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const articleFixture = `<!DOCTYPE html>
+<html>
+<head>
+	<title>How SQLite Works | Example Blog</title>
+	<meta name="author" content="Jane Doe">
+	<script>trackEverything()</script>
+</head>
+<body>
+	<nav class="site-nav"><a href="/">Home</a> <a href="/about">About</a></nav>
+	<div class="sidebar">
+		<p>Subscribe to our newsletter for more great posts, offers, and updates every week.</p>
+	</div>
+	<div class="post-content">
+		<p>SQLite is an embedded database engine, which means it runs inside your process, and there is no server.</p>
+		<p>Pages are stored in a B-tree, and every table and index is its own tree, with rows keyed by rowid.</p>
+		<p onclick="evil()">Transactions are journaled, either with a rollback journal or with a write-ahead log.</p>
+		<p><img src="/img/btree.png" alt="B-tree diagram"> <a href="javascript:alert(1)">bad link</a> <a href="/docs">docs</a></p>
+		<script>alert("xss")</script>
+	</div>
+	<footer><p>Copyright 2024, Example Blog, all rights reserved, do not copy.</p></footer>
+</body>
+</html>`
+
+func TestExtractArticle(t *testing.T) {
+	article, err := ExtractArticle(articleFixture, "https://blog.example.com/posts/sqlite")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("extracts title without site suffix", func(t *testing.T) {
+		if article.Title != "How SQLite Works" {
+			t.Errorf("Title = %q, want %q", article.Title, "How SQLite Works")
+		}
+	})
+
+	t.Run("extracts byline", func(t *testing.T) {
+		if article.Byline != "Jane Doe" {
+			t.Errorf("Byline = %q, want %q", article.Byline, "Jane Doe")
+		}
+	})
+
+	t.Run("keeps main content", func(t *testing.T) {
+		if !strings.Contains(article.TextContent, "embedded database engine") {
+			t.Errorf("expected article text, got %q", article.TextContent)
+		}
+		if !strings.Contains(article.TextContent, "write-ahead log") {
+			t.Errorf("expected all paragraphs, got %q", article.TextContent)
+		}
+	})
+
+	t.Run("drops boilerplate", func(t *testing.T) {
+		for _, unwanted := range []string{"newsletter", "Copyright", "About"} {
+			if strings.Contains(article.TextContent, unwanted) {
+				t.Errorf("expected %q to be stripped, got %q", unwanted, article.TextContent)
+			}
+		}
+	})
+
+	t.Run("sanitizes content", func(t *testing.T) {
+		for _, unsafe := range []string{"<script", "onclick", "javascript:", "alert("} {
+			if strings.Contains(article.Content, unsafe) {
+				t.Errorf("expected %q to be removed, got %q", unsafe, article.Content)
+			}
+		}
+	})
+
+	t.Run("resolves relative URLs", func(t *testing.T) {
+		if !strings.Contains(article.Content, `src="https://blog.example.com/img/btree.png"`) {
+			t.Errorf("expected absolute image src, got %q", article.Content)
+		}
+		if !strings.Contains(article.Content, `href="https://blog.example.com/docs"`) {
+			t.Errorf("expected absolute link href, got %q", article.Content)
+		}
+	})
+}
+
+func TestExtractArticle_Fallbacks(t *testing.T) {
+	t.Run("prefers og:title", func(t *testing.T) {
+		html := `<html><head><title>Site</title><meta property="og:title" content="Real Headline"></head><body><p>short</p></body></html>`
+		article, err := ExtractArticle(html, "https://example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if article.Title != "Real Headline" {
+			t.Errorf("Title = %q, want %q", article.Title, "Real Headline")
+		}
+	})
+
+	t.Run("falls back to body without paragraphs", func(t *testing.T) {
+		html := `<html><body><span>Just a tiny page</span></body></html>`
+		article, err := ExtractArticle(html, "https://example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if article.TextContent != "Just a tiny page" {
+			t.Errorf("TextContent = %q, want %q", article.TextContent, "Just a tiny page")
+		}
+	})
+
+	t.Run("keeps data URI images", func(t *testing.T) {
+		html := `<html><body><article><p>An article paragraph that is definitely long enough to count.</p><p><img src="data:image/png;base64,AAAA"></p></article></body></html>`
+		article, err := ExtractArticle(html, "https://example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(article.Content, `src="data:image/png;base64,AAAA"`) {
+			t.Errorf("expected data URI image to be kept, got %q", article.Content)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// blobChecker is implemented by archive stores that can tell whether a blob
+// is stored without reading it.
+type blobChecker interface {
+	Has(ctx context.Context, key string) (bool, error)
+}
+
+// ReconcileOptions describes a consistency check run.
+type ReconcileOptions struct {
+	// DryRun reports what is inconsistent without repairing anything.
+	DryRun bool
+	// Now is the time job ages are measured from. If zero, time.Now() is used.
+	Now time.Time
+}
+
+// ReconcileResult reports what a consistency check found and, unless
+// DryRun, repaired.
+type ReconcileResult struct {
+	DryRun bool `json:"dry_run"`
+	// Checked is how many archives marked ok had their content checked.
+	Checked int `json:"checked"`
+	// MissingArchives are bookmarks marked archived whose latest snapshot
+	// is missing or empty. They are marked failed and queued to archive
+	// again.
+	MissingArchives []int64 `json:"missing_archives,omitempty"`
+	// OrphanJobs is how many jobs were left behind by deleted bookmarks.
+	// They are deleted.
+	OrphanJobs int `json:"orphan_jobs"`
+	// StuckJobs are the bookmarks whose archive job has been running for
+	// longer than StuckJobAge. Their jobs are queued again.
+	StuckJobs []int64 `json:"stuck_jobs,omitempty"`
+	// Failed counts repairs that failed; each is logged.
+	Failed int `json:"failed"`
+}
+
+// Found reports whether the check found anything inconsistent.
+func (r ReconcileResult) Found() bool {
+	return len(r.MissingArchives) > 0 || r.OrphanJobs > 0 || len(r.StuckJobs) > 0
+}
+
+// errMissingArchive is recorded as the archive error of bookmarks whose
+// snapshot Reconcile found missing.
+var errMissingArchive = errors.New("archived page is missing from the archive store; queued to archive again")
+
+// Reconcile finds states a crash or kill can leave behind and nothing else
+// repairs: archives marked ok whose latest snapshot has no content in the
+// archive store, jobs whose bookmark was deleted, and archive jobs still
+// running after StuckJobAge. Unless opts.DryRun, missing archives are
+// recorded as failed (so they show up in the activity log, notifications
+// and the failed-archive filters) and queued to archive again, orphaned
+// jobs are deleted and stuck jobs are queued again.
+func Reconcile(ctx context.Context, database *db.DB, opts ReconcileOptions) (ReconcileResult, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	res := ReconcileResult{DryRun: opts.DryRun}
+
+	refs, err := database.ListArchivedContent()
+	if err != nil {
+		return res, err
+	}
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Checked++
+		ok, err := archiveContentPresent(ctx, database, ref)
+		if err != nil {
+			// The store may just be unreachable; don't condemn the archive.
+			res.Failed++
+			log.Printf("Consistency check: failed to check archive of bookmark %d: %v", ref.BookmarkID, err)
+			continue
+		}
+		if ok {
+			continue
+		}
+		res.MissingArchives = append(res.MissingArchives, ref.BookmarkID)
+		if opts.DryRun {
+			log.Printf("Consistency check: archive of bookmark %d is missing (%s)", ref.BookmarkID, ref.URL)
+			continue
+		}
+		if err := requeueMissingArchive(database, ref.BookmarkID, now); err != nil {
+			res.Failed++
+			log.Printf("Consistency check: failed to requeue bookmark %d: %v", ref.BookmarkID, err)
+			continue
+		}
+		log.Printf("Consistency check: archive of bookmark %d is missing, queued to archive again (%s)", ref.BookmarkID, ref.URL)
+	}
+
+	if opts.DryRun {
+		orphans, err := database.ListOrphanJobs()
+		if err != nil {
+			return res, err
+		}
+		res.OrphanJobs = len(orphans)
+	} else {
+		n, err := database.DeleteOrphanJobs()
+		if err != nil {
+			return res, err
+		}
+		res.OrphanJobs = int(n)
+	}
+	if res.OrphanJobs > 0 {
+		log.Printf("Consistency check: %d job(s) for deleted bookmarks%s", res.OrphanJobs, reconcileVerb(opts.DryRun, "deleted"))
+	}
+
+	cutoff := now.Add(-StuckJobAge)
+	stuck, err := database.ListStuckJobs(db.JobKindArchive, cutoff)
+	if err != nil {
+		return res, err
+	}
+	for _, job := range stuck {
+		res.StuckJobs = append(res.StuckJobs, job.BookmarkID)
+	}
+	if len(stuck) > 0 {
+		if !opts.DryRun {
+			if _, err := database.RequeueStuckJobs(db.JobKindArchive, cutoff); err != nil {
+				return res, err
+			}
+		}
+		log.Printf("Consistency check: %d archive job(s) running for over %s%s", len(stuck), StuckJobAge, reconcileVerb(opts.DryRun, "queued again"))
+	}
+
+	if res.Failed > 0 {
+		return res, fmt.Errorf("consistency check finished with %d failure(s)", res.Failed)
+	}
+	return res, nil
+}
+
+// reconcileVerb finishes a log line about what Reconcile did, or would do
+// in a dry run.
+func reconcileVerb(dryRun bool, done string) string {
+	if dryRun {
+		return ""
+	}
+	return ", " + done
+}
+
+// archiveContentPresent reports whether a snapshot's HTML, and its
+// downloaded file if it has one, are stored and not empty.
+func archiveContentPresent(ctx context.Context, database *db.DB, ref db.ArchiveContentRef) (bool, error) {
+	if ref.VersionID == 0 || ref.BlobHash == "" || ref.HTMLSize == 0 {
+		return false, nil
+	}
+	for _, key := range []string{ref.BlobHash, ref.DownloadHash} {
+		if key == "" {
+			continue
+		}
+		ok, err := hasArchiveBlob(ctx, database.BlobStore(), key)
+		if err == nil && !ok {
+			// Archives captured before the store was switched are still
+			// read from the database.
+			ok, err = hasArchiveBlob(ctx, database.SQLiteBlobStore(), key)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// hasArchiveBlob reports whether store holds a non-empty blob under key,
+// reading it if the store can't check without.
+func hasArchiveBlob(ctx context.Context, store db.BlobStore, key string) (bool, error) {
+	if checker, ok := store.(blobChecker); ok {
+		return checker.Has(ctx, key)
+	}
+	data, err := store.Get(ctx, key)
+	if errors.Is(err, db.ErrBlobNotFound) {
+		return false, nil
+	}
+	return len(data) > 0, err
+}
+
+// requeueMissingArchive records that a bookmark's archive went missing and
+// queues it to archive again.
+func requeueMissingArchive(database *db.DB, bookmarkID int64, now time.Time) error {
+	if err := database.SaveArchiveFailure(bookmarkID, now, ArchiveStatusError, ArchiveErrorOther, errMissingArchive.Error()); err != nil {
+		return err
+	}
+	_, err := database.EnqueueJob(db.JobKindArchive, bookmarkID, "")
+	return err
+}
+
+// RunReconcileSchedule runs Reconcile once per interval until ctx is
+// cancelled, pausing during quiet hours.
+func RunReconcileSchedule(ctx context.Context, database *db.DB, interval time.Duration, quietHours QuietHours) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := quietHours.Wait(ctx, "consistency check"); err != nil {
+			return err
+		}
+		if _, err := Reconcile(ctx, database, ReconcileOptions{}); err != nil {
+			log.Printf("Consistency check: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
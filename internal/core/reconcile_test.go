@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestReconcile(t *testing.T) {
+	database := newQueueTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	archive := func(url string) int64 {
+		t.Helper()
+		id, err := database.AddBookmark(url, "")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := database.SaveArchiveResult(id, now, &now, ArchiveStatusOK, "", url, "<html>"+url+"</html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		return id
+	}
+	good := archive("https://good.example.com")
+	missing := archive("https://missing.example.com")
+	refs, err := database.ListArchivedContent()
+	if err != nil || len(refs) != 2 {
+		t.Fatalf("expected 2 archived bookmarks, got %d (%v)", len(refs), err)
+	}
+	if err := database.SQLiteBlobStore().Delete(ctx, refs[1].BlobHash); err != nil {
+		t.Fatalf("failed to delete blob: %v", err)
+	}
+	// Marked ok without ever saving a snapshot.
+	noVersion, err := database.AddBookmark("https://none.example.com", "")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := database.SaveArchiveResult(noVersion, now, nil, ArchiveStatusOK, "", "", ""); err != nil {
+		t.Fatalf("failed to save archive status: %v", err)
+	}
+
+	if _, err := database.EnqueueJob(db.JobKindArchive, good, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	if _, err := database.ClaimJob(db.JobKindArchive, now); err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+	if _, err := database.EnqueueJob(db.JobKindArchive, 9999, ""); err != nil {
+		t.Fatalf("failed to enqueue orphaned job: %v", err)
+	}
+	// Checks run this long after the claim find the job stuck.
+	later := now.Add(2 * StuckJobAge)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		res, err := Reconcile(ctx, database, ReconcileOptions{DryRun: true, Now: later})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		if res.Checked != 3 || !slices.Equal(res.MissingArchives, []int64{missing, noVersion}) ||
+			res.OrphanJobs != 1 || !slices.Equal(res.StuckJobs, []int64{good}) {
+			t.Errorf("unexpected dry-run result: %+v", res)
+		}
+		if a, err := database.GetBookmarkArchiveStatus(missing); err != nil || a.ArchiveStatus != ArchiveStatusOK {
+			t.Errorf("expected a dry run to leave the status alone, got %+v (%v)", a, err)
+		}
+		if orphans, _ := database.ListOrphanJobs(); len(orphans) != 1 {
+			t.Errorf("expected a dry run to keep the orphaned job, got %d", len(orphans))
+		}
+	})
+
+	t.Run("repairs", func(t *testing.T) {
+		res, err := Reconcile(ctx, database, ReconcileOptions{Now: later})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		if !res.Found() || len(res.MissingArchives) != 2 || res.OrphanJobs != 1 || len(res.StuckJobs) != 1 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		for _, id := range []int64{missing, noVersion} {
+			a, err := database.GetBookmarkArchiveStatus(id)
+			if err != nil || a.ArchiveStatus != ArchiveStatusError || a.ArchiveError != errMissingArchive.Error() {
+				t.Errorf("expected bookmark %d to be marked failed, got %+v (%v)", id, a, err)
+			}
+			if _, err := database.GetPendingJob(db.JobKindArchive, id); err != nil {
+				t.Errorf("expected bookmark %d to be queued to archive again: %v", id, err)
+			}
+		}
+		if a, _ := database.GetBookmarkArchiveStatus(good); a.ArchiveStatus != ArchiveStatusOK {
+			t.Errorf("expected the intact archive to stay ok, got %q", a.ArchiveStatus)
+		}
+		if job, err := database.GetPendingJob(db.JobKindArchive, good); err != nil || job.Status != db.JobStatusQueued {
+			t.Errorf("expected the stuck job to be queued again, got %+v (%v)", job, err)
+		}
+
+		res, err = Reconcile(ctx, database, ReconcileOptions{Now: later})
+		if err != nil || res.Found() {
+			t.Errorf("expected nothing left to repair, got %+v (%v)", res, err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ResourceCache keeps page resources fetched by the inliner so later
+// archives can reuse them; *db.DB implements it with a content-addressed
+// table shared by every bookmark.
+type ResourceCache interface {
+	// GetCachedResource returns the copy of url fetched less than maxAge
+	// ago, and false if there is none.
+	GetCachedResource(url string, maxAge time.Duration) (db.CachedResource, bool, error)
+	// PutCachedResource stores a freshly fetched copy.
+	PutCachedResource(r db.CachedResource) error
+}
+
+// cachingTransport answers GET requests from a ResourceCache while its copy
+// is younger than ttl, and stores successful responses of up to
+// MaxResourceSize bytes that don't forbid it with Cache-Control: no-store or
+// private. Redirects aren't cached, only the response each hop ends in.
+type cachingTransport struct {
+	base  http.RoundTripper
+	cache ResourceCache
+	ttl   time.Duration
+}
+
+func (t cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	url := req.URL.String()
+	if r, ok, err := t.cache.GetCachedResource(url, t.ttl); err != nil {
+		log.Printf("Warning: failed to read resource cache for %s: %v", url, err)
+	} else if ok {
+		return cachedResponse(req, r), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !cacheableResponse(resp.Header) {
+		return resp, err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxResourceSize+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if len(data) > MaxResourceSize {
+		// Too big to keep; hand back what was read and the rest unread.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return resp, nil
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("failed to close response body: %v", err)
+	}
+	if err := t.cache.PutCachedResource(db.CachedResource{URL: url, ContentType: resp.Header.Get("Content-Type"), Data: data}); err != nil {
+		log.Printf("Warning: failed to cache resource %s: %v", url, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// cachedResponse builds the response a cached resource stands in for.
+func cachedResponse(req *http.Request, r db.CachedResource) *http.Response {
+	header := http.Header{}
+	if r.ContentType != "" {
+		header.Set("Content-Type", r.ContentType)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Data)),
+		ContentLength: int64(len(r.Data)),
+		Request:       req,
+	}
+}
+
+// cacheableResponse reports whether a response may be kept in the shared
+// resource cache: not if it sets cookies or its Cache-Control says no-store
+// or private.
+func cacheableResponse(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-store", "private":
+			return false
+		}
+	}
+	return true
+}
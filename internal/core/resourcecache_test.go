@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInlineResourcesCache(t *testing.T) {
+	database := newQueueTestDB(t)
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/lib.js":
+			w.Header().Set("Content-Type", "text/javascript")
+			_, _ = w.Write([]byte("window.lib = 1;"))
+		case "/private.js":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			_, _ = w.Write([]byte("window.user = 1;"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	html := `<html><head><script src="` + server.URL + `/lib.js"></script><script src="` + server.URL + `/private.js"></script></head><body></body></html>`
+	opts := DefaultInlineOptions(server.URL + "/")
+	opts.Cache, opts.CacheTTL = database, time.Hour
+	for range 2 {
+		got, err := InlineResources(context.Background(), html, opts)
+		if err != nil {
+			t.Fatalf("failed to inline: %v", err)
+		}
+		if !strings.Contains(got, "window.lib = 1;") || !strings.Contains(got, "window.user = 1;") {
+			t.Fatalf("expected both scripts inlined, got %s", got)
+		}
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("expected the shared script to be fetched once and the private one twice, got %d requests", got)
+	}
+	if urls, _, err := database.ResourceCacheSize(); err != nil || urls != 1 {
+		t.Errorf("expected one cached resource, got %d, %v", urls, err)
+	}
+
+	t.Run("not with extra headers", func(t *testing.T) {
+		hits.Store(0)
+		opts := opts
+		opts.ExtraHeaders = Headers{"Authorization": "Bearer secret"}
+		if _, err := InlineResources(context.Background(), html, opts); err != nil {
+			t.Fatalf("failed to inline: %v", err)
+		}
+		if got := hits.Load(); got != 2 {
+			t.Errorf("expected the cache to be bypassed, got %d requests", got)
+		}
+	})
+
+	t.Run("expired entries are fetched again", func(t *testing.T) {
+		hits.Store(0)
+		opts := opts
+		opts.CacheTTL = time.Nanosecond
+		if _, err := InlineResources(context.Background(), html, opts); err != nil {
+			t.Fatalf("failed to inline: %v", err)
+		}
+		if got := hits.Load(); got != 2 {
+			t.Errorf("expected both scripts to be fetched, got %d requests", got)
+		}
+	})
+}
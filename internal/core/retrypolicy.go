@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveRetryRule is how archives failing with one archive error code are
+// retried.
+type ArchiveRetryRule struct {
+	// MaxAttempts counts the first attempt, so 1 never retries.
+	MaxAttempts int
+	// Interval is the delay before the first retry; it doubles with each
+	// further retry up to the queue's MaxBackoff. Zero uses the queue's
+	// BaseBackoff.
+	Interval time.Duration
+}
+
+// ArchiveRetryPolicy maps archive error codes (see ClassifyArchiveError) to
+// how their failures are retried. Codes it leaves out are retried up to the
+// queue's MaxAttempts, except blocked pages and client errors other than
+// 429, which aren't retried.
+type ArchiveRetryPolicy map[string]ArchiveRetryRule
+
+// ParseArchiveRetryPolicy parses a comma-separated list of
+// code=attempts[/interval] rules, e.g. "network=3/5m,timeout=3,http-4xx=1".
+func ParseArchiveRetryPolicy(spec string) (ArchiveRetryPolicy, error) {
+	p := ArchiveRetryPolicy{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(part, "=")
+		code = strings.TrimSpace(code)
+		if !ok {
+			return nil, fmt.Errorf("invalid retry rule %q: expected code=attempts[/interval]", part)
+		}
+		if !slices.Contains(ArchiveErrorCodes, code) {
+			return nil, fmt.Errorf("invalid retry rule %q: unknown error code %q (want one of %s)", part, code, strings.Join(ArchiveErrorCodes, ", "))
+		}
+		attempts, interval, hasInterval := strings.Cut(strings.TrimSpace(value), "/")
+		var rule ArchiveRetryRule
+		var err error
+		if rule.MaxAttempts, err = strconv.Atoi(attempts); err != nil || rule.MaxAttempts < 1 {
+			return nil, fmt.Errorf("invalid retry rule %q: attempts must be a whole number of at least 1", part)
+		}
+		if hasInterval {
+			if rule.Interval, err = time.ParseDuration(interval); err != nil || rule.Interval <= 0 {
+				return nil, fmt.Errorf("invalid retry rule %q: interval must be a positive duration such as 5m", part)
+			}
+		}
+		p[code] = rule
+	}
+	return p, nil
+}
+
+// rule returns how an archive that failed with err is retried, falling back
+// to maxAttempts and base for codes without a rule of their own.
+func (p ArchiveRetryPolicy) rule(err error, maxAttempts int, base time.Duration) ArchiveRetryRule {
+	r, ok := p[ClassifyArchiveError(err)]
+	if !ok {
+		r = ArchiveRetryRule{MaxAttempts: maxAttempts}
+		if !archiveErrorRetryable(err) {
+			r.MaxAttempts = 1
+		}
+	}
+	if r.Interval <= 0 {
+		r.Interval = base
+	}
+	return r
+}
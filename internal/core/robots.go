@@ -0,0 +1,280 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// ErrArchiveDisallowed is returned when ArchiveOptions.RespectRobots is set
+// and the site asks not to be archived, through robots.txt, a robots meta
+// tag or an X-Robots-Tag header. The bookmark is recorded with
+// ArchiveStatusSkipped rather than as a failure, and jobs aren't retried.
+var ErrArchiveDisallowed = errors.New("site asks not to be archived")
+
+// robotsAgent is the product token matched against robots.txt user-agent
+// lines and agent-specific robots directives.
+const robotsAgent = "bookmarkd"
+
+// maxRobotsSize bounds how much of a robots.txt is read; RFC 9309 asks
+// crawlers to parse at least 500KiB.
+const maxRobotsSize = 512 * 1024
+
+// robotsRule is one Allow or Disallow line of a robots.txt group.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules are the rules of the robots.txt groups that apply to
+// robotsAgent.
+type robotsRules []robotsRule
+
+// parseRobots returns the rules robots.txt body sets for agent: those of the
+// groups naming it, or else those of the "*" groups.
+func parseRobots(body, agent string) robotsRules {
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+	var groups []*group
+	var cur *group
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key := strings.ToLower(strings.TrimSpace(key)); key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group.
+			if cur == nil || len(cur.rules) > 0 {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			// An empty Disallow allows everything and an empty Allow is a
+			// no-op, but both still end the group's user-agent lines.
+			if cur != nil {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		}
+	}
+
+	var own, all robotsRules
+	foundOwn := false
+	for _, g := range groups {
+		for _, a := range g.agents {
+			switch a {
+			case agent:
+				own, foundOwn = append(own, g.rules...), true
+			case "*":
+				all = append(all, g.rules...)
+			}
+		}
+	}
+	if foundOwn {
+		return own
+	}
+	return all
+}
+
+// allows reports whether the rules permit path (path plus query). The
+// longest matching pattern decides, and Allow wins a tie.
+func (r robotsRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	best, allowed := -1, true
+	for _, rule := range r {
+		if rule.pattern == "" || !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best, allowed = n, rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether a robots.txt pattern matches the start of
+// path. "*" matches any run of characters and a trailing "$" anchors the
+// pattern at the end of path.
+func robotsMatch(pattern, path string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
+
+// checkRobotsTxt fetches the robots.txt of pageURL's site and returns an
+// ErrArchiveDisallowed error if it forbids robotsAgent from fetching the
+// page. A missing robots.txt (any 4xx) allows everything; server errors and
+// network failures are returned as ordinary errors so the archive is
+// retried rather than skipped.
+func checkRobotsTxt(ctx context.Context, client *http.Client, pageURL string) error {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	robotsURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+	if isInternalURL(robotsURL) {
+		return fmt.Errorf("blocked request to %w: %s", ErrInternalAddress, robotsURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", robotsURL, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("failed to fetch %s: HTTP %d", robotsURL, resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("failed to fetch %s: HTTP %d", robotsURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", robotsURL, err)
+	}
+
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !parseRobots(string(body), robotsAgent).allows(path) {
+		return fmt.Errorf("%w: %s disallows %s", ErrArchiveDisallowed, robotsURL, path)
+	}
+	return nil
+}
+
+// robotsNoArchive returns the source of a noarchive (or none) directive in
+// a captured page's robots meta tags or its X-Robots-Tag header values, or
+// "" if the page may be archived. Directives for other agents are ignored.
+func robotsNoArchive(pageHTML string, headerValues []string) string {
+	for _, v := range headerValues {
+		for _, line := range strings.Split(v, "\n") {
+			if agent, directives, ok := strings.Cut(line, ":"); ok && !strings.Contains(agent, ",") {
+				// "agent: directives" applies only to that agent.
+				if !strings.EqualFold(strings.TrimSpace(agent), robotsAgent) {
+					continue
+				}
+				line = directives
+			}
+			if hasNoArchive(line) {
+				return "X-Robots-Tag header"
+			}
+		}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return ""
+	}
+	found := ""
+	doc.Find("meta[name]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		name := strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))
+		if (name == "robots" || name == robotsAgent) && hasNoArchive(s.AttrOr("content", "")) {
+			found = fmt.Sprintf(`<meta name="%s"> tag`, name)
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// hasNoArchive reports whether a comma-separated robots directive list
+// contains noarchive or none.
+func hasNoArchive(directives string) bool {
+	for _, d := range strings.Split(directives, ",") {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "noarchive", "none":
+			return true
+		}
+	}
+	return false
+}
+
+// robotsHeaderWatcher records the X-Robots-Tag header values of the top
+// frame's latest document response. The network domain must be enabled.
+type robotsHeaderWatcher struct {
+	mu     sync.Mutex
+	header []string
+}
+
+func newRobotsHeaderWatcher(ctx context.Context) *robotsHeaderWatcher {
+	w := &robotsHeaderWatcher{}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*network.EventResponseReceived)
+		if !ok || e.Type != network.ResourceTypeDocument {
+			return
+		}
+		// The top frame's ID is the target's.
+		if c := chromedp.FromContext(ctx); c == nil || c.Target == nil || string(e.FrameID) != string(c.Target.TargetID) {
+			return
+		}
+		var values []string
+		for name, v := range e.Response.Headers {
+			if s, ok := v.(string); ok && strings.EqualFold(name, "X-Robots-Tag") {
+				values = append(values, s)
+			}
+		}
+		w.mu.Lock()
+		w.header = values
+		w.mu.Unlock()
+	})
+	return w
+}
+
+// values returns the recorded X-Robots-Tag values.
+func (w *robotsHeaderWatcher) values() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.header
+}
+
+// sameOrigin reports whether two URLs share a scheme and host, and so a
+// robots.txt.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
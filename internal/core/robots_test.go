@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `# comments are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+
+User-agent: otherbot
+Disallow: /
+
+User-agent: BookmarkD
+User-agent: friend
+Disallow: /drafts
+Allow: /drafts/*.html
+`
+	tests := []struct {
+		name  string
+		agent string
+		path  string
+		want  bool
+	}{
+		{"own group replaces star", "bookmarkd", "/private/secret", true},
+		{"own group disallows", "bookmarkd", "/drafts/today", false},
+		{"longer allow wins", "bookmarkd", "/drafts/today.html", true},
+		{"star group applies to others", "crawler", "/private/secret", false},
+		{"anchored allow matches exactly", "crawler", "/private/public", true},
+		{"anchored allow rejects suffixes", "crawler", "/private/public/page", false},
+		{"unmatched paths are allowed", "crawler", "/blog/", true},
+		{"empty path is the root", "otherbot", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRobots(body, tt.agent).allows(tt.path); got != tt.want {
+				t.Errorf("allows(%q) for %s = %v, want %v", tt.path, tt.agent, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty disallow allows everything", func(t *testing.T) {
+		if !parseRobots("User-agent: *\nDisallow:\n", robotsAgent).allows("/anything") {
+			t.Error("expected an empty Disallow to allow everything")
+		}
+	})
+	t.Run("no groups allow everything", func(t *testing.T) {
+		if !parseRobots("Sitemap: https://example.com/sitemap.xml\n", robotsAgent).allows("/") {
+			t.Error("expected a robots.txt without groups to allow everything")
+		}
+	})
+}
+
+func TestCheckRobotsTxt(t *testing.T) {
+	robots := "User-agent: *\nDisallow: /private\n"
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(robots))
+	}))
+	defer server.Close()
+	client := newFetchClient(5 * time.Second)
+
+	if err := checkRobotsTxt(context.Background(), client, server.URL+"/public/page"); err != nil {
+		t.Errorf("expected an allowed page, got %v", err)
+	}
+	if err := checkRobotsTxt(context.Background(), client, server.URL+"/private/page?q=1"); !errors.Is(err, ErrArchiveDisallowed) {
+		t.Errorf("expected ErrArchiveDisallowed, got %v", err)
+	}
+
+	status = http.StatusNotFound
+	if err := checkRobotsTxt(context.Background(), client, server.URL+"/private/page"); err != nil {
+		t.Errorf("expected a missing robots.txt to allow everything, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	err := checkRobotsTxt(context.Background(), client, server.URL+"/private/page")
+	if err == nil || errors.Is(err, ErrArchiveDisallowed) {
+		t.Errorf("expected a retryable error for a server error, got %v", err)
+	}
+}
+
+func TestRobotsNoArchive(t *testing.T) {
+	tests := []struct {
+		name   string
+		html   string
+		header []string
+		want   string
+	}{
+		{"nothing set", `<html><head><title>x</title></head></html>`, nil, ""},
+		{"robots meta noarchive", `<meta name="robots" content="noindex, NOARCHIVE">`, nil, `<meta name="robots"> tag`},
+		{"robots meta none", `<meta name="Robots" content="none">`, nil, `<meta name="robots"> tag`},
+		{"own meta", `<meta name="bookmarkd" content="noarchive">`, nil, `<meta name="bookmarkd"> tag`},
+		{"other agent meta", `<meta name="googlebot" content="noarchive">`, nil, ""},
+		{"other directives", `<meta name="robots" content="noindex, nofollow">`, nil, ""},
+		{"header", "", []string{"noarchive"}, "X-Robots-Tag header"},
+		{"own agent header", "", []string{"otherbot: noindex\nbookmarkd: noarchive"}, "X-Robots-Tag header"},
+		{"other agent header", "", []string{"googlebot: noarchive"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := robotsNoArchive(tt.html, tt.header); got != tt.want {
+				t.Errorf("robotsNoArchive() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveAndPersist_RobotsSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("User-agent: bookmarkd\nDisallow: /\n"))
+	}))
+	defer server.Close()
+
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark(server.URL+"/article", "Article")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		t.Fatalf("failed to get bookmark: %v", err)
+	}
+
+	// robots.txt is checked before Chrome is started.
+	err = ArchiveAndPersist(context.Background(), database, b, ArchiveOptions{Headless: true, RespectRobots: true})
+	if !errors.Is(err, ErrArchiveDisallowed) {
+		t.Fatalf("expected ErrArchiveDisallowed, got %v", err)
+	}
+	archive, err := database.GetBookmarkArchiveStatus(id)
+	if err != nil {
+		t.Fatalf("failed to get archive status: %v", err)
+	}
+	if archive.ArchiveStatus != ArchiveStatusSkipped || !strings.Contains(archive.ArchiveError, "robots.txt") {
+		t.Errorf("expected a skipped status with the reason, got %q (%q)", archive.ArchiveStatus, archive.ArchiveError)
+	}
+	if pending, err := database.ListBookmarksToArchive(0); err != nil || len(pending) != 0 {
+		t.Errorf("expected the skipped bookmark not to be archived again, got %d (err=%v)", len(pending), err)
+	}
+
+	res, err := RunArchive(context.Background(), database, ArchiveRunOptions{ID: id, Options: ArchiveOptions{Headless: true, RespectRobots: true}})
+	if !errors.Is(err, ErrArchiveDisallowed) || res != (ArchiveRunResult{Attempted: 1, Skipped: 1}) {
+		t.Errorf("expected one skipped bookmark, got %+v (err=%v)", res, err)
+	}
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// maxFetchRedirects is how many redirects fetch clients follow, matching
+// net/http's default.
+const maxFetchRedirects = 10
+
+// specialUseNets are the IPv4 special-use ranges net.IP's predicates don't
+// cover: "this network", shared address space (carrier-grade NAT), IETF
+// protocol assignments, the documentation and benchmarking ranges, and the
+// reserved 240.0.0.0/4, which includes the broadcast address.
+var specialUseNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24",
+		"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// isInternalIP reports whether ip is loopback, private, link-local,
+// multicast, unspecified or in another special-use range, i.e. somewhere
+// fetches must not reach.
+func isInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range specialUseNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipResolver is the part of *net.Resolver publicDialer uses.
+type ipResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// publicDialer connects only to public addresses. isInternalURL only sees
+// the hostname, so a public name that resolves to 10.0.0.1 gets past it;
+// publicDialer resolves the name itself, refuses it if any address is
+// internal, and dials the checked IP so a second lookup can't return a
+// different answer (DNS rebinding).
+type publicDialer struct {
+	dialer   net.Dialer
+	resolver ipResolver
+	// proxies are the host:port addresses of the proxies configured through
+	// the environment. They are dialed as-is since the operator chose them,
+	// even when they are on the local network.
+	proxies map[string]bool
+}
+
+// newPublicDialer returns a publicDialer using the system resolver and the
+// proxies in the environment.
+func newPublicDialer() *publicDialer {
+	d := &publicDialer{
+		dialer:   net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		resolver: net.DefaultResolver,
+		proxies:  make(map[string]bool),
+	}
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			// Like net/http, treat a bare "host:port" as an http:// proxy.
+			if u, err = url.Parse("http://" + raw); err != nil {
+				continue
+			}
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		d.proxies[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+	return d
+}
+
+// DialContext resolves addr and connects to its first reachable address,
+// failing if any of them is internal.
+func (d *publicDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if AllowInternalURLsForTesting || d.proxies[addr] {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	for _, a := range addrs {
+		if isInternalIP(a.IP) {
+			return nil, fmt.Errorf("blocked connection to %w %s for %s", ErrInternalAddress, a.IP, host)
+		}
+	}
+
+	var errs []error
+	for _, a := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchTransport is shared by fetch clients so they reuse connections. It
+// dials through publicDialer.
+var fetchTransport = newFetchTransport()
+
+func newFetchTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = newPublicDialer().DialContext
+	return t
+}
+
+// checkFetchRedirect re-checks every redirect hop against isInternalURL, so
+// a public page can't bounce a fetch to an internal hostname. Addresses the
+// hop's hostname resolves to are checked when it is dialed.
+func checkFetchRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxFetchRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+	}
+	if isInternalURL(req.URL.String()) {
+		return fmt.Errorf("blocked redirect to %w: %s", ErrInternalAddress, req.URL)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver resolves hostnames from a fixed table.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	var addrs []net.IPAddr
+	for _, ip := range r[host] {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestPublicDialer(t *testing.T) {
+	// Temporarily disable the test bypass to verify SSRF protection works
+	AllowInternalURLsForTesting = false
+	defer func() { AllowInternalURLsForTesting = true }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverAddr := strings.TrimPrefix(server.URL, "http://")
+
+	d := &publicDialer{
+		dialer: net.Dialer{Timeout: 5 * time.Second},
+		resolver: fakeResolver{
+			"rebind.example.com": {"10.0.0.1"},
+			"mixed.example.com":  {"93.184.216.34", "127.0.0.1"},
+			"mapped.example.com": {"::ffff:192.168.1.1"},
+		},
+		proxies: map[string]bool{serverAddr: true},
+	}
+
+	for _, host := range []string{"rebind.example.com", "mixed.example.com", "mapped.example.com"} {
+		t.Run("blocks "+host, func(t *testing.T) {
+			_, err := d.DialContext(context.Background(), "tcp", host+":80")
+			if err == nil {
+				t.Fatal("expected error for a name resolving to an internal address")
+			}
+			if !strings.Contains(err.Error(), "blocked") {
+				t.Errorf("error should mention blocked, got: %v", err)
+			}
+		})
+	}
+
+	t.Run("blocks names without addresses", func(t *testing.T) {
+		if _, err := d.DialContext(context.Background(), "tcp", "missing.example.com:80"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("allows configured proxies", func(t *testing.T) {
+		conn, err := d.DialContext(context.Background(), "tcp", serverAddr)
+		if err != nil {
+			t.Fatalf("expected the proxy to be reachable, got %v", err)
+		}
+		if err := conn.Close(); err != nil {
+			t.Errorf("failed to close connection: %v", err)
+		}
+	})
+}
+
+func TestCheckFetchRedirect(t *testing.T) {
+	// Temporarily disable the test bypass to verify SSRF protection works
+	AllowInternalURLsForTesting = false
+	defer func() { AllowInternalURLsForTesting = true }()
+
+	redirect := func(target string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		return req
+	}
+	via := []*http.Request{redirect("https://example.com/")}
+
+	if err := checkFetchRedirect(redirect("https://example.org/page"), via); err != nil {
+		t.Errorf("expected public redirect to be followed, got %v", err)
+	}
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data", "http://localhost:8080/admin", "http://db.internal/"} {
+		if err := checkFetchRedirect(redirect(target), via); err == nil || !strings.Contains(err.Error(), "blocked") {
+			t.Errorf("%s: expected blocked redirect, got %v", target, err)
+		}
+	}
+	long := make([]*http.Request, maxFetchRedirects)
+	if err := checkFetchRedirect(redirect("https://example.org/page"), long); err == nil {
+		t.Error("expected an error after too many redirects")
+	}
+}
+
+func TestFetchURLRedirectToInternal(t *testing.T) {
+	// The test bypass lets the first request reach the localhost server;
+	// the handler then turns it off so the redirect hop is checked.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AllowInternalURLsForTesting = false
+		http.Redirect(w, r, "http://metadata.internal/secret", http.StatusFound)
+	}))
+	defer server.Close()
+	defer func() { AllowInternalURLsForTesting = true }()
+
+	_, err := fetchURL(context.Background(), newFetchClient(5*time.Second), server.URL, 0)
+	if err == nil || !strings.Contains(err.Error(), "blocked redirect") {
+		t.Errorf("expected blocked redirect, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Sources recorded with storage samples.
+const (
+	// StorageSourceCleanup samples are taken by the server after each run of
+	// the cleanup rules.
+	StorageSourceCleanup = "cleanup"
+	// StorageSourceManual samples are taken by "bookmarkd storage record",
+	// e.g. from a backup script.
+	StorageSourceManual = "manual"
+)
+
+// StorageForecastWindow is how far back forecasts look. Growth is fitted to
+// recent samples so an old burst, such as a first big import, doesn't skew
+// it.
+const StorageForecastWindow = 12 * 7 * 24 * time.Hour
+
+// storageSizer is implemented by archive stores that can report how much
+// space they use.
+type storageSizer interface {
+	Size(ctx context.Context) (int64, error)
+}
+
+// RecordStorageSample measures the database, the archive store (when it
+// keeps files on disk) and the free space on the database's disk, and saves
+// the result.
+func RecordStorageSample(ctx context.Context, database *db.DB, source string) (db.StorageSample, error) {
+	size, err := database.DatabaseSize()
+	if err != nil {
+		return db.StorageSample{}, err
+	}
+	s := db.StorageSample{Source: source, DatabaseBytes: size, FreeBytes: -1}
+	if sizer, ok := database.BlobStore().(storageSizer); ok {
+		if s.ArchiveStoreBytes, err = sizer.Size(ctx); err != nil {
+			return db.StorageSample{}, err
+		}
+	}
+	path, err := database.DatabasePath()
+	if err != nil {
+		return db.StorageSample{}, err
+	}
+	if path != "" {
+		if free, ok := diskFree(filepath.Dir(path)); ok {
+			s.FreeBytes = free
+		}
+	}
+	return database.SaveStorageSample(s)
+}
+
+// StorageUsed returns the bytes a sample counts as used.
+func StorageUsed(s db.StorageSample) int64 {
+	return s.DatabaseBytes + s.ArchiveStoreBytes
+}
+
+// StorageForecast projects storage use from samples at a constant rate.
+type StorageForecast struct {
+	Samples   int   `json:"samples"`
+	UsedBytes int64 `json:"used_bytes"`
+	// FreeBytes is from the latest sample; -1 if unknown.
+	FreeBytes    int64   `json:"free_bytes"`
+	BytesPerWeek float64 `json:"bytes_per_week"`
+	// WeeksUntilFull is how long the free space lasts at BytesPerWeek, or
+	// -1 if storage isn't growing, the free space is unknown or there are
+	// too few samples to tell.
+	WeeksUntilFull float64 `json:"weeks_until_full"`
+}
+
+// ForecastStorage fits a straight line through samples (oldest first) by
+// least squares and projects when the free space runs out.
+func ForecastStorage(samples []db.StorageSample) StorageForecast {
+	f := StorageForecast{Samples: len(samples), FreeBytes: -1, WeeksUntilFull: -1}
+	if len(samples) == 0 {
+		return f
+	}
+	last := samples[len(samples)-1]
+	f.UsedBytes, f.FreeBytes = StorageUsed(last), last.FreeBytes
+
+	first, err := time.Parse(time.RFC3339, samples[0].TakenAt)
+	if err != nil {
+		return f
+	}
+	var n, sumX, sumY, sumXX, sumXY float64
+	for _, s := range samples {
+		at, err := time.Parse(time.RFC3339, s.TakenAt)
+		if err != nil {
+			continue
+		}
+		x := at.Sub(first).Hours() / (7 * 24)
+		y := float64(StorageUsed(s))
+		n++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	denom := n*sumXX - sumX*sumX
+	if n < 2 || denom == 0 {
+		return f
+	}
+	f.BytesPerWeek = (n*sumXY - sumX*sumY) / denom
+	if f.BytesPerWeek > 0 && f.FreeBytes >= 0 {
+		f.WeeksUntilFull = float64(f.FreeBytes) / f.BytesPerWeek
+	}
+	return f
+}
+
+// Summary describes the forecast in a sentence, e.g. "Disk full in ~6
+// weeks at the current rate (+1.2 GB/week)."
+func (f StorageForecast) Summary() string {
+	switch {
+	case f.Samples < 2:
+		return "Not enough storage samples for a forecast yet."
+	case f.BytesPerWeek <= 0:
+		return "Storage use isn't growing."
+	case f.WeeksUntilFull < 0:
+		return fmt.Sprintf("Storage is growing by %s/week.", FormatBytes(int64(f.BytesPerWeek)))
+	case f.WeeksUntilFull < 2:
+		return fmt.Sprintf("Disk full in ~%d days at the current rate (+%s/week).",
+			int(math.Round(f.WeeksUntilFull*7)), FormatBytes(int64(f.BytesPerWeek)))
+	case f.WeeksUntilFull > 520:
+		return fmt.Sprintf("Storage is growing by %s/week; the disk won't fill for over 10 years.", FormatBytes(int64(f.BytesPerWeek)))
+	default:
+		return fmt.Sprintf("Disk full in ~%d weeks at the current rate (+%s/week).",
+			int(math.Round(f.WeeksUntilFull)), FormatBytes(int64(f.BytesPerWeek)))
+	}
+}
+
+// FormatBytes formats a size with binary units, e.g. "1.5 GB".
+func FormatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n)
+	for _, unit := range []string{"KB", "MB", "GB", "TB"} {
+		v /= 1024
+		if v < 1024 || unit == "TB" {
+			return fmt.Sprintf("%.1f %s", v, unit)
+		}
+	}
+	return "" // unreachable
+}
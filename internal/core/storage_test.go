@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// TestRecordStorageSample tests measuring the database and a directory
+// archive store.
+func TestRecordStorageSample(t *testing.T) {
+	database := newQueueTestDB(t)
+	store, err := NewDirArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if err := store.Put(context.Background(), "abcdef", []byte("0123456789")); err != nil {
+		t.Fatalf("failed to put blob: %v", err)
+	}
+	database.SetBlobStore(store)
+
+	s, err := RecordStorageSample(context.Background(), database, StorageSourceManual)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if s.DatabaseBytes <= 0 || s.ArchiveStoreBytes != 10 || s.Source != StorageSourceManual {
+		t.Errorf("unexpected sample %+v", s)
+	}
+	if s.FreeBytes == 0 {
+		t.Errorf("expected free space or -1, got %d", s.FreeBytes)
+	}
+	samples, err := database.ListStorageSamples(time.Time{})
+	if err != nil || len(samples) != 1 {
+		t.Errorf("expected the sample to be saved, got %v, %v", samples, err)
+	}
+}
+
+func TestForecastStorage(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(week int, used, free int64) db.StorageSample {
+		return db.StorageSample{
+			TakenAt:       start.AddDate(0, 0, 7*week).Format(time.RFC3339),
+			DatabaseBytes: used,
+			FreeBytes:     free,
+		}
+	}
+
+	t.Run("projects when the disk fills", func(t *testing.T) {
+		f := ForecastStorage([]db.StorageSample{
+			sample(0, 1000, 7000),
+			sample(1, 1500, 6500),
+			sample(2, 2000, 6000),
+		})
+		if f.BytesPerWeek != 500 || f.UsedBytes != 2000 || f.WeeksUntilFull != 12 {
+			t.Errorf("unexpected forecast %+v", f)
+		}
+		if got := f.Summary(); !strings.Contains(got, "~12 weeks") {
+			t.Errorf("unexpected summary %q", got)
+		}
+	})
+
+	t.Run("fits noisy samples", func(t *testing.T) {
+		f := ForecastStorage([]db.StorageSample{
+			sample(0, 1000, 100000),
+			sample(1, 1300, 100000),
+			sample(2, 1900, 100000),
+			sample(3, 2200, 100000),
+		})
+		if f.BytesPerWeek < 400 || f.BytesPerWeek > 440 {
+			t.Errorf("expected about 420 bytes/week, got %v", f.BytesPerWeek)
+		}
+	})
+
+	t.Run("needs two samples", func(t *testing.T) {
+		f := ForecastStorage([]db.StorageSample{sample(0, 1000, 5000)})
+		if f.WeeksUntilFull != -1 || !strings.Contains(f.Summary(), "Not enough") {
+			t.Errorf("unexpected forecast %+v", f)
+		}
+		if f := ForecastStorage(nil); f.Samples != 0 || f.FreeBytes != -1 {
+			t.Errorf("unexpected empty forecast %+v", f)
+		}
+	})
+
+	t.Run("shrinking storage never fills", func(t *testing.T) {
+		f := ForecastStorage([]db.StorageSample{sample(0, 2000, 5000), sample(1, 1000, 6000)})
+		if f.WeeksUntilFull != -1 || f.Summary() != "Storage use isn't growing." {
+			t.Errorf("unexpected forecast %+v", f)
+		}
+	})
+
+	t.Run("unknown free space", func(t *testing.T) {
+		f := ForecastStorage([]db.StorageSample{sample(0, 0, -1), sample(1, 2048, -1)})
+		if f.WeeksUntilFull != -1 || f.Summary() != "Storage is growing by 2.0 KB/week." {
+			t.Errorf("unexpected forecast %+v %q", f, f.Summary())
+		}
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KB",
+		5 << 20:       "5.0 MB",
+		3 << 30:       "3.0 GB",
+		2048 << 30:    "2.0 TB",
+		2048000 << 30: "2000.0 TB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// downloadLimiter is the process-wide download rate limit shared by every
+// archive fetch. It is nil when downloads are unthrottled.
+var downloadLimiter atomic.Pointer[rateLimiter]
+
+// SetDownloadRateLimit caps the combined download rate of the resource
+// inliner and metadata fetcher at bytesPerSec, and caps each Chrome capture
+// at the same rate via DevTools network emulation. Zero or a negative value
+// removes the limit.
+func SetDownloadRateLimit(bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		downloadLimiter.Store(nil)
+		return
+	}
+	downloadLimiter.Store(newRateLimiter(bytesPerSec))
+}
+
+// DownloadRateLimit returns the configured limit in bytes per second, or 0 if unlimited.
+func DownloadRateLimit() int64 {
+	if l := downloadLimiter.Load(); l != nil {
+		return int64(l.rate)
+	}
+	return 0
+}
+
+// rateLimiter is a token bucket measured in bytes. The bucket holds at most
+// one second's worth of tokens, so short bursts are allowed but sustained
+// throughput converges on rate.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// burst is the largest read that should be requested before waiting.
+func (l *rateLimiter) burst() int {
+	return max(int(l.rate), 1)
+}
+
+// wait blocks until n bytes may be consumed, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// Take the tokens up front (possibly going negative) so concurrent
+	// readers queue behind each other instead of all waking at once.
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledBody paces reads from a response body through the global limiter.
+type throttledBody struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	limiter *rateLimiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > b.limiter.burst() {
+		p = p[:b.limiter.burst()]
+	}
+	n, err := b.body.Read(p)
+	if n > 0 {
+		if werr := b.limiter.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (b *throttledBody) Close() error {
+	return b.body.Close()
+}
+
+// throttledTransport applies the global download limit to response bodies.
+// The limit is looked up per request, so SetDownloadRateLimit takes effect
+// for clients that were created earlier.
+type throttledTransport struct {
+	base http.RoundTripper
+}
+
+func (t throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if l := downloadLimiter.Load(); l != nil {
+		resp.Body = &throttledBody{ctx: req.Context(), body: resp.Body, limiter: l}
+	}
+	return resp, nil
+}
+
+// newFetchClient returns an HTTP client for archive-related fetches that
+// honours the global download rate limit and only connects to public
+// addresses, including after redirects (see publicDialer).
+func newFetchClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     throttledTransport{base: fetchTransport},
+		CheckRedirect: checkFetchRedirect,
+	}
+}
+
+// fetchLimiter bounds how many fetches run at once, overall and per host.
+// A slot is held from sending a request until its response body is closed,
+// never while waiting for another fetch, so a fetch that starts others (a
+// stylesheet's images) can't deadlock the pool.
+type fetchLimiter struct {
+	all     chan struct{}
+	perHost int
+	mu      sync.Mutex
+	hosts   map[string]chan struct{}
+}
+
+func newFetchLimiter(workers, perHost int) *fetchLimiter {
+	return &fetchLimiter{
+		all:     make(chan struct{}, max(workers, 1)),
+		perHost: max(perHost, 1),
+		hosts:   map[string]chan struct{}{},
+	}
+}
+
+// acquire waits for a slot to fetch from host, or until ctx is done. The
+// host's slot is taken first, so a request queued behind a busy host
+// doesn't keep other hosts waiting. The returned function frees the slot.
+func (l *fetchLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	hostSlots, ok := l.hosts[host]
+	if !ok {
+		hostSlots = make(chan struct{}, l.perHost)
+		l.hosts[host] = hostSlots
+	}
+	l.mu.Unlock()
+
+	select {
+	case hostSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case l.all <- struct{}{}:
+	case <-ctx.Done():
+		<-hostSlots
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.all
+			<-hostSlots
+		})
+	}, nil
+}
+
+// limitedTransport runs each request in a fetchLimiter slot, which is freed
+// when the response body is closed. Redirect hops take a slot each; the
+// client closes a hop's body before following it. timeout, if set, bounds
+// each request from when it gets its slot, so time spent queued doesn't
+// count against it the way http.Client.Timeout would.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *fetchLimiter
+	timeout time.Duration
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if t.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+		req = req.WithContext(ctx)
+		free := release
+		release = func() {
+			cancel()
+			free()
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees a fetchLimiter slot when it is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
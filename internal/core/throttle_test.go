@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetDownloadRateLimit(t *testing.T) {
+	t.Cleanup(func() { SetDownloadRateLimit(0) })
+
+	SetDownloadRateLimit(2048)
+	if got := DownloadRateLimit(); got != 2048 {
+		t.Errorf("DownloadRateLimit() = %d, want 2048", got)
+	}
+	SetDownloadRateLimit(0)
+	if got := DownloadRateLimit(); got != 0 {
+		t.Errorf("DownloadRateLimit() = %d, want 0", got)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := newRateLimiter(1000)
+
+	start := time.Now()
+	// The first second's worth is available immediately; the next 500 bytes
+	// should take about half a second.
+	if err := l.wait(context.Background(), 1000); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.wait(context.Background(), 500); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected throttling delay, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 10000); err == nil {
+		t.Error("expected error from cancelled context")
+	}
+}
+
+func TestFetchClientThrottles(t *testing.T) {
+	t.Cleanup(func() { SetDownloadRateLimit(0) })
+
+	body := strings.Repeat("x", 3000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	SetDownloadRateLimit(2000)
+	client := newFetchClient(5 * time.Second)
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if string(data) != body {
+		t.Errorf("expected full body, got %d bytes", len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected throttled download to take ~0.5s, took %v", elapsed)
+	}
+}
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// timestampAuthority is the process-wide RFC 3161 TSA URL; empty means
+// archives aren't timestamped.
+var timestampAuthority atomic.Value
+
+// SetTimestampAuthority makes ArchiveAndPersist timestamp every new archive
+// version with the RFC 3161 time-stamping authority at tsaURL. An empty URL
+// turns timestamping off.
+func SetTimestampAuthority(tsaURL string) {
+	timestampAuthority.Store(tsaURL)
+}
+
+// TimestampAuthority returns the configured TSA URL, or "" if timestamping is off.
+func TimestampAuthority() string {
+	s, _ := timestampAuthority.Load().(string)
+	return s
+}
+
+// Timestamp is a TSA's signed attestation that a digest existed at Time.
+type Timestamp struct {
+	// Token is the DER-encoded TimeStampToken (a CMS SignedData); it can be
+	// checked with e.g. `openssl ts -verify -token_in`.
+	Token []byte
+	// Time is the token's genTime.
+	Time time.Time
+}
+
+var (
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// The ASN.1 structures below are the parts of RFC 3161 and RFC 5652 needed
+// to request a timestamp and read its time back. Trailing fields that aren't
+// needed are left out; encoding/asn1 ignores them when unmarshalling.
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int
+	CertReq        bool
+}
+
+type timeStampResp struct {
+	// Status is a PKIStatusInfo; only its leading status integer is read.
+	Status asn1.RawValue
+	Token  asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	// Accuracy and Ordering are read only to get past them to Nonce.
+	Accuracy accuracy `asn1:"optional"`
+	Ordering bool     `asn1:"optional"`
+	Nonce    *big.Int `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// pkiStatusNames names the PKIStatus values of a TimeStampResp.
+var pkiStatusNames = map[int]string{
+	0: "granted",
+	1: "grantedWithMods",
+	2: "rejection",
+	3: "waiting",
+	4: "revocationWarning",
+	5: "revocationNotification",
+}
+
+// RequestTimestamp asks the TSA at tsaURL to timestamp a SHA-256 digest.
+// The returned token is checked to cover digest and to echo the request's
+// random nonce, so a replayed response is refused, but its signature isn't
+// verified here.
+func RequestTimestamp(ctx context.Context, tsaURL string, digest []byte, timeout time.Duration) (Timestamp, error) {
+	if len(digest) != sha256.Size {
+		return Timestamp{}, fmt.Errorf("timestamp digest must be %d bytes, got %d", sha256.Size, len(digest))
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimestampTimeout
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("failed to generate timestamp nonce: %w", err)
+	}
+	body, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		Nonce: nonce,
+		// Ask for the TSA's certificate so the token can be verified on its own.
+		CertReq: true,
+	})
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tsaURL, bytes.NewReader(body))
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("failed to create timestamp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	req.Header.Set("User-Agent", UserAgent)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("failed to reach timestamp authority: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return Timestamp{}, fmt.Errorf("timestamp authority returned HTTP %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, MaxResourceSize))
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+	return parseTimestampResponse(raw, digest, nonce)
+}
+
+// parseTimestampResponse extracts the token from a DER TimeStampResp and
+// checks that it was granted for digest in answer to the request with nonce.
+func parseTimestampResponse(raw, digest []byte, nonce *big.Int) (Timestamp, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(raw, &resp); err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp response: %w", err)
+	}
+	var status int
+	if _, err := asn1.Unmarshal(resp.Status.Bytes, &status); err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp response status: %w", err)
+	}
+	if status != 0 && status != 1 {
+		name, ok := pkiStatusNames[status]
+		if !ok {
+			name = fmt.Sprintf("status %d", status)
+		}
+		return Timestamp{}, fmt.Errorf("timestamp request not granted: %s", name)
+	}
+	if len(resp.Token.FullBytes) == 0 {
+		return Timestamp{}, fmt.Errorf("timestamp response has no token")
+	}
+
+	info, err := parseTimestampToken(resp.Token.FullBytes)
+	if err != nil {
+		return Timestamp{}, err
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return Timestamp{}, fmt.Errorf("timestamp token doesn't cover the requested digest")
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return Timestamp{}, fmt.Errorf("timestamp token doesn't answer this request (nonce mismatch)")
+	}
+	return Timestamp{Token: resp.Token.FullBytes, Time: info.GenTime.UTC()}, nil
+}
+
+// parseTimestampToken reads the TSTInfo out of a DER TimeStampToken.
+func parseTimestampToken(token []byte) (tstInfo, error) {
+	// Explicitly tagged RawValues keep their [0] wrapper, so the wrapped
+	// element is in Bytes rather than FullBytes.
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return tstInfo{}, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return tstInfo{}, fmt.Errorf("timestamp token isn't CMS signed data")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return tstInfo{}, fmt.Errorf("invalid timestamp signed data: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return tstInfo{}, fmt.Errorf("timestamp token doesn't contain TSTInfo")
+	}
+	var octets []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &octets); err != nil {
+		return tstInfo{}, fmt.Errorf("invalid timestamp content: %w", err)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(octets, &info); err != nil {
+		return tstInfo{}, fmt.Errorf("invalid TSTInfo: %w", err)
+	}
+	return info, nil
+}
+
+// TimestampArchive timestamps the archive version of a bookmark whose HTML
+// is html, the latest such version if there are several, with the TSA at
+// tsaURL and stores the token with it. The version is found before the TSA
+// is asked, so a capture saved in the meantime doesn't get the token.
+func TimestampArchive(ctx context.Context, database *db.DB, bookmarkID int64, html string, tsaURL string) (db.ArchiveTimestamp, error) {
+	sum := sha256.Sum256([]byte(html))
+	contentHash := hex.EncodeToString(sum[:])
+	versionID, err := database.ArchiveVersionByContentHash(bookmarkID, contentHash)
+	if err != nil {
+		return db.ArchiveTimestamp{}, err
+	}
+	ts, err := RequestTimestamp(ctx, tsaURL, sum[:], DefaultTimestampTimeout)
+	if err != nil {
+		return db.ArchiveTimestamp{}, err
+	}
+	record := db.ArchiveTimestamp{
+		BookmarkID:    bookmarkID,
+		VersionID:     versionID,
+		ContentHash:   contentHash,
+		Authority:     tsaURL,
+		TimestampedAt: ts.Time.Format(time.RFC3339),
+		Token:         ts.Token,
+	}
+	if err := database.SaveArchiveTimestamp(record); err != nil {
+		return db.ArchiveTimestamp{}, err
+	}
+	return record, nil
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTimestampResponse builds a DER TimeStampResp with the given PKIStatus
+// whose (unsigned) token attests to digest at genTime, answering the
+// request with nonce.
+func fakeTimestampResponse(t *testing.T, status int, digest []byte, genTime time.Time, nonce *big.Int) []byte {
+	t.Helper()
+	mustMarshal := func(v any) []byte {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal %T: %v", v, err)
+		}
+		return b
+	}
+
+	info := mustMarshal(tstInfo{
+		Version: 1,
+		Policy:  asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		SerialNumber: big.NewInt(42),
+		GenTime:      genTime,
+		Accuracy:     accuracy{Seconds: 1},
+		Nonce:        nonce,
+	})
+	sd := mustMarshal(signedData{
+		Version:          3,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: mustMarshal(info)},
+		},
+	})
+	token := mustMarshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+	resp := timeStampResp{Status: asn1.RawValue{FullBytes: mustMarshal(struct{ Status int }{status})}}
+	if status == 0 {
+		resp.Token = asn1.RawValue{FullBytes: token}
+	}
+	return mustMarshal(resp)
+}
+
+// newFakeTSA serves timestamps for whatever digest is requested, unless
+// status or digest override it.
+func newFakeTSA(t *testing.T, status int, digest []byte, genTime time.Time) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/timestamp-query" {
+			http.Error(w, "bad content type", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req timeStampReq
+		if _, err := asn1.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !req.CertReq || req.Nonce == nil {
+			http.Error(w, "expected certReq and a nonce", http.StatusBadRequest)
+			return
+		}
+		d := req.MessageImprint.HashedMessage
+		if digest != nil {
+			d = digest
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(fakeTimestampResponse(t, status, d, genTime, req.Nonce))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRequestTimestamp(t *testing.T) {
+	sum := sha256.Sum256([]byte("<html></html>"))
+	genTime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	t.Run("granted", func(t *testing.T) {
+		srv := newFakeTSA(t, 0, nil, genTime)
+		ts, err := RequestTimestamp(context.Background(), srv.URL, sum[:], time.Second)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !ts.Time.Equal(genTime) || len(ts.Token) == 0 {
+			t.Errorf("unexpected timestamp: %v, %d token bytes", ts.Time, len(ts.Token))
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		srv := newFakeTSA(t, 2, nil, genTime)
+		_, err := RequestTimestamp(context.Background(), srv.URL, sum[:], time.Second)
+		if err == nil || !strings.Contains(err.Error(), "rejection") {
+			t.Errorf("expected rejection error, got %v", err)
+		}
+	})
+
+	t.Run("token for another digest", func(t *testing.T) {
+		other := sha256.Sum256([]byte("other"))
+		srv := newFakeTSA(t, 0, other[:], genTime)
+		if _, err := RequestTimestamp(context.Background(), srv.URL, sum[:], time.Second); err == nil {
+			t.Error("expected error for mismatched digest")
+		}
+	})
+
+	t.Run("replayed response", func(t *testing.T) {
+		// A response captured for an earlier request, with its nonce.
+		replayed := fakeTimestampResponse(t, 0, sum[:], genTime, big.NewInt(7))
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(replayed)
+		}))
+		t.Cleanup(srv.Close)
+		if _, err := RequestTimestamp(context.Background(), srv.URL, sum[:], time.Second); err == nil || !strings.Contains(err.Error(), "nonce") {
+			t.Errorf("expected nonce mismatch error, got %v", err)
+		}
+	})
+
+	t.Run("wrong digest size", func(t *testing.T) {
+		if _, err := RequestTimestamp(context.Background(), "http://127.0.0.1:1", []byte("short"), time.Second); err == nil {
+			t.Error("expected error for short digest")
+		}
+	})
+}
+
+func TestTimestampArchive(t *testing.T) {
+	database := newQueueTestDB(t)
+	genTime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	srv := newFakeTSA(t, 0, nil, genTime)
+
+	id, err := database.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	html := "<html><body>Evidence</body></html>"
+	now := time.Now()
+	if err := database.SaveArchiveResult(id, now, &now, ArchiveStatusOK, "", "https://example.com", html); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	if _, err := TimestampArchive(context.Background(), database, id, "<html>something else</html>", srv.URL); err == nil {
+		t.Error("expected error timestamping content the version doesn't hold")
+	}
+
+	if _, err := TimestampArchive(context.Background(), database, id, html, srv.URL); err != nil {
+		t.Fatalf("failed to timestamp archive: %v", err)
+	}
+	v, err := database.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if !v.HasTimestamp {
+		t.Error("expected version to have a timestamp")
+	}
+	ts, err := database.GetArchiveTimestamp(id, v.ID)
+	if err != nil {
+		t.Fatalf("failed to get timestamp: %v", err)
+	}
+	sum := sha256.Sum256([]byte(html))
+	if ts.ContentHash != hex.EncodeToString(sum[:]) || ts.Authority != srv.URL || ts.TimestampedAt != "2025-03-04T05:06:07Z" {
+		t.Errorf("unexpected timestamp: %+v", ts)
+	}
+	info, err := parseTimestampToken(ts.Token)
+	if err != nil || !info.GenTime.Equal(genTime) {
+		t.Errorf("expected stored token to parse, got %+v, %v", info, err)
+	}
+
+	t.Run("newer capture during the request", func(t *testing.T) {
+		second := "<html><body>Second capture</body></html>"
+		secondAt := now.Add(time.Minute)
+		if err := database.SaveArchiveResult(id, secondAt, &secondAt, ArchiveStatusOK, "", "https://example.com", second); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		hashed, err := database.GetLatestArchiveVersion(id)
+		if err != nil {
+			t.Fatalf("failed to get version: %v", err)
+		}
+		tsa := newFakeTSA(t, 0, nil, genTime)
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			thirdAt := now.Add(2 * time.Minute)
+			if err := database.SaveArchiveResult(id, thirdAt, &thirdAt, ArchiveStatusOK, "", "https://example.com", "<html>third</html>"); err != nil {
+				t.Errorf("failed to save archive: %v", err)
+			}
+			tsa.Config.Handler.ServeHTTP(w, r)
+		}))
+		t.Cleanup(slow.Close)
+
+		if _, err := TimestampArchive(context.Background(), database, id, second, slow.URL); err != nil {
+			t.Fatalf("failed to timestamp archive: %v", err)
+		}
+		if _, err := database.GetArchiveTimestamp(id, hashed.ID); err != nil {
+			t.Errorf("expected the hashed version to get the token, got %v", err)
+		}
+		if latest, _ := database.GetLatestArchiveVersion(id); latest.ID == hashed.ID || latest.HasTimestamp {
+			t.Errorf("expected the newer capture to have no timestamp, got %+v", latest)
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// ErrNoArticleText is returned by GenerateArticleAudio for a bookmark whose
+// archive has no reader-mode text to read aloud.
+var ErrNoArticleText = errors.New("no article text to read aloud")
+
+// TTSBackend reads text aloud.
+type TTSBackend interface {
+	// Synthesize returns the spoken text and its MIME type.
+	Synthesize(ctx context.Context, text string) ([]byte, string, error)
+}
+
+// Text-to-speech backends selectable with TTSConfig.Backend.
+const (
+	TTSBackendNone    = "none"
+	TTSBackendCommand = "command"
+	TTSBackendAPI     = "api"
+)
+
+// Defaults for TTSBackendAPI, as OpenAI's speech endpoint names them.
+const (
+	DefaultTTSModel = "tts-1"
+	DefaultTTSVoice = "alloy"
+)
+
+// TTSConfig selects and configures a text-to-speech backend.
+type TTSConfig struct {
+	// Backend is one of TTSBackendNone (default), TTSBackendCommand or
+	// TTSBackendAPI.
+	Backend string
+	// Command is the program TTSBackendCommand runs, with its arguments
+	// separated by spaces (no shell is involved). It is given the text on
+	// stdin and writes the audio to stdout, as `piper --output_file -` or
+	// `espeak-ng --stdin --stdout` do.
+	Command string
+	// APIURL is the OpenAI-compatible speech endpoint TTSBackendAPI posts
+	// to, such as https://api.openai.com/v1/audio/speech.
+	APIURL string
+	// APIKey, if set, is sent as a bearer token.
+	APIKey string
+	// Model and Voice default to DefaultTTSModel and DefaultTTSVoice.
+	Model string
+	Voice string
+	// Timeout bounds one API request; 0 means DefaultTTSTimeout.
+	Timeout time.Duration
+}
+
+// OpenTTSBackend builds the backend described by cfg, or returns nil if
+// text-to-speech is turned off.
+func OpenTTSBackend(cfg TTSConfig) (TTSBackend, error) {
+	switch cfg.Backend {
+	case "", TTSBackendNone:
+		return nil, nil
+	case TTSBackendCommand:
+		args := strings.Fields(cfg.Command)
+		if len(args) == 0 {
+			return nil, errors.New("command text-to-speech backend requires a command")
+		}
+		return commandTTS{args: args}, nil
+	case TTSBackendAPI:
+		if cfg.APIURL == "" {
+			return nil, errors.New("api text-to-speech backend requires a URL")
+		}
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTTSTimeout
+		}
+		t := apiTTS{
+			url:    cfg.APIURL,
+			key:    cfg.APIKey,
+			model:  cfg.Model,
+			voice:  cfg.Voice,
+			client: &http.Client{Timeout: timeout},
+		}
+		if t.model == "" {
+			t.model = DefaultTTSModel
+		}
+		if t.voice == "" {
+			t.voice = DefaultTTSVoice
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unknown text-to-speech backend %q (want %s, %s or %s)",
+			cfg.Backend, TTSBackendNone, TTSBackendCommand, TTSBackendAPI)
+	}
+}
+
+// commandTTS runs a local speech synthesizer.
+type commandTTS struct {
+	args []string
+}
+
+func (c commandTTS) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	audio, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, "", fmt.Errorf("%s failed: %w: %s", c.args[0], err, msg)
+		}
+		return nil, "", fmt.Errorf("%s failed: %w", c.args[0], err)
+	}
+	return audio, audioContentType(audio), nil
+}
+
+// apiTTS posts to an OpenAI-compatible speech endpoint. Those cap the text
+// of one request, so longer articles are sent a few sentences at a time and
+// the MP3s joined; MP3 frames play back to back without a container.
+type apiTTS struct {
+	url, key     string
+	model, voice string
+	client       *http.Client
+}
+
+func (a apiTTS) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	var audio []byte
+	for _, chunk := range splitTTSText(text, MaxTTSChunkLength) {
+		part, err := a.speak(ctx, chunk)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(audio)+len(part) > MaxAudioSize {
+			return nil, "", fmt.Errorf("audio exceeds %d bytes", MaxAudioSize)
+		}
+		audio = append(audio, part...)
+	}
+	return audio, "audio/mpeg", nil
+}
+
+func (a apiTTS) speak(ctx context.Context, text string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{
+		"model":           a.model,
+		"voice":           a.voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode speech request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	if a.key != "" {
+		req.Header.Set("Authorization", "Bearer "+a.key)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("speech request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("speech request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, MaxAudioSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read speech response: %w", err)
+	}
+	if len(audio) > MaxAudioSize {
+		return nil, fmt.Errorf("audio exceeds %d bytes", MaxAudioSize)
+	}
+	return audio, nil
+}
+
+// GenerateArticleAudio reads the reader-mode text of a bookmark's latest
+// archive version aloud with backend and saves the audio alongside it.
+func GenerateArticleAudio(ctx context.Context, database *db.DB, backend TTSBackend, bookmarkID int64) (db.ArchiveAudio, error) {
+	bookmark, err := database.GetBookmark(bookmarkID)
+	if err != nil {
+		return db.ArchiveAudio{}, err
+	}
+	readable, err := database.GetBookmarkReadable(bookmarkID)
+	if err != nil {
+		return db.ArchiveAudio{}, err
+	}
+	if strings.TrimSpace(readable.TextContent) == "" {
+		return db.ArchiveAudio{}, ErrNoArticleText
+	}
+	title := readable.Title
+	if title == "" {
+		title = bookmark.Title
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTTSTimeout)
+	defer cancel()
+	data, mimeType, err := backend.Synthesize(ctx, articleSpeechText(title, readable.Byline, readable.TextContent))
+	if err != nil {
+		return db.ArchiveAudio{}, fmt.Errorf("failed to read article aloud: %w", err)
+	}
+	if len(data) == 0 {
+		return db.ArchiveAudio{}, errors.New("text-to-speech backend returned no audio")
+	}
+	if len(data) > MaxAudioSize {
+		return db.ArchiveAudio{}, fmt.Errorf("audio exceeds %d bytes", MaxAudioSize)
+	}
+
+	audio := db.ArchiveAudio{MIMEType: mimeType, Size: int64(len(data)), Data: data}
+	if err := database.SaveArchiveAudio(bookmarkID, audio); err != nil {
+		return db.ArchiveAudio{}, err
+	}
+	return audio, nil
+}
+
+// articleSpeechText is what is read aloud for an article: its title and
+// byline, then its text with the layout's runs of blank lines and spaces
+// collapsed, cut at a sentence within MaxTTSTextLength bytes.
+func articleSpeechText(title, byline, text string) string {
+	var paragraphs []string
+	for _, p := range []string{title, byline} {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	out := strings.Join(paragraphs, "\n\n")
+	if len(out) <= MaxTTSTextLength {
+		return out
+	}
+	out = out[:MaxTTSTextLength]
+	for !utf8.RuneStart(out[len(out)-1]) {
+		out = out[:len(out)-1]
+	}
+	if i := strings.LastIndexAny(out, ".!?"); i > 0 {
+		out = out[:i+1]
+	}
+	return out
+}
+
+// splitTTSText splits text into chunks of at most limit bytes, breaking
+// between sentences where it can and between words where it must.
+func splitTTSText(text string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+	for _, sentence := range splitSentences(text) {
+		if cur.Len()+len(sentence) > limit {
+			flush()
+		}
+		for len(sentence) > limit {
+			cut := strings.LastIndexAny(sentence[:limit], " \n")
+			if cut <= 0 {
+				cut = limit
+				for !utf8.RuneStart(sentence[cut]) {
+					cut--
+				}
+			}
+			cur.WriteString(sentence[:cut])
+			flush()
+			sentence = sentence[cut:]
+		}
+		cur.WriteString(sentence)
+	}
+	flush()
+	return chunks
+}
+
+// splitSentences splits text after each sentence-ending punctuation mark
+// followed by a space, and after each line break.
+func splitSentences(text string) []string {
+	var out []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\n',
+			(c == '.' || c == '!' || c == '?') && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n'):
+			out = append(out, text[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(text) {
+		out = append(out, text[start:])
+	}
+	return out
+}
+
+// audioContentType sniffs the MIME type of audio a synthesizer wrote.
+func audioContentType(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return "audio/wav"
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "audio/mpeg"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	}
+	return http.DetectContentType(data)
+}
+
+// AudioExtension returns the file extension, with its dot, podcast players
+// expect for audio of the given MIME type, or "" if there isn't one.
+func AudioExtension(mimeType string) string {
+	switch mimeType {
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/wav", "audio/wave", "audio/x-wav":
+		return ".wav"
+	case "audio/ogg", "application/ogg":
+		return ".ogg"
+	case "audio/flac":
+		return ".flac"
+	}
+	return ""
+}
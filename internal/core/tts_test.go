@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// fakeTTS records what it was asked to read and returns it as "audio".
+type fakeTTS struct {
+	text string
+}
+
+func (f *fakeTTS) Synthesize(_ context.Context, text string) ([]byte, string, error) {
+	f.text = text
+	return []byte("ID3" + text), "audio/mpeg", nil
+}
+
+func TestOpenTTSBackend(t *testing.T) {
+	for _, backend := range []string{"", TTSBackendNone} {
+		if b, err := OpenTTSBackend(TTSConfig{Backend: backend}); err != nil || b != nil {
+			t.Errorf("OpenTTSBackend(%q) = %v, %v, want nil, nil", backend, b, err)
+		}
+	}
+	for _, cfg := range []TTSConfig{
+		{Backend: TTSBackendCommand},
+		{Backend: TTSBackendAPI},
+		{Backend: "festival"},
+	} {
+		if _, err := OpenTTSBackend(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestSplitTTSText(t *testing.T) {
+	text := "One two. Three four! Five six?\nSeven eight nine ten eleven twelve."
+	chunks := splitTTSText(text, 20)
+	want := []string{"One two. Three four!", "Five six?", "Seven eight nine", "ten eleven twelve."}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("splitTTSText() = %q, want %q", chunks, want)
+	}
+	for _, c := range splitTTSText(strings.Repeat("é", 30), 7) {
+		if len(c) > 7 || !strings.HasPrefix(c, "é") {
+			t.Errorf("expected chunks cut between runes, got %q", c)
+		}
+	}
+}
+
+func TestArticleSpeechText(t *testing.T) {
+	got := articleSpeechText("Title", "", "  First   line\n\n\n Second line. ")
+	if got != "Title\n\nFirst line\n\nSecond line." {
+		t.Errorf("articleSpeechText() = %q", got)
+	}
+	long := articleSpeechText("", "", strings.Repeat("A sentence. ", MaxTTSTextLength/10))
+	if len(long) > MaxTTSTextLength || !strings.HasSuffix(long, ".") {
+		t.Errorf("expected long text cut at a sentence, got %d bytes ending %q", len(long), long[len(long)-10:])
+	}
+}
+
+func TestCommandTTS(t *testing.T) {
+	backend, err := OpenTTSBackend(TTSConfig{Backend: TTSBackendCommand, Command: "cat"})
+	if err != nil {
+		t.Fatalf("OpenTTSBackend() error = %v", err)
+	}
+	audio, mimeType, err := backend.Synthesize(context.Background(), "RIFF\x00\x00\x00\x00WAVEfmt ")
+	if err != nil {
+		t.Fatalf("Synthesize() error = %v", err)
+	}
+	if mimeType != "audio/wav" || !strings.HasPrefix(string(audio), "RIFF") {
+		t.Errorf("expected the command's output as WAV, got %q, %q", mimeType, audio)
+	}
+
+	backend, _ = OpenTTSBackend(TTSConfig{Backend: TTSBackendCommand, Command: "false"})
+	if _, _, err := backend.Synthesize(context.Background(), "text"); err == nil {
+		t.Error("expected an error for a failing command")
+	}
+}
+
+func TestAPITTS(t *testing.T) {
+	var inputs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req["model"] != DefaultTTSModel || req["voice"] != "nova" {
+			http.Error(w, "bad model or voice", http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, req["input"])
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("[" + req["input"][:1] + "]"))
+	}))
+	defer ts.Close()
+
+	backend, err := OpenTTSBackend(TTSConfig{Backend: TTSBackendAPI, APIURL: ts.URL, APIKey: "secret", Voice: "nova"})
+	if err != nil {
+		t.Fatalf("OpenTTSBackend() error = %v", err)
+	}
+	text := strings.Repeat("a", MaxTTSChunkLength-10) + ". " + strings.Repeat("b", 10) + "."
+	audio, mimeType, err := backend.Synthesize(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Synthesize() error = %v", err)
+	}
+	if len(inputs) != 2 || string(audio) != "[a][b]" || mimeType != "audio/mpeg" {
+		t.Errorf("expected two requests joined, got %d requests and %q", len(inputs), audio)
+	}
+
+	backend, _ = OpenTTSBackend(TTSConfig{Backend: TTSBackendAPI, APIURL: ts.URL})
+	if _, _, err := backend.Synthesize(context.Background(), "text"); err == nil {
+		t.Error("expected an error for a rejected request")
+	}
+}
+
+func TestGenerateArticleAudio(t *testing.T) {
+	database := newQueueTestDB(t)
+	id, err := database.AddBookmark("https://example.com/essay", "Essay")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	backend := &fakeTTS{}
+
+	now := time.Now()
+	if err := database.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/essay", "<html>essay</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if _, err := GenerateArticleAudio(context.Background(), database, backend, id); !errors.Is(err, ErrNoArticleText) {
+		t.Errorf("expected ErrNoArticleText without reader text, got %v", err)
+	}
+
+	if err := database.SaveBookmarkReadable(db.BookmarkReadable{BookmarkID: id, Byline: "A. Writer", TextContent: "The whole essay."}); err != nil {
+		t.Fatalf("failed to save readable: %v", err)
+	}
+	audio, err := GenerateArticleAudio(context.Background(), database, backend, id)
+	if err != nil {
+		t.Fatalf("GenerateArticleAudio() error = %v", err)
+	}
+	if backend.text != "Essay\n\nA. Writer\n\nThe whole essay." {
+		t.Errorf("expected the title, byline and text to be read, got %q", backend.text)
+	}
+	v, err := database.GetLatestArchiveVersion(id)
+	if err != nil {
+		t.Fatalf("failed to get latest version: %v", err)
+	}
+	saved, err := database.GetArchiveAudio(id, v.ID)
+	if err != nil {
+		t.Fatalf("failed to get audio: %v", err)
+	}
+	if saved.MIMEType != "audio/mpeg" || string(saved.Data) != string(audio.Data) {
+		t.Errorf("expected the audio to be saved, got %+v", saved)
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultUpdateFeed is the Atom feed of bookmarkd's releases that update
+// checks read.
+const DefaultUpdateFeed = "https://github.com/SecKatie/bookmarkd/releases.atom"
+
+// Release is a bookmarkd release found by an UpdateChecker.
+type Release struct {
+	Version   string    `json:"version"`
+	URL       string    `json:"url,omitempty"`
+	Published time.Time `json:"published,omitzero"`
+	// Summary is the start of the release notes as plain text, at most
+	// MaxChangelogSummary bytes.
+	Summary string `json:"summary,omitempty"`
+}
+
+// UpdateChecker looks for releases newer than the running one in a release
+// feed and remembers the newest it found, for the web UI to tell admins
+// about. Checks are opt-in: nothing is fetched until Check or Run is called.
+// A nil UpdateChecker never finds an update.
+type UpdateChecker struct {
+	feedURL string
+	current string
+	client  *http.Client
+
+	mu     sync.Mutex
+	latest *Release
+}
+
+// NewUpdateChecker returns a checker comparing the releases of feedURL
+// (DefaultUpdateFeed if empty) against current, normally
+// ReadBuildInfo().Version.
+func NewUpdateChecker(feedURL, current string) *UpdateChecker {
+	if feedURL == "" {
+		feedURL = DefaultUpdateFeed
+	}
+	return &UpdateChecker{
+		feedURL: feedURL,
+		current: current,
+		client:  &http.Client{Timeout: DefaultUpdateCheckTimeout},
+	}
+}
+
+// Available returns the newest release found that is newer than the
+// running one, if any.
+func (c *UpdateChecker) Available() (Release, bool) {
+	if c == nil {
+		return Release{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.latest == nil {
+		return Release{}, false
+	}
+	return *c.latest, true
+}
+
+// Check reads the release feed and returns the newest release that is newer
+// than the running one, if any. Pre-releases are ignored, and so is every
+// release when the running version isn't one (such as "dev").
+func (c *UpdateChecker) Check(ctx context.Context) (Release, bool, error) {
+	releases, err := c.fetch(ctx)
+	if err != nil {
+		return Release{}, false, err
+	}
+	var newest *Release
+	if current, ok := parseReleaseVersion(c.current); ok {
+		best := current
+		for i, r := range releases {
+			if v, ok := parseReleaseVersion(r.Version); ok && !v.pre && v.newerThan(best) {
+				newest, best = &releases[i], v
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.latest = newest
+	c.mu.Unlock()
+	if newest == nil {
+		return Release{}, false, nil
+	}
+	return *newest, true, nil
+}
+
+// Run checks for updates now and then every interval until ctx is done,
+// logging new releases as they are found.
+func (c *UpdateChecker) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var announced string
+	for {
+		release, ok, err := c.Check(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Update check: %v", err)
+		case ok && release.Version != announced:
+			log.Printf("bookmarkd %s is available (running %s): %s", release.Version, c.current, release.URL)
+			announced = release.Version
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseFeed is the part of an Atom release feed, as GitHub serves one,
+// that update checks read.
+type releaseFeed struct {
+	Entries []struct {
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Links   []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Content string `xml:"content"`
+	} `xml:"entry"`
+}
+
+// fetch reads the releases listed in the feed.
+func (c *UpdateChecker) fetch(ctx context.Context) ([]Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release feed request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "application/atom+xml, application/xml;q=0.9")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release feed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch release feed: %s", resp.Status)
+	}
+
+	var feed releaseFeed
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, MaxReleaseFeedSize)).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to parse release feed: %w", err)
+	}
+	releases := make([]Release, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		r := Release{Version: strings.TrimSpace(e.Title), Summary: changelogSummary(e.Content)}
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				r.URL = l.Href
+				break
+			}
+		}
+		// GitHub titles releases by name; the tag is at the end of the link.
+		if tag := path.Base(r.URL); strings.Contains(r.URL, "/releases/tag/") && tag != "" {
+			r.Version = tag
+		}
+		if published, err := time.Parse(time.RFC3339, e.Updated); err == nil {
+			r.Published = published
+		}
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+// changelogSummary turns HTML release notes into a line of plain text cut
+// at a word within MaxChangelogSummary bytes.
+func changelogSummary(notes string) string {
+	doc, err := html.Parse(strings.NewReader(notes))
+	if err != nil {
+		return ""
+	}
+	// Text nodes are joined with spaces so headings and list items don't
+	// run together.
+	var words []string
+	for n := range doc.Descendants() {
+		if n.Type == html.TextNode {
+			words = append(words, n.Data)
+		}
+	}
+	text := normalizeText(strings.Join(words, " "))
+	if len(text) <= MaxChangelogSummary {
+		return text
+	}
+	text = text[:MaxChangelogSummary-len("…")]
+	for !utf8.RuneStart(text[len(text)-1]) {
+		text = text[:len(text)-1]
+	}
+	if i := strings.LastIndexByte(text, ' '); i > 0 {
+		text = text[:i]
+	}
+	return text + "…"
+}
+
+// releaseVersion is a parsed vMAJOR.MINOR.PATCH[-pre] version.
+type releaseVersion struct {
+	parts [3]int
+	pre   bool
+}
+
+// parseReleaseVersion parses versions such as "v1.2.3", "1.2" or
+// "v1.3.0-rc.1"; Go pseudo-versions parse as pre-releases.
+func parseReleaseVersion(s string) (releaseVersion, bool) {
+	var v releaseVersion
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	v.pre = hasPre && pre != ""
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return v, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// newerThan reports whether v is a later version than o. A release is newer
+// than the pre-releases of the same version.
+func (v releaseVersion) newerThan(o releaseVersion) bool {
+	for i := range v.parts {
+		if v.parts[i] != o.parts[i] {
+			return v.parts[i] > o.parts[i]
+		}
+	}
+	return !v.pre && o.pre
+}
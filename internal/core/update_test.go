@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testReleaseFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <updated>2026-09-01T12:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/SecKatie/bookmarkd/releases/tag/v1.3.0-rc.1"/>
+    <title>Release candidate</title>
+    <content type="html">&lt;p&gt;Testing.&lt;/p&gt;</content>
+  </entry>
+  <entry>
+    <updated>2026-08-01T12:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/SecKatie/bookmarkd/releases/tag/v1.2.0"/>
+    <title>Podcasts</title>
+    <content type="html">&lt;h2&gt;What's new&lt;/h2&gt;&lt;ul&gt;&lt;li&gt;Read articles aloud&lt;/li&gt;&lt;/ul&gt;</content>
+  </entry>
+  <entry>
+    <updated>2026-07-01T12:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/SecKatie/bookmarkd/releases/tag/v1.1.0"/>
+    <title>v1.1.0</title>
+    <content type="html">&lt;p&gt;Fixes.&lt;/p&gt;</content>
+  </entry>
+</feed>`
+
+func TestUpdateChecker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		_, _ = w.Write([]byte(testReleaseFeed))
+	}))
+	defer ts.Close()
+
+	var nilChecker *UpdateChecker
+	if _, ok := nilChecker.Available(); ok {
+		t.Error("expected no update from a nil checker")
+	}
+
+	checker := NewUpdateChecker(ts.URL, "v1.1.0")
+	release, ok, err := checker.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !ok || release.Version != "v1.2.0" || release.Summary != "What's new Read articles aloud" {
+		t.Errorf("expected v1.2.0 without the release candidate, got %+v", release)
+	}
+	if !strings.HasSuffix(release.URL, "/tag/v1.2.0") || release.Published.Month() != 8 {
+		t.Errorf("expected the release link and date, got %+v", release)
+	}
+	if available, ok := checker.Available(); !ok || available.Version != "v1.2.0" {
+		t.Errorf("expected the release to be remembered, got %+v", available)
+	}
+
+	for _, current := range []string{"v1.2.0", "1.2.1", "dev"} {
+		if release, ok, err := NewUpdateChecker(ts.URL, current).Check(context.Background()); err != nil || ok {
+			t.Errorf("expected no update for %s, got %+v, %v", current, release, err)
+		}
+	}
+	if _, ok, _ := NewUpdateChecker(ts.URL, "v1.2.0-rc.2").Check(context.Background()); !ok {
+		t.Error("expected a release to update its release candidates")
+	}
+}
+
+func TestChangelogSummary(t *testing.T) {
+	long := "<p>" + strings.Repeat("word ", MaxChangelogSummary) + "</p>"
+	got := changelogSummary(long)
+	if len(got) > MaxChangelogSummary || !strings.HasSuffix(got, "word…") {
+		t.Errorf("expected the notes cut at a word, got %d bytes ending %q", len(got), got[len(got)-10:])
+	}
+}
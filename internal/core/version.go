@@ -0,0 +1,48 @@
+package core
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the release this binary was built from. Release builds set it
+// with -ldflags "-X github.com/seckatie/bookmarkd/internal/core.Version=v1.2.3";
+// without it, ReadBuildInfo falls back to the module version `go install`
+// records, then to "dev".
+var Version = ""
+
+// BuildInfo describes the running binary.
+type BuildInfo struct {
+	Version string `json:"version"`
+	// Commit and CommitTime are the VCS revision the binary was built from,
+	// when the build recorded one; Modified reports uncommitted changes.
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	GoVersion  string `json:"go_version"`
+}
+
+// ReadBuildInfo reports the version and build of the running binary.
+func ReadBuildInfo() BuildInfo {
+	b := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		if b.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				b.Commit = s.Value
+			case "vcs.time":
+				b.CommitTime = s.Value
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = "dev"
+	}
+	return b
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // screenshots may also be PNGs
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// VisualDiff is the result of comparing two screenshots region by region.
+type VisualDiff struct {
+	// Regions is how many regions were compared, and ChangedRegions how
+	// many of them differ by more than VisualChangeThreshold bits.
+	Regions        int
+	ChangedRegions int
+	// Distance is the largest perceptual-hash distance between two regions,
+	// from 0 (alike) to 64.
+	Distance int
+	// FirstChanged is the index of the first changed region, or -1.
+	FirstChanged int
+}
+
+// Changed reports whether any region looks different.
+func (d VisualDiff) Changed() bool { return d.ChangedRegions > 0 }
+
+// CompareScreenshots compares two screenshots perceptually. Each is cut,
+// from the top, into regions half as tall as it is wide (at most
+// MaxVisualDiffRegions of them), and each region's perceptual hash (pHash)
+// is compared with that of the region in the same place in the other. A
+// region only one screenshot reaches counts as completely changed. Since
+// pHash works on a shrunken grayscale copy, re-encoding and small rendering
+// differences don't register as changes.
+func CompareScreenshots(before, after []byte) (VisualDiff, error) {
+	a, err := decodeScreenshot(before)
+	if err != nil {
+		return VisualDiff{}, err
+	}
+	b, err := decodeScreenshot(after)
+	if err != nil {
+		return VisualDiff{}, err
+	}
+	return compareImages(a, b), nil
+}
+
+// DetectVisualChange compares the screenshot of a bookmark's newest archive
+// version with that of the latest earlier version that has one, and emits an
+// ArchiveVisualChangeEvent, with before and after images of the first changed
+// region, if they differ. It returns a zero VisualDiff if there's nothing to
+// compare.
+func DetectVisualChange(database *db.DB, bookmarkID int64) (VisualDiff, error) {
+	versions, err := database.ListArchiveVersions(bookmarkID)
+	if err != nil {
+		return VisualDiff{}, err
+	}
+	if len(versions) < 2 || !versions[0].HasScreenshot {
+		return VisualDiff{}, nil
+	}
+	i := slices.IndexFunc(versions[1:], func(v db.ArchiveVersion) bool { return v.HasScreenshot })
+	if i < 0 {
+		return VisualDiff{}, nil
+	}
+	current, previous := versions[0], versions[i+1]
+
+	before, err := loadScreenshot(database, bookmarkID, previous.ID)
+	if err != nil {
+		return VisualDiff{}, err
+	}
+	after, err := loadScreenshot(database, bookmarkID, current.ID)
+	if err != nil {
+		return VisualDiff{}, err
+	}
+	diff := compareImages(before, after)
+	if !diff.Changed() {
+		return diff, nil
+	}
+	database.EmitArchiveVisualChange(db.ArchiveVisualChangeEvent{
+		BookmarkID:        bookmarkID,
+		VersionID:         current.ID,
+		PreviousVersionID: previous.ID,
+		Regions:           diff.Regions,
+		ChangedRegions:    diff.ChangedRegions,
+		Distance:          diff.Distance,
+		Before:            regionPreview(before, diff.FirstChanged),
+		After:             regionPreview(after, diff.FirstChanged),
+	})
+	return diff, nil
+}
+
+func loadScreenshot(database *db.DB, bookmarkID, versionID int64) (image.Image, error) {
+	data, err := database.GetArchiveScreenshot(bookmarkID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeScreenshot(data)
+}
+
+func decodeScreenshot(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	if img.Bounds().Empty() {
+		return nil, fmt.Errorf("failed to decode screenshot: empty image")
+	}
+	return img, nil
+}
+
+func compareImages(a, b image.Image) VisualDiff {
+	ra, rb := screenshotRegions(a), screenshotRegions(b)
+	diff := VisualDiff{Regions: max(len(ra), len(rb)), FirstChanged: -1}
+	for i := range diff.Regions {
+		distance := 64
+		if i < len(ra) && i < len(rb) {
+			distance = bits.OnesCount64(phash(a, ra[i]) ^ phash(b, rb[i]))
+		}
+		diff.Distance = max(diff.Distance, distance)
+		if distance > VisualChangeThreshold {
+			diff.ChangedRegions++
+			if diff.FirstChanged < 0 {
+				diff.FirstChanged = i
+			}
+		}
+	}
+	return diff
+}
+
+// screenshotRegions cuts img into regions half as tall as it is wide, from
+// the top; the last may be shorter.
+func screenshotRegions(img image.Image) []image.Rectangle {
+	bounds := img.Bounds()
+	height := max(bounds.Dx()/2, 1)
+	var regions []image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y && len(regions) < MaxVisualDiffRegions; y += height {
+		regions = append(regions, image.Rect(bounds.Min.X, y, bounds.Max.X, min(y+height, bounds.Max.Y)))
+	}
+	return regions
+}
+
+const (
+	// phashSize is the side of the grayscale grid a region is shrunk to.
+	phashSize = 32
+	// phashBits is the side of the block of lowest frequencies kept.
+	phashBits = 8
+)
+
+// dctCosines[u][x] is the DCT-II basis cos((2x+1)uπ/2N) for N = phashSize.
+var dctCosines = func() (t [phashBits][phashSize]float64) {
+	for u := range phashBits {
+		for x := range phashSize {
+			t[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * phashSize))
+		}
+	}
+	return t
+}()
+
+// phash returns the 64-bit perceptual hash of region r of img: the region is
+// shrunk to a phashSize square of grays, and each of the lowest 8x8 DCT
+// frequencies sets a bit if it's above their median.
+func phash(img image.Image, r image.Rectangle) uint64 {
+	var grid [phashSize][phashSize]float64
+	for cy := range phashSize {
+		y0, y1 := cellSpan(r.Min.Y, r.Dy(), cy)
+		for cx := range phashSize {
+			x0, x1 := cellSpan(r.Min.X, r.Dx(), cx)
+			// Sample at most 4x4 pixels of each cell; averaging every pixel
+			// of a full-page screenshot is slow and changes nothing.
+			var sum float64
+			var n int
+			for y := y0; y < y1; y += max((y1-y0)/4, 1) {
+				for x := x0; x < x1; x += max((x1-x0)/4, 1) {
+					sum += float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+					n++
+				}
+			}
+			grid[cy][cx] = sum / float64(n)
+		}
+	}
+
+	var coeffs [phashBits * phashBits]float64
+	for v := range phashBits {
+		for u := range phashBits {
+			var s float64
+			for y := range phashSize {
+				for x := range phashSize {
+					s += grid[y][x] * dctCosines[u][x] * dctCosines[v][y]
+				}
+			}
+			coeffs[v*phashBits+u] = s
+		}
+	}
+	// The DC term is the region's average brightness; leave it out of the
+	// median so it doesn't skew it.
+	sorted := slices.Clone(coeffs[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// cellSpan returns the pixel span of cell i of phashSize cells over length
+// pixels from start; it's never empty.
+func cellSpan(start, length, i int) (int, int) {
+	lo := start + i*length/phashSize
+	hi := start + (i+1)*length/phashSize
+	lo = min(lo, start+length-1)
+	return lo, max(hi, lo+1)
+}
+
+// regionPreview returns region i of img scaled down to at most
+// VisualDiffPreviewWidth wide, as a JPEG, or nil if img doesn't reach it.
+func regionPreview(img image.Image, i int) []byte {
+	regions := screenshotRegions(img)
+	if i < 0 || i >= len(regions) {
+		return nil
+	}
+	r := regions[i]
+	width := min(r.Dx(), VisualDiffPreviewWidth)
+	height := max(r.Dy()*width/r.Dx(), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			dst.Set(x, y, img.At(r.Min.X+x*r.Dx()/width, r.Min.Y+y*r.Dy()/height))
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: DefaultScreenshotQuality}); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
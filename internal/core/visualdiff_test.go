@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// testScreenshot draws a white page of the given height with dark stripes,
+// plus a filled box in the region at index box (if it's not negative), and
+// encodes it as a JPEG.
+func testScreenshot(t *testing.T, width, height, box int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := color.RGBA{255, 255, 255, 255}
+			if (y/20)%3 == 0 && x > width/10 && x < width*9/10 {
+				c = color.RGBA{40, 40, 40, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	if box >= 0 {
+		top := box * width / 2
+		for y := top + 10; y < top+width/4 && y < height; y++ {
+			for x := width / 2; x < width-10; x++ {
+				img.Set(x, y, color.RGBA{200, 30, 30, 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: DefaultScreenshotQuality}); err != nil {
+		t.Fatalf("failed to encode screenshot: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompareScreenshots(t *testing.T) {
+	page := testScreenshot(t, 400, 800, -1)
+
+	t.Run("identical", func(t *testing.T) {
+		diff, err := CompareScreenshots(page, page)
+		if err != nil {
+			t.Fatalf("failed to compare: %v", err)
+		}
+		if diff.Changed() || diff.Regions != 4 || diff.FirstChanged != -1 {
+			t.Errorf("expected 4 unchanged regions, got %+v", diff)
+		}
+	})
+
+	t.Run("re-encoded", func(t *testing.T) {
+		img, _, err := image.Decode(bytes.NewReader(page))
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		diff, err := CompareScreenshots(page, buf.Bytes())
+		if err != nil {
+			t.Fatalf("failed to compare: %v", err)
+		}
+		if diff.Changed() {
+			t.Errorf("expected a PNG copy to look the same, got %+v", diff)
+		}
+	})
+
+	t.Run("one region changed", func(t *testing.T) {
+		diff, err := CompareScreenshots(page, testScreenshot(t, 400, 800, 2))
+		if err != nil {
+			t.Fatalf("failed to compare: %v", err)
+		}
+		if diff.ChangedRegions != 1 || diff.FirstChanged != 2 || diff.Distance <= VisualChangeThreshold {
+			t.Errorf("expected only region 2 to change, got %+v", diff)
+		}
+	})
+
+	t.Run("page grew", func(t *testing.T) {
+		diff, err := CompareScreenshots(page, testScreenshot(t, 400, 1000, -1))
+		if err != nil {
+			t.Fatalf("failed to compare: %v", err)
+		}
+		if diff.Regions != 5 || diff.ChangedRegions != 1 || diff.FirstChanged != 4 || diff.Distance != 64 {
+			t.Errorf("expected the added region to count as changed, got %+v", diff)
+		}
+	})
+
+	if _, err := CompareScreenshots(page, []byte("png")); err == nil {
+		t.Error("expected an error comparing with something that isn't an image")
+	}
+}
+
+func TestDetectVisualChange(t *testing.T) {
+	database := newQueueTestDB(t)
+	var events []db.ArchiveVisualChangeEvent
+	database.RegisterEventListener(db.OnArchiveVisualChangeEvent, func(event db.Event) error {
+		events = append(events, event.(db.ArchiveVisualChangeEvent))
+		return nil
+	})
+
+	id, err := database.CreateBookmark(db.NewBookmark{URL: "https://example.com", Title: "Example"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	archive := func(at time.Time, screenshot []byte) {
+		t.Helper()
+		if err := database.SaveArchiveResult(id, at, &at, ArchiveStatusOK, "", "https://example.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		if screenshot != nil {
+			if err := database.SaveArchiveScreenshot(id, screenshot); err != nil {
+				t.Fatalf("failed to save screenshot: %v", err)
+			}
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+
+	archive(start, testScreenshot(t, 400, 800, -1))
+	if diff, err := DetectVisualChange(database, id); err != nil || diff.Regions != 0 {
+		t.Fatalf("expected nothing to compare the first archive with, got %+v, %v", diff, err)
+	}
+
+	// A version without a screenshot is skipped over.
+	archive(start.Add(time.Minute), nil)
+	archive(start.Add(2*time.Minute), testScreenshot(t, 400, 800, -1))
+	if diff, err := DetectVisualChange(database, id); err != nil || diff.Regions != 4 || diff.Changed() {
+		t.Fatalf("expected an unchanged comparison, got %+v, %v", diff, err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events for an unchanged page, got %d", len(events))
+	}
+
+	archive(start.Add(3*time.Minute), testScreenshot(t, 400, 800, 1))
+	diff, err := DetectVisualChange(database, id)
+	if err != nil || !diff.Changed() {
+		t.Fatalf("expected a change, got %+v, %v", diff, err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %d", len(events))
+	}
+	versions, err := database.ListArchiveVersions(id)
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	ev := events[0]
+	if ev.BookmarkID != id || ev.VersionID != versions[0].ID || ev.PreviousVersionID != versions[1].ID ||
+		ev.ChangedRegions != 1 || ev.Regions != 4 {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	for name, data := range map[string][]byte{"before": ev.Before, "after": ev.After} {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to decode %s image: %v", name, err)
+		}
+		if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 160 {
+			t.Errorf("expected a 320x160 %s image, got %v", name, b)
+		}
+	}
+}
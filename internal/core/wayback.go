@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+const (
+	// DefaultWaybackAvailabilityURL is the Internet Archive's availability
+	// API, which finds the snapshot of a page closest to a time.
+	DefaultWaybackAvailabilityURL = "https://archive.org/wayback/available"
+	// DefaultWaybackSaveURL is Save Page Now, which captures a page when
+	// its URL is appended.
+	DefaultWaybackSaveURL = "https://web.archive.org/save/"
+)
+
+// ErrNoWaybackSnapshot is returned by WaybackClient.Submit when the Wayback
+// Machine took the page but no snapshot of it can be found yet.
+var ErrNoWaybackSnapshot = errors.New("no wayback snapshot found")
+
+// WaybackClient finds and makes snapshots of pages in the Internet
+// Archive's Wayback Machine, as a fallback for pages bookmarkd couldn't
+// archive itself. Nothing is sent to the Internet Archive unless a client
+// is set up, and a nil WaybackClient finds nothing.
+type WaybackClient struct {
+	availabilityURL string
+	saveURL         string
+	client          *http.Client
+}
+
+// NewWaybackClient returns a client for the availability API at
+// availabilityURL and Save Page Now at saveURL
+// (DefaultWaybackAvailabilityURL and DefaultWaybackSaveURL if empty).
+func NewWaybackClient(availabilityURL, saveURL string) *WaybackClient {
+	if availabilityURL == "" {
+		availabilityURL = DefaultWaybackAvailabilityURL
+	}
+	if saveURL == "" {
+		saveURL = DefaultWaybackSaveURL
+	}
+	return &WaybackClient{
+		availabilityURL: availabilityURL,
+		saveURL:         saveURL,
+		client:          &http.Client{Timeout: DefaultWaybackTimeout},
+	}
+}
+
+// waybackAvailability is the availability API's answer.
+type waybackAvailability struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// Lookup returns the URL of the snapshot of pageURL closest to at (the
+// latest if at is zero), and false if the Wayback Machine has none. Only
+// snapshots of pages that loaded (status 200) count.
+func (c *WaybackClient) Lookup(ctx context.Context, pageURL string, at time.Time) (string, bool, error) {
+	if c == nil {
+		return "", false, nil
+	}
+	q := url.Values{"url": {pageURL}}
+	if !at.IsZero() {
+		q.Set("timestamp", at.UTC().Format("20060102150405"))
+	}
+	endpoint := c.availabilityURL + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create wayback request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query the wayback machine: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("failed to query the wayback machine: %s", resp.Status)
+	}
+
+	var body waybackAvailability
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MaxWaybackResponseSize)).Decode(&body); err != nil {
+		return "", false, fmt.Errorf("failed to parse wayback answer: %w", err)
+	}
+	closest := body.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.URL == "" || closest.Status != "200" {
+		return "", false, nil
+	}
+	// The API hands out http:// links to snapshots it serves over https.
+	return strings.Replace(closest.URL, "http://web.archive.org/", "https://web.archive.org/", 1), true, nil
+}
+
+// Submit asks the Wayback Machine to capture pageURL now and returns the
+// snapshot's URL. Save Page Now points at the snapshot with its redirect or
+// Content-Location header; without either, the latest snapshot is looked
+// up, and ErrNoWaybackSnapshot is returned if there is none.
+func (c *WaybackClient) Submit(ctx context.Context, pageURL string) (string, error) {
+	if c == nil {
+		return "", errors.New("wayback machine is not enabled")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.saveURL+pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create wayback request: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit to the wayback machine: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to submit to the wayback machine: %s", resp.Status)
+	}
+
+	if loc := resp.Header.Get("Content-Location"); loc != "" {
+		if u, err := resp.Request.URL.Parse(loc); err == nil {
+			return u.String(), nil
+		}
+	}
+	if final := resp.Request.URL; strings.Contains(final.Path, "/web/") {
+		return final.String(), nil
+	}
+	snapshot, ok, err := c.Lookup(ctx, pageURL, time.Time{})
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNoWaybackSnapshot
+	}
+	return snapshot, nil
+}
+
+// waybackFallback looks for a Wayback Machine snapshot of a bookmark that
+// failed to archive with archiveErr, closest to when it was bookmarked, and
+// stores it for the viewer to link to. Pages the archive rules or
+// robots.txt kept out aren't looked up, so they aren't sent to the Internet
+// Archive. Failures are only logged.
+func waybackFallback(ctx context.Context, database *db.DB, b db.Bookmark, wayback *WaybackClient, archiveErr error) {
+	if wayback == nil || errors.Is(archiveErr, ErrArchiveDisallowed) || ClassifyArchiveError(archiveErr) == ArchiveErrorBlocked {
+		return
+	}
+	var savedAt time.Time
+	if t, err := time.Parse(time.RFC3339, b.CreatedAt); err == nil {
+		savedAt = t
+	}
+	snapshot, ok, err := wayback.Lookup(ctx, b.URL, savedAt)
+	if err != nil {
+		log.Printf("Warning: failed to look up wayback snapshot for id=%d: %v", b.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := database.SetWaybackURL(b.ID, snapshot); err != nil {
+		log.Printf("Warning: failed to save wayback snapshot for id=%d: %v", b.ID, err)
+		return
+	}
+	log.Printf("Found wayback snapshot for id=%d: %s", b.ID, snapshot)
+}
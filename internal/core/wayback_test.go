@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// newWaybackTestServer serves an availability API that has snapshots of the
+// URLs in snapshots, and a Save Page Now that points at a new snapshot with
+// Content-Location. It counts the lookups made.
+func newWaybackTestServer(t *testing.T, snapshots map[string]string) (*WaybackClient, *int) {
+	t.Helper()
+	lookups := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/available":
+			lookups++
+			snapshot, ok := snapshots[r.URL.Query().Get("url")]
+			if !ok {
+				_, _ = fmt.Fprint(w, `{"url": "x", "archived_snapshots": {}}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"archived_snapshots": {"closest": {"available": true, "url": %q, "timestamp": %q, "status": "200"}}}`, snapshot, r.URL.Query().Get("timestamp"))
+		case strings.HasPrefix(r.URL.Path, "/save/"):
+			w.Header().Set("Content-Location", "/web/20261016120000/"+strings.TrimPrefix(r.URL.Path, "/save/"))
+			_, _ = fmt.Fprint(w, "<html>Saved</html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return NewWaybackClient(ts.URL+"/available", ts.URL+"/save/"), &lookups
+}
+
+func TestWaybackClient(t *testing.T) {
+	wayback, _ := newWaybackTestServer(t, map[string]string{
+		"https://example.com/": "http://web.archive.org/web/20200101000000/https://example.com/",
+	})
+	ctx := context.Background()
+
+	snapshot, ok, err := wayback.Lookup(ctx, "https://example.com/", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if !ok || snapshot != "https://web.archive.org/web/20200101000000/https://example.com/" {
+		t.Errorf("expected the https snapshot, got %q, %v", snapshot, ok)
+	}
+	if _, ok, err := wayback.Lookup(ctx, "https://example.com/new", time.Time{}); err != nil || ok {
+		t.Errorf("expected no snapshot, got %v, %v", ok, err)
+	}
+
+	snapshot, err = wayback.Submit(ctx, "https://example.com/new")
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if !strings.HasSuffix(snapshot, "/web/20261016120000/https://example.com/new") {
+		t.Errorf("expected the snapshot from Content-Location, got %q", snapshot)
+	}
+
+	var off *WaybackClient
+	if _, ok, err := off.Lookup(ctx, "https://example.com/", time.Time{}); ok || err != nil {
+		t.Errorf("expected a nil client to find nothing, got %v, %v", ok, err)
+	}
+	if _, err := off.Submit(ctx, "https://example.com/"); err == nil {
+		t.Error("expected a nil client to refuse submissions")
+	}
+}
+
+func TestArchiveAndPersist_WaybackFallback(t *testing.T) {
+	database := newQueueTestDB(t)
+	add := func(url string) db.Bookmark {
+		t.Helper()
+		id, err := database.AddBookmark(url, url)
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		b, err := database.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		return b
+	}
+	dead := add("https://dead.example.com/")
+	blocked := add("https://blocked.example.org/")
+	wayback, lookups := newWaybackTestServer(t, map[string]string{
+		dead.URL:    "https://web.archive.org/web/20200101000000/https://dead.example.com/",
+		blocked.URL: "https://web.archive.org/web/20200101000000/https://blocked.example.org/",
+	})
+	fake := &FakeArchiver{Errors: map[string]error{
+		dead.URL: &HTTPStatusError{StatusCode: 404, URL: dead.URL},
+	}}
+	opts := ArchiveOptions{Archiver: fake, Wayback: wayback, Domains: DomainRules{Deny: []string{"blocked.example.org"}}}
+
+	if err := ArchiveAndPersist(context.Background(), database, dead, opts); err == nil {
+		t.Fatal("expected the canned error")
+	}
+	archive, err := database.GetBookmarkArchive(dead.ID)
+	if err != nil {
+		t.Fatalf("failed to get archive: %v", err)
+	}
+	if archive.ArchiveStatus != ArchiveStatusError || archive.WaybackURL != "https://web.archive.org/web/20200101000000/https://dead.example.com/" {
+		t.Errorf("expected the failure and its wayback snapshot, got %q, %q", archive.ArchiveStatus, archive.WaybackURL)
+	}
+
+	// Pages the archive rules keep out aren't sent to the Internet Archive.
+	if err := ArchiveAndPersist(context.Background(), database, blocked, opts); !errors.Is(err, ErrDomainBlocked) {
+		t.Fatalf("expected ErrDomainBlocked, got %v", err)
+	}
+	if archive, _ := database.GetBookmarkArchive(blocked.ID); archive.WaybackURL != "" {
+		t.Errorf("expected no wayback lookup for a blocked page, got %q", archive.WaybackURL)
+	}
+	if *lookups != 1 {
+		t.Errorf("expected 1 lookup, got %d", *lookups)
+	}
+}
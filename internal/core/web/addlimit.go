@@ -0,0 +1,216 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+)
+
+// Form fields a client solving an add challenge sends with the bookmark.
+const (
+	challengeField       = "challenge"
+	challengeAnswerField = "challenge_answer"
+)
+
+// addSource returns who an add is counted against: the API token it was
+// made with, so a leaked bookmarklet can't be spread across addresses, or
+// else the client IP.
+func (ws *Server) addSource(r *http.Request) string {
+	if token, ok := apiTokenFrom(r.Context()); ok {
+		return "token:" + strconv.FormatInt(token.ID, 10)
+	}
+	return "ip:" + clientIP(r, ws.clients.trustProxy)
+}
+
+// addChallengeView is a proof-of-work challenge for a client over the add
+// limit: a signed token, valid for core.AddChallengeLifetime, and how many
+// leading zero bits the SHA-256 of token + ":" + answer must have. The
+// client sends the token back in challengeField and its answer, any string
+// that works, in challengeAnswerField.
+type addChallengeView struct {
+	Token      string `json:"token"`
+	Difficulty int    `json:"difficulty"`
+}
+
+// maxChallengeAnswer bounds the answers solve hashes; counting up from 0
+// needs far fewer digits.
+const maxChallengeAnswer = 32
+
+// addChallenger hands out and checks proof-of-work challenges that let a
+// client past the add limit at the cost of about 2^difficulty hashes per
+// bookmark: cheap for a person saving a page now and then, costly for
+// anyone adding in bulk. It isn't a CAPTCHA; a script can solve them too,
+// only not for free. Challenges are signed with a key made at startup, so
+// they need no storage until answered; every answer burns its challenge,
+// right or wrong, until it expires.
+type addChallenger struct {
+	key        []byte
+	difficulty int
+	now        func() time.Time
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newAddChallenger(difficulty int) (*addChallenger, error) {
+	if difficulty < 1 || difficulty > core.MaxAddChallengeDifficulty {
+		return nil, fmt.Errorf("challenge difficulty must be between 1 and %d bits, got %d", core.MaxAddChallengeDifficulty, difficulty)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge key: %w", err)
+	}
+	return &addChallenger{key: key, difficulty: difficulty, now: time.Now, used: make(map[string]time.Time)}, nil
+}
+
+// issue returns a new challenge for source.
+func (c *addChallenger) issue(source string) (addChallengeView, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return addChallengeView{}, err
+	}
+	expires := strconv.FormatInt(c.now().Add(core.AddChallengeLifetime).Unix(), 10)
+	claims := expires + "." + strconv.Itoa(c.difficulty) + "." + hex.EncodeToString(nonce)
+	return addChallengeView{Token: claims + "." + c.sign(source, claims), Difficulty: c.difficulty}, nil
+}
+
+// sign binds a challenge to the source it was issued to.
+func (c *addChallenger) sign(source, claims string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(source + "|" + claims))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// solve reports whether answer solves token, issued to source and neither
+// expired nor tried before. Any answer to a valid token uses it up, so a
+// wrong one can't be followed by another.
+func (c *addChallenger) solve(source, token, answer string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || len(answer) > maxChallengeAnswer {
+		return false
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	now := c.now()
+	expiry := time.Unix(unix, 0)
+	if !now.Before(expiry) {
+		return false
+	}
+	want := c.sign(source, strings.Join(parts[:3], "."))
+	if !hmac.Equal([]byte(parts[3]), []byte(want)) {
+		return false
+	}
+
+	c.mu.Lock()
+	for n, exp := range c.used {
+		if !now.Before(exp) {
+			delete(c.used, n)
+		}
+	}
+	_, tried := c.used[parts[2]]
+	c.used[parts[2]] = expiry
+	c.mu.Unlock()
+	if tried {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token + ":" + answer))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+// leadingZeroBits counts the zero bits b starts with.
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		n += bits.LeadingZeros8(x)
+		if x != 0 {
+			break
+		}
+	}
+	return n
+}
+
+// allowAdd counts a request that creates a bookmark against its source's
+// add limit (ws.adds). Requests over the limit are refused with a 429 and
+// Retry-After unless they carry a solved challenge; with challenges on,
+// JSON clients are given one to answer. It reports whether the request may
+// go ahead.
+func (ws *Server) allowAdd(w http.ResponseWriter, r *http.Request) bool {
+	return ws.allowAdds(w, r, 1)
+}
+
+// allowAdds is allowAdd for a request that creates n bookmarks, which are
+// counted all at once: the request goes ahead only if the source's limit
+// covers every one. A challenge lets a single bookmark through, so it only
+// counts when n is 1, and a request for more bookmarks than the burst is
+// refused outright.
+func (ws *Server) allowAdds(w http.ResponseWriter, r *http.Request, n int) bool {
+	if n <= 0 {
+		return true
+	}
+	source := ws.addSource(r)
+	if token := r.FormValue(challengeField); token != "" && ws.challenges != nil && n == 1 {
+		if ws.challenges.solve(source, token, r.FormValue(challengeAnswerField)) {
+			return true
+		}
+	}
+	ok, retry, first := ws.adds.allowN(source, n)
+	if ok {
+		return true
+	}
+	if first {
+		log.Printf("Rate limiting bookmark adds from %s", source)
+	}
+	if burst := int(ws.adds.burst); n > burst {
+		writeErrorView(w, r, http.StatusTooManyRequests, errorView{
+			Code:    errorCodeRateLimited,
+			Message: fmt.Sprintf("%d bookmarks at once is more than the add limit allows (%d); add them in smaller batches", n, burst),
+			Details: map[string]any{"burst": burst},
+		})
+		return false
+	}
+
+	after := max(int(retry.Seconds()+0.999), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(after))
+	e := errorView{
+		Code:    errorCodeRateLimited,
+		Message: "Too many bookmarks added; try again later",
+		Details: map[string]any{"retry_after": after},
+	}
+	if !wantsJSON(r) {
+		writeErrorView(w, r, http.StatusTooManyRequests, e)
+		return false
+	}
+	// The challenge stays at the top level, where the bookmarklet looks.
+	resp := struct {
+		errorView
+		Challenge *addChallengeView `json:"challenge,omitempty"`
+	}{errorView: e}
+	if ws.challenges != nil {
+		challenge, err := ws.challenges.issue(source)
+		if err != nil {
+			log.Printf("Failed to issue add challenge: %v", err)
+		} else {
+			resp.Challenge = &challenge
+		}
+	}
+	w.Header().Set("X-Error-Code", e.Code)
+	writeJSON(w, http.StatusTooManyRequests, resp)
+	return false
+}
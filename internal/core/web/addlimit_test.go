@@ -0,0 +1,168 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAllowAdd tests the add limit on bookmark creation and its challenges.
+func TestAllowAdd(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.adds = newClientLimiter(1, 1)
+	server.adds.now = func() time.Time { return now }
+
+	n := 0
+	add := func(t *testing.T, ip string, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		n++
+		if form == nil {
+			form = url.Values{}
+		}
+		form.Set("url", fmt.Sprintf("https://example.com/%d", n))
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		return w
+	}
+	type refusal struct {
+		Error     string            `json:"error"`
+		Challenge *addChallengeView `json:"challenge"`
+	}
+	refused := func(t *testing.T, w *httptest.ResponseRecorder) refusal {
+		t.Helper()
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+			t.Fatalf("expected 429 with Retry-After, got %d %v: %s", w.Code, w.Header(), w.Body.String())
+		}
+		var body refusal
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		return body
+	}
+
+	t.Run("adds are limited per source", func(t *testing.T) {
+		if w := add(t, "192.0.2.1", nil); w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		if body := refused(t, add(t, "192.0.2.1", nil)); body.Challenge != nil {
+			t.Errorf("expected no challenge while challenges are off, got %+v", body.Challenge)
+		}
+		if w := add(t, "192.0.2.2", nil); w.Code != http.StatusCreated {
+			t.Errorf("expected another client to pass, got %d", w.Code)
+		}
+	})
+
+	t.Run("bulk adds count every URL", func(t *testing.T) {
+		limiter := server.adds
+		t.Cleanup(func() { server.adds = limiter })
+		server.adds = newClientLimiter(1, 3)
+		bulkNow := now
+		server.adds.now = func() time.Time { return bulkNow }
+		bulk := func(urls ...string) *httptest.ResponseRecorder {
+			form := url.Values{"urls": {strings.Join(urls, "\n")}}
+			req := httptest.NewRequest(http.MethodPost, "/bookmarks/bulk", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", "application/json")
+			req.RemoteAddr = "192.0.2.3:1234"
+			w := httptest.NewRecorder()
+			server.handleBookmarksBulk(w, req)
+			return w
+		}
+
+		if w := bulk("https://bulk.example.com/1", "https://bulk.example.com/2", "https://bulk.example.com/1", "not a url"); w.Code != http.StatusOK {
+			t.Fatalf("expected two URLs within the burst to pass, got %d: %s", w.Code, w.Body.String())
+		}
+		refused(t, bulk("https://bulk.example.com/3", "https://bulk.example.com/4"))
+		if existing, _ := server.db.ExistingBookmarkURLs([]string{"https://bulk.example.com/3"}); existing["https://bulk.example.com/3"] {
+			t.Error("expected a refused list to add nothing")
+		}
+		if w := bulk("https://bulk.example.com/3"); w.Code != http.StatusOK {
+			t.Errorf("expected the last token to cover one URL, got %d", w.Code)
+		}
+
+		bulkNow = bulkNow.Add(time.Hour)
+		w := bulk("https://a.example.com", "https://b.example.com", "https://c.example.com", "https://d.example.com")
+		if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "smaller batches") {
+			t.Errorf("expected a list over the burst to be refused, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("a solved challenge lets one add through", func(t *testing.T) {
+		if _, err := newAddChallenger(0); err == nil {
+			t.Error("expected an error for no difficulty")
+		}
+		challenges, err := newAddChallenger(8)
+		if err != nil {
+			t.Fatalf("failed to create challenger: %v", err)
+		}
+		challengeNow := now
+		challenges.now = func() time.Time { return challengeNow }
+		server.challenges = challenges
+
+		challenge := func() *addChallengeView {
+			t.Helper()
+			c := refused(t, add(t, "192.0.2.1", nil)).Challenge
+			if c == nil || c.Difficulty != 8 {
+				t.Fatalf("expected a challenge of 8 bits, got %+v", c)
+			}
+			return c
+		}
+		answer := func(c *addChallengeView, s string) url.Values {
+			return url.Values{challengeField: {c.Token}, challengeAnswerField: {s}}
+		}
+
+		c := challenge()
+		// A wrong answer uses the challenge up.
+		refused(t, add(t, "192.0.2.1", answer(c, wrongChallengeAnswer(c))))
+		refused(t, add(t, "192.0.2.1", answer(c, solveChallenge(c))))
+
+		c = challenge()
+		// 192.0.2.2 used up its add above; the challenge is 192.0.2.1's.
+		refused(t, add(t, "192.0.2.2", answer(c, solveChallenge(c))))
+		if w := add(t, "192.0.2.1", answer(c, solveChallenge(c))); w.Code != http.StatusCreated {
+			t.Fatalf("expected the solution to let the add through, got %d: %s", w.Code, w.Body.String())
+		}
+		refused(t, add(t, "192.0.2.1", answer(c, solveChallenge(c))))
+
+		c = challenge()
+		challengeNow = challengeNow.Add(11 * time.Minute)
+		refused(t, add(t, "192.0.2.1", answer(c, solveChallenge(c))))
+	})
+}
+
+// solveChallenge finds an answer to c, as the bookmarklet does.
+func solveChallenge(c *addChallengeView) string {
+	for n := 0; ; n++ {
+		answer := strconv.Itoa(n)
+		if sum := sha256.Sum256([]byte(c.Token + ":" + answer)); leadingZeroBits(sum[:]) >= c.Difficulty {
+			return answer
+		}
+	}
+}
+
+// wrongChallengeAnswer finds an answer that doesn't solve c.
+func wrongChallengeAnswer(c *addChallengeView) string {
+	for n := 0; ; n++ {
+		answer := strconv.Itoa(n)
+		if sum := sha256.Sum256([]byte(c.Token + ":" + answer)); leadingZeroBits(sum[:]) < c.Difficulty {
+			return answer
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// sessionCookie holds the session ID of a logged-in browser.
+const sessionCookie = "bookmarkd_session"
+
+// sessionLifetime is how long a login lasts.
+const sessionLifetime = 30 * 24 * time.Hour
+
+// session is a logged-in browser.
+type session struct {
+	userID int64
+	expiry time.Time
+}
+
+// sessionStore checks the instance password and tracks logged-in sessions.
+// Sessions are kept in memory, so everyone has to log in again when the
+// server restarts.
+type sessionStore struct {
+	mu sync.Mutex
+	// passwordHash is the SHA-256 of the instance password, which logs in
+	// as db.LocalUserID; nil disables it.
+	passwordHash []byte
+	sessions     map[string]session // session ID -> session
+	now          func() time.Time
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]session),
+		now:      time.Now,
+	}
+}
+
+// setPassword sets the instance password; an empty one disables it.
+func (s *sessionStore) setPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.passwordHash = nil
+	if password != "" {
+		sum := sha256.Sum256([]byte(password))
+		s.passwordHash = sum[:]
+	}
+}
+
+// enabled reports whether an instance password is set.
+func (s *sessionStore) enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.passwordHash != nil
+}
+
+// checkPassword reports whether password is the instance password, in
+// constant time.
+func (s *sessionStore) checkPassword(password string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.passwordHash == nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(sum[:], s.passwordHash) == 1
+}
+
+// create starts a session for userID and returns its ID and expiry.
+func (s *sessionStore) create(userID int64) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for sid, sess := range s.sessions {
+		if !now.Before(sess.expiry) {
+			delete(s.sessions, sid)
+		}
+	}
+	expiry := now.Add(sessionLifetime)
+	s.sessions[id] = session{userID: userID, expiry: expiry}
+	return id, expiry, nil
+}
+
+// user returns the user of a live session.
+func (s *sessionStore) user(id string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return 0, false
+	}
+	if !s.now().Before(sess.expiry) {
+		delete(s.sessions, id)
+		return 0, false
+	}
+	return sess.userID, true
+}
+
+// remove ends a session.
+func (s *sessionStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// removeUser ends every session of a user.
+func (s *sessionStore) removeUser(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		if sess.userID == userID {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// sessionUser returns the user of r's session cookie, if it is live.
+func (ws *Server) sessionUser(r *http.Request) (int64, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return 0, false
+	}
+	return ws.sessions.user(c.Value)
+}
+
+// loginRequired reports whether the web UI needs a login: when the
+// instance password is set or any user has a password.
+func (ws *Server) loginRequired() bool {
+	if ws.sessions.enabled() {
+		return true
+	}
+	required, err := ws.db.HasUserPasswords()
+	if err != nil {
+		// Fail closed rather than open the UI to everyone.
+		log.Printf("Failed to check user passwords: %v", err)
+		return true
+	}
+	return required
+}
+
+// userKey is the context key requireLogin stores the request's user ID
+// under.
+type userKey struct{}
+
+func withUser(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// requestUserID returns the user a request acts as. Without a login every
+// request acts as db.LocalUserID.
+func requestUserID(r *http.Request) int64 {
+	if id, ok := r.Context().Value(userKey{}).(int64); ok {
+		return id
+	}
+	return db.LocalUserID
+}
+
+// requestDB returns the database, unscoped, with queries canceled when r
+// is done or times out (see limitTime), for instance-wide queries.
+func (ws *Server) requestDB(r *http.Request) *db.DB {
+	return ws.db.WithContext(r.Context())
+}
+
+// userDB returns the database scoped to the user r acts as, with queries
+// canceled when r is done or times out.
+func (ws *Server) userDB(r *http.Request) *db.DB {
+	return ws.requestDB(r).ForUser(requestUserID(r))
+}
+
+// requireAdmin writes a 403 and returns false unless r's user is an admin,
+// for instance-wide pages such as routing rules and the activity log.
+func (ws *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if ws.isAdmin(r) {
+		return true
+	}
+	writeError(w, r, http.StatusForbidden, "Forbidden")
+	return false
+}
+
+// isAdmin reports whether r's user is an admin.
+func (ws *Server) isAdmin(r *http.Request) bool {
+	u, err := ws.requestDB(r).GetUser(requestUserID(r))
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", requestUserID(r), err)
+		return false
+	}
+	return u.IsAdmin
+}
+
+// apiTokenKey is the context key limitAPITokens stores the request's API
+// token under.
+type apiTokenKey struct{}
+
+func withAPIToken(ctx context.Context, token db.APIToken) context.Context {
+	return context.WithValue(ctx, apiTokenKey{}, token)
+}
+
+func apiTokenFrom(ctx context.Context) (db.APIToken, bool) {
+	token, ok := ctx.Value(apiTokenKey{}).(db.APIToken)
+	return token, ok
+}
+
+// requireLogin protects every route except static assets, the login page
+// and shared collections when a login is required, and records which user
+// each request acts as.
+// Requests authenticated with an API token act as the token's owner.
+// Browsers are redirected to the login page; htmx, JSON and non-GET requests
+// get a 401. WebDAV requests under /dav/ and podcast feeds under /podcast/
+// log in with Basic credentials.
+func (ws *Server) requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := apiTokenFrom(r.Context()); ok {
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), token.UserID)))
+			return
+		}
+		if id, ok := ws.sessionUser(r); ok {
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), id)))
+			return
+		}
+		if r.URL.Path == "/login" || strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, sharedPrefix) || !ws.loginRequired() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, davPrefix+"/") || strings.HasPrefix(r.URL.Path, podcastPrefix) {
+			// WebDAV clients and podcast apps can't use the login form;
+			// they send Basic credentials instead.
+			if id, ok := ws.basicAuthUser(w, r); ok {
+				next.ServeHTTP(w, r.WithContext(withUser(r.Context(), id)))
+			}
+			return
+		}
+
+		login := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+		switch {
+		case wantsJSON(r):
+			writeError(w, r, http.StatusUnauthorized, "Login required")
+		case isHTMX(r):
+			w.Header().Set("HX-Redirect", login)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			http.Redirect(w, r, login, http.StatusSeeOther)
+		default:
+			writeError(w, r, http.StatusUnauthorized, "Login required")
+		}
+	})
+}
+
+// basicAuthUser returns the user r's Basic credentials log in as (see
+// authenticate). Otherwise it writes a 401 asking for them and returns
+// false; wrong credentials also count against the client's write rate limit,
+// so they can't be guessed any faster than through the login form.
+func (ws *Server) basicAuthUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	username, password, ok := r.BasicAuth()
+	if ok {
+		id, err := ws.authenticate(strings.TrimSpace(username), password)
+		if err == nil {
+			return id, true
+		}
+		if !errors.Is(err, db.ErrInvalidCredentials) {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to check login: %v", err)
+			return 0, false
+		}
+		ip := clientIP(r, ws.clients.trustProxy)
+		log.Printf("Failed login for %q from %s", username, ip)
+		if allowed, retry, _ := ws.clients.allow(ip); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(retry.Seconds()+0.999), 1)))
+			writeError(w, r, http.StatusTooManyRequests, "Rate limit exceeded")
+			return 0, false
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="bookmarkd", charset="UTF-8"`)
+	writeError(w, r, http.StatusUnauthorized, "Login required")
+	return 0, false
+}
+
+// safeRedirect returns next if it is a path on this server, or "/".
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,284 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// TestRequireLogin tests password login, sessions and the login middleware.
+func TestRequireLogin(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("open without a password", func(t *testing.T) {
+		w := do(httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+	})
+
+	server.sessions.setPassword("hunter2")
+
+	t.Run("redirects browsers to the login page", func(t *testing.T) {
+		w := do(httptest.NewRequest(http.MethodGet, "/settings?x=1", nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next="+url.QueryEscape("/settings?x=1") {
+			t.Errorf("expected a redirect to login, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("refuses htmx, JSON and form requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("HX-Request", "true")
+		if w := do(req); w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("HX-Redirect"), "/login") {
+			t.Errorf("expected 401 with HX-Redirect, got %d %v", w.Code, w.Header())
+		}
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Accept", "application/json")
+		if w := do(req); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for JSON, got %d", w.Code)
+		}
+		if w := do(httptest.NewRequest(http.MethodPost, "/bookmarks", nil)); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for POST, got %d", w.Code)
+		}
+	})
+
+	t.Run("serves static assets and the login page", func(t *testing.T) {
+		if w := do(httptest.NewRequest(http.MethodGet, "/static/app.css", nil)); w.Code != http.StatusOK {
+			t.Errorf("expected static assets to be served, got %d", w.Code)
+		}
+		w := do(httptest.NewRequest(http.MethodGet, "/login?next=/archives", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `value="/archives"`) {
+			t.Errorf("expected the login form, got %d", w.Code)
+		}
+	})
+
+	t.Run("serves shared collections", func(t *testing.T) {
+		shared, err := server.db.ShareCollection("Reading")
+		if err != nil {
+			t.Fatalf("failed to share collection: %v", err)
+		}
+		if w := do(httptest.NewRequest(http.MethodGet, "/shared/"+shared.Token+".json", nil)); w.Code != http.StatusOK {
+			t.Errorf("expected the shared collection without a login, got %d", w.Code)
+		}
+	})
+
+	t.Run("asks podcast apps for Basic credentials", func(t *testing.T) {
+		w := do(httptest.NewRequest(http.MethodGet, "/podcast/listen.rss", nil))
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("expected a Basic auth challenge, got %d %v", w.Code, w.Header())
+		}
+		req := httptest.NewRequest(http.MethodGet, "/podcast/listen.rss", nil)
+		req.SetBasicAuth("", "hunter2")
+		if w := do(req); w.Code != http.StatusOK {
+			t.Errorf("expected the feed with Basic credentials, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=nope"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := do(withCSRF(req))
+		if w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 || !strings.Contains(w.Body.String(), "Wrong username or password") {
+			t.Errorf("expected a failed login, got %d", w.Code)
+		}
+	})
+
+	t.Run("logs in and out", func(t *testing.T) {
+		form := url.Values{"password": {"hunter2"}, "next": {"/archives"}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := do(withCSRF(req))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/archives" {
+			t.Fatalf("expected a redirect to /archives, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+			t.Fatalf("expected an HttpOnly session cookie, got %v", cookies)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/settings", nil)
+		req.AddCookie(cookies[0])
+		w = do(req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="/logout"`) {
+			t.Errorf("expected the settings page with a logout button, got %d", w.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.AddCookie(cookies[0])
+		if w := do(withCSRF(req)); w.Code != http.StatusSeeOther {
+			t.Errorf("expected a redirect after logout, got %d", w.Code)
+		}
+		req = httptest.NewRequest(http.MethodGet, "/settings", nil)
+		req.AddCookie(cookies[0])
+		if w := do(req); w.Code != http.StatusSeeOther {
+			t.Errorf("expected the session to end, got %d", w.Code)
+		}
+	})
+
+	t.Run("accepts API tokens", func(t *testing.T) {
+		_, token, err := server.db.CreateAPIToken("app", 0)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		if w := do(req); w.Code != http.StatusOK {
+			t.Errorf("expected 200 with an API token, got %d", w.Code)
+		}
+	})
+
+	t.Run("sessions expire", func(t *testing.T) {
+		id, _, err := server.sessions.create(db.LocalUserID)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		server.sessions.now = func() time.Time { return time.Now().Add(sessionLifetime) }
+		defer func() { server.sessions.now = time.Now }()
+		if _, ok := server.sessions.user(id); ok {
+			t.Error("expected the session to have expired")
+		}
+	})
+}
+
+// TestUserSessions tests logging in as separate users, who each see only
+// their own bookmarks.
+func TestUserSessions(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if _, err := server.db.AddBookmark("https://admin.example", "Admin's"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	alice, err := server.db.CreateUser("alice", "wonderland", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := server.db.ForUser(alice.ID).AddBookmark("https://alice.example", "Alice's"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	login := func(t *testing.T, username, password string) *http.Cookie {
+		t.Helper()
+		form := url.Values{"username": {username}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := do(withCSRF(req))
+		cookies := w.Result().Cookies()
+		if w.Code != http.StatusSeeOther || len(cookies) != 1 {
+			t.Fatalf("expected %s to log in, got %d", username, w.Code)
+		}
+		return cookies[0]
+	}
+
+	t.Run("requires a login once a user has a password", func(t *testing.T) {
+		if w := do(httptest.NewRequest(http.MethodGet, "/", nil)); w.Code != http.StatusSeeOther {
+			t.Errorf("expected a redirect to login, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		form := url.Values{"username": {"alice"}, "password": {"nope"}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if w := do(withCSRF(req)); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("shows only the user's bookmarks", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Accept", "application/json")
+		req.AddCookie(login(t, "alice", "wonderland"))
+		w := do(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, "alice.example") || strings.Contains(body, "admin.example") {
+			t.Errorf("expected only alice's bookmarks, got %s", body)
+		}
+	})
+
+	t.Run("keeps instance-wide pages for admins", func(t *testing.T) {
+		cookie := login(t, "alice", "wonderland")
+		req := httptest.NewRequest(http.MethodGet, "/activity", nil)
+		req.AddCookie(cookie)
+		if w := do(req); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for the activity log, got %d", w.Code)
+		}
+		req = httptest.NewRequest(http.MethodGet, "/settings", nil)
+		req.AddCookie(cookie)
+		if w := do(req); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "Routing rules") {
+			t.Errorf("expected settings without routing rules, got %d", w.Code)
+		}
+	})
+
+	t.Run("acts as the token's owner", func(t *testing.T) {
+		_, token, err := server.db.ForUser(alice.ID).CreateAPIToken("phone", 0)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		w := do(req)
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "admin.example") {
+			t.Errorf("expected only alice's bookmarks, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("logs in the instance password as the local admin", func(t *testing.T) {
+		server.sessions.setPassword("hunter2")
+		defer server.sessions.setPassword("")
+		req := httptest.NewRequest(http.MethodGet, "/activity", nil)
+		req.AddCookie(login(t, "", "hunter2"))
+		if w := do(req); w.Code != http.StatusOK {
+			t.Errorf("expected the local admin to see the activity log, got %d", w.Code)
+		}
+	})
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/archives?x=1":       "/archives?x=1",
+		"":                    "/",
+		"https://evil.com":    "/",
+		"//evil.com":          "/",
+		"/\\evil.com":         "/",
+		"javascript:alert(1)": "/",
+	}
+	for in, want := range tests {
+		if got := safeRedirect(in); got != want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+)
+
+// CSRF protection uses the double-submit pattern: each browser gets a random
+// token in csrfCookie, and state-changing requests must send it back in the
+// csrfHeader header (htmx and fetch) or the csrfFormField form field (plain
+// forms). Another site can make a browser send the cookie but can't read it,
+// so it can't fill in the header or field.
+const (
+	csrfCookie    = "bookmarkd_csrf"
+	csrfHeader    = "X-CSRF-Token"
+	csrfFormField = "csrf_token"
+)
+
+// csrfTokenKey is the context key protectCSRF stores the request's CSRF
+// token under.
+type csrfTokenKey struct{}
+
+// csrfToken returns the CSRF token of r's browser, for pages to render into
+// their forms as "CSRFToken".
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// csrfExempt reports whether r is exempt from CSRF checks. Requests
+// authenticated with an API token are: browsers never add the token by
+// themselves, so a cross-site request can't carry one.
+func csrfExempt(r *http.Request) bool {
+	_, ok := apiTokenFrom(r.Context())
+	return ok
+}
+
+// protectCSRF gives each browser a CSRF token and rejects POST, PUT, PATCH
+// and DELETE requests that don't send it back, with a 403. Form bodies are
+// parsed here to read csrfFormField, so they are capped at
+// core.MaxImportSize, the largest upload the web UI takes.
+func (ws *Server) protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 64 {
+			token = c.Value
+		} else {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+				log.Printf("Failed to generate CSRF token: %v", err)
+				return
+			}
+			token = hex.EncodeToString(b)
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     "/",
+				Expires:  time.Now().Add(sessionLifetime),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, "PROPFIND": // PROPFIND only reads the WebDAV tree
+			next.ServeHTTP(w, r)
+			return
+		}
+		if csrfExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sent := r.Header.Get(csrfHeader)
+		if sent == "" {
+			var err error
+			if sent, err = formCSRFToken(w, r); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(w, r, http.StatusRequestEntityTooLarge, "Request is too large")
+					return
+				}
+				writeError(w, r, http.StatusBadRequest, "Invalid form data")
+				return
+			}
+		}
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			log.Printf("Rejected %s %s from %s: missing or wrong CSRF token", r.Method, r.URL.Path, r.RemoteAddr)
+			writeErrorCode(w, r, http.StatusForbidden, errorCodeInvalidCSRFToken, "Missing or invalid CSRF token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// formCSRFToken returns csrfFormField from r's form body, if it has one.
+// Multipart forms are parsed as handleImport would, so it finds them parsed.
+func formCSRFToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		r.Body = http.MaxBytesReader(w, r.Body, core.MaxImportSize)
+		if err := r.ParseForm(); err != nil {
+			return "", err
+		}
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, core.MaxImportSize)
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			return "", err
+		}
+	default:
+		return "", nil
+	}
+	return r.PostForm.Get(csrfFormField), nil
+}
+
+// csrfFuncs are the template helpers for CSRF tokens. Pages pass their
+// CSRFToken to them:
+//
+//	<body hx-headers="{{ csrfHeaders .CSRFToken }}">
+//	<form method="post">{{ csrfField .CSRFToken }}...</form>
+var csrfFuncs = template.FuncMap{
+	// csrfField renders the hidden form field for a plain form.
+	"csrfField": func(token string) template.HTML {
+		return template.HTML(`<input type="hidden" name="` + csrfFormField + `" value="` + template.HTMLEscapeString(token) + `">`)
+	},
+	// csrfHeaders renders an hx-headers value, which htmx sends with every
+	// request from inside the element.
+	"csrfHeaders": func(token string) (string, error) {
+		b, err := json.Marshal(map[string]string{csrfHeader: token})
+		return string(b), err
+	},
+}
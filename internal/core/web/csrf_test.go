@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testCSRFToken is a well-formed CSRF token for tests.
+var testCSRFToken = strings.Repeat("ab", 32)
+
+// withCSRF gives req a CSRF cookie and sends the token back in the header,
+// as htmx does.
+func withCSRF(req *http.Request) *http.Request {
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRFToken})
+	req.Header.Set(csrfHeader, testCSRFToken)
+	return req
+}
+
+// TestProtectCSRF tests CSRF token issue and verification.
+func TestProtectCSRF(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	post := func(target, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	t.Run("issues a token and renders it", func(t *testing.T) {
+		w := do(httptest.NewRequest(http.MethodGet, "/settings", nil))
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != csrfCookie || len(cookies[0].Value) != 64 || !cookies[0].HttpOnly {
+			t.Fatalf("expected an HttpOnly CSRF cookie, got %v", cookies)
+		}
+		if !strings.Contains(w.Body.String(), `name="csrf_token" value="`+cookies[0].Value+`"`) {
+			t.Error("expected the settings form to carry the token")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRFToken})
+		w = do(req)
+		if len(w.Result().Cookies()) != 0 {
+			t.Error("expected an existing token to be kept")
+		}
+		want, _ := json.Marshal(map[string]string{csrfHeader: testCSRFToken})
+		if !strings.Contains(w.Body.String(), strings.ReplaceAll(string(want), `"`, "&#34;")) {
+			t.Error("expected the page to send the token with htmx requests")
+		}
+	})
+
+	t.Run("rejects cross-site posts", func(t *testing.T) {
+		if w := do(post("/bookmarks", "url=https://example.com/a")); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 without a token, got %d", w.Code)
+		}
+		req := post("/bookmarks", "url=https://example.com/a&csrf_token="+testCSRFToken)
+		if w := do(req); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 without the cookie, got %d", w.Code)
+		}
+		req = post("/bookmarks", "url=https://example.com/a")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRFToken})
+		req.Header.Set(csrfHeader, strings.Repeat("cd", 32))
+		req.Header.Set("Accept", "application/json")
+		if w := do(req); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "invalid CSRF token") {
+			t.Errorf("expected a JSON 403 for the wrong token, got %d", w.Code)
+		}
+	})
+
+	t.Run("accepts the token in a header or form field", func(t *testing.T) {
+		if w := do(withCSRF(post("/bookmarks", "url=https://example.com/b"))); w.Code >= http.StatusBadRequest {
+			t.Errorf("expected the header to be accepted, got %d", w.Code)
+		}
+		form := url.Values{"url": {"https://example.com/c"}, csrfFormField: {testCSRFToken}}
+		req := post("/bookmarks", form.Encode())
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRFToken})
+		if w := do(req); w.Code >= http.StatusBadRequest {
+			t.Errorf("expected the form field to be accepted, got %d", w.Code)
+		}
+	})
+
+	t.Run("reads multipart forms", func(t *testing.T) {
+		var body strings.Builder
+		mw := multipart.NewWriter(&body)
+		if err := mw.WriteField(csrfFormField, testCSRFToken); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+		if err := mw.WriteField("format", "pocket"); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+		fw, err := mw.CreateFormFile("file", "export")
+		if err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		if _, err := fw.Write([]byte("title,url\nD,https://example.com/d\n")); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatalf("failed to close writer: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRFToken})
+		if w := do(req); w.Code != http.StatusOK {
+			t.Errorf("expected the import to be accepted, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("exempts API tokens", func(t *testing.T) {
+		_, token, err := server.db.CreateAPIToken("app", 0)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		req := post("/bookmarks", "url=https://example.com/e")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if w := do(req); w.Code >= http.StatusBadRequest {
+			t.Errorf("expected an API token request to be accepted, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,386 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// davPrefix is where the WebDAV archive tree is mounted.
+const davPrefix = "/dav"
+
+// davMethods are the methods the read-only WebDAV tree answers.
+const davMethods = "OPTIONS, GET, HEAD, PROPFIND"
+
+// davMaxNameLength caps the title part of archive file names, in runes.
+const davMaxNameLength = 100
+
+// handleDAV serves the user's archives as a read-only WebDAV tree, so file
+// managers can mount it and desktop search tools can index it:
+//
+//	/dav/by-tag/{tag}/{id} {title}.html
+//	/dav/by-date/{yyyy}/{mm}/{id} {title}.html
+//
+// Each file is the latest archive version of an archived bookmark, filed by
+// its capture date; bookmarks with several tags appear in each tag's
+// directory. Only OPTIONS, GET, HEAD and PROPFIND are allowed, and the tree
+// advertises DAV class 1 only, so clients mount it read-only. Files are
+// sandboxed with archiveCSP like /bookmarks/{id}/archive/raw.
+func (ws *Server) handleDAV(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", davMethods)
+		w.Header().Set("DAV", "1")
+		w.Header().Set("MS-Author-Via", "DAV")
+		return
+	case http.MethodGet, http.MethodHead, "PROPFIND":
+	default:
+		w.Header().Set("Allow", davMethods)
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", archiveCSP(!ws.stripScripts))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	h := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: &davFS{db: ws.userDB(r), stripScripts: ws.stripScripts},
+		LockSystem: ws.davLocks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("WebDAV %s %s failed: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	h.ServeHTTP(w, r)
+}
+
+// davNode is a directory or archive file in the WebDAV tree.
+type davNode struct {
+	name    string
+	modTime time.Time
+	// bookmark is the archived bookmark a file holds; nil for directories.
+	bookmark *db.DAVBookmark
+	children []*davNode
+}
+
+// child returns the child directory called name, creating it if needed.
+func (n *davNode) child(name string) *davNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &davNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// add files b in n, updating the modification times of n and the
+// directories on the way.
+func (n *davNode) add(b *db.DAVBookmark, modTime time.Time, dirs ...string) {
+	dir := n
+	for _, name := range dirs {
+		if modTime.After(dir.modTime) {
+			dir.modTime = modTime
+		}
+		dir = dir.child(name)
+	}
+	if modTime.After(dir.modTime) {
+		dir.modTime = modTime
+	}
+	dir.children = append(dir.children, &davNode{name: davFileName(*b), modTime: modTime, bookmark: b})
+}
+
+// sort orders every directory's children by name.
+func (n *davNode) sort() {
+	sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+	for _, c := range n.children {
+		c.sort()
+	}
+}
+
+// lookup returns the node at the slash-separated path name. Files are
+// found by the bookmark ID their name starts with, so a title changed since
+// the directory was listed still opens the file.
+func (n *davNode) lookup(name string) (*davNode, bool) {
+	node := n
+	for _, part := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		var next *davNode
+		for _, c := range node.children {
+			if c.name == part || (c.bookmark != nil && davFileID(part) == c.bookmark.ID) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+// davFileName names the file of an archived bookmark: its ID, then its
+// title (or URL) with characters file systems reject replaced.
+func davFileName(b db.DAVBookmark) string {
+	title := b.Title
+	if title == "" {
+		title = b.URL
+	}
+	return fmt.Sprintf("%d %s.html", b.ID, davSafeName(title))
+}
+
+// davFileID returns the bookmark ID a file name starts with, or 0.
+func davFileID(name string) int64 {
+	prefix, _, _ := strings.Cut(name, " ")
+	id, err := strconv.ParseInt(strings.TrimSuffix(prefix, ".html"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// davSafeName makes s usable as a file or directory name on common file
+// systems, capped at davMaxNameLength runes.
+func davSafeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > davMaxNameLength {
+		s = string(runes[:davMaxNameLength])
+	}
+	s = strings.Trim(s, ". ")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// davFS is a read-only webdav.FileSystem over one user's archives. It is
+// created per request, and builds the tree once from db.ListDAVBookmarks.
+type davFS struct {
+	db           *db.DB
+	stripScripts bool
+
+	once sync.Once
+	root *davNode
+	err  error
+
+	// sizes caches file sizes by bookmark ID, since a PROPFIND stats each
+	// file several times.
+	mu    sync.Mutex
+	sizes map[int64]int64
+}
+
+func (f *davFS) tree() (*davNode, error) {
+	f.once.Do(func() {
+		bookmarks, err := f.db.ListDAVBookmarks()
+		if err != nil {
+			f.err = err
+			return
+		}
+		f.root = &davNode{}
+		byTag := f.root.child("by-tag")
+		byDate := f.root.child("by-date")
+		for i := range bookmarks {
+			b := &bookmarks[i]
+			archivedAt, err := time.Parse(time.RFC3339, b.ArchivedAt)
+			if err != nil {
+				log.Printf("Skipping bookmark %d in WebDAV tree: invalid archive time %q", b.ID, b.ArchivedAt)
+				continue
+			}
+			for _, tag := range b.Tags {
+				byTag.add(b, archivedAt, davSafeName(tag))
+			}
+			byDate.add(b, archivedAt, archivedAt.Format("2006"), archivedAt.Format("01"))
+		}
+		f.root.sort()
+	})
+	return f.root, f.err
+}
+
+func (f *davFS) open(name string) (*davFile, error) {
+	root, err := f.tree()
+	if err != nil {
+		return nil, err
+	}
+	node, ok := root.lookup(name)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return &davFile{fs: f, node: node}, nil
+}
+
+func (f *davFS) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, fs.ErrPermission
+	}
+	return f.open(name)
+}
+
+func (f *davFS) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	file, err := f.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return file.Stat()
+}
+
+func (f *davFS) Mkdir(context.Context, string, os.FileMode) error { return fs.ErrPermission }
+func (f *davFS) RemoveAll(context.Context, string) error          { return fs.ErrPermission }
+func (f *davFS) Rename(context.Context, string, string) error     { return fs.ErrPermission }
+
+// davFile is an open node of the WebDAV tree. A file's HTML is loaded on
+// first use, since listing a directory opens every file in it.
+type davFile struct {
+	fs      *davFS
+	node    *davNode
+	content *bytes.Reader
+	// read is how many children Readdir has returned.
+	read int
+}
+
+// load reads the archived HTML of a file.
+func (f *davFile) load() error {
+	if f.content != nil {
+		return nil
+	}
+	if f.node.bookmark == nil {
+		return fmt.Errorf("%s is a directory", f.node.name)
+	}
+	version, err := f.fs.db.GetLatestArchiveVersion(f.node.bookmark.ID)
+	if err != nil {
+		return err
+	}
+	html := version.ArchivedHTML
+	if f.fs.stripScripts {
+		if html, err = core.StripScripts(html); err != nil {
+			return err
+		}
+	}
+	f.content = bytes.NewReader([]byte(html))
+	f.fs.mu.Lock()
+	if f.fs.sizes == nil {
+		f.fs.sizes = make(map[int64]int64)
+	}
+	f.fs.sizes[f.node.bookmark.ID] = f.content.Size()
+	f.fs.mu.Unlock()
+	return nil
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Read(p)
+}
+
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *davFile) Write([]byte) (int, error) { return 0, fs.ErrPermission }
+func (f *davFile) Close() error              { return nil }
+
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.node.bookmark != nil {
+		return nil, fmt.Errorf("%s is not a directory", f.node.name)
+	}
+	rest := f.node.children[f.read:]
+	if count > 0 {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		rest = rest[:min(count, len(rest))]
+	}
+	infos := make([]os.FileInfo, 0, len(rest))
+	for _, c := range rest {
+		child := &davFile{fs: f.fs, node: c}
+		info, err := child.Stat()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+	f.read += len(rest)
+	return infos, nil
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	info := davFileInfo{name: f.node.name, modTime: f.node.modTime, dir: f.node.bookmark == nil}
+	if info.dir {
+		return info, nil
+	}
+	f.fs.mu.Lock()
+	size, ok := f.fs.sizes[f.node.bookmark.ID]
+	f.fs.mu.Unlock()
+	if !ok {
+		if err := f.load(); err != nil {
+			return nil, err
+		}
+		size = f.content.Size()
+	}
+	info.size = size
+	return info, nil
+}
+
+// davFileInfo describes a node of the WebDAV tree.
+type davFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i davFileInfo) Name() string       { return i.name }
+func (i davFileInfo) Size() int64        { return i.size }
+func (i davFileInfo) ModTime() time.Time { return i.modTime }
+func (i davFileInfo) IsDir() bool        { return i.dir }
+func (i davFileInfo) Sys() any           { return nil }
+
+func (i davFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// ContentType lets PROPFIND report archives as HTML without reading them.
+func (i davFileInfo) ContentType(context.Context) (string, error) {
+	if i.dir {
+		return "", webdav.ErrNotImplemented
+	}
+	return "text/html; charset=utf-8", nil
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// TestHandleDAV tests browsing archives through the read-only WebDAV tree.
+func TestHandleDAV(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, strings.ReplaceAll(target, " ", "%20"), strings.NewReader(body))
+		if method == "PROPFIND" {
+			req.Header.Set("Depth", "1")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	id, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://example.com/a", Title: "Go: a/b", Tags: []string{"go"}})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	archivedAt := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	if err := server.db.SaveArchiveResult(id, archivedAt, &archivedAt, "ok", "", "https://example.com/a", "<html><body>Archived</body></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if _, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://example.com/unarchived", Tags: []string{"later"}}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	name := "/1 Go_ a_b.html"
+
+	t.Run("lists directories", func(t *testing.T) {
+		w := do("PROPFIND", "/dav/", "")
+		if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "/dav/by-tag/") || !strings.Contains(w.Body.String(), "/dav/by-date/") {
+			t.Fatalf("expected the root listing, got %d %s", w.Code, w.Body.String())
+		}
+		w = do("PROPFIND", "/dav/by-tag/", "")
+		if !strings.Contains(w.Body.String(), "/dav/by-tag/go/") || strings.Contains(w.Body.String(), "later") {
+			t.Errorf("expected only tags of archived bookmarks, got %s", w.Body.String())
+		}
+		w = do("PROPFIND", "/dav/by-date/2024/05/", "")
+		if w.Code != http.StatusMultiStatus || !strings.Contains(w.Body.String(), "Go_%20a_b.html") || !strings.Contains(w.Body.String(), "<D:getcontentlength>34</D:getcontentlength>") {
+			t.Errorf("expected the archive in its month, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("serves archives sandboxed", func(t *testing.T) {
+		for _, dir := range []string{"/dav/by-tag/go", "/dav/by-date/2024/05"} {
+			w := do(http.MethodGet, dir+name, "")
+			if w.Code != http.StatusOK || w.Body.String() != "<html><body>Archived</body></html>" {
+				t.Fatalf("expected the archive from %s, got %d %q", dir, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Header().Get("Content-Security-Policy"), "sandbox") || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+				t.Errorf("expected a sandboxed HTML response, got %v", w.Header())
+			}
+		}
+		if w := do(http.MethodGet, "/dav/by-tag/go/1 Old title.html", ""); w.Code != http.StatusOK {
+			t.Errorf("expected files to be found by ID, got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/dav/by-tag/later/2 x.html", ""); w.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for an unarchived bookmark, got %d", w.Code)
+		}
+	})
+
+	t.Run("is read-only", func(t *testing.T) {
+		w := do(http.MethodOptions, "/dav/", "")
+		if w.Header().Get("DAV") != "1" || w.Header().Get("Allow") != davMethods {
+			t.Errorf("expected DAV class 1 with read methods, got %v", w.Header())
+		}
+		for _, method := range []string{http.MethodPut, http.MethodDelete, "MKCOL", "MOVE", "LOCK", "PROPPATCH"} {
+			w := httptest.NewRecorder()
+			server.handleDAV(w, httptest.NewRequest(method, "/dav/by-tag/go/1", strings.NewReader("x")))
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("expected %s to be refused, got %d", method, w.Code)
+			}
+		}
+	})
+
+	t.Run("scopes to the user", func(t *testing.T) {
+		bob, err := server.db.CreateUser("bob", "secret-pw", false)
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		req := httptest.NewRequest("PROPFIND", "/dav/by-tag/", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+			t.Fatalf("expected a Basic challenge, got %d %v", w.Code, w.Header())
+		}
+
+		req = httptest.NewRequest("PROPFIND", "/dav/by-tag/", nil)
+		req.Header.Set("Depth", "1")
+		req.SetBasicAuth(bob.Username, "secret-pw")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusMultiStatus || strings.Contains(w.Body.String(), "/dav/by-tag/go/") {
+			t.Errorf("expected bob's empty tree, got %d %s", w.Code, w.Body.String())
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/dav/by-tag/go/1", nil)
+		req.SetBasicAuth(bob.Username, "wrong")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for wrong credentials, got %d", w.Code)
+		}
+	})
+}
+
+// TestDAVSafeName tests making titles usable as file names.
+func TestDAVSafeName(t *testing.T) {
+	tests := map[string]string{
+		"Plain title":            "Plain title",
+		`a/b\c:d*e?f"g<h>i|j`:    "a_b_c_d_e_f_g_h_i_j",
+		"  spaced\n\tout  ":      "spaced out",
+		"...":                    "_",
+		strings.Repeat("x", 200): strings.Repeat("x", davMaxNameLength),
+	}
+	for in, want := range tests {
+		if got := davSafeName(in); got != want {
+			t.Errorf("davSafeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
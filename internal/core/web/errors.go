@@ -0,0 +1,107 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// Error codes, sent with every error response as errorView's code, the
+// X-Error-Code header and the HTMX error fragment's data-error-code, so
+// clients can react to a failure without parsing its message. Failures
+// without a code of their own get their status's (see statusErrorCode).
+const (
+	errorCodeBadRequest       = "bad_request"
+	errorCodeInvalidURL       = "invalid_url"
+	errorCodeInvalidSearch    = "invalid_search"
+	errorCodeDuplicate        = "duplicate"
+	errorCodeUnauthorized     = "unauthorized"
+	errorCodeForbidden        = "forbidden"
+	errorCodeInvalidCSRFToken = "invalid_csrf_token"
+	errorCodeNotFound         = "not_found"
+	errorCodeMethodNotAllowed = "method_not_allowed"
+	errorCodeConflict         = "conflict"
+	errorCodeTooLarge         = "too_large"
+	errorCodeRateLimited      = "rate_limited"
+	errorCodeQuotaExceeded    = "quota_exceeded"
+	errorCodeInternal         = "internal"
+	errorCodeNotImplemented   = "not_implemented"
+	errorCodeUpstream         = "upstream_failed"
+	errorCodeTimeout          = "timeout"
+	errorCodeUnprocessable    = "unprocessable"
+)
+
+// statusErrorCodes are the codes of failures that have none of their own.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            errorCodeBadRequest,
+	http.StatusUnauthorized:          errorCodeUnauthorized,
+	http.StatusForbidden:             errorCodeForbidden,
+	http.StatusNotFound:              errorCodeNotFound,
+	http.StatusMethodNotAllowed:      errorCodeMethodNotAllowed,
+	http.StatusConflict:              errorCodeConflict,
+	http.StatusRequestEntityTooLarge: errorCodeTooLarge,
+	http.StatusUnprocessableEntity:   errorCodeUnprocessable,
+	http.StatusTooManyRequests:       errorCodeRateLimited,
+	http.StatusInternalServerError:   errorCodeInternal,
+	http.StatusNotImplemented:        errorCodeNotImplemented,
+	http.StatusBadGateway:            errorCodeUpstream,
+}
+
+// statusErrorCode returns the error code for a failure with status.
+func statusErrorCode(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return errorCodeInternal
+	}
+	return errorCodeBadRequest
+}
+
+// writeError fails r with status and message, coded by status; see
+// writeErrorView.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeErrorView(w, r, status, errorView{Message: message})
+}
+
+// writeErrorCode fails r with status and message, under code; see
+// writeErrorView.
+func writeErrorCode(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeErrorView(w, r, status, errorView{Code: code, Message: message})
+}
+
+// writeErrorView fails r with status and e, whose code defaults to
+// status's. JSON clients get e itself; HTMX requests get an error-message
+// fragment, which the page footer's script shows as a toast; anything else
+// gets the message as plain text, like http.Error. All of them carry the
+// code in an X-Error-Code header.
+func writeErrorView(w http.ResponseWriter, r *http.Request, status int, e errorView) {
+	if wantsJSON(r) {
+		writeJSONError(w, status, e)
+		return
+	}
+	if e.Code == "" {
+		e.Code = statusErrorCode(status)
+	}
+	w.Header().Set("X-Error-Code", e.Code)
+	switch {
+	case isHTMX(r):
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, `<div class="error-message" role="alert" data-error-code="%s">%s</div>`+"\n",
+			html.EscapeString(e.Code), html.EscapeString(e.Message))
+	default:
+		http.Error(w, e.Message, status)
+	}
+}
+
+// writeJSONError fails a request with status and e as JSON whatever it
+// accepts, for endpoints such as the launcher's that only speak JSON.
+func writeJSONError(w http.ResponseWriter, status int, e errorView) {
+	if e.Code == "" {
+		e.Code = statusErrorCode(status)
+	}
+	w.Header().Set("X-Error-Code", e.Code)
+	writeJSON(w, status, e)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestWriteErrorView tests that errors are answered as JSON, an HTMX
+// fragment or plain text, always with their code.
+func TestWriteErrorView(t *testing.T) {
+	e := errorView{Message: "Bad <thing>", Details: map[string]any{"retry_after": 3}}
+
+	t.Run("JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		writeErrorView(w, req, http.StatusTooManyRequests, e)
+
+		var got errorView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		if w.Code != http.StatusTooManyRequests || got.Code != errorCodeRateLimited || got.Message != e.Message || got.Details["retry_after"] != float64(3) {
+			t.Errorf("unexpected error %d %+v", w.Code, got)
+		}
+	})
+
+	t.Run("HTMX", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+		writeErrorCode(w, req, http.StatusBadRequest, errorCodeInvalidURL, e.Message)
+
+		want := `<div class="error-message" role="alert" data-error-code="invalid_url">Bad &lt;thing&gt;</div>`
+		if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != want {
+			t.Errorf("expected fragment %q, got %d %q", want, w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Error-Code") != errorCodeInvalidURL {
+			t.Errorf("expected X-Error-Code %q, got %q", errorCodeInvalidURL, w.Header().Get("X-Error-Code"))
+		}
+	})
+
+	t.Run("plain text", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		writeError(w, req, http.StatusNotFound, "Not found")
+
+		if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != "Not found" {
+			t.Errorf("expected plain 404, got %d %q", w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Error-Code") != errorCodeNotFound {
+			t.Errorf("expected X-Error-Code %q, got %q", errorCodeNotFound, w.Header().Get("X-Error-Code"))
+		}
+	})
+
+	t.Run("status codes", func(t *testing.T) {
+		for status, want := range map[int]string{
+			http.StatusConflict:       errorCodeConflict,
+			http.StatusTeapot:         errorCodeBadRequest,
+			http.StatusBadGateway:     errorCodeUpstream,
+			http.StatusGatewayTimeout: errorCodeInternal,
+		} {
+			if got := statusErrorCode(status); got != want {
+				t.Errorf("statusErrorCode(%d) = %q, want %q", status, got, want)
+			}
+		}
+	})
+}
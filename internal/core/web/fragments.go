@@ -0,0 +1,33 @@
+package web
+
+import "net/http"
+
+// isHTMX reports whether r was sent by htmx, which swaps the response into
+// the page, rather than by the browser loading or submitting a page itself.
+func isHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// fragmentPages registers, for each fragment template htmx loads into a
+// page, the handler of the full page that renders it in place. A browser
+// without JavaScript that follows a fragment's URL (the search form's
+// GET /bookmarks, or an archive's status link) gets that page instead of a
+// bare fragment, so every view stays usable without htmx.
+var fragmentPages = map[string]func(*Server, http.ResponseWriter, *http.Request){
+	"bookmarks.html":       (*Server).handleIndex,
+	"archives_list.html":   (*Server).handleArchiveManager,
+	"archive_item.html":    (*Server).handleArchiveManager,
+	"archive_stats.html":   (*Server).handleArchiveManager,
+	"archive_storage.html": (*Server).handleArchiveManager,
+}
+
+// renderFragment writes the fragment template name with data for htmx
+// requests. Plain GETs from browsers get the page registered for it in
+// fragmentPages; state-changing requests redirect there themselves.
+func (ws *Server) renderFragment(w http.ResponseWriter, r *http.Request, name string, data any) {
+	if page, ok := fragmentPages[name]; ok && !isHTMX(r) && r.Method == http.MethodGet {
+		page(ws, w, r)
+		return
+	}
+	ws.renderTemplate(w, name, data)
+}
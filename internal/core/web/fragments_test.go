@@ -0,0 +1,93 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFragmentFallbacks tests that pages work without htmx: they render
+// their fragments in place, fragment URLs loaded directly get the whole
+// page, and every button posts as a plain form.
+func TestFragmentFallbacks(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.AddBookmark("https://plain.example", "Plain HTML")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	get := func(handler http.HandlerFunc, path string, htmx bool) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", path, http.StatusOK, w.Code)
+		}
+		return w.Body.String()
+	}
+
+	t.Run("index renders the list and plain forms", func(t *testing.T) {
+		body := get(server.handleIndex, "/?filter=unread&search=plain", false)
+		if !strings.Contains(body, "Plain HTML") {
+			t.Error("expected the bookmark list to be rendered in the page")
+		}
+		if !strings.Contains(body, `value="plain"`) || !strings.Contains(body, `value="unread" selected`) {
+			t.Error("expected the search and filter to be filled in")
+		}
+		for _, want := range []string{
+			`action="/bookmarks"`,
+			`action="/bookmarks/bulk"`,
+			`formaction="/bookmarks/bulk/delete"`,
+			`action="/bookmarks/` + itoa(id) + `/favorite"`,
+			`action="/bookmarks/` + itoa(id) + `/mark-read"`,
+			`action="/bookmarks/` + itoa(id) + `/notes"`,
+			`name="` + csrfFormField + `"`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected the page to contain %s", want)
+			}
+		}
+	})
+
+	t.Run("bookmark list is a fragment for htmx only", func(t *testing.T) {
+		if body := get(server.handleBookmarks, "/bookmarks", true); strings.Contains(body, "<html") {
+			t.Error("expected a bare fragment for htmx")
+		}
+		body := get(server.handleBookmarks, "/bookmarks?search=plain", false)
+		if !strings.Contains(body, "<html") || !strings.Contains(body, "Plain HTML") {
+			t.Error("expected the whole page with the list for a browser")
+		}
+	})
+
+	t.Run("archive manager renders its cards", func(t *testing.T) {
+		body := get(server.handleArchiveManager, "/archives", false)
+		if !strings.Contains(body, "Plain HTML") || !strings.Contains(body, "Queue depth") {
+			t.Error("expected the archive list and stats to be rendered in the page")
+		}
+		if !strings.Contains(body, `action="/archives/`+itoa(id)+`/refetch"`) {
+			t.Error("expected refetch to post as a plain form")
+		}
+	})
+
+	t.Run("archive fragments fall back to the page", func(t *testing.T) {
+		for _, path := range []string{"/archives/list", "/archives/stats", "/archives/" + itoa(id) + "/status"} {
+			if body := get(server.handleArchivesRoutes, path, false); !strings.Contains(body, "<html") {
+				t.Errorf("%s: expected the whole page for a browser", path)
+			}
+			if body := get(server.handleArchivesRoutes, path, true); strings.Contains(body, "<html") {
+				t.Errorf("%s: expected a bare fragment for htmx", path)
+			}
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package web
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 )
 
 // renderTemplate renders a template with the standard HTML content-type header.
@@ -19,8 +21,22 @@ func (ws *Server) renderTemplate(w http.ResponseWriter, templateName string, dat
 // Returns true if the method matches, false otherwise (and sends 405 response).
 func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
 	if r.Method != method {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return false
 	}
 	return true
 }
+
+// wantsJSON reports whether the client asked for a JSON response.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
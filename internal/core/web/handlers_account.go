@@ -0,0 +1,75 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// accountDeleteConfirmation must be typed into the confirm field to delete
+// an account's data, so a stray click can't wipe it.
+const accountDeleteConfirmation = "DELETE"
+
+// handleAccountExport downloads everything stored for the current user as a
+// single JSON document (see core.ExportUserData). A q parameter, in the
+// search syntax, exports only the bookmarks it finds.
+func (ws *Server) handleAccountExport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	export, err := core.ExportUserData(ws.userDB(r), requestUserID(r), r.URL.Query().Get("q"))
+	if errors.Is(err, db.ErrInvalidSearch) {
+		writeErrorCode(w, r, http.StatusBadRequest, errorCodeInvalidSearch, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to export data")
+		log.Printf("Failed to export user data: %v", err)
+		return
+	}
+	body, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to export data")
+		log.Printf("Failed to encode user data export: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="bookmarkd-export-%s.json"`, time.Now().UTC().Format("20060102")))
+	_, _ = w.Write(body)
+}
+
+// handleAccountDelete permanently deletes the current user's bookmarks,
+// archives and settings. The form must carry confirm=DELETE.
+func (ws *Server) handleAccountDelete(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	if r.FormValue("confirm") != accountDeleteConfirmation {
+		writeError(w, r, http.StatusBadRequest, "Type "+accountDeleteConfirmation+" to confirm deleting your data")
+		return
+	}
+	n, err := ws.userDB(r).DeleteUserData(requestUserID(r))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to delete data")
+		log.Printf("Failed to delete user data after %d bookmarks: %v", n, err)
+		return
+	}
+	log.Printf("Deleted all data of user %d (%d bookmarks)", requestUserID(r), n)
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]int{"deleted_bookmarks": n})
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
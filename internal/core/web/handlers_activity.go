@@ -0,0 +1,120 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// activityPageSize is how many entries /activity shows per page.
+const activityPageSize = 100
+
+// activityKindLabels name the activity kinds on the page.
+var activityKindLabels = map[string]string{
+	db.ActivityBookmarkAdded:    "Bookmark added",
+	db.ActivityBookmarkDeleted:  "Bookmark deleted",
+	db.ActivityArchiveCompleted: "Archive completed",
+	db.ActivityArchiveFailed:    "Archive failed",
+	db.ActivityImportFinished:   "Import finished",
+	db.ActivityVisualChange:     "Visual change",
+}
+
+// activityKindOption is a kind checkbox in the /activity filter form.
+type activityKindOption struct {
+	Kind     string
+	Label    string
+	Selected bool
+}
+
+// handleActivity shows the activity log, newest first. Repeated "kind"
+// parameters (see db.ActivityKinds) narrow it to those kinds, and "before"
+// pages back from an entry ID. JSON clients get the entries and the
+// next_before cursor, which is 0 on the last page. The log is
+// instance-wide, so only admins may see it.
+func (ws *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) || !ws.requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	var filter db.ActivityFilter
+	for _, kind := range q["kind"] {
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(db.ActivityKinds, kind) {
+			writeError(w, r, http.StatusBadRequest, "Invalid kind")
+			return
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || before <= 0 {
+			writeError(w, r, http.StatusBadRequest, "Invalid before")
+			return
+		}
+		filter.Before = before
+	}
+
+	// Fetch one extra entry to know whether there is an older page.
+	entries, err := ws.requestDB(r).ListActivity(filter, activityPageSize+1)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list activity: %v", err)
+		return
+	}
+	var nextBefore int64
+	if len(entries) > activityPageSize {
+		entries = entries[:activityPageSize]
+		nextBefore = entries[len(entries)-1].ID
+	}
+	views := []activityView{}
+	for _, e := range entries {
+		views = append(views, newActivityView(e))
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]any{"entries": views, "next_before": nextBefore})
+		return
+	}
+
+	options := make([]activityKindOption, 0, len(db.ActivityKinds))
+	for _, kind := range db.ActivityKinds {
+		options = append(options, activityKindOption{
+			Kind:     kind,
+			Label:    activityKindLabels[kind],
+			Selected: slices.Contains(filter.Kinds, kind),
+		})
+	}
+	olderURL := ""
+	if nextBefore != 0 {
+		older := url.Values{"kind": filter.Kinds, "before": {fmt.Sprint(nextBefore)}}
+		olderURL = "/activity?" + older.Encode()
+	}
+	ws.renderTemplate(w, "activity.html", map[string]any{
+		"Entries":    views,
+		"Kinds":      options,
+		"OlderURL":   olderURL,
+		"ActivePage": "activity",
+		"CSRFToken":  csrfToken(r),
+	})
+}
+
+func newActivityView(e db.ActivityEntry) activityView {
+	return activityView{
+		ID:            e.ID,
+		Kind:          e.Kind,
+		Label:         activityKindLabels[e.Kind],
+		BookmarkID:    e.BookmarkID,
+		BookmarkURL:   e.BookmarkURL,
+		BookmarkTitle: e.BookmarkTitle,
+		Detail:        e.Detail,
+		CreatedAt:     e.CreatedAt,
+	}
+}
@@ -2,33 +2,122 @@ package web
 
 import (
 	"fmt"
+	"html/template"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/seckatie/bookmarkd/internal/core"
 	"github.com/seckatie/bookmarkd/internal/core/db"
 )
 
-// handleArchive routes archive-related requests
+// handleArchive routes per-bookmark requests under /bookmarks/{id}/
 func (ws *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Parse bookmark ID from URL: /bookmarks/{id}/archive or /bookmarks/{id}/archive/raw
+	// Parse bookmark ID from URL: /bookmarks/{id}/archive, /bookmarks/{id}/archive/raw,
+	// /bookmarks/{id}/archive/screenshot, /bookmarks/{id}/archive/download,
+	// /bookmarks/{id}/archive/audio,
+	// /bookmarks/{id}/archive/provenance,
+	// /bookmarks/{id}/archive/timestamp, /bookmarks/{id}/archive/attempts,
+	// /bookmarks/{id}/archive/diff,
+	// /bookmarks/{id}/read, /bookmarks/{id}/favicon, /bookmarks/{id}/links,
+	// /bookmarks/{id}/qr, /bookmarks/{id}/slug,
+	// /bookmarks/{id}/notes,
+	// /bookmarks/{id}/mark-read, /bookmarks/{id}/favorite,
+	// /bookmarks/{id}/pin or /bookmarks/{id}/refresh-metadata. {id} may also
+	// be the bookmark's slug, as in share links.
 	path := strings.TrimPrefix(r.URL.Path, "/bookmarks/")
 	parts := strings.Split(path, "/")
 	if len(parts) < 2 {
-		http.Error(w, "Not Found", http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, "Not Found")
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid bookmark ID", http.StatusBadRequest)
+		if id, err = ws.userDB(r).GetBookmarkIDBySlug(parts[0]); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid bookmark ID")
+			return
+		}
+	}
+
+	if parts[1] == "refresh-metadata" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.refreshMetadata(w, r, id)
+		return
+	}
+
+	if parts[1] == "slug" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.updateSlug(w, r, id)
+		return
+	}
+
+	if parts[1] == "notes" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.updateNotes(w, r, id)
+		return
+	}
+
+	if parts[1] == "mark-read" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.setRead(w, r, id)
+		return
+	}
+
+	if parts[1] == "favorite" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.toggleFavorite(w, r, id)
+		return
+	}
+
+	if parts[1] == "pin" {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		ws.setPinned(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "audio" {
+		ws.handleArchiveAudio(w, r, id)
+		return
+	}
+
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Reader-mode view
+	if parts[1] == "read" {
+		ws.viewReader(w, r, id)
+		return
+	}
+
+	if parts[1] == "favicon" {
+		ws.serveFavicon(w, r, id)
+		return
+	}
+
+	if parts[1] == "links" {
+		ws.bookmarkLinks(w, r, id)
+		return
+	}
+
+	if parts[1] == "qr" {
+		ws.serveBookmarkQR(w, r, id)
 		return
 	}
 
@@ -38,88 +127,648 @@ func (ws *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(parts) >= 3 && parts[2] == "screenshot" {
+		ws.serveArchiveScreenshot(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "download" {
+		ws.serveArchiveDownload(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "provenance" {
+		ws.serveArchiveProvenance(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "timestamp" {
+		ws.serveArchiveTimestamp(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "attempts" {
+		ws.serveArchiveAttempts(w, r, id)
+		return
+	}
+
+	if len(parts) >= 3 && parts[2] == "diff" {
+		ws.serveArchiveDiff(w, r, id)
+		return
+	}
+
 	ws.viewArchive(w, r, id)
 }
 
-// viewArchive renders the archive viewer page with iframe
-func (ws *Server) viewArchive(w http.ResponseWriter, _ *http.Request, id int64) {
-	bookmark, err := ws.db.GetBookmark(id)
+// viewArchive renders the archive viewer page with iframe.
+// An optional ?version={versionID} selects an older snapshot; the latest is shown by default.
+func (ws *Server) viewArchive(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
 	if err != nil {
-		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
 		return
 	}
 
-	archive, err := ws.db.GetBookmarkArchive(id)
-	if err != nil || archive.ArchiveStatus != core.ArchiveStatusOK {
-		http.Error(w, "Archive not available", http.StatusNotFound)
+	archive, err := ws.userDB(r).GetBookmarkArchiveStatus(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	versions, err := ws.userDB(r).ListArchiveVersions(id)
+	if err != nil || len(versions) == 0 {
+		// Pages that never archived fall back to their Wayback Machine or
+		// archive.today snapshot.
+		if err == nil && archive.WaybackURL != "" {
+			http.Redirect(w, r, archive.WaybackURL, http.StatusFound)
+			return
+		}
+		if err == nil && archive.ArchiveTodayURL != "" {
+			http.Redirect(w, r, archive.ArchiveTodayURL, http.StatusFound)
+			return
+		}
+		writeError(w, r, http.StatusNotFound, "Archive not available")
 		return
 	}
 
+	i := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var ok bool
+		if i, ok = findArchiveVersion(w, r, versions, v); !ok {
+			return
+		}
+	}
+	selected := versions[i]
+	// Any snapshot but the oldest can be compared with the one before it.
+	var diffURL string
+	if i+1 < len(versions) {
+		diffURL = archiveDiffURL(id, versions[i+1], selected)
+	}
+
+	ws.markRead(r, id)
+
 	view := map[string]any{
-		"ID":         bookmark.ID,
-		"URL":        bookmark.URL,
-		"Title":      bookmark.Title,
-		"RawURL":     fmt.Sprintf("/bookmarks/%d/archive/raw", id),
-		"ActivePage": "archives",
+		"ID":              bookmark.ID,
+		"URL":             bookmark.URL,
+		"Title":           bookmark.Title,
+		"RawURL":          fmt.Sprintf("/bookmarks/%d/archive/raw?version=%d", id, selected.ID),
+		"ScreenshotURL":   screenshotURL(id, selected),
+		"DownloadURL":     downloadURL(id, selected),
+		"AudioURL":        audioURL(id, selected),
+		"AllowScripts":    !ws.stripScripts,
+		"ProvenanceURL":   provenanceURL(id, selected),
+		"TimestampURL":    timestampURL(id, selected),
+		"WaybackURL":      archive.WaybackURL,
+		"ArchiveTodayURL": archive.ArchiveTodayURL,
+		"ReaderURL":       fmt.Sprintf("/bookmarks/%d/read", id),
+		"SaveURL":         fmt.Sprintf("/bookmarks/%d/archive/raw?version=%d&download=1", id, selected.ID),
+		"Slug":            bookmark.Slug,
+		"ShareURL":        shareURL(r, bookmark),
+		"QRURL":           qrURL(id, "original"),
+		"ArchiveQRURL":    qrURL(id, "archive"),
+		"Versions":        versions,
+		"SelectedVersion": selected.ID,
+		"DiffURL":         diffURL,
+		"ActivePage":      "archives",
+		"CSRFToken":       csrfToken(r),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := ws.templates.ExecuteTemplate(w, "viewer.html", view); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		log.Printf("Failed to execute viewer template: %v", err)
 		return
 	}
 }
 
-// serveArchiveHTML serves the raw archived HTML content
-func (ws *Server) serveArchiveHTML(w http.ResponseWriter, _ *http.Request, id int64) {
-	archive, err := ws.db.GetBookmarkArchive(id)
+// findArchiveVersion returns the index in versions of the version whose ID
+// is param, or writes an error and returns false if there is none.
+func findArchiveVersion(w http.ResponseWriter, r *http.Request, versions []db.ArchiveVersion, param string) (int, bool) {
+	versionID, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+		return 0, false
+	}
+	for i, version := range versions {
+		if version.ID == versionID {
+			return i, true
+		}
+	}
+	writeError(w, r, http.StatusNotFound, "Archive version not found")
+	return 0, false
+}
+
+// markRead records that r's user opened a bookmark, so unread cleanup rules
+// skip it.
+func (ws *Server) markRead(r *http.Request, id int64) {
+	if err := ws.userDB(r).MarkBookmarkRead(id); err != nil {
+		log.Printf("Failed to mark bookmark %d read: %v", id, err)
+	}
+}
+
+// viewReader renders the reader-mode (readability) view of an archive
+func (ws *Server) viewReader(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	readable, err := ws.userDB(r).GetBookmarkReadable(id)
+	if err != nil || readable.Content == "" {
+		writeError(w, r, http.StatusNotFound, "Reader view not available")
 		return
 	}
 
-	if archive.ArchiveStatus != core.ArchiveStatusOK || archive.ArchivedHTML == "" {
-		http.Error(w, "Archive not available", http.StatusNotFound)
+	ws.markRead(r, id)
+
+	title := readable.Title
+	if title == "" {
+		title = bookmark.Title
+	}
+	var audio string
+	if versions, err := ws.userDB(r).ListArchiveVersions(id); err == nil && len(versions) > 0 {
+		audio = audioURL(id, versions[0])
+	}
+
+	// Content is sanitized by core.ExtractArticle before it is stored, so it is
+	// safe to render without escaping.
+	ws.renderTemplate(w, "reader.html", map[string]any{
+		"ID":         bookmark.ID,
+		"URL":        bookmark.URL,
+		"Title":      title,
+		"Byline":     readable.Byline,
+		"Content":    template.HTML(readable.Content),
+		"ArchiveURL": fmt.Sprintf("/bookmarks/%d/archive", id),
+		"AudioURL":   audio,
+		"CanSpeak":   ws.tts != nil,
+		"ActivePage": "archives",
+		"CSRFToken":  csrfToken(r),
+	})
+}
+
+// serveArchiveHTML serves the raw archived HTML content.
+// An optional ?version={versionID} selects an older snapshot; the latest is served by default.
+// With ?download=1 it is sent as an attachment named after the bookmark's slug.
+func (ws *Server) serveArchiveHTML(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	var version db.ArchiveVersion
+	if v := r.URL.Query().Get("version"); v != "" {
+		versionID, parseErr := strconv.ParseInt(v, 10, 64)
+		if parseErr != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+		version, err = ws.userDB(r).GetArchiveVersion(id, versionID)
+	} else {
+		version, err = ws.userDB(r).GetLatestArchiveVersion(id)
+	}
+	if err != nil || version.ArchivedHTML == "" {
+		writeError(w, r, http.StatusNotFound, "Archive not available")
 		return
 	}
 
+	html := version.ArchivedHTML
+	if ws.stripScripts {
+		if html, err = core.StripScripts(html); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to strip scripts from archive for id=%d: %v", id, err)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write([]byte(archive.ArchivedHTML)); err != nil {
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": bookmark.FileName(".html")}))
+	}
+	w.Header().Set("Content-Security-Policy", archiveCSP(!ws.stripScripts))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write([]byte(html)); err != nil {
 		log.Printf("Failed to write archived HTML: %v", err)
 	}
 }
 
-// handleArchiveManager serves the archive manager page
+// archiveCSP is the Content-Security-Policy archived pages are served with.
+// It sandboxes them into an opaque origin, so their scripts can't read the
+// app's cookies or call its API, and only lets them load what the archiver
+// inlined as data: URLs; resources left as remote URLs aren't fetched, which
+// also keeps viewing an archive from reaching the original site. Scripts run
+// only if allowScripts is set.
+func archiveCSP(allowScripts bool) string {
+	script, sandbox := "'none'", "sandbox allow-popups allow-popups-to-escape-sandbox"
+	if allowScripts {
+		script, sandbox = "'unsafe-inline' data:", sandbox+" allow-scripts"
+	}
+	return "default-src 'none'; img-src data: blob:; media-src data: blob:; font-src data:; " +
+		"style-src 'unsafe-inline' data:; script-src " + script + "; " +
+		"form-action 'none'; base-uri 'none'; frame-ancestors 'self'; " + sandbox
+}
+
+// serveArchiveScreenshot serves the screenshot captured with an archive.
+// An optional ?version={versionID} selects an older snapshot; the latest is served by default.
+func (ws *Server) serveArchiveScreenshot(w http.ResponseWriter, r *http.Request, id int64) {
+	var versionID int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if versionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+	} else {
+		latest, err := ws.userDB(r).GetLatestArchiveVersion(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Screenshot not available")
+			return
+		}
+		versionID = latest.ID
+	}
+
+	image, err := ws.userDB(r).GetArchiveScreenshot(id, versionID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Screenshot not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(image))
+	if _, err := w.Write(image); err != nil {
+		log.Printf("Failed to write screenshot: %v", err)
+	}
+}
+
+// serveArchiveDownload serves the file a bookmark downloaded instead of
+// opening a page, as an attachment so the browser never renders it.
+// An optional ?version={versionID} selects an older snapshot; the latest is served by default.
+func (ws *Server) serveArchiveDownload(w http.ResponseWriter, r *http.Request, id int64) {
+	var versionID int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if versionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+	} else {
+		latest, err := ws.userDB(r).GetLatestArchiveVersion(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Download not available")
+			return
+		}
+		versionID = latest.ID
+	}
+
+	d, err := ws.userDB(r).GetArchiveDownload(id, versionID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Download not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", d.MIMEType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": d.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(d.Data); err != nil {
+		log.Printf("Failed to write download: %v", err)
+	}
+}
+
+// serveFavicon serves a bookmark's stored favicon.
+func (ws *Server) serveFavicon(w http.ResponseWriter, r *http.Request, id int64) {
+	favicon, err := ws.userDB(r).GetBookmarkFavicon(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Favicon not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", favicon.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	// Icons come from arbitrary sites; SVGs must not run scripts if opened directly.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(favicon.Data); err != nil {
+		log.Printf("Failed to write favicon: %v", err)
+	}
+}
+
+// faviconURL returns the icon to show for a bookmark: the stored copy if
+// there is one, otherwise the live URL from its metadata, or "".
+func faviconURL(database *db.DB, id int64) string {
+	if ok, err := database.HasBookmarkFavicon(id); err == nil && ok {
+		return fmt.Sprintf("/bookmarks/%d/favicon", id)
+	}
+	if meta, err := database.GetBookmarkMetadata(id); err == nil {
+		return meta.FaviconURL
+	}
+	return ""
+}
+
+// serveArchiveProvenance serves, as JSON, the provenance record saved with
+// an archive. An optional ?version={versionID} selects an older snapshot;
+// the latest is described by default.
+func (ws *Server) serveArchiveProvenance(w http.ResponseWriter, r *http.Request, id int64) {
+	var versionID int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if versionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+	} else {
+		latest, err := ws.userDB(r).GetLatestArchiveVersion(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Provenance not available")
+			return
+		}
+		versionID = latest.ID
+	}
+
+	provenance, err := core.GetArchiveProvenance(ws.userDB(r), id, versionID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Provenance not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, provenance)
+}
+
+// archiveTimestampView is the JSON form of an archive version's RFC 3161
+// timestamp. Token is base64 DER, for checking with openssl ts -verify.
+type archiveTimestampView struct {
+	VersionID     int64  `json:"version_id"`
+	ContentHash   string `json:"content_hash"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	Authority     string `json:"authority"`
+	TimestampedAt string `json:"timestamped_at"`
+	Token         []byte `json:"token"`
+}
+
+// serveArchiveTimestamp serves, as JSON, the RFC 3161 timestamp saved with an
+// archive. An optional ?version={versionID} selects an older snapshot; the
+// latest is used by default.
+func (ws *Server) serveArchiveTimestamp(w http.ResponseWriter, r *http.Request, id int64) {
+	var versionID int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if versionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+	} else {
+		latest, err := ws.userDB(r).GetLatestArchiveVersion(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Timestamp not available")
+			return
+		}
+		versionID = latest.ID
+	}
+
+	ts, err := ws.userDB(r).GetArchiveTimestamp(id, versionID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Timestamp not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, archiveTimestampView{
+		VersionID:     ts.VersionID,
+		ContentHash:   ts.ContentHash,
+		HashAlgorithm: "sha256",
+		Authority:     ts.Authority,
+		TimestampedAt: ts.TimestampedAt,
+		Token:         ts.Token,
+	})
+}
+
+// archiveAttemptView is the JSON form of a db.ArchiveAttempt.
+type archiveAttemptView struct {
+	JobID         int64  `json:"job_id,omitempty"`
+	Attempt       int    `json:"attempt"`
+	StartedAt     string `json:"started_at"`
+	FinishedAt    string `json:"finished_at"`
+	Status        string `json:"status"`
+	ErrorCode     string `json:"error_code,omitempty"`
+	Error         string `json:"error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+}
+
+// serveArchiveAttempts serves, as JSON, the bookmark's recent archive
+// attempts, newest first, with the retries the queue scheduled.
+func (ws *Server) serveArchiveAttempts(w http.ResponseWriter, r *http.Request, id int64) {
+	attempts, err := ws.userDB(r).ListArchiveAttempts(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	views := make([]archiveAttemptView, 0, len(attempts))
+	for _, a := range attempts {
+		views = append(views, archiveAttemptView{
+			JobID:         a.JobID,
+			Attempt:       a.Attempt,
+			StartedAt:     a.StartedAt,
+			FinishedAt:    a.FinishedAt,
+			Status:        a.Status,
+			ErrorCode:     a.ErrorCode,
+			Error:         a.Error,
+			NextAttemptAt: a.NextAttemptAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// archiveDiffVersionView names one side of an archiveDiffView.
+type archiveDiffVersionView struct {
+	ID         int64  `json:"id"`
+	CapturedAt string `json:"captured_at"`
+}
+
+// archiveDiffView is the JSON form of a text diff between two snapshots.
+type archiveDiffView struct {
+	BookmarkID int64                  `json:"bookmark_id"`
+	From       archiveDiffVersionView `json:"from"`
+	To         archiveDiffVersionView `json:"to"`
+	core.ArchiveDiff
+}
+
+// serveArchiveDiff renders a paragraph-level diff of the readable text of
+// two snapshots, ?from={versionID} and ?to={versionID}. to defaults to the
+// latest snapshot and from to the one captured before to.
+func (ws *Server) serveArchiveDiff(w http.ResponseWriter, r *http.Request, id int64) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	versions, err := ws.userDB(r).ListArchiveVersions(id)
+	if err != nil || len(versions) < 2 {
+		writeError(w, r, http.StatusNotFound, "Not enough snapshots to compare")
+		return
+	}
+
+	to := 0
+	if v := r.URL.Query().Get("to"); v != "" {
+		var ok bool
+		if to, ok = findArchiveVersion(w, r, versions, v); !ok {
+			return
+		}
+	}
+	from := to + 1
+	if v := r.URL.Query().Get("from"); v != "" {
+		var ok bool
+		if from, ok = findArchiveVersion(w, r, versions, v); !ok {
+			return
+		}
+	}
+	if from >= len(versions) {
+		writeError(w, r, http.StatusNotFound, "No earlier snapshot to compare with")
+		return
+	}
+
+	var texts [2][]string
+	for n, i := range []int{from, to} {
+		version, err := ws.userDB(r).GetArchiveVersion(id, versions[i].ID)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Archive version not found")
+			return
+		}
+		if version.HasDownload || version.ArchivedHTML == "" {
+			writeError(w, r, http.StatusUnprocessableEntity, "Snapshot "+version.CapturedAt+" has no page text to compare")
+			return
+		}
+		if texts[n], err = core.ArchiveText(version.ArchivedHTML, bookmark.URL); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to read snapshot text")
+			log.Printf("Failed to extract text of bookmark %d version %d: %v", id, version.ID, err)
+			return
+		}
+	}
+
+	view := archiveDiffView{
+		BookmarkID:  id,
+		From:        archiveDiffVersionView{ID: versions[from].ID, CapturedAt: versions[from].CapturedAt},
+		To:          archiveDiffVersionView{ID: versions[to].ID, CapturedAt: versions[to].CapturedAt},
+		ArchiveDiff: core.DiffArchiveText(texts[0], texts[1], core.ArchiveDiffContext),
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	ws.renderTemplate(w, "archive_diff.html", map[string]any{
+		"ID":         bookmark.ID,
+		"URL":        bookmark.URL,
+		"Title":      bookmark.Title,
+		"Diff":       view,
+		"Versions":   versions,
+		"ArchiveURL": fmt.Sprintf("/bookmarks/%d/archive", id),
+		"ActivePage": "archives",
+		"CSRFToken":  csrfToken(r),
+	})
+}
+
+// archiveDiffURL links the diff from one snapshot of a bookmark to another.
+func archiveDiffURL(id int64, from, to db.ArchiveVersion) string {
+	return fmt.Sprintf("/bookmarks/%d/archive/diff?from=%d&to=%d", id, from.ID, to.ID)
+}
+
+// timestampURL links a version's timestamp, or returns "" if it has none.
+func timestampURL(id int64, version db.ArchiveVersion) string {
+	if !version.HasTimestamp {
+		return ""
+	}
+	return fmt.Sprintf("/bookmarks/%d/archive/timestamp?version=%d", id, version.ID)
+}
+
+// provenanceURL links a version's provenance record, or returns "" if it has none.
+func provenanceURL(id int64, version db.ArchiveVersion) string {
+	if !version.HasProvenance {
+		return ""
+	}
+	return fmt.Sprintf("/bookmarks/%d/archive/provenance?version=%d", id, version.ID)
+}
+
+// downloadURL links a version's downloaded file, or returns "" if it has none.
+func downloadURL(id int64, version db.ArchiveVersion) string {
+	if !version.HasDownload {
+		return ""
+	}
+	return fmt.Sprintf("/bookmarks/%d/archive/download?version=%d", id, version.ID)
+}
+
+// screenshotURL links a version's screenshot, or returns "" if it has none.
+func screenshotURL(id int64, version db.ArchiveVersion) string {
+	if !version.HasScreenshot {
+		return ""
+	}
+	return fmt.Sprintf("/bookmarks/%d/archive/screenshot?version=%d", id, version.ID)
+}
+
+// handleArchiveManager serves the archive manager page, with the progress
+// card and archive list filled in so it works without htmx. Admins also get
+// the storage card.
 func (ws *Server) handleArchiveManager(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	data := map[string]any{"ActivePage": "archives"}
-	if err := ws.templates.ExecuteTemplate(w, "archives.html", data); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Failed to execute archives template: %v", err)
+	stats, err := ws.userDB(r).GetArchiveStats()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get archive stats: %v", err)
+		return
+	}
+	archives, err := ws.listArchiveViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get bookmarks: %v", err)
+		return
+	}
+	data := map[string]any{
+		"ActivePage": "archives",
+		"IsAdmin":    ws.isAdmin(r),
+		"CSRFToken":  csrfToken(r),
+		"Stats":      newArchiveStatsView(stats, ws.archiveWorkers, time.Now()),
+		"List":       map[string]any{"archives": archives},
 	}
+	if ws.isAdmin(r) {
+		samples, err := ws.requestDB(r).ListStorageSamples(time.Now().Add(-core.StorageForecastWindow))
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to list storage samples: %v", err)
+			return
+		}
+		data["Storage"] = newStorageView(samples)
+	}
+	ws.renderTemplate(w, "archives.html", data)
 }
 
-// buildArchiveManagerView builds an archiveManagerView from a bookmark
-func (ws *Server) buildArchiveManagerView(b db.Bookmark) archiveManagerView {
+// buildArchiveManagerView builds an archiveManagerView from a bookmark,
+// looking up its archive through database.
+func (ws *Server) buildArchiveManagerView(database *db.DB, b db.Bookmark) archiveManagerView {
 	view := archiveManagerView{
-		ID:    b.ID,
-		URL:   b.URL,
-		Title: b.Title,
+		ID:             b.ID,
+		URL:            b.URL,
+		Title:          b.Title,
+		FaviconURL:     faviconURL(database, b.ID),
+		WaybackEnabled: ws.wayback != nil,
+
+		ArchiveTodayEnabled: ws.archiveToday != nil,
 	}
-	archive, err := ws.db.GetBookmarkArchive(b.ID)
+	archive, err := database.GetBookmarkArchiveStatus(b.ID)
 	if err == nil {
 		view.ArchiveStatus = archive.ArchiveStatus
 		view.ArchivedAt = archive.ArchivedAt
 		view.ArchiveAttemptedAt = archive.ArchiveAttemptedAt
 		view.ArchiveError = archive.ArchiveError
+		view.ArchiveErrorCode = archive.ArchiveErrorCode
+		view.RearchiveDisabled = archive.RearchiveDisabled
+		view.WaybackURL = archive.WaybackURL
+		view.ArchiveTodayURL = archive.ArchiveTodayURL
+		view.ArchiveTodayDisabled = archive.ArchiveTodayDisabled
+		if archive.Size > 0 {
+			view.Size = core.FormatBytes(archive.Size)
+		}
 		// IsArchiving is true when there's no archived_at (queued/in-progress)
 		// but not when it's an error state
-		view.IsArchiving = archive.ArchivedAt == "" && archive.ArchiveStatus != core.ArchiveStatusError
+		view.IsArchiving = archive.ArchivedAt == "" && archive.ArchiveStatus != core.ArchiveStatusError && archive.ArchiveStatus != core.ArchiveStatusSkipped
 	} else {
 		// If we can't get archive info, assume it needs archiving
 		view.IsArchiving = true
@@ -130,28 +779,98 @@ func (ws *Server) buildArchiveManagerView(b db.Bookmark) archiveManagerView {
 // handleArchivesList serves the archives list fragment
 func (ws *Server) handleArchivesList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
-	bookmarks, err := ws.db.ListBookmarks(0)
+	archivesData, err := ws.listArchiveViews(r)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		log.Printf("Failed to get bookmarks: %v", err)
 		return
 	}
+	ws.renderFragment(w, r, "archives_list.html", map[string]any{"archives": archivesData})
+}
 
+// listArchiveViews lists the archive status of every bookmark r's user
+// sees.
+func (ws *Server) listArchiveViews(r *http.Request) ([]archiveManagerView, error) {
+	database := ws.userDB(r)
+	bookmarks, err := database.ListBookmarks(0)
+	if err != nil {
+		return nil, err
+	}
 	var archivesData []archiveManagerView
 	for _, b := range bookmarks {
-		archivesData = append(archivesData, ws.buildArchiveManagerView(b))
+		view := ws.buildArchiveManagerView(database, b)
+		view.CSRFToken = csrfToken(r)
+		archivesData = append(archivesData, view)
 	}
+	return archivesData, nil
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := ws.templates.ExecuteTemplate(w, "archives_list.html", map[string]any{"archives": archivesData}); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Failed to execute archives list template: %v", err)
+// handleArchivesStats serves archive progress for the dashboard: counts by
+// status, queue depth, average archive duration and running jobs. Clients
+// that send Accept: application/json get the same data as JSON.
+func (ws *Server) handleArchivesStats(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	stats, err := ws.userDB(r).GetArchiveStats()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get archive stats: %v", err)
+		return
+	}
+	view := newArchiveStatsView(stats, ws.archiveWorkers, time.Now())
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
 		return
 	}
+
+	ws.renderFragment(w, r, "archive_stats.html", view)
+}
+
+// newArchiveStatsView converts db stats for display at now, estimating the
+// queue wait for the given number of workers.
+func newArchiveStatsView(s db.ArchiveStats, workers int, now time.Time) archiveStatsView {
+	view := archiveStatsView{
+		Pending:            s.Pending,
+		InProgress:         s.InProgress,
+		OK:                 s.OK,
+		Error:              s.Error,
+		ErrorsByCode:       s.ErrorsByCode,
+		Skipped:            s.Skipped,
+		QueueDepth:         s.Queued,
+		Retrying:           s.Retrying,
+		FailedJobs:         s.FailedJobs,
+		Deferred:           s.Deferred,
+		AvgDurationSeconds: s.AvgDurationSeconds,
+		Active:             []activeJobView{},
+	}
+	if s.AvgDurationSeconds > 0 {
+		view.AvgDuration = (time.Duration(s.AvgDurationSeconds*10) * time.Second / 10).String()
+	}
+	if wait := core.EstimateQueueWait(s.Queued, workers, s.AvgDurationSeconds); wait > 0 {
+		view.EstimatedWaitSeconds = wait.Seconds()
+		view.EstimatedWait = wait.Truncate(time.Second).String()
+	}
+	for _, a := range s.Active {
+		job := activeJobView{
+			BookmarkID: a.BookmarkID,
+			URL:        a.URL,
+			Title:      a.Title,
+			Attempts:   a.Attempts,
+			StartedAt:  a.StartedAt,
+		}
+		if started, err := time.Parse(time.RFC3339, a.StartedAt); err == nil {
+			job.Elapsed = now.Sub(started).Truncate(time.Second).String()
+		}
+		view.Active = append(view.Active, job)
+	}
+	return view
 }
 
 // handleArchivesRoutes routes archive management requests
@@ -164,66 +883,229 @@ func (ws *Server) handleArchivesRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle /archives/{id}/refetch and /archives/{id}/status
+	// Handle /archives/stats
+	if path == "stats" {
+		ws.handleArchivesStats(w, r)
+		return
+	}
+
+	// Handle /archives/storage
+	if path == "storage" {
+		ws.handleArchivesStorage(w, r)
+		return
+	}
+
+	// Handle /archives/{id}/refetch, /archives/{id}/status, /archives/{id}/rearchive,
+	// /archives/{id}/wayback and /archives/{id}/archive-today
 	parts := strings.Split(path, "/")
 	if len(parts) >= 2 {
 		id, err := strconv.ParseInt(parts[0], 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid bookmark ID", http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, "Invalid bookmark ID")
 			return
 		}
 
 		switch parts[1] {
 		case "refetch":
 			if r.Method != http.MethodPost {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 				return
 			}
 			ws.refetchArchive(w, r, id)
 			return
 		case "status":
 			if r.Method != http.MethodGet {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 				return
 			}
 			ws.getArchiveItemStatus(w, r, id)
 			return
+		case "rearchive":
+			if r.Method != http.MethodPost {
+				writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+				return
+			}
+			ws.setRearchive(w, r, id)
+			return
+		case "wayback":
+			if r.Method != http.MethodPost {
+				writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+				return
+			}
+			ws.submitWayback(w, r, id)
+			return
+		case "archive-today":
+			if r.Method != http.MethodPost {
+				writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+				return
+			}
+			ws.archiveTodayAction(w, r, id)
+			return
 		}
 	}
 
-	http.Error(w, "Not Found", http.StatusNotFound)
+	writeError(w, r, http.StatusNotFound, "Not Found")
 }
 
-// getArchiveItemStatus returns the current status of a single archive item
-func (ws *Server) getArchiveItemStatus(w http.ResponseWriter, _ *http.Request, id int64) {
-	bookmark, err := ws.db.GetBookmark(id)
+// setRearchive opts a bookmark into or out of scheduled re-archiving. The
+// form field "enabled" is "true" or "false".
+func (ws *Server) setRearchive(w http.ResponseWriter, r *http.Request, id int64) {
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
 	if err != nil {
-		http.Error(w, "Bookmark not found", http.StatusNotFound)
-		log.Printf("Failed to get bookmark %d: %v", id, err)
+		writeError(w, r, http.StatusBadRequest, "Invalid enabled value")
+		return
+	}
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	if err := ws.userDB(r).SetRearchiveDisabled(id, !enabled); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to update re-archive setting")
+		log.Printf("Failed to update re-archive setting for bookmark %d: %v", id, err)
 		return
 	}
 
-	view := ws.buildArchiveManagerView(bookmark)
+	if isHTMX(r) {
+		view := ws.buildArchiveManagerView(ws.userDB(r), bookmark)
+		view.CSRFToken = csrfToken(r)
+		ws.renderTemplate(w, "archive_item.html", view)
+		return
+	}
+	http.Redirect(w, r, "/archives", http.StatusSeeOther)
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := ws.templates.ExecuteTemplate(w, "archive_item.html", view); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Failed to execute archive item template: %v", err)
+// submitWayback asks the Wayback Machine to capture a bookmark's page and
+// stores the snapshot. JSON clients get a waybackView and HTMX requests the
+// archive item; browsers are sent back to the archive manager. It is only
+// there when the server has a Wayback Machine client.
+func (ws *Server) submitWayback(w http.ResponseWriter, r *http.Request, id int64) {
+	if ws.wayback == nil {
+		writeError(w, r, http.StatusNotFound, "Wayback Machine is not enabled")
+		return
+	}
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	snapshot, err := ws.wayback.Submit(r.Context(), bookmark.URL)
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "Failed to submit to the Wayback Machine")
+		log.Printf("Failed to submit bookmark %d to the Wayback Machine: %v", id, err)
+		return
+	}
+	if err := ws.userDB(r).SetWaybackURL(id, snapshot); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to save wayback URL")
+		log.Printf("Failed to save wayback URL for bookmark %d: %v", id, err)
+		return
+	}
+
+	switch {
+	case wantsJSON(r):
+		writeJSON(w, http.StatusOK, waybackView{WaybackURL: snapshot})
+	case isHTMX(r):
+		view := ws.buildArchiveManagerView(ws.userDB(r), bookmark)
+		view.CSRFToken = csrfToken(r)
+		ws.renderTemplate(w, "archive_item.html", view)
+	default:
+		http.Redirect(w, r, "/archives", http.StatusSeeOther)
+	}
+}
+
+// archiveTodayAction submits a bookmark's page to archive.today and stores
+// the snapshot, or, when the form field "enabled" is "true" or "false",
+// allows or keeps the bookmark from being submitted there. Bookmarks kept
+// from archive.today can't be submitted (409), and submitting is only there
+// when the server has an archive.today client. JSON clients get an
+// archiveTodayView and HTMX requests the archive item; browsers are sent
+// back to the archive manager.
+func (ws *Server) archiveTodayAction(w http.ResponseWriter, r *http.Request, id int64) {
+	database := ws.userDB(r)
+	bookmark, err := database.GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	if v := r.FormValue("enabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid enabled value")
+			return
+		}
+		if err := database.SetArchiveTodayDisabled(id, !enabled); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to update archive.today setting")
+			log.Printf("Failed to update archive.today setting for bookmark %d: %v", id, err)
+			return
+		}
+	} else {
+		if ws.archiveToday == nil {
+			writeError(w, r, http.StatusNotFound, "archive.today is not enabled")
+			return
+		}
+		archive, err := database.GetBookmarkArchiveStatus(id)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to get archive status for bookmark %d: %v", id, err)
+			return
+		}
+		if archive.ArchiveTodayDisabled {
+			writeError(w, r, http.StatusConflict, "Bookmark is kept from archive.today")
+			return
+		}
+		if _, err := core.SubmitToArchiveToday(r.Context(), database, ws.archiveToday, bookmark); err != nil {
+			writeError(w, r, http.StatusBadGateway, "Failed to submit to archive.today")
+			log.Printf("Failed to submit bookmark %d to archive.today: %v", id, err)
+			return
+		}
+	}
+
+	switch {
+	case wantsJSON(r):
+		archive, err := database.GetBookmarkArchiveStatus(id)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to get archive status for bookmark %d: %v", id, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, archiveTodayView{
+			ArchiveTodayURL:      archive.ArchiveTodayURL,
+			ArchiveTodayDisabled: archive.ArchiveTodayDisabled,
+		})
+	case isHTMX(r):
+		view := ws.buildArchiveManagerView(ws.userDB(r), bookmark)
+		view.CSRFToken = csrfToken(r)
+		ws.renderTemplate(w, "archive_item.html", view)
+	default:
+		http.Redirect(w, r, "/archives", http.StatusSeeOther)
+	}
+}
+
+// getArchiveItemStatus returns the current status of a single archive item
+func (ws *Server) getArchiveItemStatus(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		log.Printf("Failed to get bookmark %d: %v", id, err)
 		return
 	}
+
+	view := ws.buildArchiveManagerView(ws.userDB(r), bookmark)
+	view.CSRFToken = csrfToken(r)
+	ws.renderFragment(w, r, "archive_item.html", view)
 }
 
 // refetchArchive clears an existing archive to queue it for re-archiving
 func (ws *Server) refetchArchive(w http.ResponseWriter, r *http.Request, id int64) {
-	bookmark, err := ws.db.GetBookmark(id)
+	bookmark, err := ws.userDB(r).GetBookmark(id)
 	if err != nil {
-		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
 		log.Printf("Failed to get bookmark %d: %v", id, err)
 		return
 	}
 
-	if err := ws.db.ClearBookmarkArchive(id); err != nil {
-		http.Error(w, "Failed to clear archive", http.StatusInternalServerError)
+	if err := ws.userDB(r).ClearBookmarkArchive(id); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to clear archive")
 		log.Printf("Failed to clear bookmark archive %d: %v", id, err)
 		return
 	}
@@ -231,20 +1113,16 @@ func (ws *Server) refetchArchive(w http.ResponseWriter, r *http.Request, id int6
 	log.Printf("Cleared archive for bookmark %d, queued for re-archiving", id)
 
 	// For HTMX requests, return just the single item in archiving state
-	if r.Header.Get("HX-Request") == "true" {
-		view := ws.buildArchiveManagerView(bookmark)
+	if isHTMX(r) {
+		view := ws.buildArchiveManagerView(ws.userDB(r), bookmark)
 		// Force IsArchiving to true since we just cleared it
 		view.IsArchiving = true
 		view.ArchiveStatus = ""
 		view.ArchivedAt = ""
 		view.ArchiveError = ""
-
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := ws.templates.ExecuteTemplate(w, "archive_item.html", view); err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("Failed to execute archive item template: %v", err)
-			return
-		}
+		view.ArchiveErrorCode = ""
+		view.CSRFToken = csrfToken(r)
+		ws.renderTemplate(w, "archive_item.html", view)
 		return
 	}
 
@@ -0,0 +1,226 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// podcastPrefix is where the per-tag podcast feeds and their episodes are
+// served. Podcast apps can't use the login form, so requireLogin takes Basic
+// credentials here as it does for WebDAV.
+const podcastPrefix = "/podcast/"
+
+// handleArchiveAudio serves (GET) or generates (POST) the spoken version of
+// a bookmark's article, under /bookmarks/{id}/archive/audio.
+func (ws *Server) handleArchiveAudio(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		ws.serveArchiveAudio(w, r, id)
+	case http.MethodPost:
+		ws.generateArchiveAudio(w, r, id)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// serveArchiveAudio serves the audio saved with an archive, with range
+// requests so players can seek. An optional ?version={versionID} selects an
+// older snapshot; the latest is served by default.
+func (ws *Server) serveArchiveAudio(w http.ResponseWriter, r *http.Request, id int64) {
+	var versionID int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		if versionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid version ID")
+			return
+		}
+	} else {
+		latest, err := ws.userDB(r).GetLatestArchiveVersion(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Audio not available")
+			return
+		}
+		versionID = latest.ID
+	}
+	ws.writeArchiveAudio(w, r, id, versionID)
+}
+
+func (ws *Server) writeArchiveAudio(w http.ResponseWriter, r *http.Request, id, versionID int64) {
+	audio, err := ws.userDB(r).GetArchiveAudio(id, versionID)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Audio not available")
+		return
+	}
+	w.Header().Set("Content-Type", audio.MIMEType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(audio.Data))
+}
+
+// generateArchiveAudio reads the article of a bookmark's latest archive
+// aloud with the configured text-to-speech backend, replacing any audio it
+// already has.
+func (ws *Server) generateArchiveAudio(w http.ResponseWriter, r *http.Request, id int64) {
+	if ws.tts == nil {
+		writeError(w, r, http.StatusNotImplemented, "Text-to-speech is not configured")
+		return
+	}
+	if _, err := ws.userDB(r).GetBookmark(id); err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	audio, err := core.GenerateArticleAudio(r.Context(), ws.userDB(r), ws.tts, id)
+	if err != nil {
+		if errors.Is(err, core.ErrNoArticleText) {
+			writeError(w, r, http.StatusNotFound, "No article text to read aloud")
+			return
+		}
+		writeError(w, r, http.StatusBadGateway, "Failed to generate audio")
+		log.Printf("Failed to generate audio for bookmark %d: %v", id, err)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"bookmark_id": id,
+			"mime_type":   audio.MIMEType,
+			"size":        audio.Size,
+			"url":         fmt.Sprintf("/bookmarks/%d/archive/audio", id),
+		})
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%d/read", id), http.StatusSeeOther)
+}
+
+// audioURL links a version's audio, or returns "" if it has none.
+func audioURL(id int64, version db.ArchiveVersion) string {
+	if !version.HasAudio {
+		return ""
+	}
+	return fmt.Sprintf("/bookmarks/%d/archive/audio?version=%d", id, version.ID)
+}
+
+// podcastRSS is an RSS 2.0 feed with an enclosure per episode, which is all
+// podcast apps need.
+type podcastRSS struct {
+	XMLName xml.Name       `xml:"rss"`
+	Version string         `xml:"version,attr"`
+	Channel podcastChannel `xml:"channel"`
+}
+
+type podcastChannel struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Items       []podcastItem `xml:"item"`
+}
+
+type podcastItem struct {
+	Title       string           `xml:"title"`
+	Link        string           `xml:"link"`
+	Description string           `xml:"description,omitempty"`
+	GUID        podcastGUID      `xml:"guid"`
+	PubDate     string           `xml:"pubDate,omitempty"`
+	Enclosure   podcastEnclosure `xml:"enclosure"`
+}
+
+type podcastGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type podcastEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// handlePodcast serves /podcast/{tag}.rss, a feed of the bookmarks tagged
+// {tag} that have been read aloud, and the episodes it links,
+// /podcast/audio/{bookmarkID}/{versionID}.{ext}.
+func (ws *Server) handlePodcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, podcastPrefix)
+	if tag, ok := strings.CutSuffix(path, ".rss"); ok && tag != "" {
+		ws.servePodcastFeed(w, r, tag)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "audio/"), "/")
+	if !strings.HasPrefix(path, "audio/") || len(parts) != 2 {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	bookmarkID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	versionID, err := strconv.ParseInt(strings.SplitN(parts[1], ".", 2)[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	ws.writeArchiveAudio(w, r, bookmarkID, versionID)
+}
+
+func (ws *Server) servePodcastFeed(w http.ResponseWriter, r *http.Request, tag string) {
+	episodes, err := ws.userDB(r).ListAudioEpisodes(tag, core.DefaultPodcastEpisodes)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list podcast episodes for %q: %v", tag, err)
+		return
+	}
+
+	server := requestServerURL(r)
+	feed := podcastRSS{
+		Version: "2.0",
+		Channel: podcastChannel{
+			Title:       "bookmarkd: " + tag,
+			Link:        server + "/",
+			Description: fmt.Sprintf("Saved articles tagged %s, read aloud.", tag),
+			Items:       make([]podcastItem, 0, len(episodes)),
+		},
+	}
+	for _, e := range episodes {
+		item := podcastItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			Description: e.Description,
+			GUID:        podcastGUID{Value: fmt.Sprintf("bookmarkd-audio-%d-%d", e.BookmarkID, e.VersionID)},
+			Enclosure: podcastEnclosure{
+				URL:    fmt.Sprintf("%s%saudio/%d/%d%s", server, podcastPrefix, e.BookmarkID, e.VersionID, core.AudioExtension(e.MIMEType)),
+				Length: e.Size,
+				Type:   e.MIMEType,
+			},
+		}
+		if captured, err := time.Parse(time.RFC3339, e.CapturedAt); err == nil {
+			item.PubDate = captured.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to encode podcast feed: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if _, err := w.Write(append([]byte(xml.Header), out...)); err != nil {
+		log.Printf("Failed to write podcast feed: %v", err)
+	}
+}
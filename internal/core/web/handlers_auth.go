@@ -0,0 +1,108 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// loginView is the data for login.html.
+type loginView struct {
+	Next      string
+	Username  string
+	Error     string
+	CSRFToken string
+}
+
+// handleLogin shows the login form (GET) and starts a session when the
+// username and password are right (POST), redirecting to the page the user
+// asked for.
+func (ws *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !ws.loginRequired() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := ws.sessionUser(r); ok {
+			http.Redirect(w, r, safeRedirect(r.URL.Query().Get("next")), http.StatusSeeOther)
+			return
+		}
+		ws.renderTemplate(w, "login.html", loginView{Next: safeRedirect(r.URL.Query().Get("next")), CSRFToken: csrfToken(r)})
+	case http.MethodPost:
+		next := safeRedirect(r.FormValue("next"))
+		username := strings.TrimSpace(r.FormValue("username"))
+		userID, err := ws.authenticate(username, r.FormValue("password"))
+		if err != nil {
+			if !errors.Is(err, db.ErrInvalidCredentials) {
+				writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+				log.Printf("Failed to check login: %v", err)
+				return
+			}
+			log.Printf("Failed login for %q from %s", username, r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			ws.renderTemplate(w, "login.html", loginView{Next: next, Username: username, Error: "Wrong username or password.", CSRFToken: csrfToken(r)})
+			return
+		}
+		id, expiry, err := ws.sessions.create(userID)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to create session: %v", err)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    id,
+			Path:     "/",
+			Expires:  expiry,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// authenticate returns the user a login form logs in as. The instance
+// password logs in as db.LocalUserID, with that user's name or no username.
+func (ws *Server) authenticate(username, password string) (int64, error) {
+	if username != "" {
+		u, err := ws.db.AuthenticateUser(username, password)
+		if err == nil || !errors.Is(err, db.ErrInvalidCredentials) {
+			return u.ID, err
+		}
+		local, lerr := ws.db.GetUser(db.LocalUserID)
+		if lerr != nil || !strings.EqualFold(local.Username, username) {
+			return 0, err
+		}
+	}
+	if ws.sessions.checkPassword(password) {
+		return db.LocalUserID, nil
+	}
+	return 0, db.ErrInvalidCredentials
+}
+
+// handleLogout ends the session and returns to the login page.
+func (ws *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		ws.sessions.remove(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// bookmarkletPage is the data for bookmarklet.html.
+type bookmarkletPage struct {
+	ActivePage string
+	CSRFToken  string
+	ServerURL  string
+	Tokens     []bookmarkletTokenView
+	Presets    []presetView
+	Generated  *generatedBookmarkletView
+}
+
+// requestServerURL returns the URL this server was reached at, honouring
+// X-Forwarded-Proto from a TLS-terminating proxy.
+func requestServerURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// bookmarkletJS returns a javascript: URL that opens /bookmarklet/add on
+// serverURL with the current page, its title, the selected text and token,
+// and preset unless it is 0.
+func bookmarkletJS(serverURL, token string, preset int64) template.URL {
+	// Browsers percent-decode javascript: URLs before running them, so the
+	// embedded strings must not contain a bare '%'.
+	literal := func(s string) string {
+		b, _ := json.Marshal(s)
+		return strings.ReplaceAll(string(b), "%", "%25")
+	}
+	js := "javascript:(function(){" +
+		"var s=" + literal(serverURL) + ",t=" + literal(token) + ";" +
+		"var q='?token='+encodeURIComponent(t)" +
+		"+'&url='+encodeURIComponent(location.href)" +
+		"+'&title='+encodeURIComponent(document.title)" +
+		"+'&notes='+encodeURIComponent(String(window.getSelection()).slice(0,2000))"
+	if preset != 0 {
+		js += "+'&preset=" + strconv.FormatInt(preset, 10) + "'"
+	}
+	js += ";" +
+		"if(!window.open(s+'/bookmarklet/add'+q,'_blank','width=600,height=520')){alert('Please allow popups for this site');}" +
+		"})();"
+	return template.URL(js)
+}
+
+// bookmarkletTokens returns the tokens of generated bookmarklets.
+func (ws *Server) bookmarkletTokens(r *http.Request) ([]bookmarkletTokenView, error) {
+	tokens, err := ws.userDB(r).ListAPITokens()
+	if err != nil {
+		return nil, err
+	}
+	views := []bookmarkletTokenView{}
+	for _, t := range tokens {
+		if t.Scope == db.APITokenScopeBookmarklet {
+			views = append(views, newBookmarkletTokenView(t))
+		}
+	}
+	return views, nil
+}
+
+func newBookmarkletTokenView(t db.APIToken) bookmarkletTokenView {
+	return bookmarkletTokenView{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt, LastUsedAt: t.LastUsedAt}
+}
+
+// renderBookmarkletPage renders the bookmarklet page, with generated set
+// right after a bookmarklet was made.
+func (ws *Server) renderBookmarkletPage(w http.ResponseWriter, r *http.Request, generated *generatedBookmarkletView) {
+	tokens, err := ws.bookmarkletTokens(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list bookmarklet tokens: %v", err)
+		return
+	}
+	presets, err := ws.presetViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list presets: %v", err)
+		return
+	}
+	ws.renderTemplate(w, "bookmarklet.html", bookmarkletPage{
+		ActivePage: "bookmarklet",
+		CSRFToken:  csrfToken(r),
+		ServerURL:  requestServerURL(r),
+		Tokens:     tokens,
+		Presets:    presets,
+		Generated:  generated,
+	})
+}
+
+// handleBookmarkletGenerate creates a bookmarklet-scoped token named by the
+// "name" field and returns a bookmarklet that uses it and this server's URL,
+// and saves with the preset whose ID is in "preset", if given.
+func (ws *Server) handleBookmarkletGenerate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = "Bookmarklet"
+	}
+	preset, _, err := ws.requestPreset(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, token, err := ws.userDB(r).CreateBookmarkletToken(name)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to create bookmarklet")
+		log.Printf("Failed to create bookmarklet token: %v", err)
+		return
+	}
+	serverURL := requestServerURL(r)
+	generated := &generatedBookmarkletView{
+		bookmarkletTokenView: newBookmarkletTokenView(t),
+		ServerURL:            serverURL,
+		Bookmarklet:          bookmarkletJS(serverURL, token, preset.ID),
+		Preset:               preset.Name,
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusCreated, generated)
+		return
+	}
+	ws.renderBookmarkletPage(w, r, generated)
+}
+
+// handleBookmarkletToken handles /bookmarklet/tokens/{id}/revoke, which stops
+// a generated bookmarklet from working. Other API tokens aren't touched.
+func (ws *Server) handleBookmarkletToken(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/bookmarklet/tokens/"), "/")
+	if len(parts) != 2 || parts[1] != "revoke" {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	t, err := ws.userDB(r).GetAPIToken(id)
+	if err != nil || t.Scope != db.APITokenScopeBookmarklet {
+		writeError(w, r, http.StatusNotFound, "Bookmarklet not found")
+		return
+	}
+	if err := ws.userDB(r).RevokeAPIToken(id); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to revoke bookmarklet")
+		log.Printf("Failed to revoke bookmarklet token %d: %v", id, err)
+		return
+	}
+	log.Printf("Revoked bookmarklet %d (%s)", t.ID, t.Name)
+	if wantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/bookmarklet", http.StatusSeeOther)
+}
+
+// bookmarkletTokenAllows reports whether a bookmarklet-scoped token may make
+// request r: open the add page, save the bookmark and then its notes.
+func bookmarkletTokenAllows(r *http.Request) bool {
+	path := r.URL.Path
+	switch r.Method {
+	case http.MethodGet:
+		return path == "/bookmarklet/add"
+	case http.MethodPost:
+		return path == "/bookmarks" || (strings.HasPrefix(path, "/bookmarks/") && strings.HasSuffix(path, "/notes"))
+	default:
+		return false
+	}
+}
+
+// tokenAllows reports whether token's scope permits request r. Unknown
+// scopes allow nothing.
+func tokenAllows(token db.APIToken, r *http.Request) bool {
+	switch token.Scope {
+	case "":
+		return true
+	case db.APITokenScopeBookmarklet:
+		return bookmarkletTokenAllows(r)
+	default:
+		return false
+	}
+}
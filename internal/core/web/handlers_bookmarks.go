@@ -1,22 +1,60 @@
 package web
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
 )
 
 func (ws *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodGet) {
 		return
 	}
-	ws.renderTemplate(w, "index.html", map[string]any{"ActivePage": "bookmarks"})
+	// The list is filled in for the list parameters (see listBookmarks),
+	// so the page works without htmx.
+	list, err := ws.bookmarkListPage(r)
+	if err != nil {
+		bookmarkListError(w, r, err)
+		return
+	}
+	presets, err := ws.presetViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list presets: %v", err)
+		return
+	}
+	sources, err := ws.sourceOptionViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list bookmark sources: %v", err)
+		return
+	}
+	ws.renderTemplate(w, "index.html", map[string]any{
+		"ActivePage": "bookmarks",
+		"CSRFToken":  csrfToken(r),
+		"List":       list,
+		"Presets":    presets,
+		"Sources":    sources,
+
+		"ArchiveTodayEnabled": ws.archiveToday != nil,
+	})
 }
 
 func (ws *Server) handleBookmarklet(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodGet) {
 		return
 	}
-	ws.renderTemplate(w, "bookmarklet.html", map[string]any{"ActivePage": "bookmarklet"})
+	ws.renderBookmarkletPage(w, r, nil)
 }
 
 func (ws *Server) handleBookmarkletAdd(w http.ResponseWriter, r *http.Request) {
@@ -26,9 +64,11 @@ func (ws *Server) handleBookmarkletAdd(w http.ResponseWriter, r *http.Request) {
 
 	url := r.URL.Query().Get("url")
 	title := r.URL.Query().Get("title")
+	// The bookmarklet sends any text selected on the page as the notes.
+	notes := r.URL.Query().Get("notes")
 
 	if url == "" {
-		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Missing url parameter")
 		return
 	}
 
@@ -36,9 +76,29 @@ func (ws *Server) handleBookmarkletAdd(w http.ResponseWriter, r *http.Request) {
 		title = url // Fallback to URL if title is empty
 	}
 
+	// A generated bookmarklet passes its token, which the page then sends
+	// when saving, so it works without a login session.
+	token := ""
+	if _, ok := apiTokenFrom(r.Context()); ok {
+		token = r.URL.Query().Get("token")
+	}
+
+	// It may also pass the preset it was generated with. One that has since
+	// been deleted is dropped, so the bookmarklet keeps saving.
+	preset := ""
+	if p, ok, err := ws.requestPreset(r); err != nil {
+		log.Printf("Ignoring bookmarklet preset: %v", err)
+	} else if ok {
+		preset = strconv.FormatInt(p.ID, 10)
+	}
+
 	ws.renderTemplate(w, "bookmarklet_add.html", map[string]string{
-		"URL":   url,
-		"Title": title,
+		"URL":       url,
+		"Title":     title,
+		"Notes":     notes,
+		"Token":     token,
+		"Preset":    preset,
+		"CSRFToken": csrfToken(r),
 	})
 }
 
@@ -51,24 +111,87 @@ func (ws *Server) handleBookmarks(w http.ResponseWriter, r *http.Request) {
 		ws.listBookmarks(w, r)
 		return
 	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 }
 
+// createBookmark adds a bookmark from either the individual url/title/tags
+// form fields or a single free-text quick-add line in "q", e.g.
+// "https://example.com Great article #go #http ~toread", plus optional
+// Markdown "notes", the ID of one of the user's presets in "preset" and
+// skip_archive_today=true to keep the bookmark from archive.today.
+// Quick-add flags ~read and ~fav (or ~favorite) mark the bookmark read or
+// favorite; ~toread is the default and other flags are ignored.
+// JSON clients get the new bookmark back, with when to expect its archive.
+// Adds count against the source's add limit; see allowAdd. How the
+// bookmark was added is recorded as bookmarkSource gives it.
 func (ws *Server) createBookmark(w http.ResponseWriter, r *http.Request) {
-	url := r.FormValue("url")
-	title := r.FormValue("title")
+	if !ws.allowAdd(w, r) {
+		return
+	}
+	nb := db.NewBookmark{
+		URL:   r.FormValue("url"),
+		Title: r.FormValue("title"),
+		Tags:  splitTags(r.FormValue("tags")),
+		Notes: r.FormValue("notes"),
+	}
 
-	if _, err := ws.db.AddBookmark(url, title); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if q := strings.TrimSpace(r.FormValue("q")); q != "" {
+		qa, err := core.ParseQuickAdd(q)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		notes := nb.Notes
+		nb = qa.NewBookmark()
+		nb.Notes = notes
+	}
+
+	nb.SkipArchiveToday = r.FormValue("skip_archive_today") == "true"
+
+	preset, ok, err := ws.requestPreset(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if ok {
+		nb = preset.Apply(nb)
+	}
+
+	nb.Source = bookmarkSource(r)
+
+	id, err := ws.userDB(r).CreateBookmark(nb)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidURL) {
+			writeErrorCode(w, r, http.StatusBadRequest, errorCodeInvalidURL, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		log.Printf("Failed to insert bookmark: %v", err)
 		return
 	}
 
+	if wantsJSON(r) {
+		b, err := ws.userDB(r).GetBookmark(id)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to load new bookmark %d: %v", id, err)
+			return
+		}
+		view := buildBookmarkView(ws.userDB(r), b)
+		if view.Archive, err = ws.archiveETA(r, id); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to estimate archive of bookmark %d: %v", id, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, view)
+		return
+	}
+
 	// For HTMX requests, return the updated list fragment directly so the page can swap
 	// cleanly without a redirect.
-	if r.Header.Get("HX-Request") == "true" {
+	if isHTMX(r) {
 		ws.listBookmarks(w, r)
 		return
 	}
@@ -76,34 +199,881 @@ func (ws *Server) createBookmark(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (ws *Server) listBookmarks(w http.ResponseWriter, _ *http.Request) {
-	bookmarks, err := ws.db.ListBookmarks(0)
+// archiveETA reports where a bookmark's archive stands in the queue. The
+// archive job is queued (or deferred, if the queue is full) as the bookmark
+// is created, so it's already there.
+func (ws *Server) archiveETA(r *http.Request, id int64) (*archiveETAView, error) {
+	eta, err := core.EstimateArchive(ws.userDB(r), id, ws.archiveWorkers)
+	if err != nil {
+		return nil, err
+	}
+	if eta.Status == "" {
+		return &archiveETAView{Status: "none"}, nil
+	}
+	return &archiveETAView{Status: eta.Status, Ahead: eta.Ahead, EstimatedWaitSeconds: eta.Wait.Seconds()}, nil
+}
+
+// bookmarkSource is how r adds bookmarks (see db.BookmarkSource): with the
+// API token it was made with, from the bookmarklet's save page, which posts
+// via=bookmarklet, or from the web UI. The field isn't named "source"
+// because the add forms also carry the list's source filter.
+func bookmarkSource(r *http.Request) string {
+	if token, ok := apiTokenFrom(r.Context()); ok {
+		return db.BookmarkSource(db.SourceAPI, token.Name)
+	}
+	if r.FormValue("via") == db.SourceBookmarklet {
+		return db.SourceBookmarklet
+	}
+	return db.SourceWeb
+}
+
+// handleBookmarksBulk adds every URL in the newline-separated "urls" field,
+// each optionally followed by a title and #tags, plus the tags in "tags".
+// JSON clients get the core.BulkAddResult; htmx requests get a summary
+// fragment and an HX-Trigger so the bookmark list reloads. Every URL in the
+// list counts against the add limit, and the whole list is refused unless
+// the limit covers them all.
+func (ws *Server) handleBookmarksBulk(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	urls, err := core.CountBulkAddURLs(r.FormValue("urls"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ws.allowAdds(w, r, urls) {
+		return
+	}
+
+	res, err := core.BulkAddBookmarks(ws.userDB(r), r.FormValue("urls"), splitTags(r.FormValue("tags")), bookmarkSource(r))
+	if err != nil {
+		if errors.Is(err, core.ErrBulkAddTooLarge) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to bulk add bookmarks: %v", err)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if isHTMX(r) {
+		if len(res.Added) > 0 {
+			w.Header().Set("HX-Trigger", "bookmarks-changed")
+		}
+		ws.renderTemplate(w, "bulk_add_result.html", res)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Bulk actions served under /bookmarks/bulk/{action}.
+const (
+	bulkActionDelete    = "delete"
+	bulkActionTag       = "tag"
+	bulkActionRearchive = "rearchive"
+)
+
+// bulkActionResult reports which of the requested bookmarks a bulk action
+// changed.
+type bulkActionResult struct {
+	Action   string  `json:"action"`
+	Affected []int64 `json:"affected"`
+	// NotFound lists requested IDs that aren't bookmarks.
+	NotFound []int64 `json:"not_found"`
+}
+
+// handleBookmarkGraph returns the user's bookmarks as a core.BookmarkGraph:
+// bookmarks joined by the tags and domains they share and by links between
+// their archived pages.
+func (ws *Server) handleBookmarkGraph(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	graph, err := core.BuildBookmarkGraph(ws.userDB(r))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to build bookmark graph: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
+
+// handleBacklinks lists the bookmarks whose archives link to the page in
+// the "url" parameter, or to the domain (and its subdomains) in "domain".
+func (ws *Server) handleBacklinks(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	target, wantURL := r.URL.Query().Get("url"), true
+	if target == "" {
+		target, wantURL = r.URL.Query().Get("domain"), false
+	}
+	q, err := core.NewBacklinkQuery(target)
+	if err != nil || (q.Key != "") != wantURL {
+		writeError(w, r, http.StatusBadRequest, "Expected an http(s) url or a domain")
+		return
+	}
+	backlinks, err := ws.userDB(r).ListBacklinks(q)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list backlinks: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBacklinkViews(backlinks))
+}
+
+// bookmarkLinks returns a bookmarkLinksView for a bookmark.
+func (ws *Server) bookmarkLinks(w http.ResponseWriter, r *http.Request, id int64) {
+	database := ws.userDB(r)
+	b, err := database.GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	links, err := database.ListBookmarkLinks(id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list links for bookmark %d: %v", id, err)
+		return
+	}
+	backlinks, err := database.ListBacklinks(db.BacklinkQuery{Key: core.LinkKey(b.URL)})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list backlinks for bookmark %d: %v", id, err)
+		return
+	}
+	view := bookmarkLinksView{Outbound: make([]string, 0, len(links)), Backlinks: newBacklinkViews(backlinks)}
+	for _, l := range links {
+		view.Outbound = append(view.Outbound, l.URL)
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleBookmarksBulkAction applies an action to every bookmark listed in
+// the "ids" field (repeated, or comma-separated): /bookmarks/bulk/delete,
+// /bookmarks/bulk/tag (adds the "tags" field) or /bookmarks/bulk/rearchive.
+// Instead of ids, a "domain" field selects the bookmarks on that domain that
+// pass the list's filters, as the list grouped by domain shows them. JSON
+// clients get a bulkActionResult; htmx requests get the bookmark list
+// fragment.
+func (ws *Server) handleBookmarksBulkAction(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	action := strings.TrimPrefix(r.URL.Path, "/bookmarks/bulk/")
+	if action != bulkActionDelete && action != bulkActionTag && action != bulkActionRearchive {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	ids, err := parseBookmarkIDs(r.Form["ids"])
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid filter")
+		return
+	}
+	if filter.Domain != "" {
+		bookmarks, err := ws.userDB(r).ListFilteredBookmarks(filter, 0)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to list bookmarks on %s: %v", filter.Domain, err)
+			return
+		}
+		ids = ids[:0]
+		for _, b := range bookmarks {
+			ids = append(ids, b.ID)
+		}
+	}
+	if len(ids) == 0 {
+		writeError(w, r, http.StatusBadRequest, "No bookmarks selected")
+		return
+	}
+	if len(ids) > core.MaxBulkActionIDs {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Too many bookmarks: at most %d at once", core.MaxBulkActionIDs))
+		return
+	}
+
+	var affected []int64
+	switch action {
+	case bulkActionDelete:
+		affected, err = ws.userDB(r).DeleteBookmarks(ids)
+	case bulkActionTag:
+		tags := splitTags(r.FormValue("tags"))
+		if len(tags) == 0 {
+			writeError(w, r, http.StatusBadRequest, "No tags given")
+			return
+		}
+		affected, err = ws.userDB(r).AddTagsToBookmarks(ids, tags)
+	case bulkActionRearchive:
+		affected, err = ws.userDB(r).ClearBookmarkArchives(ids)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to bulk %s bookmarks: %v", action, err)
+		return
+	}
+	log.Printf("Bulk %s: %d of %d bookmarks", action, len(affected), len(ids))
+
+	if wantsJSON(r) {
+		res := bulkActionResult{Action: action, Affected: append([]int64{}, affected...), NotFound: []int64{}}
+		done := make(map[int64]bool, len(affected))
+		for _, id := range affected {
+			done[id] = true
+		}
+		for _, id := range ids {
+			if !done[id] {
+				res.NotFound = append(res.NotFound, id)
+			}
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if isHTMX(r) {
+		ws.listBookmarks(w, r)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// parseBookmarkIDs parses bookmark IDs from form values, each of which may
+// hold several comma-separated IDs. Repeated IDs are dropped.
+func parseBookmarkIDs(values []string) ([]int64, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, v := range values {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(field, 10, 64)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid bookmark ID %q", field)
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// splitTags splits a free-form tags field on commas and whitespace.
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// Values of the "filter" parameter of the bookmark list.
+const (
+	filterAll       = ""
+	filterUnread    = "unread"
+	filterRead      = "read"
+	filterFavorites = "favorites"
+)
+
+// parseBookmarkFilter parses the bookmark list's "filter" parameter.
+func parseBookmarkFilter(s string) (db.BookmarkFilter, bool) {
+	switch s {
+	case filterAll:
+		return db.BookmarkFilter{}, true
+	case filterUnread:
+		return db.BookmarkFilter{UnreadOnly: true}, true
+	case filterRead:
+		return db.BookmarkFilter{ReadOnly: true}, true
+	case filterFavorites:
+		return db.BookmarkFilter{FavoritesOnly: true}, true
+	}
+	return db.BookmarkFilter{}, false
+}
+
+// errInvalidFilter reports an unknown "filter" or "archive" parameter.
+var errInvalidFilter = errors.New("invalid filter")
+
+// errInvalidSort reports an unknown "sort" or "order" parameter.
+var errInvalidSort = errors.New("invalid sort")
+
+// archiveFilters are the values of the bookmark list's "archive" parameter,
+// which keeps bookmarks by archive status.
+var archiveFilters = []string{core.ArchiveStatusOK, core.ArchiveStatusError, core.ArchiveStatusSkipped, db.NotArchived}
+
+// bookmarkViewDomains is the "view" parameter that groups the bookmark list
+// by domain.
+const bookmarkViewDomains = "domains"
+
+// errInvalidView reports an unknown "view" parameter.
+var errInvalidView = errors.New("invalid view")
+
+// groupByDomain reports whether r asks for the bookmark list grouped by
+// domain. Searches are always listed best match first.
+func groupByDomain(r *http.Request) (bool, error) {
+	switch r.FormValue("view") {
+	case "":
+		return false, nil
+	case bookmarkViewDomains:
+		return strings.TrimSpace(r.FormValue("search")) == "", nil
+	}
+	return false, errInvalidView
+}
+
+// listBookmarks serves the bookmark list fragment, or the bookmarks as JSON
+// to clients that send Accept: application/json. The "filter" ("unread",
+// "read" or "favorites"), "tag", "domain", "archive" (an archive status,
+// or "none") and "source" (see db.BookmarkFilter.Source) parameters narrow
+// the list, which "sort" (created, title or
+// domain) and "order" (asc or desc) arrange, newest first by default. A
+// "search" query (see db.SearchBookmarks) lists only matches, best first;
+// handlers that re-render the list after a change pass these along too. With explain=1,
+// JSON search results include how each was scored, and with facets=1 the
+// JSON is a bookmarkListView that also counts the bookmarks by tag, domain,
+// year, archive status and source, for a filter sidebar. With view=domains (and no
+// search), the list is grouped by domain instead: a domainGroupView per
+// domain, whose bookmarks are listed by passing it as "domain". Browsers
+// loading it without htmx get the whole bookmarks page (see
+// renderFragment).
+func (ws *Server) listBookmarks(w http.ResponseWriter, r *http.Request) {
+	grouped, err := groupByDomain(r)
+	if err != nil {
+		bookmarkListError(w, r, err)
+		return
+	}
+	if grouped {
+		groups, err := ws.listDomainGroupViews(r)
+		if err != nil {
+			bookmarkListError(w, r, err)
+			return
+		}
+		if wantsJSON(r) {
+			writeJSON(w, http.StatusOK, groups)
+			return
+		}
+		ws.renderFragment(w, r, "bookmarks.html", domainGroupListData(r, groups))
+		return
+	}
+
+	bookmarksData, err := ws.listBookmarkViews(r)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Failed to get bookmarks: %v", err)
+		bookmarkListError(w, r, err)
 		return
 	}
 
-	var bookmarksData []bookmarkView
-	for _, b := range bookmarks {
-		view := bookmarkView{
-			ID:    b.ID,
-			URL:   b.URL,
-			Title: b.Title,
+	if wantsJSON(r) {
+		if r.FormValue("facets") == "1" {
+			writeJSON(w, http.StatusOK, bookmarkListView{Bookmarks: bookmarksData, Facets: newFacetsView(bookmarksData)})
+			return
 		}
-		// Fetch archive status for this bookmark
-		archive, err := ws.db.GetBookmarkArchive(b.ID)
-		if err == nil {
-			view.ArchiveStatus = archive.ArchiveStatus
-			view.ArchivedAt = archive.ArchivedAt
+		writeJSON(w, http.StatusOK, bookmarksData)
+		return
+	}
+
+	ws.renderFragment(w, r, "bookmarks.html", bookmarkListData(r, bookmarksData))
+}
+
+// bookmarkListPage is the data bookmarks.html renders for r, grouped by
+// domain or not, as listBookmarks describes.
+func (ws *Server) bookmarkListPage(r *http.Request) (map[string]any, error) {
+	grouped, err := groupByDomain(r)
+	if err != nil {
+		return nil, err
+	}
+	if grouped {
+		groups, err := ws.listDomainGroupViews(r)
+		if err != nil {
+			return nil, err
+		}
+		return domainGroupListData(r, groups), nil
+	}
+	views, err := ws.listBookmarkViews(r)
+	if err != nil {
+		return nil, err
+	}
+	return bookmarkListData(r, views), nil
+}
+
+// listBookmarkFilter parses r's "filter", "domain", "tag", "archive",
+// "source", "sort" and "order" parameters.
+func listBookmarkFilter(r *http.Request) (db.BookmarkFilter, error) {
+	filter, ok := parseBookmarkFilter(r.FormValue("filter"))
+	if !ok {
+		return db.BookmarkFilter{}, errInvalidFilter
+	}
+	filter.Domain = db.NormalizeRuleDomain(r.FormValue("domain"))
+	filter.Tag = db.NormalizeTag(r.FormValue("tag"))
+	filter.ArchiveStatus = r.FormValue("archive")
+	if filter.ArchiveStatus != "" && !slices.Contains(archiveFilters, filter.ArchiveStatus) {
+		return db.BookmarkFilter{}, errInvalidFilter
+	}
+	filter.Source = strings.TrimSpace(r.FormValue("source"))
+	filter.Sort = r.FormValue("sort")
+	if filter.Sort != "" && !slices.Contains(db.BookmarkSorts, filter.Sort) {
+		return db.BookmarkFilter{}, errInvalidSort
+	}
+	switch filter.Order = r.FormValue("order"); filter.Order {
+	case "", db.SortAsc, db.SortDesc:
+	default:
+		return db.BookmarkFilter{}, errInvalidSort
+	}
+	return filter, nil
+}
+
+// listDomainGroupViews counts the bookmarks passing r's filters per domain.
+// Its "domain" parameter is left out, so the list stays whole after an
+// action on one domain's bookmarks.
+func (ws *Server) listDomainGroupViews(r *http.Request) ([]domainGroupView, error) {
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	filter.Domain = ""
+	groups, err := ws.userDB(r).ListDomainGroups(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to group bookmarks: %w", err)
+	}
+	views := []domainGroupView{}
+	for _, g := range groups {
+		views = append(views, domainGroupView{
+			Domain:   g.Domain,
+			Count:    g.Count,
+			Unread:   g.Unread,
+			Archived: g.Archived,
+			LatestAt: g.LatestAt,
+		})
+	}
+	return views, nil
+}
+
+// listBookmarkViews lists the bookmarks for r's "filter", "domain",
+// "search" and "explain" parameters, as listBookmarks describes.
+func (ws *Server) listBookmarkViews(r *http.Request) ([]bookmarkView, error) {
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	search := strings.TrimSpace(r.FormValue("search"))
+
+	var bookmarks []db.Bookmark
+	var scores []db.ScoreExplanation
+	if search == "" {
+		var err error
+		if bookmarks, err = ws.userDB(r).ListFilteredBookmarks(filter, 0); err != nil {
+			return nil, fmt.Errorf("failed to get bookmarks: %w", err)
+		}
+	} else {
+		results, err := ws.userDB(r).SearchBookmarks(search, filter, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range results {
+			bookmarks = append(bookmarks, res.Bookmark)
+			scores = append(scores, res.Explain)
+		}
+	}
+	explain := r.FormValue("explain") == "1"
+
+	bookmarksData := []bookmarkView{}
+	for i, b := range bookmarks {
+		view := buildBookmarkView(ws.userDB(r), b)
+		if explain && scores != nil {
+			view.Score = &scores[i]
 		}
 		bookmarksData = append(bookmarksData, view)
 	}
+	return bookmarksData, nil
+}
+
+// handleBookmarkSearch serves the index page's live search (GET
+// /bookmarks/search?q=): the best core.LiveSearchResults matches for q (see
+// db.SearchBookmarks), which covers titles, URLs, notes, tags and archived
+// page text, narrowed by "filter" like the list. The list's search box sends
+// its query as "search", which is read when q is absent. HTMX requests get
+// the search_results.html fragment, with matches highlighted; JSON clients
+// get []searchResultView; browsers are sent to the full results in the
+// bookmark list.
+func (ws *Server) handleBookmarkSearch(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	q := strings.TrimSpace(r.FormValue("q"))
+	if !r.Form.Has("q") {
+		q = strings.TrimSpace(r.FormValue("search"))
+	}
+	if !wantsJSON(r) && !isHTMX(r) {
+		http.Redirect(w, r, "/?search="+url.QueryEscape(q), http.StatusSeeOther)
+		return
+	}
+
+	data := map[string]any{"Query": q}
+	views := []searchResultView{}
+	if q != "" {
+		var err error
+		var total int
+		views, total, err = ws.searchResultViews(r, q)
+		if err != nil {
+			// Half-typed queries such as "domain:" are shown as hints
+			// rather than failing the live results.
+			if isHTMX(r) && errors.Is(err, db.ErrInvalidSearch) {
+				data["Error"] = err.Error()
+				ws.renderTemplate(w, "search_results.html", data)
+				return
+			}
+			bookmarkListError(w, r, err)
+			return
+		}
+		data["Total"] = total
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, views)
+		return
+	}
+	data["Results"] = views
+	ws.renderTemplate(w, "search_results.html", data)
+}
+
+// handleRandomBookmark picks one of the current user's bookmarks at random
+// (GET /bookmarks/random) for rediscovering old ones, narrowed by the list's
+// "filter", "tag", "domain", "archive" and "source" parameters. JSON
+// clients get its bookmarkView; browsers are sent to its archive, or to the
+// page itself if it hasn't been archived. No match is a 404.
+func (ws *Server) handleRandomBookmark(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		bookmarkListError(w, r, err)
+		return
+	}
+	b, ok, err := ws.userDB(r).RandomBookmark(filter)
+	if err != nil {
+		bookmarkListError(w, r, err)
+		return
+	}
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "No bookmarks match")
+		return
+	}
+	view := buildBookmarkView(ws.userDB(r), b)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	target := view.URL
+	if view.ArchivedAt != "" {
+		target = fmt.Sprintf("/bookmarks/%d/archive", view.ID)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// searchResultViews returns the best core.LiveSearchResults matches for q
+// and how many there are in all.
+func (ws *Server) searchResultViews(r *http.Request, q string) ([]searchResultView, int, error) {
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	results, err := ws.userDB(r).SearchBookmarks(q, filter, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	terms := db.SearchTerms(q)
+	views := []searchResultView{}
+	for _, res := range results[:min(len(results), core.LiveSearchResults)] {
+		view := searchResultView{
+			bookmarkView: buildBookmarkView(ws.userDB(r), res.Bookmark),
+			TitleHTML:    highlightTerms(res.Title, terms),
+			URLHTML:      highlightTerms(res.URL, terms),
+		}
+		if plain := strings.NewReplacer(db.SearchMatchStart, "", db.SearchMatchEnd, "").Replace(res.Snippet); plain != "" && plain != res.Title {
+			view.SnippetHTML = highlightSnippet(res.Snippet)
+		}
+		views = append(views, view)
+	}
+	return views, len(results), nil
+}
+
+// bookmarkListError answers a request whose bookmark list couldn't be
+// loaded: bad parameters get a 400, anything else a 500.
+func bookmarkListError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidFilter):
+		writeError(w, r, http.StatusBadRequest, "Invalid filter")
+	case errors.Is(err, errInvalidView):
+		writeError(w, r, http.StatusBadRequest, "Invalid view")
+	case errors.Is(err, errInvalidSort):
+		writeError(w, r, http.StatusBadRequest, "Invalid sort")
+	case errors.Is(err, db.ErrInvalidSearch):
+		writeErrorCode(w, r, http.StatusBadRequest, errorCodeInvalidSearch, err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list bookmarks: %v", err)
+	}
+}
+
+// bookmarkListData is what bookmarks.html renders: the bookmarks, the
+// list parameters (listParams) they were listed with, and the CSRF token
+// its buttons post with when htmx isn't running.
+func bookmarkListData(r *http.Request, views []bookmarkView) map[string]any {
+	return map[string]any{
+		"bookmarks": views,
+		"filter":    r.FormValue("filter"),
+		"domain":    db.NormalizeRuleDomain(r.FormValue("domain")),
+		"tag":       db.NormalizeTag(r.FormValue("tag")),
+		"archive":   r.FormValue("archive"),
+		"source":    strings.TrimSpace(r.FormValue("source")),
+		"sort":      r.FormValue("sort"),
+		"order":     r.FormValue("order"),
+		"search":    strings.TrimSpace(r.FormValue("search")),
+		"view":      r.FormValue("view"),
+		"CSRFToken": csrfToken(r),
+	}
+}
+
+// listParams are the bookmark list parameters bookmarkListData keeps.
+var listParams = []string{"filter", "domain", "tag", "archive", "source", "sort", "order", "search", "view"}
+
+// listLink returns the link to the bookmarks page listed with the
+// parameters in data (from bookmarkListData), but with key set to value,
+// or left out if value is empty. Tag chips and the chips that clear a
+// filter use it.
+func listLink(data map[string]any, key, value string) string {
+	q := url.Values{}
+	for _, name := range listParams {
+		v, _ := data[name].(string)
+		if name == key {
+			v = value
+		}
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if len(q) == 0 {
+		return "/"
+	}
+	return "/?" + q.Encode()
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := ws.templates.ExecuteTemplate(w, "bookmarks.html", map[string]any{"bookmarks": bookmarksData}); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Failed to execute template: %v", err)
+// sourceOptionViews lists the sources of the current user's bookmarks for
+// the bookmark list's source menu, with r's "source" selected. A source
+// none of them has, such as a bare kind like "api", is listed too, so the
+// menu shows what the list is filtered by.
+func (ws *Server) sourceOptionViews(r *http.Request) ([]sourceOptionView, error) {
+	sources, err := ws.userDB(r).ListBookmarkSources()
+	if err != nil {
+		return nil, err
+	}
+	current := strings.TrimSpace(r.FormValue("source"))
+	views := []sourceOptionView{}
+	found := current == ""
+	for _, s := range sources {
+		selected := s.Source == current
+		found = found || selected
+		views = append(views, sourceOptionView{Value: s.Source, Count: s.Count, Selected: selected})
+	}
+	if !found {
+		views = append(views, sourceOptionView{Value: current, Selected: true})
+	}
+	return views, nil
+}
+
+// domainGroupListData is what bookmarks.html renders for the list grouped
+// by domain.
+func domainGroupListData(r *http.Request, groups []domainGroupView) map[string]any {
+	data := bookmarkListData(r, nil)
+	data["groups"] = groups
+	return data
+}
+
+// newFacetsView counts views by tag, domain (without "www."), year saved,
+// archive status and source. Years are newest first; other values are most common
+// first, then alphabetical.
+func newFacetsView(views []bookmarkView) facetsView {
+	tags := map[string]int{}
+	domains := map[string]int{}
+	years := map[string]int{}
+	statuses := map[string]int{}
+	sources := map[string]int{}
+	for _, v := range views {
+		for _, tag := range v.Tags {
+			tags[tag]++
+		}
+		if u, err := url.Parse(v.URL); err == nil && u.Hostname() != "" {
+			domains[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")]++
+		}
+		if len(v.CreatedAt) >= 4 {
+			years[v.CreatedAt[:4]]++
+		}
+		status := v.ArchiveStatus
+		if status == "" {
+			status = "none"
+		}
+		statuses[status]++
+		if v.Source != "" {
+			sources[v.Source]++
+		}
+	}
+
+	counts := func(m map[string]int) []facetCount {
+		out := []facetCount{}
+		for value, n := range m {
+			out = append(out, facetCount{Value: value, Count: n})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Count != out[j].Count {
+				return out[i].Count > out[j].Count
+			}
+			return out[i].Value < out[j].Value
+		})
+		return out
+	}
+	facets := facetsView{Tags: counts(tags), Domains: counts(domains), Years: counts(years), Statuses: counts(statuses), Sources: counts(sources)}
+	sort.Slice(facets.Years, func(i, j int) bool { return facets.Years[i].Value > facets.Years[j].Value })
+	return facets
+}
+
+// buildBookmarkView gathers what the bookmark list shows about b, looking
+// it up through database.
+func buildBookmarkView(database *db.DB, b db.Bookmark) bookmarkView {
+	view := bookmarkView{
+		ID:        b.ID,
+		URL:       b.URL,
+		Title:     b.Title,
+		CreatedAt: b.CreatedAt,
+		Slug:      b.Slug,
+		Tags:      []string{},
+	}
+	// Fetch archive status for this bookmark
+	archive, err := database.GetBookmarkArchiveStatus(b.ID)
+	if err == nil {
+		view.ArchiveStatus = archive.ArchiveStatus
+		view.ArchivedAt = archive.ArchivedAt
+	}
+	if tags, err := database.ListBookmarkTags(b.ID); err == nil && tags != nil {
+		view.Tags = tags
+	}
+	if collection, err := database.GetBookmarkCollection(b.ID); err == nil {
+		view.Collection = collection
+	}
+	if source, err := database.GetBookmarkSource(b.ID); err == nil {
+		view.Source = source
+	}
+	if meta, err := database.GetBookmarkMetadata(b.ID); err == nil {
+		view.Description = meta.Description
+	}
+	if notes, err := database.GetBookmarkNotes(b.ID); err == nil && notes != "" {
+		view.Notes = notes
+		view.NotesHTML = renderMarkdown(notes)
+	}
+	if flags, err := database.GetBookmarkFlags(b.ID); err == nil {
+		view.IsRead = flags.IsRead
+		view.IsFavorite = flags.IsFavorite
+		view.IsPinned = flags.IsPinned
+	}
+	view.FaviconURL = faviconURL(database, b.ID)
+	return view
+}
+
+// setRead marks a bookmark read, or unread when the "read" form field is
+// "false". HTMX requests get the updated list fragment back; JSON clients
+// get the bookmark.
+func (ws *Server) setRead(w http.ResponseWriter, r *http.Request, id int64) {
+	read := r.FormValue("read") != "false"
+	if err := ws.userDB(r).MarkRead(id, read); err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
 		return
 	}
+	ws.respondBookmarkChanged(w, r, id)
+}
+
+// toggleFavorite flips a bookmark's favorite flag. HTMX requests get the
+// updated list fragment back; JSON clients get the bookmark.
+func (ws *Server) toggleFavorite(w http.ResponseWriter, r *http.Request, id int64) {
+	if _, err := ws.userDB(r).ToggleFavorite(id); err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	ws.respondBookmarkChanged(w, r, id)
+}
+
+// respondBookmarkChanged answers a request that changed bookmark id: JSON
+// clients get the bookmark, HTMX requests the list fragment, and browsers
+// are sent back to the list.
+func (ws *Server) respondBookmarkChanged(w http.ResponseWriter, r *http.Request, id int64) {
+	if wantsJSON(r) {
+		bookmark, err := ws.userDB(r).GetBookmark(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, buildBookmarkView(ws.userDB(r), bookmark))
+		return
+	}
+	if isHTMX(r) {
+		ws.listBookmarks(w, r)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// updateNotes replaces a bookmark's notes with the "notes" form field.
+// HTMX requests get the updated list fragment back; JSON clients get the
+// bookmark.
+func (ws *Server) updateNotes(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	if err := ws.userDB(r).SetBookmarkNotes(id, r.FormValue("notes")); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to save notes for bookmark %d: %v", id, err)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, buildBookmarkView(ws.userDB(r), bookmark))
+		return
+	}
+	if isHTMX(r) {
+		ws.listBookmarks(w, r)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// refreshMetadata re-fetches a bookmark's title, description and favicon
+// without archiving it. HTMX requests get the updated list fragment back.
+func (ws *Server) refreshMetadata(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	if err := core.RefreshBookmarkMetadata(r.Context(), ws.userDB(r), bookmark, core.DefaultMetadataTimeout); err != nil {
+		writeError(w, r, http.StatusBadGateway, "Failed to refresh metadata")
+		log.Printf("Failed to refresh metadata for bookmark %d: %v", id, err)
+		return
+	}
+
+	if isHTMX(r) {
+		ws.listBookmarks(w, r)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// handleDomains serves the domains page (GET /domains): every domain the
+// current user has bookmarks on, with how many there are and when the
+// latest was added (db.ListDomains). The bookmark list's parameters (see
+// listBookmarks) narrow the counts, and "sort" orders the domains: by
+// bookmark count by default, by name with sort=domain, or most recently
+// added first with sort=created. Each domain links to the bookmark list
+// filtered to it. Clients that send Accept: application/json get
+// []domainView.
+func (ws *Server) handleDomains(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	filter, err := listBookmarkFilter(r)
+	if err != nil {
+		bookmarkListError(w, r, err)
+		return
+	}
+	filter.Domain = ""
+	domains, err := ws.userDB(r).ListDomains(filter)
+	if err != nil {
+		bookmarkListError(w, r, fmt.Errorf("failed to list domains: %w", err))
+		return
+	}
+	views := []domainView{}
+	total := 0
+	for _, d := range domains {
+		views = append(views, domainView{Domain: d.Domain, Count: d.Count, LastAddedAt: d.LastAddedAt})
+		total += d.Count
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, views)
+		return
+	}
+	ws.renderTemplate(w, "domains.html", map[string]any{
+		"ActivePage": "domains",
+		"CSRFToken":  csrfToken(r),
+		"Domains":    views,
+		"Total":      total,
+		"Filter":     r.FormValue("filter"),
+		"Sort":       r.FormValue("sort"),
+	})
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// importFormatView is an entry of the import page's format menu.
+type importFormatView struct {
+	Name   string
+	Source string
+}
+
+// handleImport shows the import page and, on POST, imports the uploaded
+// export: a multipart form with the "format" (a key of core.ImportFormats),
+// the "file" and optional "tags" added to every bookmark. JSON clients get
+// the core.ImportResult; others get the page again with a summary.
+func (ws *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ws.viewImport(w, r, r.URL.Query().Get("format"), nil)
+	case http.MethodPost:
+		ws.runImport(w, r)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (ws *Server) viewImport(w http.ResponseWriter, r *http.Request, format string, res *core.ImportResult) {
+	formats := make([]importFormatView, 0, len(core.ImportFormats))
+	for name, f := range core.ImportFormats {
+		formats = append(formats, importFormatView{Name: name, Source: f.Source})
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+	ws.renderTemplate(w, "import.html", map[string]any{
+		"Formats":    formats,
+		"Format":     format,
+		"Result":     res,
+		"ActivePage": "import",
+		"CSRFToken":  csrfToken(r),
+	})
+}
+
+func (ws *Server) runImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, core.MaxImportSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "Export is too large")
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	defer func() {
+		if err := r.MultipartForm.RemoveAll(); err != nil {
+			log.Printf("Failed to remove uploaded export: %v", err)
+		}
+	}()
+
+	format := r.FormValue("format")
+	f, ok := core.ImportFormats[format]
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "Unknown import format")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Missing export file")
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Failed to close uploaded export: %v", err)
+		}
+	}()
+
+	items, err := f.Parse(file)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	source := db.BookmarkSource(db.SourceImport, header.Filename)
+	for i := range items {
+		items[i].Source = source
+	}
+	res, err := core.ImportBookmarks(ws.userDB(r), f.Source, items, splitTags(r.FormValue("tags")))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to import %s export: %v", f.Source, err)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	ws.viewImport(w, r, format, &res)
+}
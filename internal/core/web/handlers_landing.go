@@ -0,0 +1,282 @@
+package web
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// landingLabels name the landing page widgets.
+var landingLabels = map[string]string{
+	db.LandingPinned: "Pinned",
+	db.LandingRecent: "Recently added",
+	db.LandingUnread: "Unread",
+	db.LandingStats:  "Stats",
+}
+
+// handleLanding serves the landing page (GET /home): the current user's
+// widgets, in the order their layout puts them. Clients that send Accept:
+// application/json get a landingView.
+func (ws *Server) handleLanding(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	layout, err := ws.userDB(r).GetLandingLayout(requestUserID(r))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to load landing layout: %v", err)
+		return
+	}
+	view, err := ws.buildLandingView(r, layout)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to build landing page: %v", err)
+		return
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	ws.renderTemplate(w, "home.html", map[string]any{
+		"ActivePage": "home",
+		"CSRFToken":  csrfToken(r),
+		"Widgets":    view.Widgets,
+	})
+}
+
+// buildLandingView fills in layout's widgets for the current user.
+func (ws *Server) buildLandingView(r *http.Request, layout db.LandingLayout) (landingView, error) {
+	database := ws.userDB(r)
+	views := func(bookmarks []db.Bookmark) []bookmarkView {
+		out := []bookmarkView{}
+		for _, b := range bookmarks {
+			out = append(out, buildBookmarkView(database, b))
+		}
+		return out
+	}
+
+	view := landingView{Widgets: []landingWidgetView{}}
+	for _, name := range layout.Widgets {
+		widget := landingWidgetView{Name: name, Label: landingLabels[name]}
+		switch name {
+		case db.LandingPinned:
+			bookmarks, err := database.ListPinnedBookmarks(0)
+			if err != nil {
+				return landingView{}, err
+			}
+			widget.Bookmarks = views(bookmarks)
+			collections, err := database.ListPinnedCollections()
+			if err != nil {
+				return landingView{}, err
+			}
+			widget.Collections = []pinnedCollectionView{}
+			for _, c := range collections {
+				bookmarks, err := database.ListCollectionBookmarks(c.Collection, layout.ListSize)
+				if err != nil {
+					return landingView{}, err
+				}
+				widget.Collections = append(widget.Collections, pinnedCollectionView{
+					Collection: c.Collection,
+					Count:      c.Count,
+					Bookmarks:  views(bookmarks),
+				})
+			}
+		case db.LandingRecent:
+			bookmarks, err := database.ListBookmarks(layout.ListSize)
+			if err != nil {
+				return landingView{}, err
+			}
+			widget.Bookmarks = views(bookmarks)
+		case db.LandingUnread:
+			bookmarks, err := database.ListFilteredBookmarks(db.BookmarkFilter{UnreadOnly: true}, layout.ListSize)
+			if err != nil {
+				return landingView{}, err
+			}
+			widget.Bookmarks = views(bookmarks)
+		case db.LandingStats:
+			counts, err := database.CountBookmarks()
+			if err != nil {
+				return landingView{}, err
+			}
+			widget.Stats = &bookmarkCountsView{
+				Total:     counts.Total,
+				Unread:    counts.Unread,
+				Favorites: counts.Favorites,
+				Pinned:    counts.Pinned,
+				Archived:  counts.Archived,
+			}
+		}
+		view.Widgets = append(view.Widgets, widget)
+	}
+	return view, nil
+}
+
+// setPinned pins a bookmark to the landing page, or unpins it when the
+// "pinned" form field is "false". JSON clients get the bookmark and HTMX
+// requests the list fragment; browsers are sent back to the "next" path,
+// the bookmark list by default.
+func (ws *Server) setPinned(w http.ResponseWriter, r *http.Request, id int64) {
+	pinned := r.FormValue("pinned") != "false"
+	if err := ws.userDB(r).SetBookmarkPinned(id, pinned); err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	if !wantsJSON(r) && !isHTMX(r) {
+		http.Redirect(w, r, safeRedirect(r.FormValue("next")), http.StatusSeeOther)
+		return
+	}
+	ws.respondBookmarkChanged(w, r, id)
+}
+
+// handlePinnedCollections pins (POST, with the form field collection) a
+// collection to the current user's landing page, or unpins it when the form
+// field pinned is "false". Clients that send Accept: application/json get
+// the pinned collections back; browsers are sent to the landing page.
+func (ws *Server) handlePinnedCollections(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	collection := strings.TrimSpace(r.FormValue("collection"))
+	if collection == "" {
+		writeError(w, r, http.StatusBadRequest, "Collection is required")
+		return
+	}
+	database := ws.userDB(r)
+	change := database.PinCollection
+	if r.FormValue("pinned") == "false" {
+		change = database.UnpinCollection
+	}
+	if err := change(collection); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to pin collection")
+		log.Printf("Failed to pin collection: %v", err)
+		return
+	}
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/home", http.StatusSeeOther)
+		return
+	}
+	pinned, err := database.ListPinnedCollections()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list pinned collections: %v", err)
+		return
+	}
+	names := []string{}
+	for _, c := range pinned {
+		names = append(names, c.Collection)
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+// handleLandingLayout returns (GET) or saves (POST) the current user's
+// landing layout. Clients that send Accept: application/json get a
+// landingLayoutView back; browsers are sent to the settings page, where the
+// layout is managed.
+//
+// The form has a field named after each widget, holding its position (1 is
+// the top) or "off" to hide it, and list_size, how many bookmarks each list
+// shows. Widgets given the same position keep their default order.
+func (ws *Server) handleLandingLayout(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid form data")
+			return
+		}
+		layout, err := parseLandingLayout(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		layout.UserID = requestUserID(r)
+		if err := ws.userDB(r).SaveLandingLayout(layout); err != nil {
+			if errors.Is(err, db.ErrInvalidLandingLayout) {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to save landing layout")
+			log.Printf("Failed to save landing layout: %v", err)
+			return
+		}
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	view, err := ws.landingLayoutView(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to load landing layout: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// parseLandingLayout reads a landing layout from the form handleLandingLayout
+// describes.
+func parseLandingLayout(r *http.Request) (db.LandingLayout, error) {
+	var layout db.LandingLayout
+	positions := map[string]int{}
+	for _, name := range db.LandingWidgets {
+		v := strings.TrimSpace(r.FormValue(name))
+		if v == "" || v == "off" {
+			continue
+		}
+		pos, err := strconv.Atoi(v)
+		if err != nil || pos < 1 {
+			return db.LandingLayout{}, fmt.Errorf("invalid position for %s: %q", name, v)
+		}
+		positions[name] = pos
+		layout.Widgets = append(layout.Widgets, name)
+	}
+	// Widgets are appended in default order, so the stable sort keeps it
+	// for ties.
+	slices.SortStableFunc(layout.Widgets, func(a, b string) int { return cmp.Compare(positions[a], positions[b]) })
+
+	if v := strings.TrimSpace(r.FormValue("list_size")); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return db.LandingLayout{}, fmt.Errorf("invalid list_size: %q", v)
+		}
+		layout.ListSize = size
+	}
+	return layout, nil
+}
+
+// landingLayoutView returns the current user's landing layout.
+func (ws *Server) landingLayoutView(r *http.Request) (landingLayoutView, error) {
+	layout, err := ws.userDB(r).GetLandingLayout(requestUserID(r))
+	if err != nil {
+		return landingLayoutView{}, err
+	}
+	view := landingLayoutView{Widgets: layout.Widgets, ListSize: layout.ListSize, MaxListSize: db.MaxLandingListSize}
+	for i, name := range layout.Widgets {
+		view.Positions = append(view.Positions, landingPositionView{Name: name, Label: landingLabels[name], Position: i + 1})
+	}
+	for i, name := range db.LandingWidgets {
+		view.Slots = append(view.Slots, i+1)
+		if !slices.Contains(layout.Widgets, name) {
+			view.Positions = append(view.Positions, landingPositionView{Name: name, Label: landingLabels[name]})
+		}
+	}
+	return view, nil
+}
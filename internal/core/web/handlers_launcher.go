@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// handleLauncher answers launcher extensions such as Alfred and Raycast:
+// the best matches for the "q" search (see db.SearchBookmarks), or the
+// newest bookmarks without one, as a launcherView. "limit" caps the matches
+// at up to core.MaxLauncherResults (default core.DefaultLauncherResults).
+// It reads only the bookmark rows and archive status, never archives, so it
+// stays fast enough to run on every keystroke.
+func (ws *Server) handleLauncher(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	limit := core.DefaultLauncherResults
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, errorView{Message: "Invalid limit"})
+			return
+		}
+		limit = min(n, core.MaxLauncherResults)
+	}
+
+	database := ws.userDB(r)
+	var bookmarks []db.Bookmark
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		results, err := database.SearchBookmarks(q, db.BookmarkFilter{}, limit)
+		if errors.Is(err, db.ErrInvalidSearch) {
+			writeJSONError(w, http.StatusBadRequest, errorView{Code: errorCodeInvalidSearch, Message: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, errorView{Message: "Search failed"})
+			log.Printf("Failed to search bookmarks: %v", err)
+			return
+		}
+		for _, res := range results {
+			bookmarks = append(bookmarks, res.Bookmark)
+		}
+	} else {
+		var err error
+		if bookmarks, err = database.ListBookmarks(limit); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, errorView{Message: "Listing bookmarks failed"})
+			log.Printf("Failed to list bookmarks: %v", err)
+			return
+		}
+	}
+
+	server := requestServerURL(r)
+	view := launcherView{Items: make([]launcherItemView, 0, len(bookmarks))}
+	for _, b := range bookmarks {
+		item := launcherItemView{
+			UID:      strconv.FormatInt(b.ID, 10),
+			Title:    b.Title,
+			Subtitle: b.URL,
+			Arg:      b.URL,
+			URL:      b.URL,
+		}
+		if item.Title == "" {
+			item.Title = b.URL
+		}
+		if archive, err := database.GetBookmarkArchiveStatus(b.ID); err == nil && archive.ArchivedAt != "" {
+			item.ArchiveURL = fmt.Sprintf("%s/bookmarks/%d/archive", server, b.ID)
+			item.Mods = map[string]launcherModView{"cmd": {Arg: item.ArchiveURL, Subtitle: "Open archive"}}
+		}
+		view.Items = append(view.Items, item)
+	}
+	writeJSON(w, http.StatusOK, view)
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// notificationLabels name the events on the settings page.
+var notificationLabels = map[db.EventKind]string{
+	db.OnBookmarkCreatedEvent:     "Bookmark saved",
+	db.OnBookmarkUpdatedEvent:     "Bookmark edited",
+	db.OnBookmarkDeletedEvent:     "Bookmark deleted",
+	db.OnArchiveResultSavedEvent:  "Archive finished",
+	db.OnArchiveClearedEvent:      "Archive cleared for re-archiving",
+	db.OnImportFinishedEvent:      "Import finished",
+	db.OnArchiveVisualChangeEvent: "Archived page looks different",
+}
+
+// handleNotifications lists (GET) or saves (POST) the current user's
+// notification preferences. Clients that send Accept: application/json get
+// JSON back; browsers are sent to the settings page, where they're managed.
+//
+// The form has a checkbox named "<event>.<channel>" for every event and
+// channel; unchecked ones turn the channel off for the event.
+func (ws *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		ws.writeNotificationViews(w, r)
+	case http.MethodPost:
+		ws.saveNotifications(w, r)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (ws *Server) saveNotifications(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	userID := requestUserID(r)
+	database := ws.userDB(r)
+	// The form has every event and channel, so it replaces whatever was
+	// stored; only what's turned off needs a row.
+	if err := database.ResetNotificationPreferences(userID); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to save notification preferences")
+		log.Printf("Failed to reset notification preferences: %v", err)
+		return
+	}
+	for _, kind := range db.EventKinds {
+		for _, channel := range db.NotificationChannels {
+			if r.Form.Has(kind.String() + "." + channel) {
+				continue
+			}
+			p := db.NotificationPreference{UserID: userID, Event: kind.String(), Channel: channel}
+			if err := database.SetNotificationPreference(p); err != nil {
+				writeError(w, r, http.StatusInternalServerError, "Failed to save notification preferences")
+				log.Printf("Failed to save notification preference: %v", err)
+				return
+			}
+		}
+	}
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	ws.writeNotificationViews(w, r)
+}
+
+func (ws *Server) writeNotificationViews(w http.ResponseWriter, r *http.Request) {
+	views, err := ws.notificationViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to load notification preferences: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// notificationViews returns whether the current user is notified of each
+// event over each channel.
+func (ws *Server) notificationViews(r *http.Request) ([]notificationView, error) {
+	userID := requestUserID(r)
+	database := ws.userDB(r)
+	views := make([]notificationView, 0, len(db.EventKinds))
+	for _, kind := range db.EventKinds {
+		v := notificationView{Event: kind.String(), Label: notificationLabels[kind]}
+		for _, channel := range db.NotificationChannels {
+			enabled, err := database.NotificationEnabled(userID, kind, channel)
+			if err != nil {
+				return nil, err
+			}
+			v.Channels = append(v.Channels, notificationChannelView{Channel: channel, Enabled: enabled})
+		}
+		views = append(views, v)
+	}
+	return views, nil
+}
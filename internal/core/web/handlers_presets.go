@@ -0,0 +1,199 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// handlePresets lists (GET) or creates (POST) the current user's bookmark
+// presets. Clients that send Accept: application/json get JSON back;
+// browsers are sent to the settings page, where presets are managed.
+//
+// New presets are read from the form fields name, tags, collection,
+// skip_archive ("true" or "false") and strip_scripts, mobile_viewport and
+// screenshot ("on", "off" or "" to keep the user's preference).
+func (ws *Server) handlePresets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		views, err := ws.presetViews(r)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to list presets: %v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		ws.createPreset(w, r)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (ws *Server) createPreset(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	p := db.BookmarkPreset{
+		Name:       r.FormValue("name"),
+		Tags:       splitTags(r.FormValue("tags")),
+		Collection: r.FormValue("collection"),
+	}
+	if v := r.FormValue("skip_archive"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid value for skip_archive")
+			return
+		}
+		p.SkipArchive = b
+	}
+	for _, field := range []struct {
+		name string
+		dst  **bool
+	}{
+		{"strip_scripts", &p.Archive.StripScripts},
+		{"mobile_viewport", &p.Archive.MobileViewport},
+		{"screenshot", &p.Archive.Screenshot},
+	} {
+		v, ok := parseTriState(r.FormValue(field.name))
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "Invalid value for "+field.name)
+			return
+		}
+		*field.dst = v
+	}
+
+	id, err := ws.userDB(r).CreateBookmarkPreset(p)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPreset) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Failed to create preset")
+		log.Printf("Failed to create preset: %v", err)
+		return
+	}
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	created, err := ws.userDB(r).GetBookmarkPreset(id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get preset %d: %v", id, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newPresetView(created))
+}
+
+// handlePreset handles POST /settings/presets/{id}/delete.
+func (ws *Server) handlePreset(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/settings/presets/"), "/")
+	if len(parts) != 2 || parts[1] != "delete" {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid preset ID")
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if _, err := ws.userDB(r).GetBookmarkPreset(id); err != nil {
+		writeError(w, r, http.StatusNotFound, "Preset not found")
+		return
+	}
+	if err := ws.userDB(r).DeleteBookmarkPreset(id); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to delete preset")
+		log.Printf("Failed to delete preset %d: %v", id, err)
+		return
+	}
+	if wantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// requestPreset returns the preset named by the "preset" form value, the ID
+// of one of the current user's presets, and false if there is none.
+func (ws *Server) requestPreset(r *http.Request) (db.BookmarkPreset, bool, error) {
+	v := strings.TrimSpace(r.FormValue("preset"))
+	if v == "" {
+		return db.BookmarkPreset{}, false, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return db.BookmarkPreset{}, false, fmt.Errorf("invalid preset ID: %s", v)
+	}
+	p, err := ws.userDB(r).GetBookmarkPreset(id)
+	if err != nil {
+		return db.BookmarkPreset{}, false, err
+	}
+	return p, true, nil
+}
+
+func (ws *Server) presetViews(r *http.Request) ([]presetView, error) {
+	presets, err := ws.userDB(r).ListBookmarkPresets()
+	if err != nil {
+		return nil, err
+	}
+	views := []presetView{}
+	for _, p := range presets {
+		views = append(views, newPresetView(p))
+	}
+	return views, nil
+}
+
+func newPresetView(p db.BookmarkPreset) presetView {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return presetView{
+		ID:             p.ID,
+		Name:           p.Name,
+		Tags:           tags,
+		Collection:     p.Collection,
+		SkipArchive:    p.SkipArchive,
+		StripScripts:   p.Archive.StripScripts,
+		MobileViewport: p.Archive.MobileViewport,
+		Screenshot:     p.Archive.Screenshot,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+// ArchiveLabels describes the archive options the preset sets, for the
+// settings page.
+func (v presetView) ArchiveLabels() []string {
+	var labels []string
+	for _, o := range []struct {
+		value   *bool
+		on, off string
+	}{
+		{v.StripScripts, "scripts stripped", "scripts kept"},
+		{v.MobileViewport, "mobile viewport", "desktop viewport"},
+		{v.Screenshot, "screenshot", "no screenshot"},
+	} {
+		switch {
+		case o.value == nil:
+		case *o.value:
+			labels = append(labels, o.on)
+		default:
+			labels = append(labels, o.off)
+		}
+	}
+	return labels
+}
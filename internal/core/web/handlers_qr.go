@@ -0,0 +1,54 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"rsc.io/qr"
+)
+
+// qrScale is how many image pixels each QR module takes up.
+const qrScale = 6
+
+// serveBookmarkQR serves a PNG QR code for a bookmark, so a link can be moved
+// from a desktop session to a phone by pointing its camera at the screen.
+// ?link=original (the default) encodes the bookmarked URL; ?link=archive
+// encodes this server's archive page for the bookmark (by its slug), which
+// the phone opens after signing in.
+func (ws *Server) serveBookmarkQR(w http.ResponseWriter, r *http.Request, id int64) {
+	bookmark, err := ws.userDB(r).GetBookmark(id)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	var text string
+	switch link := r.URL.Query().Get("link"); link {
+	case "", "original":
+		text = bookmark.URL
+	case "archive":
+		text = shareURL(r, bookmark)
+	default:
+		writeError(w, r, http.StatusBadRequest, "Invalid link: want original or archive")
+		return
+	}
+
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, "URL too long for a QR code")
+		return
+	}
+	code.Scale = qrScale
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if _, err := w.Write(code.PNG()); err != nil {
+		log.Printf("Failed to write QR code: %v", err)
+	}
+}
+
+// qrURL links the QR code encoding a bookmark's link ("original" or "archive").
+func qrURL(id int64, link string) string {
+	return fmt.Sprintf("/bookmarks/%d/qr?link=%s", id, link)
+}
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// handleRoutingRules lists (GET) or creates (POST) routing rules. Clients
+// that send Accept: application/json get JSON back; browsers are sent to the
+// settings page, where the rules are managed. Routing rules apply to every
+// user's bookmarks, so only admins may change them.
+//
+// New rules are read from the form fields name, domain, title_contains,
+// add_tag, collection, skip_archive and enabled (both "true" or "false";
+// enabled defaults to true).
+func (ws *Server) handleRoutingRules(w http.ResponseWriter, r *http.Request) {
+	if !ws.requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		views, err := ws.routingRuleViews(r)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to list routing rules: %v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		ws.createRoutingRule(w, r)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (ws *Server) createRoutingRule(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+	rule := db.RoutingRule{
+		Name:          r.FormValue("name"),
+		Domain:        r.FormValue("domain"),
+		TitleContains: r.FormValue("title_contains"),
+		AddTag:        r.FormValue("add_tag"),
+		Collection:    r.FormValue("collection"),
+		Enabled:       true,
+	}
+	for _, field := range []struct {
+		name string
+		dst  *bool
+	}{
+		{"skip_archive", &rule.SkipArchive},
+		{"enabled", &rule.Enabled},
+	} {
+		if v := r.FormValue(field.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "Invalid value for "+field.name)
+				return
+			}
+			*field.dst = b
+		}
+	}
+
+	id, err := ws.requestDB(r).CreateRoutingRule(rule)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidRoutingRule) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Failed to create routing rule")
+		log.Printf("Failed to create routing rule: %v", err)
+		return
+	}
+	ws.routingRuleResponse(w, r, id, http.StatusCreated)
+}
+
+// handleRoutingRule handles POST /settings/routing/{id}/enable,
+// /settings/routing/{id}/disable and /settings/routing/{id}/delete.
+func (ws *Server) handleRoutingRule(w http.ResponseWriter, r *http.Request) {
+	if !ws.requireAdmin(w, r) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/settings/routing/"), "/")
+	if len(parts) != 2 {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if _, err := ws.requestDB(r).GetRoutingRule(id); err != nil {
+		writeError(w, r, http.StatusNotFound, "Routing rule not found")
+		return
+	}
+
+	switch parts[1] {
+	case "enable", "disable":
+		if err := ws.requestDB(r).SetRoutingRuleEnabled(id, parts[1] == "enable"); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to update routing rule")
+			log.Printf("Failed to update routing rule %d: %v", id, err)
+			return
+		}
+		ws.routingRuleResponse(w, r, id, http.StatusOK)
+	case "delete":
+		if err := ws.requestDB(r).DeleteRoutingRule(id); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to delete routing rule")
+			log.Printf("Failed to delete routing rule %d: %v", id, err)
+			return
+		}
+		if wantsJSON(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+	default:
+		writeError(w, r, http.StatusNotFound, "Not Found")
+	}
+}
+
+// routingRuleResponse answers a change to rule id: JSON clients get the rule
+// with status, browsers are sent back to the settings page.
+func (ws *Server) routingRuleResponse(w http.ResponseWriter, r *http.Request, id int64, status int) {
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	rule, err := ws.requestDB(r).GetRoutingRule(id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get routing rule %d: %v", id, err)
+		return
+	}
+	writeJSON(w, status, newRoutingRuleView(rule))
+}
+
+func (ws *Server) routingRuleViews(r *http.Request) ([]routingRuleView, error) {
+	rules, err := ws.requestDB(r).ListRoutingRules()
+	if err != nil {
+		return nil, err
+	}
+	views := []routingRuleView{}
+	for _, rule := range rules {
+		views = append(views, newRoutingRuleView(rule))
+	}
+	return views, nil
+}
+
+func newRoutingRuleView(r db.RoutingRule) routingRuleView {
+	return routingRuleView{
+		ID:            r.ID,
+		Name:          r.Name,
+		Domain:        r.Domain,
+		TitleContains: r.TitleContains,
+		AddTag:        r.AddTag,
+		Collection:    r.Collection,
+		SkipArchive:   r.SkipArchive,
+		Enabled:       r.Enabled,
+	}
+}
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// handleSettings shows and saves the archive preferences of the current user.
+// Each preference is tri-state: "on", "off" or "" to use the server's default.
+// The page also lists the user's presets, changed via /settings/presets,
+// their notification preferences, changed via /settings/notifications,
+// their shared collections, changed via /settings/shared,
+// the running version, and for admins any available update and the
+// routing rules, changed via /settings/routing.
+func (ws *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ws.viewSettings(w, r, false)
+	case http.MethodPost:
+		ws.saveSettings(w, r)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (ws *Server) viewSettings(w http.ResponseWriter, r *http.Request, saved bool) {
+	prefs, err := ws.userDB(r).GetArchivePreferences(requestUserID(r))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to load archive preferences: %v", err)
+		return
+	}
+	presets, err := ws.presetViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to list presets: %v", err)
+		return
+	}
+	notifications, err := ws.notificationViews(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to load notification preferences: %v", err)
+		return
+	}
+	shared, err := ws.sharedCollectionLinks(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to list shared collections: %v", err)
+		return
+	}
+	admin := ws.isAdmin(r)
+	var rules []routingRuleView
+	if admin {
+		if rules, err = ws.routingRuleViews(r); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+			log.Printf("Failed to list routing rules: %v", err)
+			return
+		}
+	}
+	landing, err := ws.landingLayoutView(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to load landing layout: %v", err)
+		return
+	}
+	version, err := ws.versionView(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to load settings")
+		log.Printf("Failed to read schema version: %v", err)
+		return
+	}
+	ws.renderTemplate(w, "settings.html", map[string]any{
+		"Preferences": newPreferenceViews(prefs),
+		"Presets":     presets,
+		// Presets can set the capture options, but not auto-archiving.
+		"PresetOptions": newPreferenceViews(db.ArchivePreferences{})[1:],
+		"Notifications": notifications,
+		"Channels":      db.NotificationChannels,
+		"Shared":        shared,
+		"Landing":       landing,
+		"IsAdmin":       admin,
+		"RoutingRules":  rules,
+		"Version":       version,
+		"Saved":         saved,
+		"ActivePage":    "settings",
+		"CSRFToken":     csrfToken(r),
+	})
+}
+
+func (ws *Server) saveSettings(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	prefs := db.ArchivePreferences{UserID: requestUserID(r)}
+	for _, field := range []struct {
+		name string
+		dst  **bool
+	}{
+		{"auto_archive", &prefs.AutoArchive},
+		{"strip_scripts", &prefs.StripScripts},
+		{"mobile_viewport", &prefs.MobileViewport},
+		{"screenshot", &prefs.Screenshot},
+	} {
+		v, ok := parseTriState(r.FormValue(field.name))
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "Invalid value for "+field.name)
+			return
+		}
+		*field.dst = v
+	}
+
+	if err := ws.userDB(r).SaveArchivePreferences(prefs); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to save settings")
+		log.Printf("Failed to save archive preferences: %v", err)
+		return
+	}
+	ws.viewSettings(w, r, true)
+}
+
+// parseTriState parses a settings form value. "" means unset.
+func parseTriState(s string) (*bool, bool) {
+	switch s {
+	case "":
+		return nil, true
+	case "on":
+		v := true
+		return &v, true
+	case "off":
+		v := false
+		return &v, true
+	}
+	return nil, false
+}
+
+func newPreferenceViews(p db.ArchivePreferences) []preferenceView {
+	return []preferenceView{
+		{"auto_archive", "Archive automatically", "Queue new bookmarks for archiving as soon as they are saved.", triStateValue(p.AutoArchive)},
+		{"strip_scripts", "Strip scripts", "Remove scripts and inline event handlers from archived pages.", triStateValue(p.StripScripts)},
+		{"mobile_viewport", "Mobile viewport", "Capture pages as a phone would render them.", triStateValue(p.MobileViewport)},
+		{"screenshot", "Screenshots", "Store a full-page screenshot with each archive.", triStateValue(p.Screenshot)},
+	}
+}
+
+func triStateValue(v *bool) string {
+	switch {
+	case v == nil:
+		return ""
+	case *v:
+		return "on"
+	default:
+		return "off"
+	}
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// sharedPrefix is where shared collections are published. Its routes don't
+// need a login.
+const sharedPrefix = "/shared/"
+
+// handleSharedCollection serves a shared collection to anyone with its
+// token: GET /shared/{token}.json is the JSON feed, readable from any
+// origin, and GET /shared/{token} a plain HTML list meant to be embedded in
+// an iframe.
+func (ws *Server) handleSharedCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, sharedPrefix)
+	token, asJSON := strings.CutSuffix(token, ".json")
+	if token == "" || strings.Contains(token, "/") {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	shared, err := ws.requestDB(r).GetSharedCollectionByToken(token)
+	if err != nil {
+		if errors.Is(err, db.ErrSharedCollectionNotFound) {
+			writeError(w, r, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to get shared collection: %v", err)
+		return
+	}
+	bookmarks, err := ws.requestDB(r).ListSharedBookmarks(shared)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list shared collection %d: %v", shared.ID, err)
+		return
+	}
+
+	view := sharedCollectionView{
+		Collection: shared.Collection,
+		URL:        requestServerURL(r) + sharedPrefix + shared.Token,
+		Bookmarks:  make([]sharedBookmarkView, 0, len(bookmarks)),
+	}
+	for _, b := range bookmarks {
+		view.Bookmarks = append(view.Bookmarks, sharedBookmarkView{
+			URL:         b.URL,
+			Title:       b.Title,
+			Description: b.Description,
+			Tags:        b.Tags,
+			CreatedAt:   b.CreatedAt,
+			Date:        sharedDate(b.CreatedAt),
+		})
+	}
+	// Embeds re-fetch on every page view; let caches take some of that.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if asJSON {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	// Links open outside the embedding page's iframe; nothing else loads.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'")
+	ws.renderTemplate(w, "shared.html", view)
+}
+
+// handleSharedCollections lists (GET) or creates (POST, with the form field
+// collection) the current user's shared collections. Clients that send
+// Accept: application/json get JSON back; browsers are sent to the settings
+// page, where shared collections are managed.
+func (ws *Server) handleSharedCollections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		views, err := ws.sharedCollectionLinks(r)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to list shared collections: %v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid form data")
+			return
+		}
+		collection := strings.TrimSpace(r.FormValue("collection"))
+		if collection == "" {
+			writeError(w, r, http.StatusBadRequest, "Collection is required")
+			return
+		}
+		shared, err := ws.userDB(r).ShareCollection(collection)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to share collection")
+			log.Printf("Failed to share collection: %v", err)
+			return
+		}
+		if !wantsJSON(r) {
+			http.Redirect(w, r, "/settings", http.StatusSeeOther)
+			return
+		}
+		writeJSON(w, http.StatusCreated, newSharedCollectionLinkView(r, shared))
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// handleSharedCollectionAction handles POST /settings/shared/{id}/delete,
+// which stops sharing a collection.
+func (ws *Server) handleSharedCollectionAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/settings/shared/"), "/")
+	if len(parts) != 2 || parts[1] != "delete" {
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid shared collection ID")
+		return
+	}
+	if err := ws.userDB(r).UnshareCollection(id); err != nil {
+		if errors.Is(err, db.ErrSharedCollectionNotFound) {
+			writeError(w, r, http.StatusNotFound, "Shared collection not found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Failed to unshare collection")
+		log.Printf("Failed to unshare collection %d: %v", id, err)
+		return
+	}
+	if !wantsJSON(r) {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedCollectionLinks returns the current user's shared collections.
+func (ws *Server) sharedCollectionLinks(r *http.Request) ([]sharedCollectionLinkView, error) {
+	shared, err := ws.userDB(r).ListSharedCollections()
+	if err != nil {
+		return nil, err
+	}
+	views := make([]sharedCollectionLinkView, 0, len(shared))
+	for _, c := range shared {
+		views = append(views, newSharedCollectionLinkView(r, c))
+	}
+	return views, nil
+}
+
+func newSharedCollectionLinkView(r *http.Request, c db.SharedCollection) sharedCollectionLinkView {
+	page := requestServerURL(r) + sharedPrefix + c.Token
+	snippet := fmt.Sprintf(`<iframe src="%s" title="%s" style="width: 100%%; height: 400px; border: 0" loading="lazy"></iframe>`,
+		template.HTMLEscapeString(page), template.HTMLEscapeString(c.Collection))
+	return sharedCollectionLinkView{
+		ID:         c.ID,
+		Collection: c.Collection,
+		JSONURL:    page + ".json",
+		WidgetURL:  page,
+		Snippet:    snippet,
+		CreatedAt:  c.CreatedAt,
+	}
+}
+
+// sharedDate is the day an RFC3339 timestamp falls on, or the timestamp
+// itself if it doesn't parse.
+func sharedDate(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ts
+	}
+	return t.Format("2006-01-02")
+}
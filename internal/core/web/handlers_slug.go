@@ -0,0 +1,50 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// updateSlug renames the slug a bookmark's share links and downloads use,
+// from the slug form field. Browsers are sent to the archive under its new
+// name; API clients get {"slug": ...} back.
+func (ws *Server) updateSlug(w http.ResponseWriter, r *http.Request, id int64) {
+	if _, err := ws.userDB(r).GetBookmark(id); err != nil {
+		writeError(w, r, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+	slug, err := ws.userDB(r).SetBookmarkSlug(id, r.FormValue("slug"))
+	switch {
+	case errors.Is(err, db.ErrInvalidSlug):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, db.ErrSlugTaken):
+		writeErrorCode(w, r, http.StatusConflict, errorCodeDuplicate, err.Error())
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to set slug for bookmark %d: %v", id, err)
+		return
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, map[string]string{"slug": slug})
+		return
+	}
+	http.Redirect(w, r, "/bookmarks/"+url.PathEscape(slug)+"/archive", http.StatusSeeOther)
+}
+
+// shareURL is the absolute link to a bookmark's archive by its slug, or by
+// its ID if it has none.
+func shareURL(r *http.Request, b db.Bookmark) string {
+	name := fmt.Sprint(b.ID)
+	if b.Slug != "" {
+		name = url.PathEscape(b.Slug)
+	}
+	return requestServerURL(r) + "/bookmarks/" + name + "/archive"
+}
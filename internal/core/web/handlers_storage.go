@@ -0,0 +1,140 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Size of the storage chart's SVG viewBox.
+const (
+	storageChartWidth  = 600
+	storageChartHeight = 160
+)
+
+// handleArchivesStorage serves the storage card of the archive dashboard:
+// disk use over core.StorageForecastWindow, one sample per day, and the
+// growth forecast. Clients that send Accept: application/json get the same
+// data as JSON. Storage is instance-wide, so only admins may see it.
+func (ws *Server) handleArchivesStorage(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) || !ws.requireAdmin(w, r) {
+		return
+	}
+	samples, err := ws.requestDB(r).ListStorageSamples(time.Now().Add(-core.StorageForecastWindow))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list storage samples: %v", err)
+		return
+	}
+	view := newStorageView(samples)
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	ws.renderFragment(w, r, "archive_storage.html", view)
+}
+
+// newStorageView forecasts from samples (oldest first) and charts the last
+// sample of each day.
+func newStorageView(samples []db.StorageSample) storageView {
+	f := core.ForecastStorage(samples)
+	view := storageView{
+		UsedBytes:      f.UsedBytes,
+		Used:           core.FormatBytes(f.UsedBytes),
+		FreeBytes:      f.FreeBytes,
+		BytesPerWeek:   f.BytesPerWeek,
+		WeeksUntilFull: f.WeeksUntilFull,
+		Summary:        f.Summary(),
+		Samples:        []storageSampleView{},
+	}
+	if f.FreeBytes >= 0 {
+		view.Free = core.FormatBytes(f.FreeBytes)
+	}
+	for _, s := range dailyStorageSamples(samples) {
+		view.Samples = append(view.Samples, storageSampleView{
+			TakenAt:           s.TakenAt,
+			DatabaseBytes:     s.DatabaseBytes,
+			ArchiveStoreBytes: s.ArchiveStoreBytes,
+			FreeBytes:         s.FreeBytes,
+		})
+	}
+	view.Chart = newStorageChart(view.Samples, f.BytesPerWeek)
+	return view
+}
+
+// dailyStorageSamples keeps the last sample of each UTC day.
+func dailyStorageSamples(samples []db.StorageSample) []db.StorageSample {
+	var out []db.StorageSample
+	for _, s := range samples {
+		if n := len(out); n > 0 && sameDay(out[n-1].TakenAt, s.TakenAt) {
+			out[n-1] = s
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func sameDay(a, b string) bool {
+	return len(a) >= 10 && len(b) >= 10 && a[:10] == b[:10]
+}
+
+// newStorageChart plots used storage and, when it is growing, a dashed
+// projection over a further third of the charted period.
+func newStorageChart(samples []storageSampleView, bytesPerWeek float64) storageChartView {
+	type point struct {
+		at   time.Time
+		used float64
+	}
+	var points []point
+	for _, s := range samples {
+		at, err := time.Parse(time.RFC3339, s.TakenAt)
+		if err != nil {
+			continue
+		}
+		points = append(points, point{at, float64(s.DatabaseBytes + s.ArchiveStoreBytes)})
+	}
+	if len(points) < 2 {
+		return storageChartView{}
+	}
+	first, last := points[0], points[len(points)-1]
+	end := last.at.Add(last.at.Sub(first.at) / 3)
+	projected := last.used
+	if bytesPerWeek > 0 {
+		projected += bytesPerWeek * end.Sub(last.at).Hours() / (7 * 24)
+	}
+	top := projected
+	for _, p := range points {
+		top = max(top, p.used)
+	}
+	top *= 1.1
+	span := end.Sub(first.at).Seconds()
+	xy := func(at time.Time, used float64) string {
+		x := at.Sub(first.at).Seconds() / span * storageChartWidth
+		y := storageChartHeight - used/top*storageChartHeight
+		return fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	var line []string
+	for _, p := range points {
+		line = append(line, xy(p.at, p.used))
+	}
+	chart := storageChartView{
+		Width:  storageChartWidth,
+		Height: storageChartHeight,
+		Line:   strings.Join(line, " "),
+		Top:    core.FormatBytes(int64(top)),
+		Start:  first.at.Format("Jan 2"),
+		End:    end.Format("Jan 2"),
+	}
+	if bytesPerWeek > 0 {
+		chart.Projection = xy(last.at, last.used) + " " + xy(end, projected)
+	}
+	return chart
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Tag actions, posted to /tags/{action}.
+const (
+	tagActionRename = "rename"
+	tagActionMerge  = "merge"
+	tagActionDelete = "delete"
+)
+
+// handleTags serves the tags page (GET /tags): every tag on the current
+// user's bookmarks, with how many each is on and forms to rename, merge
+// and delete them. Clients that send Accept: application/json get
+// []tagView.
+func (ws *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	tags, err := ws.userDB(r).ListTags()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to list tags: %v", err)
+		return
+	}
+	views := newTagViews(tags)
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, views)
+		return
+	}
+	ws.renderTemplate(w, "tags.html", map[string]any{
+		"ActivePage": "tags",
+		"CSRFToken":  csrfToken(r),
+		"Tags":       views,
+	})
+}
+
+// handleTagAction handles POST /tags/rename (form fields tag and to),
+// /tags/merge (tags, repeated or comma-separated, and into) and
+// /tags/delete (tag), which change the tag on all of the current user's
+// bookmarks. JSON clients get a tagChangeView; browsers are sent back to
+// the tags page.
+func (ws *Server) handleTagAction(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(r.URL.Path, "/tags/")
+	switch action {
+	case tagActionRename, tagActionMerge, tagActionDelete:
+	default:
+		writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	database := ws.userDB(r)
+	var (
+		res tagChangeView
+		err error
+	)
+	switch action {
+	case tagActionRename:
+		res.Tag = db.NormalizeTag(r.FormValue("to"))
+		res.Bookmarks, err = database.RenameTag(r.FormValue("tag"), r.FormValue("to"))
+	case tagActionMerge:
+		var tags []string
+		for _, v := range r.Form["tags"] {
+			tags = append(tags, splitTags(v)...)
+		}
+		res.Tag = db.NormalizeTag(r.FormValue("into"))
+		res.Bookmarks, err = database.MergeTags(tags, r.FormValue("into"))
+	case tagActionDelete:
+		res.Bookmarks, err = database.DeleteTag(r.FormValue("tag"))
+	}
+	switch {
+	case errors.Is(err, db.ErrInvalidTag):
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, db.ErrTagNotFound):
+		writeError(w, r, http.StatusNotFound, "Tag not found")
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to %s tags: %v", action, err)
+		return
+	}
+	log.Printf("Tag %s: %d bookmarks", action, res.Bookmarks)
+
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	http.Redirect(w, r, "/tags", http.StatusSeeOther)
+}
+
+// handleTagSuggest answers GET /tags/suggest with the current user's most
+// used tags starting with "q", as []tagView, for autocompleting tag fields.
+// "limit" caps them at up to core.MaxTagSuggestions (default
+// core.DefaultTagSuggestions).
+func (ws *Server) handleTagSuggest(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	limit := core.DefaultTagSuggestions
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, errorView{Message: "Invalid limit"})
+			return
+		}
+		limit = min(n, core.MaxTagSuggestions)
+	}
+	tags, err := ws.userDB(r).SuggestTags(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errorView{Message: "Suggesting tags failed"})
+		log.Printf("Failed to suggest tags: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTagViews(tags))
+}
+
+func newTagViews(tags []db.TagCount) []tagView {
+	views := make([]tagView, len(tags))
+	for i, t := range tags {
+		views[i] = tagView{Name: t.Name, Count: t.Count}
+	}
+	return views
+}
@@ -1,13 +1,26 @@
 package web
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+	"github.com/seckatie/bookmarkd/internal/core/db"
 )
 
 // TestHandleIndex tests the index page handler.
@@ -83,6 +96,117 @@ func TestHandleBookmarklet(t *testing.T) {
 	})
 }
 
+// TestBookmarkletGeneration tests generating, using and revoking signed
+// bookmarklets.
+func TestBookmarkletGeneration(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	server.sessions.setPassword("hunter2")
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+
+	req := httptest.NewRequest(http.MethodPost, "https://bm.example.com/bookmarklet/generate", strings.NewReader("name=Laptop"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	server.handleBookmarkletGenerate(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var generated struct {
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		ServerURL   string `json:"server_url"`
+		Bookmarklet string `json:"bookmarklet"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&generated); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if generated.Name != "Laptop" || generated.ServerURL != "https://bm.example.com" || !strings.HasPrefix(generated.Bookmarklet, "javascript:") {
+		t.Fatalf("unexpected bookmarklet %+v", generated)
+	}
+	_, after, _ := strings.Cut(generated.Bookmarklet, `t="`)
+	token, _, _ := strings.Cut(after, `"`)
+	if !strings.HasPrefix(token, "bmk_") || !strings.Contains(generated.Bookmarklet, `"https://bm.example.com"`) {
+		t.Fatalf("expected the server URL and token in %q", generated.Bookmarklet)
+	}
+
+	t.Run("opens the add page with the token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarklet/add?url=https://example.com&token="+token, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), token) {
+			t.Errorf("expected the add page to carry the token, got %d", w.Code)
+		}
+	})
+
+	t.Run("saves bookmarks but nothing else", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader("url=https://example.com/saved"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+			t.Errorf("expected the bookmark to be saved, got %d: %s", w.Code, w.Body.String())
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected listing bookmarks to be forbidden, got %d", w.Code)
+		}
+	})
+
+	t.Run("lists and revokes bookmarklets", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleBookmarklet(w, httptest.NewRequest(http.MethodGet, "/bookmarklet", nil))
+		if !strings.Contains(w.Body.String(), fmt.Sprintf("/bookmarklet/tokens/%d/revoke", generated.ID)) {
+			t.Fatal("expected the bookmarklet to be listed with a revoke button")
+		}
+
+		_, apiToken, err := server.db.CreateAPIToken("api", 0)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		api, _ := server.db.AuthenticateAPIToken(apiToken)
+		w = httptest.NewRecorder()
+		server.handleBookmarkletToken(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/bookmarklet/tokens/%d/revoke", api.ID), nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected other API tokens to be left alone, got %d", w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		server.handleBookmarkletToken(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/bookmarklet/tokens/%d/revoke", generated.ID), nil))
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("expected a redirect after revoking, got %d", w.Code)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/bookmarklet/add?url=https://example.com&token="+token, nil)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected a revoked bookmarklet to be refused, got %d", w.Code)
+		}
+	})
+}
+
+func TestBookmarkletJS(t *testing.T) {
+	js := string(bookmarkletJS("http://host:8080/100%", "bmk_abc", 0))
+	if !strings.Contains(js, `"http://host:8080/100%25"`) {
+		t.Errorf("expected '%%' to be escaped in %q", js)
+	}
+	if !strings.Contains(js, `t="bmk_abc"`) {
+		t.Errorf("expected the token in %q", js)
+	}
+}
+
 // TestHandleBookmarkletAdd tests the bookmarklet add handler.
 func TestHandleBookmarkletAdd(t *testing.T) {
 	server := newTestServer(t)
@@ -110,6 +234,17 @@ func TestHandleBookmarkletAdd(t *testing.T) {
 		}
 	})
 
+	t.Run("GET with selected text prefills notes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarklet/add?url=https://example.com&notes=Quoted+passage", nil)
+		w := httptest.NewRecorder()
+
+		server.handleBookmarkletAdd(w, req)
+
+		if !strings.Contains(w.Body.String(), `name="notes" value="Quoted passage"`) {
+			t.Error("expected notes to be submitted with the bookmark")
+		}
+	})
+
 	t.Run("GET with url only uses url as title", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/bookmarklet/add?url=https://example.com", nil)
 		w := httptest.NewRecorder()
@@ -173,6 +308,26 @@ func TestHandleBookmarks(t *testing.T) {
 		}
 	})
 
+	t.Run("shows the archive's size", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://sized.example", "Sized")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, core.ArchiveStatusOK, "", "https://sized.example", strings.Repeat("x", 2048)); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesList(w, req)
+
+		if body := w.Body.String(); !strings.Contains(body, "Size: 2.0 KB") {
+			t.Error("expected the archive's size to be shown")
+		}
+	})
+
 	t.Run("POST creates bookmark and redirects", func(t *testing.T) {
 		form := url.Values{}
 		form.Add("url", "https://newsite.com")
@@ -213,6 +368,172 @@ func TestHandleBookmarks(t *testing.T) {
 		}
 	})
 
+	t.Run("GET shows stored metadata", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://meta.com", "Meta")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := server.db.SaveBookmarkMetadata(db.BookmarkMetadata{
+			BookmarkID:  id,
+			Description: "A page about metadata",
+			FaviconURL:  "https://meta.com/favicon.ico",
+		}); err != nil {
+			t.Fatalf("failed to save metadata: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "A page about metadata") {
+			t.Error("expected list to contain description")
+		}
+		if !strings.Contains(body, `src="https://meta.com/favicon.ico"`) {
+			t.Error("expected list to contain favicon")
+		}
+		if !strings.Contains(body, "/bookmarks/"+itoa(id)+"/refresh-metadata") {
+			t.Error("expected list to contain refresh-metadata button")
+		}
+	})
+
+	t.Run("POST with quick-add line parses title and tags", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("q", "https://quick.com Quick Title #go #http ~toread")
+
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Quick Title") {
+			t.Error("expected list to contain parsed title")
+		}
+		if !strings.Contains(body, "#go") || !strings.Contains(body, "#http") {
+			t.Error("expected list to contain parsed tags")
+		}
+	})
+
+	t.Run("POST with quick-add flags sets read and favorite", func(t *testing.T) {
+		for line, want := range map[string]db.BookmarkFlags{
+			"https://flag-read.com Read ~read":             {IsRead: true},
+			"https://flag-fav.com Fav ~fav":                {IsFavorite: true},
+			"https://flag-both.com Both ~favorite ~read":   {IsRead: true, IsFavorite: true},
+			"https://flag-toread.com Later ~toread ~other": {},
+		} {
+			form := url.Values{}
+			form.Add("q", line)
+			req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+
+			server.handleBookmarks(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("%q: expected status %d, got %d: %s", line, http.StatusCreated, w.Code, w.Body.String())
+			}
+			var created struct{ ID int64 }
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("%q: failed to decode response: %v", line, err)
+			}
+			flags, err := server.db.GetBookmarkFlags(created.ID)
+			if err != nil {
+				t.Fatalf("%q: failed to get flags: %v", line, err)
+			}
+			if flags != want {
+				t.Errorf("%q: expected flags %+v, got %+v", line, want, flags)
+			}
+		}
+	})
+
+	t.Run("POST with tags field stores tags", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("url", "https://tagged.com")
+		form.Add("title", "Tagged")
+		form.Add("tags", "one, two three")
+
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+		var id int64
+		bookmarks, _ := server.db.ListBookmarks(0)
+		for _, b := range bookmarks {
+			if b.URL == "https://tagged.com" {
+				id = b.ID
+			}
+		}
+		tags, _ := server.db.ListBookmarkTags(id)
+		if strings.Join(tags, ",") != "one,three,two" {
+			t.Errorf("expected tags [one three two], got %v", tags)
+		}
+	})
+
+	t.Run("POST with quick-add line without URL returns bad request", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("q", "no url here #go")
+
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("POST with invalid URL returns bad request", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("url", "javascript:alert(1)")
+		form.Add("title", "Bad")
+
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("POST with invalid URL reports invalid_url", func(t *testing.T) {
+		form := url.Values{"url": {"javascript:alert(1)"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		var got errorView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		if w.Code != http.StatusBadRequest || got.Code != errorCodeInvalidURL || !strings.Contains(got.Message, "invalid URL") {
+			t.Errorf("expected a 400 invalid_url error, got %d %+v", w.Code, got)
+		}
+		if w.Header().Get("X-Error-Code") != errorCodeInvalidURL {
+			t.Errorf("expected X-Error-Code %q, got %q", errorCodeInvalidURL, w.Header().Get("X-Error-Code"))
+		}
+	})
+
 	t.Run("DELETE returns method not allowed", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodDelete, "/bookmarks", nil)
 		w := httptest.NewRecorder()
@@ -329,341 +650,3872 @@ func TestHandleArchive(t *testing.T) {
 		if w.Body.String() != htmlContent {
 			t.Errorf("expected raw HTML content, got %q", w.Body.String())
 		}
+		csp := w.Header().Get("Content-Security-Policy")
+		if !strings.Contains(csp, "sandbox allow-popups allow-popups-to-escape-sandbox allow-scripts") || strings.Contains(csp, "allow-same-origin") {
+			t.Errorf("expected a sandboxing Content-Security-Policy that allows scripts, got %q", csp)
+		}
 	})
 
-	t.Run("POST returns method not allowed", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/bookmarks/1/archive", nil)
-		w := httptest.NewRecorder()
+	t.Run("GET raw archive strips scripts when configured", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://scripted.com", "Scripted Site")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		htmlContent := `<html><body onload="steal()"><p>Text</p><script>steal()</script></body></html>`
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://scripted.com", htmlContent); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		server.stripScripts = true
+		t.Cleanup(func() { server.stripScripts = false })
 
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/raw", nil)
+		w := httptest.NewRecorder()
 		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if strings.Contains(body, "steal") || !strings.Contains(body, "<p>Text</p>") {
+			t.Errorf("expected scripts to be stripped, got %q", body)
+		}
+		if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'none'") || strings.Contains(csp, "allow-scripts") {
+			t.Errorf("expected scripts to be blocked, got %q", csp)
+		}
 
-		if w.Code != http.StatusMethodNotAllowed {
-			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if strings.Contains(w.Body.String(), "allow-scripts") {
+			t.Error("expected the viewer iframe to disallow scripts")
 		}
 	})
-}
 
-// TestHandleArchiveManager tests the archive manager page handler.
-func TestHandleArchiveManager(t *testing.T) {
-	server := newTestServer(t)
-	t.Cleanup(func() {
-		if err := server.db.Close(); err != nil {
-			t.Errorf("failed to close db: %v", err)
+	t.Run("GET reader view renders extracted article", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://reader.com", "Reader Site")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://reader.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		if err := server.db.SaveBookmarkReadable(db.BookmarkReadable{
+			BookmarkID: id,
+			Title:      "Readable Headline",
+			Byline:     "Jane Doe",
+			Content:    "<p>Readable body</p>",
+		}); err != nil {
+			t.Fatalf("failed to save readable: %v", err)
 		}
-	})
 
-	t.Run("GET returns archive manager page", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/archives", nil)
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/read", nil)
 		w := httptest.NewRecorder()
 
-		server.handleArchiveManager(w, req)
+		server.handleArchive(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
-			t.Errorf("expected Content-Type 'text/html; charset=utf-8', got %q", ct)
+		body := w.Body.String()
+		for _, want := range []string{"Readable Headline", "Jane Doe", "<p>Readable body</p>"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected response to contain %q", want)
+			}
 		}
 	})
 
-	t.Run("POST returns method not allowed", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/archives", nil)
-		w := httptest.NewRecorder()
+	t.Run("GET reader view without extraction returns not found", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://noreader.com", "No Reader")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
 
-		server.handleArchiveManager(w, req)
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/read", nil)
+		w := httptest.NewRecorder()
 
-		if w.Code != http.StatusMethodNotAllowed {
-			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
-		}
-	})
-}
+		server.handleArchive(w, req)
 
-// TestHandleArchivesList tests the archives list fragment handler.
-func TestHandleArchivesList(t *testing.T) {
-	server := newTestServer(t)
-	t.Cleanup(func() {
-		if err := server.db.Close(); err != nil {
-			t.Errorf("failed to close db: %v", err)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
 		}
 	})
 
-	t.Run("GET returns archives list", func(t *testing.T) {
-		if _, err := server.db.AddBookmark("https://example.com", "Example"); err != nil {
+	t.Run("GET archive with version selects snapshot", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://versioned.com", "Versioned Site")
+		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
+		first := time.Now().Add(-time.Hour)
+		second := time.Now()
+		if err := server.db.SaveArchiveResult(id, first, &first, "ok", "", "https://versioned.com", "<html>first</html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		if err := server.db.SaveArchiveResult(id, second, &second, "ok", "", "https://versioned.com", "<html>second</html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		versions, err := server.db.ListArchiveVersions(id)
+		if err != nil || len(versions) != 2 {
+			t.Fatalf("expected 2 versions, got %d (%v)", len(versions), err)
+		}
+		older := itoa(versions[1].ID)
 
-		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive?version="+older, nil)
 		w := httptest.NewRecorder()
-
-		server.handleArchivesList(w, req)
+		server.handleArchive(w, req)
 
 		if w.Code != http.StatusOK {
-			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
 		body := w.Body.String()
-		if !strings.Contains(body, "example.com") {
-			t.Error("expected response to contain bookmark URL")
+		if !strings.Contains(body, "archive/raw?version="+older) {
+			t.Error("expected iframe to point at the selected version")
 		}
-	})
-
-	t.Run("POST returns method not allowed", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/archives/list", nil)
-		w := httptest.NewRecorder()
-
-		server.handleArchivesList(w, req)
-
-		if w.Code != http.StatusMethodNotAllowed {
-			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		if !strings.Contains(body, `name="version"`) {
+			t.Error("expected version picker to be rendered")
 		}
-	})
-}
 
-// TestHandleArchivesRoutes tests the archives routing handler.
-func TestHandleArchivesRoutes(t *testing.T) {
-	server := newTestServer(t)
-	t.Cleanup(func() {
-		if err := server.db.Close(); err != nil {
-			t.Errorf("failed to close db: %v", err)
-		}
-	})
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/raw?version="+older, nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
 
-	t.Run("routes to list handler", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
-		w := httptest.NewRecorder()
+		if w.Body.String() != "<html>first</html>" {
+			t.Errorf("expected first version HTML, got %q", w.Body.String())
+		}
 
-		server.handleArchivesRoutes(w, req)
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive?version=99999", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
 
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for unknown version, got %d", http.StatusNotFound, w.Code)
 		}
 	})
 
-	t.Run("routes to status handler", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://example.com", "Example")
+	t.Run("GET archive/diff compares snapshots", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://terms.example.com", "Terms")
 		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
+		first := time.Now().Add(-time.Hour)
+		if err := server.db.SaveArchiveResult(id, first, &first, "ok", "", "https://terms.example.com", "<html><body><p>Intro</p><p>We keep data for 30 days.</p></body></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
 
-		req := httptest.NewRequest(http.MethodGet, "/archives/"+itoa(id)+"/status", nil)
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/diff", nil)
 		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d with one snapshot, got %d", http.StatusNotFound, w.Code)
+		}
 
-		server.handleArchivesRoutes(w, req)
-
-		if w.Code != http.StatusOK {
-			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		second := time.Now()
+		if err := server.db.SaveArchiveResult(id, second, &second, "ok", "", "https://terms.example.com", "<html><body><p>Intro</p><p>We keep data for 90 days.</p></body></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		versions, err := server.db.ListArchiveVersions(id)
+		if err != nil || len(versions) != 2 {
+			t.Fatalf("expected 2 versions, got %d (%v)", len(versions), err)
 		}
-	})
 
-	t.Run("status for non-existent bookmark returns not found", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/archives/99999/status", nil)
-		w := httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/diff", nil)
+		req.Header.Set("Accept", "application/json")
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var view archiveDiffView
+		if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+			t.Fatalf("failed to decode diff: %v", err)
+		}
+		// Without from and to, the latest snapshot is compared with the one before.
+		if view.From.ID != versions[1].ID || view.To.ID != versions[0].ID {
+			t.Errorf("expected diff from %d to %d, got %d to %d", versions[1].ID, versions[0].ID, view.From.ID, view.To.ID)
+		}
+		if view.Inserted != 1 || view.Deleted != 1 {
+			t.Errorf("expected 1 paragraph changed, got %d inserted and %d deleted", view.Inserted, view.Deleted)
+		}
 
-		server.handleArchivesRoutes(w, req)
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/diff?from="+itoa(versions[1].ID)+"&to="+itoa(versions[0].ID), nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, "<mark> 30</mark>") || !strings.Contains(body, "<mark> 90</mark>") {
+			t.Errorf("expected the changed words to be marked, got %s", body)
+		}
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if !strings.Contains(w.Body.String(), "/archive/diff?from=") {
+			t.Error("expected the viewer to link the changes since the previous snapshot")
 		}
-	})
 
-	t.Run("refetch requires POST", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://example.com", "Example")
-		if err != nil {
-			t.Fatalf("failed to add bookmark: %v", err)
+		for query, want := range map[string]int{
+			"?to=abc":                     http.StatusBadRequest,
+			"?from=99999":                 http.StatusNotFound,
+			"?to=" + itoa(versions[1].ID): http.StatusNotFound,
+		} {
+			req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/diff"+query, nil)
+			w = httptest.NewRecorder()
+			server.handleArchive(w, req)
+			if w.Code != want {
+				t.Errorf("%s: expected status %d, got %d", query, want, w.Code)
+			}
 		}
+	})
 
-		req := httptest.NewRequest(http.MethodGet, "/archives/"+itoa(id)+"/refetch", nil)
+	t.Run("GET refresh-metadata returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/1/refresh-metadata", nil)
 		w := httptest.NewRecorder()
 
-		server.handleArchivesRoutes(w, req)
+		server.handleArchive(w, req)
 
 		if w.Code != http.StatusMethodNotAllowed {
 			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
 		}
 	})
 
-	t.Run("invalid path returns not found", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/archives/invalid/path", nil)
+	t.Run("POST refresh-metadata for non-existent bookmark returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/99999/refresh-metadata", nil)
 		w := httptest.NewRecorder()
 
-		server.handleArchivesRoutes(w, req)
+		server.handleArchive(w, req)
 
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
 		}
 	})
 
-	t.Run("unknown route returns not found", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/archives/1/unknown", nil)
+	t.Run("POST refresh-metadata reports fetch failures", func(t *testing.T) {
+		// Internal addresses are blocked by the fetcher, so this fails without network access.
+		id, err := server.db.AddBookmark("http://127.0.0.1/page", "Local")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/"+itoa(id)+"/refresh-metadata", nil)
 		w := httptest.NewRecorder()
 
-		server.handleArchivesRoutes(w, req)
+		server.handleArchive(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("expected status %d, got %d", http.StatusBadGateway, w.Code)
 		}
 	})
-}
 
-// TestRefetchArchive tests the refetch archive handler.
-func TestRefetchArchive(t *testing.T) {
-	server := newTestServer(t)
-	t.Cleanup(func() {
-		if err := server.db.Close(); err != nil {
-			t.Errorf("failed to close db: %v", err)
+	t.Run("GET archive marks the bookmark read", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://read.com", "Read Site")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://read.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		unread := db.CleanupRule{Name: "unread", UnreadOnly: true, OlderThanDays: 1, Action: db.CleanupActionDelete}
+		isUnread := func() bool {
+			matches, err := server.db.ListCleanupRuleMatches(unread, now.AddDate(0, 0, 2))
+			if err != nil {
+				t.Fatalf("failed to list matches: %v", err)
+			}
+			for _, b := range matches {
+				if b.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+		if !isUnread() {
+			t.Fatal("expected new bookmark to be unread")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+
+		if isUnread() {
+			t.Error("expected viewing the archive to mark the bookmark read")
 		}
 	})
 
-	t.Run("POST clears archive and redirects", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://example.com", "Example")
+	t.Run("GET screenshot serves the image", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://screenshot.com", "Screenshot Site")
 		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
 		now := time.Now()
-		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://screenshot.com", "<html></html>"); err != nil {
 			t.Fatalf("failed to save archive result: %v", err)
 		}
 
-		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/refetch", nil)
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/screenshot", nil)
 		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without a screenshot, got %d", http.StatusNotFound, w.Code)
+		}
 
-		server.refetchArchive(w, req, id)
+		image := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}
+		if err := server.db.SaveArchiveScreenshot(id, image); err != nil {
+			t.Fatalf("failed to save screenshot: %v", err)
+		}
 
-		if w.Code != http.StatusSeeOther {
-			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/screenshot", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+			t.Errorf("expected Content-Type image/jpeg, got %q", ct)
+		}
+		if w.Body.String() != string(image) {
+			t.Error("expected screenshot bytes")
 		}
 
-		// Verify archive was cleared
-		archive, _ := server.db.GetBookmarkArchive(id)
-		if archive.ArchiveStatus != "" {
-			t.Error("expected archive to be cleared")
+		// The viewer links the screenshot.
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if !strings.Contains(w.Body.String(), "/archive/screenshot?version=") {
+			t.Error("expected viewer to link the screenshot")
 		}
 	})
 
-	t.Run("POST with HX-Request returns item fragment", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://htmx.com", "HTMX")
+	t.Run("GET download serves the file as an attachment", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://download.com/report.zip", "Download Site")
 		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
 		now := time.Now()
-		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://htmx.com", "<html></html>"); err != nil {
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://download.com/report.zip", "<html></html>"); err != nil {
 			t.Fatalf("failed to save archive result: %v", err)
 		}
 
-		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/refetch", nil)
-		req.Header.Set("HX-Request", "true")
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/download", nil)
 		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without a download, got %d", http.StatusNotFound, w.Code)
+		}
 
-		server.refetchArchive(w, req, id)
+		data := []byte("PK\x03\x04")
+		if err := server.db.SaveArchiveDownload(id, db.ArchiveDownload{Filename: "report.zip", MIMEType: "application/zip", Data: data}); err != nil {
+			t.Fatalf("failed to save download: %v", err)
+		}
 
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/download", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
 		if w.Code != http.StatusOK {
-			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		// Should return HTML fragment, not redirect
-		if w.Header().Get("Location") != "" {
-			t.Error("expected no redirect for HTMX request")
+		if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+			t.Errorf("expected Content-Type application/zip, got %q", ct)
 		}
-	})
-
-	t.Run("POST for non-existent bookmark returns not found", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPost, "/archives/99999/refetch", nil)
-		w := httptest.NewRecorder()
-
-		server.refetchArchive(w, req, 99999)
-
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=report.zip" {
+			t.Errorf("unexpected Content-Disposition %q", cd)
+		}
+		if w.Body.String() != string(data) {
+			t.Error("expected file bytes")
 		}
-	})
-}
 
-// TestBuildArchiveManagerView tests the view builder function.
-func TestBuildArchiveManagerView(t *testing.T) {
-	server := newTestServer(t)
-	t.Cleanup(func() {
-		if err := server.db.Close(); err != nil {
-			t.Errorf("failed to close db: %v", err)
+		// The viewer links the file.
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if !strings.Contains(w.Body.String(), "/archive/download?version=") {
+			t.Error("expected viewer to link the download")
 		}
 	})
 
-	t.Run("builds view for unarchived bookmark", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://example.com", "Example")
+	t.Run("GET provenance serves the record as JSON", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://provenance.com", "Provenance Site")
 		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
-		bookmark, err := server.db.GetBookmark(id)
-		if err != nil {
-			t.Fatalf("failed to get bookmark: %v", err)
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://provenance.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
 		}
 
-		view := server.buildArchiveManagerView(bookmark)
+		path := "/bookmarks/" + itoa(id) + "/archive/provenance"
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without provenance, got %d", http.StatusNotFound, w.Code)
+		}
 
-		if view.ID != id {
-			t.Errorf("expected ID %d, got %d", id, view.ID)
+		if err := server.db.SaveArchiveProvenance(id, `{"requested_url":"https://provenance.com","user_agent":"TestAgent/1.0","egress":"direct"}`); err != nil {
+			t.Fatalf("failed to save provenance: %v", err)
 		}
-		if view.URL != "https://example.com" {
-			t.Errorf("expected URL 'https://example.com', got %q", view.URL)
+
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		if view.Title != "Example" {
-			t.Errorf("expected Title 'Example', got %q", view.Title)
+		var got core.ArchiveProvenance
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
 		}
-		if !view.IsArchiving {
-			t.Error("expected IsArchiving to be true for unarchived bookmark")
+		if got.UserAgent != "TestAgent/1.0" || got.Egress != core.EgressDirect {
+			t.Errorf("unexpected provenance: %+v", got)
+		}
+
+		// The viewer links the provenance record.
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if !strings.Contains(w.Body.String(), "/archive/provenance?version=") {
+			t.Error("expected viewer to link the provenance record")
 		}
 	})
 
-	t.Run("builds view for archived bookmark", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://archived.com", "Archived")
+	t.Run("GET timestamp serves the token as JSON", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://timestamp.com", "Timestamp Site")
 		if err != nil {
 			t.Fatalf("failed to add bookmark: %v", err)
 		}
 		now := time.Now()
-		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://archived.com", "<html></html>"); err != nil {
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://timestamp.com", "<html></html>"); err != nil {
 			t.Fatalf("failed to save archive result: %v", err)
 		}
-		bookmark, err := server.db.GetBookmark(id)
-		if err != nil {
-			t.Fatalf("failed to get bookmark: %v", err)
+
+		path := "/bookmarks/" + itoa(id) + "/archive/timestamp"
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without a timestamp, got %d", http.StatusNotFound, w.Code)
 		}
 
-		view := server.buildArchiveManagerView(bookmark)
+		sum := sha256.Sum256([]byte("<html></html>"))
+		hash := hex.EncodeToString(sum[:])
+		versionID, err := server.db.ArchiveVersionByContentHash(id, hash)
+		if err != nil {
+			t.Fatalf("failed to find version: %v", err)
+		}
+		if err := server.db.SaveArchiveTimestamp(db.ArchiveTimestamp{
+			BookmarkID:    id,
+			VersionID:     versionID,
+			ContentHash:   hash,
+			Authority:     "https://tsa.example.com",
+			TimestampedAt: "2025-01-02T03:04:05Z",
+			Token:         []byte{1, 2, 3},
+		}); err != nil {
+			t.Fatalf("failed to save timestamp: %v", err)
+		}
 
-		if view.ArchiveStatus != "ok" {
-			t.Errorf("expected ArchiveStatus 'ok', got %q", view.ArchiveStatus)
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		if view.IsArchiving {
-			t.Error("expected IsArchiving to be false for archived bookmark")
+		var got struct {
+			ContentHash   string `json:"content_hash"`
+			HashAlgorithm string `json:"hash_algorithm"`
+			Authority     string `json:"authority"`
+			Token         []byte `json:"token"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if got.ContentHash != hash || got.HashAlgorithm != "sha256" || got.Authority != "https://tsa.example.com" || string(got.Token) != "\x01\x02\x03" {
+			t.Errorf("unexpected timestamp: %+v", got)
+		}
+
+		// The viewer links the timestamp.
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if !strings.Contains(w.Body.String(), "/archive/timestamp?version=") {
+			t.Error("expected viewer to link the timestamp")
 		}
 	})
 
-	t.Run("builds view for failed archive", func(t *testing.T) {
-		id, err := server.db.AddBookmark("https://failed.com", "Failed")
-		if err != nil {
-			t.Fatalf("failed to add bookmark: %v", err)
+	t.Run("GET screenshot with invalid version returns bad request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/1/archive/screenshot?version=abc", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
 		}
-		now := time.Now()
-		if err := server.db.SaveArchiveResult(id, now, nil, "error", "connection timeout", "", ""); err != nil {
-			t.Fatalf("failed to save archive result: %v", err)
+	})
+
+	t.Run("POST returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/1/archive", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
 		}
-		bookmark, err := server.db.GetBookmark(id)
-		if err != nil {
-			t.Fatalf("failed to get bookmark: %v", err)
+	})
+}
+
+// TestHandleArchiveManager tests the archive manager page handler.
+func TestHandleArchiveManager(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
 		}
+	})
+
+	t.Run("GET returns archive manager page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives", nil)
+		w := httptest.NewRecorder()
 
-		view := server.buildArchiveManagerView(bookmark)
+		server.handleArchiveManager(w, req)
 
-		if view.ArchiveStatus != "error" {
-			t.Errorf("expected ArchiveStatus 'error', got %q", view.ArchiveStatus)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
-		if view.ArchiveError != "connection timeout" {
-			t.Errorf("expected ArchiveError 'connection timeout', got %q", view.ArchiveError)
+		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("expected Content-Type 'text/html; charset=utf-8', got %q", ct)
 		}
-		if view.IsArchiving {
-			t.Error("expected IsArchiving to be false for failed archive")
+	})
+
+	t.Run("POST returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/archives", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchiveManager(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
 		}
 	})
 }
 
-// itoa converts an int64 to string for URL building.
-func itoa(n int64) string {
-	return strconv.FormatInt(n, 10)
+// TestHandleArchivesList tests the archives list fragment handler.
+// TestServeFavicon tests serving stored favicons and using them in lists.
+func TestServeFavicon(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.AddBookmark("https://icon.com", "Icon")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	faviconPath := "/bookmarks/" + itoa(id) + "/favicon"
+
+	t.Run("GET without stored favicon returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, faviconPath, nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	if err := server.db.SaveBookmarkFavicon(db.BookmarkFavicon{
+		BookmarkID:  id,
+		SourceURL:   "https://icon.com/favicon.svg",
+		ContentType: "image/svg+xml",
+		Data:        []byte("<svg></svg>"),
+	}); err != nil {
+		t.Fatalf("failed to save favicon: %v", err)
+	}
+
+	t.Run("GET serves stored favicon", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, faviconPath, nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/svg+xml" {
+			t.Errorf("expected image/svg+xml, got %q", ct)
+		}
+		if !strings.Contains(w.Header().Get("Content-Security-Policy"), "sandbox") {
+			t.Error("expected a sandboxing Content-Security-Policy")
+		}
+		if w.Body.String() != "<svg></svg>" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("lists prefer the stored favicon", func(t *testing.T) {
+		if err := server.db.SaveBookmarkMetadata(db.BookmarkMetadata{
+			BookmarkID: id,
+			FaviconURL: "https://icon.com/favicon.svg",
+		}); err != nil {
+			t.Fatalf("failed to save metadata: %v", err)
+		}
+		for _, path := range []string{"/bookmarks", "/archives/list"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			if path == "/bookmarks" {
+				server.handleBookmarks(w, req)
+			} else {
+				server.handleArchivesList(w, req)
+			}
+
+			body := w.Body.String()
+			if !strings.Contains(body, `src="`+faviconPath+`"`) {
+				t.Errorf("expected %s to use the stored favicon", path)
+			}
+			if strings.Contains(body, `src="https://icon.com/favicon.svg"`) {
+				t.Errorf("expected %s not to hot-link the live favicon", path)
+			}
+		}
+	})
+}
+
+// TestCreateBookmarkArchiveETA tests that JSON clients creating a bookmark
+// learn where its archive stands in the queue.
+func TestCreateBookmarkArchiveETA(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	create := func(t *testing.T, rawURL string) archiveETAView {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(url.Values{"url": {rawURL}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var created bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if created.Archive == nil {
+			t.Fatalf("expected archive info, got %s", w.Body.String())
+		}
+		return *created.Archive
+	}
+
+	t.Run("not queued", func(t *testing.T) {
+		if eta := create(t, "https://unqueued.example"); eta.Status != "none" {
+			t.Errorf("expected no archive pending, got %+v", eta)
+		}
+	})
+
+	queue := core.NewArchiveQueue(server.db, core.ArchiveQueueOptions{MaxQueued: 1})
+	server.db.RegisterEventListener(db.OnBookmarkCreatedEvent, func(event db.Event) error {
+		return queue.EnqueueNew(event.(db.BookmarkCreatedEvent).Bookmark.ID, db.ArchiveOverrides{})
+	})
+
+	t.Run("queued", func(t *testing.T) {
+		eta := create(t, "https://first.example")
+		if eta.Status != db.JobStatusQueued || eta.Ahead != 0 || eta.EstimatedWaitSeconds != core.DefaultArchiveTimeout.Seconds() {
+			t.Errorf("expected to be next, got %+v", eta)
+		}
+	})
+
+	t.Run("deferred when the queue is full", func(t *testing.T) {
+		eta := create(t, "https://second.example")
+		if eta.Status != db.JobStatusDeferred || eta.Ahead != 1 || eta.EstimatedWaitSeconds != 2*core.DefaultArchiveTimeout.Seconds() {
+			t.Errorf("expected to be deferred behind the first, got %+v", eta)
+		}
+	})
+
+	t.Run("stats report the backlog", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/stats", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleArchivesStats(w, req)
+		var stats archiveStatsView
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if stats.QueueDepth != 1 || stats.Deferred != 1 || stats.EstimatedWaitSeconds != core.DefaultArchiveTimeout.Seconds() {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+}
+
+// TestBookmarkNotesAPI tests notes through the JSON API and the list UI.
+func TestBookmarkNotesAPI(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	var created bookmarkView
+	t.Run("POST JSON creates a bookmark with notes", func(t *testing.T) {
+		form := url.Values{"url": {"https://notes.com"}, "title": {"Notes"}, "tags": {"go"}, "notes": {"Worth **rereading**"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if created.ID == 0 || created.URL != "https://notes.com" || created.Notes != "Worth **rereading**" || len(created.Tags) != 1 {
+			t.Errorf("unexpected bookmark: %+v", created)
+		}
+	})
+
+	t.Run("GET JSON lists bookmarks", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		var list []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(list) != 1 || list[0].Notes != "Worth **rereading**" {
+			t.Errorf("unexpected list: %+v", list)
+		}
+	})
+
+	t.Run("list renders notes as Markdown", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		if !strings.Contains(w.Body.String(), "Worth <strong>rereading</strong>") {
+			t.Error("expected rendered notes in the list")
+		}
+	})
+
+	t.Run("POST notes updates them", func(t *testing.T) {
+		form := url.Values{"notes": {"<b>new</b>"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/"+itoa(created.ID)+"/notes", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if strings.Contains(body, "<b>new</b>") || !strings.Contains(body, "&lt;b&gt;new&lt;/b&gt;") {
+			t.Error("expected notes to be escaped in the list")
+		}
+		if notes, _ := server.db.GetBookmarkNotes(created.ID); notes != "<b>new</b>" {
+			t.Errorf("expected notes to be saved, got %q", notes)
+		}
+	})
+
+	t.Run("POST notes for missing bookmark returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/99999/notes", strings.NewReader("notes=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleArchive(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestBookmarkFlagsAPI(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	readID, _ := server.db.AddBookmark("https://read.com", "Read Me")
+	favID, _ := server.db.AddBookmark("https://fav.com", "Favorite")
+
+	post := func(path string, form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		return w
+	}
+	list := func(filter string) []bookmarkView {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks?filter="+filter, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var views []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		return views
+	}
+
+	t.Run("POST mark-read returns the bookmark as JSON", func(t *testing.T) {
+		w := post("/bookmarks/"+itoa(readID)+"/mark-read", nil, map[string]string{"Accept": "application/json"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var got bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || !got.IsRead {
+			t.Errorf("expected bookmark to be read, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("POST favorite toggles and returns the filtered list to HTMX", func(t *testing.T) {
+		w := post("/bookmarks/"+itoa(favID)+"/favorite", url.Values{"filter": {"favorites"}}, map[string]string{"HX-Request": "true"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "https://fav.com") || strings.Contains(body, "https://read.com") {
+			t.Errorf("expected only the favorite in the list, got %s", body)
+		}
+		if !strings.Contains(body, "favorite-btn active") {
+			t.Error("expected the favorite button to be active")
+		}
+	})
+
+	t.Run("GET filters", func(t *testing.T) {
+		if got := list("unread"); len(got) != 1 || got[0].ID != favID {
+			t.Errorf("expected only the unread bookmark, got %+v", got)
+		}
+		if got := list("favorites"); len(got) != 1 || got[0].ID != favID || !got[0].IsFavorite {
+			t.Errorf("expected only the favorite, got %+v", got)
+		}
+		if got := list(""); len(got) != 2 {
+			t.Errorf("expected both bookmarks, got %+v", got)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks?filter=bogus", nil)
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an unknown filter, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("POST mark-read with read=false puts it back in the queue", func(t *testing.T) {
+		w := post("/bookmarks/"+itoa(readID)+"/mark-read", url.Values{"read": {"false"}}, nil)
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+		if got := list("unread"); len(got) != 2 {
+			t.Errorf("expected both bookmarks unread, got %+v", got)
+		}
+	})
+
+	t.Run("missing bookmark and wrong method", func(t *testing.T) {
+		if w := post("/bookmarks/999/favorite", nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(readID)+"/mark-read", nil)
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+func TestHandleArchivesList(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("GET returns archives list", func(t *testing.T) {
+		if _, err := server.db.AddBookmark("https://example.com", "Example"); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesList(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "example.com") {
+			t.Error("expected response to contain bookmark URL")
+		}
+	})
+
+	t.Run("shows why a bookmark was skipped", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://noarchive.example", "No Archive")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := server.db.SaveArchiveResult(id, time.Now(), nil, core.ArchiveStatusSkipped, "robots.txt disallows /", "", ""); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesList(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "status-skipped") || !strings.Contains(body, "Skipped: robots.txt disallows /") {
+			t.Error("expected the skipped bookmark to show its reason")
+		}
+		b, err := server.db.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+		if server.buildArchiveManagerView(server.db, b).IsArchiving {
+			t.Error("expected a skipped bookmark not to show as archiving")
+		}
+	})
+
+	t.Run("POST returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/archives/list", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesList(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+// TestHandleArchivesStats tests the archive dashboard stats endpoint.
+func TestHandleArchivesStats(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.AddBookmark("https://example.com/running", "Running Page")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := server.db.AddBookmark("https://example.com/pending", "Pending Page"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := server.db.EnqueueJob(db.JobKindArchive, id, ""); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+	if _, err := server.db.ClaimJob(db.JobKindArchive, time.Now()); err != nil {
+		t.Fatalf("failed to claim job: %v", err)
+	}
+
+	t.Run("GET returns dashboard fragment", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/stats", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Queue depth") {
+			t.Error("expected response to contain queue depth")
+		}
+		if !strings.Contains(body, "Running Page") {
+			t.Error("expected response to show the running job")
+		}
+	})
+
+	t.Run("JSON when requested", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/stats", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected Content-Type application/json, got %q", ct)
+		}
+		var got archiveStatsView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if got.Pending != 1 || got.InProgress != 1 {
+			t.Errorf("unexpected stats: %+v", got)
+		}
+		if len(got.Active) != 1 || got.Active[0].BookmarkID != id {
+			t.Errorf("unexpected active jobs: %+v", got.Active)
+		}
+	})
+
+	t.Run("POST returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/archives/stats", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+// TestNewArchiveStatsView tests formatting of durations for the dashboard.
+func TestNewArchiveStatsView(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	view := newArchiveStatsView(db.ArchiveStats{
+		AvgDurationSeconds: 4.26,
+		Active: []db.ActiveJob{{
+			BookmarkID: 1,
+			StartedAt:  now.Add(-90 * time.Second).Format(time.RFC3339),
+		}},
+	}, 1, now)
+
+	if view.AvgDuration != "4.2s" {
+		t.Errorf("expected AvgDuration 4.2s, got %q", view.AvgDuration)
+	}
+	if len(view.Active) != 1 || view.Active[0].Elapsed != "1m30s" {
+		t.Errorf("unexpected active jobs: %+v", view.Active)
+	}
+
+	empty := newArchiveStatsView(db.ArchiveStats{}, 1, now)
+	if empty.AvgDuration != "" || empty.Active == nil {
+		t.Errorf("expected no average and an empty (non-nil) active list, got %+v", empty)
+	}
+}
+
+// TestHandleArchivesStorage tests the storage card and its forecast.
+func TestHandleArchivesStorage(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	now := time.Now().UTC()
+	for i, used := range []int64{1 << 30, 2 << 30, 3 << 30} {
+		if _, err := server.db.SaveStorageSample(db.StorageSample{
+			TakenAt:       now.Add(time.Duration(i-2) * 7 * 24 * time.Hour).Format(time.RFC3339),
+			Source:        "test",
+			DatabaseBytes: used,
+			FreeBytes:     6 << 30,
+		}); err != nil {
+			t.Fatalf("failed to save storage sample: %v", err)
+		}
+	}
+
+	t.Run("GET returns the chart and forecast", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/storage", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "<polyline") {
+			t.Error("expected response to contain the chart")
+		}
+		if !strings.Contains(body, "Disk full in ~6 weeks") {
+			t.Errorf("expected a six week forecast, got %s", body)
+		}
+	})
+
+	t.Run("JSON when requested", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/storage", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		var got storageView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Samples) != 3 || got.UsedBytes != 3<<30 || got.FreeBytes != 6<<30 {
+			t.Errorf("unexpected storage view: %+v", got)
+		}
+	})
+
+	t.Run("forbidden for non-admins", func(t *testing.T) {
+		alice, err := server.db.CreateUser("alice", "", false)
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/archives/storage", nil)
+		req = req.WithContext(withUser(req.Context(), alice.ID))
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+// TestNewStorageView tests daily bucketing and the chart of the storage card.
+func TestNewStorageView(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return day.Add(d).Format(time.RFC3339) }
+	view := newStorageView([]db.StorageSample{
+		{TakenAt: at(time.Hour), DatabaseBytes: 100, FreeBytes: -1},
+		{TakenAt: at(2 * time.Hour), DatabaseBytes: 200, FreeBytes: -1},
+		{TakenAt: at(48 * time.Hour), DatabaseBytes: 300, FreeBytes: -1},
+	})
+
+	if len(view.Samples) != 2 || view.Samples[0].DatabaseBytes != 200 {
+		t.Errorf("expected the last sample of each day, got %+v", view.Samples)
+	}
+	if view.Free != "" {
+		t.Errorf("expected no free space label, got %q", view.Free)
+	}
+	if view.Chart.Line == "" || view.Chart.Projection == "" {
+		t.Errorf("expected a line and a projection, got %+v", view.Chart)
+	}
+
+	empty := newStorageView(nil)
+	if empty.Chart.Line != "" || empty.Samples == nil {
+		t.Errorf("expected no chart and an empty (non-nil) sample list, got %+v", empty)
+	}
+}
+
+// TestHandleArchivesRoutes tests the archives routing handler.
+func TestHandleArchivesRoutes(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("routes to list handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/list", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("routes to status handler", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/archives/"+itoa(id)+"/status", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("rearchive toggles the opt-out", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://rearchive.com", "Rearchive")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://rearchive.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		form := url.Values{"enabled": {"false"}}
+		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/rearchive", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "Auto-refresh off") {
+			t.Error("expected item to show re-archiving as off")
+		}
+		a, err := server.db.GetBookmarkArchiveStatus(id)
+		if err != nil {
+			t.Fatalf("failed to get archive status: %v", err)
+		}
+		if !a.RearchiveDisabled {
+			t.Error("expected bookmark to be opted out")
+		}
+	})
+
+	t.Run("rearchive rejects invalid values", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/archives/1/rearchive?enabled=maybe", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("wayback submits the page and the viewer falls back to it", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://gone.example.com/", "Gone")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/wayback", nil)
+		w := httptest.NewRecorder()
+		server.handleArchivesRoutes(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without a wayback client, got %d", http.StatusNotFound, w.Code)
+		}
+
+		wayback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Location", "/web/20261016120000/"+strings.TrimPrefix(r.URL.Path, "/save/"))
+		}))
+		t.Cleanup(wayback.Close)
+		server.wayback = core.NewWaybackClient(wayback.URL+"/available", wayback.URL+"/save/")
+		t.Cleanup(func() { server.wayback = nil })
+
+		req = httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/wayback", nil)
+		req.Header.Set("Accept", "application/json")
+		w = httptest.NewRecorder()
+		server.handleArchivesRoutes(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var got waybackView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		want := wayback.URL + "/web/20261016120000/https://gone.example.com/"
+		if got.WaybackURL != want {
+			t.Errorf("expected wayback URL %q, got %q", want, got.WaybackURL)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive", nil)
+		w = httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("expected a redirect to the snapshot, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("archive-today submits the page unless the bookmark opts out", func(t *testing.T) {
+		form := url.Values{"url": {"https://offsite.example.com/"}, "skip_archive_today": {"true"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		if w.Code >= 400 {
+			t.Fatalf("failed to add bookmark: %d %s", w.Code, w.Body.String())
+		}
+		bookmarks, err := server.db.SearchBookmarks("offsite", db.BookmarkFilter{}, 1)
+		if err != nil || len(bookmarks) != 1 {
+			t.Fatalf("failed to find bookmark: %v", err)
+		}
+		id := bookmarks[0].ID
+
+		archiveToday := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Refresh", "0;url=/wip/AbCd1")
+		}))
+		t.Cleanup(archiveToday.Close)
+		submit := func(form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/archive-today", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			server.handleArchivesRoutes(w, req)
+			return w
+		}
+		if w := submit(nil); w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without an archive.today client, got %d", http.StatusNotFound, w.Code)
+		}
+		server.archiveToday = core.NewArchiveTodayClient(archiveToday.URL)
+		t.Cleanup(func() { server.archiveToday = nil })
+
+		if w := submit(nil); w.Code != http.StatusConflict {
+			t.Errorf("expected status %d for an opted-out bookmark, got %d", http.StatusConflict, w.Code)
+		}
+		if w := submit(url.Values{"enabled": {"true"}}); w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		w = submit(nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var got archiveTodayView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if want := archiveToday.URL + "/AbCd1"; got.ArchiveTodayURL != want || got.ArchiveTodayDisabled {
+			t.Errorf("expected snapshot %q, got %+v", want, got)
+		}
+	})
+
+	t.Run("status for non-existent bookmark returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/99999/status", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("refetch requires POST", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/archives/"+itoa(id)+"/refetch", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+
+	t.Run("invalid path returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/invalid/path", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("unknown route returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/archives/1/unknown", nil)
+		w := httptest.NewRecorder()
+
+		server.handleArchivesRoutes(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+// TestRefetchArchive tests the refetch archive handler.
+func TestRefetchArchive(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("POST clears archive and redirects", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/refetch", nil)
+		w := httptest.NewRecorder()
+
+		server.refetchArchive(w, req, id)
+
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+
+		// Verify archive was cleared
+		archive, _ := server.db.GetBookmarkArchive(id)
+		if archive.ArchiveStatus != "" {
+			t.Error("expected archive to be cleared")
+		}
+	})
+
+	t.Run("POST with HX-Request returns item fragment", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://htmx.com", "HTMX")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://htmx.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/archives/"+itoa(id)+"/refetch", nil)
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		server.refetchArchive(w, req, id)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		// Should return HTML fragment, not redirect
+		if w.Header().Get("Location") != "" {
+			t.Error("expected no redirect for HTMX request")
+		}
+	})
+
+	t.Run("POST for non-existent bookmark returns not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/archives/99999/refetch", nil)
+		w := httptest.NewRecorder()
+
+		server.refetchArchive(w, req, 99999)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+// TestBuildArchiveManagerView tests the view builder function.
+func TestBuildArchiveManagerView(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("builds view for unarchived bookmark", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://example.com", "Example")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		bookmark, err := server.db.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+
+		view := server.buildArchiveManagerView(server.db, bookmark)
+
+		if view.ID != id {
+			t.Errorf("expected ID %d, got %d", id, view.ID)
+		}
+		if view.URL != "https://example.com" {
+			t.Errorf("expected URL 'https://example.com', got %q", view.URL)
+		}
+		if view.Title != "Example" {
+			t.Errorf("expected Title 'Example', got %q", view.Title)
+		}
+		if !view.IsArchiving {
+			t.Error("expected IsArchiving to be true for unarchived bookmark")
+		}
+	})
+
+	t.Run("builds view for archived bookmark", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://archived.com", "Archived")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://archived.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		bookmark, err := server.db.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+
+		view := server.buildArchiveManagerView(server.db, bookmark)
+
+		if view.ArchiveStatus != "ok" {
+			t.Errorf("expected ArchiveStatus 'ok', got %q", view.ArchiveStatus)
+		}
+		if view.IsArchiving {
+			t.Error("expected IsArchiving to be false for archived bookmark")
+		}
+	})
+
+	t.Run("builds view for failed archive", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://failed.com", "Failed")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(id, now, nil, "error", "connection timeout", "", ""); err != nil {
+			t.Fatalf("failed to save archive result: %v", err)
+		}
+		bookmark, err := server.db.GetBookmark(id)
+		if err != nil {
+			t.Fatalf("failed to get bookmark: %v", err)
+		}
+
+		view := server.buildArchiveManagerView(server.db, bookmark)
+
+		if view.ArchiveStatus != "error" {
+			t.Errorf("expected ArchiveStatus 'error', got %q", view.ArchiveStatus)
+		}
+		if view.ArchiveError != "connection timeout" {
+			t.Errorf("expected ArchiveError 'connection timeout', got %q", view.ArchiveError)
+		}
+		if view.IsArchiving {
+			t.Error("expected IsArchiving to be false for failed archive")
+		}
+	})
+
+	t.Run("follows archives made with a fake archiver", func(t *testing.T) {
+		id, err := server.db.AddBookmark("https://flaky.example.com", "Flaky")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		fake := &core.FakeArchiver{Errors: map[string]error{
+			"https://flaky.example.com": &core.HTTPStatusError{StatusCode: 503, URL: "https://flaky.example.com"},
+		}}
+		run := func() archiveManagerView {
+			t.Helper()
+			_, _ = core.RunArchive(context.Background(), server.db, core.ArchiveRunOptions{ID: id, Options: core.ArchiveOptions{Archiver: fake}})
+			bookmark, err := server.db.GetBookmark(id)
+			if err != nil {
+				t.Fatalf("failed to get bookmark: %v", err)
+			}
+			return server.buildArchiveManagerView(server.db, bookmark)
+		}
+
+		if view := run(); view.ArchiveStatus != core.ArchiveStatusError || view.ArchiveErrorCode != core.ArchiveErrorHTTP5xx || view.IsArchiving {
+			t.Errorf("expected an http-5xx failure, got %+v", view)
+		}
+		delete(fake.Errors, "https://flaky.example.com")
+		if view := run(); view.ArchiveStatus != core.ArchiveStatusOK || view.ArchiveErrorCode != "" || view.ArchivedAt == "" {
+			t.Errorf("expected the page to be archived, got %+v", view)
+		}
+	})
+}
+
+// itoa converts an int64 to string for URL building.
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+// TestHandleSettings tests the archive settings page.
+func TestHandleSettings(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("GET renders server defaults", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		w := httptest.NewRecorder()
+
+		server.handleSettings(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, `name="screenshot"`) {
+			t.Error("expected screenshot setting")
+		}
+		if strings.Contains(body, `value="on" selected`) || strings.Contains(body, `value="off" selected`) {
+			t.Error("expected every setting to use the server default")
+		}
+	})
+
+	t.Run("POST saves preferences", func(t *testing.T) {
+		form := url.Values{"auto_archive": {"off"}, "screenshot": {"on"}, "strip_scripts": {""}}
+		req := httptest.NewRequest(http.MethodPost, "/settings", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleSettings(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "Saved.") {
+			t.Error("expected confirmation")
+		}
+		p, err := server.db.GetArchivePreferences(db.LocalUserID)
+		if err != nil {
+			t.Fatalf("failed to get preferences: %v", err)
+		}
+		if p.AutoArchive == nil || *p.AutoArchive {
+			t.Errorf("expected auto_archive off, got %v", p.AutoArchive)
+		}
+		if p.Screenshot == nil || !*p.Screenshot {
+			t.Errorf("expected screenshot on, got %v", p.Screenshot)
+		}
+		if p.StripScripts != nil || p.MobileViewport != nil {
+			t.Errorf("expected other settings unset, got %+v", p)
+		}
+	})
+
+	t.Run("POST rejects invalid values", func(t *testing.T) {
+		form := url.Values{"screenshot": {"maybe"}}
+		req := httptest.NewRequest(http.MethodPost, "/settings", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		server.handleSettings(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("DELETE returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/settings", nil)
+		w := httptest.NewRecorder()
+
+		server.handleSettings(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+// TestHandleRoutingRules tests managing routing rules from the settings page
+// and as JSON.
+func TestAccountData(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.AddBookmark("https://example.com", "Example")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("settings page offers export and deletion", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		w := httptest.NewRecorder()
+		server.handleSettings(w, req)
+		body := w.Body.String()
+		if !strings.Contains(body, `action="/settings/account/export"`) || !strings.Contains(body, `action="/settings/account/delete"`) {
+			t.Error("expected account export and delete forms")
+		}
+	})
+
+	t.Run("GET export downloads JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings/account/export", nil)
+		w := httptest.NewRecorder()
+		server.handleAccountExport(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+			t.Errorf("expected attachment, got %q", w.Header().Get("Content-Disposition"))
+		}
+		var got core.UserDataExport
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(got.Bookmarks) != 1 || got.Bookmarks[0].ID != id {
+			t.Errorf("expected the bookmark in the export, got %+v", got.Bookmarks)
+		}
+	})
+
+	t.Run("GET export takes a search query", func(t *testing.T) {
+		other, err := server.db.AddBookmark("https://other.test/page", "Other")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		t.Cleanup(func() { _ = server.db.DeleteBookmark(other) })
+
+		req := httptest.NewRequest(http.MethodGet, "/settings/account/export?q=domain:other.test", nil)
+		w := httptest.NewRecorder()
+		server.handleAccountExport(w, req)
+		var got core.UserDataExport
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if got.Query != "domain:other.test" || len(got.Bookmarks) != 1 || got.Bookmarks[0].ID != other {
+			t.Errorf("expected only the matching bookmark, got %+v", got.Bookmarks)
+		}
+
+		w = httptest.NewRecorder()
+		server.handleAccountExport(w, httptest.NewRequest(http.MethodGet, "/settings/account/export?q=before:someday", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for an invalid query, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("POST delete requires confirmation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/settings/account/delete", strings.NewReader("confirm=yes"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.handleAccountDelete(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if _, err := server.db.GetBookmark(id); err != nil {
+			t.Errorf("expected bookmark to survive, got %v", err)
+		}
+	})
+
+	t.Run("POST delete purges everything", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/settings/account/delete", strings.NewReader("confirm=DELETE"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleAccountDelete(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"deleted_bookmarks":1`) {
+			t.Errorf("unexpected body: %s", w.Body.String())
+		}
+		if bookmarks, _ := server.db.ListBookmarks(0); len(bookmarks) != 0 {
+			t.Errorf("expected no bookmarks left, got %d", len(bookmarks))
+		}
+	})
+
+	t.Run("wrong methods are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleAccountExport(w, httptest.NewRequest(http.MethodPost, "/settings/account/export", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected export status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+		w = httptest.NewRecorder()
+		server.handleAccountDelete(w, httptest.NewRequest(http.MethodGet, "/settings/account/delete", nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected delete status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+func TestHandleRoutingRules(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	postForm := func(handler http.HandlerFunc, path string, form url.Values, asJSON bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if asJSON {
+			req.Header.Set("Accept", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	var id int64
+	t.Run("POST creates a rule", func(t *testing.T) {
+		form := url.Values{"name": {"gh"}, "domain": {"github.com"}, "add_tag": {"code"}, "collection": {"Dev"}}
+		w := postForm(server.handleRoutingRules, "/settings/routing", form, true)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var got routingRuleView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if got.Name != "gh" || got.Domain != "github.com" || got.AddTag != "code" || !got.Enabled {
+			t.Errorf("unexpected rule: %+v", got)
+		}
+		id = got.ID
+	})
+
+	t.Run("browser POST redirects to settings", func(t *testing.T) {
+		form := url.Values{"name": {"video"}, "domain": {"youtube.com"}, "skip_archive": {"true"}}
+		w := postForm(server.handleRoutingRules, "/settings/routing", form, false)
+
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/settings" {
+			t.Errorf("expected redirect to /settings, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("POST rejects invalid rules", func(t *testing.T) {
+		w := postForm(server.handleRoutingRules, "/settings/routing", url.Values{"name": {"empty"}, "add_tag": {"x"}}, false)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		w = postForm(server.handleRoutingRules, "/settings/routing", url.Values{"name": {"x"}, "domain": {"a.com"}, "skip_archive": {"maybe"}}, false)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("GET lists rules as JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings/routing", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handleRoutingRules(w, req)
+
+		var got []routingRuleView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got) != 2 || got[1].Name != "video" || !got[1].SkipArchive {
+			t.Errorf("unexpected rules: %+v", got)
+		}
+	})
+
+	t.Run("settings page lists rules", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		w := httptest.NewRecorder()
+
+		server.handleSettings(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "on github.com") || !strings.Contains(body, "#code") {
+			t.Error("expected the github rule to be listed")
+		}
+		if !strings.Contains(body, `action="/settings/routing/`+strconv.FormatInt(id, 10)+`/disable"`) {
+			t.Error("expected a disable button")
+		}
+	})
+
+	t.Run("new bookmarks are routed", func(t *testing.T) {
+		form := url.Values{"url": {"https://github.com/seckatie/bookmarkd"}, "title": {"bookmarkd"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+
+		server.handleBookmarks(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "#code") || !strings.Contains(body, ">Dev<") {
+			t.Errorf("expected routed tag and collection in list, got %s", body)
+		}
+	})
+
+	t.Run("disable and enable", func(t *testing.T) {
+		path := "/settings/routing/" + strconv.FormatInt(id, 10)
+		w := postForm(server.handleRoutingRule, path+"/disable", nil, true)
+		var got routingRuleView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if got.Enabled {
+			t.Error("expected rule disabled")
+		}
+
+		w = postForm(server.handleRoutingRule, path+"/enable", nil, false)
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+		if r, _ := server.db.GetRoutingRule(id); !r.Enabled {
+			t.Error("expected rule enabled")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		path := "/settings/routing/" + strconv.FormatInt(id, 10) + "/delete"
+		w := postForm(server.handleRoutingRule, path, nil, true)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		w = postForm(server.handleRoutingRule, path, nil, true)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("bad paths", func(t *testing.T) {
+		for path, want := range map[string]int{
+			"/settings/routing/abc/enable":   http.StatusBadRequest,
+			"/settings/routing/1":            http.StatusNotFound,
+			"/settings/routing/1/frobnicate": http.StatusNotFound,
+		} {
+			w := postForm(server.handleRoutingRule, path, nil, false)
+			if w.Code != want {
+				t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+			}
+		}
+		req := httptest.NewRequest(http.MethodGet, "/settings/routing/1/enable", nil)
+		w := httptest.NewRecorder()
+		server.handleRoutingRule(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+// TestHandlePresets tests managing presets and saving bookmarks with one.
+func TestHandlePresets(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	postForm := func(handler http.HandlerFunc, path string, form url.Values, asJSON bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if asJSON {
+			req.Header.Set("Accept", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	var preset presetView
+	t.Run("POST creates a preset", func(t *testing.T) {
+		form := url.Values{"name": {"Recipe"}, "tags": {"recipe, food"}, "collection": {"Kitchen"}, "screenshot": {"off"}}
+		w := postForm(server.handlePresets, "/settings/presets", form, true)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &preset); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if preset.Name != "Recipe" || len(preset.Tags) != 2 || preset.Collection != "Kitchen" ||
+			preset.Screenshot == nil || *preset.Screenshot || preset.StripScripts != nil {
+			t.Errorf("unexpected preset: %+v", preset)
+		}
+	})
+
+	t.Run("POST rejects invalid presets", func(t *testing.T) {
+		for _, form := range []url.Values{
+			{"name": {"Empty"}},
+			{"name": {"Recipe"}, "tags": {"again"}},
+			{"name": {"Bad"}, "screenshot": {"maybe"}},
+			{"name": {"Bad"}, "skip_archive": {"maybe"}},
+		} {
+			w := postForm(server.handlePresets, "/settings/presets", form, false)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%v: expected status %d, got %d", form, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("GET lists presets as JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/settings/presets", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		server.handlePresets(w, req)
+
+		var got []presetView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got) != 1 || got[0].ID != preset.ID {
+			t.Errorf("unexpected presets: %+v", got)
+		}
+	})
+
+	t.Run("pages offer the preset", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleSettings(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if body := w.Body.String(); !strings.Contains(body, "#recipe") || !strings.Contains(body, "no screenshot") {
+			t.Error("expected the preset to be listed on the settings page")
+		}
+		option := fmt.Sprintf(`<option value="%d">Recipe</option>`, preset.ID)
+		w = httptest.NewRecorder()
+		server.handleIndex(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !strings.Contains(w.Body.String(), option) {
+			t.Error("expected the preset in the add form")
+		}
+		w = httptest.NewRecorder()
+		server.handleBookmarklet(w, httptest.NewRequest(http.MethodGet, "/bookmarklet", nil))
+		if !strings.Contains(w.Body.String(), option) {
+			t.Error("expected the preset on the bookmarklet page")
+		}
+	})
+
+	t.Run("new bookmarks get the preset", func(t *testing.T) {
+		form := url.Values{"url": {"https://example.com/soup"}, "title": {"Soup"}, "tags": {"dinner"}, "preset": {strconv.FormatInt(preset.ID, 10)}}
+		w := postForm(server.handleBookmarks, "/bookmarks", form, true)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var got bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if strings.Join(got.Tags, ",") != "dinner,food,recipe" || got.Collection != "Kitchen" {
+			t.Errorf("expected the preset's tags and collection, got %+v", got)
+		}
+
+		for _, v := range []string{"abc", "999"} {
+			form := url.Values{"url": {"https://example.com/" + v}, "preset": {v}}
+			if w := postForm(server.handleBookmarks, "/bookmarks", form, true); w.Code != http.StatusBadRequest {
+				t.Errorf("preset %s: expected status %d, got %d", v, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("bookmarklets carry the preset", func(t *testing.T) {
+		form := url.Values{"name": {"Kitchen tablet"}, "preset": {strconv.FormatInt(preset.ID, 10)}}
+		w := postForm(server.handleBookmarkletGenerate, "/bookmarklet/generate", form, true)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var generated generatedBookmarkletView
+		if err := json.Unmarshal(w.Body.Bytes(), &generated); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if generated.Preset != "Recipe" || !strings.Contains(string(generated.Bookmarklet), fmt.Sprintf("'&preset=%d'", preset.ID)) {
+			t.Errorf("expected the preset in the bookmarklet, got %+v", generated)
+		}
+
+		w = httptest.NewRecorder()
+		server.handleBookmarkletAdd(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/bookmarklet/add?url=https://example.com&preset=%d", preset.ID), nil))
+		if !strings.Contains(w.Body.String(), fmt.Sprintf(`name="preset" value="%d"`, preset.ID)) {
+			t.Error("expected the add page to submit the preset")
+		}
+		w = httptest.NewRecorder()
+		server.handleBookmarkletAdd(w, httptest.NewRequest(http.MethodGet, "/bookmarklet/add?url=https://example.com&preset=999", nil))
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `name="preset"`) {
+			t.Errorf("expected an unknown preset to be dropped, got %d", w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		path := "/settings/presets/" + strconv.FormatInt(preset.ID, 10) + "/delete"
+		w := postForm(server.handlePreset, path, nil, true)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		w = postForm(server.handlePreset, path, nil, true)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+		for path, want := range map[string]int{
+			"/settings/presets/abc/delete": http.StatusBadRequest,
+			"/settings/presets/1":          http.StatusNotFound,
+		} {
+			if w := postForm(server.handlePreset, path, nil, false); w.Code != want {
+				t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+			}
+		}
+	})
+}
+
+// TestHandleBookmarksBulk tests adding a pasted list of URLs.
+func TestHandleBookmarksBulk(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	post := func(form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/bulk", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarksBulk(w, req)
+		return w
+	}
+
+	t.Run("HX-Request returns a summary and triggers a reload", func(t *testing.T) {
+		w := post(url.Values{"urls": {"https://a.com\nhttps://b.com\nhttps://a.com\nnope"}}, map[string]string{"HX-Request": "true"})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get("HX-Trigger") != "bookmarks-changed" {
+			t.Error("expected bookmarks-changed trigger")
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Added 2 bookmarks.") {
+			t.Errorf("expected added count, got %s", body)
+		}
+		if !strings.Contains(body, "Listed more than once (1)") || !strings.Contains(body, "line 4: nope") {
+			t.Errorf("expected skipped lines, got %s", body)
+		}
+	})
+
+	t.Run("JSON result", func(t *testing.T) {
+		w := post(url.Values{"urls": {"https://a.com\nhttps://c.com"}, "tags": {"later"}}, map[string]string{"Accept": "application/json"})
+
+		var got struct {
+			Added    []int64  `json:"added"`
+			Existing []string `json:"existing"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Added) != 1 || len(got.Existing) != 1 || got.Existing[0] != "https://a.com" {
+			t.Errorf("unexpected result: %+v", got)
+		}
+		if tags, _ := server.db.ListBookmarkTags(got.Added[0]); len(tags) != 1 || tags[0] != "later" {
+			t.Errorf("expected tag later, got %v", tags)
+		}
+	})
+
+	t.Run("browser POST redirects", func(t *testing.T) {
+		w := post(url.Values{"urls": {"https://d.com"}}, nil)
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+	})
+
+	t.Run("GET returns method not allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/bulk", nil)
+		w := httptest.NewRecorder()
+		server.handleBookmarksBulk(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+func TestHandleBookmarksBulkAction(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	a, _ := server.db.AddBookmark("https://a.com", "A")
+	b, _ := server.db.AddBookmark("https://b.com", "B")
+	c, _ := server.db.AddBookmark("https://c.com", "C")
+
+	post := func(action string, form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/bulk/"+action, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarksBulkAction(w, req)
+		return w
+	}
+	jsonHeaders := map[string]string{"Accept": "application/json"}
+
+	t.Run("tag adds tags and reports unknown IDs", func(t *testing.T) {
+		w := post("tag", url.Values{"ids": {itoa(a) + "," + itoa(b), "999"}, "tags": {"later, go"}}, jsonHeaders)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var res bulkActionResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if res.Action != "tag" || len(res.Affected) != 2 || len(res.NotFound) != 1 || res.NotFound[0] != 999 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if tags, _ := server.db.ListBookmarkTags(b); len(tags) != 2 {
+			t.Errorf("expected 2 tags on bookmark %d, got %v", b, tags)
+		}
+	})
+
+	t.Run("tag requires tags", func(t *testing.T) {
+		if w := post("tag", url.Values{"ids": {itoa(a)}}, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("rearchive clears archive status", func(t *testing.T) {
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(c, now, &now, "ok", "", "https://c.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+		w := post("rearchive", url.Values{"ids": {itoa(c)}}, jsonHeaders)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if archive, _ := server.db.GetBookmarkArchiveStatus(c); archive.ArchiveStatus != "" {
+			t.Errorf("expected archive to be cleared, got %q", archive.ArchiveStatus)
+		}
+	})
+
+	t.Run("delete returns the list fragment to HTMX", func(t *testing.T) {
+		w := post("delete", url.Values{"ids": {itoa(a), itoa(c)}}, map[string]string{"HX-Request": "true"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		if strings.Contains(body, "https://a.com") || !strings.Contains(body, "https://b.com") {
+			t.Errorf("expected only b.com left in the list, got %s", body)
+		}
+		if !strings.Contains(body, `name="ids" value="`+itoa(b)+`"`) {
+			t.Error("expected a selection checkbox for the remaining bookmark")
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			action string
+			form   url.Values
+			want   int
+		}{
+			{"unknown action", "archive", url.Values{"ids": {itoa(b)}}, http.StatusNotFound},
+			{"no IDs", "delete", url.Values{}, http.StatusBadRequest},
+			{"bad ID", "delete", url.Values{"ids": {"abc"}}, http.StatusBadRequest},
+			{"negative ID", "delete", url.Values{"ids": {"-1"}}, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			if w := post(tt.action, tt.form, nil); w.Code != tt.want {
+				t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/bulk/delete", nil)
+		w := httptest.NewRecorder()
+		server.handleBookmarksBulkAction(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+		if _, err := server.db.GetBookmark(b); err != nil {
+			t.Errorf("expected bookmark %d to survive, got %v", b, err)
+		}
+	})
+}
+
+// TestBookmarkDomainView tests the bookmark list grouped by domain and the
+// bulk actions on a domain.
+func TestBookmarkDomainView(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	a, _ := server.db.AddBookmark("https://go.dev/doc", "Docs")
+	b, _ := server.db.AddBookmark("https://www.go.dev/blog", "Blog")
+	c, _ := server.db.AddBookmark("https://sqlite.org/", "SQLite")
+
+	get := func(query string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks?"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		return w
+	}
+
+	t.Run("JSON lists domains with counts", func(t *testing.T) {
+		w := get("view=domains", map[string]string{"Accept": "application/json"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var groups []domainGroupView
+		if err := json.Unmarshal(w.Body.Bytes(), &groups); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(groups) != 2 || groups[0].Domain != "go.dev" || groups[0].Count != 2 || groups[1].Domain != "sqlite.org" {
+			t.Errorf("unexpected groups: %+v", groups)
+		}
+	})
+
+	t.Run("HTMX gets collapsible sections", func(t *testing.T) {
+		body := get("view=domains", map[string]string{"HX-Request": "true"}).Body.String()
+		if !strings.Contains(body, `class="domain-group"`) || !strings.Contains(body, "/bookmarks?domain=go.dev") {
+			t.Errorf("expected a section per domain, got %s", body)
+		}
+		if strings.Contains(body, "https://go.dev/doc") {
+			t.Error("expected bookmarks to load when a section is opened")
+		}
+	})
+
+	t.Run("domain lists one domain's bookmarks", func(t *testing.T) {
+		w := get("domain=www.go.dev", map[string]string{"Accept": "application/json"})
+		var views []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(views) != 2 || !slices.ContainsFunc(views, func(v bookmarkView) bool { return v.ID == a }) || !slices.ContainsFunc(views, func(v bookmarkView) bool { return v.ID == b }) {
+			t.Errorf("expected go.dev's bookmarks, got %+v", views)
+		}
+	})
+
+	t.Run("searches are listed flat", func(t *testing.T) {
+		body := get("view=domains&search=sqlite", map[string]string{"HX-Request": "true"}).Body.String()
+		if strings.Contains(body, "domain-group") || !strings.Contains(body, "https://sqlite.org/") {
+			t.Errorf("expected the search results, got %s", body)
+		}
+	})
+
+	t.Run("invalid view", func(t *testing.T) {
+		if w := get("view=cards", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("bulk actions take a domain", func(t *testing.T) {
+		form := url.Values{"domain": {"go.dev"}, "tags": {"golang"}, "view": {"domains"}}
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/bulk/tag", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+		server.handleBookmarksBulkAction(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		for _, id := range []int64{a, b} {
+			if tags, _ := server.db.ListBookmarkTags(id); !slices.Contains(tags, "golang") {
+				t.Errorf("expected bookmark %d to be tagged, got %v", id, tags)
+			}
+		}
+		if tags, _ := server.db.ListBookmarkTags(c); len(tags) != 0 {
+			t.Errorf("expected sqlite.org to be left alone, got %v", tags)
+		}
+		if !strings.Contains(w.Body.String(), "sqlite.org") {
+			t.Error("expected every domain in the re-rendered list")
+		}
+	})
+}
+
+// TestBookmarkListSortAndFilter tests the bookmark list's sort, order, tag,
+// archive and read filters.
+func TestBookmarkListSortAndFilter(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	b, _ := server.db.AddBookmark("https://b.example.com/", "banana")
+	a, _ := server.db.AddBookmark("https://a.example.com/", "Apple")
+	if err := server.db.AddBookmarkTags(a, []string{"fruit"}); err != nil {
+		t.Fatalf("failed to tag bookmark: %v", err)
+	}
+	if err := server.db.MarkRead(b, true); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	now := time.Now()
+	if err := server.db.SaveArchiveResult(b, now, &now, core.ArchiveStatusOK, "", "https://b.example.com/", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	get := func(query string, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks?"+query, nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		} else {
+			req.Header.Set("Accept", "application/json")
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		return w
+	}
+	ids := func(query string) []int64 {
+		t.Helper()
+		w := get(query, false)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", query, http.StatusOK, w.Code, w.Body.String())
+		}
+		var views []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		var out []int64
+		for _, v := range views {
+			out = append(out, v.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{a, b}},
+		{"order=asc", []int64{b, a}},
+		{"sort=title", []int64{a, b}},
+		{"sort=title&order=desc", []int64{b, a}},
+		{"sort=domain", []int64{a, b}},
+		{"filter=read", []int64{b}},
+		{"tag=%23Fruit", []int64{a}},
+		{"archive=ok", []int64{b}},
+		{"archive=none", []int64{a}},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"sort=size", "order=up", "archive=maybe"} {
+			if w := get(query, false); w.Code != http.StatusBadRequest {
+				t.Errorf("%q: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("tags link to the list filtered by them", func(t *testing.T) {
+		body := get("sort=title", true).Body.String()
+		if !strings.Contains(body, `href="/?sort=title&amp;tag=fruit"`) {
+			t.Errorf("expected a tag link keeping the sort, got %s", body)
+		}
+	})
+}
+
+func TestBookmarkSourceRecorded(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	mux := http.NewServeMux()
+	server.registerRoutes(mux)
+	handler := server.limitAPITokens(server.requireLogin(server.protectCSRF(mux)))
+	_, token, err := server.db.CreateAPIToken("CI bot", 0)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	add := func(form, bearer string) bookmarkView {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+			handler.ServeHTTP(w, req)
+		} else {
+			server.handleBookmarks(w, req)
+		}
+		var view bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.ID == 0 {
+			t.Fatalf("expected the new bookmark, got %d: %s", w.Code, w.Body.String())
+		}
+		return view
+	}
+	if v := add("url=https://a.example.com/", ""); v.Source != db.SourceWeb {
+		t.Errorf("expected the web form's source, got %q", v.Source)
+	}
+	if v := add("url=https://b.example.com/&via=bookmarklet", ""); v.Source != db.SourceBookmarklet {
+		t.Errorf("expected the bookmarklet's source, got %q", v.Source)
+	}
+	api := add("url=https://c.example.com/&via=bookmarklet", token)
+	if api.Source != "api:CI bot" {
+		t.Errorf("expected the API token's source, got %q", api.Source)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/bookmarks?source=api&facets=1", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	server.handleBookmarks(w, req)
+	var list bookmarkListView
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+	}
+	if len(list.Bookmarks) != 1 || list.Bookmarks[0].ID != api.ID {
+		t.Errorf("expected only the API token's bookmark, got %+v", list.Bookmarks)
+	}
+	if want := []facetCount{{Value: "api:CI bot", Count: 1}}; !slices.Equal(list.Facets.Sources, want) {
+		t.Errorf("expected source facets %v, got %v", want, list.Facets.Sources)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleIndex(w, httptest.NewRequest(http.MethodGet, "/?source=api", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `<option value="api" selected>api</option>`) || !strings.Contains(body, `<option value="web">web (1)</option>`) {
+		t.Errorf("expected the source menu with the filter selected, got %s", body)
+	}
+	if !strings.Contains(body, `href="/?source=api%3ACI&#43;bot"`) {
+		t.Errorf("expected the bookmark's source to link to its bookmarks, got %s", body)
+	}
+}
+
+func TestHandleRandomBookmark(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	get := func(query string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/random?"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		server.handleRandomBookmark(w, req)
+		return w
+	}
+
+	if w := get("", "application/json"); w.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 without bookmarks, got %d", w.Code)
+	}
+
+	plain, _ := server.db.CreateBookmark(db.NewBookmark{URL: "https://a.example.com/", Tags: []string{"later"}})
+	archived, _ := server.db.AddBookmark("https://b.example.com/", "")
+	now := time.Now()
+	if err := server.db.SaveArchiveResult(archived, now, &now, core.ArchiveStatusOK, "", "https://b.example.com/", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	w := get("tag=later", "application/json")
+	var view bookmarkView
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.ID != plain {
+		t.Errorf("expected the tagged bookmark, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := get("tag=later", ""); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "https://a.example.com/" {
+		t.Errorf("expected a redirect to the page, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("archive=ok", ""); w.Header().Get("Location") != fmt.Sprintf("/bookmarks/%d/archive", archived) {
+		t.Errorf("expected a redirect to the archive, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("filter=read", "application/json"); w.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 when nothing matches, got %d", w.Code)
+	}
+	if w := get("archive=maybe", "application/json"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 for an invalid filter, got %d", w.Code)
+	}
+}
+
+// TestHandleBookmarkGraph tests the bookmark graph endpoint.
+func TestHandleBookmarkGraph(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	for _, u := range []string{"https://a.com/one", "https://a.com/two"} {
+		if _, err := server.db.AddBookmark(u, ""); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+	}
+	alice, err := server.db.CreateUser("alice", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := server.db.ForUser(alice.ID).AddBookmark("https://a.com/three", ""); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	t.Run("GET returns the user's graph", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/graph", nil)
+		req = req.WithContext(withUser(req.Context(), db.LocalUserID))
+		w := httptest.NewRecorder()
+
+		server.handleBookmarkGraph(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var got core.BookmarkGraph
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Nodes) != 3 || len(got.Edges) != 2 {
+			t.Fatalf("expected 2 bookmarks joined by their domain, got %+v", got)
+		}
+		if got.Nodes[2].ID != "domain:a.com" || got.Nodes[2].Count != 2 {
+			t.Errorf("unexpected domain node %+v", got.Nodes[2])
+		}
+	})
+
+	t.Run("rejects POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/bookmarks/graph", nil)
+		w := httptest.NewRecorder()
+		server.handleBookmarkGraph(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+// TestBacklinksAPI tests the outbound link and backlink endpoints.
+func TestBacklinksAPI(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	target, _ := server.db.AddBookmark("https://www.example.com/post/", "Post")
+	source, _ := server.db.AddBookmark("https://blog.example.org/", "Blog")
+	if err := server.db.SaveBookmarkLinks(source, core.ExtractLinks(`<a href="https://example.com/post">post</a> <a href="https://docs.example.com/">docs</a>`, "https://blog.example.org/")); err != nil {
+		t.Fatalf("failed to save links: %v", err)
+	}
+
+	get := func(path string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	t.Run("GET bookmark links", func(t *testing.T) {
+		w := get(fmt.Sprintf("/bookmarks/%d/links", target), server.handleArchive)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var got bookmarkLinksView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Outbound) != 0 || len(got.Backlinks) != 1 || got.Backlinks[0].ID != source || got.Backlinks[0].Link != "https://example.com/post" {
+			t.Errorf("unexpected links view %+v", got)
+		}
+
+		w = get(fmt.Sprintf("/bookmarks/%d/links", source), server.handleArchive)
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Outbound) != 2 || len(got.Backlinks) != 0 {
+			t.Errorf("unexpected links view %+v", got)
+		}
+	})
+
+	t.Run("GET backlinks by URL or domain", func(t *testing.T) {
+		for path, want := range map[string]int{
+			"/bookmarks/backlinks?url=http://example.com/post/": 1,
+			"/bookmarks/backlinks?domain=example.com":           2,
+			"/bookmarks/backlinks?domain=example.net":           0,
+		} {
+			w := get(path, server.handleBacklinks)
+			var got []backlinkView
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("%s: failed to decode JSON: %v", path, err)
+			}
+			if len(got) != want {
+				t.Errorf("%s: expected %d backlinks, got %+v", path, want, got)
+			}
+		}
+	})
+
+	t.Run("rejects bad targets", func(t *testing.T) {
+		for _, path := range []string{"/bookmarks/backlinks", "/bookmarks/backlinks?url=example.com", "/bookmarks/backlinks?domain=https://example.com/", "/bookmarks/backlinks?url=ftp://example.com/"} {
+			if w := get(path, server.handleBacklinks); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", path, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("unknown bookmark", func(t *testing.T) {
+		if w := get("/bookmarks/9999/links", server.handleArchive); w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+// TestHandleLauncher tests the launcher search endpoint.
+func TestHandleLauncher(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	archived, _ := server.db.AddBookmark("https://go.dev/doc/", "Go documentation")
+	now := time.Now()
+	if err := server.db.SaveArchiveResult(archived, now, &now, "ok", "", "https://go.dev/doc/", "<html></html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	untitled, _ := server.db.AddBookmark("https://go.dev/blog/", "")
+	if _, err := server.db.AddBookmark("https://rust-lang.org/", "Rust"); err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	get := func(path string) (*httptest.ResponseRecorder, launcherView) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		server.handleLauncher(w, req)
+		var got launcherView
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode JSON: %v", err)
+			}
+		}
+		return w, got
+	}
+
+	t.Run("returns matches with deep links", func(t *testing.T) {
+		_, got := get("/api/v1/launcher?q=go")
+		if len(got.Items) != 2 {
+			t.Fatalf("expected 2 matches, got %+v", got.Items)
+		}
+		for _, item := range got.Items {
+			switch item.UID {
+			case fmt.Sprint(archived):
+				want := fmt.Sprintf("http://example.com/bookmarks/%d/archive", archived)
+				if item.Arg != "https://go.dev/doc/" || item.ArchiveURL != want || item.Mods["cmd"].Arg != want {
+					t.Errorf("unexpected archived item %+v", item)
+				}
+			case fmt.Sprint(untitled):
+				if item.Title != "https://go.dev/blog/" || item.ArchiveURL != "" || item.Mods != nil {
+					t.Errorf("unexpected unarchived item %+v", item)
+				}
+			default:
+				t.Errorf("unexpected item %+v", item)
+			}
+		}
+	})
+
+	t.Run("lists the newest without a query", func(t *testing.T) {
+		if _, got := get("/api/v1/launcher?limit=2"); len(got.Items) != 2 {
+			t.Errorf("expected 2 items, got %+v", got.Items)
+		}
+		if _, got := get("/api/v1/launcher?q=nothing-matches"); got.Items == nil || len(got.Items) != 0 {
+			t.Errorf("expected an empty item list, got %+v", got.Items)
+		}
+	})
+
+	t.Run("rejects bad parameters", func(t *testing.T) {
+		for _, path := range []string{"/api/v1/launcher?limit=0", "/api/v1/launcher?limit=x", "/api/v1/launcher?q=before:soon"} {
+			if w, _ := get(path); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", path, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+}
+
+// TestServeBookmarkQR tests the per-bookmark QR code PNG.
+func TestServeBookmarkQR(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.AddBookmark("https://qr.example/page", "QR")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		return w
+	}
+
+	for _, link := range []string{"", "?link=original", "?link=archive"} {
+		w := get("/bookmarks/" + itoa(id) + "/qr" + link)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status %d, got %d", link, http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+			t.Errorf("%q: expected image/png, got %q", link, ct)
+		}
+		img, err := png.Decode(w.Body)
+		if err != nil {
+			t.Fatalf("%q: failed to decode PNG: %v", link, err)
+		}
+		if b := img.Bounds(); b.Dx() == 0 || b.Dx() != b.Dy() {
+			t.Errorf("%q: expected a square image, got %v", link, b)
+		}
+	}
+
+	if w := get("/bookmarks/" + itoa(id) + "/qr?link=other"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown link, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := get("/bookmarks/99999/qr"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing bookmark, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestBookmarkSlugs tests share links by slug, renaming slugs and saving
+// archives under them.
+func TestBookmarkSlugs(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	id, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://sqlite.example", Title: "How SQLite Works", CreatedAt: time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	other, err := server.db.AddBookmark("https://other.example", "Taken name")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if _, err := server.db.SetBookmarkSlug(other, "taken-name"); err != nil {
+		t.Fatalf("failed to set slug: %v", err)
+	}
+	now := time.Now()
+	if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://sqlite.example", "<html><body>B-trees</body></html>"); err != nil {
+		t.Fatalf("failed to save archive result: %v", err)
+	}
+
+	serve := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		return w
+	}
+
+	t.Run("viewer by slug", func(t *testing.T) {
+		w := serve(http.MethodGet, "/bookmarks/2024-06-01-how-sqlite-works/archive", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "/bookmarks/2024-06-01-how-sqlite-works/archive") {
+			t.Error("expected the viewer to show the share link")
+		}
+	})
+
+	t.Run("save HTML", func(t *testing.T) {
+		w := serve(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/raw?download=1", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=2024-06-01-how-sqlite-works.html` {
+			t.Errorf("unexpected Content-Disposition %q", cd)
+		}
+		if cd := serve(http.MethodGet, "/bookmarks/"+itoa(id)+"/archive/raw", nil).Header().Get("Content-Disposition"); cd != "" {
+			t.Errorf("expected no attachment without download=1, got %q", cd)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		w := serve(http.MethodPost, "/bookmarks/"+itoa(id)+"/slug", url.Values{"slug": {"SQLite Internals"}})
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/bookmarks/sqlite-internals/archive" {
+			t.Fatalf("expected a redirect to the new link, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		if w := serve(http.MethodGet, "/bookmarks/sqlite-internals/archive", nil); w.Code != http.StatusOK {
+			t.Errorf("expected the new slug to work, got %d", w.Code)
+		}
+		if w := serve(http.MethodPost, "/bookmarks/"+itoa(id)+"/slug", url.Values{"slug": {"taken-name"}}); w.Code != http.StatusConflict {
+			t.Errorf("expected status %d for a taken slug, got %d", http.StatusConflict, w.Code)
+		}
+		if w := serve(http.MethodPost, "/bookmarks/"+itoa(id)+"/slug", url.Values{"slug": {"42"}}); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for a numeric slug, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := serve(http.MethodPost, "/bookmarks/99999/slug", url.Values{"slug": {"x"}}); w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for a missing bookmark, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+// TestListBookmarksSearch tests the bookmark list's search parameter.
+func TestListBookmarksSearch(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	titled, _ := server.db.CreateBookmark(db.NewBookmark{URL: "https://a.com", Title: "Sourdough starter"})
+	noted, _ := server.db.CreateBookmark(db.NewBookmark{URL: "https://b.com", Title: "Flour", Notes: "for sourdough"})
+	if _, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://c.com", Title: "Unrelated"}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	get := func(query string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks?"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarks(w, req)
+		return w
+	}
+
+	t.Run("GET search returns matches best first as JSON", func(t *testing.T) {
+		w := get("search=sourdough", map[string]string{"Accept": "application/json"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var views []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(views) != 2 || views[0].ID != titled || views[1].ID != noted {
+			t.Errorf("expected the title match then the note match, got %+v", views)
+		}
+	})
+
+	t.Run("GET search explains scores on request", func(t *testing.T) {
+		w := get("search=sourdough", map[string]string{"Accept": "application/json"})
+		if strings.Contains(w.Body.String(), `"score"`) {
+			t.Errorf("expected no scores without explain, got %s", w.Body.String())
+		}
+		w = get("search=sourdough&explain=1", map[string]string{"Accept": "application/json"})
+		var views []bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(views) != 2 || views[0].Score == nil || views[1].Score == nil {
+			t.Fatalf("expected scores, got %+v", views)
+		}
+		if views[0].Score.Total <= views[1].Score.Total || views[0].Score.Text <= 0 {
+			t.Errorf("unexpected scores: %+v, %+v", views[0].Score, views[1].Score)
+		}
+	})
+
+	t.Run("GET search counts facets on request", func(t *testing.T) {
+		if err := server.db.SetBookmarkTags(titled, []string{"baking", "bread"}); err != nil {
+			t.Fatalf("failed to tag bookmark: %v", err)
+		}
+		if err := server.db.SetBookmarkTags(noted, []string{"baking"}); err != nil {
+			t.Fatalf("failed to tag bookmark: %v", err)
+		}
+		now := time.Now()
+		if err := server.db.SaveArchiveResult(titled, now, &now, "ok", "", "https://a.com", "<html></html>"); err != nil {
+			t.Fatalf("failed to save archive: %v", err)
+		}
+
+		w := get("search=sourdough&facets=1", map[string]string{"Accept": "application/json"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var list bookmarkListView
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		if len(list.Bookmarks) != 2 {
+			t.Fatalf("expected 2 bookmarks, got %+v", list.Bookmarks)
+		}
+		year := list.Bookmarks[0].CreatedAt[:4]
+		want := facetsView{
+			Tags:     []facetCount{{"baking", 2}, {"bread", 1}},
+			Domains:  []facetCount{{"a.com", 1}, {"b.com", 1}},
+			Years:    []facetCount{{year, 2}},
+			Statuses: []facetCount{{"none", 1}, {"ok", 1}},
+		}
+		if fmt.Sprint(list.Facets) != fmt.Sprint(want) {
+			t.Errorf("expected facets %+v, got %+v", want, list.Facets)
+		}
+	})
+
+	t.Run("GET search with a field prefix", func(t *testing.T) {
+		w := get("search="+url.QueryEscape("note:sourdough"), map[string]string{"HX-Request": "true"})
+		body := w.Body.String()
+		if !strings.Contains(body, "https://b.com") || strings.Contains(body, "https://a.com") {
+			t.Errorf("expected only the note match, got %s", body)
+		}
+	})
+
+	t.Run("GET search without matches", func(t *testing.T) {
+		w := get("search=rye", map[string]string{"HX-Request": "true"})
+		if !strings.Contains(w.Body.String(), "No bookmarks match your search.") {
+			t.Errorf("expected the no matches message, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("GET search rejects invalid queries", func(t *testing.T) {
+		if w := get("search="+url.QueryEscape("before:soon"), nil); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestHandleImport(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	post := func(format, export string, headers map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if err := mw.WriteField("format", format); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+		if err := mw.WriteField("tags", "imported"); err != nil {
+			t.Fatalf("failed to write field: %v", err)
+		}
+		fw, err := mw.CreateFormFile("file", "export")
+		if err != nil {
+			t.Fatalf("failed to create file field: %v", err)
+		}
+		if _, err := fw.Write([]byte(export)); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatalf("failed to close form: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleImport(w, req)
+		return w
+	}
+
+	t.Run("GET lists the formats", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/import", nil)
+		w := httptest.NewRecorder()
+		server.handleImport(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		for _, want := range []string{`value="linkding"`, `value="linkwarden"`, `value="pocket"`} {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("expected %s in page", want)
+			}
+		}
+	})
+
+	t.Run("POST imports and summarises", func(t *testing.T) {
+		w := post("pocket", "title,url,time_added,tags,status\nGo,https://go.dev,1700000000,go,archive\nBad,nope,,,unread\n", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Added 1 bookmark from Pocket.") || !strings.Contains(body, "entry 2: nope") {
+			t.Errorf("expected summary, got %s", body)
+		}
+		bookmarks, err := server.db.ListBookmarks(0)
+		if err != nil || len(bookmarks) != 1 {
+			t.Fatalf("expected 1 bookmark, got %d, %v", len(bookmarks), err)
+		}
+		if tags, _ := server.db.ListBookmarkTags(bookmarks[0].ID); len(tags) != 2 {
+			t.Errorf("expected export and form tags, got %v", tags)
+		}
+	})
+
+	t.Run("JSON result", func(t *testing.T) {
+		w := post("pocket", "title,url\nGo,https://go.dev\n", map[string]string{"Accept": "application/json"})
+		var got core.ImportResult
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if got.Source != "Pocket" || len(got.Existing) != 1 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("rejects unknown formats and bad exports", func(t *testing.T) {
+		if w := post("delicious", "{}", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := post("linkding", "not json", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestHandleActivity(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	server.db.EnableActivityLog()
+
+	id, err := server.db.AddBookmark("https://a.com", "Alpha")
+	if err != nil {
+		t.Fatalf("failed to add bookmark: %v", err)
+	}
+	if err := server.db.SaveArchiveResult(id, time.Now(), nil, "error", "connection refused", "", ""); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+
+	get := func(query string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/activity?"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		server.handleActivity(w, req)
+		return w
+	}
+
+	t.Run("GET renders the stream", func(t *testing.T) {
+		w := get("", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		body := w.Body.String()
+		for _, want := range []string{"Bookmark added", "Archive failed", "connection refused", "https://a.com"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected %q in page", want)
+			}
+		}
+	})
+
+	t.Run("GET filters by kind as JSON", func(t *testing.T) {
+		w := get("kind=archive_failed", map[string]string{"Accept": "application/json"})
+		var got struct {
+			Entries    []activityView `json:"entries"`
+			NextBefore int64          `json:"next_before"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		if len(got.Entries) != 1 || got.Entries[0].Kind != db.ActivityArchiveFailed || got.Entries[0].BookmarkID != id || got.NextBefore != 0 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"kind=nope", "before=x"} {
+			if w := get(query, nil); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+}
+
+// TestNewFacetsView tests how bookmark facets are counted and ordered.
+func TestNewFacetsView(t *testing.T) {
+	facets := newFacetsView([]bookmarkView{
+		{URL: "https://www.Example.com/a", CreatedAt: "2023-05-01T00:00:00Z", Tags: []string{"go"}},
+		{URL: "https://example.com/b", CreatedAt: "2025-01-01T00:00:00Z", Tags: []string{"go", "db"}, ArchiveStatus: "ok"},
+		{URL: "https://other.org", CreatedAt: "2024-02-01T00:00:00Z", Tags: []string{}, ArchiveStatus: "error"},
+	})
+	want := facetsView{
+		Tags:     []facetCount{{"go", 2}, {"db", 1}},
+		Domains:  []facetCount{{"example.com", 2}, {"other.org", 1}},
+		Years:    []facetCount{{"2025", 1}, {"2024", 1}, {"2023", 1}},
+		Statuses: []facetCount{{"error", 1}, {"none", 1}, {"ok", 1}},
+	}
+	if fmt.Sprint(facets) != fmt.Sprint(want) {
+		t.Errorf("expected %+v, got %+v", want, facets)
+	}
+}
+
+func TestHandleNotifications(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+
+	t.Run("settings page shows every event checked", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleSettings(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		body := w.Body.String()
+		if !strings.Contains(body, `name="bookmark_created.webhook" aria-label="webhook" checked`) {
+			t.Errorf("expected a checked webhook box for new bookmarks, got %s", body)
+		}
+	})
+
+	t.Run("POST turns off unchecked channels", func(t *testing.T) {
+		form := url.Values{}
+		for _, kind := range db.EventKinds {
+			for _, channel := range db.NotificationChannels {
+				if !(kind == db.OnBookmarkDeletedEvent && channel == db.NotifyWebhook) {
+					form.Set(kind.String()+"."+channel, "on")
+				}
+			}
+		}
+		req := httptest.NewRequest(http.MethodPost, "/settings/notifications", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleNotifications(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var views []notificationView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		off := 0
+		for _, v := range views {
+			for _, c := range v.Channels {
+				if !c.Enabled {
+					off++
+					if v.Event != "bookmark_deleted" || c.Channel != db.NotifyWebhook {
+						t.Errorf("expected only deletion webhooks off, got %s %s", v.Event, c.Channel)
+					}
+				}
+			}
+		}
+		if off != 1 {
+			t.Errorf("expected one channel off, got %d", off)
+		}
+		prefs, err := server.db.ListNotificationPreferences(db.LocalUserID)
+		if err != nil {
+			t.Fatalf("failed to list preferences: %v", err)
+		}
+		if len(prefs) != 1 {
+			t.Errorf("expected only the change to be stored, got %+v", prefs)
+		}
+	})
+
+	t.Run("GET redirects browsers to settings", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleNotifications(w, httptest.NewRequest(http.MethodGet, "/settings/notifications", nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/settings" {
+			t.Errorf("expected a redirect to /settings, got %d", w.Code)
+		}
+	})
+}
+
+func TestSharedCollections(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	if _, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://go.dev", Title: "Go <3", Collection: "Reading", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	if _, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://example.com/private", Title: "Private", Collection: "Inbox"}); err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+
+	var link sharedCollectionLinkView
+	t.Run("POST shares a collection", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/settings/shared", strings.NewReader("collection=Reading"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleSharedCollections(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if link.Collection != "Reading" || link.JSONURL != link.WidgetURL+".json" ||
+			!strings.Contains(link.Snippet, `<iframe src="`+link.WidgetURL+`"`) {
+			t.Errorf("unexpected link %+v", link)
+		}
+	})
+	token := link.WidgetURL[strings.LastIndex(link.WidgetURL, "/")+1:]
+
+	t.Run("JSON feed is readable from any origin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleSharedCollection(w, httptest.NewRequest(http.MethodGet, "/shared/"+token+".json", nil))
+		if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("expected a public JSON feed, got %d %v", w.Code, w.Header())
+		}
+		var view sharedCollectionView
+		if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if view.Collection != "Reading" || len(view.Bookmarks) != 1 || view.Bookmarks[0].URL != "https://go.dev" ||
+			len(view.Bookmarks[0].Tags) != 1 || view.Bookmarks[0].Tags[0] != "go" {
+			t.Errorf("expected only the shared collection's bookmark, got %+v", view)
+		}
+	})
+
+	t.Run("widget lists the bookmarks", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleSharedCollection(w, httptest.NewRequest(http.MethodGet, "/shared/"+token, nil))
+		body := w.Body.String()
+		if w.Code != http.StatusOK || !strings.Contains(body, `href="https://go.dev"`) || !strings.Contains(body, "Go &lt;3") {
+			t.Errorf("expected the bookmark in the widget, got %d: %s", w.Code, body)
+		}
+		if strings.Contains(body, "Private") || w.Header().Get("Content-Security-Policy") == "" {
+			t.Errorf("expected a locked-down widget of only the shared collection, got %v: %s", w.Header(), body)
+		}
+	})
+
+	t.Run("unknown tokens are not found", func(t *testing.T) {
+		for _, path := range []string{"/shared/nope.json", "/shared/", "/shared/" + token + "/x"} {
+			w := httptest.NewRecorder()
+			server.handleSharedCollection(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected 404 for %s, got %d", path, w.Code)
+			}
+		}
+	})
+
+	t.Run("settings page shows the snippet", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleSettings(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if body := w.Body.String(); !strings.Contains(body, template.HTMLEscapeString(link.Snippet)) {
+			t.Errorf("expected the embed snippet on the settings page, got %s", body)
+		}
+	})
+
+	t.Run("delete stops sharing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/settings/shared/%d/delete", link.ID), nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleSharedCollectionAction(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+		w = httptest.NewRecorder()
+		server.handleSharedCollection(w, httptest.NewRequest(http.MethodGet, "/shared/"+token+".json", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected the feed to be gone, got %d", w.Code)
+		}
+	})
+}
+
+func TestLanding(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, nb := range []db.NewBookmark{
+		{URL: "https://go.dev", Title: "Go", Collection: "Reading", CreatedAt: day},
+		{URL: "https://example.com/later", Title: "Later", Collection: "Reading", CreatedAt: day.Add(time.Hour)},
+		{URL: "https://example.com/other", Title: "Other", CreatedAt: day.Add(2 * time.Hour)},
+	} {
+		id, err := server.db.CreateBookmark(nb)
+		if err != nil {
+			t.Fatalf("failed to create bookmark %d: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	post := func(t *testing.T, handler http.HandlerFunc, path string, form url.Values, asJSON bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if asJSON {
+			req.Header.Set("Accept", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	home := func(t *testing.T) landingView {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/home", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleLanding(w, req)
+		var view landingView
+		if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+			t.Fatalf("failed to decode landing page %d %q: %v", w.Code, w.Body.String(), err)
+		}
+		return view
+	}
+
+	t.Run("pins a bookmark and a collection", func(t *testing.T) {
+		w := post(t, server.handleArchive, fmt.Sprintf("/bookmarks/%d/pin", ids[2]), url.Values{}, true)
+		var bookmark bookmarkView
+		if err := json.Unmarshal(w.Body.Bytes(), &bookmark); err != nil || !bookmark.IsPinned {
+			t.Fatalf("expected a pinned bookmark, got %d %s: %v", w.Code, w.Body.String(), err)
+		}
+		w = post(t, server.handlePinnedCollections, "/home/collections", url.Values{"collection": {"Reading"}}, true)
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `["Reading"]` {
+			t.Fatalf("expected the pinned collections, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := post(t, server.handlePinnedCollections, "/home/collections", url.Values{"collection": {" "}}, true); w.Code != http.StatusBadRequest {
+			t.Errorf("expected a blank collection to be refused, got %d", w.Code)
+		}
+	})
+
+	t.Run("default layout shows every widget", func(t *testing.T) {
+		view := home(t)
+		var names []string
+		for _, w := range view.Widgets {
+			names = append(names, w.Name)
+		}
+		if !slices.Equal(names, db.LandingWidgets) {
+			t.Fatalf("expected every widget, got %v", names)
+		}
+		pinned := view.Widgets[0]
+		if len(pinned.Bookmarks) != 1 || pinned.Bookmarks[0].ID != ids[2] ||
+			len(pinned.Collections) != 1 || pinned.Collections[0].Count != 2 || len(pinned.Collections[0].Bookmarks) != 2 {
+			t.Errorf("unexpected pinned widget %+v", pinned)
+		}
+		if stats := view.Widgets[3].Stats; stats == nil || stats.Total != 3 || stats.Unread != 3 || stats.Pinned != 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("saved layout orders and limits the widgets", func(t *testing.T) {
+		w := post(t, server.handleLandingLayout, "/settings/landing", url.Values{
+			db.LandingStats: {"1"}, db.LandingPinned: {"2"}, db.LandingRecent: {"off"}, "list_size": {"1"},
+		}, true)
+		var layout landingLayoutView
+		if err := json.Unmarshal(w.Body.Bytes(), &layout); err != nil {
+			t.Fatalf("failed to decode layout %d %q: %v", w.Code, w.Body.String(), err)
+		}
+		if !slices.Equal(layout.Widgets, []string{db.LandingStats, db.LandingPinned}) || layout.ListSize != 1 {
+			t.Errorf("unexpected layout %+v", layout)
+		}
+		view := home(t)
+		if len(view.Widgets) != 2 || view.Widgets[0].Name != db.LandingStats || len(view.Widgets[1].Collections[0].Bookmarks) != 1 {
+			t.Errorf("expected stats, then pinned with one bookmark per collection, got %+v", view.Widgets)
+		}
+
+		w = httptest.NewRecorder()
+		server.handleLanding(w, httptest.NewRequest(http.MethodGet, "/home", nil))
+		body := w.Body.String()
+		stats, pinned := strings.Index(body, "landing-widget landing-stats"), strings.Index(body, "landing-widget landing-pinned")
+		if w.Code != http.StatusOK || stats < 0 || pinned < stats || strings.Contains(body, "landing-recent") {
+			t.Errorf("expected the page to follow the layout, got %d: %s", w.Code, body)
+		}
+		w = httptest.NewRecorder()
+		server.handleSettings(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if body := w.Body.String(); !strings.Contains(body, `<option value="1" selected>1</option>`) || !strings.Contains(body, `value="1">`) {
+			t.Errorf("expected the layout on the settings page, got %s", body)
+		}
+	})
+
+	t.Run("invalid layouts are refused", func(t *testing.T) {
+		for _, form := range []url.Values{
+			{db.LandingStats: {"top"}},
+			{db.LandingStats: {"1"}, "list_size": {"500"}},
+		} {
+			if w := post(t, server.handleLandingLayout, "/settings/landing", form, true); w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400 for %v, got %d", form, w.Code)
+			}
+		}
+	})
+
+	t.Run("browsers go back to the landing page", func(t *testing.T) {
+		w := post(t, server.handleArchive, fmt.Sprintf("/bookmarks/%d/pin", ids[2]), url.Values{"pinned": {"false"}, "next": {"/home"}}, false)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/home" {
+			t.Fatalf("expected a redirect to /home, got %d %v", w.Code, w.Header())
+		}
+		if flags, err := server.db.GetBookmarkFlags(ids[2]); err != nil || flags.IsPinned {
+			t.Errorf("expected the bookmark to be unpinned, got %+v, %v", flags, err)
+		}
+	})
+}
+
+// fakeTTS reads text aloud as the text itself.
+type fakeTTS struct{}
+
+func (fakeTTS) Synthesize(_ context.Context, text string) ([]byte, string, error) {
+	return []byte("ID3" + text), "audio/mpeg", nil
+}
+
+func TestArchiveAudio(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	id, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://example.com/essay", Title: "Essay", Tags: []string{"listen"}})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	now := time.Now()
+	if err := server.db.SaveArchiveResult(id, now, &now, "ok", "", "https://example.com/essay", "<html>essay</html>"); err != nil {
+		t.Fatalf("failed to save archive: %v", err)
+	}
+	if err := server.db.SaveBookmarkReadable(db.BookmarkReadable{BookmarkID: id, Content: "<p>The essay.</p>", TextContent: "The essay."}); err != nil {
+		t.Fatalf("failed to save readable: %v", err)
+	}
+	audioPath := fmt.Sprintf("/bookmarks/%d/archive/audio", id)
+
+	t.Run("POST without a backend is not implemented", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleArchive(w, httptest.NewRequest(http.MethodPost, audioPath, nil))
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status %d, got %d", http.StatusNotImplemented, w.Code)
+		}
+	})
+
+	server.tts = fakeTTS{}
+
+	t.Run("POST reads the article aloud", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, audioPath, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"mime_type":"audio/mpeg"`) {
+			t.Fatalf("expected the audio to be generated, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("GET serves the audio with ranges", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, audioPath, nil)
+		req.Header.Set("Range", "bytes=0-2")
+		w := httptest.NewRecorder()
+		server.handleArchive(w, req)
+		if w.Code != http.StatusPartialContent || w.Body.String() != "ID3" || w.Header().Get("Content-Type") != "audio/mpeg" {
+			t.Errorf("expected the first bytes of the audio, got %d %q %v", w.Code, w.Body.String(), w.Header())
+		}
+	})
+
+	t.Run("reader view plays it", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handleArchive(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/bookmarks/%d/read", id), nil))
+		if body := w.Body.String(); !strings.Contains(body, `<audio class="reader-audio"`) || !strings.Contains(body, "Read aloud again") {
+			t.Errorf("expected an audio player, got %s", body)
+		}
+	})
+
+	t.Run("podcast feed lists the tag's episodes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		server.handlePodcast(w, httptest.NewRequest(http.MethodGet, "/podcast/listen.rss", nil))
+		body := w.Body.String()
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") {
+			t.Fatalf("expected an RSS feed, got %d %v", w.Code, w.Header())
+		}
+		v, err := server.db.GetLatestArchiveVersion(id)
+		if err != nil {
+			t.Fatalf("failed to get latest version: %v", err)
+		}
+		enclosure := fmt.Sprintf(`<enclosure url="http://example.com/podcast/audio/%d/%d.mp3" length="20" type="audio/mpeg">`, id, v.ID)
+		if !strings.Contains(body, enclosure) || !strings.Contains(body, "<title>Essay</title>") {
+			t.Errorf("expected the episode in the feed, got %s", body)
+		}
+
+		w = httptest.NewRecorder()
+		server.handlePodcast(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/podcast/audio/%d/%d.mp3", id, v.ID), nil))
+		if w.Code != http.StatusOK || w.Body.String() != "ID3Essay\n\nThe essay." {
+			t.Errorf("expected the episode's audio, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("unknown podcast paths are not found", func(t *testing.T) {
+		for _, path := range []string{"/podcast/", "/podcast/audio/1", "/podcast/audio/x/1.mp3", "/podcast/audio/1/999.mp3"} {
+			w := httptest.NewRecorder()
+			server.handlePodcast(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected 404 for %s, got %d", path, w.Code)
+			}
+		}
+	})
+}
+
+func TestHandleVersion(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"><entry>
+			<link rel="alternate" href="https://github.com/SecKatie/bookmarkd/releases/tag/v9.0.0"/>
+			<title>Nine</title><content type="html">&lt;p&gt;Big changes.&lt;/p&gt;</content>
+		</entry></feed>`))
+	}))
+	defer feed.Close()
+	server.updates = core.NewUpdateChecker(feed.URL, "v1.0.0")
+	if _, _, err := server.updates.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	alice, err := server.db.CreateUser("alice", "", false)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	get := func(userID int64) versionView {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+		req = req.WithContext(withUser(req.Context(), userID))
+		w := httptest.NewRecorder()
+		server.handleVersion(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var got versionView
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		return got
+	}
+
+	got := get(db.LocalUserID)
+	if got.Version == "" || got.GoVersion == "" || !strings.HasPrefix(got.SchemaVersion, "00") {
+		t.Errorf("expected the build and schema, got %+v", got)
+	}
+	if got.Update == nil || got.Update.Version != "v9.0.0" || got.Update.Summary != "Big changes." {
+		t.Errorf("expected admins to see the update, got %+v", got.Update)
+	}
+	if got := get(alice.ID); got.Update != nil {
+		t.Errorf("expected no update for non-admins, got %+v", got.Update)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+	w := httptest.NewRecorder()
+	server.handleSettings(w, req)
+	if !strings.Contains(w.Body.String(), "bookmarkd v9.0.0 is available") {
+		t.Error("expected the settings page to announce the update")
+	}
+}
+
+func TestTriage(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	day := time.Now().Add(-48 * time.Hour)
+	var ids []int64
+	for i, nb := range []db.NewBookmark{
+		{URL: "https://example.com/old", Title: "Old", CreatedAt: day},
+		{URL: "https://example.com/new", Title: "New", CreatedAt: day.Add(time.Hour)},
+	} {
+		id, err := server.db.CreateBookmark(nb)
+		if err != nil {
+			t.Fatalf("failed to create bookmark %d: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	triage := func(t *testing.T, method string, form url.Values) (*httptest.ResponseRecorder, triageView) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/triage", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleTriage(w, req)
+		var view triageView
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+				t.Fatalf("failed to decode triage %q: %v", w.Body.String(), err)
+			}
+		}
+		return w, view
+	}
+
+	_, view := triage(t, http.MethodGet, nil)
+	if view.Bookmark == nil || view.Bookmark.ID != ids[0] || view.Remaining != 2 || view.Done != 0 {
+		t.Fatalf("expected the oldest bookmark with 2 left, got %+v", view)
+	}
+
+	_, view = triage(t, http.MethodPost, url.Values{"id": {itoa(ids[0])}, "action": {"later"}})
+	if view.Bookmark == nil || view.Bookmark.ID != ids[1] || view.Later != 1 || view.Remaining != 1 {
+		t.Fatalf("expected the next bookmark after putting one off, got %+v", view)
+	}
+	_, view = triage(t, http.MethodPost, url.Values{"id": {itoa(ids[1])}, "action": {"keep"}})
+	if view.Bookmark != nil || view.Kept != 1 || view.Done != 2 || view.Remaining != 0 {
+		t.Errorf("expected the triage to be done, got %+v", view)
+	}
+
+	if w, _ := triage(t, http.MethodPost, url.Values{"id": {itoa(ids[0])}, "action": {"snooze"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown action, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w, _ := triage(t, http.MethodPost, url.Values{"id": {"99999"}, "action": {"keep"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing bookmark, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/triage", nil)
+	w := httptest.NewRecorder()
+	server.handleTriage(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "All done for this week") {
+		t.Errorf("expected the triage page, got %d", w.Code)
+	}
+}
+
+func TestBookmarkSearch(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	titled, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://go.dev/blog", Title: "Go <blog>"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	noted, err := server.db.CreateBookmark(db.NewBookmark{URL: "https://example.com/", Title: "Example", Notes: "Read the Go blog later"})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %v", err)
+	}
+	// client is "json", "htmx" or "" for a browser.
+	search := func(query string, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks/search?"+query, nil)
+		switch client {
+		case "json":
+			req.Header.Set("Accept", "application/json")
+		case "htmx":
+			req.Header.Set("HX-Request", "true")
+		}
+		w := httptest.NewRecorder()
+		server.handleBookmarkSearch(w, req)
+		return w
+	}
+
+	w := search("q=blog", "json")
+	var results []searchResultView
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("failed to decode results %d %q: %v", w.Code, w.Body.String(), err)
+	}
+	if len(results) != 2 || results[0].ID != titled || results[1].ID != noted {
+		t.Fatalf("expected the title match before the notes match, got %+v", results)
+	}
+	if results[0].TitleHTML != "Go &lt;<mark>blog</mark>&gt;" || results[1].SnippetHTML != "Read the Go <mark>blog</mark> later" {
+		t.Errorf("unexpected highlights %q, %q", results[0].TitleHTML, results[1].SnippetHTML)
+	}
+
+	// The list's search box sends its query as "search".
+	w = search("search=later", "htmx")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "<mark>later</mark>") || strings.Contains(body, "go.dev") {
+		t.Errorf("expected the notes match highlighted, got %d %s", w.Code, body)
+	}
+	if w = search("q=domain:", "htmx"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "search-hint") {
+		t.Errorf("expected a hint for a half-typed query, got %d %s", w.Code, w.Body.String())
+	}
+	if w = search("q=domain:", "json"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for JSON, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w = search("q=go+blog", ""); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/?search=go+blog" {
+		t.Errorf("expected a redirect to the full results, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+// TestDomainsPage tests the domains page and its JSON.
+func TestDomainsPage(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	for _, u := range []string{"https://go.dev/doc", "https://go.dev/blog", "https://sqlite.org/"} {
+		if _, err := server.db.AddBookmark(u, u); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+	}
+
+	get := func(query string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/domains?"+query, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		server.handleDomains(w, req)
+		return w
+	}
+
+	w := get("sort=domain", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var domains []domainView
+	if err := json.Unmarshal(w.Body.Bytes(), &domains); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+	}
+	if len(domains) != 2 || domains[0].Domain != "go.dev" || domains[0].Count != 2 || domains[0].LastAddedAt == "" || domains[1].Domain != "sqlite.org" {
+		t.Errorf("unexpected domains: %+v", domains)
+	}
+
+	w = get("filter=unread", "text/html")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `href="/?domain=go.dev&amp;filter=unread"`) || !strings.Contains(body, "2 domains, 3 bookmarks") {
+		t.Errorf("expected links into the bookmark list, got %s", body)
+	}
+
+	if w := get("sort=size", "application/json"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid sort, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// TestTagsPage tests listing, suggesting, renaming, merging and deleting
+// tags through /tags.
+func TestTagsPage(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	for _, nb := range []db.NewBookmark{
+		{URL: "https://go.dev/doc", Tags: []string{"golang", "docs"}},
+		{URL: "https://go.dev/blog", Tags: []string{"go"}},
+	} {
+		if _, err := server.db.CreateBookmark(nb); err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+	}
+	post := func(action string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tags/"+action, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleTagAction(w, req)
+		return w
+	}
+	tags := func() []tagView {
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		server.handleTags(w, req)
+		var views []tagView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", w.Body.String(), err)
+		}
+		return views
+	}
+
+	if got := tags(); len(got) != 3 || got[0] != (tagView{"docs", 1}) {
+		t.Errorf("unexpected tags: %+v", got)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+	w := httptest.NewRecorder()
+	server.handleTags(w, req)
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, `href="/?tag=golang"`) || !strings.Contains(body, `action="/tags/rename"`) {
+		t.Errorf("expected the tags page, got %d %s", w.Code, body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tags/suggest?q=go", nil)
+	w = httptest.NewRecorder()
+	server.handleTagSuggest(w, req)
+	var suggested []tagView
+	if err := json.Unmarshal(w.Body.Bytes(), &suggested); err != nil || len(suggested) != 2 || suggested[0].Name != "go" {
+		t.Errorf("unexpected suggestions %s: %v", w.Body.String(), err)
+	}
+
+	w = post("rename", url.Values{"tag": {"golang"}, "to": {"go"}})
+	var res tagChangeView
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK || res != (tagChangeView{"go", 1}) {
+		t.Errorf("unexpected rename result %d %s: %v", w.Code, w.Body.String(), err)
+	}
+	w = post("merge", url.Values{"tags": {"docs, go"}, "into": {"reading"}})
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res != (tagChangeView{"reading", 2}) {
+		t.Errorf("unexpected merge result %d %s: %v", w.Code, w.Body.String(), err)
+	}
+	if got := tags(); len(got) != 1 || got[0] != (tagView{"reading", 2}) {
+		t.Errorf("expected only the merged tag left, got %+v", got)
+	}
+	if w := post("delete", url.Values{"tag": {"golang"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing tag, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := post("rename", url.Values{"tag": {"reading"}, "to": {"#"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an empty tag, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := post("delete", url.Values{"tag": {"reading"}}); w.Code != http.StatusOK || len(tags()) != 0 {
+		t.Errorf("expected the tag deleted, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("archive", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown action, got %d", http.StatusNotFound, w.Code)
+	}
 }
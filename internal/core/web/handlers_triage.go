@@ -0,0 +1,100 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// handleTriage serves the weekly unread triage (/triage): GET shows the
+// next unread bookmark with this week's progress, and POST applies one of
+// db.TriageActions (form fields id and action) to a bookmark and moves on.
+// Clients that send Accept: application/json get a triageView and HTMX
+// requests the triage card; browsers are sent back to the triage page.
+func (ws *Server) handleTriage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid form data")
+			return
+		}
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid bookmark ID")
+			return
+		}
+		action := strings.ToLower(strings.TrimSpace(r.FormValue("action")))
+		if err := ws.userDB(r).TriageBookmark(id, action, time.Now()); err != nil {
+			if errors.Is(err, db.ErrInvalidTriageAction) {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusNotFound, "Bookmark not found")
+			log.Printf("Failed to triage bookmark %d: %v", id, err)
+			return
+		}
+		if !wantsJSON(r) && !isHTMX(r) {
+			http.Redirect(w, r, "/triage", http.StatusSeeOther)
+			return
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	view, err := ws.buildTriageView(r)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+		log.Printf("Failed to build triage: %v", err)
+		return
+	}
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusOK, view)
+		return
+	}
+	data := map[string]any{
+		"ActivePage": "triage",
+		"CSRFToken":  csrfToken(r),
+		"Triage":     view,
+	}
+	if isHTMX(r) {
+		ws.renderTemplate(w, "triage_card", data)
+		return
+	}
+	ws.renderTemplate(w, "triage.html", data)
+}
+
+// buildTriageView loads this week's triage for the current user.
+func (ws *Server) buildTriageView(r *http.Request) (triageView, error) {
+	database := ws.userDB(r)
+	now := time.Now()
+	s, err := database.GetTriageSession(now)
+	if err != nil {
+		return triageView{}, err
+	}
+	view := triageView{
+		Week:      s.Week,
+		StartedAt: s.StartedAt,
+		Kept:      s.Kept,
+		Later:     s.Later,
+		Archived:  s.Archived,
+		Deleted:   s.Deleted,
+		Done:      s.Done(),
+		Remaining: s.Remaining,
+	}
+	next, ok, err := database.NextTriageBookmark(now)
+	if err != nil {
+		return triageView{}, err
+	}
+	if ok {
+		b := buildBookmarkView(database, next)
+		view.Bookmark = &b
+	}
+	return view, nil
+}
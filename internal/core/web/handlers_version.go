@@ -0,0 +1,44 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+)
+
+// versionView is the /api/version response.
+type versionView struct {
+	core.BuildInfo
+	SchemaVersion string `json:"schema_version"`
+	// Update is the newer release the update checker found, shown to
+	// admins only.
+	Update *core.Release `json:"update,omitempty"`
+}
+
+// handleVersion reports the running build and database schema, and to
+// admins any newer release the opt-in update checker has found.
+func (ws *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	view, err := ws.versionView(r)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errorView{Message: "Failed to read schema version"})
+		log.Printf("Failed to read schema version: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (ws *Server) versionView(r *http.Request) (versionView, error) {
+	schema, err := ws.requestDB(r).SchemaVersion()
+	if err != nil {
+		return versionView{}, err
+	}
+	view := versionView{BuildInfo: core.ReadBuildInfo(), SchemaVersion: schema}
+	if release, ok := ws.updates.Available(); ok && ws.isAdmin(r) {
+		view.Update = &release
+	}
+	return view, nil
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// highlightTerms escapes text and wraps each word in it that starts with
+// one of terms (see db.SearchTerms) in <mark>, ignoring case. Prefix matches
+// stand in for the stemming and prefix searches of the search index.
+func highlightTerms(text string, terms []string) template.HTML {
+	var quoted []string
+	for _, t := range terms {
+		if t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return template.HTML(html.EscapeString(text))
+	}
+	re := regexp.MustCompile(`(?i)(^|[^\pL\pN])(` + strings.Join(quoted, "|") + `)`)
+	var out strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[4], m[5]
+		out.WriteString(html.EscapeString(text[last:start]))
+		out.WriteString("<mark>" + html.EscapeString(text[start:end]) + "</mark>")
+		last = end
+	}
+	out.WriteString(html.EscapeString(text[last:]))
+	return template.HTML(out.String())
+}
+
+// highlightSnippet escapes a db.SearchResult snippet, turning the marks
+// around its matches into <mark> elements.
+func highlightSnippet(snippet string) template.HTML {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, db.SearchMatchStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, db.SearchMatchEnd, "</mark>")
+	return template.HTML(escaped)
+}
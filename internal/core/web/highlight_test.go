@@ -0,0 +1,36 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"no terms", "Go <generics>", nil, "Go &lt;generics&gt;"},
+		{"word starts only", "Gardening and regarding", []string{"gard"}, "<mark>Gard</mark>ening and regarding"},
+		{"several terms", "https://go.dev/blog", []string{"go", "blog"}, "https://<mark>go</mark>.dev/<mark>blog</mark>"},
+		{"escapes around matches", `"Tom & Jerry"`, []string{"jerry"}, "&#34;Tom &amp; <mark>Jerry</mark>&#34;"},
+		{"regexp characters are literal", "c++ (and c)", []string{"c++"}, "<mark>c++</mark> (and c)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(highlightTerms(tt.text, tt.terms)); got != tt.want {
+				t.Errorf("highlightTerms(%q, %q)\n got %s\nwant %s", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	got := string(highlightSnippet("a <b> " + db.SearchMatchStart + "match" + db.SearchMatchEnd + "…"))
+	if want := "a &lt;b&gt; <mark>match</mark>…"; got != want {
+		t.Errorf("highlightSnippet() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// markdownInline matches the inline Markdown renderMarkdown understands:
+// `code`, [text](url), bare URLs, **bold** and *italic*.
+var markdownInline = regexp.MustCompile("`([^`]+)`" +
+	`|\[([^\]]+)\]\((https?://[^\s)]+)\)` +
+	`|(https?://[^\s<>"]+)` +
+	`|\*\*([^*]+)\*\*` +
+	`|\*([^*\s](?:[^*]*[^*\s])?)\*`)
+
+// listItem matches a "- ", "* " or "+ " bullet.
+var listItem = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+
+// renderMarkdown renders the small subset of Markdown used in bookmark
+// notes: paragraphs (single newlines become line breaks), bullet lists,
+// inline code, bold, italic and http(s) links. Everything else is shown as
+// text, and all text is escaped, so the result is safe to embed.
+func renderMarkdown(src string) template.HTML {
+	var out strings.Builder
+	var para, items []string
+
+	flushPara := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+			para = nil
+		}
+	}
+	flushList := func() {
+		if len(items) > 0 {
+			out.WriteString("<ul>")
+			for _, item := range items {
+				out.WriteString("<li>" + item + "</li>")
+			}
+			out.WriteString("</ul>")
+			items = nil
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		switch m := listItem.FindStringSubmatch(line); {
+		case strings.TrimSpace(line) == "":
+			flushPara()
+			flushList()
+		case m != nil:
+			flushPara()
+			items = append(items, renderInline(m[1]))
+		default:
+			flushList()
+			para = append(para, renderInline(strings.TrimSpace(line)))
+		}
+	}
+	flushPara()
+	flushList()
+
+	// Everything written above is either escaped text or markup built here.
+	return template.HTML(out.String())
+}
+
+// renderInline escapes a line of text and renders its inline Markdown.
+func renderInline(s string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range markdownInline.FindAllStringSubmatchIndex(s, -1) {
+		out.WriteString(html.EscapeString(s[last:m[0]]))
+		last = m[1]
+		group := func(i int) string { return s[m[2*i]:m[2*i+1]] }
+		switch {
+		case m[2] >= 0:
+			out.WriteString("<code>" + html.EscapeString(group(1)) + "</code>")
+		case m[4] >= 0:
+			out.WriteString(markdownLink(group(3), group(2)))
+		case m[8] >= 0:
+			// Leave trailing punctuation out of bare URLs.
+			u := strings.TrimRight(group(4), ".,;:!?)'")
+			out.WriteString(markdownLink(u, u))
+			out.WriteString(html.EscapeString(group(4)[len(u):]))
+		case m[10] >= 0:
+			out.WriteString("<strong>" + html.EscapeString(group(5)) + "</strong>")
+		case m[12] >= 0:
+			out.WriteString("<em>" + html.EscapeString(group(6)) + "</em>")
+		}
+	}
+	out.WriteString(html.EscapeString(s[last:]))
+	return out.String()
+}
+
+func markdownLink(href, text string) string {
+	return `<a href="` + html.EscapeString(href) + `" target="_blank" rel="noopener nofollow">` + html.EscapeString(text) + `</a>`
+}
@@ -0,0 +1,28 @@
+package web
+
+import "testing"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"paragraphs and line breaks", "one\ntwo\n\nthree", "<p>one<br>two</p><p>three</p>"},
+		{"emphasis and code", "**bold**, *italic* and `x < y`", "<p><strong>bold</strong>, <em>italic</em> and <code>x &lt; y</code></p>"},
+		{"list", "Todo:\n- first\n* second", "<p>Todo:</p><ul><li>first</li><li>second</li></ul>"},
+		{"link", "[docs](https://go.dev/doc)", `<p><a href="https://go.dev/doc" target="_blank" rel="noopener nofollow">docs</a></p>`},
+		{"bare url keeps trailing punctuation outside", "See https://example.com/a.", `<p>See <a href="https://example.com/a" target="_blank" rel="noopener nofollow">https://example.com/a</a>.</p>`},
+		{"html is escaped", `<script>alert(1)</script>`, "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"javascript links are text", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{"quotes in urls are escaped", `https://example.com/"onmouseover=x`, `<p><a href="https://example.com/" target="_blank" rel="noopener nofollow">https://example.com/</a>&#34;onmouseover=x</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderMarkdown(tt.in)); got != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// rateLimitWindow is the period API token quotas are counted over.
+const rateLimitWindow = time.Hour
+
+// rateLimiter counts requests per API token in fixed windows of
+// rateLimitWindow, starting at a token's first request. Counts are kept in
+// memory, so they start over when the server restarts.
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[int64]*rateWindow
+	// defaultQuota applies to tokens without a quota of their own; 0 means
+	// they are unlimited.
+	defaultQuota int
+	now          func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(defaultQuota int) *rateLimiter {
+	return &rateLimiter{
+		windows:      make(map[int64]*rateWindow),
+		defaultQuota: defaultQuota,
+		now:          time.Now,
+	}
+}
+
+// rateLimitStatus is the outcome of counting one request.
+type rateLimitStatus struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Allowed   bool
+}
+
+// allow counts a request by token and reports whether it is within the
+// token's quota. A zero Limit means the token is unlimited.
+func (l *rateLimiter) allow(token db.APIToken) rateLimitStatus {
+	limit := token.Quota
+	if limit == 0 {
+		limit = l.defaultQuota
+	}
+	if limit <= 0 {
+		return rateLimitStatus{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	w, ok := l.windows[token.ID]
+	if !ok || !now.Before(w.start.Add(rateLimitWindow)) {
+		w = &rateWindow{start: now}
+		l.windows[token.ID] = w
+	}
+	status := rateLimitStatus{Limit: limit, Reset: w.start.Add(rateLimitWindow)}
+	if w.count >= limit {
+		return status
+	}
+	w.count++
+	status.Remaining = limit - w.count
+	status.Allowed = true
+	return status
+}
+
+// limitAPITokens authenticates requests that carry an API token (see
+// requestAPIToken) and enforces the token's scope and quota, reporting the
+// quota in X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// (Unix seconds) headers. Unknown tokens get a 401, requests outside a
+// token's scope a 403 and tokens over their quota a 429 with Retry-After;
+// accepted tokens are stored in the request context. Requests without a
+// token pass through unchanged.
+func (ws *Server) limitAPITokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := requestAPIToken(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := ws.requestDB(r).AuthenticateAPIToken(secret)
+		if errors.Is(err, db.ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, r, http.StatusUnauthorized, "Invalid API token")
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
+			log.Printf("Failed to authenticate API token: %v", err)
+			return
+		}
+
+		if !tokenAllows(token, r) {
+			writeError(w, r, http.StatusForbidden, "API token not allowed here")
+			return
+		}
+
+		status := ws.limiter.allow(token)
+		if status.Limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+		}
+		if !status.Allowed {
+			retry := int(status.Reset.Sub(ws.limiter.now()).Seconds()) + 1
+			retry = max(retry, 1)
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			writeErrorView(w, r, http.StatusTooManyRequests, errorView{
+				Code:    errorCodeQuotaExceeded,
+				Message: "API token rate limit exceeded",
+				Details: map[string]any{"retry_after": retry, "limit": status.Limit},
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withAPIToken(r.Context(), token)))
+	})
+}
+
+// clientLimiter is a token bucket per client IP for write requests: each
+// bucket holds up to burst requests and refills at rate per second. Buckets
+// are kept in memory and dropped once they have refilled.
+type clientLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*clientBucket
+	// rate is in requests per second; 0 turns the limit off.
+	rate   float64
+	burst  float64
+	pruned time.Time
+	now    func() time.Time
+	// trustProxy takes client IPs from X-Forwarded-For; see clientIP.
+	trustProxy bool
+}
+
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+	// limited is set while the client is being refused, so each run of
+	// refusals is logged once.
+	limited bool
+}
+
+// newClientLimiter allows perMinute write requests a minute from each
+// client, in bursts of up to burst.
+func newClientLimiter(perMinute float64, burst int) *clientLimiter {
+	return &clientLimiter{
+		buckets: make(map[string]*clientBucket),
+		rate:    perMinute / 60,
+		burst:   float64(max(burst, 1)),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from ip's bucket. If it is empty, allow returns false
+// with how long until the next token, and whether this starts a run of
+// refusals.
+func (l *clientLimiter) allow(ip string) (ok bool, retry time.Duration, first bool) {
+	return l.allowN(ip, 1)
+}
+
+// allowN is allow for n tokens at once: it takes all of them or, refusing,
+// none. n over the burst is never allowed; the retry is then until the
+// bucket is full.
+func (l *clientLimiter) allowN(ip string, n int) (ok bool, retry time.Duration, first bool) {
+	if l.rate <= 0 {
+		return true, 0, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.pruned) > full {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) >= full {
+				delete(l.buckets, key)
+			}
+		}
+		l.pruned = now
+	}
+
+	b, found := l.buckets[ip]
+	if !found {
+		b = &clientBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if want := float64(n); b.tokens < want {
+		first, b.limited = !b.limited, true
+		return false, time.Duration((min(want, l.burst) - b.tokens) / l.rate * float64(time.Second)), first
+	}
+	b.tokens -= float64(n)
+	b.limited = false
+	return true, 0, false
+}
+
+// clientIP returns the IP address r came from. With trustProxy, that is the
+// last X-Forwarded-For entry, which the proxy in front of the server added;
+// earlier entries come from the client and can be forged.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if hops := r.Header.Values("X-Forwarded-For"); len(hops) > 0 {
+			last := hops[len(hops)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isWrite reports whether r changes data: any request but GET, HEAD,
+// OPTIONS, TRACE and WebDAV's PROPFIND, and the bookmarklet's GET
+// /bookmarklet/add, which saves a bookmark when it carries an API token.
+func isWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, "PROPFIND":
+		return r.URL.Path == "/bookmarklet/add"
+	}
+	return true
+}
+
+// limitClients rate limits write requests (see isWrite) per client IP with
+// ws.clients, so a publicly exposed instance can't be flooded with new
+// bookmarks, login attempts or archive jobs. Clients over the limit get a
+// 429 with Retry-After. It runs before API token checks, so token requests
+// are limited too.
+func (ws *Server) limitClients(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWrite(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := clientIP(r, ws.clients.trustProxy)
+		ok, retry, first := ws.clients.allow(ip)
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if first {
+			log.Printf("Rate limiting write requests from %s", ip)
+		}
+		after := max(int(retry.Seconds()+0.999), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(after))
+		writeErrorView(w, r, http.StatusTooManyRequests, errorView{
+			Code:    errorCodeRateLimited,
+			Message: "Rate limit exceeded",
+			Details: map[string]any{"retry_after": after},
+		})
+	})
+}
+
+// requestAPIToken returns the API token r carries, if any: from an
+// "Authorization: Bearer" header or, since bookmarklets open it in a new
+// window and can't set headers, a "token" parameter on /bookmarklet/add.
+func requestAPIToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		// Other schemes, such as Basic, are for other handlers.
+		scheme, secret, _ := strings.Cut(header, " ")
+		return strings.TrimSpace(secret), strings.EqualFold(scheme, "Bearer")
+	}
+	if r.URL.Path == "/bookmarklet/add" {
+		if token := r.URL.Query().Get("token"); token != "" {
+			return token, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestLimitAPITokens tests API token authentication and quotas.
+func TestLimitAPITokens(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.limiter.now = func() time.Time { return now }
+	server.limiter.defaultQuota = 5
+
+	_, limited, err := server.db.CreateAPIToken("limited", 2)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	_, defaulted, err := server.db.CreateAPIToken("default", 0)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	handler := server.limitAPITokens(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("requests without a token pass", func(t *testing.T) {
+		w := do("")
+		if w.Code != http.StatusNoContent || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("expected an unlimited request, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("invalid tokens are refused", func(t *testing.T) {
+		w := do("Bearer bmk_nope")
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("expected 401 with WWW-Authenticate, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("quota is counted and enforced", func(t *testing.T) {
+		reset := strconv.FormatInt(now.Add(rateLimitWindow).Unix(), 10)
+		for i, remaining := range []string{"1", "0"} {
+			w := do("Bearer " + limited)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("request %d: expected status %d, got %d", i, http.StatusNoContent, w.Code)
+			}
+			if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != remaining ||
+				w.Header().Get("X-RateLimit-Reset") != reset {
+				t.Errorf("request %d: unexpected headers %v", i, w.Header())
+			}
+		}
+		w := do("Bearer " + limited)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "3601" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+			t.Errorf("unexpected headers %v", w.Header())
+		}
+	})
+
+	t.Run("tokens without a quota use the default", func(t *testing.T) {
+		w := do("bearer " + defaulted)
+		if w.Code != http.StatusNoContent || w.Header().Get("X-RateLimit-Limit") != "5" {
+			t.Errorf("expected the default quota, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("the window resets", func(t *testing.T) {
+		now = now.Add(rateLimitWindow)
+		w := do("Bearer " + limited)
+		if w.Code != http.StatusNoContent || w.Header().Get("X-RateLimit-Remaining") != "1" {
+			t.Errorf("expected a fresh window, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("a zero default leaves tokens unlimited", func(t *testing.T) {
+		server.limiter.defaultQuota = 0
+		w := do("Bearer " + defaulted)
+		if w.Code != http.StatusNoContent || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("expected an unlimited request, got %d %v", w.Code, w.Header())
+		}
+	})
+}
+
+// TestLimitClients tests per-IP rate limiting of write requests.
+func TestLimitClients(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.clients = newClientLimiter(60, 2)
+	server.clients.now = func() time.Time { return now }
+
+	handler := server.limitClients(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(method, target, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("writes are limited after the burst", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if w := do(http.MethodPost, "/bookmarks", "192.0.2.1"); w.Code != http.StatusNoContent {
+				t.Fatalf("request %d: expected status %d, got %d", i, http.StatusNoContent, w.Code)
+			}
+		}
+		w := do(http.MethodPost, "/bookmarks", "192.0.2.1")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+			t.Errorf("expected 429 with Retry-After 1, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("reads and other clients are not limited", func(t *testing.T) {
+		if w := do(http.MethodGet, "/bookmarks", "192.0.2.1"); w.Code != http.StatusNoContent {
+			t.Errorf("expected GET to pass, got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/bookmarks", "192.0.2.2"); w.Code != http.StatusNoContent {
+			t.Errorf("expected another client to pass, got %d", w.Code)
+		}
+	})
+
+	t.Run("buckets refill over time", func(t *testing.T) {
+		now = now.Add(time.Second)
+		if w := do(http.MethodPost, "/bookmarks", "192.0.2.1"); w.Code != http.StatusNoContent {
+			t.Errorf("expected a refilled token, got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/bookmarks", "192.0.2.1"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected 429, got %d", w.Code)
+		}
+	})
+
+	t.Run("bookmarklet adds count as writes", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			do(http.MethodGet, "/bookmarklet/add?url=https://example.com", "192.0.2.3")
+		}
+		if w := do(http.MethodGet, "/bookmarklet/add?url=https://example.com", "192.0.2.3"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("expected 429, got %d", w.Code)
+		}
+	})
+
+	t.Run("zero turns the limit off", func(t *testing.T) {
+		server.clients = newClientLimiter(0, 0)
+		for i := 0; i < 5; i++ {
+			if w := do(http.MethodPost, "/bookmarks", "192.0.2.1"); w.Code != http.StatusNoContent {
+				t.Fatalf("request %d: expected status %d, got %d", i, http.StatusNoContent, w.Code)
+			}
+		}
+	})
+}
+
+// TestClientIP tests which address requests are attributed to.
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/bookmarks", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.Header.Add("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	if got := clientIP(req, false); got != "10.0.0.1" {
+		t.Errorf("expected the peer address, got %q", got)
+	}
+	if got := clientIP(req, true); got != "198.51.100.7" {
+		t.Errorf("expected the address the proxy added, got %q", got)
+	}
+	req.Header.Del("X-Forwarded-For")
+	if got := clientIP(req, true); got != "10.0.0.1" {
+		t.Errorf("expected the peer address without the header, got %q", got)
+	}
+}
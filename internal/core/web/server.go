@@ -5,8 +5,13 @@ import (
 	"html/template"
 	"io/fs"
 	"log"
+	"maps"
 	"net/http"
+	"time"
 
+	"golang.org/x/net/webdav"
+
+	"github.com/seckatie/bookmarkd/internal/core"
 	"github.com/seckatie/bookmarkd/internal/core/db"
 )
 
@@ -14,54 +19,215 @@ import (
 var templatesFS embed.FS
 
 type Server struct {
-	db                 *db.DB
-	templates          *template.Template
-	staticFS           http.FileSystem
+	db        *db.DB
+	templates *template.Template
+	staticFS  http.FileSystem
+	limiter   *rateLimiter
+	clients   *clientLimiter
+	// adds limits new bookmarks per API token or client IP; see allowAdd.
+	adds *clientLimiter
+	// challenges lets people past the add limit; nil if challenges are off.
+	challenges *addChallenger
+	// stripScripts removes scripts from archived pages as they are served.
+	stripScripts bool
+	sessions     *sessionStore
+	// archiveWorkers is how many archive workers the server runs, for
+	// estimating queue waits.
+	archiveWorkers int
+	// davLocks is the lock system the WebDAV handler requires; the tree is
+	// read-only, so nothing is ever locked.
+	davLocks webdav.LockSystem
+	// tts reads archived articles aloud; nil if text-to-speech is off.
+	tts core.TTSBackend
+	// updates finds newer releases to tell admins about; nil if update
+	// checks are off.
+	updates *core.UpdateChecker
+	// wayback submits pages to the Wayback Machine; nil if it is off.
+	wayback *core.WaybackClient
+	// archiveToday submits pages to archive.today; nil if it is off.
+	archiveToday *core.ArchiveTodayClient
+	// requestTimeout and routeTimeouts bound how long requests may take;
+	// see limitTime.
+	requestTimeout time.Duration
+	routeTimeouts  RouteTimeouts
+}
+
+// Options configure the web server.
+type Options struct {
+	// APITokenQuota is the requests per hour allowed to API tokens without
+	// a quota of their own; 0 leaves them unlimited.
+	APITokenQuota int
+	// WriteRateLimit is the write requests per minute allowed from one
+	// client IP, in bursts of up to WriteRateBurst; 0 turns it off.
+	WriteRateLimit float64
+	WriteRateBurst int
+	// AddRateLimit is the bookmarks per minute that may be added with one
+	// API token or from one client IP, in bursts of up to AddRateBurst; 0
+	// turns it off.
+	AddRateLimit float64
+	AddRateBurst int
+	// AddChallenge offers JSON clients over the add limit, such as the
+	// bookmarklet, a proof-of-work challenge of AddChallengeDifficulty bits
+	// whose solution lets the bookmark through.
+	AddChallenge           bool
+	AddChallengeDifficulty int
+	// TrustProxy takes client IPs from X-Forwarded-For, for servers behind
+	// a reverse proxy.
+	TrustProxy bool
+	// StripArchiveScripts removes scripts from archived pages when serving
+	// them, including pages archived before scripts were stripped.
+	StripArchiveScripts bool
+	// Password, when set, is required to use the web UI and logs in as
+	// db.LocalUserID; see requireLogin. Users with their own passwords
+	// also make a login required.
+	Password string
+	// ArchiveWorkers is how many archive workers are running, for
+	// estimating how long queued archives will take.
+	ArchiveWorkers int
+	// TTS reads archived articles aloud for /bookmarks/{id}/archive/audio
+	// and the podcast feeds; nil turns generating audio off.
+	TTS core.TTSBackend
+	// Updates, if set, is the checker whose newer releases /api/version
+	// and the settings page show admins.
+	Updates *core.UpdateChecker
+	// Wayback, if set, lets the archive manager submit pages to the
+	// Wayback Machine.
+	Wayback *core.WaybackClient
+	// ArchiveToday, if set, lets the archive manager submit pages to
+	// archive.today.
+	ArchiveToday *core.ArchiveTodayClient
+	// RequestTimeout is how long a request may take before it is answered
+	// with a 503 and its database queries are canceled; 0 turns it off.
+	// RouteTimeouts override it, and the built-in longer timeouts of
+	// routes such as /import, per path; see limitTime.
+	RequestTimeout time.Duration
+	RouteTimeouts  RouteTimeouts
 }
 
-func StartServer(addr string, database *db.DB) {
+func StartServer(addr string, database *db.DB, opts Options) {
 	ws, err := newServer(database)
 	if err != nil {
 		log.Fatalf("Failed to initialize web server: %v", err)
 	}
+	ws.limiter.defaultQuota = opts.APITokenQuota
+	ws.clients = newClientLimiter(opts.WriteRateLimit, opts.WriteRateBurst)
+	ws.clients.trustProxy = opts.TrustProxy
+	ws.adds = newClientLimiter(opts.AddRateLimit, opts.AddRateBurst)
+	if opts.AddChallenge {
+		if ws.challenges, err = newAddChallenger(opts.AddChallengeDifficulty); err != nil {
+			log.Fatalf("Failed to initialize add challenges: %v", err)
+		}
+	}
+	ws.stripScripts = opts.StripArchiveScripts
+	ws.sessions.setPassword(opts.Password)
+	ws.archiveWorkers = max(opts.ArchiveWorkers, 1)
+	ws.tts = opts.TTS
+	ws.updates = opts.Updates
+	ws.wayback = opts.Wayback
+	ws.archiveToday = opts.ArchiveToday
+	ws.requestTimeout = opts.RequestTimeout
+	maps.Copy(ws.routeTimeouts, opts.RouteTimeouts)
+	if opts.Password != "" {
+		log.Printf("Web UI requires a password")
+	}
 
 	mux := http.NewServeMux()
 	ws.registerRoutes(mux)
 
 	log.Printf("Starting web server at %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           ws.limitTime(ws.limitClients(ws.limitAPITokens(ws.requireLogin(ws.protectCSRF(mux))))),
+		ReadHeaderTimeout: core.DefaultReadHeaderTimeout,
+		IdleTimeout:       core.DefaultIdleTimeout,
+	}
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Web server failed: %v", err)
 	}
 }
 
 func newServer(database *db.DB) (*Server, error) {
-	templates, err := template.ParseFS(templatesFS, "templates/*.html")
+	ws := &Server{
+		db:             database,
+		limiter:        newRateLimiter(core.DefaultAPITokenQuota),
+		clients:        newClientLimiter(core.DefaultWriteRateLimit, core.DefaultWriteRateBurst),
+		adds:           newClientLimiter(core.DefaultAddRateLimit, core.DefaultAddRateBurst),
+		sessions:       newSessionStore(),
+		davLocks:       webdav.NewMemLS(),
+		archiveWorkers: 1,
+		routeTimeouts:  maps.Clone(defaultRouteTimeouts),
+	}
+
+	funcs := template.FuncMap{
+		// loginEnabled lets the nav show a logout button when a password is set.
+		"loginEnabled": func() bool { return ws.sessions.enabled() },
+		// landingItem passes a bookmark and the page's CSRF token, for its
+		// pin button, to home.html's landing_bookmark template.
+		"landingItem": func(b bookmarkView, csrfToken string) map[string]any {
+			return map[string]any{"Bookmark": b, "CSRFToken": csrfToken}
+		},
+		"listLink": listLink,
+	}
+	templates, err := template.New("").Funcs(funcs).Funcs(csrfFuncs).ParseFS(templatesFS, "templates/*.html")
 	if err != nil {
 		return nil, err
 	}
+	ws.templates = templates
 
 	staticSub, err := fs.Sub(templatesFS, "static")
 	if err != nil {
 		return nil, err
 	}
+	ws.staticFS = http.FS(staticSub)
 
-	return &Server{
-		db:        database,
-		templates: templates,
-		staticFS:  http.FS(staticSub),
-	}, nil
+	return ws, nil
 }
 
 func (ws *Server) registerRoutes(mux *http.ServeMux) {
 	ws.registerStaticRoutes(mux)
 
 	mux.HandleFunc("/", ws.handleIndex)
+	mux.HandleFunc("/home", ws.handleLanding)
+	mux.HandleFunc("/home/collections", ws.handlePinnedCollections)
+	mux.HandleFunc("/triage", ws.handleTriage)
+	mux.HandleFunc("/domains", ws.handleDomains)
+	mux.HandleFunc("/tags", ws.handleTags)
+	mux.HandleFunc("/tags/suggest", ws.handleTagSuggest)
+	mux.HandleFunc("/tags/", ws.handleTagAction) // Handles /tags/rename, /tags/merge and /tags/delete
+	mux.HandleFunc("/login", ws.handleLogin)
+	mux.HandleFunc("/logout", ws.handleLogout)
 	mux.HandleFunc("/bookmarklet/add", ws.handleBookmarkletAdd)
 	mux.HandleFunc("/bookmarklet", ws.handleBookmarklet)
+	mux.HandleFunc("/bookmarklet/generate", ws.handleBookmarkletGenerate)
+	mux.HandleFunc("/bookmarklet/tokens/", ws.handleBookmarkletToken) // Handles /bookmarklet/tokens/{id}/revoke
 	mux.HandleFunc("/bookmarks", ws.handleBookmarks)
-	mux.HandleFunc("/bookmarks/", ws.handleArchive) // Handles /bookmarks/{id}/archive and /bookmarks/{id}/archive/raw
+	mux.HandleFunc("/bookmarks/bulk", ws.handleBookmarksBulk)
+	mux.HandleFunc("/bookmarks/bulk/", ws.handleBookmarksBulkAction) // Handles /bookmarks/bulk/delete, /tag and /rearchive
+	mux.HandleFunc("/bookmarks/search", ws.handleBookmarkSearch)
+	mux.HandleFunc("/bookmarks/random", ws.handleRandomBookmark)
+	mux.HandleFunc("/bookmarks/graph", ws.handleBookmarkGraph)
+	mux.HandleFunc("/bookmarks/backlinks", ws.handleBacklinks)
+	mux.HandleFunc("/bookmarks/", ws.handleArchive) // Handles /bookmarks/{id}/archive, /bookmarks/{id}/archive/raw, /bookmarks/{id}/archive/screenshot, /bookmarks/{id}/archive/download, /bookmarks/{id}/archive/audio, /bookmarks/{id}/archive/provenance, /bookmarks/{id}/archive/timestamp, /bookmarks/{id}/archive/attempts, /bookmarks/{id}/archive/diff, /bookmarks/{id}/favicon, /bookmarks/{id}/links, /bookmarks/{id}/read, /bookmarks/{id}/notes, /bookmarks/{id}/mark-read, /bookmarks/{id}/favorite and /bookmarks/{id}/pin
 	mux.HandleFunc("/archives", ws.handleArchiveManager)
-	mux.HandleFunc("/archives/", ws.handleArchivesRoutes) // Handles /archives/list and /archives/{id}/refetch
+	mux.HandleFunc("/archives/", ws.handleArchivesRoutes) // Handles /archives/list, /archives/stats, /archives/storage and /archives/{id}/refetch
+	mux.HandleFunc("/import", ws.handleImport)
+	mux.HandleFunc("/activity", ws.handleActivity)
+	mux.HandleFunc("/settings", ws.handleSettings)
+	mux.HandleFunc("/settings/routing", ws.handleRoutingRules)
+	mux.HandleFunc("/settings/routing/", ws.handleRoutingRule) // Handles /settings/routing/{id}/enable, /disable and /delete
+	mux.HandleFunc("/settings/presets", ws.handlePresets)
+	mux.HandleFunc("/settings/presets/", ws.handlePreset) // Handles /settings/presets/{id}/delete
+	mux.HandleFunc("/settings/notifications", ws.handleNotifications)
+	mux.HandleFunc("/settings/landing", ws.handleLandingLayout)
+	mux.HandleFunc("/settings/shared", ws.handleSharedCollections)
+	mux.HandleFunc("/settings/shared/", ws.handleSharedCollectionAction) // Handles /settings/shared/{id}/delete
+	mux.HandleFunc(sharedPrefix, ws.handleSharedCollection)              // Handles /shared/{token} and /shared/{token}.json, without a login
+	mux.HandleFunc("/settings/account/export", ws.handleAccountExport)
+	mux.HandleFunc("/settings/account/delete", ws.handleAccountDelete)
+	mux.HandleFunc("/api/v1/launcher", ws.handleLauncher)
+	mux.HandleFunc("/api/version", ws.handleVersion)
+	mux.HandleFunc(davPrefix+"/", ws.handleDAV)     // Handles /dav/by-tag/... and /dav/by-date/...
+	mux.HandleFunc(podcastPrefix, ws.handlePodcast) // Handles /podcast/{tag}.rss and /podcast/audio/{id}/{version}.{ext}
 }
 
 func (ws *Server) registerStaticRoutes(mux *http.ServeMux) {
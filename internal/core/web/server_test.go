@@ -76,6 +76,8 @@ func TestNewServer(t *testing.T) {
 			"bookmarklet.html",
 			"bookmarklet_add.html",
 			"nav.html",
+			"reader.html",
+			"archive_diff.html",
 		}
 
 		for _, name := range requiredTemplates {
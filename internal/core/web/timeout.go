@@ -0,0 +1,178 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core"
+)
+
+// RouteTimeouts maps URL path patterns to how long requests to them may
+// take (see limitTime). A pattern ending in "/" matches every path under
+// it; any other is matched with path.Match, so "/bookmarks/*/archive/audio"
+// covers every bookmark's audio. The longest matching pattern wins, and a
+// zero timeout lets requests take as long as they need.
+type RouteTimeouts map[string]time.Duration
+
+// defaultRouteTimeouts are the routes that may take longer than
+// core.DefaultRequestTimeout: those that read or build whole collections
+// or talk to slow services. Routes that stream large files, such as
+// archived pages, screenshots and downloads, have none, since limitTime
+// would hold their whole response in memory; they stop when the client
+// goes away. Options.RouteTimeouts override them.
+var defaultRouteTimeouts = RouteTimeouts{
+	"/import":                         10 * time.Minute,
+	"/settings/account/export":        5 * time.Minute,
+	"/bookmarks/*/archive/audio":      core.DefaultTTSTimeout + time.Minute,
+	"/bookmarks/*/archive/raw":        0,
+	"/bookmarks/*/archive/screenshot": 0,
+	"/bookmarks/*/archive/download":   0,
+	"/archives/*/wayback":             core.DefaultWaybackTimeout + 30*time.Second,
+	"/archives/*/archive-today":       core.DefaultArchiveTodayTimeout + 30*time.Second,
+	davPrefix + "/":                   0,
+	podcastPrefix:                     0,
+}
+
+// ParseRouteTimeouts parses a comma-separated list of pattern=timeout
+// rules, e.g. "/import=30m,/dav/=0".
+func ParseRouteTimeouts(spec string) (RouteTimeouts, error) {
+	t := RouteTimeouts{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, value, ok := strings.Cut(part, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid route timeout %q: expected /path=timeout", part)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid route timeout %q: %w", part, err)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid route timeout %q: timeout must be a duration such as 30s, or 0 for none", part)
+		}
+		t[pattern] = d
+	}
+	return t, nil
+}
+
+// timeout returns how long a request for p may take: the timeout of the
+// longest pattern matching it, or def if none does.
+func (t RouteTimeouts) timeout(p string, def time.Duration) time.Duration {
+	best := ""
+	for pattern := range t {
+		if len(pattern) <= len(best) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, p); ok || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(p, pattern)) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return def
+	}
+	return t[best]
+}
+
+// limitTime bounds how long each request may take, as ws.routeTimeouts
+// set for its path (ws.requestTimeout by default). The request's context,
+// which database queries made through requestDB and userDB run under, is
+// canceled at the deadline, and the client gets a 503 with the timeout
+// error code even if the handler is still stuck, so a slow query or
+// template can't hold the connection open. Responses are buffered until
+// the handler returns, so routes that stream large files should have no
+// timeout.
+func (ws *Server) limitTime(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := ws.routeTimeouts.timeout(r.URL.Path, ws.requestTimeout)
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{h: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			for k, v := range tw.h {
+				w.Header()[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			_, _ = w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			tw.timedOut = true
+			tw.mu.Unlock()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return // the client went away
+			}
+			log.Printf("%s %s timed out after %s", r.Method, r.URL.Path, d)
+			writeErrorCode(w, r, http.StatusServiceUnavailable, errorCodeTimeout, "Request timed out")
+		}
+	})
+}
+
+// timeoutWriter holds a response until limitTime sends it, and discards
+// what the handler writes once the request has timed out.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	h        http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRouteTimeouts tests parsing route timeouts and matching them to paths.
+func TestRouteTimeouts(t *testing.T) {
+	got, err := ParseRouteTimeouts(" /import=30m, /bookmarks/*/archive/raw=1m,/dav/=0")
+	if err != nil {
+		t.Fatalf("ParseRouteTimeouts() error = %v", err)
+	}
+	if len(got) != 3 || got["/import"] != 30*time.Minute || got["/dav/"] != 0 {
+		t.Errorf("unexpected timeouts %v", got)
+	}
+	for _, spec := range []string{"import=1m", "/import", "/import=soon", "/import=-1s", "/[=1m"} {
+		if _, err := ParseRouteTimeouts(spec); err == nil {
+			t.Errorf("ParseRouteTimeouts(%q): expected error", spec)
+		}
+	}
+
+	timeouts := RouteTimeouts{
+		"/bookmarks/":                time.Minute,
+		"/bookmarks/*/archive/audio": time.Hour,
+		"/dav/":                      0,
+	}
+	for p, want := range map[string]time.Duration{
+		"/":                          time.Second,
+		"/bookmarks":                 time.Second,
+		"/bookmarks/7/read":          time.Minute,
+		"/bookmarks/7/archive/audio": time.Hour,
+		"/dav/by-tag/go/":            0,
+	} {
+		if got := timeouts.timeout(p, time.Second); got != want {
+			t.Errorf("timeout(%q) = %s, want %s", p, got, want)
+		}
+	}
+}
+
+// TestLimitTime tests that slow requests are answered with a 503 and their
+// context canceled, and that others pass through unchanged.
+func TestLimitTime(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	server.requestTimeout = 20 * time.Millisecond
+	server.routeTimeouts = RouteTimeouts{"/slow/unlimited": 0}
+	canceled := make(chan bool, 1)
+	handler := server.limitTime(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			w.Header().Set("X-Test", "yes")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("done"))
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			canceled <- true
+		case <-time.After(100 * time.Millisecond):
+			canceled <- false
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Test") != "yes" {
+		t.Errorf("expected the response passed through, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var got errorView
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusServiceUnavailable || got.Code != errorCodeTimeout {
+		t.Errorf("expected a 503 timeout error, got %d %q: %v", w.Code, w.Body.String(), err)
+	}
+	if !<-canceled {
+		t.Error("expected the handler's context to be canceled")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow/unlimited", nil))
+	if w.Code != http.StatusNoContent || <-canceled {
+		t.Errorf("expected a route without a timeout to finish, got %d", w.Code)
+	}
+}
+
+// TestLimitTimeStreamingRoutes tests that archive files are streamed to the
+// client rather than held by limitTime.
+func TestLimitTimeStreamingRoutes(t *testing.T) {
+	server := newTestServer(t)
+	t.Cleanup(func() {
+		if err := server.db.Close(); err != nil {
+			t.Errorf("failed to close db: %v", err)
+		}
+	})
+	for _, p := range []string{"/bookmarks/7/archive/download", "/bookmarks/7/archive/raw", "/bookmarks/my-slug/archive/screenshot"} {
+		var buffered bool
+		handler := server.limitTime(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, buffered = w.(*timeoutWriter)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+		if buffered {
+			t.Errorf("%s: expected the response not to be buffered", p)
+		}
+	}
+}
@@ -1,20 +1,453 @@
 package web
 
+import (
+	"html/template"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// bookmarkView backs the bookmark list and the JSON form of /bookmarks.
 type bookmarkView struct {
-	ID            int64
-	URL           string
-	Title         string
-	ArchiveStatus string // "", "ok", "error"
-	ArchivedAt    string
+	ID            int64    `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	CreatedAt     string   `json:"created_at"`
+	Slug          string   `json:"slug,omitempty"`
+	ArchiveStatus string   `json:"archive_status"` // "", "ok", "error", "skipped"
+	ArchivedAt    string   `json:"archived_at,omitempty"`
+	Tags          []string `json:"tags"`
+	Collection    string   `json:"collection,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	// Source is how the bookmark was added (see db.BookmarkSource); empty
+	// for bookmarks saved before sources were recorded.
+	Source string `json:"source,omitempty"`
+	// Notes is the Markdown source; NotesHTML is it rendered for the list.
+	Notes     string        `json:"notes"`
+	NotesHTML template.HTML `json:"-"`
+	// FaviconURL is the stored icon (/bookmarks/{id}/favicon) or the live one.
+	FaviconURL string `json:"favicon_url,omitempty"`
+	IsRead     bool   `json:"is_read"`
+	IsFavorite bool   `json:"is_favorite"`
+	// IsPinned is set for bookmarks pinned to the landing page.
+	IsPinned bool `json:"is_pinned"`
+	// Score explains a search result's rank; set only for explain=1 searches.
+	Score *db.ScoreExplanation `json:"score,omitempty"`
+	// Archive is where the bookmark's archive stands in the queue; set only
+	// when a bookmark is created.
+	Archive *archiveETAView `json:"archive,omitempty"`
+}
+
+// archiveETAView is a new bookmark's place in the archive queue.
+type archiveETAView struct {
+	// Status is "queued", "running", "deferred" (the queue was full; it
+	// will be queued once it drains) or "none" (not being archived).
+	Status               string  `json:"status"`
+	Ahead                int     `json:"ahead"`
+	EstimatedWaitSeconds float64 `json:"estimated_wait_seconds"`
+}
+
+// bookmarkListView is the JSON bookmark list with facets=1: the bookmarks
+// plus how many of them fall under each tag, domain, year and archive status.
+type bookmarkListView struct {
+	Bookmarks []bookmarkView `json:"bookmarks"`
+	Facets    facetsView     `json:"facets"`
+}
+
+// domainGroupView is one domain's section of the bookmark list grouped by
+// domain (/bookmarks?view=domains).
+type domainGroupView struct {
+	Domain   string `json:"domain"`
+	Count    int    `json:"count"`
+	Unread   int    `json:"unread"`
+	Archived int    `json:"archived"`
+	LatestAt string `json:"latest_at"`
+}
+
+// domainView is a domain on the domains page (/domains).
+type domainView struct {
+	Domain      string `json:"domain"`
+	Count       int    `json:"count"`
+	LastAddedAt string `json:"last_added_at"`
+}
+
+// tagView is a tag on the tags page (/tags) and in its autocomplete
+// suggestions (/tags/suggest).
+type tagView struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// tagChangeView reports a tag rename, merge or delete: the tag the
+// bookmarks now have ("" after a delete) and how many changed.
+type tagChangeView struct {
+	Tag       string `json:"tag"`
+	Bookmarks int    `json:"bookmarks"`
+}
+
+// errorView is the body of every JSON error response (see writeErrorView).
+type errorView struct {
+	// Code is one of the errorCode constants.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details carries what the failure is about, when there is more to
+	// say, such as when a limit lets up.
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type facetsView struct {
+	Tags    []facetCount `json:"tags"`
+	Domains []facetCount `json:"domains"`
+	Years   []facetCount `json:"years"`
+	// Statuses counts archive statuses: "ok", "error", "skipped" or "none".
+	Statuses []facetCount `json:"statuses"`
+	// Sources counts how the bookmarks were added, leaving out those
+	// without a recorded source.
+	Sources []facetCount `json:"sources"`
+}
+
+// sourceOptionView is an entry of the bookmark list's source menu.
+type sourceOptionView struct {
+	Value    string
+	Count    int
+	Selected bool
+}
+
+type facetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// bookmarkLinksView lists the outbound links in a bookmark's latest archive
+// and the bookmarks whose archives link to it.
+type bookmarkLinksView struct {
+	Outbound  []string       `json:"outbound"`
+	Backlinks []backlinkView `json:"backlinks"`
+}
+
+// backlinkView is a bookmark whose archive contains Link.
+type backlinkView struct {
+	ID    int64  `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+func newBacklinkViews(backlinks []db.Backlink) []backlinkView {
+	views := make([]backlinkView, 0, len(backlinks))
+	for _, b := range backlinks {
+		views = append(views, backlinkView{ID: b.BookmarkID, URL: b.URL, Title: b.Title, Link: b.LinkURL})
+	}
+	return views
+}
+
+// launcherView is the /api/v1/launcher response, in the Alfred Script
+// Filter shape that Raycast extensions read as well.
+type launcherView struct {
+	Items []launcherItemView `json:"items"`
+}
+
+// launcherItemView is one match. Arg, what the launcher opens, is the
+// original URL; the cmd modifier opens the archive instead, when there is
+// one.
+type launcherItemView struct {
+	UID        string                     `json:"uid"`
+	Title      string                     `json:"title"`
+	Subtitle   string                     `json:"subtitle"`
+	Arg        string                     `json:"arg"`
+	URL        string                     `json:"url"`
+	ArchiveURL string                     `json:"archive_url,omitempty"`
+	Mods       map[string]launcherModView `json:"mods,omitempty"`
+}
+
+type launcherModView struct {
+	Arg      string `json:"arg"`
+	Subtitle string `json:"subtitle"`
 }
 
 type archiveManagerView struct {
 	ID                 int64
 	URL                string
 	Title              string
-	ArchiveStatus      string // "", "ok", "error"
+	ArchiveStatus      string // "", "ok", "error", "skipped"
 	ArchivedAt         string
 	ArchiveAttemptedAt string
 	ArchiveError       string
-	IsArchiving        bool // true when archive is queued or in progress
+	ArchiveErrorCode   string // e.g. "timeout", see core.ClassifyArchiveError
+	IsArchiving        bool   // true when archive is queued or in progress
+	RearchiveDisabled  bool   // opted out of scheduled re-archiving
+	Size               string // the latest archive's size, or "" if unknown
+	FaviconURL         string
+	CSRFToken          string // lets the item's buttons post as plain forms
+	WaybackURL         string // a Wayback Machine snapshot of the page, if any
+	WaybackEnabled     bool   // pages can be submitted to the Wayback Machine
+	// ArchiveTodayURL is the page's archive.today snapshot, if any.
+	ArchiveTodayURL      string
+	ArchiveTodayDisabled bool // kept from being submitted to archive.today
+	ArchiveTodayEnabled  bool // pages can be submitted to archive.today
+}
+
+// waybackView is the JSON answer to /archives/{id}/wayback.
+type waybackView struct {
+	WaybackURL string `json:"wayback_url"`
+}
+
+// archiveTodayView is the JSON answer to /archives/{id}/archive-today.
+type archiveTodayView struct {
+	ArchiveTodayURL      string `json:"archive_today_url"`
+	ArchiveTodayDisabled bool   `json:"archive_today_disabled"`
+}
+
+// archiveStatsView backs the archive dashboard fragment and the JSON form of
+// /archives/stats.
+type archiveStatsView struct {
+	Pending    int `json:"pending"`
+	InProgress int `json:"in_progress"`
+	OK         int `json:"ok"`
+	Error      int `json:"error"`
+	// ErrorsByCode counts failed archives by archive error code.
+	ErrorsByCode       map[string]int `json:"errors_by_code"`
+	Skipped            int            `json:"skipped"`     // sites that asked not to be archived
+	QueueDepth         int            `json:"queue_depth"` // jobs due now
+	Retrying           int            `json:"retrying"`    // jobs waiting out a backoff
+	FailedJobs         int            `json:"failed_jobs"`
+	Deferred           int            `json:"deferred"` // jobs waiting for the queue to drain
+	AvgDurationSeconds float64        `json:"avg_duration_seconds"`
+	AvgDuration        string         `json:"-"` // e.g. "4.2s"
+	// EstimatedWaitSeconds is how long the workers should take to get
+	// through the jobs due now.
+	EstimatedWaitSeconds float64         `json:"estimated_wait_seconds"`
+	EstimatedWait        string          `json:"-"` // e.g. "2m30s"
+	Active               []activeJobView `json:"active"`
+}
+
+type activeJobView struct {
+	BookmarkID int64  `json:"bookmark_id"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	Attempts   int    `json:"attempts"`
+	StartedAt  string `json:"started_at"`
+	Elapsed    string `json:"-"` // e.g. "12s"
+}
+
+// storageView backs the storage card of the archive dashboard and the JSON
+// form of /archives/storage.
+type storageView struct {
+	UsedBytes      int64               `json:"used_bytes"`
+	Used           string              `json:"-"`          // e.g. "1.2 GB"
+	FreeBytes      int64               `json:"free_bytes"` // -1 if unknown
+	Free           string              `json:"-"`
+	BytesPerWeek   float64             `json:"bytes_per_week"`
+	WeeksUntilFull float64             `json:"weeks_until_full"` // -1 if it won't fill or can't tell
+	Summary        string              `json:"summary"`
+	Samples        []storageSampleView `json:"samples"`
+	Chart          storageChartView    `json:"-"`
+}
+
+type storageSampleView struct {
+	TakenAt           string `json:"taken_at"`
+	DatabaseBytes     int64  `json:"database_bytes"`
+	ArchiveStoreBytes int64  `json:"archive_store_bytes"`
+	FreeBytes         int64  `json:"free_bytes"`
+}
+
+// storageChartView holds SVG polyline points for the storage chart. Line is
+// empty with fewer than two days of samples.
+type storageChartView struct {
+	Width, Height int
+	Line          string
+	Projection    string
+	Top           string // label for the top of the y axis
+	Start, End    string // labels for the ends of the x axis
+}
+
+// preferenceView is one row of the settings form.
+type preferenceView struct {
+	Name  string
+	Label string
+	Help  string
+	// Value is "on", "off" or "" for the server default.
+	Value string
+}
+
+// notificationView is an event's row of notification preferences on the
+// settings page and in the JSON form of /settings/notifications.
+type notificationView struct {
+	Event    string                    `json:"event"`
+	Label    string                    `json:"-"`
+	Channels []notificationChannelView `json:"channels"`
+}
+
+// notificationChannelView is whether the user is notified of an event over
+// a channel.
+type notificationChannelView struct {
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// searchResultView is one live search result (/bookmarks/search): the
+// bookmark with the query's words marked in its title and URL, and a snippet
+// of the field that matched best, unless that was the title.
+type searchResultView struct {
+	bookmarkView
+	TitleHTML   template.HTML `json:"title_html"`
+	URLHTML     template.HTML `json:"url_html"`
+	SnippetHTML template.HTML `json:"snippet_html,omitempty"`
+}
+
+// triageView is the current user's unread triage for this week (/triage):
+// what they decided so far, how many bookmarks are left and the next one,
+// nil once they are done.
+type triageView struct {
+	Week      string        `json:"week"`
+	StartedAt string        `json:"started_at,omitempty"`
+	Kept      int           `json:"kept"`
+	Later     int           `json:"later"`
+	Archived  int           `json:"archived"`
+	Deleted   int           `json:"deleted"`
+	Done      int           `json:"done"`
+	Remaining int           `json:"remaining"`
+	Bookmark  *bookmarkView `json:"bookmark"`
+}
+
+// landingView is the landing page (/home): the user's widgets, in the order
+// their layout puts them.
+type landingView struct {
+	Widgets []landingWidgetView `json:"widgets"`
+}
+
+// landingWidgetView is one widget of the landing page. Which fields are set
+// depends on Name: pinned widgets have Bookmarks and Collections, recent and
+// unread ones Bookmarks, and the stats widget Stats.
+type landingWidgetView struct {
+	Name        string                 `json:"name"`
+	Label       string                 `json:"label"`
+	Bookmarks   []bookmarkView         `json:"bookmarks,omitempty"`
+	Collections []pinnedCollectionView `json:"collections,omitempty"`
+	Stats       *bookmarkCountsView    `json:"stats,omitempty"`
+}
+
+// pinnedCollectionView is a collection pinned to the landing page, with its
+// newest bookmarks.
+type pinnedCollectionView struct {
+	Collection string         `json:"collection"`
+	Count      int            `json:"count"`
+	Bookmarks  []bookmarkView `json:"bookmarks"`
+}
+
+// bookmarkCountsView is what the landing page's stats widget counts.
+type bookmarkCountsView struct {
+	Total     int `json:"total"`
+	Unread    int `json:"unread"`
+	Favorites int `json:"favorites"`
+	Pinned    int `json:"pinned"`
+	Archived  int `json:"archived"`
+}
+
+// landingLayoutView is the user's landing layout on the settings page and in
+// the JSON form of /settings/landing.
+type landingLayoutView struct {
+	// Widgets are the widgets shown, in order.
+	Widgets  []string `json:"widgets"`
+	ListSize int      `json:"list_size"`
+	// Positions has every widget, for the settings form: shown ones in
+	// order, then hidden ones. Slots are the positions to choose from.
+	Positions   []landingPositionView `json:"-"`
+	Slots       []int                 `json:"-"`
+	MaxListSize int                   `json:"-"`
+}
+
+// landingPositionView is where a widget is on the landing page; Position is
+// 0 for hidden widgets.
+type landingPositionView struct {
+	Name     string
+	Label    string
+	Position int
+}
+
+// sharedCollectionView is a shared collection as /shared/{token} shows it
+// and /shared/{token}.json publishes it.
+type sharedCollectionView struct {
+	Collection string               `json:"collection"`
+	URL        string               `json:"url"`
+	Bookmarks  []sharedBookmarkView `json:"bookmarks"`
+}
+
+// sharedBookmarkView is a bookmark in a shared collection.
+type sharedBookmarkView struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+	CreatedAt   string   `json:"created_at"`
+	Date        string   `json:"-"`
+}
+
+// sharedCollectionLinkView is one of the user's shared collections on the
+// settings page and in the JSON form of /settings/shared, with its public
+// URLs and an HTML snippet that embeds it.
+type sharedCollectionLinkView struct {
+	ID         int64  `json:"id"`
+	Collection string `json:"collection"`
+	JSONURL    string `json:"json_url"`
+	WidgetURL  string `json:"widget_url"`
+	Snippet    string `json:"snippet"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// routingRuleView is a routing rule on the settings page and in the JSON
+// form of /settings/routing.
+type routingRuleView struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Domain        string `json:"domain,omitempty"`
+	TitleContains string `json:"title_contains,omitempty"`
+	AddTag        string `json:"add_tag,omitempty"`
+	Collection    string `json:"collection,omitempty"`
+	SkipArchive   bool   `json:"skip_archive"`
+	Enabled       bool   `json:"enabled"`
+}
+
+// presetView is a bookmark preset on the settings page, in the add forms and
+// in the JSON form of /settings/presets. A null archive option keeps the
+// user's preference.
+type presetView struct {
+	ID             int64    `json:"id"`
+	Name           string   `json:"name"`
+	Tags           []string `json:"tags"`
+	Collection     string   `json:"collection,omitempty"`
+	SkipArchive    bool     `json:"skip_archive"`
+	StripScripts   *bool    `json:"strip_scripts"`
+	MobileViewport *bool    `json:"mobile_viewport"`
+	Screenshot     *bool    `json:"screenshot"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+// activityView is an activity log entry on /activity and in its JSON form.
+type activityView struct {
+	ID            int64  `json:"id"`
+	Kind          string `json:"kind"`
+	Label         string `json:"-"`
+	BookmarkID    int64  `json:"bookmark_id,omitempty"`
+	BookmarkURL   string `json:"bookmark_url,omitempty"`
+	BookmarkTitle string `json:"bookmark_title,omitempty"`
+	Detail        string `json:"detail,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// bookmarkletTokenView is a generated bookmarklet's token on /bookmarklet.
+type bookmarkletTokenView struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// generatedBookmarkletView is a newly generated bookmarklet. Its token can't
+// be shown again, so neither can the bookmarklet.
+type generatedBookmarkletView struct {
+	bookmarkletTokenView
+	ServerURL   string       `json:"server_url"`
+	Bookmarklet template.URL `json:"bookmarklet"`
+	// Preset names the preset the bookmarklet saves with, if any.
+	Preset string `json:"preset,omitempty"`
 }
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// Headers sent with each webhook delivery. The signature is
+// "sha256=" followed by the hex HMAC-SHA256 of the body, keyed by the
+// webhook's secret.
+const (
+	WebhookEventHeader     = "X-Bookmarkd-Event"
+	WebhookDeliveryHeader  = "X-Bookmarkd-Delivery"
+	WebhookSignatureHeader = "X-Bookmarkd-Signature"
+)
+
+// WebhookPingEvent is the event name of the test payload sent by
+// "bookmarkd webhooks test".
+const WebhookPingEvent = "ping"
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	// ID identifies the delivery; retries of it keep the same ID.
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	// CreatedAt is when the event happened, as RFC3339 text.
+	CreatedAt string `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+// webhookBookmark is a bookmark in webhook payloads.
+type webhookBookmark struct {
+	ID        int64  `json:"id"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+}
+
+// NewWebhookPayload returns the payload for an event, with a new delivery
+// ID.
+func NewWebhookPayload(event string, data any) (WebhookPayload, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return WebhookPayload{}, fmt.Errorf("failed to generate delivery ID: %w", err)
+	}
+	return WebhookPayload{
+		ID:        hex.EncodeToString(id),
+		Event:     event,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Data:      data,
+	}, nil
+}
+
+// webhookData returns the payload data for a DB event.
+func webhookData(event db.Event) any {
+	bookmark := func(b db.Bookmark) webhookBookmark {
+		return webhookBookmark{ID: b.ID, URL: b.URL, Title: b.Title, CreatedAt: b.CreatedAt}
+	}
+	switch ev := event.(type) {
+	case db.BookmarkCreatedEvent:
+		return map[string]any{"bookmark": bookmark(ev.Bookmark), "skip_archive": ev.SkipArchive}
+	case db.BookmarkUpdatedEvent:
+		return map[string]any{"bookmark": bookmark(ev.Bookmark)}
+	case db.BookmarkDeletedEvent:
+		return map[string]any{"bookmark": bookmark(ev.Bookmark)}
+	case db.ArchiveResultSavedEvent:
+		return map[string]any{"bookmark_id": ev.BookmarkID, "status": ev.Status, "error": ev.Error, "error_code": ev.ErrorCode}
+	case db.ArchiveClearedEvent:
+		return map[string]any{"bookmark_id": ev.BookmarkID}
+	case db.ImportFinishedEvent:
+		return map[string]any{"source": ev.Source, "added": ev.Added, "skipped": ev.Skipped, "invalid": ev.Invalid}
+	case db.ArchiveVisualChangeEvent:
+		// Before and After are base64-encoded JPEGs.
+		return map[string]any{"bookmark_id": ev.BookmarkID, "version_id": ev.VersionID, "previous_version_id": ev.PreviousVersionID,
+			"regions": ev.Regions, "changed_regions": ev.ChangedRegions, "distance": ev.Distance, "before": ev.Before, "after": ev.After}
+	default:
+		return map[string]any{}
+	}
+}
+
+// SignWebhookPayload returns the signature header value for body: "sha256="
+// and the hex HMAC-SHA256 of body keyed by secret. Receivers should compute
+// the same and compare in constant time.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookOptions configure a WebhookDispatcher. Zero values fall back to
+// DefaultWebhookWorkers, DefaultWebhookTimeout, DefaultWebhookAttempts and a
+// 10 second backoff.
+type WebhookOptions struct {
+	Workers  int
+	Timeout  time.Duration
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles after each.
+	Backoff time.Duration
+}
+
+// WebhookDispatcher delivers DB events to webhooks in the background. Each
+// delivery is tried up to Attempts times with exponential backoff, and its
+// outcome is recorded on the webhook.
+type WebhookDispatcher struct {
+	db       *db.DB
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	sem      chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher; call Register to start
+// sending events to it.
+func NewWebhookDispatcher(database *db.DB, opts WebhookOptions) *WebhookDispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWebhookWorkers
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWebhookTimeout
+	}
+	if opts.Attempts <= 0 {
+		opts.Attempts = DefaultWebhookAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 10 * time.Second
+	}
+	return &WebhookDispatcher{
+		db:       database,
+		client:   &http.Client{Timeout: opts.Timeout},
+		attempts: opts.Attempts,
+		backoff:  opts.Backoff,
+		sem:      make(chan struct{}, opts.Workers),
+	}
+}
+
+// Channel returns db.NotifyWebhook; with Notify, it makes the dispatcher a
+// Notifier.
+func (d *WebhookDispatcher) Channel() string {
+	return db.NotifyWebhook
+}
+
+// Notify dispatches event to the webhooks. Webhooks belong to the
+// instance, so every user's events go to the same ones.
+func (d *WebhookDispatcher) Notify(userID int64, event db.Event) error {
+	return d.Dispatch(event)
+}
+
+// Register adds event listeners that dispatch every DB event, regardless of
+// notification preferences; see NotificationDispatcher for those.
+func (d *WebhookDispatcher) Register() {
+	for _, kind := range db.EventKinds {
+		d.db.RegisterEventListener(kind, d.Dispatch)
+	}
+}
+
+// Dispatch starts delivering event to every enabled webhook subscribed to
+// it. It doesn't wait for the deliveries; failures are logged and recorded
+// on the webhook.
+func (d *WebhookDispatcher) Dispatch(event db.Event) error {
+	hooks, err := d.db.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	var targets []db.Webhook
+	for _, w := range hooks {
+		if w.Enabled && w.Wants(event.Kind()) {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	payload, err := NewWebhookPayload(event.Kind().String(), webhookData(event))
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	for _, w := range targets {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliverWithRetries(w, payload, body)
+		}()
+	}
+	return nil
+}
+
+// Wait blocks until every started delivery has finished or given up.
+func (d *WebhookDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *WebhookDispatcher) deliverWithRetries(w db.Webhook, payload WebhookPayload, body []byte) {
+	for attempt := 1; ; attempt++ {
+		d.sem <- struct{}{}
+		status, err := d.send(context.Background(), w, payload, body)
+		<-d.sem
+
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if rerr := d.db.RecordWebhookDelivery(w.ID, status, msg); rerr != nil {
+			log.Printf("Failed to record delivery for webhook %d: %v", w.ID, rerr)
+		}
+		if err == nil {
+			return
+		}
+		if attempt >= d.attempts {
+			log.Printf("Giving up on %s delivery %s to webhook %d after %d attempts: %v", payload.Event, payload.ID, w.ID, attempt, err)
+			return
+		}
+		time.Sleep(d.backoff << (attempt - 1))
+	}
+}
+
+// Deliver sends payload to w once and returns the response status. Any
+// status other than 2xx is an error.
+func (d *WebhookDispatcher) Deliver(ctx context.Context, w db.Webhook, payload WebhookPayload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	status, err := d.send(ctx, w, payload, body)
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	if rerr := d.db.RecordWebhookDelivery(w.ID, status, msg); rerr != nil {
+		log.Printf("Failed to record delivery for webhook %d: %v", w.ID, rerr)
+	}
+	return status, err
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, w db.Webhook, payload WebhookPayload, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set(WebhookEventHeader, payload.Event)
+	req.Header.Set(WebhookDeliveryHeader, payload.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(w.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close webhook response: %v", err)
+		}
+	}()
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
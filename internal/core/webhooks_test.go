@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seckatie/bookmarkd/internal/core/db"
+)
+
+// webhookReceiver records the deliveries sent to an httptest server.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	// fail is the number of requests to answer with 500 before succeeding.
+	fail int
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+	if rec.fail > 0 {
+		rec.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	t.Run("sends signed payloads for subscribed events", func(t *testing.T) {
+		database := newQueueTestDB(t)
+		rec := &webhookReceiver{}
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+
+		id, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Secret: "s3cret", Events: []string{"bookmark_created"}, Enabled: true})
+		if err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+		if _, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Secret: "off", Enabled: false}); err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+
+		d := NewWebhookDispatcher(database, WebhookOptions{Backoff: time.Millisecond})
+		d.Register()
+		b, err := database.AddBookmark("https://example.com/a", "A")
+		if err != nil {
+			t.Fatalf("failed to add bookmark: %v", err)
+		}
+		if err := database.DeleteBookmark(b); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		d.Wait()
+
+		if len(rec.requests) != 1 {
+			t.Fatalf("expected 1 delivery, got %d", len(rec.requests))
+		}
+		req, body := rec.requests[0], rec.bodies[0]
+		if got := req.Header.Get(WebhookSignatureHeader); got != SignWebhookPayload("s3cret", body) {
+			t.Errorf("signature %q doesn't match the body", got)
+		}
+		if req.Header.Get(WebhookEventHeader) != "bookmark_created" || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", req.Header)
+		}
+
+		var payload struct {
+			ID    string `json:"id"`
+			Event string `json:"event"`
+			Data  struct {
+				Bookmark webhookBookmark `json:"bookmark"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		if payload.ID != req.Header.Get(WebhookDeliveryHeader) || payload.Event != "bookmark_created" || payload.Data.Bookmark.URL != "https://example.com/a" {
+			t.Errorf("unexpected payload %s", body)
+		}
+
+		w, _ := database.GetWebhook(id)
+		if w.LastStatus != http.StatusNoContent || w.LastError != "" {
+			t.Errorf("expected the delivery to be recorded, got %+v", w)
+		}
+	})
+
+	t.Run("retries failed deliveries", func(t *testing.T) {
+		database := newQueueTestDB(t)
+		rec := &webhookReceiver{fail: 2}
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+
+		id, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Enabled: true})
+		if err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+		d := NewWebhookDispatcher(database, WebhookOptions{Attempts: 3, Backoff: time.Millisecond})
+		if err := d.Dispatch(db.ImportFinishedEvent{Source: "pocket", Added: 3}); err != nil {
+			t.Fatalf("failed to dispatch: %v", err)
+		}
+		d.Wait()
+
+		if len(rec.requests) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(rec.requests))
+		}
+		first, last := rec.requests[0].Header.Get(WebhookDeliveryHeader), rec.requests[2].Header.Get(WebhookDeliveryHeader)
+		if first == "" || first != last {
+			t.Errorf("expected retries to keep the delivery ID, got %q and %q", first, last)
+		}
+		w, _ := database.GetWebhook(id)
+		if w.LastStatus != http.StatusNoContent {
+			t.Errorf("expected the final success to be recorded, got %+v", w)
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		database := newQueueTestDB(t)
+		rec := &webhookReceiver{fail: 10}
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+
+		id, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Enabled: true})
+		if err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+		d := NewWebhookDispatcher(database, WebhookOptions{Attempts: 2, Backoff: time.Millisecond})
+		if err := d.Dispatch(db.ArchiveClearedEvent{BookmarkID: 7}); err != nil {
+			t.Fatalf("failed to dispatch: %v", err)
+		}
+		d.Wait()
+
+		if len(rec.requests) != 2 {
+			t.Fatalf("expected 2 attempts, got %d", len(rec.requests))
+		}
+		w, _ := database.GetWebhook(id)
+		if w.LastStatus != http.StatusInternalServerError || w.LastError == "" {
+			t.Errorf("expected the failure to be recorded, got %+v", w)
+		}
+	})
+
+	t.Run("delivers a ping synchronously", func(t *testing.T) {
+		database := newQueueTestDB(t)
+		rec := &webhookReceiver{}
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+
+		id, err := database.CreateWebhook(db.Webhook{URL: srv.URL, Enabled: true})
+		if err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+		w, _ := database.GetWebhook(id)
+		payload, err := NewWebhookPayload(WebhookPingEvent, nil)
+		if err != nil {
+			t.Fatalf("failed to build payload: %v", err)
+		}
+		status, err := NewWebhookDispatcher(database, WebhookOptions{}).Deliver(context.Background(), w, payload)
+		if err != nil || status != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d, %v", status, err)
+		}
+		if len(rec.requests) != 1 || rec.requests[0].Header.Get(WebhookEventHeader) != WebhookPingEvent {
+			t.Errorf("expected one ping, got %d requests", len(rec.requests))
+		}
+	})
+}